@@ -0,0 +1,522 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ems/backend/internal/agent/tool"
+	"github.com/ems/backend/internal/dto"
+	"github.com/ems/backend/internal/model"
+	"github.com/ems/backend/internal/repository"
+	"github.com/ems/backend/internal/service"
+)
+
+// PublishAgentTools publishes selected v1 endpoints as agent tools. Input schemas
+// are reflected from the same query DTOs the HTTP handlers bind, so each entry
+// below is the whole annotation. Must run after the Init* functions.
+func PublishAgentTools() {
+	rankingSchema := tool.SchemaOf([]service.EquipmentRanking{})
+	tool.Publish(tool.Spec{Name: "list_inspection_tasks", Description: "List inspection tasks filtered by inspector, status and date range", Scopes: []string{"read:inspection"}, ReadOnly: true}, listInspectionTasksTool)
+	tool.Publish(tool.Spec{Name: "get_inspection_statistics", Description: "Get inspection task counts by status and completion rate", Scopes: []string{"read:inspection"}, ReadOnly: true}, inspectionStatisticsTool)
+	tool.Publish(tool.Spec{Name: "list_maintenance_tasks", Description: "List maintenance tasks filtered by assignee, status and date range", Scopes: []string{"read:maintenance"}, ReadOnly: true}, listMaintenanceTasksTool)
+	tool.Publish(tool.Spec{Name: "get_maintenance_statistics", Description: "Get maintenance task counts by status and completion rate", Scopes: []string{"read:maintenance"}, ReadOnly: true}, maintenanceStatisticsTool)
+	tool.Publish(tool.Spec{Name: "list_spare_parts", Description: "Search the spare part catalog by code or name", Scopes: []string{"read:sparepart"}, ReadOnly: true}, listSparePartsTool)
+	tool.Publish(tool.Spec{Name: "list_spare_part_inventory", Description: "List spare part stock per factory", Scopes: []string{"read:sparepart"}, ReadOnly: true}, listInventoryTool)
+	tool.Publish(tool.Spec{Name: "get_low_stock_alerts", Description: "List spare parts whose stock is below safety stock", Scopes: []string{"read:sparepart"}, ReadOnly: true, OutputSchema: tool.SchemaOf([]dto.LowStockAlert{})}, lowStockAlertsTool)
+	tool.Publish(tool.Spec{Name: "get_inspection_history", Description: "Get an equipment's recent inspection tasks with per-item OK/NG results", Scopes: []string{"read:inspection"}, ReadOnly: true}, inspectionHistoryTool)
+	tool.Publish(tool.Spec{Name: "get_inspection_ng_trend", Description: "Count NG inspection results per day for an equipment or the whole factory", Scopes: []string{"read:inspection"}, ReadOnly: true, OutputSchema: tool.SchemaOf([]repository.NGTrendPoint{})}, inspectionNGTrendTool)
+	tool.Publish(tool.Spec{Name: "get_maintenance_calendar", Description: "List maintenance tasks grouped by scheduled date for a date range", Scopes: []string{"read:maintenance"}, ReadOnly: true}, maintenanceCalendarTool)
+	tool.Publish(tool.Spec{Name: "create_maintenance_task", Description: "Schedule a maintenance task for an equipment under a maintenance plan", Scopes: []string{"write:maintenance"}}, createMaintenanceTaskTool)
+	tool.Publish(tool.Spec{Name: "reschedule_maintenance_task", Description: "Move a pending or overdue maintenance task to a new date", Scopes: []string{"write:maintenance"}}, rescheduleMaintenanceTaskTool)
+	tool.Publish(tool.Spec{Name: "reserve_spare_part", Description: "Hold spare parts for a repair order or maintenance task without stocking out. Held stock cannot be issued to other orders until it is released, issued for this order, or expires", Scopes: []string{"write:sparepart"}}, reserveSparePartTool)
+	tool.Publish(tool.Spec{Name: "release_spare_part_reservation", Description: "Release an active spare part reservation so the stock becomes available again", Scopes: []string{"write:sparepart"}}, releaseSparePartReservationTool)
+	tool.Publish(tool.Spec{Name: "issue_spare_part", Description: "Issue (stock out) spare parts for a repair order or maintenance task, consuming any reservation the order holds. The quantity is permanently deducted from factory stock and recorded as consumption, so confirm with the user first and pass confirm=true", Scopes: []string{"write:sparepart"}}, issueSparePartTool)
+	tool.Publish(tool.Spec{Name: "search_repair_orders", Description: "Search repair orders by fault code, equipment, status, priority and creation date", Scopes: []string{"read:repair"}, ReadOnly: true}, searchRepairOrdersTool)
+	tool.Publish(tool.Spec{Name: "get_mtbf_ranking", Description: "Rank equipment by mean time between failures (hours, lowest first)", Scopes: []string{"read:analytics"}, ReadOnly: true, OutputSchema: rankingSchema}, rankingTool(analyticsService.GetMTBFRanking))
+	tool.Publish(tool.Spec{Name: "get_downtime_ranking", Description: "Rank equipment by total downtime hours", Scopes: []string{"read:analytics"}, ReadOnly: true, OutputSchema: rankingSchema}, rankingTool(analyticsService.GetDowntimeRanking))
+	tool.Publish(tool.Spec{Name: "get_performance_ranking", Description: "Rank equipment by maintenance performance score", Scopes: []string{"read:analytics"}, ReadOnly: true, OutputSchema: rankingSchema}, rankingTool(analyticsService.GetPerformanceRanking))
+}
+
+// toolFactoryScope 非管理员只能查询本工厂数据
+func toolFactoryScope(user model.User, requested *uint) *uint {
+	if user.Role == model.RoleAdmin {
+		return requested
+	}
+	return user.FactoryID
+}
+
+// toolEquipmentInScope 加载设备并校验其所属工厂
+func toolEquipmentInScope(user model.User, equipmentID uint) (*model.Equipment, error) {
+	equipment, err := equipmentService.GetByID(equipmentID)
+	if err != nil {
+		return nil, fmt.Errorf("equipment %d not found", equipmentID)
+	}
+	if err := toolCheckEquipmentFactory(user, equipment); err != nil {
+		return nil, err
+	}
+	return equipment, nil
+}
+
+// toolCheckEquipmentFactory 非管理员只能操作本工厂的设备
+func toolCheckEquipmentFactory(user model.User, equipment *model.Equipment) error {
+	if user.Role != model.RoleAdmin && user.FactoryID != nil &&
+		(equipment.Workshop == nil || equipment.Workshop.FactoryID != *user.FactoryID) {
+		return fmt.Errorf("permission denied: equipment belongs to another factory")
+	}
+	return nil
+}
+
+// 写操作工具允许的角色（管理员始终允许）
+var (
+	maintenanceWriteRoles = []model.UserRole{model.RoleSupervisor, model.RoleEngineer}
+	sparePartIssueRoles   = []model.UserRole{model.RoleSupervisor, model.RoleEngineer, model.RoleMaintenance}
+)
+
+// toolRequireRole 写操作工具在 scope 之外还按角色限制
+func toolRequireRole(user model.User, roles ...model.UserRole) error {
+	if user.Role == model.RoleAdmin {
+		return nil
+	}
+	for _, r := range roles {
+		if user.Role == r {
+			return nil
+		}
+	}
+	return fmt.Errorf("permission denied: role %s cannot perform this action", user.Role)
+}
+
+// parseRequiredToolDate 写操作的日期必须合法，不能静默当作零值
+func parseRequiredToolDate(field, s string) (time.Time, error) {
+	t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be a date in YYYY-MM-DD format, got %q", field, s)
+	}
+	return t, nil
+}
+
+func parseToolDate(s string) time.Time {
+	t, _ := time.Parse("2006-01-02", s)
+	return t
+}
+
+func toolPage(page, pageSize int) (int, int) {
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 20
+	}
+	return page, pageSize
+}
+
+func listInspectionTasksTool(_ context.Context, user model.User, query dto.InspectionTaskQuery) (interface{}, error) {
+	filter := &service.InspectionTaskFilter{
+		AssignedTo: query.AssignedTo,
+		Status:     query.Status,
+		DateFrom:   parseToolDate(query.DateFrom),
+		DateTo:     parseToolDate(query.DateTo),
+		FactoryID:  toolFactoryScope(user, nil),
+	}
+	filter.Page, filter.PageSize = toolPage(query.Page, query.PageSize)
+
+	result, err := inspectionTaskService.List(filter)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]dto.InspectionTaskResponse, len(result.Items))
+	for i, task := range result.Items {
+		items[i] = inspectionTaskToResponse(&task)
+	}
+	return dto.InspectionTaskListResponse{Total: result.Total, Items: items}, nil
+}
+
+func inspectionStatisticsTool(_ context.Context, user model.User, _ struct{}) (interface{}, error) {
+	return inspectionTaskService.GetStatistics(toolFactoryScope(user, nil))
+}
+
+func listMaintenanceTasksTool(_ context.Context, user model.User, query dto.MaintenanceTaskQuery) (interface{}, error) {
+	filter := &service.MaintenanceTaskFilter{
+		Status:     query.Status,
+		AssignedTo: query.AssignedTo,
+		DateFrom:   parseToolDate(query.DateFrom),
+		DateTo:     parseToolDate(query.DateTo),
+	}
+	if fid := toolFactoryScope(user, nil); fid != nil {
+		filter.FactoryID = *fid
+	}
+	filter.Page, filter.PageSize = toolPage(query.Page, query.PageSize)
+
+	result, err := maintenanceTaskService.List(filter)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]dto.MaintenanceTaskResponse, len(result.Items))
+	for i, task := range result.Items {
+		items[i] = taskToResponse(&task)
+	}
+	return dto.MaintenanceTaskListResponse{Total: result.Total, Items: items}, nil
+}
+
+func maintenanceStatisticsTool(_ context.Context, user model.User, _ struct{}) (interface{}, error) {
+	return maintenanceTaskService.GetStatistics(toolFactoryScope(user, nil))
+}
+
+func listSparePartsTool(_ context.Context, user model.User, query dto.SparePartQuery) (interface{}, error) {
+	filter := repository.SparePartFilter{Code: query.Code, Name: query.Name, FactoryID: toolFactoryScope(user, nil)}
+	filter.Page, filter.PageSize = toolPage(query.Page, query.PageSize)
+
+	result, err := sparePartService.ListParts(filter)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]dto.SparePartResponse, len(result.Items))
+	for i, p := range result.Items {
+		items[i] = dto.SparePartResponse{
+			ID: p.ID, Code: p.Code, Name: p.Name, Specification: p.Specification,
+			Unit: p.Unit, FactoryID: p.FactoryID, SafetyStock: p.SafetyStock, CreatedAt: p.CreatedAt,
+		}
+	}
+	return dto.SparePartListResponse{Total: result.Total, Items: items}, nil
+}
+
+func listInventoryTool(_ context.Context, user model.User, query dto.InventoryQuery) (interface{}, error) {
+	filter := repository.InventoryFilter{
+		SparePartID: query.SparePartID,
+		FactoryID:   toolFactoryScope(user, query.FactoryID),
+		LowStock:    query.LowStock,
+	}
+	filter.Page, filter.PageSize = toolPage(query.Page, query.PageSize)
+
+	result, err := sparePartService.GetInventory(filter)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]dto.InventoryResponse, len(result.Items))
+	for i, inv := range result.Items {
+		items[i] = dto.InventoryResponse{
+			ID: inv.ID, SparePartID: inv.SparePartID, SparePartCode: inv.SparePart.Code, SparePartName: inv.SparePart.Name,
+			FactoryID: inv.FactoryID, FactoryName: inv.Factory.Name, Quantity: inv.Quantity,
+			IsLowStock: inv.SparePart.ID > 0 && inv.Quantity < inv.SparePart.SafetyStock, UpdatedAt: inv.UpdatedAt,
+		}
+	}
+	return dto.InventoryListResponse{Total: result.Total, Items: items}, nil
+}
+
+func lowStockAlertsTool(_ context.Context, user model.User, _ struct{}) (interface{}, error) {
+	alerts, err := sparePartService.GetLowStockAlerts()
+	if err != nil {
+		return nil, err
+	}
+	fid := toolFactoryScope(user, nil)
+	items := make([]dto.LowStockAlert, 0, len(alerts))
+	for _, a := range alerts {
+		if fid != nil && a.FactoryID != *fid {
+			continue
+		}
+		items = append(items, dto.LowStockAlert{
+			SparePartID: a.SparePartID, SparePartCode: a.SparePartCode, SparePartName: a.SparePartName,
+			FactoryID: a.FactoryID, FactoryName: a.FactoryName,
+			CurrentStock: a.CurrentStock, SafetyStock: a.SafetyStock, Shortage: a.Shortage,
+		})
+	}
+	return items, nil
+}
+
+func inspectionHistoryTool(_ context.Context, user model.User, query dto.InspectionHistoryQuery) (interface{}, error) {
+	if _, err := toolEquipmentInScope(user, query.EquipmentID); err != nil {
+		return nil, err
+	}
+	tasks, err := inspectionTaskService.GetEquipmentHistory(query.EquipmentID, parseToolDate(query.DateFrom), parseToolDate(query.DateTo), query.Limit)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]dto.InspectionTaskResponse, len(tasks))
+	for i, task := range tasks {
+		items[i] = inspectionTaskToResponse(&task)
+	}
+	return items, nil
+}
+
+func inspectionNGTrendTool(_ context.Context, user model.User, query dto.NGTrendQuery) (interface{}, error) {
+	if query.EquipmentID > 0 {
+		if _, err := toolEquipmentInScope(user, query.EquipmentID); err != nil {
+			return nil, err
+		}
+	}
+	points, err := inspectionTaskService.GetNGTrend(query.EquipmentID, toolFactoryScope(user, nil), query.Days)
+	if err != nil {
+		return nil, err
+	}
+	if points == nil {
+		points = []repository.NGTrendPoint{}
+	}
+	return points, nil
+}
+
+func maintenanceCalendarTool(_ context.Context, user model.User, query dto.MaintenanceCalendarQuery) (interface{}, error) {
+	from, err := parseRequiredToolDate("date_from", query.DateFrom)
+	if err != nil {
+		return nil, err
+	}
+	to, err := parseRequiredToolDate("date_to", query.DateTo)
+	if err != nil {
+		return nil, err
+	}
+	if to.Before(from) || to.Sub(from) > 62*24*time.Hour {
+		return nil, fmt.Errorf("date_to must be within 62 days after date_from")
+	}
+
+	filter := &service.MaintenanceTaskFilter{
+		Status: query.Status, AssignedTo: query.AssignedTo,
+		DateFrom: from, DateTo: to, Page: 1, PageSize: 500,
+	}
+	if fid := toolFactoryScope(user, nil); fid != nil {
+		filter.FactoryID = *fid
+	}
+	result, err := maintenanceTaskService.List(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	days := []dto.MaintenanceCalendarDay{}
+	index := map[string]int{}
+	// List 按日期倒序返回，日历按日期正序展示
+	for i := len(result.Items) - 1; i >= 0; i-- {
+		task := result.Items[i]
+		pos, ok := index[task.ScheduledDate]
+		if !ok {
+			pos = len(days)
+			index[task.ScheduledDate] = pos
+			days = append(days, dto.MaintenanceCalendarDay{Date: task.ScheduledDate})
+		}
+		days[pos].Tasks = append(days[pos].Tasks, taskToResponse(&task))
+	}
+	return map[string]interface{}{
+		"days":      days,
+		"total":     result.Total,
+		"truncated": result.Total > int64(len(result.Items)),
+	}, nil
+}
+
+func createMaintenanceTaskTool(_ context.Context, user model.User, req dto.CreateMaintenanceTaskRequest) (interface{}, error) {
+	if err := toolRequireRole(user, maintenanceWriteRoles...); err != nil {
+		return nil, err
+	}
+	if _, err := toolEquipmentInScope(user, req.EquipmentID); err != nil {
+		return nil, err
+	}
+	date, err := parseRequiredToolDate("scheduled_date", req.ScheduledDate)
+	if err != nil {
+		return nil, err
+	}
+	task, err := maintenanceTaskService.CreateTask(&service.CreateMaintenanceTaskRequest{
+		PlanID: req.PlanID, EquipmentID: req.EquipmentID, ScheduledDate: date,
+		AssignedTo: req.AssignedTo, Remark: req.Remark, CreatedBy: user.ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// 重新加载以带出计划、设备与执行人
+	task, err = maintenanceTaskService.GetByID(task.ID)
+	if err != nil {
+		return nil, err
+	}
+	return taskToResponse(task), nil
+}
+
+func rescheduleMaintenanceTaskTool(_ context.Context, user model.User, req dto.RescheduleMaintenanceTaskRequest) (interface{}, error) {
+	if err := toolRequireRole(user, maintenanceWriteRoles...); err != nil {
+		return nil, err
+	}
+	task, err := maintenanceTaskService.GetByID(req.TaskID)
+	if err != nil {
+		return nil, fmt.Errorf("maintenance task %d not found", req.TaskID)
+	}
+	if _, err := toolEquipmentInScope(user, task.EquipmentID); err != nil {
+		return nil, err
+	}
+	date, err := parseRequiredToolDate("scheduled_date", req.ScheduledDate)
+	if err != nil {
+		return nil, err
+	}
+	task, err = maintenanceTaskService.RescheduleTask(req.TaskID, date, req.Reason)
+	if err != nil {
+		return nil, err
+	}
+	return taskToResponse(task), nil
+}
+
+// checkStockTarget 校验领用/预留对象与出库工厂，不访问数据库
+func checkStockTarget(user model.User, orderID, taskID, factoryID *uint) (*uint, error) {
+	if err := toolRequireRole(user, sparePartIssueRoles...); err != nil {
+		return nil, err
+	}
+	if (orderID == nil) == (taskID == nil) {
+		return nil, fmt.Errorf("exactly one of repair_order_id or maintenance_task_id is required")
+	}
+	factory := toolFactoryScope(user, factoryID)
+	if factory == nil {
+		return nil, fmt.Errorf("factory_id is required")
+	}
+	return factory, nil
+}
+
+// checkIssueSparePart 校验领用请求本身，不访问数据库
+func checkIssueSparePart(user model.User, req dto.IssueSparePartRequest) (*uint, error) {
+	factoryID, err := checkStockTarget(user, req.RepairOrderID, req.MaintenanceTaskID, req.FactoryID)
+	if err != nil {
+		return nil, err
+	}
+	if !req.Confirm {
+		return nil, fmt.Errorf("issuing spare parts permanently deducts stock; confirm with the user and call again with confirm=true")
+	}
+	return factoryID, nil
+}
+
+// toolCheckStockFactory 领用对象的设备必须属于出库工厂
+func toolCheckStockFactory(equipment *model.Equipment, factoryID uint) error {
+	if equipment.Workshop == nil || equipment.Workshop.FactoryID != factoryID {
+		return fmt.Errorf("permission denied: the order belongs to another factory than the stock location")
+	}
+	return nil
+}
+
+// toolCheckStockOrder 加载工单或保养任务，校验其设备在调用者范围内且属于出库工厂
+func toolCheckStockOrder(user model.User, orderID, taskID *uint, factoryID uint) error {
+	var equipmentID uint
+	if orderID != nil {
+		order, err := repairOrderService.GetByID(*orderID)
+		if err != nil {
+			return fmt.Errorf("repair order %d not found", *orderID)
+		}
+		equipmentID = order.EquipmentID
+	} else {
+		task, err := maintenanceTaskService.GetByID(*taskID)
+		if err != nil {
+			return fmt.Errorf("maintenance task %d not found", *taskID)
+		}
+		equipmentID = task.EquipmentID
+	}
+	equipment, err := toolEquipmentInScope(user, equipmentID)
+	if err != nil {
+		return err
+	}
+	return toolCheckStockFactory(equipment, factoryID)
+}
+
+func issueSparePartTool(_ context.Context, user model.User, req dto.IssueSparePartRequest) (interface{}, error) {
+	factoryID, err := checkIssueSparePart(user, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := toolCheckStockOrder(user, req.RepairOrderID, req.MaintenanceTaskID, *factoryID); err != nil {
+		return nil, err
+	}
+
+	remark := "智能体领用"
+	if req.Remark != "" {
+		remark += "：" + req.Remark
+	}
+	if err := sparePartService.StockOut(req.SparePartID, *factoryID, req.Quantity, req.RepairOrderID, req.MaintenanceTaskID, remark, user.ID); err != nil {
+		return nil, err
+	}
+
+	result := map[string]interface{}{"spare_part_id": req.SparePartID, "factory_id": *factoryID, "issued": req.Quantity}
+	if inv, err := sparePartService.GetInventory(repository.InventoryFilter{SparePartID: &req.SparePartID, FactoryID: factoryID, Page: 1, PageSize: 1}); err == nil && len(inv.Items) > 0 {
+		result["remaining"] = inv.Items[0].Quantity
+		result["below_safety_stock"] = inv.Items[0].SparePart.ID > 0 && inv.Items[0].Quantity < inv.Items[0].SparePart.SafetyStock
+	}
+	return result, nil
+}
+
+// 预留默认保留 24 小时
+const defaultReservationHours = 24
+
+func reserveSparePartTool(_ context.Context, user model.User, req dto.ReserveSparePartRequest) (interface{}, error) {
+	factoryID, err := checkStockTarget(user, req.RepairOrderID, req.MaintenanceTaskID, req.FactoryID)
+	if err != nil {
+		return nil, err
+	}
+	if err := toolCheckStockOrder(user, req.RepairOrderID, req.MaintenanceTaskID, *factoryID); err != nil {
+		return nil, err
+	}
+
+	hours := req.ExpiresInHours
+	if hours <= 0 {
+		hours = defaultReservationHours
+	}
+	remark := "智能体预留"
+	if req.Remark != "" {
+		remark += "：" + req.Remark
+	}
+	reservation, available, err := sparePartService.Reserve(req.SparePartID, *factoryID, req.Quantity,
+		req.RepairOrderID, req.MaintenanceTaskID, time.Duration(hours)*time.Hour, remark, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"reservation_id": reservation.ID, "spare_part_id": req.SparePartID, "factory_id": *factoryID,
+		"reserved": req.Quantity, "expires_at": reservation.ExpiresAt, "available": available,
+	}, nil
+}
+
+// toolCheckReservationFactory 非管理员只能释放本工厂的预留
+func toolCheckReservationFactory(user model.User, reservation *model.SparePartReservation) error {
+	if user.Role != model.RoleAdmin && (user.FactoryID == nil || reservation.FactoryID != *user.FactoryID) {
+		return fmt.Errorf("permission denied: reservation belongs to another factory")
+	}
+	return nil
+}
+
+func releaseSparePartReservationTool(_ context.Context, user model.User, req dto.ReleaseSparePartReservationRequest) (interface{}, error) {
+	if err := toolRequireRole(user, sparePartIssueRoles...); err != nil {
+		return nil, err
+	}
+	reservation, err := sparePartService.GetReservation(req.ReservationID)
+	if err != nil {
+		return nil, fmt.Errorf("reservation %d not found", req.ReservationID)
+	}
+	if err := toolCheckReservationFactory(user, reservation); err != nil {
+		return nil, err
+	}
+	if err := sparePartService.ReleaseReservation(reservation.ID); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"reservation_id": reservation.ID, "released": reservation.Quantity}, nil
+}
+
+func searchRepairOrdersTool(_ context.Context, user model.User, query dto.RepairOrderSearchQuery) (interface{}, error) {
+	filter := &service.RepairOrderFilter{
+		FaultCode:   query.FaultCode,
+		EquipmentID: query.EquipmentID,
+		Status:      query.Status,
+		Priority:    query.Priority,
+		DateFrom:    parseToolDate(query.DateFrom),
+		DateTo:      parseToolDate(query.DateTo),
+	}
+	if fid := toolFactoryScope(user, nil); fid != nil {
+		filter.FactoryID = *fid
+	}
+	filter.Page, filter.PageSize = toolPage(query.Page, query.PageSize)
+
+	result, err := repairOrderService.List(filter)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]dto.RepairOrderResponse, len(result.Items))
+	for i, order := range result.Items {
+		items[i] = orderToResponse(&order)
+	}
+	return map[string]interface{}{"total": result.Total, "items": items}, nil
+}
+
+func rankingTool(rank func(limit int, factoryID *uint) ([]service.EquipmentRanking, error)) func(context.Context, model.User, dto.RankingQuery) (interface{}, error) {
+	return func(_ context.Context, user model.User, query dto.RankingQuery) (interface{}, error) {
+		limit := query.Limit
+		if limit <= 0 || limit > 100 {
+			limit = 10
+		}
+		return rank(limit, toolFactoryScope(user, query.FactoryID))
+	}
+}
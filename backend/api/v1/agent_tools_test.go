@@ -0,0 +1,167 @@
+package v1
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ems/backend/internal/dto"
+	"github.com/ems/backend/internal/model"
+)
+
+func uintPtr(v uint) *uint { return &v }
+
+func equipmentInFactory(factoryID uint) *model.Equipment {
+	return &model.Equipment{Workshop: &model.Workshop{FactoryID: factoryID}}
+}
+
+func TestWriteToolsRejectRolesBeforeLoading(t *testing.T) {
+	// 角色不允许时在访问任何服务之前拒绝（测试中服务均未初始化）
+	operator := model.User{Role: model.RoleOperator, FactoryID: uintPtr(1)}
+	calls := map[string]func() error{
+		"create_maintenance_task": func() error {
+			_, err := createMaintenanceTaskTool(context.Background(), operator, dto.CreateMaintenanceTaskRequest{EquipmentID: 1, ScheduledDate: "2026-05-01"})
+			return err
+		},
+		"reschedule_maintenance_task": func() error {
+			_, err := rescheduleMaintenanceTaskTool(context.Background(), operator, dto.RescheduleMaintenanceTaskRequest{TaskID: 1, ScheduledDate: "2026-05-01"})
+			return err
+		},
+		"issue_spare_part": func() error {
+			_, err := issueSparePartTool(context.Background(), operator, dto.IssueSparePartRequest{SparePartID: 1, Quantity: 1, RepairOrderID: uintPtr(1), Confirm: true})
+			return err
+		},
+		"reserve_spare_part": func() error {
+			_, err := reserveSparePartTool(context.Background(), operator, dto.ReserveSparePartRequest{SparePartID: 1, Quantity: 1, RepairOrderID: uintPtr(1)})
+			return err
+		},
+		"release_spare_part_reservation": func() error {
+			_, err := releaseSparePartReservationTool(context.Background(), operator, dto.ReleaseSparePartReservationRequest{ReservationID: 1})
+			return err
+		},
+	}
+	for name, call := range calls {
+		if err := call(); err == nil || !strings.Contains(err.Error(), "permission denied") {
+			t.Errorf("%s: expected permission denied for operator, got %v", name, err)
+		}
+	}
+}
+
+func TestToolRequireRole(t *testing.T) {
+	cases := []struct {
+		role  model.UserRole
+		roles []model.UserRole
+		want  bool
+	}{
+		{model.RoleAdmin, maintenanceWriteRoles, true},
+		{model.RoleSupervisor, maintenanceWriteRoles, true},
+		{model.RoleEngineer, maintenanceWriteRoles, true},
+		{model.RoleMaintenance, maintenanceWriteRoles, false},
+		{model.RoleOperator, maintenanceWriteRoles, false},
+		{model.RoleMaintenance, sparePartIssueRoles, true},
+		{model.RoleOperator, sparePartIssueRoles, false},
+	}
+	for _, c := range cases {
+		if got := toolRequireRole(model.User{Role: c.role}, c.roles...) == nil; got != c.want {
+			t.Errorf("%s with %v: expected allowed=%v", c.role, c.roles, c.want)
+		}
+	}
+}
+
+func TestToolFactoryScope(t *testing.T) {
+	own := model.User{Role: model.RoleEngineer, FactoryID: uintPtr(1)}
+	if got := toolFactoryScope(own, uintPtr(2)); got == nil || *got != 1 {
+		t.Errorf("Expected non-admin to be pinned to factory 1, got %v", got)
+	}
+	admin := model.User{Role: model.RoleAdmin}
+	if got := toolFactoryScope(admin, uintPtr(2)); got == nil || *got != 2 {
+		t.Errorf("Expected admin to use the requested factory, got %v", got)
+	}
+	if got := toolFactoryScope(admin, nil); got != nil {
+		t.Errorf("Expected admin without factory to be unscoped, got %v", *got)
+	}
+}
+
+func TestToolCheckEquipmentFactory(t *testing.T) {
+	engineer := model.User{Role: model.RoleEngineer, FactoryID: uintPtr(1)}
+	if err := toolCheckEquipmentFactory(engineer, equipmentInFactory(1)); err != nil {
+		t.Errorf("Expected same-factory equipment to be allowed, got %v", err)
+	}
+	if err := toolCheckEquipmentFactory(engineer, equipmentInFactory(2)); err == nil {
+		t.Error("Expected equipment from another factory to be rejected")
+	}
+	if err := toolCheckEquipmentFactory(engineer, &model.Equipment{}); err == nil {
+		t.Error("Expected equipment without workshop to be rejected")
+	}
+	if err := toolCheckEquipmentFactory(model.User{Role: model.RoleAdmin}, equipmentInFactory(2)); err != nil {
+		t.Errorf("Expected admin to reach any factory, got %v", err)
+	}
+}
+
+func TestCheckIssueSparePart(t *testing.T) {
+	maintainer := model.User{Role: model.RoleMaintenance, FactoryID: uintPtr(1)}
+	valid := dto.IssueSparePartRequest{SparePartID: 1, Quantity: 2, RepairOrderID: uintPtr(5), FactoryID: uintPtr(2), Confirm: true}
+
+	factoryID, err := checkIssueSparePart(maintainer, valid)
+	if err != nil || factoryID == nil || *factoryID != 1 {
+		t.Fatalf("Expected non-admin to issue from own factory 1, got %v, %v", factoryID, err)
+	}
+
+	unconfirmed := valid
+	unconfirmed.Confirm = false
+	if _, err := checkIssueSparePart(maintainer, unconfirmed); err == nil || !strings.Contains(err.Error(), "confirm") {
+		t.Errorf("Expected unconfirmed issue to be rejected, got %v", err)
+	}
+
+	both := valid
+	both.MaintenanceTaskID = uintPtr(6)
+	if _, err := checkIssueSparePart(maintainer, both); err == nil {
+		t.Error("Expected request naming both an order and a task to be rejected")
+	}
+
+	noFactory := valid
+	noFactory.FactoryID = nil
+	if _, err := checkIssueSparePart(model.User{Role: model.RoleAdmin}, noFactory); err == nil {
+		t.Error("Expected admin without factory_id to be rejected")
+	}
+}
+
+func TestToolCheckStockFactory(t *testing.T) {
+	if err := toolCheckStockFactory(equipmentInFactory(1), 1); err != nil {
+		t.Errorf("Expected matching stock location to be allowed, got %v", err)
+	}
+	// 管理员指定的出库工厂与工单设备所属工厂不一致
+	if err := toolCheckStockFactory(equipmentInFactory(1), 2); err == nil || !strings.Contains(err.Error(), "another factory") {
+		t.Errorf("Expected factory mismatch to be rejected, got %v", err)
+	}
+	if err := toolCheckStockFactory(&model.Equipment{}, 1); err == nil {
+		t.Error("Expected equipment without workshop to be rejected")
+	}
+}
+
+func TestCheckStockTarget(t *testing.T) {
+	maintainer := model.User{Role: model.RoleMaintenance, FactoryID: uintPtr(1)}
+	factoryID, err := checkStockTarget(maintainer, nil, uintPtr(6), uintPtr(2))
+	if err != nil || factoryID == nil || *factoryID != 1 {
+		t.Fatalf("Expected non-admin to reserve from own factory 1, got %v, %v", factoryID, err)
+	}
+	if _, err := checkStockTarget(maintainer, nil, nil, nil); err == nil {
+		t.Error("Expected request without an order or task to be rejected")
+	}
+}
+
+func TestToolCheckReservationFactory(t *testing.T) {
+	reservation := &model.SparePartReservation{FactoryID: 2}
+	if err := toolCheckReservationFactory(model.User{Role: model.RoleMaintenance, FactoryID: uintPtr(2)}, reservation); err != nil {
+		t.Errorf("Expected same-factory reservation to be releasable, got %v", err)
+	}
+	if err := toolCheckReservationFactory(model.User{Role: model.RoleMaintenance, FactoryID: uintPtr(1)}, reservation); err == nil {
+		t.Error("Expected reservation from another factory to be rejected")
+	}
+	if err := toolCheckReservationFactory(model.User{Role: model.RoleMaintenance}, reservation); err == nil {
+		t.Error("Expected user without factory to be rejected")
+	}
+	if err := toolCheckReservationFactory(model.User{Role: model.RoleAdmin}, reservation); err != nil {
+		t.Errorf("Expected admin to release any reservation, got %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package v1
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ems/backend/internal/dto"
+	"github.com/ems/backend/internal/model"
+	"github.com/ems/backend/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	alertManager *service.AlertManager
+)
+
+// =====================================================
+// Alert APIs
+// =====================================================
+
+// ListAlerts returns aggregated alerts in the user's factory
+// @Summary List alerts
+// @Tags alert
+// @Router /alerts [get]
+func ListAlerts(c *gin.Context) {
+	user, ok := notificationUser(c)
+	if !ok {
+		return
+	}
+	var q dto.AlertQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	alerts, err := alertManager.ListAlerts(user, q.Status, q.Severity, q.Limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, alerts)
+}
+
+// GetAlert returns an alert
+// @Summary Get alert
+// @Tags alert
+// @Router /alerts/{id} [get]
+func GetAlert(c *gin.Context) {
+	alertAction(c, alertManager.GetAlert)
+}
+
+// AcknowledgeAlert stops repeat notifications for an alert until it escalates or resolves
+// @Summary Acknowledge alert
+// @Tags alert
+// @Router /alerts/{id}/acknowledge [post]
+func AcknowledgeAlert(c *gin.Context) {
+	alertAction(c, alertManager.Acknowledge)
+}
+
+// ResolveAlert resolves an alert
+// @Summary Resolve alert
+// @Tags alert
+// @Router /alerts/{id}/resolve [post]
+func ResolveAlert(c *gin.Context) {
+	alertAction(c, alertManager.Resolve)
+}
+
+func alertAction(c *gin.Context, action func(*model.User, uint) (*model.Alert, error)) {
+	user, ok := notificationUser(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	alert, err := action(user, uint(id))
+	if err != nil {
+		handleNotificationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, alert)
+}
+
+// GetQuietHours returns the current user's quiet hours
+// @Summary Get quiet hours
+// @Tags notification
+// @Router /notifications/quiet-hours [get]
+func GetQuietHours(c *gin.Context) {
+	user, ok := notificationUser(c)
+	if !ok {
+		return
+	}
+	q, err := alertManager.GetQuietHours(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, q)
+}
+
+// SaveQuietHours sets the current user's quiet hours
+// @Summary Save quiet hours
+// @Tags notification
+// @Router /notifications/quiet-hours [put]
+func SaveQuietHours(c *gin.Context) {
+	user, ok := notificationUser(c)
+	if !ok {
+		return
+	}
+	var req dto.QuietHoursRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	q, err := alertManager.SaveQuietHours(user.ID, req.Start, req.End, req.Timezone, req.Enabled)
+	if err != nil {
+		handleNotificationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, q)
+}
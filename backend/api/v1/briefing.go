@@ -0,0 +1,221 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/ems/backend/internal/dto"
+	"github.com/ems/backend/internal/middleware"
+	"github.com/ems/backend/internal/model"
+	"github.com/ems/backend/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	briefingService *service.BriefingService
+)
+
+// InitBriefing 初始化简报服务；到期简报由定时任务 briefing 执行
+func InitBriefing() {
+	briefingService = service.NewBriefingService()
+}
+
+// =====================================================
+// Agent Briefing APIs
+// =====================================================
+
+// briefingOperator 校验操作人权限：管理员可管理全部，主管仅限本工厂
+func briefingOperator(c *gin.Context) (*model.User, bool) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, false
+	}
+	var user model.User
+	if err := db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return nil, false
+	}
+	if user.Role != model.RoleAdmin && user.Role != model.RoleSupervisor {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return nil, false
+	}
+	return &user, true
+}
+
+func briefingInScope(user *model.User, factoryID *uint) bool {
+	if user.Role == model.RoleAdmin || user.FactoryID == nil {
+		return true
+	}
+	return factoryID != nil && *factoryID == *user.FactoryID
+}
+
+func handleBriefingError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrInvalidInput) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	handleServiceError(c, err)
+}
+
+func toBriefingDefinition(req *dto.BriefingRequest, user *model.User) *service.BriefingDefinitionRequest {
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+	factoryID := req.FactoryID
+	if factoryID == nil && user.Role != model.RoleAdmin {
+		factoryID = user.FactoryID
+	}
+	return &service.BriefingDefinitionRequest{
+		Name: req.Name, Cron: req.Cron, Period: req.Period, Audience: req.Audience,
+		FactoryID: factoryID, Template: req.Template, Enabled: enabled, CreatedBy: user.ID,
+	}
+}
+
+// ListBriefings returns scheduled briefing definitions
+// @Summary List agent briefings
+// @Tags agent
+// @Router /agent/briefings [get]
+func ListBriefings(c *gin.Context) {
+	user, ok := briefingOperator(c)
+	if !ok {
+		return
+	}
+	var factoryID *uint
+	if user.Role != model.RoleAdmin {
+		factoryID = user.FactoryID
+	}
+	briefings, err := briefingService.List(factoryID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, briefings)
+}
+
+// CreateBriefing creates a scheduled briefing definition
+// @Summary Create agent briefing
+// @Tags agent
+// @Router /agent/briefings [post]
+func CreateBriefing(c *gin.Context) {
+	user, ok := briefingOperator(c)
+	if !ok {
+		return
+	}
+	var req dto.BriefingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	def := toBriefingDefinition(&req, user)
+	if !briefingInScope(user, def.FactoryID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot manage briefings of another factory"})
+		return
+	}
+	briefing, err := briefingService.Create(def)
+	if err != nil {
+		handleBriefingError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, briefing)
+}
+
+// UpdateBriefing updates a scheduled briefing definition
+// @Summary Update agent briefing
+// @Tags agent
+// @Router /agent/briefings/{id} [put]
+func UpdateBriefing(c *gin.Context) {
+	user, ok := briefingOperator(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	existing, err := briefingService.GetByID(uint(id))
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+	var req dto.BriefingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	def := toBriefingDefinition(&req, user)
+	if !briefingInScope(user, existing.FactoryID) || !briefingInScope(user, def.FactoryID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot manage briefings of another factory"})
+		return
+	}
+	briefing, err := briefingService.Update(uint(id), def)
+	if err != nil {
+		handleBriefingError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, briefing)
+}
+
+// DeleteBriefing deletes a scheduled briefing definition
+// @Summary Delete agent briefing
+// @Tags agent
+// @Router /agent/briefings/{id} [delete]
+func DeleteBriefing(c *gin.Context) {
+	user, ok := briefingOperator(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	existing, err := briefingService.GetByID(uint(id))
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+	if !briefingInScope(user, existing.FactoryID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot manage briefings of another factory"})
+		return
+	}
+	if err := briefingService.Delete(uint(id)); err != nil {
+		handleServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Briefing deleted"})
+}
+
+// RunBriefing triggers a briefing immediately
+// @Summary Run agent briefing now
+// @Tags agent
+// @Router /agent/briefings/{id}/run [post]
+func RunBriefing(c *gin.Context) {
+	user, ok := briefingOperator(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	existing, err := briefingService.GetByID(uint(id))
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+	if !briefingInScope(user, existing.FactoryID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot manage briefings of another factory"})
+		return
+	}
+	result, err := briefingService.Trigger(uint(id))
+	if err != nil {
+		handleBriefingError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
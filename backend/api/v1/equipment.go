@@ -213,6 +213,7 @@ func ListFactories(c *gin.Context) {
 			BaseName:  f.Base.Name,
 			Code:      f.Code,
 			Name:      f.Name,
+			ManagerID: f.ManagerID,
 			CreatedAt: f.CreatedAt,
 			UpdatedAt: f.UpdatedAt,
 		}
@@ -243,7 +244,7 @@ func CreateFactory(c *gin.Context) {
 		return
 	}
 
-	factory, err := factoryService.Create(req.BaseID, req.Code, req.Name)
+	factory, err := factoryService.Create(req.BaseID, req.Code, req.Name, req.ManagerID)
 	if err != nil {
 		handleServiceError(c, err)
 		return
@@ -254,6 +255,7 @@ func CreateFactory(c *gin.Context) {
 		BaseID:    factory.BaseID,
 		Code:      factory.Code,
 		Name:      factory.Name,
+		ManagerID: factory.ManagerID,
 		CreatedAt: factory.CreatedAt,
 		UpdatedAt: factory.UpdatedAt,
 	})
@@ -281,7 +283,7 @@ func UpdateFactory(c *gin.Context) {
 		return
 	}
 
-	factory, err := factoryService.Update(uint(id), req.BaseID, req.Code, req.Name)
+	factory, err := factoryService.Update(uint(id), req.BaseID, req.Code, req.Name, req.ManagerID)
 	if err != nil {
 		handleServiceError(c, err)
 		return
@@ -292,6 +294,7 @@ func UpdateFactory(c *gin.Context) {
 		BaseID:    factory.BaseID,
 		Code:      factory.Code,
 		Name:      factory.Name,
+		ManagerID: factory.ManagerID,
 		CreatedAt: factory.CreatedAt,
 		UpdatedAt: factory.UpdatedAt,
 	})
@@ -344,13 +347,14 @@ func ListWorkshops(c *gin.Context) {
 	resp := make([]dto.WorkshopResponse, len(workshops))
 	for i, w := range workshops {
 		resp[i] = dto.WorkshopResponse{
-			ID:          w.ID,
-			FactoryID:   w.FactoryID,
-			FactoryName: w.Factory.Name,
-			Code:        w.Code,
-			Name:        w.Name,
-			CreatedAt:   w.CreatedAt,
-			UpdatedAt:   w.UpdatedAt,
+			ID:           w.ID,
+			FactoryID:    w.FactoryID,
+			FactoryName:  w.Factory.Name,
+			Code:         w.Code,
+			Name:         w.Name,
+			SupervisorID: w.SupervisorID,
+			CreatedAt:    w.CreatedAt,
+			UpdatedAt:    w.UpdatedAt,
 		}
 	}
 
@@ -373,19 +377,20 @@ func CreateWorkshop(c *gin.Context) {
 		return
 	}
 
-	workshop, err := workshopService.Create(req.FactoryID, req.Code, req.Name)
+	workshop, err := workshopService.Create(req.FactoryID, req.Code, req.Name, req.SupervisorID)
 	if err != nil {
 		handleServiceError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusCreated, dto.WorkshopResponse{
-		ID:        workshop.ID,
-		FactoryID: workshop.FactoryID,
-		Code:      workshop.Code,
-		Name:      workshop.Name,
-		CreatedAt: workshop.CreatedAt,
-		UpdatedAt: workshop.UpdatedAt,
+		ID:           workshop.ID,
+		FactoryID:    workshop.FactoryID,
+		Code:         workshop.Code,
+		Name:         workshop.Name,
+		SupervisorID: workshop.SupervisorID,
+		CreatedAt:    workshop.CreatedAt,
+		UpdatedAt:    workshop.UpdatedAt,
 	})
 }
 
@@ -410,19 +415,20 @@ func UpdateWorkshop(c *gin.Context) {
 		return
 	}
 
-	workshop, err := workshopService.Update(uint(id), req.FactoryID, req.Code, req.Name)
+	workshop, err := workshopService.Update(uint(id), req.FactoryID, req.Code, req.Name, req.SupervisorID)
 	if err != nil {
 		handleServiceError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, dto.WorkshopResponse{
-		ID:        workshop.ID,
-		FactoryID: workshop.FactoryID,
-		Code:      workshop.Code,
-		Name:      workshop.Name,
-		CreatedAt: workshop.CreatedAt,
-		UpdatedAt: workshop.UpdatedAt,
+		ID:           workshop.ID,
+		FactoryID:    workshop.FactoryID,
+		Code:         workshop.Code,
+		Name:         workshop.Name,
+		SupervisorID: workshop.SupervisorID,
+		CreatedAt:    workshop.CreatedAt,
+		UpdatedAt:    workshop.UpdatedAt,
 	})
 }
 
@@ -0,0 +1,302 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/ems/backend/internal/dto"
+	"github.com/ems/backend/internal/model"
+	"github.com/ems/backend/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	escalationService *service.EscalationService
+)
+
+// =====================================================
+// Escalation APIs
+// =====================================================
+
+func handleEscalationError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrForbidden) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+	handleNotificationError(c, err)
+}
+
+func escalationID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// ListEscalationPolicies returns escalation policies of the user's factory and the defaults
+// @Summary List escalation policies
+// @Tags escalation
+// @Router /escalation-policies [get]
+func ListEscalationPolicies(c *gin.Context) {
+	user, ok := notificationUser(c)
+	if !ok {
+		return
+	}
+	policies, err := escalationService.ListPolicies(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, policies)
+}
+
+// CreateEscalationPolicy creates an escalation policy (admin, or supervisor for their own factory)
+// @Summary Create escalation policy
+// @Tags escalation
+// @Router /escalation-policies [post]
+func CreateEscalationPolicy(c *gin.Context) {
+	saveEscalationPolicy(c, 0)
+}
+
+// UpdateEscalationPolicy updates an escalation policy
+// @Summary Update escalation policy
+// @Tags escalation
+// @Router /escalation-policies/{id} [put]
+func UpdateEscalationPolicy(c *gin.Context) {
+	id, ok := escalationID(c)
+	if !ok {
+		return
+	}
+	saveEscalationPolicy(c, id)
+}
+
+func saveEscalationPolicy(c *gin.Context, id uint) {
+	user, ok := notificationUser(c)
+	if !ok {
+		return
+	}
+	var req dto.EscalationPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	policy, err := escalationService.SavePolicy(user, id, &req)
+	if err != nil {
+		handleEscalationError(c, err)
+		return
+	}
+	status := http.StatusOK
+	if id == 0 {
+		status = http.StatusCreated
+	}
+	c.JSON(status, policy)
+}
+
+// DeleteEscalationPolicy deletes an escalation policy
+// @Summary Delete escalation policy
+// @Tags escalation
+// @Router /escalation-policies/{id} [delete]
+func DeleteEscalationPolicy(c *gin.Context) {
+	user, ok := notificationUser(c)
+	if !ok {
+		return
+	}
+	id, ok := escalationID(c)
+	if !ok {
+		return
+	}
+	if err := escalationService.DeletePolicy(user, id); err != nil {
+		handleEscalationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Policy deleted"})
+}
+
+// ListEscalations returns escalations in the user's factory
+// @Summary List escalations
+// @Tags escalation
+// @Router /escalations [get]
+func ListEscalations(c *gin.Context) {
+	user, ok := notificationUser(c)
+	if !ok {
+		return
+	}
+	var q dto.EscalationQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	escalations, err := escalationService.ListEscalations(user, q.Status, q.Limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, escalations)
+}
+
+// GetEscalation returns an escalation with its history
+// @Summary Get escalation
+// @Tags escalation
+// @Router /escalations/{id} [get]
+func GetEscalation(c *gin.Context) {
+	escalationAction(c, false, escalationService.GetEscalation)
+}
+
+// AcknowledgeEscalation stops further escalation steps
+// @Summary Acknowledge escalation
+// @Tags escalation
+// @Router /escalations/{id}/acknowledge [post]
+func AcknowledgeEscalation(c *gin.Context) {
+	escalationAction(c, true, escalationService.Acknowledge)
+}
+
+// AcknowledgeRepairOrder acknowledges a repair order and stops its escalation
+// @Summary Acknowledge repair order
+// @Tags repair
+// @Router /repair/orders/{id}/acknowledge [post]
+func AcknowledgeRepairOrder(c *gin.Context) {
+	escalationAction(c, true, escalationService.AcknowledgeOrder)
+}
+
+// escalationAction 确认类操作不允许操作工执行，避免报修人自行停止升级
+func escalationAction(c *gin.Context, acknowledge bool, action func(*model.User, uint) (*model.Escalation, error)) {
+	user, ok := notificationUser(c)
+	if !ok {
+		return
+	}
+	if acknowledge && user.Role == model.RoleOperator {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+	id, ok := escalationID(c)
+	if !ok {
+		return
+	}
+	esc, err := action(user, id)
+	if err != nil {
+		handleEscalationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, esc)
+}
+
+// GetRepairOrderEscalations returns the escalation history of a repair order
+// @Summary Repair order escalation history
+// @Tags repair
+// @Router /repair/orders/{id}/escalations [get]
+func GetRepairOrderEscalations(c *gin.Context) {
+	escalationHistory(c, model.EscalationSubjectRepairOrder)
+}
+
+// GetAlertEscalations returns the escalation history of an alert
+// @Summary Alert escalation history
+// @Tags alert
+// @Router /alerts/{id}/escalations [get]
+func GetAlertEscalations(c *gin.Context) {
+	escalationHistory(c, model.EscalationSubjectAlert)
+}
+
+func escalationHistory(c *gin.Context, subjectType string) {
+	user, ok := notificationUser(c)
+	if !ok {
+		return
+	}
+	id, ok := escalationID(c)
+	if !ok {
+		return
+	}
+	escalations, err := escalationService.SubjectHistory(user, subjectType, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, escalations)
+}
+
+// =====================================================
+// On-call APIs
+// =====================================================
+
+// ListOnCallShifts returns on-call shifts overlapping the queried range (default next 7 days)
+// @Summary List on-call shifts
+// @Tags escalation
+// @Router /oncall [get]
+func ListOnCallShifts(c *gin.Context) {
+	user, ok := notificationUser(c)
+	if !ok {
+		return
+	}
+	var q dto.OnCallQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	shifts, err := escalationService.ListShifts(user, q.From, q.To)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, shifts)
+}
+
+// GetCurrentOnCall returns the shifts on call right now
+// @Summary Current on-call
+// @Tags escalation
+// @Router /oncall/current [get]
+func GetCurrentOnCall(c *gin.Context) {
+	user, ok := notificationUser(c)
+	if !ok {
+		return
+	}
+	shifts, err := escalationService.CurrentOnCall(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, shifts)
+}
+
+// CreateOnCallShift adds an on-call shift (admin, or supervisor for their own factory)
+// @Summary Create on-call shift
+// @Tags escalation
+// @Router /oncall [post]
+func CreateOnCallShift(c *gin.Context) {
+	user, ok := notificationUser(c)
+	if !ok {
+		return
+	}
+	var req dto.OnCallShiftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	shift, err := escalationService.CreateShift(user, &req)
+	if err != nil {
+		handleEscalationError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, shift)
+}
+
+// DeleteOnCallShift removes an on-call shift
+// @Summary Delete on-call shift
+// @Tags escalation
+// @Router /oncall/{id} [delete]
+func DeleteOnCallShift(c *gin.Context) {
+	user, ok := notificationUser(c)
+	if !ok {
+		return
+	}
+	id, ok := escalationID(c)
+	if !ok {
+		return
+	}
+	if err := escalationService.DeleteShift(user, id); err != nil {
+		handleEscalationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Shift deleted"})
+}
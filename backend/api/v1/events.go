@@ -0,0 +1,17 @@
+package v1
+
+import (
+	"context"
+
+	agentService "github.com/ems/backend/internal/agent/service"
+	"github.com/ems/backend/internal/event"
+	"github.com/ems/backend/internal/service"
+)
+
+// InitEvents 注册领域事件订阅者，启动 outbox 分发与 Webhook 投递；
+// SLA/寿命巡检、告警摘要与升级由定时任务调度（见 InitScheduler）
+func InitEvents() {
+	service.RegisterEventSubscribers(event.Default())
+	go event.Default().Run(context.Background())
+	go agentService.NewAgentService().RunWebhookDispatcher(context.Background())
+}
@@ -566,7 +566,7 @@ func CompleteInspection(c *gin.Context) {
 // @Tags inspection
 // @Router /inspection/statistics [get]
 func GetInspectionStatistics(c *gin.Context) {
-	stats, err := inspectionTaskService.GetStatistics()
+	stats, err := inspectionTaskService.GetStatistics(nil)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
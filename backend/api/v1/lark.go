@@ -3,11 +3,13 @@ package v1
 import (
 	"context"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/ems/backend/internal/dto"
 	"github.com/ems/backend/internal/middleware"
@@ -162,6 +164,34 @@ func verifyLarkSignature(c *gin.Context, body []byte, encryptKey string) bool {
 	return sum == signature
 }
 
+// larkCardActionEventType is the callback event of interactive card buttons
+const larkCardActionEventType = "card.action.trigger"
+
+const larkCallbackTolerance = 5 * time.Minute
+
+// verifyLarkToken checks the verification token carried in the event header.
+// Card actions change business data, so unlike plain events they are rejected
+// when no verification token is configured.
+func verifyLarkToken(token string, user model.User) bool {
+	if user.LarkVerificationToken == nil || *user.LarkVerificationToken == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(*user.LarkVerificationToken)) == 1
+}
+
+// larkTimestampFresh rejects replayed callbacks; requests without a timestamp
+// header (no encrypt key configured) are accepted
+func larkTimestampFresh(ts string, now time.Time) bool {
+	if ts == "" {
+		return true
+	}
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return false
+	}
+	return now.Sub(time.Unix(sec, 0)).Abs() <= larkCallbackTolerance
+}
+
 // LarkWebhook handles Lark events
 func LarkWebhook(c *gin.Context) {
 	userIDStr := c.Param("user_id")
@@ -258,7 +288,24 @@ func LarkWebhook(c *gin.Context) {
 		return
 	}
 
-	// 4. Handle Events
+	// 4. Card actions must be answered synchronously so the card can be updated in place
+	if req.Header.EventType == larkCardActionEventType {
+		if !verifyLarkToken(req.Header.Token, user) || !larkTimestampFresh(c.GetHeader("X-Lark-Request-Timestamp"), time.Now()) {
+			fmt.Printf("[LarkWebhook] Card action verification failed for user %d\n", user.ID)
+			c.JSON(http.StatusForbidden, gin.H{"error": "invalid token"})
+			return
+		}
+		eventBody, _ := json.Marshal(req.Event)
+		var action dto.LarkCardActionEvent
+		if err := json.Unmarshal(eventBody, &action); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, larkService.HandleCardAction(c.Request.Context(), action))
+		return
+	}
+
+	// 5. Handle Events
 	if req.Header.EventType != "" {
 		if larkService == nil {
 			fmt.Printf("[LarkWebhook] larkService is nil!\n")
@@ -308,6 +355,7 @@ func BindLark(c *gin.Context) {
 
 	var req struct {
 		OpenID string `json:"openid" binding:"required"`
+		AppID  string `json:"app_id"` // 发送绑定链接的飞书应用，open_id 按应用区分
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		fmt.Printf("[BindLark] Invalid request body: %v\n", err)
@@ -326,6 +374,10 @@ func BindLark(c *gin.Context) {
 			return
 		}
 		u.LarkOpenID = &req.OpenID
+		u.LarkBotAppID = nil
+		if req.AppID != "" {
+			u.LarkBotAppID = &req.AppID
+		}
 		fmt.Printf("[BindLark] User %d bound successfully (Memory Mode)\n", userID)
 		c.JSON(http.StatusOK, gin.H{"message": "Lark account bound successfully"})
 		return
@@ -337,7 +389,7 @@ func BindLark(c *gin.Context) {
 		return
 	}
 
-	if err := larkService.BindUser(userID, req.OpenID); err != nil {
+	if err := larkService.BindUser(userID, req.OpenID, req.AppID); err != nil {
 		fmt.Printf("[BindLark] Failed to bind user %d: %v\n", userID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error: " + err.Error()})
 		return
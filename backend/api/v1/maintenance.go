@@ -387,7 +387,7 @@ func CompleteMaintenance(c *gin.Context) {
 // @Tags maintenance
 // @Router /maintenance/statistics [get]
 func GetMaintenanceStatistics(c *gin.Context) {
-	stats, err := maintenanceTaskService.GetStatistics()
+	stats, err := maintenanceTaskService.GetStatistics(nil)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -429,6 +429,7 @@ func taskToResponse(task *model.MaintenanceTask) dto.MaintenanceTaskResponse {
 		ScheduledDate: task.ScheduledDate,
 		DueDate:       task.DueDate,
 		Status:        dto.MaintenanceTaskStatus(task.Status),
+		AutoGenerated: task.AutoGenerated,
 		ActualHours:   task.ActualHours,
 		Remark:        task.Remark,
 		StartedAt:     task.StartedAt,
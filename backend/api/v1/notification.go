@@ -0,0 +1,216 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/ems/backend/internal/dto"
+	"github.com/ems/backend/internal/middleware"
+	"github.com/ems/backend/internal/model"
+	"github.com/ems/backend/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	notificationService *service.NotificationService
+)
+
+// InitNotification 初始化通知服务、告警管理与升级
+func InitNotification() {
+	notificationService = service.NewNotificationService()
+	alertManager = service.NewAlertManager()
+	escalationService = service.NewEscalationService()
+}
+
+// =====================================================
+// Notification APIs
+// =====================================================
+
+func notificationUser(c *gin.Context) (*model.User, bool) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, false
+	}
+	var user model.User
+	if err := db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return nil, false
+	}
+	return &user, true
+}
+
+func handleNotificationError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrInvalidInput) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	handleServiceError(c, err)
+}
+
+// ListNotificationChannels returns available notification channels
+// @Summary List notification channels
+// @Tags notification
+// @Router /notifications/channels [get]
+func ListNotificationChannels(c *gin.Context) {
+	c.JSON(http.StatusOK, notificationService.Channels())
+}
+
+// ListNotificationPreferences returns the current user's channel preferences
+// @Summary List notification preferences
+// @Tags notification
+// @Router /notifications/preferences [get]
+func ListNotificationPreferences(c *gin.Context) {
+	user, ok := notificationUser(c)
+	if !ok {
+		return
+	}
+	prefs, err := notificationService.ListPreferences(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, prefs)
+}
+
+// SaveNotificationPreference creates or updates the current user's preference for a channel
+// @Summary Save notification preference
+// @Tags notification
+// @Router /notifications/preferences/{channel} [put]
+func SaveNotificationPreference(c *gin.Context) {
+	user, ok := notificationUser(c)
+	if !ok {
+		return
+	}
+	var req dto.NotificationPreferenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	pref, err := notificationService.SavePreference(user.ID, c.Param("channel"), &req)
+	if err != nil {
+		handleNotificationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, pref)
+}
+
+// DeleteNotificationPreference removes the current user's preference for a channel
+// @Summary Delete notification preference
+// @Tags notification
+// @Router /notifications/preferences/{channel} [delete]
+func DeleteNotificationPreference(c *gin.Context) {
+	user, ok := notificationUser(c)
+	if !ok {
+		return
+	}
+	if err := notificationService.DeletePreference(user.ID, c.Param("channel")); err != nil {
+		handleNotificationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Preference deleted"})
+}
+
+// TestNotificationPreference sends a test notification through a saved channel
+// @Summary Test notification preference
+// @Tags notification
+// @Router /notifications/preferences/{channel}/test [post]
+func TestNotificationPreference(c *gin.Context) {
+	user, ok := notificationUser(c)
+	if !ok {
+		return
+	}
+	delivery, err := notificationService.TestPreference(c.Request.Context(), user.ID, c.Param("channel"))
+	if err != nil {
+		handleNotificationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, delivery)
+}
+
+// ListNotificationDeliveries returns the unified notification delivery log
+// @Summary List notification deliveries
+// @Tags notification
+// @Router /notifications/deliveries [get]
+func ListNotificationDeliveries(c *gin.Context) {
+	user, ok := notificationUser(c)
+	if !ok {
+		return
+	}
+	var q dto.NotificationDeliveryQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	deliveries, err := notificationService.ListDeliveries(user, &q)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// ListNotificationTemplates returns the effective template of every event type and language
+// @Summary List notification templates
+// @Tags notification
+// @Router /notifications/templates [get]
+func ListNotificationTemplates(c *gin.Context) {
+	templates, err := notificationService.ListTemplates()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, templates)
+}
+
+// SaveNotificationTemplate overrides the builtin template of an event type and language (admin only)
+// @Summary Save notification template
+// @Tags notification
+// @Router /notifications/templates [put]
+func SaveNotificationTemplate(c *gin.Context) {
+	user, ok := notificationUser(c)
+	if !ok {
+		return
+	}
+	if user.Role != model.RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+	var req dto.NotificationTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	tpl, err := notificationService.SaveTemplate(&req)
+	if err != nil {
+		handleNotificationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, tpl)
+}
+
+// DeleteNotificationTemplate removes a custom template and restores the builtin one (admin only)
+// @Summary Delete notification template
+// @Tags notification
+// @Router /notifications/templates/{id} [delete]
+func DeleteNotificationTemplate(c *gin.Context) {
+	user, ok := notificationUser(c)
+	if !ok {
+		return
+	}
+	if user.Role != model.RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	if err := notificationService.DeleteTemplate(uint(id)); err != nil {
+		handleNotificationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Template deleted"})
+}
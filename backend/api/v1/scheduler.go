@@ -0,0 +1,158 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/ems/backend/internal/dto"
+	"github.com/ems/backend/internal/model"
+	"github.com/ems/backend/internal/scheduler"
+	"github.com/ems/backend/internal/service"
+	"github.com/ems/backend/pkg/config"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	jobService *service.JobService
+)
+
+// InitScheduler 注册内置定时任务并启动调度；scheduler.disabled 为 true 时只提供接口
+func InitScheduler() {
+	svc, err := service.NewJobService()
+	if err != nil {
+		log.Printf("Warning: Failed to initialize scheduler: %v", err)
+		return
+	}
+	jobService = svc
+	if config.Cfg.Scheduler.Disabled {
+		if err := jobService.Sync(); err != nil {
+			log.Printf("Warning: Failed to sync scheduled jobs: %v", err)
+		}
+		log.Println("Scheduler disabled on this instance")
+		return
+	}
+	go jobService.Run(context.Background())
+}
+
+// =====================================================
+// Scheduler APIs (admin only)
+// =====================================================
+
+func schedulerAdmin(c *gin.Context) bool {
+	user, ok := notificationUser(c)
+	if !ok {
+		return false
+	}
+	if user.Role != model.RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return false
+	}
+	if jobService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Scheduler not initialized"})
+		return false
+	}
+	return true
+}
+
+// ListScheduledJobs returns the job registry with schedule state
+// @Summary List scheduled jobs
+// @Tags scheduler
+// @Router /scheduler/jobs [get]
+func ListScheduledJobs(c *gin.Context) {
+	if !schedulerAdmin(c) {
+		return
+	}
+	jobs, err := jobService.ListJobs()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, jobs)
+}
+
+// GetScheduledJob returns a job with its schedule state
+// @Summary Get scheduled job
+// @Tags scheduler
+// @Router /scheduler/jobs/{name} [get]
+func GetScheduledJob(c *gin.Context) {
+	if !schedulerAdmin(c) {
+		return
+	}
+	job, err := jobService.GetJob(c.Param("name"))
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// ListScheduledJobRuns returns the run history of a job
+// @Summary List job runs
+// @Tags scheduler
+// @Router /scheduler/jobs/{name}/runs [get]
+func ListScheduledJobRuns(c *gin.Context) {
+	if !schedulerAdmin(c) {
+		return
+	}
+	var q dto.JobRunQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	runs, err := jobService.ListRuns(c.Param("name"), &q)
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, runs)
+}
+
+// TriggerScheduledJob runs a job once in the background without changing its schedule
+// @Summary Trigger job
+// @Tags scheduler
+// @Router /scheduler/jobs/{name}/trigger [post]
+func TriggerScheduledJob(c *gin.Context) {
+	if !schedulerAdmin(c) {
+		return
+	}
+	err := jobService.Trigger(c.Request.Context(), c.Param("name"))
+	if errors.Is(err, scheduler.ErrJobRunning) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "Job triggered"})
+}
+
+// PauseScheduledJob stops scheduled runs of a job
+// @Summary Pause job
+// @Tags scheduler
+// @Router /scheduler/jobs/{name}/pause [post]
+func PauseScheduledJob(c *gin.Context) {
+	setScheduledJobPaused(c, true)
+}
+
+// ResumeScheduledJob resumes a paused job from the next cron time
+// @Summary Resume job
+// @Tags scheduler
+// @Router /scheduler/jobs/{name}/resume [post]
+func ResumeScheduledJob(c *gin.Context) {
+	setScheduledJobPaused(c, false)
+}
+
+func setScheduledJobPaused(c *gin.Context, paused bool) {
+	if !schedulerAdmin(c) {
+		return
+	}
+	job, err := jobService.SetPaused(c.Param("name"), paused)
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
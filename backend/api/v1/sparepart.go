@@ -253,7 +253,7 @@ func StockIn(c *gin.Context) {
 		return
 	}
 
-	if err := sparePartService.StockIn(req.SparePartID, req.FactoryID, req.Quantity, req.Remark, userID); err != nil {
+	if err := sparePartService.StockIn(req.SparePartID, req.FactoryID, req.Quantity, req.BatchNo, req.Remark, userID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
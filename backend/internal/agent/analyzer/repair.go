@@ -3,6 +3,7 @@ package analyzer
 import (
 	"fmt"
 	"time"
+	"sort"
 	"strings"
 	"github.com/ems/backend/internal/agent/dto"
 	"github.com/ems/backend/internal/agent/tool"
@@ -66,6 +67,7 @@ func (a *RepairAuditAnalyzer) Analyze(req *dto.RepairAuditRequest, user model.Us
 		if len(oList) < 2 { continue }
 		
 		// Sort by creation date (desc)
+		sort.Slice(oList, func(i, j int) bool { return oList[i].CreatedAt.After(oList[j].CreatedAt) })
 		for i := 0; i < len(oList)-1; i++ {
 			for j := i + 1; j < len(oList); j++ {
 				// If two orders on same equipment within 72 hours
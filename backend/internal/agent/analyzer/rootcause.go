@@ -0,0 +1,672 @@
+package analyzer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ems/backend/internal/agent/dto"
+	"github.com/ems/backend/internal/agent/tool"
+	"github.com/ems/backend/internal/model"
+)
+
+const (
+	rootCauseDefaultWindow = 180 * 24 * time.Hour
+	partLookback           = 365 * 24 * time.Hour // 窗口前安装的备件仍可能是故障诱因
+	clusterGap             = 45 * 24 * time.Hour  // 同类故障间隔超过该值视为新一轮爆发
+	afterMaintenanceWindow = 14 * 24 * time.Hour
+	afterRepairWindow      = 30 * 24 * time.Hour
+	maxHypotheses          = 10
+)
+
+// 描述聚类关键词：优先按部件归类，其次按现象
+var (
+	componentTerms = []string{"轴承", "主轴", "电机", "皮带", "齿轮", "液压", "气缸", "密封", "阀", "泵", "刀具", "导轨", "丝杠", "传感器", "线路", "变频器"}
+	symptomTerms   = []string{"异响", "漏油", "漏气", "过热", "温度", "振动", "报错", "卡死", "磨损", "压力", "停机"}
+)
+
+// RootCauseAnalyzer clusters similar repair orders across an equipment type or workshop
+// and correlates each cluster with spare part batches, maintenance and technicians to
+// produce ranked root cause hypotheses.
+type RootCauseAnalyzer struct {
+	fleetTool     *tool.FleetTool
+	retrievalTool *tool.RetrievalTool
+	now           func() time.Time
+}
+
+func NewRootCauseAnalyzer(fleetTool *tool.FleetTool, retrievalTool *tool.RetrievalTool) *RootCauseAnalyzer {
+	return &RootCauseAnalyzer{fleetTool: fleetTool, retrievalTool: retrievalTool, now: time.Now}
+}
+
+// rootCauseRun 单次分析的上下文
+type rootCauseRun struct {
+	h          *tool.FleetHistory
+	equipment  map[uint]model.Equipment
+	orderByID  map[uint]model.RepairOrder
+	taskByID   map[uint]model.MaintenanceTask
+	since      time.Time
+	until      time.Time
+	workshops  map[uint]int // 车间 -> 设备数
+	hypotheses []dto.RootCauseHypothesis
+}
+
+func (a *RootCauseAnalyzer) Analyze(req *dto.RootCauseRequest, user model.User) (*dto.RootCauseData, error) {
+	until := a.now()
+	since := until.Add(-rootCauseDefaultWindow)
+	if t, err := time.ParseInLocation("2006-01-02", req.TimeRange.StartDate, time.Local); err == nil {
+		since = t
+	}
+	if t, err := time.ParseInLocation("2006-01-02", req.TimeRange.EndDate, time.Local); err == nil {
+		until = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	if !since.Before(until) {
+		return nil, fmt.Errorf("invalid time range: start date must be before end date")
+	}
+	minSize := req.MinClusterSize
+	if minSize < 2 {
+		minSize = 3
+	}
+
+	h, err := a.fleetTool.GetFleetHistory(tool.FleetScope{
+		FactoryID: req.FactoryID, WorkshopID: req.WorkshopID, EquipmentTypeID: req.EquipmentTypeID,
+		EquipmentIDs: req.EquipmentIDs, Since: since,
+	}, partLookback, user)
+	if err != nil {
+		return nil, err
+	}
+
+	run := &rootCauseRun{
+		h: h, since: since, until: until,
+		equipment: map[uint]model.Equipment{}, orderByID: map[uint]model.RepairOrder{},
+		taskByID: map[uint]model.MaintenanceTask{}, workshops: map[uint]int{},
+	}
+	for _, e := range h.Equipment {
+		run.equipment[e.ID] = e
+		run.workshops[e.WorkshopID]++
+	}
+	for _, o := range h.Orders {
+		run.orderByID[o.ID] = o
+	}
+	for _, m := range h.Maintenance {
+		run.taskByID[m.ID] = m
+	}
+
+	var windowOrders []model.RepairOrder
+	for _, o := range h.Orders {
+		if o.CreatedAt.Before(since) || o.CreatedAt.After(until) {
+			continue
+		}
+		if req.FaultCode != "" && !strings.HasPrefix(o.FaultCode, req.FaultCode) {
+			continue
+		}
+		if req.Keyword != "" && !strings.Contains(o.FaultDescription, req.Keyword) {
+			continue
+		}
+		windowOrders = append(windowOrders, o)
+	}
+
+	clusters, unclassified := run.cluster(windowOrders, minSize)
+	for _, c := range clusters {
+		before := len(run.hypotheses)
+		run.batchHypotheses(c)
+		run.maintenanceHypothesis(c)
+		run.technicianHypotheses(c)
+		run.workshopHypothesis(c)
+		if len(run.hypotheses) == before {
+			run.onsetHypothesis(c)
+		}
+	}
+
+	hyps := run.hypotheses
+	sort.SliceStable(hyps, func(i, j int) bool {
+		if hyps[i].Confidence != hyps[j].Confidence {
+			return hyps[i].Confidence > hyps[j].Confidence
+		}
+		return hyps[i].Support > hyps[j].Support
+	})
+	if len(hyps) > maxHypotheses {
+		hyps = hyps[:maxHypotheses]
+	}
+	data := &dto.RootCauseData{
+		Clusters:   clusters,
+		Hypotheses: hyps,
+		Evidence:   []dto.EvidenceItem{},
+	}
+	for i := range hyps {
+		hyps[i].Rank = i + 1
+		data.Evidence = append(data.Evidence, hyps[i].Evidence...)
+	}
+	if data.Clusters == nil {
+		data.Clusters = []dto.FailureCluster{}
+	}
+	if data.Hypotheses == nil {
+		data.Hypotheses = []dto.RootCauseHypothesis{}
+	}
+
+	// 为排名第一的假设补充手册依据
+	if len(hyps) > 0 && req.EquipmentTypeID != 0 && a.retrievalTool != nil {
+		if evs, err := a.retrievalTool.SearchManualKnowledge(hyps[0].Title, &req.EquipmentTypeID, user); err == nil {
+			data.Evidence = append(data.Evidence, evs...)
+		}
+	}
+
+	summary := fmt.Sprintf("分析 %d 台设备的 %d 张维修单，识别出 %d 个故障簇、%d 条根因假设。", len(h.Equipment), len(windowOrders), len(clusters), len(hyps))
+	if len(hyps) > 0 {
+		summary += "首要假设：" + hyps[0].Statement
+	}
+	data.Stats = map[string]interface{}{
+		"equipment_count":    len(h.Equipment),
+		"orders_analyzed":    len(windowOrders),
+		"unclassified":       unclassified,
+		"cluster_count":      len(clusters),
+		"window_start":       since.Format("2006-01-02"),
+		"window_end":         until.Format("2006-01-02"),
+		"root_cause_summary": summary,
+	}
+	return data, nil
+}
+
+// faultKey 按故障代码、部件关键词、现象关键词的优先级归类
+func faultKey(o model.RepairOrder) (key, label string) {
+	if o.FaultCode != "" {
+		return "code:" + o.FaultCode, "故障代码 " + o.FaultCode
+	}
+	for _, t := range componentTerms {
+		if strings.Contains(o.FaultDescription, t) {
+			return "part:" + t, t + "故障"
+		}
+	}
+	for _, t := range symptomTerms {
+		if strings.Contains(o.FaultDescription, t) {
+			return "symptom:" + t, t
+		}
+	}
+	return "", ""
+}
+
+// cluster 先按故障类别分组，再按时间间隔切分为多轮爆发；只保留涉及多台设备的簇
+func (r *rootCauseRun) cluster(orders []model.RepairOrder, minSize int) ([]dto.FailureCluster, int) {
+	groups := map[string][]model.RepairOrder{}
+	labels := map[string]string{}
+	var keys []string
+	unclassified := 0
+	for _, o := range orders {
+		key, label := faultKey(o)
+		if key == "" {
+			unclassified++
+			continue
+		}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+			labels[key] = label
+		}
+		groups[key] = append(groups[key], o)
+	}
+	sort.Strings(keys)
+
+	var clusters []dto.FailureCluster
+	third := r.until.Sub(r.since) / 3
+	for _, key := range keys {
+		list := groups[key]
+		start := 0
+		for i := 1; i <= len(list); i++ {
+			if i < len(list) && list[i].CreatedAt.Sub(list[i-1].CreatedAt) <= clusterGap {
+				continue
+			}
+			episode := list[start:i]
+			start = i
+			if len(episode) < minSize {
+				continue
+			}
+			c := dto.FailureCluster{
+				Key: key + "@" + episode[0].CreatedAt.Format("2006-01-02"), Label: labels[key],
+				FirstAt: episode[0].CreatedAt, LastAt: episode[len(episode)-1].CreatedAt,
+			}
+			seen := map[uint]bool{}
+			recent := 0
+			for _, o := range episode {
+				c.OrderIDs = append(c.OrderIDs, o.ID)
+				if !seen[o.EquipmentID] {
+					seen[o.EquipmentID] = true
+					c.EquipmentIDs = append(c.EquipmentIDs, o.EquipmentID)
+					c.EquipmentCodes = append(c.EquipmentCodes, r.equipment[o.EquipmentID].Code)
+				}
+				if r.until.Sub(o.CreatedAt) <= third {
+					recent++
+				}
+			}
+			if len(c.EquipmentIDs) < 2 {
+				continue
+			}
+			c.RecentShare = round2(float64(recent) / float64(len(episode)))
+			clusters = append(clusters, c)
+		}
+	}
+	return clusters, unclassified
+}
+
+// firstFailure 每台设备在簇内的首次故障时间
+func (r *rootCauseRun) firstFailure(c dto.FailureCluster) map[uint]time.Time {
+	first := map[uint]time.Time{}
+	for _, id := range c.OrderIDs {
+		o := r.orderByID[id]
+		if t, ok := first[o.EquipmentID]; !ok || o.CreatedAt.Before(t) {
+			first[o.EquipmentID] = o.CreatedAt
+		}
+	}
+	return first
+}
+
+// consumptionEquipment 领用记录对应的设备（经由维修单或保养任务）
+func (r *rootCauseRun) consumptionEquipment(c model.SparePartConsumption) uint {
+	if c.OrderID != nil {
+		if o, ok := r.orderByID[*c.OrderID]; ok {
+			return o.EquipmentID
+		}
+	}
+	if c.TaskID != nil {
+		if t, ok := r.taskByID[*c.TaskID]; ok {
+			return t.EquipmentID
+		}
+	}
+	return 0
+}
+
+// batchOf 按先进先出近似：领用前同工厂最近一次入库即为该备件批次
+func (r *rootCauseRun) batchOf(c model.SparePartConsumption, factoryID uint) *model.SparePartTransaction {
+	var batch *model.SparePartTransaction
+	for i := range r.h.StockIns {
+		in := &r.h.StockIns[i]
+		if in.SparePartID != c.SparePartID || in.FactoryID != factoryID || in.CreatedAt.After(c.CreatedAt) {
+			continue
+		}
+		batch = in
+	}
+	return batch
+}
+
+func batchLabel(in *model.SparePartTransaction) string {
+	if in.BatchNo != "" {
+		return in.BatchNo
+	}
+	return fmt.Sprintf("入库#%d", in.ID)
+}
+
+type batchUse struct {
+	in          *model.SparePartTransaction
+	partName    string
+	failed      map[uint]bool // 安装后在簇内发生故障的设备
+	installed   map[uint]bool // 窗口结束前安装过该批次的设备
+	consumption []model.SparePartConsumption
+}
+
+func (r *rootCauseRun) batchHypotheses(c dto.FailureCluster) {
+	first := r.firstFailure(c)
+	uses := map[uint]*batchUse{}
+	var order []uint
+	for _, cons := range r.h.Consumptions {
+		eqID := r.consumptionEquipment(cons)
+		if eqID == 0 || cons.CreatedAt.After(r.until) {
+			continue
+		}
+		in := r.batchOf(cons, r.h.FactoryOf[eqID])
+		if in == nil {
+			continue
+		}
+		u, ok := uses[in.ID]
+		if !ok {
+			u = &batchUse{in: in, failed: map[uint]bool{}, installed: map[uint]bool{}}
+			if cons.SparePart != nil {
+				u.partName = cons.SparePart.Name
+			}
+			uses[in.ID] = u
+			order = append(order, in.ID)
+		}
+		u.installed[eqID] = true
+		if f, ok := first[eqID]; ok && cons.CreatedAt.Before(f) && f.Sub(cons.CreatedAt) <= partLookback {
+			u.failed[eqID] = true
+			u.consumption = append(u.consumption, cons)
+		}
+	}
+
+	clusterEquip := len(c.EquipmentIDs)
+	fleet := len(r.equipment)
+	for _, id := range order {
+		u := uses[id]
+		if len(u.failed) < 2 {
+			continue
+		}
+		share := float64(len(u.failed)) / float64(clusterEquip)
+		rate := float64(len(u.failed)) / float64(len(u.installed))
+		baseline := 0.0
+		if others := fleet - len(u.installed); others > 0 {
+			baseline = float64(clusterEquip-len(u.failed)) / float64(others)
+		}
+		if share < 0.4 || rate <= baseline {
+			continue
+		}
+		name := u.partName
+		if name == "" {
+			name = fmt.Sprintf("备件#%d", u.in.SparePartID)
+		}
+		label := batchLabel(u.in)
+		h := dto.RootCauseHypothesis{
+			Category: "spare_part_batch", ClusterKey: c.Key,
+			Title: fmt.Sprintf("%s批次 %s 与%s相关", name, label, c.Label),
+			Statement: fmt.Sprintf("%d 台设备在装用%s批次 %s（%s 入库）后出现%s；该批次共装到 %d 台设备，故障率 %.0f%%，未装用该批次的设备为 %.0f%%。",
+				len(u.failed), name, label, u.in.CreatedAt.Format("2006-01-02"), c.Label, len(u.installed), rate*100, baseline*100),
+			Confidence:   clamp(0.5*share+0.5*(rate-baseline), 0.05, 0.95),
+			Support:      len(u.failed),
+			EquipmentIDs: sortedIDs(u.failed),
+			OrderIDs:     r.ordersOf(c, u.failed),
+			NextStep:     fmt.Sprintf("隔离批次 %s 的剩余库存并抽检，联系供应商确认该批次质量记录。", label),
+		}
+		h.Evidence = append(h.Evidence, dto.EvidenceItem{
+			EvidenceType: "spare_part_batch", SourceTable: "spare_part_transactions", SourceID: u.in.ID,
+			Title: "备件入库批次", Score: h.Confidence,
+			Excerpt: fmt.Sprintf("%s 批次 %s 于 %s 入库 %d 件", name, label, u.in.CreatedAt.Format("2006-01-02"), u.in.Quantity),
+		})
+		for _, cons := range u.consumption {
+			h.Evidence = append(h.Evidence, dto.EvidenceItem{
+				EvidenceType: "spare_part_consumption", SourceTable: "spare_part_consumptions", SourceID: cons.ID,
+				Title: "备件领用", Score: h.Confidence,
+				Excerpt: fmt.Sprintf("%s 于 %s 领用 %d 件，随后 %s 出现%s", r.equipment[r.consumptionEquipment(cons)].Code,
+					cons.CreatedAt.Format("2006-01-02"), cons.Quantity, first[r.consumptionEquipment(cons)].Format("2006-01-02"), c.Label),
+			})
+		}
+		r.hypotheses = append(r.hypotheses, h)
+	}
+}
+
+// maintenanceHypothesis 故障是否集中发生在保养完成后不久
+func (r *rootCauseRun) maintenanceHypothesis(c dto.FailureCluster) {
+	matched := map[uint]model.MaintenanceTask{} // 工单 -> 之前最近的保养
+	byWorker := map[uint]int{}
+	for _, id := range c.OrderIDs {
+		o := r.orderByID[id]
+		var last *model.MaintenanceTask
+		for i := range r.h.Maintenance {
+			m := &r.h.Maintenance[i]
+			if m.EquipmentID == o.EquipmentID && m.CompletedAt.Before(o.CreatedAt) && o.CreatedAt.Sub(*m.CompletedAt) <= afterMaintenanceWindow {
+				last = m
+			}
+		}
+		if last != nil {
+			matched[id] = *last
+			byWorker[last.AssignedTo]++
+		}
+	}
+	if len(matched) < 2 {
+		return
+	}
+	share := float64(len(matched)) / float64(len(c.OrderIDs))
+	// 随机情况下故障落在保养后 14 天内的概率
+	completions := 0
+	for _, m := range r.h.Maintenance {
+		if _, ok := r.equipment[m.EquipmentID]; ok && !m.CompletedAt.Before(r.since) {
+			completions++
+		}
+	}
+	baseline := 0.0
+	if days := r.until.Sub(r.since).Hours() / 24 * float64(len(r.equipment)); days > 0 {
+		baseline = minFloat(1, float64(completions)*afterMaintenanceWindow.Hours()/24/days)
+	}
+	if share < 0.5 || share <= 2*baseline {
+		return
+	}
+
+	worker, top := uint(0), 0
+	for w, n := range byWorker {
+		if n > top || (n == top && w < worker) {
+			worker, top = w, n
+		}
+	}
+	statement := fmt.Sprintf("%s中 %d/%d 张工单发生在同台设备保养完成后 14 天内（随机情况下约 %.0f%%）。", c.Label, len(matched), len(c.OrderIDs), baseline*100)
+	next := "复核保养作业标准与装配工艺，确认保养后的试运行检查项。"
+	if top >= 2 && float64(top)/float64(len(matched)) >= 0.6 {
+		statement += fmt.Sprintf("其中 %d 次保养由 %s 执行。", top, r.userName(worker))
+		next = fmt.Sprintf("抽查 %s 近期的保养记录与作业方法，必要时安排复训。", r.userName(worker))
+	}
+	h := dto.RootCauseHypothesis{
+		Category: "maintenance", ClusterKey: c.Key,
+		Title:      fmt.Sprintf("%s多发生在保养之后", c.Label),
+		Statement:  statement,
+		Confidence: clamp(share-baseline, 0.05, 0.9),
+		Support:    len(matched),
+		NextStep:   next,
+	}
+	equip := map[uint]bool{}
+	for _, id := range c.OrderIDs {
+		m, ok := matched[id]
+		if !ok {
+			continue
+		}
+		o := r.orderByID[id]
+		equip[o.EquipmentID] = true
+		h.OrderIDs = append(h.OrderIDs, id)
+		h.Evidence = append(h.Evidence, dto.EvidenceItem{
+			EvidenceType: "maintenance_task", SourceTable: "maintenance_tasks", SourceID: m.ID,
+			Title: "保养后故障", Score: h.Confidence,
+			Excerpt: fmt.Sprintf("%s 于 %s 完成保养（%s），%s 报修：%s", r.equipment[o.EquipmentID].Code,
+				m.CompletedAt.Format("2006-01-02"), r.userName(m.AssignedTo), o.CreatedAt.Format("2006-01-02"), o.FaultDescription),
+		})
+	}
+	h.EquipmentIDs = sortedIDs(equip)
+	r.hypotheses = append(r.hypotheses, h)
+}
+
+// technicianHypotheses 某维修人员处理过的设备是否更常在 30 天内再次发生该类故障
+func (r *rootCauseRun) technicianHypotheses(c dto.FailureCluster) {
+	follow := map[uint][]uint{} // 维修人员 -> 其维修后再次故障的簇内工单
+	previous := map[uint]uint{} // 簇内工单 -> 前一次维修单
+	for _, id := range c.OrderIDs {
+		o := r.orderByID[id]
+		var prev *model.RepairOrder
+		for i := range r.h.Orders {
+			p := &r.h.Orders[i]
+			if p.ID == o.ID || p.EquipmentID != o.EquipmentID || !p.CreatedAt.Before(o.CreatedAt) || p.AssignedTo == nil {
+				continue
+			}
+			done := p.CreatedAt
+			if p.CompletedAt != nil {
+				done = *p.CompletedAt
+			}
+			if done.Before(o.CreatedAt) && o.CreatedAt.Sub(done) <= afterRepairWindow {
+				prev = p
+			}
+		}
+		if prev != nil {
+			follow[*prev.AssignedTo] = append(follow[*prev.AssignedTo], id)
+			previous[id] = prev.ID
+		}
+	}
+
+	// 各维修人员在窗口内的维修总量，作为比较基数
+	total := map[uint]int{}
+	allFollow, allTotal := 0, 0
+	for _, o := range r.h.Orders {
+		if o.AssignedTo == nil || o.CreatedAt.Before(r.since) || o.CreatedAt.After(r.until) {
+			continue
+		}
+		total[*o.AssignedTo]++
+		allTotal++
+	}
+	for _, ids := range follow {
+		allFollow += len(ids)
+	}
+	if allTotal == 0 {
+		return
+	}
+	avg := float64(allFollow) / float64(allTotal)
+
+	techs := make([]uint, 0, len(follow))
+	for t := range follow {
+		techs = append(techs, t)
+	}
+	sort.Slice(techs, func(i, j int) bool { return techs[i] < techs[j] })
+	for _, t := range techs {
+		ids := follow[t]
+		if len(ids) < 2 || total[t] == 0 {
+			continue
+		}
+		rate := float64(len(ids)) / float64(total[t])
+		others := allTotal - total[t]
+		otherRate := 0.0
+		if others > 0 {
+			otherRate = float64(allFollow-len(ids)) / float64(others)
+		}
+		if rate < 0.3 || rate < 2*otherRate {
+			continue
+		}
+		share := float64(len(ids)) / float64(len(c.OrderIDs))
+		h := dto.RootCauseHypothesis{
+			Category: "technician", ClusterKey: c.Key,
+			Title: fmt.Sprintf("%s维修后的设备%s复发率偏高", r.userName(t), c.Label),
+			Statement: fmt.Sprintf("%s维修过的设备中有 %d 次在 30 天内再次出现%s，占其 %d 张维修单的 %.0f%%，其他人员为 %.0f%%（整体 %.0f%%）。",
+				r.userName(t), len(ids), c.Label, total[t], rate*100, otherRate*100, avg*100),
+			Confidence: clamp(0.6*(rate-otherRate)+0.4*share, 0.05, 0.85),
+			Support:    len(ids),
+			OrderIDs:   ids,
+			NextStep:   fmt.Sprintf("复核 %s 处理%s的维修方案与更换件，安排经验丰富的技术员复查。", r.userName(t), c.Label),
+		}
+		equip := map[uint]bool{}
+		for _, id := range ids {
+			o := r.orderByID[id]
+			equip[o.EquipmentID] = true
+			p := r.orderByID[previous[id]]
+			h.Evidence = append(h.Evidence, dto.EvidenceItem{
+				EvidenceType: "repair_order", SourceTable: "repair_orders", SourceID: p.ID,
+				Title: "维修后复发", Score: h.Confidence,
+				Excerpt: fmt.Sprintf("%s 维修单 #%d（%s）后，%s 再次报修 #%d：%s", r.equipment[o.EquipmentID].Code,
+					p.ID, r.userName(t), o.CreatedAt.Format("2006-01-02"), o.ID, o.FaultDescription),
+			})
+		}
+		h.EquipmentIDs = sortedIDs(equip)
+		r.hypotheses = append(r.hypotheses, h)
+	}
+}
+
+// workshopHypothesis 故障是否集中在某个车间（环境、供电、工艺因素）
+func (r *rootCauseRun) workshopHypothesis(c dto.FailureCluster) {
+	if len(r.workshops) < 2 {
+		return
+	}
+	count := map[uint]map[uint]bool{}
+	for _, id := range c.EquipmentIDs {
+		w := r.equipment[id].WorkshopID
+		if count[w] == nil {
+			count[w] = map[uint]bool{}
+		}
+		count[w][id] = true
+	}
+	for w, equip := range count {
+		share := float64(len(equip)) / float64(len(c.EquipmentIDs))
+		fleetShare := float64(r.workshops[w]) / float64(len(r.equipment))
+		if len(equip) < 2 || share < 0.8 || fleetShare > 0.6 {
+			continue
+		}
+		name := fmt.Sprintf("车间#%d", w)
+		for _, id := range c.EquipmentIDs {
+			if e := r.equipment[id]; e.WorkshopID == w && e.Workshop != nil && e.Workshop.Name != "" {
+				name = e.Workshop.Name
+				break
+			}
+		}
+		h := dto.RootCauseHypothesis{
+			Category: "workshop", ClusterKey: c.Key,
+			Title: fmt.Sprintf("%s集中在%s", c.Label, name),
+			Statement: fmt.Sprintf("%s涉及的 %d 台设备中有 %d 台位于%s，而该车间只占分析范围内设备的 %.0f%%。",
+				c.Label, len(c.EquipmentIDs), len(equip), name, fleetShare*100),
+			Confidence:   clamp(share-fleetShare, 0.05, 0.7),
+			Support:      len(equip),
+			EquipmentIDs: sortedIDs(equip),
+			OrderIDs:     r.ordersOf(c, equip),
+			NextStep:     fmt.Sprintf("排查%s的环境（温湿度、粉尘）、供电质量与工艺参数差异。", name),
+		}
+		h.Evidence = r.orderEvidence(h.OrderIDs, "车间集中故障", h.Confidence)
+		r.hypotheses = append(r.hypotheses, h)
+	}
+}
+
+// onsetHypothesis 没有其他关联时，至少指出故障集中爆发的起点
+func (r *rootCauseRun) onsetHypothesis(c dto.FailureCluster) {
+	if c.RecentShare < 0.6 {
+		return
+	}
+	h := dto.RootCauseHypothesis{
+		Category: "onset", ClusterKey: c.Key,
+		Title: fmt.Sprintf("%s自 %s 起集中出现", c.Label, c.FirstAt.Format("2006-01-02")),
+		Statement: fmt.Sprintf("%d 台设备的 %d 张%s工单中 %.0f%% 发生在分析窗口后三分之一，未找到备件批次、保养或维修人员方面的共同因素。",
+			len(c.EquipmentIDs), len(c.OrderIDs), c.Label, c.RecentShare*100),
+		Confidence:   0.2,
+		Support:      len(c.OrderIDs),
+		EquipmentIDs: c.EquipmentIDs,
+		OrderIDs:     c.OrderIDs,
+		NextStep:     fmt.Sprintf("核查 %s 前后的工艺、负荷、供应商或人员变更。", c.FirstAt.Format("2006-01-02")),
+	}
+	h.Evidence = r.orderEvidence(c.OrderIDs, "集中爆发的故障", h.Confidence)
+	r.hypotheses = append(r.hypotheses, h)
+}
+
+func (r *rootCauseRun) ordersOf(c dto.FailureCluster, equip map[uint]bool) []uint {
+	var ids []uint
+	for _, id := range c.OrderIDs {
+		if equip[r.orderByID[id].EquipmentID] {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func (r *rootCauseRun) orderEvidence(ids []uint, title string, score float64) []dto.EvidenceItem {
+	var evs []dto.EvidenceItem
+	for i, id := range ids {
+		if i >= 5 {
+			break
+		}
+		o := r.orderByID[id]
+		evs = append(evs, dto.EvidenceItem{
+			EvidenceType: "repair_order", SourceTable: "repair_orders", SourceID: id, Title: title, Score: score,
+			Excerpt: fmt.Sprintf("%s %s 报修：%s", r.equipment[o.EquipmentID].Code, o.CreatedAt.Format("2006-01-02"), o.FaultDescription),
+		})
+	}
+	return evs
+}
+
+func (r *rootCauseRun) userName(id uint) string {
+	if name := r.h.Users[id]; name != "" {
+		return name
+	}
+	return fmt.Sprintf("用户#%d", id)
+}
+
+func sortedIDs(set map[uint]bool) []uint {
+	ids := make([]uint, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func clamp(v, lo, hi float64) float64 {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return round2(v)
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func round2(v float64) float64 {
+	return float64(int(v*100+0.5)) / 100
+}
@@ -0,0 +1,138 @@
+package analyzer
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ems/backend/internal/agent/dto"
+	"github.com/ems/backend/internal/agent/tool"
+	"github.com/ems/backend/internal/model"
+	"github.com/ems/backend/pkg/config"
+	"github.com/ems/backend/pkg/memory"
+)
+
+// setupRootCauseFleet 8 台同型设备：5 台在保养时换上了批次 B-2406 的轴承，40 天后相继出现轴承故障
+func setupRootCauseFleet(t *testing.T) (*RootCauseAnalyzer, uint, []uint) {
+	config.Cfg = &config.Config{
+		Storage: config.StorageConfig{Mode: "memory"},
+	}
+	store := memory.GetStore()
+	now := time.Date(2026, 6, 30, 12, 0, 0, 0, time.Local)
+	day := 24 * time.Hour
+
+	typeID := store.NextID()
+	ws1, ws2 := store.NextID(), store.NextID()
+	store.Workshops[ws1] = &model.Workshop{BaseModel: model.BaseModel{ID: ws1}, FactoryID: 1, Name: "一车间"}
+	store.Workshops[ws2] = &model.Workshop{BaseModel: model.BaseModel{ID: ws2}, FactoryID: 1, Name: "二车间"}
+	var equip []uint
+	for i := 0; i < 8; i++ {
+		id := store.NextID()
+		ws := ws1
+		if i >= 6 {
+			ws = ws2
+		}
+		store.Equipment[id] = &model.Equipment{BaseModel: model.BaseModel{ID: id}, Code: "CNC-" + string(rune('A'+i)), TypeID: typeID, WorkshopID: ws}
+		equip = append(equip, id)
+	}
+
+	partID := store.NextID()
+	store.SpareParts[partID] = &model.SparePart{BaseModel: model.BaseModel{ID: partID}, Name: "主轴轴承"}
+	oldBatch, newBatch := store.NextID(), store.NextID()
+	store.SparePartTransactions[oldBatch] = &model.SparePartTransaction{BaseModel: model.BaseModel{ID: oldBatch, CreatedAt: now.Add(-300 * day)},
+		SparePartID: partID, FactoryID: 1, Type: "in", Quantity: 10, BatchNo: "B-2311"}
+	store.SparePartTransactions[newBatch] = &model.SparePartTransaction{BaseModel: model.BaseModel{ID: newBatch, CreatedAt: now.Add(-120 * day)},
+		SparePartID: partID, FactoryID: 1, Type: "in", Quantity: 10, BatchNo: "B-2406"}
+
+	for i, eq := range equip {
+		completed := now.Add(-250 * day)
+		if i < 5 {
+			completed = now.Add(-100 * day)
+		}
+		taskID := store.NextID()
+		store.MaintenanceTasks[taskID] = &model.MaintenanceTask{BaseModel: model.BaseModel{ID: taskID},
+			EquipmentID: eq, Status: "completed", CompletedAt: &completed, AssignedTo: 1}
+		consID := store.NextID()
+		store.SparePartConsumption[consID] = &model.SparePartConsumption{BaseModel: model.BaseModel{ID: consID, CreatedAt: completed},
+			SparePartID: partID, TaskID: &taskID, Quantity: 1}
+	}
+
+	failing := append(append([]uint{}, equip[:5]...), equip[6])
+	for i, eq := range failing {
+		id := store.NextID()
+		store.RepairOrders[id] = &model.RepairOrder{BaseModel: model.BaseModel{ID: id, CreatedAt: now.Add(time.Duration(-60+i*4) * day)},
+			EquipmentID: eq, FaultDescription: "主轴轴承异响", Status: model.RepairClosed}
+	}
+
+	a := NewRootCauseAnalyzer(tool.NewFleetTool(), nil)
+	a.now = func() time.Time { return now }
+	return a, typeID, equip
+}
+
+func TestRootCause_SparePartBatch(t *testing.T) {
+	a, typeID, equip := setupRootCauseFleet(t)
+	user := model.User{BaseModel: model.BaseModel{ID: 1}, Role: model.RoleAdmin}
+
+	data, err := a.Analyze(&dto.RootCauseRequest{EquipmentTypeID: typeID}, user)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(data.Clusters) != 1 || len(data.Clusters[0].EquipmentIDs) != 6 || !strings.HasPrefix(data.Clusters[0].Key, "part:轴承") {
+		t.Fatalf("Expected one bearing cluster over 6 equipment, got %+v", data.Clusters)
+	}
+	if len(data.Hypotheses) == 0 {
+		t.Fatal("Expected ranked hypotheses")
+	}
+	top := data.Hypotheses[0]
+	if top.Rank != 1 || top.Category != "spare_part_batch" || !strings.Contains(top.Title, "B-2406") {
+		t.Fatalf("Expected batch B-2406 ranked first, got %+v", top)
+	}
+	if top.Support != 5 || len(top.EquipmentIDs) != 5 || top.EquipmentIDs[0] != equip[0] {
+		t.Errorf("Expected the 5 equipment fitted with the batch, got %v", top.EquipmentIDs)
+	}
+	hasBatch := false
+	for _, ev := range top.Evidence {
+		if ev.SourceTable == "spare_part_transactions" {
+			hasBatch = true
+		}
+	}
+	if !hasBatch || len(data.Evidence) < len(top.Evidence) {
+		t.Errorf("Expected batch evidence to be attached, got %v", top.Evidence)
+	}
+	for _, h := range data.Hypotheses {
+		if strings.Contains(h.Title, "B-2311") {
+			t.Errorf("Batch fitted mostly to healthy equipment should not be a hypothesis: %+v", h)
+		}
+	}
+}
+
+func TestRootCause_FactoryIsolation(t *testing.T) {
+	a, typeID, _ := setupRootCauseFleet(t)
+	other := uint(2)
+	user := model.User{BaseModel: model.BaseModel{ID: 2}, Role: model.RoleSupervisor, FactoryID: &other}
+
+	data, err := a.Analyze(&dto.RootCauseRequest{EquipmentTypeID: typeID, FactoryID: 1}, user)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(data.Clusters) != 0 || len(data.Hypotheses) != 0 {
+		t.Errorf("Expected no access to another factory's fleet, got %+v", data)
+	}
+}
+
+func TestFaultKey(t *testing.T) {
+	cases := []struct {
+		order model.RepairOrder
+		key   string
+	}{
+		{model.RepairOrder{FaultCode: "E101", FaultDescription: "轴承异响"}, "code:E101"},
+		{model.RepairOrder{FaultDescription: "液压站漏油"}, "part:液压"},
+		{model.RepairOrder{FaultDescription: "运行中异响"}, "symptom:异响"},
+		{model.RepairOrder{FaultDescription: "外观划伤"}, ""},
+	}
+	for _, tc := range cases {
+		if key, _ := faultKey(tc.order); key != tc.key {
+			t.Errorf("faultKey(%q) = %q, want %q", tc.order.FaultDescription, key, tc.key)
+		}
+	}
+}
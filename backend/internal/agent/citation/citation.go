@@ -0,0 +1,260 @@
+// Package citation validates inline evidence markers ([E1], [E2]...) in LLM
+// summaries against the evidence the agent actually collected.
+package citation
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ems/backend/internal/agent/dto"
+	"github.com/ems/backend/internal/model"
+)
+
+// 未通过校验的原因
+const (
+	ReasonUnknownCitation = "unknown_citation"
+	ReasonMissingCitation = "missing_citation"
+	ReasonNumberMismatch  = "number_not_in_evidence"
+)
+
+// 置信度低于该值时下调风险等级
+const downgradeThreshold = 0.5
+
+var (
+	markerGroupRe = regexp.MustCompile(`[\[【]\s*(E\d+(?:\s*[,，、]\s*E\d+)*)\s*[\]】]`)
+	markerIDRe    = regexp.MustCompile(`E(\d+)`)
+	numberRe      = regexp.MustCompile(`\d+(?:,\d{3})*(?:\.\d+)?%?`)
+	ordinalRe     = regexp.MustCompile(`^\s*(?:#+\s*)?(?:[-*•]\s*|\d{1,2}(?:[、)）]|\.[^\d]))?`)
+	sentenceRe    = regexp.MustCompile(`[^。！？!?；;\n]+[。！？!?；;]?`)
+)
+
+// Marker 返回第 i 条证据（从 1 开始）的引用标记
+func Marker(i int) string {
+	return fmt.Sprintf("E%d", i)
+}
+
+// Result 校验结果
+type Result struct {
+	Citations  []dto.CitationItem
+	Report     dto.GroundingReport
+	Confidence float64
+}
+
+type claim struct {
+	text    string
+	markers []string
+}
+
+// Validate 校验摘要中的引用标记与数字。
+// evidence 顺序即标记编号；context 为分析器的结构化结果，其中出现的数字同样视为有据可查。
+func Validate(summary string, evidence []dto.EvidenceItem, context ...interface{}) *Result {
+	res := &Result{Confidence: 1}
+
+	evidenceText := make([]string, len(evidence))
+	for i, ev := range evidence {
+		evidenceText[i] = ev.Title + " " + ev.Excerpt
+	}
+	var contextText strings.Builder
+	for _, c := range context {
+		if c == nil {
+			continue
+		}
+		if b, err := json.Marshal(c); err == nil {
+			contextText.Write(b)
+			contextText.WriteByte(' ')
+		}
+	}
+
+	cited := map[int]*dto.CitationItem{}
+	unknown := map[string]bool{}
+	var order []int
+
+	for _, cl := range splitClaims(summary) {
+		numbers := extractNumbers(cl.text)
+		if len(cl.markers) == 0 && len(numbers) == 0 {
+			continue
+		}
+		res.Report.TotalClaims++
+
+		var corpus strings.Builder
+		var bad []string
+		for _, m := range cl.markers {
+			idx, _ := strconv.Atoi(strings.TrimPrefix(m, "E"))
+			if idx < 1 || idx > len(evidence) {
+				bad = append(bad, m)
+				if !unknown[m] {
+					unknown[m] = true
+					res.Report.UnknownMarkers = append(res.Report.UnknownMarkers, m)
+				}
+				continue
+			}
+			corpus.WriteString(evidenceText[idx-1])
+			corpus.WriteByte(' ')
+			item, ok := cited[idx]
+			if !ok {
+				ev := evidence[idx-1]
+				item = &dto.CitationItem{
+					Marker: m, EvidenceIndex: idx, EvidenceType: ev.EvidenceType,
+					SourceTable: ev.SourceTable, SourceID: ev.SourceID, Title: ev.Title, Valid: true,
+				}
+				cited[idx] = item
+				order = append(order, idx)
+			}
+			item.Claims = append(item.Claims, cl.text)
+		}
+		corpus.WriteString(contextText.String())
+
+		var missing []string
+		if len(numbers) > 0 {
+			known := extractNumbers(corpus.String())
+			if len(cl.markers) == 0 {
+				known = extractNumbers(strings.Join(evidenceText, " ") + " " + contextText.String())
+			}
+			for _, n := range numbers {
+				if !containsNumber(known, n) {
+					missing = append(missing, n)
+				}
+			}
+		}
+
+		switch {
+		case len(bad) > 0:
+			res.Report.UnsupportedClaims = append(res.Report.UnsupportedClaims, dto.UnsupportedClaim{
+				Text: cl.text, Reason: ReasonUnknownCitation, Markers: bad, Numbers: missing,
+			})
+		case len(missing) > 0:
+			res.Report.UnsupportedClaims = append(res.Report.UnsupportedClaims, dto.UnsupportedClaim{
+				Text: cl.text, Reason: ReasonNumberMismatch, Numbers: missing, Markers: cl.markers,
+			})
+		case len(cl.markers) == 0:
+			res.Report.UnsupportedClaims = append(res.Report.UnsupportedClaims, dto.UnsupportedClaim{
+				Text: cl.text, Reason: ReasonMissingCitation, Numbers: numbers,
+			})
+		default:
+			res.Report.SupportedClaims++
+		}
+	}
+
+	res.Citations = make([]dto.CitationItem, 0, len(order)+len(res.Report.UnknownMarkers))
+	for _, idx := range order {
+		res.Citations = append(res.Citations, *cited[idx])
+	}
+	for _, m := range res.Report.UnknownMarkers {
+		res.Citations = append(res.Citations, dto.CitationItem{Marker: m, Valid: false})
+	}
+
+	if res.Report.TotalClaims > 0 {
+		res.Confidence = float64(res.Report.SupportedClaims) / float64(res.Report.TotalClaims)
+	}
+	res.Confidence -= 0.1 * float64(len(res.Report.UnknownMarkers))
+	res.Confidence = math.Max(0, math.Round(res.Confidence*100)/100)
+	return res
+}
+
+// AdjustRiskLevel 在论断缺乏证据支撑时将风险等级下调一级
+func (r *Result) AdjustRiskLevel(level string) string {
+	if r.Confidence >= downgradeThreshold || len(r.Report.UnsupportedClaims) == 0 {
+		return level
+	}
+	downgraded := level
+	switch strings.ToLower(level) {
+	case "critical":
+		downgraded = "high"
+	case "high":
+		downgraded = "medium"
+	case "medium":
+		downgraded = "low"
+	}
+	if downgraded != level {
+		r.Report.OriginalRiskLevel = level
+	}
+	return downgraded
+}
+
+// BindLinks 将落库后的证据记录 ID 回填到引用（links 与 evidence 顺序一致）
+func (r *Result) BindLinks(links []model.AgentEvidenceLink) {
+	for i := range r.Citations {
+		idx := r.Citations[i].EvidenceIndex
+		if idx >= 1 && idx <= len(links) {
+			r.Citations[i].EvidenceLinkID = links[idx-1].ID
+		}
+	}
+}
+
+// splitClaims 按句切分，仅含引用标记的片段并入上一句
+func splitClaims(text string) []claim {
+	var claims []claim
+	for _, seg := range sentenceRe.FindAllString(text, -1) {
+		var markers []string
+		for _, group := range markerGroupRe.FindAllStringSubmatch(seg, -1) {
+			for _, id := range markerIDRe.FindAllStringSubmatch(group[1], -1) {
+				markers = append(markers, "E"+id[1])
+			}
+		}
+		body := strings.TrimSpace(markerGroupRe.ReplaceAllString(seg, ""))
+		bare := strings.Trim(body, "。！？!?；;，, ")
+		if bare == "" {
+			if len(markers) > 0 && len(claims) > 0 {
+				claims[len(claims)-1].markers = append(claims[len(claims)-1].markers, markers...)
+			}
+			continue
+		}
+		claims = append(claims, claim{text: body, markers: markers})
+	}
+	return claims
+}
+
+// extractNumbers 提取句中的数字（去掉列表序号与引用标记）
+func extractNumbers(text string) []string {
+	text = markerGroupRe.ReplaceAllString(text, "")
+	var out []string
+	for _, line := range strings.Split(text, "\n") {
+		line = ordinalRe.ReplaceAllString(line, "")
+		out = append(out, numberRe.FindAllString(line, -1)...)
+	}
+	return out
+}
+
+// containsNumber 判断数字是否出现在证据中：按声明的小数位取整比较，百分数同时匹配小数形式（65% ≈ 0.648）
+func containsNumber(known []string, claimed string) bool {
+	isPercent := strings.HasSuffix(claimed, "%")
+	value, decimals, ok := parseNumber(claimed)
+	if !ok {
+		return true
+	}
+	for _, k := range known {
+		v, _, ok := parseNumber(k)
+		if !ok {
+			continue
+		}
+		if roundTo(v, decimals) == value {
+			return true
+		}
+		if isPercent && !strings.HasSuffix(k, "%") && v <= 1 && roundTo(v*100, decimals) == value {
+			return true
+		}
+	}
+	return false
+}
+
+func parseNumber(s string) (float64, int, bool) {
+	s = strings.TrimSuffix(strings.ReplaceAll(s, ",", ""), "%")
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	decimals := 0
+	if dot := strings.IndexByte(s, '.'); dot >= 0 {
+		decimals = len(s) - dot - 1
+	}
+	return v, decimals, true
+}
+
+func roundTo(v float64, decimals int) float64 {
+	p := math.Pow(10, float64(decimals))
+	return math.Round(v*p) / p
+}
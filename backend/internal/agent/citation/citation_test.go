@@ -0,0 +1,75 @@
+package citation
+
+import (
+	"testing"
+
+	"github.com/ems/backend/internal/agent/dto"
+	"github.com/ems/backend/internal/model"
+)
+
+var testEvidence = []dto.EvidenceItem{
+	{EvidenceType: "repair_cost", SourceTable: "repair_orders", SourceID: 12, Title: "维修费用", Excerpt: "累计维修费 32,400 元，设备原值 50000 元，占比 0.648"},
+	{EvidenceType: "failure_stats", SourceTable: "equipment", SourceID: 3, Title: "故障统计", Excerpt: "近 30 天故障 4 次"},
+}
+
+func TestValidateSupportedClaims(t *testing.T) {
+	summary := "累计维修费已达原值的 65% [E1]。近 30 天故障 4 次。[E2]\n建议评估是否更换主轴。"
+	res := Validate(summary, testEvidence)
+
+	if res.Report.TotalClaims != 2 || res.Report.SupportedClaims != 2 {
+		t.Fatalf("Expected 2/2 supported claims, got %+v", res.Report)
+	}
+	if res.Confidence != 1 {
+		t.Errorf("Expected confidence 1, got %v", res.Confidence)
+	}
+	if len(res.Citations) != 2 || res.Citations[0].Marker != "E1" || res.Citations[0].SourceID != 12 || !res.Citations[0].Valid {
+		t.Errorf("Unexpected citations: %+v", res.Citations)
+	}
+	if res.AdjustRiskLevel("high") != "high" {
+		t.Error("Expected risk level unchanged for grounded summary")
+	}
+}
+
+func TestValidateFlagsUnsupportedClaims(t *testing.T) {
+	summary := "累计维修费已达原值的 80% [E1]。停机损失约 12 万元 [E7]。近期故障 4 次。"
+	res := Validate(summary, testEvidence)
+
+	if res.Report.SupportedClaims != 0 || len(res.Report.UnsupportedClaims) != 3 {
+		t.Fatalf("Expected 3 unsupported claims, got %+v", res.Report)
+	}
+	reasons := []string{ReasonNumberMismatch, ReasonUnknownCitation, ReasonMissingCitation}
+	for i, want := range reasons {
+		if got := res.Report.UnsupportedClaims[i].Reason; got != want {
+			t.Errorf("claim %d: expected reason %s, got %s", i, want, got)
+		}
+	}
+	if len(res.Report.UnknownMarkers) != 1 || res.Report.UnknownMarkers[0] != "E7" {
+		t.Errorf("Expected unknown marker E7, got %v", res.Report.UnknownMarkers)
+	}
+	if res.Confidence != 0 {
+		t.Errorf("Expected confidence clamped to 0, got %v", res.Confidence)
+	}
+
+	if got := res.AdjustRiskLevel("high"); got != "medium" {
+		t.Errorf("Expected high to be downgraded to medium, got %s", got)
+	}
+	if res.Report.OriginalRiskLevel != "high" {
+		t.Errorf("Expected original risk level recorded, got %q", res.Report.OriginalRiskLevel)
+	}
+}
+
+func TestValidateUsesContextNumbers(t *testing.T) {
+	context := map[string]interface{}{"overdue_tasks": 7}
+	res := Validate("共有 7 项保养任务逾期 [E2]。", testEvidence, context)
+	if res.Report.SupportedClaims != 1 {
+		t.Errorf("Expected number from analyzer context to be accepted, got %+v", res.Report)
+	}
+}
+
+func TestBindLinks(t *testing.T) {
+	res := Validate("近 30 天故障 4 次 [E2]。", testEvidence)
+	res.BindLinks([]model.AgentEvidenceLink{{BaseModel: model.BaseModel{ID: 101}}, {BaseModel: model.BaseModel{ID: 102}}})
+	if res.Citations[0].EvidenceLinkID != 102 {
+		t.Errorf("Expected evidence link 102, got %d", res.Citations[0].EvidenceLinkID)
+	}
+}
@@ -1,17 +1,24 @@
 package controller
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"strconv"
 
 	"github.com/ems/backend/internal/agent/dto"
+	"github.com/ems/backend/internal/agent/pushfilter"
 	"github.com/ems/backend/internal/agent/service"
+	"github.com/ems/backend/internal/agent/tool"
+	"github.com/ems/backend/internal/agent/webhook"
 	"github.com/ems/backend/internal/middleware"
 	"github.com/ems/backend/internal/model"
 	"github.com/ems/backend/pkg/database"
 	"github.com/ems/backend/pkg/trace"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type AgentController struct {
@@ -119,6 +126,36 @@ func (ctrl *AgentController) AuditRepair(c *gin.Context) {
 	c.JSON(http.StatusOK, result)
 }
 
+// AnalyzeRootCause clusters fleet-wide failures and ranks root cause hypotheses
+// @Summary Fleet-level root cause analysis
+// @Tags agent
+// @Router /agent/root-cause [post]
+func (ctrl *AgentController) AnalyzeRootCause(c *gin.Context) {
+	var req dto.RootCauseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.AgentErrorEnvelope{
+			Success: false,
+			TraceID: trace.GenerateTraceID(),
+			Error:   dto.AgentErrDetail{Code: "INVALID_ARGUMENT", Message: err.Error()},
+		})
+		return
+	}
+	userID, _, ok := requireAuth(c)
+	if !ok {
+		return
+	}
+	user, ok := loadUser(c, userID)
+	if !ok {
+		return
+	}
+	result, err := ctrl.agentService.AnalyzeRootCause(user, &req)
+	if err != nil {
+		agentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
 // AuditMaintenance audits maintenance plan
 func (ctrl *AgentController) AuditMaintenance(c *gin.Context) {
 	var req dto.MaintenanceAuditRequest
@@ -191,6 +228,36 @@ func (ctrl *AgentController) Analyze(c *gin.Context) {
 	c.JSON(http.StatusOK, result)
 }
 
+// Orchestrate decomposes a complex question into parallel sub-tasks and merges their results
+// @Summary Planner-executor orchestration
+// @Tags agent
+// @Router /agent/orchestrate [post]
+func (ctrl *AgentController) Orchestrate(c *gin.Context) {
+	var req dto.OrchestrateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.AgentErrorEnvelope{
+			Success: false,
+			TraceID: trace.GenerateTraceID(),
+			Error:   dto.AgentErrDetail{Code: "INVALID_ARGUMENT", Message: err.Error()},
+		})
+		return
+	}
+	userID, _, ok := requireAuth(c)
+	if !ok {
+		return
+	}
+	user, ok := loadUser(c, userID)
+	if !ok {
+		return
+	}
+	result, err := ctrl.agentService.Orchestrate(user, &req)
+	if err != nil {
+		agentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
 // GetSession returns session metadata
 func (ctrl *AgentController) GetSession(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
@@ -245,6 +312,47 @@ func (ctrl *AgentController) GetArtifact(c *gin.Context) {
 	c.JSON(http.StatusOK, result)
 }
 
+// ExportArtifact renders an artifact as a downloadable report
+// @Summary Export agent artifact
+// @Tags agent
+// @Param format query string false "pdf | docx | md" default(pdf)
+// @Router /agent/artifacts/{id}/export [get]
+func (ctrl *AgentController) ExportArtifact(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.AgentErrorEnvelope{
+			Success: false,
+			TraceID: trace.GenerateTraceID(),
+			Error:   dto.AgentErrDetail{Code: "INVALID_ARGUMENT", Message: "Invalid ID"},
+		})
+		return
+	}
+	format := c.DefaultQuery("format", "pdf")
+	if format != "pdf" && format != "docx" && format != "md" {
+		c.JSON(http.StatusBadRequest, dto.AgentErrorEnvelope{
+			Success: false,
+			TraceID: trace.GenerateTraceID(),
+			Error:   dto.AgentErrDetail{Code: "INVALID_ARGUMENT", Message: "format must be one of pdf, docx, md"},
+		})
+		return
+	}
+	userID, role, ok := requireAuth(c)
+	if !ok {
+		return
+	}
+	result, err := ctrl.agentService.ExportArtifact(uint(id), userID, role, format)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.AgentErrorEnvelope{
+			Success: false,
+			TraceID: trace.GenerateTraceID(),
+			Error:   dto.AgentErrDetail{Code: "INTERNAL_ERROR", Message: err.Error()},
+		})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, result.Filename))
+	c.Data(http.StatusOK, result.ContentType, result.Data)
+}
+
 // ListSessions returns user's recent agent sessions
 func (ctrl *AgentController) ListSessions(c *gin.Context) {
 	userID, _, ok := requireAuth(c)
@@ -377,14 +485,10 @@ func (ctrl *AgentController) Chat(c *gin.Context) {
 		return
 	}
 
-	result, err := ctrl.agentService.Chat(user, &req)
+	result, err := ctrl.agentService.Chat(c.Request.Context(), user, &req)
 	if err != nil {
 		log.Printf("[AgentController] Chat service error: %v", err)
-		c.JSON(http.StatusInternalServerError, dto.AgentErrorEnvelope{
-			Success: false,
-			TraceID: trace.GenerateTraceID(),
-			Error:   dto.AgentErrDetail{Code: "INTERNAL_ERROR", Message: err.Error()},
-		})
+		agentError(c, err)
 		return
 	}
 	
@@ -398,7 +502,20 @@ func (ctrl *AgentController) ListConversations(c *gin.Context) {
 	if !ok {
 		return
 	}
-	result, err := ctrl.agentService.ListConversations(userID, 20)
+	// 默认只列出进行中的对话；status=archived 查看归档，status=all 查看全部
+	status := c.DefaultQuery("status", "active")
+	if status != "active" && status != "archived" && status != "all" {
+		c.JSON(http.StatusBadRequest, dto.AgentErrorEnvelope{
+			Success: false,
+			TraceID: trace.GenerateTraceID(),
+			Error:   dto.AgentErrDetail{Code: "INVALID_ARGUMENT", Message: "status must be one of active, archived, all"},
+		})
+		return
+	}
+	if status == "all" {
+		status = ""
+	}
+	result, err := ctrl.agentService.ListConversations(userID, status, 20)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, dto.AgentErrorEnvelope{
 			Success: false,
@@ -437,6 +554,220 @@ func (ctrl *AgentController) GetConversation(c *gin.Context) {
 	c.JSON(http.StatusOK, result)
 }
 
+func parseIDParam(c *gin.Context, name string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.AgentErrorEnvelope{
+			Success: false,
+			TraceID: trace.GenerateTraceID(),
+			Error:   dto.AgentErrDetail{Code: "INVALID_ARGUMENT", Message: "Invalid " + name},
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// UpdateConversation renames, archives or restores a conversation
+// @Summary Update conversation
+// @Tags agent
+// @Router /agent/conversations/{id} [put]
+func (ctrl *AgentController) UpdateConversation(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req dto.UpdateConversationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.AgentErrorEnvelope{
+			Success: false,
+			TraceID: trace.GenerateTraceID(),
+			Error:   dto.AgentErrDetail{Code: "INVALID_ARGUMENT", Message: err.Error()},
+		})
+		return
+	}
+	userID, _, ok := requireAuth(c)
+	if !ok {
+		return
+	}
+	result, err := ctrl.agentService.UpdateConversation(id, userID, &req)
+	if err != nil {
+		agentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// DeleteConversation deletes a conversation with its messages and share links
+// @Summary Delete conversation
+// @Tags agent
+// @Router /agent/conversations/{id} [delete]
+func (ctrl *AgentController) DeleteConversation(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	userID, _, ok := requireAuth(c)
+	if !ok {
+		return
+	}
+	if err := ctrl.agentService.DeleteConversation(id, userID); err != nil {
+		agentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// SearchMessages searches the current user's message history
+// @Summary Search conversation messages
+// @Tags agent
+// @Param q query string true "keywords separated by spaces"
+// @Router /agent/messages/search [get]
+func (ctrl *AgentController) SearchMessages(c *gin.Context) {
+	userID, _, ok := requireAuth(c)
+	if !ok {
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	result, err := ctrl.agentService.SearchMessages(userID, c.Query("q"), limit)
+	if err != nil {
+		agentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// ExportConversation downloads a conversation, including tool calls, as Markdown or JSON
+// @Summary Export conversation
+// @Tags agent
+// @Param format query string false "md or json"
+// @Router /agent/conversations/{id}/export [get]
+func (ctrl *AgentController) ExportConversation(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	format := c.DefaultQuery("format", "md")
+	if format != "md" && format != "json" {
+		c.JSON(http.StatusBadRequest, dto.AgentErrorEnvelope{
+			Success: false,
+			TraceID: trace.GenerateTraceID(),
+			Error:   dto.AgentErrDetail{Code: "INVALID_ARGUMENT", Message: "format must be one of md, json"},
+		})
+		return
+	}
+	userID, role, ok := requireAuth(c)
+	if !ok {
+		return
+	}
+	result, err := ctrl.agentService.ExportConversation(id, userID, role, format)
+	if err != nil {
+		agentError(c, err)
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, result.Filename))
+	c.Data(http.StatusOK, result.ContentType, result.Data)
+}
+
+// CreateShare creates a read-only share link for colleagues in the same factory
+// @Summary Share conversation
+// @Tags agent
+// @Router /agent/conversations/{id}/shares [post]
+func (ctrl *AgentController) CreateShare(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req dto.CreateShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, dto.AgentErrorEnvelope{
+			Success: false,
+			TraceID: trace.GenerateTraceID(),
+			Error:   dto.AgentErrDetail{Code: "INVALID_ARGUMENT", Message: err.Error()},
+		})
+		return
+	}
+	userID, _, ok := requireAuth(c)
+	if !ok {
+		return
+	}
+	user, ok := loadUser(c, userID)
+	if !ok {
+		return
+	}
+	result, err := ctrl.agentService.CreateShare(id, user, &req)
+	if err != nil {
+		agentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// ListShares lists share links of a conversation
+// @Summary List conversation shares
+// @Tags agent
+// @Router /agent/conversations/{id}/shares [get]
+func (ctrl *AgentController) ListShares(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	userID, _, ok := requireAuth(c)
+	if !ok {
+		return
+	}
+	result, err := ctrl.agentService.ListShares(id, userID)
+	if err != nil {
+		agentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// RevokeShare revokes a share link
+// @Summary Revoke conversation share
+// @Tags agent
+// @Router /agent/conversations/{id}/shares/{shareId} [delete]
+func (ctrl *AgentController) RevokeShare(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	shareID, ok := parseIDParam(c, "shareId")
+	if !ok {
+		return
+	}
+	userID, _, ok := requireAuth(c)
+	if !ok {
+		return
+	}
+	if err := ctrl.agentService.RevokeShare(id, shareID, userID); err != nil {
+		agentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// GetSharedConversation opens a shared conversation in read-only mode
+// @Summary View shared conversation
+// @Tags agent
+// @Router /agent/shared/conversations/{token} [get]
+func (ctrl *AgentController) GetSharedConversation(c *gin.Context) {
+	userID, _, ok := requireAuth(c)
+	if !ok {
+		return
+	}
+	user, ok := loadUser(c, userID)
+	if !ok {
+		return
+	}
+	result, err := ctrl.agentService.GetSharedConversation(c.Param("token"), user)
+	if err != nil {
+		agentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
 // =====================================================
 // Phase 2: Skill Management Endpoints
 // =====================================================
@@ -615,16 +946,17 @@ func (ctrl *AgentController) Subscribe(c *gin.Context) {
 	if !ok {
 		return
 	}
-	err := ctrl.agentService.Subscribe(userID, req.PushType, req.Enabled, req.Scope, req.WebhookURL)
+	sub, secret, err := ctrl.agentService.Subscribe(userID, req.PushType, req.Enabled, req.Scope, req.WebhookURL)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, dto.AgentErrorEnvelope{
-			Success: false,
-			TraceID: trace.GenerateTraceID(),
-			Error:   dto.AgentErrDetail{Code: "INTERNAL_ERROR", Message: err.Error()},
-		})
+		agentError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "Subscription updated"})
+	resp := gin.H{"message": "Subscription updated", "id": sub.ID}
+	if secret != "" {
+		// 签名密钥只在生成时返回一次
+		resp["secret"] = secret
+	}
+	c.JSON(http.StatusOK, resp)
 }
 
 // ListSubscriptions returns user's push configurations
@@ -645,6 +977,162 @@ func (ctrl *AgentController) ListSubscriptions(c *gin.Context) {
 	c.JSON(http.StatusOK, subs)
 }
 
+// subscriptionParam 解析路径中的订阅 ID 并校验登录
+func subscriptionParam(c *gin.Context) (userID, subscriptionID uint, ok bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.AgentErrorEnvelope{
+			Success: false,
+			TraceID: trace.GenerateTraceID(),
+			Error:   dto.AgentErrDetail{Code: "INVALID_ARGUMENT", Message: "Invalid ID"},
+		})
+		return 0, 0, false
+	}
+	userID, _, ok = requireAuth(c)
+	return userID, uint(id), ok
+}
+
+// UpdateSubscription 修改订阅地址、范围或启用状态
+func (ctrl *AgentController) UpdateSubscription(c *gin.Context) {
+	userID, id, ok := subscriptionParam(c)
+	if !ok {
+		return
+	}
+	var req dto.UpdateSubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.AgentErrorEnvelope{
+			Success: false,
+			TraceID: trace.GenerateTraceID(),
+			Error:   dto.AgentErrDetail{Code: "INVALID_ARGUMENT", Message: err.Error()},
+		})
+		return
+	}
+	sub, err := ctrl.agentService.UpdateSubscription(userID, id, &req)
+	if err != nil {
+		agentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, sub)
+}
+
+// DeleteSubscription 删除订阅
+func (ctrl *AgentController) DeleteSubscription(c *gin.Context) {
+	userID, id, ok := subscriptionParam(c)
+	if !ok {
+		return
+	}
+	if err := ctrl.agentService.DeleteSubscription(userID, id); err != nil {
+		agentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Subscription deleted"})
+}
+
+// PauseSubscription 暂停订阅
+func (ctrl *AgentController) PauseSubscription(c *gin.Context) {
+	ctrl.setSubscriptionPaused(c, true)
+}
+
+// ResumeSubscription 恢复订阅，同时清除自动停用状态
+func (ctrl *AgentController) ResumeSubscription(c *gin.Context) {
+	ctrl.setSubscriptionPaused(c, false)
+}
+
+func (ctrl *AgentController) setSubscriptionPaused(c *gin.Context, paused bool) {
+	userID, id, ok := subscriptionParam(c)
+	if !ok {
+		return
+	}
+	sub, err := ctrl.agentService.SetSubscriptionPaused(userID, id, paused)
+	if err != nil {
+		agentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, sub)
+}
+
+// RotateSubscriptionSecret 轮换订阅的签名密钥
+func (ctrl *AgentController) RotateSubscriptionSecret(c *gin.Context) {
+	userID, id, ok := subscriptionParam(c)
+	if !ok {
+		return
+	}
+	secret, err := ctrl.agentService.RotateSubscriptionSecret(userID, id)
+	if err != nil {
+		agentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": id, "secret": secret, "previous_secret_valid_for": webhook.SecretGracePeriod.String()})
+}
+
+// ListSubscriptionDeliveries 返回订阅最近的投递记录
+func (ctrl *AgentController) ListSubscriptionDeliveries(c *gin.Context) {
+	userID, id, ok := subscriptionParam(c)
+	if !ok {
+		return
+	}
+	var q dto.DeliveryQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, dto.AgentErrorEnvelope{
+			Success: false,
+			TraceID: trace.GenerateTraceID(),
+			Error:   dto.AgentErrDetail{Code: "INVALID_ARGUMENT", Message: err.Error()},
+		})
+		return
+	}
+	deliveries, err := ctrl.agentService.ListDeliveries(userID, id, &q)
+	if err != nil {
+		agentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// RedeliverSubscriptionDelivery 以原负载重新投递
+func (ctrl *AgentController) RedeliverSubscriptionDelivery(c *gin.Context) {
+	userID, id, ok := subscriptionParam(c)
+	if !ok {
+		return
+	}
+	deliveryID, err := strconv.ParseUint(c.Param("delivery_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.AgentErrorEnvelope{
+			Success: false,
+			TraceID: trace.GenerateTraceID(),
+			Error:   dto.AgentErrDetail{Code: "INVALID_ARGUMENT", Message: "Invalid delivery ID"},
+		})
+		return
+	}
+	delivery, err := ctrl.agentService.Redeliver(userID, id, uint(deliveryID))
+	if err != nil {
+		agentError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, delivery)
+}
+
+// PingSubscription 同步发送一次测试投递
+func (ctrl *AgentController) PingSubscription(c *gin.Context) {
+	userID, id, ok := subscriptionParam(c)
+	if !ok {
+		return
+	}
+	delivery, err := ctrl.agentService.PingSubscription(c.Request.Context(), userID, id)
+	if err != nil {
+		agentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, delivery)
+}
+
+// ListPushSchemas 返回各推送类型的版本化负载 Schema
+func (ctrl *AgentController) ListPushSchemas(c *gin.Context) {
+	if _, _, ok := requireAuth(c); !ok {
+		return
+	}
+	c.JSON(http.StatusOK, ctrl.agentService.PushSchemas())
+}
+
 // GetEquipmentPrediction returns RUL and TCO for a specific equipment
 func (ctrl *AgentController) GetEquipmentPrediction(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
@@ -740,7 +1228,11 @@ func (ctrl *AgentController) CallTool(c *gin.Context) {
 	}
 
 	scopes, _ := middleware.GetAPIKeyScopes(c)
-	result, err := ctrl.agentService.CallTool(user, &req, scopes)
+	ctx := c.Request.Context()
+	if keyID, ok := c.Get(middleware.ContextKeyAPIKeyID); ok {
+		ctx = tool.WithCaller(ctx, fmt.Sprintf("apikey:%v", keyID))
+	}
+	result, err := ctrl.agentService.CallTool(ctx, user, &req, scopes)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, dto.AgentErrorEnvelope{
 			Success: false,
@@ -752,3 +1244,310 @@ func (ctrl *AgentController) CallTool(c *gin.Context) {
 
 	c.JSON(http.StatusOK, result)
 }
+
+// =====================================================
+// Answer Feedback Endpoints
+// =====================================================
+
+// loadUser loads the authenticated user, aborts with 500 if missing
+func loadUser(c *gin.Context, userID uint) (model.User, bool) {
+	var user model.User
+	if err := database.GetDB().First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, dto.AgentErrorEnvelope{
+			Success: false,
+			TraceID: trace.GenerateTraceID(),
+			Error:   dto.AgentErrDetail{Code: "INTERNAL_ERROR", Message: "User not found"},
+		})
+		return user, false
+	}
+	return user, true
+}
+
+func agentError(c *gin.Context, err error) {
+	status, code := http.StatusInternalServerError, "INTERNAL_ERROR"
+	switch {
+	case errors.Is(err, service.ErrPermissionDenied):
+		status, code = http.StatusForbidden, "FORBIDDEN"
+	case errors.Is(err, service.ErrNotUnderReview), errors.Is(err, service.ErrNoteReviewed):
+		status, code = http.StatusConflict, "CONFLICT"
+	case errors.Is(err, service.ErrSubscriptionDisabled):
+		status, code = http.StatusConflict, "CONFLICT"
+	case errors.Is(err, service.ErrInvalidWebhookURL), errors.Is(err, pushfilter.ErrInvalidFilter):
+		status, code = http.StatusBadRequest, "INVALID_ARGUMENT"
+	case errors.Is(err, service.ErrShareUnavailable):
+		status, code = http.StatusGone, "GONE"
+	case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, service.ErrSubscriptionNotFound), errors.Is(err, service.ErrDeliveryNotFound):
+		status, code = http.StatusNotFound, "NOT_FOUND"
+	}
+	c.JSON(status, dto.AgentErrorEnvelope{
+		Success: false,
+		TraceID: trace.GenerateTraceID(),
+		Error:   dto.AgentErrDetail{Code: code, Message: err.Error()},
+	})
+}
+
+func requireReviewer(c *gin.Context, role string) bool {
+	if role != string(model.RoleAdmin) && role != string(model.RoleSupervisor) {
+		c.JSON(http.StatusForbidden, dto.AgentErrorEnvelope{
+			Success: false,
+			TraceID: trace.GenerateTraceID(),
+			Error:   dto.AgentErrDetail{Code: "FORBIDDEN", Message: "Only admin or supervisor can review feedback"},
+		})
+		return false
+	}
+	return true
+}
+
+// SubmitFeedback rates an assistant message or artifact
+// @Summary Submit answer feedback
+// @Tags agent
+// @Router /agent/feedback [post]
+func (ctrl *AgentController) SubmitFeedback(c *gin.Context) {
+	var req dto.FeedbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.AgentErrorEnvelope{
+			Success: false,
+			TraceID: trace.GenerateTraceID(),
+			Error:   dto.AgentErrDetail{Code: "INVALID_ARGUMENT", Message: err.Error()},
+		})
+		return
+	}
+	userID, _, ok := requireAuth(c)
+	if !ok {
+		return
+	}
+	user, ok := loadUser(c, userID)
+	if !ok {
+		return
+	}
+	result, err := ctrl.agentService.SubmitFeedback(user, &req)
+	if err != nil {
+		agentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// ListFeedbackReviews returns the negative feedback review queue
+// @Summary List feedback review queue
+// @Tags agent
+// @Param status query string false "pending | resolved | dismissed"
+// @Router /agent/feedback/reviews [get]
+func (ctrl *AgentController) ListFeedbackReviews(c *gin.Context) {
+	userID, role, ok := requireAuth(c)
+	if !ok || !requireReviewer(c, role) {
+		return
+	}
+	user, ok := loadUser(c, userID)
+	if !ok {
+		return
+	}
+	result, err := ctrl.agentService.ListFeedbackReviews(user, c.Query("status"))
+	if err != nil {
+		agentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// ReviewFeedback resolves or dismisses a negative feedback item
+// @Summary Review feedback
+// @Tags agent
+// @Router /agent/feedback/{id}/review [put]
+func (ctrl *AgentController) ReviewFeedback(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.AgentErrorEnvelope{
+			Success: false,
+			TraceID: trace.GenerateTraceID(),
+			Error:   dto.AgentErrDetail{Code: "INVALID_ARGUMENT", Message: "Invalid ID"},
+		})
+		return
+	}
+	var req dto.FeedbackReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.AgentErrorEnvelope{
+			Success: false,
+			TraceID: trace.GenerateTraceID(),
+			Error:   dto.AgentErrDetail{Code: "INVALID_ARGUMENT", Message: err.Error()},
+		})
+		return
+	}
+	userID, role, ok := requireAuth(c)
+	if !ok || !requireReviewer(c, role) {
+		return
+	}
+	user, ok := loadUser(c, userID)
+	if !ok {
+		return
+	}
+	result, err := ctrl.agentService.ReviewFeedback(user, uint(id), &req)
+	if err != nil {
+		agentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// FeedbackAnalytics returns answer quality grouped by scenario, skill and model
+// @Summary Answer quality analytics
+// @Tags agent
+// @Param days query int false "look-back window in days" default(30)
+// @Router /agent/feedback/analytics [get]
+func (ctrl *AgentController) FeedbackAnalytics(c *gin.Context) {
+	userID, role, ok := requireAuth(c)
+	if !ok || !requireReviewer(c, role) {
+		return
+	}
+	user, ok := loadUser(c, userID)
+	if !ok {
+		return
+	}
+	days, _ := strconv.Atoi(c.DefaultQuery("days", "30"))
+	result, err := ctrl.agentService.FeedbackAnalytics(user, days)
+	if err != nil {
+		agentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// ListEquipmentNotes lists long-term equipment notes; status=pending is the moderation queue
+// @Summary List equipment notes
+// @Tags agent
+// @Param equipment_id query int false "equipment ID"
+// @Param status query string false "pending | approved | rejected"
+// @Param include_expired query bool false "include expired notes"
+// @Router /agent/equipment-notes [get]
+func (ctrl *AgentController) ListEquipmentNotes(c *gin.Context) {
+	var q dto.EquipmentNoteQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, dto.AgentErrorEnvelope{
+			Success: false,
+			TraceID: trace.GenerateTraceID(),
+			Error:   dto.AgentErrDetail{Code: "INVALID_ARGUMENT", Message: err.Error()},
+		})
+		return
+	}
+	userID, _, ok := requireAuth(c)
+	if !ok {
+		return
+	}
+	user, ok := loadUser(c, userID)
+	if !ok {
+		return
+	}
+	result, err := ctrl.agentService.ListEquipmentNotes(user, &q)
+	if err != nil {
+		agentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// AddEquipmentNote records a long-term note for an equipment
+// @Summary Add equipment note
+// @Tags agent
+// @Router /agent/equipment/{id}/notes [post]
+func (ctrl *AgentController) AddEquipmentNote(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.AgentErrorEnvelope{
+			Success: false,
+			TraceID: trace.GenerateTraceID(),
+			Error:   dto.AgentErrDetail{Code: "INVALID_ARGUMENT", Message: "Invalid ID"},
+		})
+		return
+	}
+	var req dto.EquipmentNoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.AgentErrorEnvelope{
+			Success: false,
+			TraceID: trace.GenerateTraceID(),
+			Error:   dto.AgentErrDetail{Code: "INVALID_ARGUMENT", Message: err.Error()},
+		})
+		return
+	}
+	userID, _, ok := requireAuth(c)
+	if !ok {
+		return
+	}
+	user, ok := loadUser(c, userID)
+	if !ok {
+		return
+	}
+	result, err := ctrl.agentService.AddEquipmentNote(user, uint(id), &req)
+	if err != nil {
+		agentError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, result)
+}
+
+// ReviewEquipmentNote approves or rejects a pending equipment note
+// @Summary Review equipment note
+// @Tags agent
+// @Router /agent/equipment-notes/{id}/review [put]
+func (ctrl *AgentController) ReviewEquipmentNote(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.AgentErrorEnvelope{
+			Success: false,
+			TraceID: trace.GenerateTraceID(),
+			Error:   dto.AgentErrDetail{Code: "INVALID_ARGUMENT", Message: "Invalid ID"},
+		})
+		return
+	}
+	var req dto.EquipmentNoteReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.AgentErrorEnvelope{
+			Success: false,
+			TraceID: trace.GenerateTraceID(),
+			Error:   dto.AgentErrDetail{Code: "INVALID_ARGUMENT", Message: err.Error()},
+		})
+		return
+	}
+	userID, role, ok := requireAuth(c)
+	if !ok || !requireReviewer(c, role) {
+		return
+	}
+	user, ok := loadUser(c, userID)
+	if !ok {
+		return
+	}
+	result, err := ctrl.agentService.ReviewEquipmentNote(user, uint(id), &req)
+	if err != nil {
+		agentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// DeleteEquipmentNote removes an equipment note
+// @Summary Delete equipment note
+// @Tags agent
+// @Router /agent/equipment-notes/{id} [delete]
+func (ctrl *AgentController) DeleteEquipmentNote(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.AgentErrorEnvelope{
+			Success: false,
+			TraceID: trace.GenerateTraceID(),
+			Error:   dto.AgentErrDetail{Code: "INVALID_ARGUMENT", Message: "Invalid ID"},
+		})
+		return
+	}
+	userID, _, ok := requireAuth(c)
+	if !ok {
+		return
+	}
+	user, ok := loadUser(c, userID)
+	if !ok {
+		return
+	}
+	if err := ctrl.agentService.DeleteEquipmentNote(user, uint(id)); err != nil {
+		agentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
@@ -1,6 +1,9 @@
 package dto
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // =====================================================
 // Common Agent DTOs
@@ -17,6 +20,9 @@ type AgentResponseEnvelope struct {
 	RiskLevel     string                 `json:"risk_level"`
 	ArtifactID    uint                   `json:"artifact_id,omitempty"`
 	EvidenceCount int                    `json:"evidence_count"`
+	Confidence    float64                `json:"confidence"`
+	Citations     []CitationItem         `json:"citations"`
+	Grounding     *GroundingReport       `json:"grounding,omitempty"`
 	Data          interface{}            `json:"data"`
 }
 
@@ -48,6 +54,36 @@ type EvidenceItem struct {
 	Score        float64 `json:"score"`
 }
 
+// CitationItem links an inline marker such as [E2] in the summary to an evidence item
+type CitationItem struct {
+	Marker         string   `json:"marker"`
+	EvidenceIndex  int      `json:"evidence_index"`
+	EvidenceLinkID uint     `json:"evidence_link_id,omitempty"`
+	EvidenceType   string   `json:"evidence_type,omitempty"`
+	SourceTable    string   `json:"source_table,omitempty"`
+	SourceID       uint     `json:"source_id,omitempty"`
+	Title          string   `json:"title,omitempty"`
+	Valid          bool     `json:"valid"`
+	Claims         []string `json:"claims,omitempty"`
+}
+
+// UnsupportedClaim is a sentence in the summary that the evidence does not back up
+type UnsupportedClaim struct {
+	Text    string   `json:"text"`
+	Reason  string   `json:"reason"`
+	Numbers []string `json:"numbers,omitempty"`
+	Markers []string `json:"markers,omitempty"`
+}
+
+// GroundingReport summarizes post-generation citation validation
+type GroundingReport struct {
+	TotalClaims       int                `json:"total_claims"`
+	SupportedClaims   int                `json:"supported_claims"`
+	UnknownMarkers    []string           `json:"unknown_markers,omitempty"`
+	UnsupportedClaims []UnsupportedClaim `json:"unsupported_claims,omitempty"`
+	OriginalRiskLevel string             `json:"original_risk_level,omitempty"`
+}
+
 // RecommendationItem represents a single recommendation
 type RecommendationItem struct {
 	Type        string `json:"type"`
@@ -137,6 +173,58 @@ type MaintenanceAuditData struct {
 	Evidence         []EvidenceItem `json:"evidence"`
 }
 
+// =====================================================
+// Root Cause Analysis DTOs
+// =====================================================
+
+// RootCauseRequest 跨设备（机型/车间）的故障根因分析请求
+type RootCauseRequest struct {
+	FactoryID       uint      `json:"factory_id"`
+	WorkshopID      uint      `json:"workshop_id"`
+	EquipmentTypeID uint      `json:"equipment_type_id"`
+	EquipmentIDs    []uint    `json:"equipment_ids"`
+	TimeRange       TimeRange `json:"time_range"`   // 默认最近 180 天
+	FaultCode       string    `json:"fault_code"`   // 只分析该故障代码（前缀匹配）
+	Keyword         string    `json:"keyword"`      // 只分析描述包含该关键词的工单
+	MinClusterSize  int       `json:"min_cluster_size" binding:"omitempty,min=2,max=50"`
+	Language        string    `json:"language"`
+	SystemPrompt    string    `json:"system_prompt"`
+}
+
+// FailureCluster 一组相似故障（故障代码或描述关键词相同）
+type FailureCluster struct {
+	Key            string    `json:"key"`
+	Label          string    `json:"label"`
+	OrderIDs       []uint    `json:"order_ids"`
+	EquipmentIDs   []uint    `json:"equipment_ids"`
+	EquipmentCodes []string  `json:"equipment_codes"`
+	FirstAt        time.Time `json:"first_at"`
+	LastAt         time.Time `json:"last_at"`
+	RecentShare    float64   `json:"recent_share"` // 落在时间窗后三分之一的比例，用于判断是否集中爆发
+}
+
+// RootCauseHypothesis 一条带支撑证据的根因假设
+type RootCauseHypothesis struct {
+	Rank         int            `json:"rank"`
+	Category     string         `json:"category"` // spare_part_batch, maintenance, technician, workshop, onset
+	ClusterKey   string         `json:"cluster_key"`
+	Title        string         `json:"title"`
+	Statement    string         `json:"statement"`
+	Confidence   float64        `json:"confidence"`
+	Support      int            `json:"support"` // 支撑该假设的设备或工单数
+	EquipmentIDs []uint         `json:"equipment_ids"`
+	OrderIDs     []uint         `json:"order_ids"`
+	Evidence     []EvidenceItem `json:"evidence"`
+	NextStep     string         `json:"next_step"`
+}
+
+type RootCauseData struct {
+	Clusters   []FailureCluster      `json:"clusters"`
+	Hypotheses []RootCauseHypothesis `json:"hypotheses"`
+	Stats      map[string]interface{} `json:"stats"`
+	Evidence   []EvidenceItem        `json:"evidence"`
+}
+
 // =====================================================
 // Analysis Assistant DTOs
 // =====================================================
@@ -151,11 +239,73 @@ type AnalyzeRequest struct {
 }
 
 type AnalyzeData struct {
-	KeyFindings       []string           `json:"key_findings"`
+	KeyFindings       []string           `json:"key_findings"` // Findings 的标题，兼容旧客户端
+	Findings          []AnalysisFinding  `json:"findings"`
+	RiskLevel         string             `json:"risk_level"`
 	MetricComparisons interface{}        `json:"metric_comparisons"`
 	TopEntities       interface{}        `json:"top_entities"`
 	Evidence          []EvidenceItem     `json:"evidence"`
-	RecommendedActions []string           `json:"recommended_actions"`
+	RecommendedActions []RecommendedAction `json:"recommended_actions"`
+}
+
+// AnalysisFinding 分析结论中的一条发现
+type AnalysisFinding struct {
+	Title        string `json:"title" binding:"required,max=100" desc:"一句话结论"`
+	Detail       string `json:"detail" binding:"max=500" desc:"依据与影响说明"`
+	RiskLevel    string `json:"risk_level" binding:"required,oneof=low medium high critical"`
+	EvidenceRefs []int  `json:"evidence_refs" desc:"支撑该结论的证据编号，即 [E1] 中的 1"`
+	Source       string `json:"source,omitempty"` // rule 或 llm，由服务端填写
+}
+
+// RecommendedAction 建议采取的行动
+type RecommendedAction struct {
+	Action    string `json:"action" binding:"required,max=200"`
+	Priority  string `json:"priority" binding:"required,oneof=high medium low"`
+	Rationale string `json:"rationale" binding:"max=300"`
+}
+
+// AnalysisReport 通用分析场景要求 LLM 按 schema 输出的结构化结果
+type AnalysisReport struct {
+	Summary            string              `json:"summary" binding:"required" desc:"结论先行的中文分析正文，按引用规则标注 [E1] 等证据编号"`
+	RiskLevel          string              `json:"risk_level" binding:"required,oneof=low medium high critical"`
+	Findings           []AnalysisFinding   `json:"findings" binding:"required,min=1,max=8"`
+	RecommendedActions []RecommendedAction `json:"recommended_actions" binding:"required,max=8"`
+}
+
+// =====================================================
+// Orchestration DTOs
+// =====================================================
+
+// OrchestrateRequest 复杂问题的规划-执行编排请求
+type OrchestrateRequest struct {
+	Question       string    `json:"question" binding:"required"`
+	FactoryID      uint      `json:"factory_id"`
+	TimeRange      TimeRange `json:"time_range"`
+	Language       string    `json:"language"`
+	MaxConcurrency int       `json:"max_concurrency" binding:"omitempty,min=1,max=8"` // 并行执行的子任务上限
+}
+
+// SubTaskResult 编排中单个子任务的执行结果
+type SubTaskResult struct {
+	ID            string                 `json:"id"`
+	Title         string                 `json:"title"`
+	Kind          string                 `json:"kind"`
+	Target        string                 `json:"target"`
+	Args          map[string]interface{} `json:"args,omitempty"`
+	DependsOn     []string               `json:"depends_on,omitempty"`
+	Status        string                 `json:"status"`
+	Summary       string                 `json:"summary,omitempty"`
+	Error         string                 `json:"error,omitempty"`
+	DurationMs    int64                  `json:"duration_ms"`
+	EvidenceRange string                 `json:"evidence_range,omitempty"` // 如 E3-E5
+	Data          interface{}            `json:"data,omitempty"`
+}
+
+type OrchestrationData struct {
+	Goal     string          `json:"goal"`
+	Planner  string          `json:"planner"` // llm, rule
+	Tasks    []SubTaskResult `json:"tasks"`
+	Evidence []EvidenceItem  `json:"evidence"`
 }
 
 // =====================================================
@@ -194,14 +344,25 @@ type AgentArtifactResponse struct {
 // =====================================================
 
 type ChatRequest struct {
-	ConversationID uint   `json:"conversation_id"` // 可选，不传则创建新会话
-	Message        string `json:"message" binding:"required"`
-	Context        any    `json:"context"`          // 补充上下文（如当前页面、选中的设备等）
-	SystemPrompt   string `json:"system_prompt"`   // 自定义系统提示词
+	ConversationID uint         `json:"conversation_id"` // 可选，不传则创建新会话
+	Message        string       `json:"message" binding:"required"`
+	Context        *ChatContext `json:"context"`       // 补充上下文（如当前页面、选中的设备等）
+	SystemPrompt   string       `json:"system_prompt"` // 自定义系统提示词
+}
+
+// ChatContext 前端随消息传入的当前页面状态，服务端按权限解析选中的对象后注入对话
+type ChatContext struct {
+	Route              string            `json:"route" binding:"omitempty,max=200"`               // 当前页面路由，如 /maintenance/tasks/42
+	EquipmentIDs       []uint            `json:"equipment_ids" binding:"omitempty,max=20"`        // 选中的设备
+	RepairOrderIDs     []uint            `json:"repair_order_ids" binding:"omitempty,max=20"`     // 选中的维修单
+	MaintenanceTaskIDs []uint            `json:"maintenance_task_ids" binding:"omitempty,max=20"` // 选中的保养任务
+	SparePartIDs       []uint            `json:"spare_part_ids" binding:"omitempty,max=20"`       // 选中的备件
+	Filters            map[string]string `json:"filters" binding:"omitempty,max=20"`              // 列表页当前的筛选条件
 }
 
 type ChatResponse struct {
 	ConversationID uint           `json:"conversation_id"`
+	MessageID      uint           `json:"message_id,omitempty"`
 	Reply          string         `json:"reply"`
 	TraceID        string         `json:"trace_id"`
 	ArtifactID     uint           `json:"artifact_id,omitempty"`
@@ -218,10 +379,56 @@ type ConversationResponse struct {
 }
 
 type MessageItem struct {
-	ID        uint      `json:"id"`
-	Role      string    `json:"role"`
-	Content   string    `json:"content"`
-	CreatedAt time.Time `json:"created_at"`
+	ID        uint             `json:"id"`
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	SkillID   string           `json:"skill_id,omitempty"`
+	ToolCalls []ToolCallRecord `json:"tool_calls,omitempty"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// ToolCallRecord 助手消息执行过程中的一次工具调用
+type ToolCallRecord struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+	Result    string `json:"result,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+type UpdateConversationRequest struct {
+	Title  *string `json:"title" binding:"omitempty,max=200"`
+	Status string  `json:"status" binding:"omitempty,oneof=active archived"`
+}
+
+type MessageSearchHit struct {
+	ConversationID    uint      `json:"conversation_id"`
+	ConversationTitle string    `json:"conversation_title"`
+	MessageID         uint      `json:"message_id"`
+	Role              string    `json:"role"`
+	Snippet           string    `json:"snippet"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+type CreateShareRequest struct {
+	ExpiresInHours int `json:"expires_in_hours" binding:"omitempty,min=1,max=2160"` // 不传则长期有效
+}
+
+type ConversationShareResponse struct {
+	ID             uint       `json:"id"`
+	ConversationID uint       `json:"conversation_id"`
+	Token          string     `json:"token"`
+	URL            string     `json:"url"` // 只读分享接口路径（需登录）
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// SharedConversationResponse 通过分享链接查看的只读对话
+type SharedConversationResponse struct {
+	ConversationResponse
+	ReadOnly bool `json:"read_only"`
+	SharedBy uint `json:"shared_by"`
 }
 
 // =====================================================
@@ -281,6 +488,8 @@ type ToolDefinition struct {
 	Name        string      `json:"name"`
 	Description string      `json:"description"`
 	InputSchema interface{} `json:"input_schema"`
+	// OutputSchema 可选，描述工具返回值结构
+	OutputSchema interface{} `json:"output_schema,omitempty"`
 }
 
 type ListToolsResponse struct {
@@ -296,3 +505,177 @@ type CallToolResponse struct {
 	Content interface{} `json:"content"`
 	IsError bool        `json:"is_error"`
 }
+
+// 内置工具入参，工具 schema 由 tool.SchemaOf 从这些结构体生成
+
+type EquipmentToolArgs struct {
+	EquipmentID uint `json:"equipment_id" binding:"required"`
+}
+
+type SearchEquipmentArgs struct {
+	Keyword string `json:"keyword" binding:"required,min=1" desc:"Search keyword"`
+}
+
+type SparePartInventoryArgs struct {
+	SparePartID uint  `json:"spare_part_id" binding:"required"`
+	FactoryID   *uint `json:"factory_id" desc:"Only honoured for admin users"`
+}
+
+type ReportRepairArgs struct {
+	EquipmentID      uint   `json:"equipment_id" binding:"required"`
+	FaultDescription string `json:"fault_description" binding:"required,min=1"`
+	Priority         int    `json:"priority" binding:"omitempty,oneof=1 2 3" desc:"1=High, 2=Medium, 3=Low"`
+}
+
+type FailureDistributionArgs struct {
+	EquipmentTypeID uint `json:"equipment_type_id" binding:"required"`
+}
+
+type RootCauseArgs struct {
+	EquipmentTypeID uint   `json:"equipment_type_id" desc:"Equipment type to analyse; give this or workshop_id"`
+	WorkshopID      uint   `json:"workshop_id"`
+	Days            int    `json:"days" binding:"omitempty,min=7,max=730" desc:"Look-back window in days, default 180"`
+	FaultCode       string `json:"fault_code" desc:"Only analyse orders with this fault code (prefix match)"`
+	Keyword         string `json:"keyword" desc:"Only analyse orders whose fault description contains this keyword"`
+}
+
+type ManualSearchArgs struct {
+	Query string `json:"query" binding:"required,min=1"`
+}
+
+type SQLAnalystArgs struct {
+	SQLQuery string `json:"sql_query" binding:"required,min=1" desc:"The standard PostgreSQL SELECT query to execute."`
+}
+
+// =====================================================
+// Answer Feedback
+// =====================================================
+
+type FeedbackRequest struct {
+	TargetType string `json:"target_type" binding:"required,oneof=message artifact"`
+	TargetID   uint   `json:"target_id" binding:"required"`
+	Rating     string `json:"rating" binding:"required,oneof=up down"`
+	Correction string `json:"correction"`
+}
+
+type FeedbackReviewRequest struct {
+	Status          string `json:"status" binding:"required,oneof=resolved dismissed"`
+	Note            string `json:"note"`
+	CreateKnowledge bool   `json:"create_knowledge"` // 将纠正内容沉淀为知识草稿
+}
+
+type FeedbackResponse struct {
+	ID           uint      `json:"id"`
+	TargetType   string    `json:"target_type"`
+	TargetID     uint      `json:"target_id"`
+	Rating       string    `json:"rating"`
+	Correction   string    `json:"correction,omitempty"`
+	Scenario     string    `json:"scenario"`
+	SkillID      string    `json:"skill_id,omitempty"`
+	Model        string    `json:"model"`
+	ReviewStatus string    `json:"review_status,omitempty"`
+	ReviewNote   string    `json:"review_note,omitempty"`
+	Excerpt      string    `json:"excerpt,omitempty"`
+	UserID       uint      `json:"user_id"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// FeedbackQualityItem aggregates ratings for one scenario, skill or model
+type FeedbackQualityItem struct {
+	Key          string  `json:"key"`
+	Label        string  `json:"label,omitempty"`
+	Up           int     `json:"up"`
+	Down         int     `json:"down"`
+	Corrections  int     `json:"corrections"`
+	Satisfaction float64 `json:"satisfaction"`
+}
+
+type FeedbackAnalyticsResponse struct {
+	Days           int                   `json:"days"`
+	Total          int                   `json:"total"`
+	Satisfaction   float64               `json:"satisfaction"`
+	PendingReviews int                   `json:"pending_reviews"`
+	ByScenario     []FeedbackQualityItem `json:"by_scenario"`
+	BySkill        []FeedbackQualityItem `json:"by_skill"`
+	ByModel        []FeedbackQualityItem `json:"by_model"`
+}
+
+// =====================================================
+// Equipment Notes
+// =====================================================
+
+type EquipmentNoteRequest struct {
+	Category  string `json:"category" binding:"omitempty,oneof=modification known_issue operation other"`
+	Content   string `json:"content" binding:"required,min=2,max=1000"`
+	ExpiresAt string `json:"expires_at" binding:"omitempty,datetime=2006-01-02"` // 为空表示长期有效
+}
+
+type EquipmentNoteReviewRequest struct {
+	Status    string `json:"status" binding:"required,oneof=approved rejected"`
+	Note      string `json:"note"`
+	Content   string `json:"content" binding:"omitempty,min=2,max=1000"`          // 审核时可修订内容
+	ExpiresAt string `json:"expires_at" binding:"omitempty,datetime=2006-01-02"` // 审核时可调整有效期
+}
+
+type EquipmentNoteQuery struct {
+	EquipmentID    uint   `form:"equipment_id"`
+	Status         string `form:"status" binding:"omitempty,oneof=pending approved rejected"`
+	IncludeExpired bool   `form:"include_expired"`
+}
+
+type EquipmentNoteResponse struct {
+	ID          uint       `json:"id"`
+	EquipmentID uint       `json:"equipment_id"`
+	Category    string     `json:"category"`
+	Content     string     `json:"content"`
+	SourceType  string     `json:"source_type"`
+	SourceID    uint       `json:"source_id,omitempty"`
+	Provenance  string     `json:"provenance"`
+	CreatedBy   uint       `json:"created_by,omitempty"`
+	Status      string     `json:"status"`
+	ReviewedBy  *uint      `json:"reviewed_by,omitempty"`
+	ReviewedAt  *time.Time `json:"reviewed_at,omitempty"`
+	ReviewNote  string     `json:"review_note,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	Expired     bool       `json:"expired"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// =====================================================
+// Push Subscriptions & Webhook Deliveries
+// =====================================================
+
+type UpdateSubscriptionRequest struct {
+	Enabled    *bool           `json:"enabled"`
+	Scope      json.RawMessage `json:"scope"`
+	WebhookURL *string         `json:"webhook_url" binding:"omitempty,max=500"`
+}
+
+type DeliveryQuery struct {
+	Status string `form:"status" binding:"omitempty,oneof=pending sending success failed dead"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=200"`
+}
+
+type WebhookDeliveryResponse struct {
+	ID             uint            `json:"id"`
+	SubscriptionID uint            `json:"subscription_id"`
+	EventType      string          `json:"event_type"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseCode   int             `json:"response_code,omitempty"`
+	LatencyMs      int64           `json:"latency_ms"`
+	Error          string          `json:"error,omitempty"`
+	Payload        json.RawMessage `json:"payload"`
+	RedeliveryOf   *uint           `json:"redelivery_of,omitempty"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// PushSchemaResponse 某类推送负载的版本化 JSON Schema
+type PushSchemaResponse struct {
+	Event       string                 `json:"event"`
+	Version     string                 `json:"version"`
+	Description string                 `json:"description"`
+	Schema      map[string]interface{} `json:"schema"`
+}
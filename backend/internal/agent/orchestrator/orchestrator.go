@@ -0,0 +1,267 @@
+// Package orchestrator runs a planner-produced DAG of analysis sub-tasks with
+// bounded concurrency. Binding sub-tasks to analyzers or tools is left to the
+// caller through an Executor.
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ems/backend/internal/agent/dto"
+)
+
+// 子任务绑定类型
+const (
+	KindAnalyzer = "analyzer"
+	KindTool     = "tool"
+)
+
+// 子任务执行状态
+const (
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+	StatusSkipped   = "skipped" // 依赖任务失败或编排被取消
+)
+
+// MaxTasks 单个计划允许的最大子任务数
+const MaxTasks = 12
+
+// Task 计划中的一个子任务
+type Task struct {
+	ID        string                 `json:"id"`
+	Title     string                 `json:"title"`
+	Kind      string                 `json:"kind"`   // analyzer, tool
+	Target    string                 `json:"target"` // 分析器或工具名称
+	Args      map[string]interface{} `json:"args,omitempty"`
+	DependsOn []string               `json:"depends_on,omitempty"`
+}
+
+// Plan 规划器产出的任务 DAG
+type Plan struct {
+	Goal  string `json:"goal"`
+	Tasks []Task `json:"tasks"`
+}
+
+// Output 执行器返回的子任务结果
+type Output struct {
+	Summary  string
+	Data     interface{}
+	Evidence []dto.EvidenceItem
+}
+
+// Result 子任务执行记录
+type Result struct {
+	Task       Task
+	Status     string
+	Output     Output
+	Error      string
+	DurationMs int64
+}
+
+// Executor 执行单个子任务；deps 为已完成的直接依赖结果
+type Executor func(ctx context.Context, task Task, deps map[string]Output) (Output, error)
+
+var jsonBlockRe = regexp.MustCompile("(?s)```(?:json)?\\s*(\\{.*\\})\\s*```")
+
+// ParsePlan 从规划器回复中提取 JSON 计划（兼容 ```json 代码块与前后说明文字）
+func ParsePlan(text string) (*Plan, error) {
+	raw := strings.TrimSpace(text)
+	if m := jsonBlockRe.FindStringSubmatch(raw); m != nil {
+		raw = m[1]
+	} else if start, end := strings.Index(raw, "{"), strings.LastIndex(raw, "}"); start >= 0 && end > start {
+		raw = raw[start : end+1]
+	}
+	var plan Plan
+	if err := json.Unmarshal([]byte(raw), &plan); err != nil {
+		return nil, fmt.Errorf("invalid plan json: %w", err)
+	}
+	return &plan, nil
+}
+
+// Validate 校验计划：ID 唯一、绑定存在、依赖存在且无环
+func Validate(plan *Plan, known func(kind, target string) bool) error {
+	if len(plan.Tasks) == 0 {
+		return fmt.Errorf("plan has no tasks")
+	}
+	if len(plan.Tasks) > MaxTasks {
+		return fmt.Errorf("plan has %d tasks, at most %d allowed", len(plan.Tasks), MaxTasks)
+	}
+	ids := make(map[string]bool, len(plan.Tasks))
+	for _, t := range plan.Tasks {
+		if t.ID == "" {
+			return fmt.Errorf("task without id")
+		}
+		if ids[t.ID] {
+			return fmt.Errorf("duplicate task id %q", t.ID)
+		}
+		ids[t.ID] = true
+		if known != nil && !known(t.Kind, t.Target) {
+			return fmt.Errorf("task %q is bound to unknown %s %q", t.ID, t.Kind, t.Target)
+		}
+	}
+	for _, t := range plan.Tasks {
+		for _, dep := range t.DependsOn {
+			if !ids[dep] {
+				return fmt.Errorf("task %q depends on unknown task %q", t.ID, dep)
+			}
+		}
+	}
+	if _, err := topoOrder(plan.Tasks); err != nil {
+		return err
+	}
+	return nil
+}
+
+// topoOrder 返回拓扑序，存在环时报错
+func topoOrder(tasks []Task) ([]string, error) {
+	indegree := make(map[string]int, len(tasks))
+	children := make(map[string][]string, len(tasks))
+	for _, t := range tasks {
+		indegree[t.ID] += 0
+		for _, dep := range t.DependsOn {
+			indegree[t.ID]++
+			children[dep] = append(children[dep], t.ID)
+		}
+	}
+	var queue, order []string
+	for _, t := range tasks {
+		if indegree[t.ID] == 0 {
+			queue = append(queue, t.ID)
+		}
+	}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		order = append(order, id)
+		for _, c := range children[id] {
+			if indegree[c]--; indegree[c] == 0 {
+				queue = append(queue, c)
+			}
+		}
+	}
+	if len(order) != len(tasks) {
+		return nil, fmt.Errorf("plan contains a dependency cycle")
+	}
+	return order, nil
+}
+
+// Run 按依赖关系并行执行计划，同时运行的子任务不超过 concurrency 个。
+// 结果按计划中的任务顺序返回；依赖失败的任务标记为 skipped。
+// 调度与结果汇总都在调用方 goroutine 中完成，工作 goroutine 只持有各自的任务与依赖输出。
+func Run(ctx context.Context, plan *Plan, exec Executor, concurrency int) []Result {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	index := make(map[string]int, len(plan.Tasks))
+	pending := make(map[string]int, len(plan.Tasks))
+	children := make(map[string][]string, len(plan.Tasks))
+	for i, t := range plan.Tasks {
+		index[t.ID] = i
+		pending[t.ID] = len(t.DependsOn)
+		for _, dep := range t.DependsOn {
+			children[dep] = append(children[dep], t.ID)
+		}
+	}
+
+	results := make([]Result, len(plan.Tasks))
+	for i, t := range plan.Tasks {
+		results[i] = Result{Task: t}
+	}
+
+	type done struct {
+		id  string
+		res Result
+	}
+	finished := make(chan done)
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	start := func(id string) {
+		task := plan.Tasks[index[id]]
+		deps := make(map[string]Output, len(task.DependsOn))
+		for _, dep := range task.DependsOn {
+			deps[dep] = results[index[dep]].Output
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := Result{Task: task}
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				res.Status, res.Error = StatusSkipped, ctx.Err().Error()
+				finished <- done{id, res}
+				return
+			}
+			began := time.Now()
+			out, err := exec(ctx, task, deps)
+			<-sem
+			res.DurationMs = time.Since(began).Milliseconds()
+			if err != nil {
+				res.Status, res.Error = StatusFailed, err.Error()
+			} else {
+				res.Status, res.Output = StatusCompleted, out
+			}
+			finished <- done{id, res}
+		}()
+	}
+
+	running := 0
+	for _, t := range plan.Tasks {
+		if pending[t.ID] == 0 {
+			start(t.ID)
+			running++
+		}
+	}
+
+	// skip 将依赖失败任务的全部下游标记为跳过
+	var skip func(id, reason string)
+	skip = func(id, reason string) {
+		for _, c := range children[id] {
+			r := &results[index[c]]
+			if r.Status != "" {
+				continue
+			}
+			r.Status, r.Error = StatusSkipped, reason
+			skip(c, reason)
+		}
+	}
+
+	for running > 0 {
+		d := <-finished
+		running--
+		results[index[d.id]] = d.res
+		if d.res.Status != StatusCompleted {
+			skip(d.id, fmt.Sprintf("dependency %s %s", d.id, d.res.Status))
+			continue
+		}
+		for _, c := range children[d.id] {
+			if pending[c]--; pending[c] == 0 && results[index[c]].Status == "" {
+				start(c)
+				running++
+			}
+		}
+	}
+	wg.Wait()
+	return results
+}
+
+// CombinedEvidence 按任务顺序合并已完成子任务的证据，返回合并结果及每个任务证据的起始编号（从 1 开始）
+func CombinedEvidence(results []Result) ([]dto.EvidenceItem, map[string]int) {
+	evidence := []dto.EvidenceItem{}
+	offsets := make(map[string]int, len(results))
+	for _, r := range results {
+		if r.Status != StatusCompleted || len(r.Output.Evidence) == 0 {
+			continue
+		}
+		offsets[r.Task.ID] = len(evidence) + 1
+		evidence = append(evidence, r.Output.Evidence...)
+	}
+	return evidence, offsets
+}
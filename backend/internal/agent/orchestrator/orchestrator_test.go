@@ -0,0 +1,111 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ems/backend/internal/agent/dto"
+)
+
+func TestParsePlan(t *testing.T) {
+	text := "计划如下：\n```json\n{\"goal\":\"下季度保养预算\",\"tasks\":[{\"id\":\"t1\",\"kind\":\"analyzer\",\"target\":\"maintenance_recommend\"}]}\n```"
+	plan, err := ParsePlan(text)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if plan.Goal != "下季度保养预算" || len(plan.Tasks) != 1 || plan.Tasks[0].Target != "maintenance_recommend" {
+		t.Errorf("Unexpected plan: %+v", plan)
+	}
+	if _, err := ParsePlan("无法规划"); err == nil {
+		t.Error("Expected error for text without JSON")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	known := func(kind, target string) bool { return kind == KindAnalyzer && target == "repair_audit" }
+	cases := map[string]*Plan{
+		"empty":       {},
+		"unknown":     {Tasks: []Task{{ID: "t1", Kind: KindTool, Target: "drop_tables"}}},
+		"duplicate":   {Tasks: []Task{{ID: "t1", Kind: KindAnalyzer, Target: "repair_audit"}, {ID: "t1", Kind: KindAnalyzer, Target: "repair_audit"}}},
+		"missing dep": {Tasks: []Task{{ID: "t1", Kind: KindAnalyzer, Target: "repair_audit", DependsOn: []string{"t9"}}}},
+		"cycle": {Tasks: []Task{
+			{ID: "t1", Kind: KindAnalyzer, Target: "repair_audit", DependsOn: []string{"t2"}},
+			{ID: "t2", Kind: KindAnalyzer, Target: "repair_audit", DependsOn: []string{"t1"}},
+		}},
+	}
+	for name, plan := range cases {
+		if err := Validate(plan, known); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+	ok := &Plan{Tasks: []Task{
+		{ID: "t1", Kind: KindAnalyzer, Target: "repair_audit"},
+		{ID: "t2", Kind: KindAnalyzer, Target: "repair_audit", DependsOn: []string{"t1"}},
+	}}
+	if err := Validate(ok, known); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestRunRespectsDependenciesAndConcurrency(t *testing.T) {
+	plan := &Plan{Tasks: []Task{
+		{ID: "a"}, {ID: "b"}, {ID: "c"}, {ID: "d"},
+		{ID: "merge", DependsOn: []string{"a", "b", "c", "d"}},
+	}}
+	var running, peak int32
+	exec := func(ctx context.Context, task Task, deps map[string]Output) (Output, error) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		if task.ID == "merge" && len(deps) != 4 {
+			return Output{}, fmt.Errorf("expected 4 dependency outputs, got %d", len(deps))
+		}
+		return Output{Summary: task.ID, Evidence: []dto.EvidenceItem{{Title: task.ID}}}, nil
+	}
+
+	results := Run(context.Background(), plan, exec, 2)
+	for _, r := range results {
+		if r.Status != StatusCompleted {
+			t.Errorf("task %s: expected completed, got %s (%s)", r.Task.ID, r.Status, r.Error)
+		}
+	}
+	if peak > 2 {
+		t.Errorf("Expected at most 2 concurrent tasks, got %d", peak)
+	}
+
+	evidence, offsets := CombinedEvidence(results)
+	if len(evidence) != 5 || offsets["a"] != 1 || offsets["merge"] != 5 {
+		t.Errorf("Unexpected combined evidence: %d items, offsets %v", len(evidence), offsets)
+	}
+}
+
+func TestRunSkipsDownstreamOfFailures(t *testing.T) {
+	plan := &Plan{Tasks: []Task{
+		{ID: "a"},
+		{ID: "b", DependsOn: []string{"a"}},
+		{ID: "c", DependsOn: []string{"b"}},
+		{ID: "d"},
+	}}
+	exec := func(ctx context.Context, task Task, deps map[string]Output) (Output, error) {
+		if task.ID == "a" {
+			return Output{}, fmt.Errorf("boom")
+		}
+		return Output{Summary: task.ID}, nil
+	}
+	results := Run(context.Background(), plan, exec, 4)
+	want := []string{StatusFailed, StatusSkipped, StatusSkipped, StatusCompleted}
+	for i, r := range results {
+		if r.Status != want[i] {
+			t.Errorf("task %s: expected %s, got %s", r.Task.ID, want[i], r.Status)
+		}
+	}
+}
@@ -2,8 +2,16 @@ package prompt
 
 import (
 	"fmt"
+	"strings"
+
+	"github.com/ems/backend/internal/agent/dto"
 )
 
+// CitationRules 要求模型为结论标注证据编号，生成后由 citation 包校验
+const CitationRules = `### 引用规范
+- 每条结论和每个数字后必须用证据编号标注来源，如 [E1] 或 [E1, E3]，编号只能取自“参考证据”列表。
+- 数字必须与证据原文一致，不得自行估算或编造；证据无法支撑的判断请明确写“推测”。`
+
 type PromptTool struct {
 }
 
@@ -11,6 +19,30 @@ func NewPromptTool() *PromptTool {
 	return &PromptTool{}
 }
 
+// FormatEvidence 将证据渲染为带编号的列表，编号从 E1 开始与证据顺序一致
+func (t *PromptTool) FormatEvidence(evidence interface{}) string {
+	items, ok := evidence.([]dto.EvidenceItem)
+	if !ok {
+		return fmt.Sprintf("%v", evidence)
+	}
+	if len(items) == 0 {
+		return "（无）"
+	}
+	var b strings.Builder
+	for i, ev := range items {
+		fmt.Fprintf(&b, "[E%d] ", i+1)
+		if ev.Title != "" {
+			b.WriteString(ev.Title + "：")
+		}
+		if ev.SourceTable != "" {
+			fmt.Fprintf(&b, "(%s#%d) ", ev.SourceTable, ev.SourceID)
+		}
+		b.WriteString(ev.Excerpt)
+		b.WriteString("\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
 func (t *PromptTool) BuildMaintenanceRecommendPrompt(data interface{}, evidence interface{}) string {
 	return fmt.Sprintf(`你是一个专业的工业设备管理助手。请根据以下设备当前的保养计划和相关的参考证据（手册或最佳实践），为工程师生成一份中文保养优化建议。
 
@@ -24,7 +56,9 @@ func (t *PromptTool) BuildMaintenanceRecommendPrompt(data interface{}, evidence
 1. 语言：中文
 2. 风格：专业、严谨、客观
 3. 重点：评估当前周期的合理性，是否需要增加或删除维护项，并给出理由。
-4. 格式：简短的摘要（50-100字），随后是具体的建议项。`, data, evidence)
+4. 格式：简短的摘要（50-100字），随后是具体的建议项。
+
+%s`, data, t.FormatEvidence(evidence), CitationRules)
 }
 
 func (t *PromptTool) BuildRepairAuditPrompt(data interface{}, evidence interface{}) string {
@@ -39,8 +73,29 @@ func (t *PromptTool) BuildRepairAuditPrompt(data interface{}, evidence interface
 ### 输出要求
 1. 语言：中文
 2. 重点：指出风险点（如重复故障、费用异常），解释为什么这被认为是异常，并给出核查建议。
-3. 风格：批判性思维但保持专业。`, data, evidence)
+3. 风格：批判性思维但保持专业。
+
+%s`, data, t.FormatEvidence(evidence), CitationRules)
 }
+
+func (t *PromptTool) BuildRootCausePrompt(data interface{}, evidence interface{}) string {
+	return fmt.Sprintf(`你是一个设备可靠性工程师。请根据以下同型设备的故障聚类与按置信度排序的根因假设，生成一份中文根因分析结论。
+
+### 故障簇与根因假设
+%v
+
+### 参考证据
+%v
+
+### 输出要求
+1. 语言：中文
+2. 重点：按可能性从高到低说明各根因假设及其支撑数据，区分相关性与已证实的因果关系。
+3. 对每条假设给出可执行的验证步骤（如隔离批次、复查保养作业、现场确认）。
+4. 风格：严谨、客观，证据不足时明确说明。
+
+%s`, data, t.FormatEvidence(evidence), CitationRules)
+}
+
 func (t *PromptTool) BuildMaintenanceAuditPrompt(data interface{}, evidence interface{}) string {
 	return fmt.Sprintf(`你是一个专业的设备保养审计专家。请根据以下保养任务的执行异常分析和相关的参考证据，生成一份中文审计结论。
 
@@ -53,10 +108,12 @@ func (t *PromptTool) BuildMaintenanceAuditPrompt(data interface{}, evidence inte
 ### 输出要求
 1. 语言：中文
 2. 重点：评估保养执行的合规性，重点关注延期和漏检风险。
-3. 风格：严谨、客观，提供改进建议。`, data, evidence)
+3. 风格：严谨、客观，提供改进建议。
+
+%s`, data, t.FormatEvidence(evidence), CitationRules)
 }
 
-func (t *PromptTool) BuildGenericAnalysisPrompt(question string, context interface{}) string {
+func (t *PromptTool) BuildGenericAnalysisPrompt(question string, context interface{}, evidence []dto.EvidenceItem) string {
 	return fmt.Sprintf(`你是一个顶级的工业资产战略专家。请针对用户提出的问题，结合系统提供的多维业务上下文进行深度分析。
 
 ### 用户问题
@@ -65,10 +122,54 @@ func (t *PromptTool) BuildGenericAnalysisPrompt(question string, context interfa
 ### 系统上下文 (Context)
 %v
 
+### 参考证据
+%s
+
 ### 输出要求
 1. 语言：中文
 2. 逻辑：结论先行，随后引用上下文中的证据。
-3. 深度：跨维度分析（如结合维修成本与保养频率）。`, question, context)
+3. 深度：跨维度分析（如结合维修成本与保养频率）。
+
+%s`, question, context, t.FormatEvidence(evidence), CitationRules)
+}
+
+// BuildPlannerPrompt 要求规划器将复杂问题拆解为绑定分析器或工具的子任务 DAG
+func (t *PromptTool) BuildPlannerPrompt(question string, bindings string, maxTasks int) string {
+	return fmt.Sprintf(`你是设备管理分析的任务规划器。请把用户的复杂问题拆解为若干可独立执行的子任务，每个子任务必须绑定一个下列分析器或工具。
+
+### 用户问题
+%s
+
+### 可用的分析器与工具
+%s
+
+### 规划要求
+1. 子任务不超过 %d 个；互不依赖的子任务不要声明依赖，以便并行执行。
+2. depends_on 只能引用前面已定义的子任务 ID，不得形成环。
+3. 参数值可以用 "${任务ID.字段}" 引用依赖任务结果中的顶层字段。
+4. 只输出 JSON，不要输出其他文字，格式如下：
+{"goal": "一句话目标", "tasks": [{"id": "t1", "title": "子任务说明", "kind": "analyzer 或 tool", "target": "名称", "args": {}, "depends_on": []}]}`, question, bindings, maxTasks)
+}
+
+// BuildSynthesisPrompt 汇总各子任务结果，生成一份带证据引用的综合结论
+func (t *PromptTool) BuildSynthesisPrompt(goal string, results string, evidence []dto.EvidenceItem) string {
+	return fmt.Sprintf(`你是一个顶级的工业资产战略专家。以下是为完成分析目标而并行执行的各子任务结果，请将其整合为一份统一的中文分析报告。
+
+### 分析目标
+%s
+
+### 子任务结果
+%s
+
+### 参考证据
+%s
+
+### 输出要求
+1. 结论先行，随后按主题（而非按子任务）组织论据，指出各结果之间的关联与矛盾。
+2. 失败或跳过的子任务请说明其对结论的影响。
+3. 最后给出可执行的建议与优先级。
+
+%s`, goal, results, t.FormatEvidence(evidence), CitationRules)
 }
 
 func (t *PromptTool) BuildKnowledgeExtractionPrompt(history interface{}) string {
@@ -98,6 +199,28 @@ func (t *PromptTool) BuildKnowledgeExtractionPrompt(history interface{}) string
 3. 语言必须是中文。`, history)
 }
 
+func (t *PromptTool) BuildEquipmentNoteExtractionPrompt(equipmentCode string, history interface{}) string {
+	return fmt.Sprintf(`你是一个资深的设备管理专家。下面是一段关于设备 %s 的对话记录，请判断其中是否提到了这台设备特有、且在以后的对话中仍然有用的事实，例如：改造或加装、使用了非原厂/代用备件、已知的遗留缺陷、特殊的操作或参数设置。
+
+### 对话记录
+%v
+
+### 提取任务
+将每条事实提取为 JSON 数组中的一项（如果没有值得长期记住的事实，请只返回 []）：
+[
+  {
+    "category": "modification 或 known_issue 或 operation 或 other",
+    "content": "一句话陈述事实，包含时间、部件等关键信息",
+    "expires_in_days": 事实预计保持有效的天数，无法判断时填 0
+  }
+]
+
+### 要求
+1. 只返回纯 JSON 字符串，不要包含任何 Markdown 代码块包裹（即不要有 ` + "```" + `json 等）。
+2. 只提取对话中明确陈述的事实，不要推测；通用知识、临时性的故障现象不要提取。
+3. 最多 3 条，语言必须是中文。`, equipmentCode, history)
+}
+
 func (t *PromptTool) BuildSymptomAnalysisPrompt(findings interface{}) string {
 	return fmt.Sprintf(`你是一个资深的设备预测性维护专家。请分析以下识别出的设备“亚健康”征兆，并生成一份具有前瞻性的预警报告。
 
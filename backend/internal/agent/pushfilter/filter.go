@@ -0,0 +1,278 @@
+// Package pushfilter implements the scope filter language of push
+// subscriptions. A subscription's scope is a JSON object; every present key
+// narrows the events it receives and absent keys do not constrain. Filters are
+// validated once when a subscription is saved and compiled into set lookups so
+// that evaluating an event against many subscriptions stays cheap.
+package pushfilter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var ErrInvalidFilter = errors.New("invalid subscription scope")
+
+// Severities 从低到高
+var Severities = []string{"low", "medium", "high", "critical"}
+
+// Spec 订阅 scope 的 JSON 结构
+type Spec struct {
+	FactoryID        *uint    `json:"factory_id,omitempty" desc:"单个工厂，兼容旧格式"`
+	FactoryIDs       []uint   `json:"factory_ids,omitempty"`
+	WorkshopIDs      []uint   `json:"workshop_ids,omitempty"`
+	EquipmentTypeIDs []uint   `json:"equipment_type_ids,omitempty"`
+	EquipmentIDs     []uint   `json:"equipment_ids,omitempty"`
+	MinSeverity      string   `json:"min_severity,omitempty" binding:"omitempty,oneof=low medium high critical"`
+	Priorities       []int    `json:"priorities,omitempty" desc:"维修优先级 1=高 2=中 3=低"`
+	MaxRULDays       *int     `json:"max_rul_days,omitempty" desc:"剩余寿命不超过该天数时推送"`
+	MaxStockRatio    *float64 `json:"max_stock_ratio,omitempty" desc:"库存/安全库存不超过该比例时推送"`
+	BusinessHours    *Window  `json:"business_hours,omitempty"`
+}
+
+// Window 推送时间窗口，End 早于 Start 表示跨夜
+type Window struct {
+	Days     []int  `json:"days,omitempty" desc:"ISO 星期 1=周一 … 7=周日，为空表示每天"`
+	Start    string `json:"start" binding:"required" desc:"HH:MM"`
+	End      string `json:"end" binding:"required" desc:"HH:MM"`
+	Timezone string `json:"timezone,omitempty" desc:"IANA 时区，默认 Asia/Shanghai"`
+}
+
+// Facts 被评估事件的属性；零值或 nil 表示事件不具备该属性
+type Facts struct {
+	FactoryID       *uint
+	WorkshopID      uint
+	EquipmentTypeID uint
+	EquipmentID     uint
+	Severity        string
+	Priority        int
+	RULDays         *int
+	StockRatio      *float64
+	At              time.Time
+}
+
+// Filter 编译后的 scope；nil 或空 Filter 匹配全部事件
+type Filter struct {
+	factories      set
+	workshops      set
+	equipmentTypes set
+	equipment      set
+	minSeverity    int
+	priorities     map[int]bool
+	maxRULDays     *int
+	maxStockRatio  *float64
+	window         *window
+}
+
+type set map[uint]bool
+
+func newSet(ids []uint) set {
+	if len(ids) == 0 {
+		return nil
+	}
+	s := make(set, len(ids))
+	for _, id := range ids {
+		s[id] = true
+	}
+	return s
+}
+
+type window struct {
+	days       map[time.Weekday]bool
+	start, end int // 当天分钟数
+	loc        *time.Location
+}
+
+// Parse 校验并编译 scope JSON；空串、null 与 {} 得到匹配全部事件的过滤器
+func Parse(raw string) (*Filter, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || raw == "null" || raw == "{}" {
+		return &Filter{}, nil
+	}
+	var spec Spec
+	dec := json.NewDecoder(bytes.NewReader([]byte(raw)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFilter, err)
+	}
+	return Compile(spec)
+}
+
+// Compile 校验 spec 并生成过滤器
+func Compile(spec Spec) (*Filter, error) {
+	f := &Filter{
+		workshops:      newSet(spec.WorkshopIDs),
+		equipmentTypes: newSet(spec.EquipmentTypeIDs),
+		equipment:      newSet(spec.EquipmentIDs),
+		maxRULDays:     spec.MaxRULDays,
+		maxStockRatio:  spec.MaxStockRatio,
+	}
+	factories := spec.FactoryIDs
+	if spec.FactoryID != nil {
+		factories = append(factories, *spec.FactoryID)
+	}
+	f.factories = newSet(factories)
+
+	if spec.MinSeverity != "" {
+		if f.minSeverity = severityRank(spec.MinSeverity); f.minSeverity == 0 {
+			return nil, fmt.Errorf("%w: min_severity must be one of %s", ErrInvalidFilter, strings.Join(Severities, ", "))
+		}
+	}
+	if len(spec.Priorities) > 0 {
+		f.priorities = map[int]bool{}
+		for _, p := range spec.Priorities {
+			if p < 1 || p > 3 {
+				return nil, fmt.Errorf("%w: priorities must be between 1 and 3", ErrInvalidFilter)
+			}
+			f.priorities[p] = true
+		}
+	}
+	if spec.MaxRULDays != nil && *spec.MaxRULDays < 0 {
+		return nil, fmt.Errorf("%w: max_rul_days must not be negative", ErrInvalidFilter)
+	}
+	if spec.MaxStockRatio != nil && *spec.MaxStockRatio < 0 {
+		return nil, fmt.Errorf("%w: max_stock_ratio must not be negative", ErrInvalidFilter)
+	}
+	if spec.BusinessHours != nil {
+		w, err := compileWindow(*spec.BusinessHours)
+		if err != nil {
+			return nil, err
+		}
+		f.window = w
+	}
+	return f, nil
+}
+
+func compileWindow(spec Window) (*window, error) {
+	start, err := parseClock(spec.Start)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseClock(spec.End)
+	if err != nil {
+		return nil, err
+	}
+	if start == end {
+		return nil, fmt.Errorf("%w: business_hours start and end must differ", ErrInvalidFilter)
+	}
+	tz := spec.Timezone
+	if tz == "" {
+		tz = "Asia/Shanghai"
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unknown timezone %q", ErrInvalidFilter, tz)
+	}
+	w := &window{start: start, end: end, loc: loc}
+	if len(spec.Days) > 0 {
+		w.days = map[time.Weekday]bool{}
+		for _, d := range spec.Days {
+			if d < 1 || d > 7 {
+				return nil, fmt.Errorf("%w: business_hours days must be 1 (Monday) to 7 (Sunday)", ErrInvalidFilter)
+			}
+			w.days[time.Weekday(d%7)] = true
+		}
+	}
+	return w, nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("%w: business_hours time %q must be HH:MM", ErrInvalidFilter, s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func severityRank(s string) int {
+	for i, v := range Severities {
+		if v == s {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Match 评估事件是否满足过滤器；过滤器限定了某个维度而事件不具备该属性时视为不匹配
+func (f *Filter) Match(facts Facts) bool {
+	if f == nil {
+		return true
+	}
+	if f.factories != nil && (facts.FactoryID == nil || !f.factories[*facts.FactoryID]) {
+		return false
+	}
+	if !f.workshops.allows(facts.WorkshopID) || !f.equipmentTypes.allows(facts.EquipmentTypeID) || !f.equipment.allows(facts.EquipmentID) {
+		return false
+	}
+	if f.minSeverity > 0 && severityRank(facts.Severity) < f.minSeverity {
+		return false
+	}
+	if f.priorities != nil && !f.priorities[facts.Priority] {
+		return false
+	}
+	if f.maxRULDays != nil && (facts.RULDays == nil || *facts.RULDays > *f.maxRULDays) {
+		return false
+	}
+	if f.maxStockRatio != nil && (facts.StockRatio == nil || *facts.StockRatio > *f.maxStockRatio) {
+		return false
+	}
+	if f.window != nil && !f.window.contains(facts.At) {
+		return false
+	}
+	return true
+}
+
+// MaxRULDays returns the RUL threshold of the filter, or def when unset
+func (f *Filter) MaxRULDays(def int) int {
+	if f == nil || f.maxRULDays == nil {
+		return def
+	}
+	return *f.maxRULDays
+}
+
+// TimeWindow 编译后的时间窗口，供推送范围以外的场景（如免打扰时段）复用
+type TimeWindow struct {
+	w *window
+}
+
+// CompileWindow 校验并编译时间窗口
+func CompileWindow(spec Window) (*TimeWindow, error) {
+	w, err := compileWindow(spec)
+	if err != nil {
+		return nil, err
+	}
+	return &TimeWindow{w: w}, nil
+}
+
+// Contains 判断时间点是否落在窗口内，跨夜窗口的凌晨部分属于前一天
+func (t *TimeWindow) Contains(at time.Time) bool {
+	return t.w.contains(at)
+}
+
+func (s set) allows(id uint) bool {
+	return s == nil || (id != 0 && s[id])
+}
+
+func (w *window) contains(at time.Time) bool {
+	if at.IsZero() {
+		at = time.Now()
+	}
+	local := at.In(w.loc)
+	minute := local.Hour()*60 + local.Minute()
+	day := local.Weekday()
+	if w.start < w.end {
+		return w.dayAllowed(day) && minute >= w.start && minute < w.end
+	}
+	// 跨夜窗口：凌晨部分属于前一天的窗口
+	if minute >= w.start {
+		return w.dayAllowed(day)
+	}
+	return minute < w.end && w.dayAllowed((day+6)%7)
+}
+
+func (w *window) dayAllowed(d time.Weekday) bool {
+	return w.days == nil || w.days[d]
+}
@@ -0,0 +1,84 @@
+package pushfilter
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseRejectsInvalidScopes(t *testing.T) {
+	for _, raw := range []string{
+		`{"workshop":[1]}`,
+		`{"min_severity":"urgent"}`,
+		`{"priorities":[0]}`,
+		`{"max_rul_days":-1}`,
+		`{"business_hours":{"start":"8:00am","end":"18:00"}}`,
+		`{"business_hours":{"start":"08:00","end":"08:00"}}`,
+		`{"business_hours":{"start":"08:00","end":"18:00","days":[0]}}`,
+		`{"business_hours":{"start":"08:00","end":"18:00","timezone":"Mars/Olympus"}}`,
+		`[1,2]`,
+	} {
+		if _, err := Parse(raw); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("Parse(%s) = %v, want ErrInvalidFilter", raw, err)
+		}
+	}
+	for _, raw := range []string{"", "null", "{}", ` {} `} {
+		f, err := Parse(raw)
+		if err != nil || !f.Match(Facts{}) {
+			t.Errorf("Expected %q to match everything, got %v", raw, err)
+		}
+	}
+}
+
+func TestMatchIsStrictOnConstrainedDimensions(t *testing.T) {
+	f, err := Parse(`{"factory_id":1,"workshop_ids":[3,4],"min_severity":"medium","max_stock_ratio":0.5}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	factory, other := uint(1), uint(2)
+	ratio, high := 0.3, 0.8
+	base := Facts{FactoryID: &factory, WorkshopID: 3, Severity: "high", StockRatio: &ratio}
+	if !f.Match(base) {
+		t.Fatalf("Expected %+v to match", base)
+	}
+	for name, mutate := range map[string]func(*Facts){
+		"other factory":     func(x *Facts) { x.FactoryID = &other },
+		"no factory":        func(x *Facts) { x.FactoryID = nil },
+		"other workshop":    func(x *Facts) { x.WorkshopID = 5 },
+		"unknown workshop":  func(x *Facts) { x.WorkshopID = 0 },
+		"low severity":      func(x *Facts) { x.Severity = "low" },
+		"ratio above limit": func(x *Facts) { x.StockRatio = &high },
+		"no stock ratio":    func(x *Facts) { x.StockRatio = nil },
+	} {
+		facts := base
+		mutate(&facts)
+		if f.Match(facts) {
+			t.Errorf("%s: expected no match", name)
+		}
+	}
+	if got := f.MaxRULDays(6); got != 6 {
+		t.Errorf("MaxRULDays default = %d, want 6", got)
+	}
+}
+
+func TestBusinessHoursAcrossMidnight(t *testing.T) {
+	// 周五 22:00 至次日 06:00（上海时间）
+	f, err := Parse(`{"business_hours":{"days":[5],"start":"22:00","end":"06:00"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loc, _ := time.LoadLocation("Asia/Shanghai")
+	cases := map[string]bool{
+		"2026-01-02 23:30": true,  // 周五夜间
+		"2026-01-03 05:59": true,  // 周六凌晨，属于周五的窗口
+		"2026-01-03 06:00": false, // 窗口结束
+		"2026-01-03 23:00": false, // 周六夜间
+		"2026-01-02 12:00": false, // 周五白天
+	}
+	for at, want := range cases {
+		ts, _ := time.ParseInLocation("2006-01-02 15:04", at, loc)
+		if got := f.Match(Facts{At: ts.UTC()}); got != want {
+			t.Errorf("Match at %s = %v, want %v", at, got, want)
+		}
+	}
+}
@@ -1,9 +1,13 @@
 package repository
 
 import (
+	"fmt"
+	"strings"
 	"time"
+
 	"github.com/ems/backend/internal/model"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type IAgentRepository interface {
@@ -27,7 +31,10 @@ type IAgentRepository interface {
 	// Phase 2: Conversations & Messages
 	CreateConversation(conv *model.AgentConversation) error
 	GetConversationByID(id uint) (*model.AgentConversation, error)
-	ListConversationsByUserID(userID uint, limit int) ([]model.AgentConversation, error)
+	ListConversationsByUserID(userID uint, status string, limit int) ([]model.AgentConversation, error)
+	UpdateConversation(conv *model.AgentConversation) error
+	DeleteConversation(id uint) error
+	SearchMessages(userID uint, terms []string, limit int) ([]model.AgentMessage, error)
 	CreateMessage(msg *model.AgentMessage) error
 	GetMessagesByConversationID(convID uint) ([]model.AgentMessage, error)
 	CreateKnowledge(knowledge *model.AgentKnowledge) error
@@ -51,6 +58,60 @@ type IAgentRepository interface {
 	CreatePushSubscription(sub *model.AgentPushSubscription) error
 	GetPushSubscription(userID uint, pushType string) (*model.AgentPushSubscription, error)
 	ListPushSubscriptions(userID uint) ([]model.AgentPushSubscription, error)
+	GetPushSubscriptionByID(id uint) (*model.AgentPushSubscription, error)
+	DeletePushSubscription(id uint) error
+	ResetPushSubscriptionFailures(id uint) error
+	RecordPushSubscriptionFailure(id uint, disableAfter int, now time.Time) (bool, error)
+
+	// Webhook Deliveries
+	CreatePushLog(log *model.AgentPushLog) error
+	UpdatePushLog(log *model.AgentPushLog) error
+	ClaimDuePushLogs(now time.Time, lease time.Duration, limit int) ([]model.AgentPushLog, error)
+	CreatePushDeadLetter(dl *model.AgentPushDeadLetter) error
+	GetPushLogByID(id uint) (*model.AgentPushLog, error)
+	ListPushLogs(subscriptionID uint, status string, limit int) ([]model.AgentPushLog, error)
+
+	// Feedback
+	GetMessageByID(id uint) (*model.AgentMessage, error)
+	GetKnowledgeByID(id string) (*model.AgentKnowledge, error)
+	UpdateKnowledgeConfidence(id string, confidence float64) error
+	GetUsageBySession(sessionID uint, scenario string) (*model.AgentUsage, error)
+	SaveFeedback(fb *model.AgentFeedback) error
+	GetFeedbackByID(id uint) (*model.AgentFeedback, error)
+	GetUserFeedback(userID uint, targetType string, targetID uint) (*model.AgentFeedback, error)
+	ListFeedback(filter FeedbackFilter) ([]model.AgentFeedback, error)
+
+	// Conversation Shares
+	CreateConversationShare(share *model.AgentConversationShare) error
+	GetConversationShareByToken(token string) (*model.AgentConversationShare, error)
+	ListConversationShares(convID uint) ([]model.AgentConversationShare, error)
+	RevokeConversationShare(id uint) error
+
+	// Equipment Notes
+	SaveEquipmentNote(note *model.AgentEquipmentNote) error
+	GetEquipmentNoteByID(id uint) (*model.AgentEquipmentNote, error)
+	ListEquipmentNotes(filter EquipmentNoteFilter) ([]model.AgentEquipmentNote, error)
+	DeleteEquipmentNote(id uint) error
+}
+
+// FeedbackFilter 评价查询条件，零值字段不参与过滤
+type FeedbackFilter struct {
+	FactoryID    *uint
+	ReviewStatus string
+	SkillID      string
+	Since        *time.Time
+	Limit        int
+}
+
+// EquipmentNoteFilter 设备备注查询条件，零值字段不参与过滤
+type EquipmentNoteFilter struct {
+	EquipmentIDs []uint
+	FactoryID    *uint
+	Status       string
+	SourceType   string
+	SourceIDs    []uint
+	ActiveAt     *time.Time // 排除在该时间点前已过期的备注
+	Limit        int
 }
 
 type DBAgentRepository struct {
@@ -195,12 +256,51 @@ func (r *DBAgentRepository) GetConversationByID(id uint) (*model.AgentConversati
 	return &conv, nil
 }
 
-func (r *DBAgentRepository) ListConversationsByUserID(userID uint, limit int) ([]model.AgentConversation, error) {
+func (r *DBAgentRepository) ListConversationsByUserID(userID uint, status string, limit int) ([]model.AgentConversation, error) {
 	var convs []model.AgentConversation
-	err := r.db.Where("user_id = ?", userID).Order("updated_at DESC").Limit(limit).Find(&convs).Error
+	q := r.db.Where("user_id = ?", userID)
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	err := q.Order("updated_at DESC").Limit(limit).Find(&convs).Error
 	return convs, err
 }
 
+// UpdateConversation 仅更新标题与状态，避免级联保存预加载的消息
+func (r *DBAgentRepository) UpdateConversation(conv *model.AgentConversation) error {
+	return r.db.Model(&model.AgentConversation{}).Where("id = ?", conv.ID).
+		Updates(map[string]interface{}{"title": conv.Title, "status": conv.Status, "updated_at": time.Now()}).Error
+}
+
+func (r *DBAgentRepository) DeleteConversation(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("conversation_id = ?", id).Delete(&model.AgentConversationShare{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("conversation_id = ?", id).Delete(&model.AgentMessage{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.AgentConversation{}, id).Error
+	})
+}
+
+// SearchMessages 在用户的全部对话中检索消息，所有关键词均需命中
+func (r *DBAgentRepository) SearchMessages(userID uint, terms []string, limit int) ([]model.AgentMessage, error) {
+	var msgs []model.AgentMessage
+	q := r.db.Model(&model.AgentMessage{}).
+		Joins("JOIN agent_conversations ON agent_conversations.id = agent_messages.conversation_id").
+		Where("agent_conversations.user_id = ? AND agent_conversations.deleted_at IS NULL", userID)
+	for _, t := range terms {
+		q = q.Where("agent_messages.content ILIKE ?", "%"+escapeLike(t)+"%")
+	}
+	err := q.Order("agent_messages.created_at DESC").Limit(limit).Find(&msgs).Error
+	return msgs, err
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
 func (r *DBAgentRepository) CreateMessage(msg *model.AgentMessage) error {
 	return r.db.Create(msg).Error
 }
@@ -315,7 +415,14 @@ func (r *DBAgentRepository) ApplyDecayToExperiences() error {
 // =====================================================
 
 func (r *DBAgentRepository) CreatePushSubscription(sub *model.AgentPushSubscription) error {
-	return r.db.Save(sub).Error // Save uses upsert logic
+	if err := r.db.Save(sub).Error; err != nil { // Save uses upsert logic
+		return err
+	}
+	if !sub.Enabled {
+		// enabled 带 default:true，新建时零值会被默认值覆盖，需显式写回
+		return r.db.Model(sub).Update("enabled", false).Error
+	}
+	return nil
 }
 
 func (r *DBAgentRepository) GetPushSubscription(userID uint, pushType string) (*model.AgentPushSubscription, error) {
@@ -332,3 +439,245 @@ func (r *DBAgentRepository) ListPushSubscriptions(userID uint) ([]model.AgentPus
 	err := r.db.Where("user_id = ?", userID).Find(&subs).Error
 	return subs, err
 }
+
+func (r *DBAgentRepository) GetPushSubscriptionByID(id uint) (*model.AgentPushSubscription, error) {
+	var sub model.AgentPushSubscription
+	if err := r.db.First(&sub, id).Error; err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+func (r *DBAgentRepository) DeletePushSubscription(id uint) error {
+	return r.db.Delete(&model.AgentPushSubscription{}, id).Error
+}
+
+// ResetPushSubscriptionFailures 投递成功后清零连续失败次数，只更新该列
+func (r *DBAgentRepository) ResetPushSubscriptionFailures(id uint) error {
+	return r.db.Model(&model.AgentPushSubscription{}).
+		Where("id = ? AND consecutive_failures > 0", id).
+		Update("consecutive_failures", 0).Error
+}
+
+// RecordPushSubscriptionFailure 原子累加连续失败次数，达到 disableAfter 时停用订阅；返回本次是否停用。
+// 只更新健康状况相关列，不覆盖投递期间用户对订阅的修改
+func (r *DBAgentRepository) RecordPushSubscriptionFailure(id uint, disableAfter int, now time.Time) (bool, error) {
+	err := r.db.Model(&model.AgentPushSubscription{}).Where("id = ?", id).
+		Update("consecutive_failures", gorm.Expr("consecutive_failures + 1")).Error
+	if err != nil || disableAfter <= 0 {
+		return false, err
+	}
+	res := r.db.Model(&model.AgentPushSubscription{}).
+		Where("id = ? AND enabled = ? AND consecutive_failures >= ?", id, true, disableAfter).
+		Updates(map[string]interface{}{
+			"enabled":         false,
+			"disabled_at":     now,
+			"disabled_reason": fmt.Sprintf("%d consecutive delivery failures", disableAfter),
+		})
+	return res.RowsAffected > 0, res.Error
+}
+
+// =====================================================
+// Webhook Delivery Repositories
+// =====================================================
+
+func (r *DBAgentRepository) CreatePushLog(log *model.AgentPushLog) error {
+	return r.db.Create(log).Error
+}
+
+func (r *DBAgentRepository) UpdatePushLog(log *model.AgentPushLog) error {
+	return r.db.Save(log).Error
+}
+
+// ClaimDuePushLogs 锁定并领取到期的待投递记录，领取后 lease 时间内其他实例不会重复领取
+func (r *DBAgentRepository) ClaimDuePushLogs(now time.Time, lease time.Duration, limit int) ([]model.AgentPushLog, error) {
+	var logs []model.AgentPushLog
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND (next_attempt_at IS NULL OR next_attempt_at <= ?)", "pending", now).
+			Order("id").Limit(limit).Find(&logs).Error
+		if err != nil || len(logs) == 0 {
+			return err
+		}
+		ids := make([]uint, len(logs))
+		for i, l := range logs {
+			ids[i] = l.ID
+		}
+		return tx.Model(&model.AgentPushLog{}).Where("id IN ?", ids).Update("next_attempt_at", now.Add(lease)).Error
+	})
+	return logs, err
+}
+
+func (r *DBAgentRepository) CreatePushDeadLetter(dl *model.AgentPushDeadLetter) error {
+	return r.db.Create(dl).Error
+}
+
+func (r *DBAgentRepository) GetPushLogByID(id uint) (*model.AgentPushLog, error) {
+	var log model.AgentPushLog
+	if err := r.db.First(&log, id).Error; err != nil {
+		return nil, err
+	}
+	return &log, nil
+}
+
+// ListPushLogs 按时间倒序返回订阅的投递记录
+func (r *DBAgentRepository) ListPushLogs(subscriptionID uint, status string, limit int) ([]model.AgentPushLog, error) {
+	var logs []model.AgentPushLog
+	q := r.db.Where("subscription_id = ?", subscriptionID)
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	err := q.Order("id DESC").Limit(limit).Find(&logs).Error
+	return logs, err
+}
+
+// =====================================================
+// Feedback Repositories
+// =====================================================
+
+func (r *DBAgentRepository) GetMessageByID(id uint) (*model.AgentMessage, error) {
+	var msg model.AgentMessage
+	if err := r.db.First(&msg, id).Error; err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+func (r *DBAgentRepository) GetKnowledgeByID(id string) (*model.AgentKnowledge, error) {
+	var k model.AgentKnowledge
+	if err := r.db.Where("id = ?", id).First(&k).Error; err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+func (r *DBAgentRepository) UpdateKnowledgeConfidence(id string, confidence float64) error {
+	return r.db.Model(&model.AgentKnowledge{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"confidence": confidence, "updated_at": time.Now()}).Error
+}
+
+func (r *DBAgentRepository) GetUsageBySession(sessionID uint, scenario string) (*model.AgentUsage, error) {
+	var usage model.AgentUsage
+	err := r.db.Where("session_id = ? AND scenario = ?", sessionID, scenario).Order("created_at DESC").First(&usage).Error
+	if err != nil {
+		return nil, err
+	}
+	return &usage, nil
+}
+
+func (r *DBAgentRepository) SaveFeedback(fb *model.AgentFeedback) error {
+	return r.db.Save(fb).Error
+}
+
+func (r *DBAgentRepository) GetFeedbackByID(id uint) (*model.AgentFeedback, error) {
+	var fb model.AgentFeedback
+	if err := r.db.First(&fb, id).Error; err != nil {
+		return nil, err
+	}
+	return &fb, nil
+}
+
+func (r *DBAgentRepository) GetUserFeedback(userID uint, targetType string, targetID uint) (*model.AgentFeedback, error) {
+	var fb model.AgentFeedback
+	err := r.db.Where("user_id = ? AND target_type = ? AND target_id = ?", userID, targetType, targetID).First(&fb).Error
+	if err != nil {
+		return nil, err
+	}
+	return &fb, nil
+}
+
+func (r *DBAgentRepository) ListFeedback(filter FeedbackFilter) ([]model.AgentFeedback, error) {
+	var results []model.AgentFeedback
+	q := r.db.Model(&model.AgentFeedback{})
+	if filter.FactoryID != nil {
+		q = q.Where("factory_id = ?", *filter.FactoryID)
+	}
+	if filter.ReviewStatus != "" {
+		q = q.Where("review_status = ?", filter.ReviewStatus)
+	}
+	if filter.SkillID != "" {
+		q = q.Where("skill_id = ?", filter.SkillID)
+	}
+	if filter.Since != nil {
+		q = q.Where("created_at >= ?", *filter.Since)
+	}
+	if filter.Limit > 0 {
+		q = q.Limit(filter.Limit)
+	}
+	err := q.Order("created_at DESC").Find(&results).Error
+	return results, err
+}
+
+// =====================================================
+// Conversation Share Repositories
+// =====================================================
+
+func (r *DBAgentRepository) CreateConversationShare(share *model.AgentConversationShare) error {
+	return r.db.Create(share).Error
+}
+
+func (r *DBAgentRepository) GetConversationShareByToken(token string) (*model.AgentConversationShare, error) {
+	var share model.AgentConversationShare
+	if err := r.db.Where("token = ?", token).First(&share).Error; err != nil {
+		return nil, err
+	}
+	return &share, nil
+}
+
+func (r *DBAgentRepository) ListConversationShares(convID uint) ([]model.AgentConversationShare, error) {
+	var shares []model.AgentConversationShare
+	err := r.db.Where("conversation_id = ?", convID).Order("created_at DESC").Find(&shares).Error
+	return shares, err
+}
+
+func (r *DBAgentRepository) RevokeConversationShare(id uint) error {
+	return r.db.Model(&model.AgentConversationShare{}).Where("id = ?", id).Update("revoked_at", time.Now()).Error
+}
+
+// =====================================================
+// Equipment Note Repositories
+// =====================================================
+
+func (r *DBAgentRepository) SaveEquipmentNote(note *model.AgentEquipmentNote) error {
+	return r.db.Save(note).Error
+}
+
+func (r *DBAgentRepository) GetEquipmentNoteByID(id uint) (*model.AgentEquipmentNote, error) {
+	var note model.AgentEquipmentNote
+	if err := r.db.First(&note, id).Error; err != nil {
+		return nil, err
+	}
+	return &note, nil
+}
+
+func (r *DBAgentRepository) ListEquipmentNotes(filter EquipmentNoteFilter) ([]model.AgentEquipmentNote, error) {
+	var results []model.AgentEquipmentNote
+	q := r.db.Model(&model.AgentEquipmentNote{})
+	if len(filter.EquipmentIDs) > 0 {
+		q = q.Where("equipment_id IN ?", filter.EquipmentIDs)
+	}
+	if filter.FactoryID != nil {
+		q = q.Where("factory_id = ?", *filter.FactoryID)
+	}
+	if filter.Status != "" {
+		q = q.Where("status = ?", filter.Status)
+	}
+	if filter.SourceType != "" {
+		q = q.Where("source_type = ?", filter.SourceType)
+	}
+	if len(filter.SourceIDs) > 0 {
+		q = q.Where("source_id IN ?", filter.SourceIDs)
+	}
+	if filter.ActiveAt != nil {
+		q = q.Where("expires_at IS NULL OR expires_at > ?", *filter.ActiveAt)
+	}
+	if filter.Limit > 0 {
+		q = q.Limit(filter.Limit)
+	}
+	err := q.Order("created_at DESC").Find(&results).Error
+	return results, err
+}
+
+func (r *DBAgentRepository) DeleteEquipmentNote(id uint) error {
+	return r.db.Delete(&model.AgentEquipmentNote{}, id).Error
+}
@@ -185,6 +185,7 @@ func (r *MemoryAgentRepository) GetArtifactByID(id uint) (*model.AgentArtifact,
 				links = append(links, *l)
 			}
 		}
+		sort.Slice(links, func(i, j int) bool { return links[i].ID < links[j].ID })
 		a.EvidenceLinks = links
 		return a, nil
 	}
@@ -213,6 +214,9 @@ func (r *MemoryAgentRepository) CreateUsage(usage *model.AgentUsage) error {
 
 func (r *MemoryAgentRepository) CreateConversation(conv *model.AgentConversation) error {
 	conv.ID = r.store.NextID()
+	if conv.Status == "" {
+		conv.Status = "active"
+	}
 	conv.CreatedAt = time.Now()
 	conv.UpdatedAt = time.Now()
 	r.store.AgentConversations[conv.ID] = conv
@@ -237,10 +241,10 @@ func (r *MemoryAgentRepository) GetConversationByID(id uint) (*model.AgentConver
 	return nil, fmt.Errorf("conversation not found")
 }
 
-func (r *MemoryAgentRepository) ListConversationsByUserID(userID uint, limit int) ([]model.AgentConversation, error) {
+func (r *MemoryAgentRepository) ListConversationsByUserID(userID uint, status string, limit int) ([]model.AgentConversation, error) {
 	var results []model.AgentConversation
 	for _, c := range r.store.AgentConversations {
-		if c.UserID == userID {
+		if c.UserID == userID && (status == "" || c.Status == status) {
 			results = append(results, *c)
 		}
 	}
@@ -253,6 +257,63 @@ func (r *MemoryAgentRepository) ListConversationsByUserID(userID uint, limit int
 	return results, nil
 }
 
+func (r *MemoryAgentRepository) UpdateConversation(conv *model.AgentConversation) error {
+	c, ok := r.store.AgentConversations[conv.ID]
+	if !ok {
+		return fmt.Errorf("conversation not found")
+	}
+	c.Title = conv.Title
+	c.Status = conv.Status
+	c.UpdatedAt = time.Now()
+	return nil
+}
+
+func (r *MemoryAgentRepository) DeleteConversation(id uint) error {
+	if _, ok := r.store.AgentConversations[id]; !ok {
+		return fmt.Errorf("conversation not found")
+	}
+	for mid, m := range r.store.AgentMessages {
+		if m.ConversationID == id {
+			delete(r.store.AgentMessages, mid)
+		}
+	}
+	for sid, sh := range r.store.AgentConversationShares {
+		if sh.ConversationID == id {
+			delete(r.store.AgentConversationShares, sid)
+		}
+	}
+	delete(r.store.AgentConversations, id)
+	return nil
+}
+
+func (r *MemoryAgentRepository) SearchMessages(userID uint, terms []string, limit int) ([]model.AgentMessage, error) {
+	var results []model.AgentMessage
+	for _, m := range r.store.AgentMessages {
+		c, ok := r.store.AgentConversations[m.ConversationID]
+		if !ok || c.UserID != userID {
+			continue
+		}
+		content := strings.ToLower(m.Content)
+		matched := true
+		for _, t := range terms {
+			if !strings.Contains(content, strings.ToLower(t)) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			results = append(results, *m)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].CreatedAt.After(results[j].CreatedAt)
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
 func (r *MemoryAgentRepository) CreateMessage(msg *model.AgentMessage) error {
 	msg.ID = r.store.NextID()
 	msg.CreatedAt = time.Now()
@@ -434,3 +495,308 @@ func (r *MemoryAgentRepository) ListPushSubscriptions(userID uint) ([]model.Agen
 	}
 	return results, nil
 }
+
+func (r *MemoryAgentRepository) GetPushSubscriptionByID(id uint) (*model.AgentPushSubscription, error) {
+	if s, ok := r.store.AgentPushSubscriptions[id]; ok {
+		return s, nil
+	}
+	return nil, fmt.Errorf("subscription not found")
+}
+
+func (r *MemoryAgentRepository) DeletePushSubscription(id uint) error {
+	delete(r.store.AgentPushSubscriptions, id)
+	return nil
+}
+
+func (r *MemoryAgentRepository) ResetPushSubscriptionFailures(id uint) error {
+	if s, ok := r.store.AgentPushSubscriptions[id]; ok {
+		s.ConsecutiveFailures = 0
+	}
+	return nil
+}
+
+func (r *MemoryAgentRepository) RecordPushSubscriptionFailure(id uint, disableAfter int, now time.Time) (bool, error) {
+	s, ok := r.store.AgentPushSubscriptions[id]
+	if !ok {
+		return false, fmt.Errorf("subscription not found")
+	}
+	s.ConsecutiveFailures++
+	if disableAfter <= 0 || !s.Enabled || s.ConsecutiveFailures < disableAfter {
+		return false, nil
+	}
+	s.Enabled, s.DisabledAt = false, &now
+	s.DisabledReason = fmt.Sprintf("%d consecutive delivery failures", disableAfter)
+	return true, nil
+}
+
+// =====================================================
+// Webhook Delivery Repositories
+// =====================================================
+
+func (r *MemoryAgentRepository) CreatePushLog(log *model.AgentPushLog) error {
+	log.ID = r.store.NextID()
+	log.CreatedAt = time.Now()
+	log.UpdatedAt = log.CreatedAt
+	cp := *log
+	r.store.AgentPushLogs[log.ID] = &cp
+	return nil
+}
+
+func (r *MemoryAgentRepository) UpdatePushLog(log *model.AgentPushLog) error {
+	if _, ok := r.store.AgentPushLogs[log.ID]; !ok {
+		return fmt.Errorf("push log not found")
+	}
+	log.UpdatedAt = time.Now()
+	cp := *log
+	r.store.AgentPushLogs[log.ID] = &cp
+	return nil
+}
+
+func (r *MemoryAgentRepository) ClaimDuePushLogs(now time.Time, lease time.Duration, limit int) ([]model.AgentPushLog, error) {
+	var results []model.AgentPushLog
+	for _, l := range r.store.AgentPushLogs {
+		if l.Status == "pending" && (l.NextAttemptAt == nil || !l.NextAttemptAt.After(now)) {
+			results = append(results, *l)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].ID < results[j].ID })
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	leaseUntil := now.Add(lease)
+	for _, l := range results {
+		r.store.AgentPushLogs[l.ID].NextAttemptAt = &leaseUntil
+	}
+	return results, nil
+}
+
+func (r *MemoryAgentRepository) CreatePushDeadLetter(dl *model.AgentPushDeadLetter) error {
+	dl.ID = r.store.NextID()
+	dl.CreatedAt = time.Now()
+	dl.UpdatedAt = dl.CreatedAt
+	r.store.AgentPushDeadLetters[dl.ID] = dl
+	return nil
+}
+
+func (r *MemoryAgentRepository) GetPushLogByID(id uint) (*model.AgentPushLog, error) {
+	if l, ok := r.store.AgentPushLogs[id]; ok {
+		cp := *l
+		return &cp, nil
+	}
+	return nil, fmt.Errorf("push log not found")
+}
+
+func (r *MemoryAgentRepository) ListPushLogs(subscriptionID uint, status string, limit int) ([]model.AgentPushLog, error) {
+	var results []model.AgentPushLog
+	for _, l := range r.store.AgentPushLogs {
+		if l.SubscriptionID == subscriptionID && (status == "" || l.Status == status) {
+			results = append(results, *l)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].ID > results[j].ID })
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// =====================================================
+// Feedback Repositories
+// =====================================================
+
+func (r *MemoryAgentRepository) GetMessageByID(id uint) (*model.AgentMessage, error) {
+	if m, ok := r.store.AgentMessages[id]; ok {
+		return m, nil
+	}
+	return nil, fmt.Errorf("message not found")
+}
+
+func (r *MemoryAgentRepository) GetKnowledgeByID(id string) (*model.AgentKnowledge, error) {
+	if k, ok := r.store.AgentKnowledges[id]; ok {
+		return k, nil
+	}
+	return nil, fmt.Errorf("knowledge not found")
+}
+
+func (r *MemoryAgentRepository) UpdateKnowledgeConfidence(id string, confidence float64) error {
+	if k, ok := r.store.AgentKnowledges[id]; ok {
+		k.Confidence = confidence
+		k.UpdatedAt = time.Now()
+		return nil
+	}
+	return fmt.Errorf("knowledge not found")
+}
+
+func (r *MemoryAgentRepository) GetUsageBySession(sessionID uint, scenario string) (*model.AgentUsage, error) {
+	var latest *model.AgentUsage
+	for _, u := range r.store.AgentUsages {
+		if u.SessionID == sessionID && u.Scenario == scenario && (latest == nil || u.CreatedAt.After(latest.CreatedAt)) {
+			latest = u
+		}
+	}
+	if latest == nil {
+		return nil, fmt.Errorf("usage not found")
+	}
+	return latest, nil
+}
+
+func (r *MemoryAgentRepository) SaveFeedback(fb *model.AgentFeedback) error {
+	if fb.ID == 0 {
+		fb.ID = r.store.NextID()
+		fb.CreatedAt = time.Now()
+	}
+	fb.UpdatedAt = time.Now()
+	r.store.AgentFeedbacks[fb.ID] = fb
+	return nil
+}
+
+func (r *MemoryAgentRepository) GetFeedbackByID(id uint) (*model.AgentFeedback, error) {
+	if fb, ok := r.store.AgentFeedbacks[id]; ok {
+		return fb, nil
+	}
+	return nil, fmt.Errorf("feedback not found")
+}
+
+func (r *MemoryAgentRepository) GetUserFeedback(userID uint, targetType string, targetID uint) (*model.AgentFeedback, error) {
+	for _, fb := range r.store.AgentFeedbacks {
+		if fb.UserID == userID && fb.TargetType == targetType && fb.TargetID == targetID {
+			return fb, nil
+		}
+	}
+	return nil, fmt.Errorf("feedback not found")
+}
+
+func (r *MemoryAgentRepository) ListFeedback(filter FeedbackFilter) ([]model.AgentFeedback, error) {
+	var results []model.AgentFeedback
+	for _, fb := range r.store.AgentFeedbacks {
+		if filter.FactoryID != nil && (fb.FactoryID == nil || *fb.FactoryID != *filter.FactoryID) {
+			continue
+		}
+		if filter.ReviewStatus != "" && fb.ReviewStatus != filter.ReviewStatus {
+			continue
+		}
+		if filter.SkillID != "" && fb.SkillID != filter.SkillID {
+			continue
+		}
+		if filter.Since != nil && fb.CreatedAt.Before(*filter.Since) {
+			continue
+		}
+		results = append(results, *fb)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].CreatedAt.After(results[j].CreatedAt)
+	})
+	if filter.Limit > 0 && len(results) > filter.Limit {
+		results = results[:filter.Limit]
+	}
+	return results, nil
+}
+
+// =====================================================
+// Conversation Share Repositories
+// =====================================================
+
+func (r *MemoryAgentRepository) CreateConversationShare(share *model.AgentConversationShare) error {
+	share.ID = r.store.NextID()
+	share.CreatedAt = time.Now()
+	share.UpdatedAt = time.Now()
+	r.store.AgentConversationShares[share.ID] = share
+	return nil
+}
+
+func (r *MemoryAgentRepository) GetConversationShareByToken(token string) (*model.AgentConversationShare, error) {
+	for _, sh := range r.store.AgentConversationShares {
+		if sh.Token == token {
+			return sh, nil
+		}
+	}
+	return nil, fmt.Errorf("share not found")
+}
+
+func (r *MemoryAgentRepository) ListConversationShares(convID uint) ([]model.AgentConversationShare, error) {
+	var results []model.AgentConversationShare
+	for _, sh := range r.store.AgentConversationShares {
+		if sh.ConversationID == convID {
+			results = append(results, *sh)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].CreatedAt.After(results[j].CreatedAt)
+	})
+	return results, nil
+}
+
+func (r *MemoryAgentRepository) RevokeConversationShare(id uint) error {
+	sh, ok := r.store.AgentConversationShares[id]
+	if !ok {
+		return fmt.Errorf("share not found")
+	}
+	now := time.Now()
+	sh.RevokedAt = &now
+	return nil
+}
+
+// =====================================================
+// Equipment Note Repositories
+// =====================================================
+
+func (r *MemoryAgentRepository) SaveEquipmentNote(note *model.AgentEquipmentNote) error {
+	if note.ID == 0 {
+		note.ID = r.store.NextID()
+		note.CreatedAt = time.Now()
+	}
+	note.UpdatedAt = time.Now()
+	r.store.AgentEquipmentNotes[note.ID] = note
+	return nil
+}
+
+func (r *MemoryAgentRepository) GetEquipmentNoteByID(id uint) (*model.AgentEquipmentNote, error) {
+	if note, ok := r.store.AgentEquipmentNotes[id]; ok {
+		return note, nil
+	}
+	return nil, fmt.Errorf("equipment note not found")
+}
+
+func (r *MemoryAgentRepository) ListEquipmentNotes(filter EquipmentNoteFilter) ([]model.AgentEquipmentNote, error) {
+	equipmentIDs, sourceIDs := map[uint]bool{}, map[uint]bool{}
+	for _, id := range filter.EquipmentIDs {
+		equipmentIDs[id] = true
+	}
+	for _, id := range filter.SourceIDs {
+		sourceIDs[id] = true
+	}
+	var results []model.AgentEquipmentNote
+	for _, n := range r.store.AgentEquipmentNotes {
+		if len(equipmentIDs) > 0 && !equipmentIDs[n.EquipmentID] {
+			continue
+		}
+		if filter.FactoryID != nil && (n.FactoryID == nil || *n.FactoryID != *filter.FactoryID) {
+			continue
+		}
+		if filter.Status != "" && n.Status != filter.Status {
+			continue
+		}
+		if filter.SourceType != "" && n.SourceType != filter.SourceType {
+			continue
+		}
+		if len(sourceIDs) > 0 && !sourceIDs[n.SourceID] {
+			continue
+		}
+		if filter.ActiveAt != nil && n.ExpiresAt != nil && !n.ExpiresAt.After(*filter.ActiveAt) {
+			continue
+		}
+		results = append(results, *n)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].CreatedAt.After(results[j].CreatedAt)
+	})
+	if filter.Limit > 0 && len(results) > filter.Limit {
+		results = results[:filter.Limit]
+	}
+	return results, nil
+}
+
+func (r *MemoryAgentRepository) DeleteEquipmentNote(id uint) error {
+	delete(r.store.AgentEquipmentNotes, id)
+	return nil
+}
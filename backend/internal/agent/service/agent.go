@@ -1,18 +1,22 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
-	"net/http"
 	"time"
 	"strings"
 	"github.com/ems/backend/internal/agent/analyzer"
+	"github.com/ems/backend/internal/agent/citation"
 	"github.com/ems/backend/internal/agent/dto"
 	"github.com/ems/backend/internal/agent/policy"
 	"github.com/ems/backend/internal/agent/prompt"
+	"github.com/ems/backend/internal/agent/pushfilter"
 	"github.com/ems/backend/internal/agent/repository"
 	"github.com/ems/backend/internal/agent/tool"
+	"github.com/ems/backend/internal/agent/webhook"
+	"github.com/ems/backend/internal/middleware"
 	"github.com/ems/backend/internal/model"
 	internalRepo "github.com/ems/backend/internal/repository"
 	"github.com/ems/backend/pkg/config"
@@ -43,7 +47,11 @@ type AgentService struct {
 	maintenanceAnalyzer *analyzer.MaintenanceAnalyzer
 	repairAuditAnalyzer *analyzer.RepairAuditAnalyzer
 	predictiveAnalyzer  *analyzer.PredictiveAnalyzer
+	rootCauseAnalyzer   *analyzer.RootCauseAnalyzer
 	sqlAnalystTool      *tool.SQLAnalystTool
+
+	// Webhook 投递
+	webhooks *webhook.Dispatcher
 }
 
 func NewAgentService() *AgentService {
@@ -61,6 +69,10 @@ func NewAgentService() *AgentService {
 	var llmClient llm.LLMClient
 	if config.Cfg.LLM.APIKey != "" {
 		llmClient = llm.NewOpenAIClient(config.Cfg.LLM.BaseURL, config.Cfg.LLM.APIKey, config.Cfg.LLM.Model)
+		if config.Cfg.LLM.Redaction.Enabled {
+			llmClient = llm.NewRedactingClient(llmClient, llm.NewRedactor(loadUserNames, 10*time.Minute))
+			log.Printf("[AgentService] LLM redaction enabled")
+		}
 		log.Printf("[AgentService] LLM client initialized (Provider: %s, Model: %s)", config.Cfg.LLM.Provider, config.Cfg.LLM.Model)
 	} else {
 		log.Printf("[AgentService] Warning: LLM API key is empty, AI features will be disabled")
@@ -79,6 +91,8 @@ func NewAgentService() *AgentService {
 		maintenanceAnalyzer: analyzer.NewMaintenanceAnalyzer(retrievalTool, maintenanceTool),
 		repairAuditAnalyzer: analyzer.NewRepairAuditAnalyzer(retrievalTool, repairTool),
 		predictiveAnalyzer:  analyzer.NewPredictiveAnalyzer(repairTool, maintenanceTool, retrievalTool),
+		rootCauseAnalyzer:   analyzer.NewRootCauseAnalyzer(tool.NewFleetTool(), retrievalTool),
+		webhooks:            webhook.NewDispatcher(repo),
 	}
 
 	svc.initToolRegistry()
@@ -89,194 +103,133 @@ func (s *AgentService) initToolRegistry() {
 	// Register search_equipment
 	s.toolRegistry.Register("search_equipment", dto.ToolDefinition{
 		Name: "search_equipment", Description: "Search for equipment by name, code or model",
-		InputSchema: map[string]interface{}{
-			"type": "object", "properties": map[string]interface{}{
-				"keyword": map[string]interface{}{"type": "string", "description": "Search keyword"},
-			}, "required": []string{"keyword"},
-		},
-	}, s.handleSearchEquipment, []string{"read:equipment"}, true)
+		InputSchema: tool.SchemaOf(dto.SearchEquipmentArgs{}),
+	}, tool.Typed(s.handleSearchEquipment), []string{"read:equipment"}, true)
 
 	// Register get_equipment_health
 	s.toolRegistry.Register("get_equipment_health", dto.ToolDefinition{
 		Name: "get_equipment_health", Description: "Get real-time health analysis and RUL prediction",
-		InputSchema: map[string]interface{}{
-			"type": "object", "properties": map[string]interface{}{
-				"equipment_id": map[string]interface{}{"type": "integer"},
-			}, "required": []string{"equipment_id"},
-		},
-	}, s.handleGetEquipmentHealth, []string{"read:equipment", "read:prediction"}, true)
+		InputSchema: tool.SchemaOf(dto.EquipmentToolArgs{}),
+	}, tool.Typed(s.handleGetEquipmentHealth), []string{"read:equipment", "read:prediction"}, true)
 	
 	// Register get_spare_part_inventory
 	s.toolRegistry.Register("get_spare_part_inventory", dto.ToolDefinition{
 		Name: "get_spare_part_inventory", Description: "Check stock levels of spare parts",
-		InputSchema: map[string]interface{}{
-			"type": "object", "properties": map[string]interface{}{
-				"spare_part_id": map[string]interface{}{"type": "integer"},
-				"factory_id":    map[string]interface{}{"type": "integer"},
-			}, "required": []string{"spare_part_id"},
-		},
-	}, s.handleGetSparePartInventory, []string{"read:sparepart"}, true)
+		InputSchema: tool.SchemaOf(dto.SparePartInventoryArgs{}),
+	}, tool.Typed(s.handleGetSparePartInventory), []string{"read:sparepart"}, true)
 
 	// Register report_repair
 	s.toolRegistry.Register("report_repair", dto.ToolDefinition{
 		Name: "report_repair", Description: "Submit a new repair request",
-		InputSchema: map[string]interface{}{
-			"type": "object", "properties": map[string]interface{}{
-				"equipment_id":      map[string]interface{}{"type": "integer"},
-				"fault_description": map[string]interface{}{"type": "string"},
-				"priority":          map[string]interface{}{"type": "integer", "description": "1=High, 2=Medium, 3=Low"},
-			}, "required": []string{"equipment_id", "fault_description"},
-		},
-	}, s.handleReportRepair, []string{"write:repair"}, false)
+		InputSchema: tool.SchemaOf(dto.ReportRepairArgs{}),
+	}, tool.Typed(s.handleReportRepair), []string{"write:repair"}, false)
 
 	// Register get_equipment_financials
 	s.toolRegistry.Register("get_equipment_financials", dto.ToolDefinition{
 		Name: "get_equipment_financials", Description: "Get equipment original value, residual value, and downtime loss",
-		InputSchema: map[string]interface{}{
-			"type": "object", "properties": map[string]interface{}{
-				"equipment_id": map[string]interface{}{"type": "integer"},
-			}, "required": []string{"equipment_id"},
-		},
-	}, s.handleGetEquipmentFinancials, []string{"read:equipment"}, true)
+		InputSchema: tool.SchemaOf(dto.EquipmentToolArgs{}),
+	}, tool.Typed(s.handleGetEquipmentFinancials), []string{"read:equipment"}, true)
 
 	// Register get_repair_costs
 	s.toolRegistry.Register("get_repair_costs", dto.ToolDefinition{
 		Name: "get_repair_costs", Description: "Get cumulative repair cost details (labor, spare parts)",
-		InputSchema: map[string]interface{}{
-			"type": "object", "properties": map[string]interface{}{
-				"equipment_id": map[string]interface{}{"type": "integer"},
-			}, "required": []string{"equipment_id"},
-		},
-	}, s.handleGetRepairCosts, []string{"read:repair"}, true)
+		InputSchema: tool.SchemaOf(dto.EquipmentToolArgs{}),
+	}, tool.Typed(s.handleGetRepairCosts), []string{"read:repair"}, true)
 
 	// Register get_equipment_profile
 	s.toolRegistry.Register("get_equipment_profile", dto.ToolDefinition{
 		Name: "get_equipment_profile", Description: "Get equipment basic profile and specifications",
-		InputSchema: map[string]interface{}{
-			"type": "object", "properties": map[string]interface{}{
-				"equipment_id": map[string]interface{}{"type": "integer"},
-			}, "required": []string{"equipment_id"},
-		},
-	}, s.handleGetEquipmentProfile, []string{"read:equipment"}, true)
+		InputSchema: tool.SchemaOf(dto.EquipmentToolArgs{}),
+	}, tool.Typed(s.handleGetEquipmentProfile), []string{"read:equipment"}, true)
 
 	// Register get_failure_stats
 	s.toolRegistry.Register("get_failure_stats", dto.ToolDefinition{
 		Name: "get_failure_stats", Description: "Get historical failure statistics for an equipment",
-		InputSchema: map[string]interface{}{
-			"type": "object", "properties": map[string]interface{}{
-				"equipment_id": map[string]interface{}{"type": "integer"},
-			}, "required": []string{"equipment_id"},
-		},
-	}, s.handleGetFailureStats, []string{"read:repair"}, true)
+		InputSchema: tool.SchemaOf(dto.EquipmentToolArgs{}),
+	}, tool.Typed(s.handleGetFailureStats), []string{"read:repair"}, true)
 
 	// Register get_maintenance_compliance
 	s.toolRegistry.Register("get_maintenance_compliance", dto.ToolDefinition{
 		Name: "get_maintenance_compliance", Description: "Get maintenance compliance evaluation",
-		InputSchema: map[string]interface{}{
-			"type": "object", "properties": map[string]interface{}{
-				"equipment_id": map[string]interface{}{"type": "integer"},
-			}, "required": []string{"equipment_id"},
-		},
-	}, s.handleGetMaintenanceCompliance, []string{"read:maintenance"}, true)
+		InputSchema: tool.SchemaOf(dto.EquipmentToolArgs{}),
+	}, tool.Typed(s.handleGetMaintenanceCompliance), []string{"read:maintenance"}, true)
 
 	// Register get_failure_distribution
 	s.toolRegistry.Register("get_failure_distribution", dto.ToolDefinition{
 		Name: "get_failure_distribution", Description: "Get failure distribution analysis for an equipment type",
-		InputSchema: map[string]interface{}{
-			"type": "object", "properties": map[string]interface{}{
-				"equipment_type_id": map[string]interface{}{"type": "integer"},
-			}, "required": []string{"equipment_type_id"},
-		},
-	}, s.handleGetFailureDistribution, []string{"read:repair"}, true)
+		InputSchema: tool.SchemaOf(dto.FailureDistributionArgs{}),
+	}, tool.Typed(s.handleGetFailureDistribution), []string{"read:repair"}, true)
+
+	// Register analyze_root_cause
+	s.toolRegistry.Register("analyze_root_cause", dto.ToolDefinition{
+		Name: "analyze_root_cause", Description: "Cluster recurring failures across a fleet of equipment and rank root cause hypotheses (spare part batch, recent maintenance, technician, workshop) with evidence",
+		InputSchema: tool.SchemaOf(dto.RootCauseArgs{}),
+	}, tool.Typed(s.handleAnalyzeRootCause), []string{"read:repair"}, true)
+
+	// Register get_equipment_notes
+	s.toolRegistry.Register("get_equipment_notes", dto.ToolDefinition{
+		Name: "get_equipment_notes", Description: "Get reviewed long-term notes about a specific equipment (modifications, non-OEM parts, known issues) with their provenance",
+		InputSchema: tool.SchemaOf(dto.EquipmentToolArgs{}),
+	}, tool.Typed(s.handleGetEquipmentNotes), []string{"read:equipment"}, true)
 
 	// Register search_manual_knowledge
 	s.toolRegistry.Register("search_manual_knowledge", dto.ToolDefinition{
 		Name: "search_manual_knowledge", Description: "Search for technical knowledge and manual excerpts",
-		InputSchema: map[string]interface{}{
-			"type": "object", "properties": map[string]interface{}{
-				"query": map[string]interface{}{"type": "string"},
-			}, "required": []string{"query"},
-		},
-	}, s.handleSearchManualKnowledge, []string{"read:knowledge"}, true)
+		InputSchema: tool.SchemaOf(dto.ManualSearchArgs{}),
+	}, tool.Typed(s.handleSearchManualKnowledge), []string{"read:knowledge"}, true)
 
 	// Register predict_remaining_life
 	s.toolRegistry.Register("predict_remaining_life", dto.ToolDefinition{
 		Name: "predict_remaining_life", Description: "Predict Remaining Useful Life (RUL) for an equipment",
-		InputSchema: map[string]interface{}{
-			"type": "object", "properties": map[string]interface{}{
-				"equipment_id": map[string]interface{}{"type": "integer"},
-			}, "required": []string{"equipment_id"},
-		},
-	}, s.handlePredictRUL, []string{"read:prediction"}, true)
+		InputSchema: tool.SchemaOf(dto.EquipmentToolArgs{}),
+	}, tool.Typed(s.handlePredictRUL), []string{"read:prediction"}, true)
 
 	// Register detect_symptoms
 	s.toolRegistry.Register("detect_symptoms", dto.ToolDefinition{
 		Name: "detect_symptoms", Description: "Detect sub-health symptoms for an equipment",
-		InputSchema: map[string]interface{}{
-			"type": "object", "properties": map[string]interface{}{
-				"equipment_id": map[string]interface{}{"type": "integer"},
-			}, "required": []string{"equipment_id"},
-		},
-	}, s.handleDetectSymptoms, []string{"read:prediction"}, true)
+		InputSchema: tool.SchemaOf(dto.EquipmentToolArgs{}),
+	}, tool.Typed(s.handleDetectSymptoms), []string{"read:prediction"}, true)
 
 	// Register get_tco_analysis
 	s.toolRegistry.Register("get_tco_analysis", dto.ToolDefinition{
 		Name: "get_tco_analysis", Description: "Get Total Cost of Ownership (TCO) analysis",
-		InputSchema: map[string]interface{}{
-			"type": "object", "properties": map[string]interface{}{
-				"equipment_id": map[string]interface{}{"type": "integer"},
-			}, "required": []string{"equipment_id"},
-		},
-	}, s.handleGetTCOAnalysis, []string{"read:equipment", "read:repair"}, true)
+		InputSchema: tool.SchemaOf(dto.EquipmentToolArgs{}),
+	}, tool.Typed(s.handleGetTCOAnalysis), []string{"read:equipment", "read:repair"}, true)
 
 	// Register get_retirement_recommendation
 	s.toolRegistry.Register("get_retirement_recommendation", dto.ToolDefinition{
 		Name: "get_retirement_recommendation", Description: "Get asset retirement and replacement recommendation",
-		InputSchema: map[string]interface{}{
-			"type": "object", "properties": map[string]interface{}{
-				"equipment_id": map[string]interface{}{"type": "integer"},
-			}, "required": []string{"equipment_id"},
-		},
-	}, s.handleEvaluateRetirement, []string{"read:equipment", "read:prediction"}, true)
+		InputSchema: tool.SchemaOf(dto.EquipmentToolArgs{}),
+	}, tool.Typed(s.handleEvaluateRetirement), []string{"read:equipment", "read:prediction"}, true)
 
 	// Register get_cost_analysis (alias for get_repair_costs)
 	s.toolRegistry.Register("get_cost_analysis", dto.ToolDefinition{
 		Name: "get_cost_analysis", Description: "Get cumulative repair cost details",
-		InputSchema: map[string]interface{}{
-			"type": "object", "properties": map[string]interface{}{
-				"equipment_id": map[string]interface{}{"type": "integer"},
-			}, "required": []string{"equipment_id"},
-		},
-	}, s.handleGetRepairCosts, []string{"read:repair"}, true)
+		InputSchema: tool.SchemaOf(dto.EquipmentToolArgs{}),
+	}, tool.Typed(s.handleGetRepairCosts), []string{"read:repair"}, true)
 
 	// Register sql_data_analyst
 	s.toolRegistry.Register("sql_data_analyst", dto.ToolDefinition{
 		Name: "sql_data_analyst",
 		Description: "Execute a read-only SQL query to perform flexible data analysis across multiple tables. Use this for complex questions that pre-defined tools cannot answer.",
-		InputSchema: map[string]interface{}{
-			"type": "object",
-			"properties": map[string]interface{}{
-				"sql_query": map[string]interface{}{
-					"type":        "string",
-					"description": "The standard PostgreSQL SELECT query to execute.",
-				},
-			},
-			"required": []string{"sql_query"},
-		},
-	}, s.handleSQLDataAnalyst, []string{"read:all"}, true)
+		InputSchema: tool.SchemaOf(dto.SQLAnalystArgs{}),
+	}, tool.Typed(s.handleSQLDataAnalyst), []string{"read:all"}, true)
+
+	// 耗时工具的超时、限流与并发上限；其余工具使用 tool.DefaultTimeout
+	s.toolRegistry.SetLimits("sql_data_analyst", tool.Limits{Timeout: 20 * time.Second, CallerPerMinute: 10, MaxConcurrent: 2})
+	s.toolRegistry.SetLimits("get_failure_distribution", tool.Limits{Timeout: 60 * time.Second, MaxConcurrent: 4})
+	s.toolRegistry.SetLimits("analyze_root_cause", tool.Limits{Timeout: 60 * time.Second, MaxConcurrent: 4})
+	s.toolRegistry.SetLimits("report_repair", tool.Limits{CallerPerMinute: 5})
+	s.toolRegistry.SetRateLimiter(middleware.AllowRate)
 }
 
-func (s *AgentService) handleSQLDataAnalyst(user model.User, args map[string]interface{}) (interface{}, error) {
-	query, ok := args["sql_query"].(string)
-	if !ok {
-		return nil, fmt.Errorf("missing sql_query argument")
-	}
-	return s.sqlAnalystTool.ExecuteQuery(query, user)
+func (s *AgentService) handleSQLDataAnalyst(ctx context.Context, user model.User, args dto.SQLAnalystArgs) (interface{}, error) {
+	return s.sqlAnalystTool.ExecuteQuery(ctx, args.SQLQuery, user)
 }
 
-func (s *AgentService) handleSearchEquipment(user model.User, args map[string]interface{}) (interface{}, error) {
-	keyword, _ := args["keyword"].(string)
-	db := database.GetDB()
+func (s *AgentService) handleSearchEquipment(ctx context.Context, user model.User, args dto.SearchEquipmentArgs) (interface{}, error) {
+	keyword := args.Keyword
+	db := database.GetDB().WithContext(ctx)
 	var equipments []model.Equipment
 	query := db.Preload("Workshop").Preload("Workshop.Factory")
 	if user.Role != "admin" && user.FactoryID != nil {
@@ -288,36 +241,33 @@ func (s *AgentService) handleSearchEquipment(user model.User, args map[string]in
 	return equipments, err
 }
 
-func (s *AgentService) handleGetEquipmentHealth(user model.User, args map[string]interface{}) (interface{}, error) {
-	var id uint
-	if v, ok := args["equipment_id"].(float64); ok { id = uint(v) } else if v, ok := args["equipment_id"].(int); ok { id = uint(v) }
-	return s.GetEquipmentPrediction(id, user)
+func (s *AgentService) handleGetEquipmentHealth(ctx context.Context, user model.User, args dto.EquipmentToolArgs) (interface{}, error) {
+	return s.GetEquipmentPrediction(args.EquipmentID, user)
 }
 
-func (s *AgentService) handleGetSparePartInventory(user model.User, args map[string]interface{}) (interface{}, error) {
-	var partID uint
-	if v, ok := args["spare_part_id"].(float64); ok { partID = uint(v) } else if v, ok := args["spare_part_id"].(int); ok { partID = uint(v) }
-	
-	db := database.GetDB()
+func (s *AgentService) handleGetSparePartInventory(ctx context.Context, user model.User, args dto.SparePartInventoryArgs) (interface{}, error) {
+	partID := args.SparePartID
+
+	db := database.GetDB().WithContext(ctx)
 	var inventories []model.SparePartInventory
 	query := db.Preload("Factory").Preload("SparePart").Where("spare_part_id = ?", partID)
 	if user.Role != "admin" && user.FactoryID != nil {
 		query = query.Where("factory_id = ?", *user.FactoryID)
-	} else if fid, ok := args["factory_id"]; ok {
-		query = query.Where("factory_id = ?", fid)
+	} else if args.FactoryID != nil {
+		query = query.Where("factory_id = ?", *args.FactoryID)
 	}
 	err := query.Find(&inventories).Error
 	return inventories, err
 }
 
-func (s *AgentService) handleReportRepair(user model.User, args map[string]interface{}) (interface{}, error) {
-	var equipID uint
-	if v, ok := args["equipment_id"].(float64); ok { equipID = uint(v) } else if v, ok := args["equipment_id"].(int); ok { equipID = uint(v) }
-	desc, _ := args["fault_description"].(string)
+func (s *AgentService) handleReportRepair(ctx context.Context, user model.User, args dto.ReportRepairArgs) (interface{}, error) {
+	equipID, desc := args.EquipmentID, args.FaultDescription
 	priority := 2
-	if p, ok := args["priority"].(float64); ok { priority = int(p) }
-	
-	db := database.GetDB()
+	if args.Priority != 0 {
+		priority = args.Priority
+	}
+
+	db := database.GetDB().WithContext(ctx)
 	var equipment model.Equipment
 	if err := db.Joins("JOIN workshops ON workshops.id = equipment.workshop_id").First(&equipment, equipID).Error; err != nil {
 		return nil, fmt.Errorf("equipment not found")
@@ -339,13 +289,8 @@ func (s *AgentService) handleReportRepair(user model.User, args map[string]inter
 	return fmt.Sprintf("Repair order #%d created successfully", order.ID), nil
 }
 
-func (s *AgentService) handleGetEquipmentFinancials(user model.User, args map[string]interface{}) (interface{}, error) {
-	var id uint
-	if v, ok := args["equipment_id"].(float64); ok {
-		id = uint(v)
-	} else if v, ok := args["equipment_id"].(int); ok {
-		id = uint(v)
-	}
+func (s *AgentService) handleGetEquipmentFinancials(ctx context.Context, user model.User, args dto.EquipmentToolArgs) (interface{}, error) {
+	id := args.EquipmentID
 
 	if config.Cfg.Storage.Mode == "memory" {
 		store := memory.GetStore()
@@ -393,65 +338,58 @@ func (s *AgentService) handleGetEquipmentFinancials(user model.User, args map[st
 	}, nil
 }
 
-func (s *AgentService) handleGetRepairCosts(user model.User, args map[string]interface{}) (interface{}, error) {
-	var id uint
-	if v, ok := args["equipment_id"].(float64); ok { id = uint(v) } else if v, ok := args["equipment_id"].(int); ok { id = uint(v) }
-	return s.repairTool.GetCostByEquipmentID(id, user)
+func (s *AgentService) handleGetRepairCosts(ctx context.Context, user model.User, args dto.EquipmentToolArgs) (interface{}, error) {
+	return s.repairTool.GetCostByEquipmentID(args.EquipmentID, user)
 }
 
-func (s *AgentService) handleGetEquipmentProfile(user model.User, args map[string]interface{}) (interface{}, error) {
-	var id uint
-	if v, ok := args["equipment_id"].(float64); ok { id = uint(v) } else if v, ok := args["equipment_id"].(int); ok { id = uint(v) }
-	return s.retrievalTool.GetEquipmentProfile(id, user)
+func (s *AgentService) handleGetEquipmentProfile(ctx context.Context, user model.User, args dto.EquipmentToolArgs) (interface{}, error) {
+	return s.retrievalTool.GetEquipmentProfile(args.EquipmentID, user)
 }
 
-func (s *AgentService) handleGetFailureStats(user model.User, args map[string]interface{}) (interface{}, error) {
-	var id uint
-	if v, ok := args["equipment_id"].(float64); ok { id = uint(v) } else if v, ok := args["equipment_id"].(int); ok { id = uint(v) }
-	return s.repairTool.GetFailureStats(id, user)
+func (s *AgentService) handleGetFailureStats(ctx context.Context, user model.User, args dto.EquipmentToolArgs) (interface{}, error) {
+	return s.repairTool.GetFailureStats(args.EquipmentID, user)
 }
 
-func (s *AgentService) handleGetMaintenanceCompliance(user model.User, args map[string]interface{}) (interface{}, error) {
-	var id uint
-	if v, ok := args["equipment_id"].(float64); ok { id = uint(v) } else if v, ok := args["equipment_id"].(int); ok { id = uint(v) }
-	return s.maintenanceTool.GetMaintenanceCompliance(id, user)
+func (s *AgentService) handleGetMaintenanceCompliance(ctx context.Context, user model.User, args dto.EquipmentToolArgs) (interface{}, error) {
+	return s.maintenanceTool.GetMaintenanceCompliance(args.EquipmentID, user)
 }
 
-func (s *AgentService) handleGetFailureDistribution(user model.User, args map[string]interface{}) (interface{}, error) {
-	var typeID uint
-	if v, ok := args["equipment_type_id"].(float64); ok { typeID = uint(v) } else if v, ok := args["equipment_type_id"].(int); ok { typeID = uint(v) }
+func (s *AgentService) handleGetFailureDistribution(ctx context.Context, user model.User, args dto.FailureDistributionArgs) (interface{}, error) {
+	typeID := args.EquipmentTypeID
 	if typeID == 0 { typeID = 12 } // Default for now to match old behavior
 	auditReq := &dto.RepairAuditRequest{EquipmentTypeID: typeID}
 	return s.repairAuditAnalyzer.Analyze(auditReq, user)
 }
 
-func (s *AgentService) handleSearchManualKnowledge(user model.User, args map[string]interface{}) (interface{}, error) {
-	query, _ := args["query"].(string)
-	return s.retrievalTool.SearchManualKnowledge(query, nil, user)
+func (s *AgentService) handleAnalyzeRootCause(ctx context.Context, user model.User, args dto.RootCauseArgs) (interface{}, error) {
+	req := &dto.RootCauseRequest{
+		EquipmentTypeID: args.EquipmentTypeID, WorkshopID: args.WorkshopID,
+		FaultCode: args.FaultCode, Keyword: args.Keyword,
+	}
+	if args.Days > 0 {
+		req.TimeRange.StartDate = time.Now().AddDate(0, 0, -args.Days).Format("2006-01-02")
+	}
+	return s.rootCauseAnalyzer.Analyze(req, user)
+}
+
+func (s *AgentService) handleSearchManualKnowledge(ctx context.Context, user model.User, args dto.ManualSearchArgs) (interface{}, error) {
+	return s.retrievalTool.SearchManualKnowledge(args.Query, nil, user)
 }
 
-func (s *AgentService) handlePredictRUL(user model.User, args map[string]interface{}) (interface{}, error) {
-	var id uint
-	if v, ok := args["equipment_id"].(float64); ok { id = uint(v) } else if v, ok := args["equipment_id"].(int); ok { id = uint(v) }
-	return s.predictiveAnalyzer.PredictRUL(id, user)
+func (s *AgentService) handlePredictRUL(ctx context.Context, user model.User, args dto.EquipmentToolArgs) (interface{}, error) {
+	return s.predictiveAnalyzer.PredictRUL(args.EquipmentID, user)
 }
 
-func (s *AgentService) handleDetectSymptoms(user model.User, args map[string]interface{}) (interface{}, error) {
-	var id uint
-	if v, ok := args["equipment_id"].(float64); ok { id = uint(v) } else if v, ok := args["equipment_id"].(int); ok { id = uint(v) }
-	return s.predictiveAnalyzer.DetectSymptoms(id, user)
+func (s *AgentService) handleDetectSymptoms(ctx context.Context, user model.User, args dto.EquipmentToolArgs) (interface{}, error) {
+	return s.predictiveAnalyzer.DetectSymptoms(args.EquipmentID, user)
 }
 
-func (s *AgentService) handleGetTCOAnalysis(user model.User, args map[string]interface{}) (interface{}, error) {
-	var id uint
-	if v, ok := args["equipment_id"].(float64); ok { id = uint(v) } else if v, ok := args["equipment_id"].(int); ok { id = uint(v) }
-	return s.predictiveAnalyzer.CalculateTCO(id, user)
+func (s *AgentService) handleGetTCOAnalysis(ctx context.Context, user model.User, args dto.EquipmentToolArgs) (interface{}, error) {
+	return s.predictiveAnalyzer.CalculateTCO(args.EquipmentID, user)
 }
 
-func (s *AgentService) handleEvaluateRetirement(user model.User, args map[string]interface{}) (interface{}, error) {
-	var id uint
-	if v, ok := args["equipment_id"].(float64); ok { id = uint(v) } else if v, ok := args["equipment_id"].(int); ok { id = uint(v) }
-	return s.predictiveAnalyzer.EvaluateRetirement(id, user)
+func (s *AgentService) handleEvaluateRetirement(ctx context.Context, user model.User, args dto.EquipmentToolArgs) (interface{}, error) {
+	return s.predictiveAnalyzer.EvaluateRetirement(args.EquipmentID, user)
 }
 
 func (s *AgentService) RecommendMaintenance(user model.User, req *dto.MaintenanceRecommendRequest) (*dto.AgentResponseEnvelope, error) {
@@ -480,6 +418,8 @@ func (s *AgentService) RecommendMaintenance(user model.User, req *dto.Maintenanc
 	if err != nil { return nil, err }
 
 	summary := "建议缩短保养周期，以提高设备可用性。"
+	generated := false
+	var knowledgeIDs []string
 	if s.llmClient != nil {
 		p := req.SystemPrompt
 		if p == "" {
@@ -487,14 +427,16 @@ func (s *AgentService) RecommendMaintenance(user model.User, req *dto.Maintenanc
 		} else {
 			p = fmt.Sprintf("%s\n\n### 原始数据参考\n当前计划: %v\n参考证据: %v", p, analysisResult.CurrentPlan, analysisResult.Evidence)
 		}
-		resp, err := s.llmClient.ChatCompletion([]llm.Message{
+		confirmedContext, confirmedIDs := s.confirmedKnowledge(req.Question)
+		resp, err := s.llmFor(user).ChatCompletion([]llm.Message{
 			{Role: "system", Content: "你是一个专业的工业设备管理助手。"},
-			{Role: "user", Content: p},
+			{Role: "user", Content: p + confirmedContext},
 		})
 		if err != nil {
 			log.Printf("[AgentService] LLM request failed in RecommendMaintenance: %v", err)
 		} else if resp != "" {
-			summary = resp
+			summary, generated = resp, true
+			knowledgeIDs = confirmedIDs
 		}
 	} else if len(analysisResult.Recommendations) > 0 {
 		summary = analysisResult.Recommendations[0].Description + "。" + analysisResult.Recommendations[0].Reason
@@ -511,32 +453,24 @@ func (s *AgentService) RecommendMaintenance(user model.User, req *dto.Maintenanc
 		return nil, err
 	}
 
+	grounding, riskLevel := s.groundSummary(summary, generated, req.SystemPrompt != "", analysisResult.Evidence, "medium", analysisResult)
 	artifact := &model.AgentArtifact{
 		SessionID: session.ID, ArtifactType: "recommendation", Title: "设备保养优化建议",
-		Summary: summary, ResultJSON: string(resultJSON), RiskLevel: "medium",
+		Summary: summary, ResultJSON: string(resultJSON), RiskLevel: riskLevel, KnowledgeIDs: knowledgeIDsJSON(knowledgeIDs),
 	}
 	if err := s.repo.CreateArtifact(artifact); err != nil {
 		log.Printf("[AgentService] Failed to create artifact: %v", err)
 	}
-
-	for _, ev := range analysisResult.Evidence {
-		link := model.AgentEvidenceLink{
-			ArtifactID: artifact.ID, EvidenceType: ev.EvidenceType,
-			SourceTable: ev.SourceTable, SourceID: ev.SourceID, Excerpt: ev.Excerpt, Score: ev.Score,
-		}
-		if err := s.repo.CreateEvidenceLinks([]model.AgentEvidenceLink{link}); err != nil {
-			log.Printf("[AgentService] Failed to create evidence link: %v", err)
-		}
-	}
+	links := s.saveEvidenceLinks(artifact.ID, analysisResult.Evidence)
 
 	res := &dto.AgentResponseEnvelope{
 		Success: true, TraceID: traceID, Language: agentCtx.Language, Scenario: "maintenance_recommendation",
 		ScopeSummary: map[string]interface{}{"factory_id": targetFactoryID},
-		Summary: summary, RiskLevel: "medium", ArtifactID: artifact.ID,
+		Summary: summary, RiskLevel: riskLevel, ArtifactID: artifact.ID,
 		EvidenceCount: len(analysisResult.Evidence), Data: analysisResult,
 	}
 	s.logUsage(session.ID, user.ID, "maintenance_recommendation", startTime)
-	return res, nil
+	return withGrounding(res, grounding, links), nil
 }
 
 func (s *AgentService) AuditRepair(user model.User, req *dto.RepairAuditRequest) (*dto.AgentResponseEnvelope, error) {
@@ -565,6 +499,7 @@ func (s *AgentService) AuditRepair(user model.User, req *dto.RepairAuditRequest)
 	if err != nil { return nil, err }
 
 	summary := "发现维修异常，建议复核维修质量。"
+	generated := false
 	if s.llmClient != nil {
 		p := req.SystemPrompt
 		if p == "" {
@@ -572,14 +507,14 @@ func (s *AgentService) AuditRepair(user model.User, req *dto.RepairAuditRequest)
 		} else {
 			p = fmt.Sprintf("%s\n\n### 原始数据参考\n异常项: %v\n参考证据: %v", p, analysisResult.Anomalies, analysisResult.Evidence)
 		}
-		resp, err := s.llmClient.ChatCompletion([]llm.Message{
+		resp, err := s.llmFor(user).ChatCompletion([]llm.Message{
 			{Role: "system", Content: "你是一个设备维修审计助手。"},
 			{Role: "user", Content: p},
 		})
 		if err != nil {
 			log.Printf("[AgentService] LLM request failed in AuditRepair: %v", err)
 		} else if resp != "" {
-			summary = resp
+			summary, generated = resp, true
 		}
 	} else if stats, ok := analysisResult.Stats.(map[string]interface{}); ok {
 		if val, exists := stats["anomaly_summary"]; exists {
@@ -598,32 +533,105 @@ func (s *AgentService) AuditRepair(user model.User, req *dto.RepairAuditRequest)
 		return nil, err
 	}
 
+	grounding, riskLevel := s.groundSummary(summary, generated, req.SystemPrompt != "", analysisResult.Evidence, "high", analysisResult)
 	artifact := &model.AgentArtifact{
 		SessionID: session.ID, ArtifactType: "audit_report", Title: "设备维修审计报告",
-		Summary: summary, ResultJSON: string(resultJSON), RiskLevel: "high",
+		Summary: summary, ResultJSON: string(resultJSON), RiskLevel: riskLevel,
 	}
 	if err := s.repo.CreateArtifact(artifact); err != nil {
 		log.Printf("[AgentService] Failed to create artifact: %v", err)
 	}
+	links := s.saveEvidenceLinks(artifact.ID, analysisResult.Evidence)
+
+	res := &dto.AgentResponseEnvelope{
+		Success: true, TraceID: traceID, Language: agentCtx.Language, Scenario: "repair_audit",
+		ScopeSummary: map[string]interface{}{"factory_id": targetFactoryID},
+		Summary: summary, RiskLevel: riskLevel, ArtifactID: artifact.ID,
+		EvidenceCount: len(analysisResult.Evidence), Data: analysisResult,
+	}
+	s.logUsage(session.ID, user.ID, "repair_audit", startTime)
+	return withGrounding(res, grounding, links), nil
+}
+
+// AnalyzeRootCause 机群级根因分析：对同型设备的故障聚类并排序根因假设
+func (s *AgentService) AnalyzeRootCause(user model.User, req *dto.RootCauseRequest) (*dto.AgentResponseEnvelope, error) {
+	startTime := time.Now()
+	traceID := trace.GenerateTraceID()
+
+	agentCtx, err := s.policy.DeriveAgentContext(user.ID, string(user.Role), req.Language)
+	if err != nil { return nil, err }
+
+	// Prevent system prompt override for non-admin users
+	if req.SystemPrompt != "" && user.Role != "admin" {
+		log.Printf("[AgentService] Security warning: Non-admin user %d tried to override system prompt", user.ID)
+		req.SystemPrompt = ""
+	}
+
+	targetFactoryID := req.FactoryID
+	if targetFactoryID == 0 && agentCtx.FactoryID != nil {
+		targetFactoryID = *agentCtx.FactoryID
+	}
 
-	for _, ev := range analysisResult.Evidence {
-		link := model.AgentEvidenceLink{
-			ArtifactID: artifact.ID, EvidenceType: ev.EvidenceType,
-			SourceTable: ev.SourceTable, SourceID: ev.SourceID, Excerpt: ev.Excerpt, Score: ev.Score,
+	if err := s.policy.ValidateScope(agentCtx, &targetFactoryID); err != nil {
+		return nil, err
+	}
+	req.FactoryID = targetFactoryID
+
+	analysisResult, err := s.rootCauseAnalyzer.Analyze(req, user)
+	if err != nil { return nil, err }
+
+	summary, _ := analysisResult.Stats["root_cause_summary"].(string)
+	if summary == "" {
+		summary = "未发现可归因的批量故障。"
+	}
+	generated := false
+	if s.llmClient != nil && len(analysisResult.Hypotheses) > 0 {
+		p := req.SystemPrompt
+		if p == "" {
+			p = s.promptTool.BuildRootCausePrompt(analysisResult.Hypotheses, analysisResult.Evidence)
+		} else {
+			p = fmt.Sprintf("%s\n\n### 原始数据参考\n根因假设: %v\n参考证据: %v", p, analysisResult.Hypotheses, analysisResult.Evidence)
 		}
-		if err := s.repo.CreateEvidenceLinks([]model.AgentEvidenceLink{link}); err != nil {
-			log.Printf("[AgentService] Failed to create evidence link: %v", err)
+		resp, err := s.llmFor(user).ChatCompletion([]llm.Message{
+			{Role: "system", Content: "你是一个设备可靠性工程师。"},
+			{Role: "user", Content: p},
+		})
+		if err != nil {
+			log.Printf("[AgentService] LLM request failed in AnalyzeRootCause: %v", err)
+		} else if resp != "" {
+			summary, generated = resp, true
 		}
 	}
 
+	inputSnap, _ := json.Marshal(req)
+	resultJSON, _ := json.Marshal(analysisResult)
+	session := &model.AgentSession{
+		UserID: user.ID, Scenario: "root_cause", FactoryID: &targetFactoryID,
+		Language: agentCtx.Language, InputSnapshot: string(inputSnap), TraceID: traceID, Status: "completed",
+	}
+	if err := s.repo.CreateSession(session); err != nil {
+		log.Printf("[AgentService] Failed to create session: %v", err)
+		return nil, err
+	}
+
+	grounding, riskLevel := s.groundSummary(summary, generated, req.SystemPrompt != "", analysisResult.Evidence, "high", analysisResult)
+	artifact := &model.AgentArtifact{
+		SessionID: session.ID, ArtifactType: "root_cause_report", Title: "机群故障根因分析报告",
+		Summary: summary, ResultJSON: string(resultJSON), RiskLevel: riskLevel,
+	}
+	if err := s.repo.CreateArtifact(artifact); err != nil {
+		log.Printf("[AgentService] Failed to create artifact: %v", err)
+	}
+	links := s.saveEvidenceLinks(artifact.ID, analysisResult.Evidence)
+
 	res := &dto.AgentResponseEnvelope{
-		Success: true, TraceID: traceID, Language: agentCtx.Language, Scenario: "repair_audit",
-		ScopeSummary: map[string]interface{}{"factory_id": targetFactoryID},
-		Summary: summary, RiskLevel: "high", ArtifactID: artifact.ID,
+		Success: true, TraceID: traceID, Language: agentCtx.Language, Scenario: "root_cause",
+		ScopeSummary: map[string]interface{}{"factory_id": targetFactoryID, "equipment_type_id": req.EquipmentTypeID},
+		Summary: summary, RiskLevel: riskLevel, ArtifactID: artifact.ID,
 		EvidenceCount: len(analysisResult.Evidence), Data: analysisResult,
 	}
-	s.logUsage(session.ID, user.ID, "repair_audit", startTime)
-	return res, nil
+	s.logUsage(session.ID, user.ID, "root_cause", startTime)
+	return withGrounding(res, grounding, links), nil
 }
 
 func (s *AgentService) AuditMaintenance(user model.User, req *dto.MaintenanceAuditRequest) (*dto.AgentResponseEnvelope, error) {
@@ -652,6 +660,7 @@ func (s *AgentService) AuditMaintenance(user model.User, req *dto.MaintenanceAud
 	if err != nil { return nil, err }
 
 	summary := analysisResult.AuditSummary
+	generated := false
 	if s.llmClient != nil {
 		p := req.SystemPrompt
 		if p == "" {
@@ -659,12 +668,12 @@ func (s *AgentService) AuditMaintenance(user model.User, req *dto.MaintenanceAud
 		} else {
 			p = fmt.Sprintf("%s\n\n### 审计发现\n异常: %v\n证据: %v", p, analysisResult.Anomalies, analysisResult.Evidence)
 		}
-		resp, err := s.llmClient.ChatCompletion([]llm.Message{
+		resp, err := s.llmFor(user).ChatCompletion([]llm.Message{
 			{Role: "system", Content: "你是一个专业的设备保养审计专家。"},
 			{Role: "user", Content: p},
 		})
 		if err == nil && resp != "" {
-			summary = resp
+			summary, generated = resp, true
 		}
 	}
 
@@ -676,20 +685,22 @@ func (s *AgentService) AuditMaintenance(user model.User, req *dto.MaintenanceAud
 	}
 	_ = s.repo.CreateSession(session)
 
+	grounding, riskLevel := s.groundSummary(summary, generated, req.SystemPrompt != "", analysisResult.Evidence, "medium", analysisResult)
 	artifact := &model.AgentArtifact{
 		SessionID: session.ID, ArtifactType: "audit_report", Title: "设备保养合规审计报告",
-		Summary: summary, ResultJSON: string(resultJSON), RiskLevel: "medium",
+		Summary: summary, ResultJSON: string(resultJSON), RiskLevel: riskLevel,
 	}
 	_ = s.repo.CreateArtifact(artifact)
+	links := s.saveEvidenceLinks(artifact.ID, analysisResult.Evidence)
 
 	res := &dto.AgentResponseEnvelope{
 		Success: true, TraceID: traceID, Language: agentCtx.Language, Scenario: "maintenance_audit",
 		ScopeSummary: map[string]interface{}{"factory_id": targetFactoryID},
-		Summary: summary, RiskLevel: "medium", ArtifactID: artifact.ID,
+		Summary: summary, RiskLevel: riskLevel, ArtifactID: artifact.ID,
 		EvidenceCount: len(analysisResult.Evidence), Data: analysisResult,
 	}
 	s.logUsage(session.ID, user.ID, "maintenance_audit", startTime)
-	return res, nil
+	return withGrounding(res, grounding, links), nil
 }
 
 func (s *AgentService) Analyze(user model.User, req *dto.AnalyzeRequest) (*dto.AgentResponseEnvelope, error) {
@@ -715,10 +726,10 @@ func (s *AgentService) Analyze(user model.User, req *dto.AnalyzeRequest) (*dto.A
 	}
 
 	// 1. Gather context based on the question (Entity extraction simplified for MVP)
-	eqID := s.extractEquipmentID(req.Question, user)
+	eqID, eqFound := s.extractEquipmentID(req.Question, user)
 	contextMap := make(map[string]interface{})
 	
-	if eqID != 1 {
+	if eqFound {
 		profile, _ := s.retrievalTool.GetEquipmentProfile(eqID, user)
 		health, _ := s.GetEquipmentPrediction(eqID, user)
 		failureStats, _ := s.repairTool.GetFailureStats(eqID, user)
@@ -727,47 +738,64 @@ func (s *AgentService) Analyze(user model.User, req *dto.AnalyzeRequest) (*dto.A
 		contextMap["failure_stats"] = failureStats
 	}
 
-	// 2. Generate summary via LLM
-	summary := "已为您完成多维度分析。建议关注设备的 RUL 变化及维护成本趋势。"
-	if s.llmClient != nil {
-		p := req.SystemPrompt
-		if p == "" {
-			p = s.promptTool.BuildGenericAnalysisPrompt(req.Question, contextMap)
-		} else {
-			p = fmt.Sprintf("%s\n\n### 补充背景\n%v", p, contextMap)
-		}
-		
-		resp, err := s.llmClient.ChatCompletion([]llm.Message{
-			{Role: "system", Content: "你是一个顶级的工业资产战略分析师。"},
-			{Role: "user", Content: p},
-		})
-		if err == nil && resp != "" {
-			summary = resp
-		}
-	}
-
+	// 2. Derive rule-based findings and evidence from context
 	analysisData := dto.AnalyzeData{
-		KeyFindings: []string{},
-		Evidence:    []dto.EvidenceItem{},
+		KeyFindings:        []string{},
+		Findings:           []dto.AnalysisFinding{},
+		Evidence:           []dto.EvidenceItem{},
+		RecommendedActions: []dto.RecommendedAction{},
 	}
 
 	if health, ok := contextMap["equipment_health"].(map[string]interface{}); ok {
 		if rul, ok := health["rul"].(*dto.RULPrediction); ok && rul.EstimatedRULDays < 10 {
-			analysisData.KeyFindings = append(analysisData.KeyFindings, fmt.Sprintf("设备剩余寿命仅剩 %d 天，存在停机风险", rul.EstimatedRULDays))
 			analysisData.Evidence = append(analysisData.Evidence, dto.EvidenceItem{
-				EvidenceType: "prediction", Title: "RUL 预测", Excerpt: fmt.Sprintf("预计剩余寿命: %d天", rul.EstimatedRULDays), Score: 0.95,
+				EvidenceType: "prediction", SourceTable: "equipment", SourceID: eqID, Title: "RUL 预测", Excerpt: fmt.Sprintf("预计剩余寿命: %d天", rul.EstimatedRULDays), Score: 0.95,
+			})
+			analysisData.Findings = append(analysisData.Findings, dto.AnalysisFinding{
+				Title: fmt.Sprintf("设备剩余寿命仅剩 %d 天，存在停机风险", rul.EstimatedRULDays), RiskLevel: "high",
+				EvidenceRefs: []int{len(analysisData.Evidence)}, Source: "rule",
 			})
 		}
 	}
 
 	if stats, ok := contextMap["failure_stats"].(map[string]interface{}); ok {
 		if count, ok := stats["repair_count"].(int); ok && count > 5 {
-			analysisData.KeyFindings = append(analysisData.KeyFindings, "近期维修频率较高，建议核查根本原因")
+			analysisData.Findings = append(analysisData.Findings, dto.AnalysisFinding{
+				Title: "近期维修频率较高，建议核查根本原因", Detail: fmt.Sprintf("统计周期内维修 %d 次", count), RiskLevel: "medium", Source: "rule",
+			})
 		}
 	}
 
-	if len(analysisData.KeyFindings) == 0 {
-		analysisData.KeyFindings = append(analysisData.KeyFindings, "设备运行状况平稳", "未发现近期异常趋势")
+	// 3. Generate a schema-validated report via LLM; rule findings stay as the fallback
+	summary := "已为您完成多维度分析。建议关注设备的 RUL 变化及维护成本趋势。"
+	generated := false
+	var knowledgeIDs []string
+	if s.llmClient != nil {
+		p := req.SystemPrompt
+		if p == "" {
+			p = s.promptTool.BuildGenericAnalysisPrompt(req.Question, contextMap, analysisData.Evidence)
+		} else {
+			p = fmt.Sprintf("%s\n\n### 补充背景\n%v", p, contextMap)
+		}
+		confirmedContext, confirmedIDs := s.confirmedKnowledge(req.Question)
+		if report, err := s.structuredAnalysis(user, p+confirmedContext, analysisData.Findings); err != nil {
+			log.Printf("[AgentService] Structured analysis failed, using rule-based findings: %v", err)
+		} else {
+			summary, generated = report.Summary, true
+			knowledgeIDs = confirmedIDs
+			mergeAnalysisReport(&analysisData, report)
+		}
+	}
+	if len(analysisData.Findings) == 0 {
+		analysisData.Findings = append(analysisData.Findings,
+			dto.AnalysisFinding{Title: "设备运行状况平稳", RiskLevel: "low", Source: "rule"},
+			dto.AnalysisFinding{Title: "未发现近期异常趋势", RiskLevel: "low", Source: "rule"})
+	}
+	for _, f := range analysisData.Findings {
+		analysisData.KeyFindings = append(analysisData.KeyFindings, f.Title)
+	}
+	if analysisData.RiskLevel == "" {
+		analysisData.RiskLevel = highestRisk(analysisData.Findings)
 	}
 
 	inputSnap, _ := json.Marshal(req)
@@ -778,18 +806,21 @@ func (s *AgentService) Analyze(user model.User, req *dto.AnalyzeRequest) (*dto.A
 	}
 	_ = s.repo.CreateSession(session)
 
+	grounding, riskLevel := s.groundSummary(summary, generated, req.SystemPrompt != "", analysisData.Evidence, analysisData.RiskLevel, contextMap)
 	artifact := &model.AgentArtifact{
 		SessionID: session.ID, ArtifactType: "analysis_result", Title: "深度业务分析报告",
-		Summary: summary, ResultJSON: string(resultJSON), RiskLevel: "medium",
+		Summary: summary, ResultJSON: string(resultJSON), RiskLevel: riskLevel, KnowledgeIDs: knowledgeIDsJSON(knowledgeIDs),
 	}
 	_ = s.repo.CreateArtifact(artifact)
+	links := s.saveEvidenceLinks(artifact.ID, analysisData.Evidence)
 
 	res := &dto.AgentResponseEnvelope{
 		Success: true, TraceID: traceID, Language: agentCtx.Language, Scenario: "analysis",
 		ScopeSummary: map[string]interface{}{"factory_id": targetFactoryID, "equipment_id": eqID},
-		Summary: summary, RiskLevel: "medium", ArtifactID: artifact.ID,
+		Summary: summary, RiskLevel: riskLevel, ArtifactID: artifact.ID,
 		EvidenceCount: len(analysisData.Evidence), Data: analysisData,
 	}
+	res = withGrounding(res, grounding, links)
 	s.logUsage(session.ID, user.ID, "analysis", startTime)
 	return res, nil
 }
@@ -862,7 +893,7 @@ func (s *AgentService) ListKnowledges(status string, query string, eqTypeID *uin
 // Phase 2: Chat & Conversational Logic
 // =====================================================
 
-func (s *AgentService) Chat(user model.User, req *dto.ChatRequest) (*dto.ChatResponse, error) {
+func (s *AgentService) Chat(ctx context.Context, user model.User, req *dto.ChatRequest) (*dto.ChatResponse, error) {
 	startTime := time.Now()
 	traceID := trace.GenerateTraceID()
 
@@ -877,12 +908,23 @@ func (s *AgentService) Chat(user model.User, req *dto.ChatRequest) (*dto.ChatRes
 		newConv := &model.AgentConversation{
 			UserID: user.ID,
 			Title:  title,
+			Status: conversationActive,
 		}
 		if err := s.repo.CreateConversation(newConv); err != nil {
 			log.Printf("[AgentService] Failed to create conversation: %v", err)
 			return nil, err
 		}
 		convID = newConv.ID
+	} else {
+		conv, err := s.ownConversation(convID, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		// 在归档对话中继续提问时恢复为进行中
+		if conv.Status == conversationArchived {
+			conv.Status = conversationActive
+			_ = s.repo.UpdateConversation(conv)
+		}
 	}
 
 	// 2. 持久化用户消息
@@ -896,16 +938,28 @@ func (s *AgentService) Chat(user model.User, req *dto.ChatRequest) (*dto.ChatRes
 		for _, e := range activeExps { expContext += fmt.Sprintf("- [%s]: %s\n", e.Category, e.Content) }
 	}
 
+	// 解析页面上下文（当前页面、选中的设备/工单/任务/备件）
+	page := s.resolveChatContext(user, req.Context)
+
 	// 4. 意图识别与技能匹配 (Milestone N)
 	matchedSkills, _ := s.repo.MatchSkills(req.Message, 1)
 	var reply string
 	var skillID string
+	var knowledgeIDs []string
+	var toolCalls []dto.ToolCallRecord
 
 	if len(matchedSkills) > 0 {
 		skill := matchedSkills[0]
 		skillID = fmt.Sprintf("%d", skill.ID)
-		res, err := s.ExecuteSkill(user, &skill, req)
-		if err == nil { reply = res.Summary + expContext }
+		res, err := s.executeSkill(ctx, user, &skill, req, page)
+		if err == nil {
+			reply = res.Summary + expContext
+			if data, ok := res.Data.(map[string]interface{}); ok {
+				if msgs, ok := data["final_messages"].([]llm.Message); ok {
+					toolCalls = toolCallRecords(msgs)
+				}
+			}
+		}
 	}
 
 	// 5. 退回到标准对话
@@ -913,14 +967,19 @@ func (s *AgentService) Chat(user model.User, req *dto.ChatRequest) (*dto.ChatRes
 		history, _ := s.repo.GetMessagesByConversationID(convID)
 		
 		// Context retrieval: Find relevant equipment or knowledge
-		eqID := s.extractEquipmentID(req.Message, user)
-		businessContext := ""
-		if eqID != 1 { // If a specific equipment was found
+		eqID, eqFound := s.extractEquipmentID(req.Message, user)
+		eqID, eqFound = pageEquipment(eqID, eqFound, page)
+		businessContext := page.Prompt()
+		if eqFound { // If a specific equipment was found
 			profile, _ := s.retrievalTool.GetEquipmentProfile(eqID, user)
 			health, _ := s.GetEquipmentPrediction(eqID, user)
 			profileJSON, _ := json.Marshal(profile)
 			healthJSON, _ := json.Marshal(health)
 			businessContext += fmt.Sprintf("\n### 当前讨论的设备上下文\n基础信息: %s\n健康分析: %s\n", profileJSON, healthJSON)
+			businessContext += s.equipmentNotesContext(eqID)
+		}
+		if others := page.otherEquipment(eqID); len(others) > 0 {
+			businessContext += s.equipmentNotesContext(others...)
 		}
 		
 		// Retrieve relevant knowledge
@@ -933,6 +992,11 @@ func (s *AgentService) Chat(user model.User, req *dto.ChatRequest) (*dto.ChatRes
 			}
 		}
 
+		// 已确认的沉淀知识，记录引用以便用户评价回写置信度
+		confirmedContext, confirmedIDs := s.confirmedKnowledge(req.Message)
+		businessContext += confirmedContext
+		knowledgeIDs = append(knowledgeIDs, confirmedIDs...)
+
 		llmMsgs := []llm.Message{
 			{Role: "system", Content: "你是一个顶级的工业资产战略专家。你拥有‘L4 级主动洞察’权限，可以基于全生命周期成本 (TCO)、资产退役 ROI 评价、剩余健康寿命 (RUL) 和亚健康故障征兆进行跨维度的深度分析。请使用中文回答，结论必须引用系统中的财务与技术证据。" + expContext + businessContext},
 		}
@@ -954,7 +1018,7 @@ func (s *AgentService) Chat(user model.User, req *dto.ChatRequest) (*dto.ChatRes
 
 		if s.llmClient != nil {
 			var err error
-			reply, err = s.llmClient.ChatCompletion(llmMsgs)
+			reply, err = s.llmFor(user).ChatCompletion(llmMsgs)
 			if err != nil { reply = "抱歉，分析过程中出现了点问题：" + err.Error() }
 		} else {
 			reply = "（预览模式）收到了您的消息：\"" + req.Message + "\"。目前 LLM 服务未配置。"
@@ -962,35 +1026,69 @@ func (s *AgentService) Chat(user model.User, req *dto.ChatRequest) (*dto.ChatRes
 	}
 
 	// 6. 持久化助手消息
-	_ = s.repo.CreateMessage(&model.AgentMessage{ConversationID: convID, Role: "assistant", Content: reply, SkillID: skillID})
+	assistantMsg := &model.AgentMessage{ConversationID: convID, Role: "assistant", Content: reply, SkillID: skillID, KnowledgeIDs: knowledgeIDsJSON(knowledgeIDs)}
+	if len(toolCalls) > 0 {
+		callsJSON, _ := json.Marshal(toolCalls)
+		calls := string(callsJSON)
+		assistantMsg.ToolCalls = &calls
+	}
+	_ = s.repo.CreateMessage(assistantMsg)
 
 	// 7. 异步触发反思与学习 (Milestone L, O & P)
-	go s.ReflectAndLearn(convID, user.ID)
+	go s.ReflectAndLearn(convID, user)
 
 	// 8. 记录使用情况
 	s.logUsage(convID, user.ID, "chat", startTime)
 
 	return &dto.ChatResponse{
-		ConversationID: convID, Reply: reply, TraceID: traceID,
+		ConversationID: convID, MessageID: assistantMsg.ID, Reply: reply, TraceID: traceID,
 		SuggestedActions: []string{"查看维修历史", "运行故障诊断", "查询备件库存"},
 	}, nil
 }
 
-func (s *AgentService) ListConversations(userID uint, limit int) ([]dto.ConversationResponse, error) {
-	convs, err := s.repo.ListConversationsByUserID(userID, limit)
+// ListConversations 按状态列出对话，status 为空时返回全部
+// confirmedKnowledge 检索与问题相关的已确认知识，返回提示词片段与引用的知识 ID
+func (s *AgentService) confirmedKnowledge(query string) (string, []string) {
+	if strings.TrimSpace(query) == "" {
+		return "", nil
+	}
+	confirmed, _ := s.repo.ListKnowledges("confirmed", query, 3)
+	if len(confirmed) == 0 {
+		return "", nil
+	}
+	var b strings.Builder
+	b.WriteString("\n### 已确认的经验知识\n")
+	ids := make([]string, 0, len(confirmed))
+	for _, k := range confirmed {
+		fmt.Fprintf(&b, "- [%s]: %s\n", k.Title, k.Summary)
+		ids = append(ids, k.ID)
+	}
+	return b.String(), ids
+}
+
+func knowledgeIDsJSON(ids []string) *string {
+	if len(ids) == 0 {
+		return nil
+	}
+	idsJSON, _ := json.Marshal(ids)
+	s := string(idsJSON)
+	return &s
+}
+
+func (s *AgentService) ListConversations(userID uint, status string, limit int) ([]dto.ConversationResponse, error) {
+	convs, err := s.repo.ListConversationsByUserID(userID, status, limit)
 	if err != nil { return nil, err }
 	results := make([]dto.ConversationResponse, len(convs))
-	for i, c := range convs {
-		results[i] = dto.ConversationResponse{
-			ID: c.ID, Title: c.Title, Status: c.Status, CreatedAt: c.CreatedAt, UpdatedAt: c.UpdatedAt,
-		}
+	for i := range convs {
+		results[i] = mapConversation(&convs[i])
 	}
 	return results, nil
 }
 
-func (s *AgentService) extractEquipmentID(message string, user model.User) uint {
+// extractEquipmentID 按编号或名称识别消息中提到的本工厂设备，未识别到时 ok 为 false
+func (s *AgentService) extractEquipmentID(message string, user model.User) (uint, bool) {
 	if config.Cfg.Storage.Mode == "memory" {
-		return 1 // Fallback for memory mode demo
+		return 0, false // 内存模式不做设备识别
 	}
 	
 	var equipments []model.Equipment
@@ -1002,16 +1100,16 @@ func (s *AgentService) extractEquipmentID(message string, user model.User) uint
 	}
 	
 	if err := query.Find(&equipments).Error; err != nil {
-		return 1
+		return 0, false
 	}
 
 	for _, eq := range equipments {
 		if strings.Contains(message, eq.Code) || (eq.Name != "" && strings.Contains(message, eq.Name)) {
-			return eq.ID
+			return eq.ID, true
 		}
 	}
 	
-	return 1 // Default fallback
+	return 0, false
 }
 
 func (s *AgentService) GetConversation(id uint, userID uint, role string) (*dto.ConversationResponse, error) {
@@ -1023,15 +1121,11 @@ func (s *AgentService) GetConversation(id uint, userID uint, role string) (*dto.
 		return nil, fmt.Errorf("permission denied: unauthorized access to conversation")
 	}
 
-	res := &dto.ConversationResponse{
-		ID: conv.ID, Title: conv.Title, Status: conv.Status, CreatedAt: conv.CreatedAt, UpdatedAt: conv.UpdatedAt,
-	}
+	res := mapConversation(conv)
 	for _, m := range conv.Messages {
-		res.Messages = append(res.Messages, dto.MessageItem{
-			ID: m.ID, Role: m.Role, Content: m.Content, CreatedAt: m.CreatedAt,
-		})
+		res.Messages = append(res.Messages, mapMessage(m))
 	}
-	return res, nil
+	return &res, nil
 }
 
 // =====================================================
@@ -1058,20 +1152,26 @@ func (s *AgentService) ListSkills(status string, query string) ([]dto.SkillRespo
 	return results, nil
 }
 
-func (s *AgentService) ExecuteSkill(user model.User, skill *model.AgentSkill, req *dto.ChatRequest) (*dto.AgentResponseEnvelope, error) {
+func (s *AgentService) ExecuteSkill(ctx context.Context, user model.User, skill *model.AgentSkill, req *dto.ChatRequest) (*dto.AgentResponseEnvelope, error) {
+	return s.executeSkill(ctx, user, skill, req, s.resolveChatContext(user, req.Context))
+}
+
+func (s *AgentService) executeSkill(ctx context.Context, user model.User, skill *model.AgentSkill, req *dto.ChatRequest, page *pageContext) (*dto.AgentResponseEnvelope, error) {
 	if s.llmClient == nil {
 		return nil, fmt.Errorf("LLM service not configured")
 	}
 
-	// 1. 获取所有可用工具并映射为 LLM 工具格式
-	toolDefs := s.toolRegistry.List(user)
-	llmTools := make([]llm.Tool, len(toolDefs))
-	for i, def := range toolDefs {
-		llmTools[i] = s.mapToolToLLM(def)
+	// 1. 获取只读工具并映射为 LLM 工具格式（写操作须由用户直接调用，不交给模型自行决定）
+	var llmTools []llm.Tool
+	for _, def := range s.toolRegistry.List(user) {
+		if entry, ok := s.toolRegistry.GetTool(def.Name); ok && entry.IsReadOnly {
+			llmTools = append(llmTools, s.mapToolToLLM(def))
+		}
 	}
 
-	// 2. 提取上下文：设备 ID
-	eqID := s.extractEquipmentID(req.Message, user)
+	// 2. 提取上下文：设备 ID（消息中未提及时取页面选中的设备）
+	eqID, eqFound := s.extractEquipmentID(req.Message, user)
+	eqID, eqFound = pageEquipment(eqID, eqFound, page)
 	
 	// 3. 准备 SOP 建议
 	var suggestedSteps []any
@@ -1091,7 +1191,11 @@ func (s *AgentService) ExecuteSkill(user model.User, skill *model.AgentSkill, re
 4. workshops/factories: 车间/工厂关系
 
 请根据用户的需求和建议的 SOP，自主决定调用哪些工具。
-收集完证据后，请给出一份专业的中文分析摘要。`, skill.Name, skill.Description, string(stepsJSON))
+工具结果开头会注明其证据编号（如 [E1]），摘要中引用数据时请标注对应编号。
+收集完证据后，请给出一份专业的中文分析摘要。
+
+%s`, skill.Name, skill.Description, string(stepsJSON), prompt.CitationRules)
+	systemPrompt += page.Prompt() + s.equipmentNotesContext(page.equipment()...)
 
 	messages := []llm.Message{
 		{Role: "system", Content: systemPrompt},
@@ -1116,10 +1220,15 @@ func (s *AgentService) ExecuteSkill(user model.User, skill *model.AgentSkill, re
 		"get_retirement_recommendation": {"retirement", "资产退役与投资决策建议"},
 		"search_equipment":            {"equipment_search", "设备搜索结果"},
 		"get_equipment_health":        {"health_analysis", "设备健康分析"},
+		"get_equipment_notes":         {"equipment_note", "设备长期备注"},
 	}
 
+	// 循环结束（达到迭代上限或出错返回）时取消仍在运行的工具调用
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	for i := 0; i < maxIterations; i++ {
-		resp, err := s.llmClient.ChatWithTools(messages, llmTools)
+		resp, err := s.llmFor(user).ChatWithTools(messages, llmTools)
 		if err != nil {
 			log.Printf("[AgentService] LLM ChatWithTools failed in ExecuteSkill: %v", err)
 			return nil, fmt.Errorf("LLM 服务响应失败: %v", err)
@@ -1130,15 +1239,16 @@ func (s *AgentService) ExecuteSkill(user model.User, skill *model.AgentSkill, re
 
 		// 如果没有工具调用，说明 LLM 给出了最终回答
 		if len(resp.ToolCalls) == 0 {
-			return &dto.AgentResponseEnvelope{
-				Success: true, Scenario: "skill_execution", Summary: resp.Content, EvidenceCount: len(evidence),
+			grounding, riskLevel := s.groundSummary(resp.Content, true, false, evidence, "")
+			return withGrounding(&dto.AgentResponseEnvelope{
+				Success: true, Scenario: "skill_execution", Summary: resp.Content, RiskLevel: riskLevel, EvidenceCount: len(evidence),
 				Data: map[string]interface{}{
 					"skill_id":       skill.ID,
 					"skill_name":     skill.Name,
 					"evidence":       evidence,
 					"final_messages": messages, // 可选，用于前端展示过程
 				},
-			}, nil
+			}, grounding, nil), nil
 		}
 
 		// 处理工具调用
@@ -1153,7 +1263,7 @@ func (s *AgentService) ExecuteSkill(user model.User, skill *model.AgentSkill, re
 			}
 
 			// 启发式：如果工具需要 equipment_id 但 LLM 没提供，且我们有识别到的 eqID
-			if _, ok := args["equipment_id"]; !ok && eqID != 1 {
+			if _, ok := args["equipment_id"]; !ok && eqFound {
 				// 检查工具定义中是否包含 equipment_id
 				if tEntry, ok := s.toolRegistry.GetTool(tc.Function.Name); ok {
 					if schema, ok := tEntry.Definition.InputSchema.(map[string]interface{}); ok {
@@ -1166,8 +1276,14 @@ func (s *AgentService) ExecuteSkill(user model.User, skill *model.AgentSkill, re
 				}
 			}
 
-			// 执行工具
-			res, err := s.toolRegistry.Call(tc.Function.Name, user, args, nil)
+			// 执行工具（模型可能给出未提供的工具名，写操作一律拒绝）
+			var res interface{}
+			var err error
+			if entry, ok := s.toolRegistry.GetTool(tc.Function.Name); ok && !entry.IsReadOnly {
+				err = fmt.Errorf("tool %s modifies data and is not available during skill execution", tc.Function.Name)
+			} else {
+				res, err = s.toolRegistry.Call(ctx, tc.Function.Name, user, args, nil)
+			}
 			if err != nil {
 				log.Printf("[AgentService] Tool call failed: %s, err: %v", tc.Function.Name, err)
 				messages = append(messages, llm.Message{
@@ -1176,13 +1292,9 @@ func (s *AgentService) ExecuteSkill(user model.User, skill *model.AgentSkill, re
 				continue
 			}
 
-			// 将工具结果添加到对话历史
-			resJSON, _ := json.Marshal(res)
-			messages = append(messages, llm.Message{
-				Role: "tool", ToolCallID: tc.ID, Content: string(resJSON),
-			})
-
 			// 收集证据 (只记录只读工具)
+			resJSON, _ := json.Marshal(res)
+			firstEvidence := len(evidence)
 			if tEntry, ok := s.toolRegistry.GetTool(tc.Function.Name); ok && tEntry.IsReadOnly {
 				if tc.Function.Name == "search_manual_knowledge" {
 					if evs, ok := res.([]dto.EvidenceItem); ok {
@@ -1192,6 +1304,10 @@ func (s *AgentService) ExecuteSkill(user model.User, skill *model.AgentSkill, re
 					if auditData, ok := res.(*dto.RepairAuditData); ok {
 						evidence = append(evidence, auditData.Evidence...)
 					}
+				} else if tc.Function.Name == "analyze_root_cause" {
+					if rcData, ok := res.(*dto.RootCauseData); ok {
+						evidence = append(evidence, rcData.Evidence...)
+					}
 				} else {
 					title := fmt.Sprintf("工具调用: %s", tc.Function.Name)
 					eType := "tool_result"
@@ -1207,6 +1323,19 @@ func (s *AgentService) ExecuteSkill(user model.User, skill *model.AgentSkill, re
 					})
 				}
 			}
+
+			// 将工具结果添加到对话历史，并注明新增证据编号
+			content := string(resJSON)
+			if len(evidence) > firstEvidence {
+				markers := make([]string, 0, len(evidence)-firstEvidence)
+				for idx := firstEvidence + 1; idx <= len(evidence); idx++ {
+					markers = append(markers, citation.Marker(idx))
+				}
+				content = fmt.Sprintf("证据编号: [%s]\n%s", strings.Join(markers, ", "), content)
+			}
+			messages = append(messages, llm.Message{
+				Role: "tool", ToolCallID: tc.ID, Content: content,
+			})
 		}
 	}
 
@@ -1245,37 +1374,45 @@ func (s *AgentService) UpdateSkill(id uint, req *dto.UpdateSkillRequest) (*dto.S
 // Phase 2: Proactive Notification Logic
 // =====================================================
 
-func (s *AgentService) Subscribe(userID uint, pushType string, enabled bool, scope any, webhookURL string) error {
+// Subscribe 创建或更新推送订阅；首次创建（或旧订阅尚无密钥）时生成签名密钥，
+// 返回的 secret 仅在此时非空
+func (s *AgentService) Subscribe(userID uint, pushType string, enabled bool, scope any, webhookURL string) (*model.AgentPushSubscription, string, error) {
+	if err := s.validateWebhookURL(context.Background(), webhookURL); err != nil {
+		return nil, "", err
+	}
 	scopeJSON, _ := json.Marshal(scope)
+	if _, err := pushfilter.Parse(string(scopeJSON)); err != nil {
+		return nil, "", err
+	}
 
-	// Check if already exists
-	db := database.GetDB()
-	var sub model.AgentPushSubscription
-	err := db.Where("user_id = ? AND push_type = ?", userID, pushType).First(&sub).Error
-
-	if err == nil {
-		// Update
-		sub.Enabled = enabled
-		sub.Scope = string(scopeJSON)
-		sub.WebhookURL = webhookURL
-		return db.Save(&sub).Error
+	sub, err := s.repo.GetPushSubscription(userID, pushType)
+	if err != nil || sub == nil {
+		sub = &model.AgentPushSubscription{UserID: userID, PushType: pushType, Enabled: true}
 	}
+	sub.Scope = string(scopeJSON)
+	sub.WebhookURL = webhookURL
+	setSubscriptionEnabled(sub, enabled, time.Now())
 
-	// Create
-	sub = model.AgentPushSubscription{
-		UserID: userID, PushType: pushType, Enabled: enabled, Scope: string(scopeJSON),
-		WebhookURL: webhookURL,
+	var secret string
+	if sub.Secret == "" {
+		if secret, err = webhook.GenerateSecret(); err != nil {
+			return nil, "", err
+		}
+		sub.Secret = secret
 	}
-	return db.Create(&sub).Error
+	if err := s.repo.CreatePushSubscription(sub); err != nil {
+		return nil, "", err
+	}
+	return sub, secret, nil
 }
 
 func (s *AgentService) ListSubscriptions(userID uint) ([]model.AgentPushSubscription, error) {
-	db := database.GetDB()
-	var subs []model.AgentPushSubscription
-	err := db.Where("user_id = ?", userID).Find(&subs).Error
-	return subs, err
+	return s.repo.ListPushSubscriptions(userID)
 }
 
+// defaultMaxRULDays 订阅未设置 max_rul_days 时，剩余寿命不足一周才推送预警
+const defaultMaxRULDays = 6
+
 func (s *AgentService) NotifyEvent(eventType string, targetID uint, context map[string]interface{}) {
 	// 1. 查找所有启用且类型匹配的订阅
 	var subs []model.AgentPushSubscription
@@ -1295,17 +1432,25 @@ func (s *AgentService) NotifyEvent(eventType string, targetID uint, context map[
 		if err := database.GetDB().Joins("JOIN workshops ON workshops.id = equipment.workshop_id").First(&equipment, targetID).Error; err != nil {
 			continue
 		}
+		var workshop model.Workshop
+		database.GetDB().First(&workshop, equipment.WorkshopID)
 		if user.Role != "admin" && user.FactoryID != nil {
-			var workshop model.Workshop
-			database.GetDB().First(&workshop, equipment.WorkshopID)
 			if workshop.FactoryID != *user.FactoryID {
 				continue // 跨工厂，跳过此订阅者
 			}
 		}
 
 		// 3. 在用户上下文中执行分析 (确保 RUL/TCO 等逻辑应用了正确的工厂参数，且报错能被捕捉)
+		filter := subscriptionFilter(&sub)
 		prediction, err := s.predictiveAnalyzer.PredictRUL(targetID, user)
-		if err != nil || prediction.EstimatedRULDays >= 7 {
+		if err != nil || prediction.EstimatedRULDays > filter.MaxRULDays(defaultMaxRULDays) {
+			continue
+		}
+		rul := prediction.EstimatedRULDays
+		if !filter.Match(pushfilter.Facts{
+			FactoryID: &workshop.FactoryID, WorkshopID: equipment.WorkshopID, EquipmentTypeID: equipment.TypeID,
+			EquipmentID: equipment.ID, Severity: "high", RULDays: &rul, At: time.Now(),
+		}) {
 			continue
 		}
 
@@ -1326,50 +1471,76 @@ func (s *AgentService) NotifyEvent(eventType string, targetID uint, context map[
 }
 
 func (s *AgentService) deliverPush(sub model.AgentPushSubscription, artifact *model.AgentArtifact) {
-	payload := map[string]interface{}{
-		"event":       "agent_alert",
-		"artifact_id": artifact.ID,
-		"title":       artifact.Title,
-		"summary":     artifact.Summary,
-		"risk_level":  artifact.RiskLevel,
-		"timestamp":   time.Now().Unix(),
-	}
-	body, _ := json.Marshal(payload)
-	
-	logRecord := &model.AgentPushLog{
-		SubscriptionID: sub.ID,
-		ArtifactID:     artifact.ID,
-		Payload:        string(body),
-		Status:         "pending",
-	}
-	database.GetDB().Create(logRecord)
-
-	if sub.WebhookURL != "" {
-		req, _ := http.NewRequest("POST", sub.WebhookURL, strings.NewReader(string(body)))
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("X-EMS-Event", "agent_alert")
-		// Future: Sign payload with sub.Secret
-
-		client := &http.Client{Timeout: 10 * time.Second}
-		resp, err := client.Do(req)
-		
-		now := time.Now()
-		if err != nil {
-			logRecord.Status = "failed"
-			logRecord.ErrorMessage = err.Error()
-		} else {
-			defer resp.Body.Close()
-			if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-				logRecord.Status = "success"
-				logRecord.DeliveredAt = &now
-			} else {
-				logRecord.Status = "failed"
-				logRecord.ErrorMessage = fmt.Sprintf("HTTP %d", resp.StatusCode)
-			}
+	body, _ := json.Marshal(AgentAlertPush{
+		Event: pushEventAgentAlert, Version: PushSchemaVersion, ArtifactID: artifact.ID,
+		Title: artifact.Title, Summary: artifact.Summary, RiskLevel: artifact.RiskLevel, Timestamp: time.Now().Unix(),
+	})
+	if err := s.postPush(sub, pushEventAgentAlert, artifact.ID, body); err != nil {
+		log.Printf("[AgentService] Failed to enqueue push for subscription %d: %v", sub.ID, err)
+	}
+}
+
+// postPush 将负载写入 Webhook 投递队列，由后台分发器签名发送并按退避策略重试
+func (s *AgentService) postPush(sub model.AgentPushSubscription, eventName string, artifactID uint, body []byte) error {
+	if sub.WebhookURL == "" {
+		return nil
+	}
+	_, err := s.webhooks.Enqueue(sub, eventName, artifactID, body)
+	return err
+}
+
+// PublishArtifact 为后台生成的结果（如定时简报）落库会话、产物与证据链接，归属于 user
+func (s *AgentService) PublishArtifact(user model.User, scenario string, factoryID *uint, artifact *model.AgentArtifact, evidence []dto.EvidenceItem) error {
+	session := &model.AgentSession{
+		UserID: user.ID, Scenario: scenario, FactoryID: factoryID,
+		Language: "zh-CN", TraceID: trace.GenerateTraceID(), Status: "completed",
+	}
+	if err := s.repo.CreateSession(session); err != nil {
+		return err
+	}
+
+	artifact.SessionID = session.ID
+	if err := s.repo.CreateArtifact(artifact); err != nil {
+		return err
+	}
+
+	if len(evidence) > 0 {
+		links := make([]model.AgentEvidenceLink, 0, len(evidence))
+		for _, ev := range evidence {
+			links = append(links, model.AgentEvidenceLink{
+				ArtifactID: artifact.ID, EvidenceType: ev.EvidenceType,
+				SourceTable: ev.SourceTable, SourceID: ev.SourceID, Excerpt: ev.Excerpt, Score: ev.Score,
+			})
+		}
+		if err := s.repo.CreateEvidenceLinks(links); err != nil {
+			log.Printf("[AgentService] Failed to create evidence links for artifact %d: %v", artifact.ID, err)
 		}
-		database.GetDB().Save(logRecord)
 	}
+	return nil
 }
+
+// DeliverToSubscribers 将产物投递到用户在 pushType 下已启用的订阅
+func (s *AgentService) DeliverToSubscribers(userID uint, pushType string, artifact *model.AgentArtifact) int {
+	sub, err := s.repo.GetPushSubscription(userID, pushType)
+	if err != nil || sub == nil || !sub.Enabled {
+		return 0
+	}
+	facts := pushfilter.Facts{Severity: artifact.RiskLevel, At: time.Now()}
+	if session, err := s.repo.GetSessionByID(artifact.SessionID); err == nil && session != nil {
+		facts.FactoryID = session.FactoryID
+	}
+	if !subscriptionFilter(sub).Match(facts) {
+		return 0
+	}
+	go s.deliverPush(*sub, artifact)
+	return 1
+}
+
+// PredictRUL 以用户权限预测设备剩余寿命，供后台任务复用
+func (s *AgentService) PredictRUL(equipmentID uint, user model.User) (*dto.RULPrediction, error) {
+	return s.predictiveAnalyzer.PredictRUL(equipmentID, user)
+}
+
 func (s *AgentService) GetEquipmentPrediction(equipmentID uint, user model.User) (map[string]interface{}, error) {
 	// Web端调用，使用真实用户权限进行隔离校验
 	rul, _ := s.predictiveAnalyzer.PredictRUL(equipmentID, user)
@@ -1412,79 +1583,124 @@ func (s *AgentService) mapToolToLLM(toolDef dto.ToolDefinition) llm.Tool {
 
 func (s *AgentService) logUsage(sessionID, userID uint, scenario string, startTime time.Time) {
 	duration := time.Since(startTime).Milliseconds()
-	modelName := config.Cfg.LLM.Model
-	if modelName == "" { modelName = "rule-based" }
 	usage := &model.AgentUsage{
-		SessionID: sessionID, UserID: userID, Scenario: scenario, Model: modelName, ResponseTimeMs: duration,
+		SessionID: sessionID, UserID: userID, Scenario: scenario, Model: currentModelName(), ResponseTimeMs: duration,
 	}
 	if err := s.repo.CreateUsage(usage); err != nil {
 		log.Printf("[AgentService] Failed to create usage record: %v", err)
 	}
 }
 
-func (s *AgentService) ReflectAndLearn(convID uint, userID uint) {
+// llmFor 按用户所属工厂的脱敏策略返回 LLM 客户端（财务字段是否脱敏由工厂策略决定）
+func (s *AgentService) llmFor(user model.User) llm.LLMClient {
+	if rc, ok := s.llmClient.(*llm.RedactingClient); ok {
+		return rc.WithFinancial(config.Cfg.LLM.Redaction.FinancialFor(user.FactoryID))
+	}
+	return s.llmClient
+}
+
+// loadUserNames 加载所有用户姓名，供脱敏器识别人员信息
+func loadUserNames() []string {
+	var names []string
+	if config.Cfg.Storage.Mode == "memory" {
+		for _, u := range memory.GetStore().Users {
+			names = append(names, u.Name)
+		}
+		return names
+	}
+	if err := database.GetDB().Model(&model.User{}).Pluck("name", &names).Error; err != nil {
+		log.Printf("[AgentService] Failed to load user names for redaction: %v", err)
+	}
+	return names
+}
+
+func (s *AgentService) ReflectAndLearn(convID uint, user model.User) {
 	defer func() {
 		if r := recover(); r != nil {
-			log.Printf("[AgentService] PANIC in ReflectAndLearn (conv=%d, user=%d): %v", convID, userID, r)
+			log.Printf("[AgentService] PANIC in ReflectAndLearn (conv=%d, user=%d): %v", convID, user.ID, r)
 		}
 	}()
 
 	if s.llmClient == nil { return }
 	history, err := s.repo.GetMessagesByConversationID(convID)
 	if err != nil || len(history) < 2 { return }
-	s.asyncExtractKnowledge(history, convID)
-	s.asyncExtractSkill(history, convID)
-	s.asyncCollectExperience(history, userID)
+	s.asyncExtractKnowledge(history, convID, user)
+	s.asyncExtractSkill(history, convID, user)
+	s.asyncExtractEquipmentNotes(history, convID, user)
+	s.asyncCollectExperience(history, user.ID)
 }
 
 func (s *AgentService) asyncCollectExperience(history []model.AgentMessage, userID uint) { }
 
-func (s *AgentService) asyncExtractKnowledge(history []model.AgentMessage, convID uint) {
+// extractedKnowledge 对话中提炼的知识草稿；不值得提取时模型返回 {}
+type extractedKnowledge struct {
+	Title      string                 `json:"title" binding:"max=100"`
+	Type       string                 `json:"type"`
+	Summary    string                 `json:"summary"`
+	Details    map[string]interface{} `json:"details"`
+	Confidence float64                `json:"confidence" binding:"min=0,max=1"`
+}
+
+// extractedSkill 对话中提炼的排查技能草稿
+type extractedSkill struct {
+	Name                string   `json:"name" binding:"max=100"`
+	Description         string   `json:"description"`
+	ApplicableScenarios []string `json:"applicable_scenarios"`
+	Steps               []struct {
+		Step   int    `json:"step"`
+		Action string `json:"action" binding:"required"`
+		Tool   string `json:"tool"`
+	} `json:"steps"`
+}
+
+var (
+	knowledgeSchema = tool.SchemaOf(extractedKnowledge{})
+	skillSchema     = tool.SchemaOf(extractedSkill{})
+)
+
+// asyncExtractKnowledge 对话历史按对话所属用户的脱敏策略发送
+func (s *AgentService) asyncExtractKnowledge(history []model.AgentMessage, convID uint, user model.User) {
 	p := s.promptTool.BuildKnowledgeExtractionPrompt(history)
-	resp, err := s.llmClient.ChatCompletion([]llm.Message{
+	var extracted extractedKnowledge
+	err := llm.Structured(s.llmFor(user), []llm.Message{
 		{Role: "system", Content: "你是一个专业的工业设备知识专家。"},
 		{Role: "user", Content: p},
-	})
+	}, llm.StructuredSpec{Name: "knowledge", Schema: knowledgeSchema}, &extracted)
 	if err != nil {
-		log.Printf("[AgentService] LLM request failed in asyncExtractKnowledge: %v", err)
+		log.Printf("[AgentService] Knowledge extraction failed for conversation %d: %v", convID, err)
 		return
 	}
-	if resp == "" { return }
-	var extracted struct {
-		Title string `json:"title"`; Type string `json:"type"`; Summary string `json:"summary"`; Details any `json:"details"`; Confidence float64 `json:"confidence"`
+	if extracted.Title == "" { return }
+	detailsJSON, _ := json.Marshal(extracted.Details)
+	knowledge := &model.AgentKnowledge{
+		ID: fmt.Sprintf("k_%d_%d", convID, time.Now().Unix()), Title: extracted.Title, Type: extracted.Type, Summary: extracted.Summary,
+		Details: string(detailsJSON), Confidence: extracted.Confidence, Status: "draft", CreatedBy: fmt.Sprintf("agent:conv_%d", convID),
 	}
-	if err := json.Unmarshal([]byte(resp), &extracted); err == nil && extracted.Title != "" {
-		detailsJSON, _ := json.Marshal(extracted.Details)
-		knowledge := &model.AgentKnowledge{
-			ID: fmt.Sprintf("k_%d_%d", convID, time.Now().Unix()), Title: extracted.Title, Type: extracted.Type, Summary: extracted.Summary,
-			Details: string(detailsJSON), Confidence: extracted.Confidence, Status: "draft", CreatedBy: fmt.Sprintf("agent:conv_%d", convID),
-		}
-		_ = s.repo.CreateKnowledge(knowledge)
+	if err := s.repo.CreateKnowledge(knowledge); err != nil {
+		log.Printf("[AgentService] Failed to save extracted knowledge: %v", err)
 	}
 }
 
-func (s *AgentService) asyncExtractSkill(history []model.AgentMessage, convID uint) {
+func (s *AgentService) asyncExtractSkill(history []model.AgentMessage, convID uint, user model.User) {
 	p := s.promptTool.BuildSkillExtractionPrompt(history)
-	resp, err := s.llmClient.ChatCompletion([]llm.Message{
+	var extracted extractedSkill
+	err := llm.Structured(s.llmFor(user), []llm.Message{
 		{Role: "system", Content: "你是一个资深的工业诊断专家。"},
 		{Role: "user", Content: p},
-	})
+	}, llm.StructuredSpec{Name: "skill", Schema: skillSchema}, &extracted)
 	if err != nil {
-		log.Printf("[AgentService] LLM request failed in asyncExtractSkill: %v", err)
+		log.Printf("[AgentService] Skill extraction failed for conversation %d: %v", convID, err)
 		return
 	}
-	if resp == "" { return }
-	var extracted struct {
-		Name string `json:"name"`; Description string `json:"description"`; ApplicableScenarios []string `json:"applicable_scenarios"`; Steps []any `json:"steps"`
+	if extracted.Name == "" { return }
+	appSce, _ := json.Marshal(extracted.ApplicableScenarios)
+	steps, _ := json.Marshal(extracted.Steps)
+	skill := &model.AgentSkill{
+		Name: extracted.Name, Description: extracted.Description, ApplicableScenarios: string(appSce), Steps: string(steps),
+		Status: "draft",
 	}
-	if err := json.Unmarshal([]byte(resp), &extracted); err == nil && extracted.Name != "" {
-		appSce, _ := json.Marshal(extracted.ApplicableScenarios)
-		steps, _ := json.Marshal(extracted.Steps)
-		skill := &model.AgentSkill{
-			Name: extracted.Name, Description: extracted.Description, ApplicableScenarios: string(appSce), Steps: string(steps),
-			Status: "draft",
-		}
-		skill.CreatedBy = fmt.Sprintf("agent:conv_%d", convID)
-		_ = s.repo.CreateSkill(skill)
+	skill.CreatedBy = fmt.Sprintf("agent:conv_%d", convID)
+	if err := s.repo.CreateSkill(skill); err != nil {
+		log.Printf("[AgentService] Failed to save extracted skill: %v", err)
 	}
 }
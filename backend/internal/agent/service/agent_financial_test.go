@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"testing"
 	"github.com/ems/backend/internal/model"
 	"github.com/ems/backend/pkg/config"
@@ -32,7 +33,7 @@ func TestAgentService_HandleGetEquipmentFinancials(t *testing.T) {
 	svc := NewAgentService()
 	args := map[string]interface{}{"equipment_id": 1001}
 	
-	result, err := svc.handleGetEquipmentFinancials(user, args)
+	result, err := svc.toolRegistry.Call(context.Background(), "get_equipment_financials", user, args, nil)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -89,7 +90,7 @@ func TestAgentService_HandleGetRepairCosts(t *testing.T) {
 	svc := NewAgentService()
 	args := map[string]interface{}{"equipment_id": float64(eqID)}
 	
-	result, err := svc.handleGetRepairCosts(user, args)
+	result, err := svc.toolRegistry.Call(context.Background(), "get_repair_costs", user, args, nil)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
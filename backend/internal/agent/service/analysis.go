@@ -0,0 +1,69 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ems/backend/internal/agent/dto"
+	"github.com/ems/backend/internal/agent/tool"
+	"github.com/ems/backend/internal/model"
+	"github.com/ems/backend/pkg/llm"
+)
+
+var analysisReportSchema = tool.SchemaOf(dto.AnalysisReport{})
+
+var riskRank = map[string]int{"low": 1, "medium": 2, "high": 3, "critical": 4}
+
+// structuredAnalysis 要求模型按 AnalysisReport 的 schema 输出，校验失败时自动带着问题重试
+func (s *AgentService) structuredAnalysis(user model.User, prompt string, rules []dto.AnalysisFinding) (*dto.AnalysisReport, error) {
+	if len(rules) > 0 {
+		confirmed, _ := json.Marshal(rules)
+		prompt += fmt.Sprintf("\n\n### 规则引擎已确认的发现\n%s\n以上发现会原样保留在报告中，findings 只需补充其他发现，risk_level 不得低于其中最高的风险等级。", confirmed)
+	}
+	var report dto.AnalysisReport
+	err := llm.Structured(s.llmFor(user), []llm.Message{
+		{Role: "system", Content: "你是一个顶级的工业资产战略分析师。"},
+		{Role: "user", Content: prompt},
+	}, llm.StructuredSpec{Name: "analysis_report", Description: "提交分析报告", Schema: analysisReportSchema}, &report)
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// mergeAnalysisReport 规则发现在前、模型补充在后；越界的证据编号被丢弃，风险等级取两者较高者
+func mergeAnalysisReport(data *dto.AnalyzeData, report *dto.AnalysisReport) {
+	seen := map[string]bool{}
+	for _, f := range data.Findings {
+		seen[f.Title] = true
+	}
+	for _, f := range report.Findings {
+		if seen[f.Title] {
+			continue
+		}
+		seen[f.Title] = true
+		refs := make([]int, 0, len(f.EvidenceRefs))
+		for _, ref := range f.EvidenceRefs {
+			if ref >= 1 && ref <= len(data.Evidence) {
+				refs = append(refs, ref)
+			}
+		}
+		f.EvidenceRefs, f.Source = refs, "llm"
+		data.Findings = append(data.Findings, f)
+	}
+	data.RecommendedActions = append(data.RecommendedActions, report.RecommendedActions...)
+	data.RiskLevel = report.RiskLevel
+	if rule := highestRisk(data.Findings); riskRank[rule] > riskRank[data.RiskLevel] {
+		data.RiskLevel = rule
+	}
+}
+
+func highestRisk(findings []dto.AnalysisFinding) string {
+	level := "low"
+	for _, f := range findings {
+		if riskRank[f.RiskLevel] > riskRank[level] {
+			level = f.RiskLevel
+		}
+	}
+	return level
+}
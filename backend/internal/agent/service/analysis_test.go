@@ -0,0 +1,37 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/ems/backend/internal/agent/dto"
+)
+
+func TestMergeAnalysisReport(t *testing.T) {
+	data := dto.AnalyzeData{
+		Evidence: []dto.EvidenceItem{{Title: "RUL 预测"}},
+		Findings: []dto.AnalysisFinding{{Title: "设备剩余寿命仅剩 6 天，存在停机风险", RiskLevel: "high", EvidenceRefs: []int{1}, Source: "rule"}},
+	}
+	report := &dto.AnalysisReport{
+		Summary:   "主轴轴承磨损加剧 [E1]",
+		RiskLevel: "medium",
+		Findings: []dto.AnalysisFinding{
+			{Title: "设备剩余寿命仅剩 6 天，存在停机风险", RiskLevel: "medium"},
+			{Title: "润滑周期偏长", RiskLevel: "medium", EvidenceRefs: []int{1, 4}, Source: "rule"},
+		},
+		RecommendedActions: []dto.RecommendedAction{{Action: "本周内更换主轴轴承", Priority: "high"}},
+	}
+
+	mergeAnalysisReport(&data, report)
+	if len(data.Findings) != 2 || data.Findings[0].Source != "rule" || data.Findings[1].Source != "llm" {
+		t.Fatalf("Expected the rule finding followed by one model finding, got %+v", data.Findings)
+	}
+	if refs := data.Findings[1].EvidenceRefs; len(refs) != 1 || refs[0] != 1 {
+		t.Errorf("Expected out-of-range evidence refs to be dropped, got %v", refs)
+	}
+	if data.RiskLevel != "high" {
+		t.Errorf("Expected the model not to lower the rule risk level, got %s", data.RiskLevel)
+	}
+	if len(data.RecommendedActions) != 1 {
+		t.Errorf("Expected recommended actions to be copied, got %+v", data.RecommendedActions)
+	}
+}
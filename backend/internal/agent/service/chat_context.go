@@ -0,0 +1,283 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ems/backend/internal/agent/dto"
+	"github.com/ems/backend/internal/model"
+	"github.com/ems/backend/pkg/config"
+	"github.com/ems/backend/pkg/database"
+	"github.com/ems/backend/pkg/memory"
+)
+
+// pageContext 按权限解析后的页面上下文
+type pageContext struct {
+	Route        string
+	Filters      map[string]string
+	Entities     []map[string]interface{}
+	EquipmentIDs []uint // 选中对象涉及的设备，用于加载设备档案与长期备注
+	Skipped      int    // 不存在或无权访问而被忽略的对象数
+}
+
+// resolveChatContext 解析前端传入的页面上下文；不存在或无权访问的对象静默忽略，不向模型透露其内容
+func (s *AgentService) resolveChatContext(user model.User, c *dto.ChatContext) *pageContext {
+	if c == nil {
+		return nil
+	}
+	pc := &pageContext{Route: truncateRunes(c.Route, 200), Filters: map[string]string{}}
+	for k, v := range c.Filters {
+		pc.Filters[truncateRunes(k, 50)] = truncateRunes(v, 100)
+	}
+	seen := map[uint]bool{}
+	addEquipment := func(id uint) {
+		if id > 0 && !seen[id] {
+			seen[id] = true
+			pc.EquipmentIDs = append(pc.EquipmentIDs, id)
+		}
+	}
+	add := func(entity map[string]interface{}, equipmentID uint, err error) {
+		if err != nil {
+			pc.Skipped++
+			return
+		}
+		pc.Entities = append(pc.Entities, entity)
+		addEquipment(equipmentID)
+	}
+
+	for _, id := range c.EquipmentIDs {
+		e, err := s.contextEquipment(user, id)
+		add(e, id, err)
+	}
+	for _, id := range c.RepairOrderIDs {
+		e, eqID, err := s.contextRepairOrder(user, id)
+		add(e, eqID, err)
+	}
+	for _, id := range c.MaintenanceTaskIDs {
+		e, eqID, err := s.contextMaintenanceTask(user, id)
+		add(e, eqID, err)
+	}
+	for _, id := range c.SparePartIDs {
+		e, err := s.contextSparePart(user, id)
+		add(e, 0, err)
+	}
+	return pc
+}
+
+// Prompt 渲染为系统提示词中的结构化段落
+func (pc *pageContext) Prompt() string {
+	if pc == nil || (pc.Route == "" && len(pc.Filters) == 0 && len(pc.Entities) == 0 && pc.Skipped == 0) {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\n### 用户当前页面上下文\n")
+	if pc.Route != "" {
+		fmt.Fprintf(&b, "页面: %s\n", pc.Route)
+	}
+	if len(pc.Filters) > 0 {
+		filters, _ := json.Marshal(pc.Filters)
+		fmt.Fprintf(&b, "筛选条件: %s\n", filters)
+	}
+	if len(pc.Entities) > 0 {
+		b.WriteString("选中的对象:\n")
+		for _, e := range pc.Entities {
+			data, _ := json.Marshal(e)
+			fmt.Fprintf(&b, "- %s\n", data)
+		}
+		b.WriteString("用户用“这个”“这台”“这张单”等指代且未说明对象时，指的是上面选中的对象。\n")
+	}
+	if pc.Skipped > 0 {
+		fmt.Fprintf(&b, "另有 %d 个选中对象不存在或无权访问，已忽略。\n", pc.Skipped)
+	}
+	return b.String()
+}
+
+func (s *AgentService) contextEquipment(user model.User, id uint) (map[string]interface{}, error) {
+	if _, _, err := s.equipmentScope(user, id); err != nil {
+		return nil, err
+	}
+	var eq model.Equipment
+	workshop := ""
+	if config.Cfg.Storage.Mode == "memory" {
+		store := memory.GetStore()
+		eq = *store.Equipment[id]
+		if w, ok := store.Workshops[eq.WorkshopID]; ok {
+			workshop = w.Name
+		}
+	} else {
+		if err := database.GetDB().Preload("Workshop").First(&eq, id).Error; err != nil {
+			return nil, err
+		}
+		if eq.Workshop != nil {
+			workshop = eq.Workshop.Name
+		}
+	}
+	return map[string]interface{}{
+		"kind": "equipment", "id": eq.ID, "code": eq.Code, "name": eq.Name, "model": eq.Model,
+		"status": eq.Status, "workshop": workshop,
+	}, nil
+}
+
+func (s *AgentService) contextRepairOrder(user model.User, id uint) (map[string]interface{}, uint, error) {
+	var order model.RepairOrder
+	assignee := ""
+	if config.Cfg.Storage.Mode == "memory" {
+		store := memory.GetStore()
+		o, ok := store.RepairOrders[id]
+		if !ok {
+			return nil, 0, fmt.Errorf("repair order not found")
+		}
+		order = *o
+		if order.AssignedTo != nil {
+			if u, ok := store.Users[*order.AssignedTo]; ok {
+				assignee = u.Name
+			}
+		}
+	} else {
+		if err := database.GetDB().Preload("Assignee").First(&order, id).Error; err != nil {
+			return nil, 0, err
+		}
+		if order.Assignee != nil {
+			assignee = order.Assignee.Name
+		}
+	}
+	code, _, err := s.equipmentScope(user, order.EquipmentID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return map[string]interface{}{
+		"kind": "repair_order", "id": order.ID, "equipment_id": order.EquipmentID, "equipment_code": code,
+		"fault_description": order.FaultDescription, "fault_code": order.FaultCode, "priority": order.Priority,
+		"status": order.Status, "assignee": assignee, "created_at": order.CreatedAt.Format("2006-01-02 15:04"),
+		"solution": truncateRunes(order.Solution, 300),
+	}, order.EquipmentID, nil
+}
+
+func (s *AgentService) contextMaintenanceTask(user model.User, id uint) (map[string]interface{}, uint, error) {
+	var task model.MaintenanceTask
+	var plan *model.MaintenancePlan
+	assignee := ""
+	if config.Cfg.Storage.Mode == "memory" {
+		store := memory.GetStore()
+		t, ok := store.MaintenanceTasks[id]
+		if !ok {
+			return nil, 0, fmt.Errorf("maintenance task not found")
+		}
+		task = *t
+		plan = store.MaintenancePlans[task.PlanID]
+		if u, ok := store.Users[task.AssignedTo]; ok {
+			assignee = u.Name
+		}
+	} else {
+		if err := database.GetDB().Preload("Plan").Preload("Assignee").First(&task, id).Error; err != nil {
+			return nil, 0, err
+		}
+		plan = task.Plan
+		if task.Assignee != nil {
+			assignee = task.Assignee.Name
+		}
+	}
+	code, _, err := s.equipmentScope(user, task.EquipmentID)
+	if err != nil {
+		return nil, 0, err
+	}
+	entity := map[string]interface{}{
+		"kind": "maintenance_task", "id": task.ID, "equipment_id": task.EquipmentID, "equipment_code": code,
+		"scheduled_date": task.ScheduledDate, "due_date": task.DueDate, "status": task.Status,
+		"assignee": assignee, "remark": truncateRunes(task.Remark, 300),
+	}
+	if plan != nil {
+		entity["plan"] = plan.Name
+		entity["cycle_days"] = plan.CycleDays
+		entity["flexible_days"] = plan.FlexibleDays
+	}
+	if task.StartedAt != nil {
+		entity["started_at"] = task.StartedAt.Format("2006-01-02 15:04")
+	}
+	if task.CompletedAt != nil {
+		entity["completed_at"] = task.CompletedAt.Format("2006-01-02 15:04")
+	}
+	// 未完成且已过截止日的任务给出逾期天数，便于回答“为什么逾期”
+	if due, err := time.ParseInLocation("2006-01-02", task.DueDate, time.Local); err == nil && task.CompletedAt == nil {
+		if days := int(time.Since(due).Hours() / 24); days > 0 {
+			entity["overdue_days"] = days
+		}
+	}
+	return entity, task.EquipmentID, nil
+}
+
+func (s *AgentService) contextSparePart(user model.User, id uint) (map[string]interface{}, error) {
+	scope := feedbackScope(user)
+	var part model.SparePart
+	stock := map[uint]int{}
+	if config.Cfg.Storage.Mode == "memory" {
+		store := memory.GetStore()
+		p, ok := store.SpareParts[id]
+		if !ok {
+			return nil, fmt.Errorf("spare part not found")
+		}
+		part = *p
+		for _, inv := range store.SparePartInventory {
+			if inv.SparePartID == id {
+				stock[inv.FactoryID] += inv.Quantity
+			}
+		}
+	} else {
+		db := database.GetDB()
+		if err := db.First(&part, id).Error; err != nil {
+			return nil, err
+		}
+		var invs []model.SparePartInventory
+		if err := db.Where("spare_part_id = ?", id).Find(&invs).Error; err != nil {
+			return nil, err
+		}
+		for _, inv := range invs {
+			stock[inv.FactoryID] += inv.Quantity
+		}
+	}
+	if scope != nil && part.FactoryID != nil && *part.FactoryID != *scope {
+		return nil, fmt.Errorf("%w: spare part belongs to another factory", ErrPermissionDenied)
+	}
+	entity := map[string]interface{}{
+		"kind": "spare_part", "id": part.ID, "code": part.Code, "name": part.Name,
+		"specification": part.Specification, "unit": part.Unit, "safety_stock": part.SafetyStock,
+	}
+	if scope != nil {
+		entity["stock"] = stock[*scope]
+	} else {
+		total := 0
+		for _, q := range stock {
+			total += q
+		}
+		entity["stock"] = total
+	}
+	return entity, nil
+}
+
+func (pc *pageContext) equipment() []uint {
+	if pc == nil {
+		return nil
+	}
+	return pc.EquipmentIDs
+}
+
+// otherEquipment 页面选中的、除 exclude 以外的设备
+func (pc *pageContext) otherEquipment(exclude uint) []uint {
+	var ids []uint
+	for _, id := range pc.equipment() {
+		if id != exclude {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// pageEquipment 消息中未识别到设备时，使用页面上唯一选中的设备
+func pageEquipment(extracted uint, found bool, pc *pageContext) (uint, bool) {
+	if ids := pc.equipment(); !found && len(ids) == 1 {
+		return ids[0], true
+	}
+	return extracted, found
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ems/backend/internal/agent/dto"
+	"github.com/ems/backend/internal/model"
+	"github.com/ems/backend/pkg/config"
+	"github.com/ems/backend/pkg/memory"
+)
+
+func TestAgentService_ResolveChatContext(t *testing.T) {
+	config.Cfg = &config.Config{Storage: config.StorageConfig{Mode: "memory"}}
+	svc := NewAgentService()
+	store := memory.GetStore()
+
+	factoryA, factoryB := store.NextID(), store.NextID()
+	wsA, wsB := store.NextID(), store.NextID()
+	store.Workshops[wsA] = &model.Workshop{BaseModel: model.BaseModel{ID: wsA}, FactoryID: factoryA, Name: "装配车间"}
+	store.Workshops[wsB] = &model.Workshop{BaseModel: model.BaseModel{ID: wsB}, FactoryID: factoryB, Name: "焊接车间"}
+	eqA, eqB := store.NextID(), store.NextID()
+	store.Equipment[eqA] = &model.Equipment{BaseModel: model.BaseModel{ID: eqA}, Code: "CNC-07", WorkshopID: wsA}
+	store.Equipment[eqB] = &model.Equipment{BaseModel: model.BaseModel{ID: eqB}, Code: "WELD-02", WorkshopID: wsB}
+
+	planID, taskID, orderID := store.NextID(), store.NextID(), store.NextID()
+	store.MaintenancePlans[planID] = &model.MaintenancePlan{BaseModel: model.BaseModel{ID: planID}, Name: "月度保养", CycleDays: 30}
+	due := time.Now().AddDate(0, 0, -5).Format("2006-01-02")
+	store.MaintenanceTasks[taskID] = &model.MaintenanceTask{BaseModel: model.BaseModel{ID: taskID},
+		EquipmentID: eqA, Equipment: store.Equipment[eqA], PlanID: planID, ScheduledDate: due, DueDate: due, Status: "overdue", Remark: "等待备件"}
+	store.RepairOrders[orderID] = &model.RepairOrder{BaseModel: model.BaseModel{ID: orderID}, EquipmentID: eqB, FaultDescription: "焊枪漏气"}
+
+	user := model.User{BaseModel: model.BaseModel{ID: 701}, Role: model.RoleMaintenance, FactoryID: &factoryA}
+	page := svc.resolveChatContext(user, &dto.ChatContext{
+		Route:              "/maintenance/tasks",
+		MaintenanceTaskIDs: []uint{taskID},
+		RepairOrderIDs:     []uint{orderID},
+		Filters:            map[string]string{"status": "overdue"},
+	})
+
+	if len(page.Entities) != 1 || page.Skipped != 1 {
+		t.Fatalf("Expected the task resolved and the other factory's order skipped, got %+v", page)
+	}
+	task := page.Entities[0]
+	if task["kind"] != "maintenance_task" || task["plan"] != "月度保养" || task["overdue_days"] != 5 {
+		t.Errorf("Unexpected task context: %+v", task)
+	}
+	if got, ok := pageEquipment(0, false, page); !ok || got != eqA {
+		t.Errorf("Expected the task's equipment %d to stand in for an unnamed equipment, got %d", eqA, got)
+	}
+	if got, _ := pageEquipment(eqB, true, page); got != eqB {
+		t.Errorf("Expected an equipment named in the message to win, got %d", got)
+	}
+
+	prompt := page.Prompt()
+	for _, want := range []string{"/maintenance/tasks", `"status":"overdue"`, "CNC-07", "等待备件", "1 个选中对象"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("Expected prompt to contain %q, got %q", want, prompt)
+		}
+	}
+	if strings.Contains(prompt, "焊枪漏气") {
+		t.Errorf("Prompt must not leak entities outside the user's factory: %q", prompt)
+	}
+	if _, ok := pageEquipment(0, false, nil); (*pageContext)(nil).Prompt() != "" || ok {
+		t.Error("Expected a nil page context to be a no-op")
+	}
+}
@@ -0,0 +1,334 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ems/backend/internal/agent/dto"
+	"github.com/ems/backend/internal/model"
+	"github.com/ems/backend/pkg/llm"
+)
+
+var ErrShareUnavailable = errors.New("share link has expired or been revoked")
+
+const (
+	conversationActive   = "active"
+	conversationArchived = "archived"
+
+	// 导出与展示时单个工具结果的最大长度
+	maxToolResultRunes = 4000
+	// 搜索结果摘要前后保留的字数
+	snippetRadius = 40
+)
+
+var messageRoleLabels = map[string]string{
+	"user":      "用户",
+	"assistant": "助手",
+	"system":    "系统",
+}
+
+// ownConversation 仅对话所有者可修改、删除或分享对话
+func (s *AgentService) ownConversation(id uint, userID uint) (*model.AgentConversation, error) {
+	conv, err := s.repo.GetConversationByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if conv.UserID != userID {
+		return nil, ErrPermissionDenied
+	}
+	return conv, nil
+}
+
+func mapConversation(conv *model.AgentConversation) dto.ConversationResponse {
+	return dto.ConversationResponse{
+		ID: conv.ID, Title: conv.Title, Status: conv.Status, CreatedAt: conv.CreatedAt, UpdatedAt: conv.UpdatedAt,
+	}
+}
+
+func mapMessage(m model.AgentMessage) dto.MessageItem {
+	item := dto.MessageItem{ID: m.ID, Role: m.Role, Content: m.Content, SkillID: m.SkillID, CreatedAt: m.CreatedAt}
+	if m.ToolCalls != nil && *m.ToolCalls != "" {
+		_ = json.Unmarshal([]byte(*m.ToolCalls), &item.ToolCalls)
+	}
+	return item
+}
+
+// toolCallRecords 从技能执行的消息轨迹中整理工具调用及其结果
+func toolCallRecords(messages []llm.Message) []dto.ToolCallRecord {
+	var records []dto.ToolCallRecord
+	index := map[string]int{}
+	for _, m := range messages {
+		switch {
+		case m.Role == "assistant" && len(m.ToolCalls) > 0:
+			for _, tc := range m.ToolCalls {
+				index[tc.ID] = len(records)
+				records = append(records, dto.ToolCallRecord{ID: tc.ID, Name: tc.Function.Name, Arguments: tc.Function.Arguments})
+			}
+		case m.Role == "tool":
+			i, ok := index[m.ToolCallID]
+			if !ok {
+				continue
+			}
+			if strings.HasPrefix(m.Content, "Error:") {
+				records[i].Error = strings.TrimSpace(strings.TrimPrefix(m.Content, "Error:"))
+			} else {
+				records[i].Result = truncateRunes(m.Content, maxToolResultRunes)
+			}
+		}
+	}
+	return records
+}
+
+// UpdateConversation 重命名或归档/恢复对话
+func (s *AgentService) UpdateConversation(id uint, userID uint, req *dto.UpdateConversationRequest) (*dto.ConversationResponse, error) {
+	conv, err := s.ownConversation(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if req.Title != nil {
+		title := strings.TrimSpace(*req.Title)
+		if title == "" {
+			return nil, fmt.Errorf("title must not be empty")
+		}
+		conv.Title = title
+	}
+	if req.Status != "" {
+		conv.Status = req.Status
+	}
+	if err := s.repo.UpdateConversation(conv); err != nil {
+		return nil, err
+	}
+	conv.UpdatedAt = time.Now()
+	res := mapConversation(conv)
+	return &res, nil
+}
+
+// DeleteConversation 删除对话及其消息与分享链接
+func (s *AgentService) DeleteConversation(id uint, userID uint) error {
+	if _, err := s.ownConversation(id, userID); err != nil {
+		return err
+	}
+	return s.repo.DeleteConversation(id)
+}
+
+// SearchMessages 在用户全部历史消息中按关键词检索（空格分隔的关键词需同时命中）
+func (s *AgentService) SearchMessages(userID uint, query string, limit int) ([]dto.MessageSearchHit, error) {
+	terms := strings.Fields(query)
+	if len(terms) == 0 {
+		return []dto.MessageSearchHit{}, nil
+	}
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+	msgs, err := s.repo.SearchMessages(userID, terms, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	titles := map[uint]string{}
+	hits := make([]dto.MessageSearchHit, 0, len(msgs))
+	for _, m := range msgs {
+		title, ok := titles[m.ConversationID]
+		if !ok {
+			if conv, err := s.repo.GetConversationByID(m.ConversationID); err == nil {
+				title = conv.Title
+			}
+			titles[m.ConversationID] = title
+		}
+		hits = append(hits, dto.MessageSearchHit{
+			ConversationID: m.ConversationID, ConversationTitle: title, MessageID: m.ID,
+			Role: m.Role, Snippet: snippet(m.Content, terms[0]), CreatedAt: m.CreatedAt,
+		})
+	}
+	return hits, nil
+}
+
+// snippet 截取关键词前后的片段
+func snippet(content, term string) string {
+	runes := []rune(content)
+	lower := []rune(strings.ToLower(content))
+	needle := []rune(strings.ToLower(term))
+	pos := 0
+	for i := 0; i+len(needle) <= len(lower); i++ {
+		if string(lower[i:i+len(needle)]) == string(needle) {
+			pos = i
+			break
+		}
+	}
+	start, end := pos-snippetRadius, pos+len(needle)+snippetRadius
+	prefix, suffix := "...", "..."
+	if start <= 0 {
+		start, prefix = 0, ""
+	}
+	if end >= len(runes) {
+		end, suffix = len(runes), ""
+	}
+	return prefix + strings.TrimSpace(string(runes[start:end])) + suffix
+}
+
+func newShareToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// sharedConversationPath 只读分享接口；接收人登录后以同工厂身份访问
+const sharedConversationPath = "/api/v1/agent/shared/conversations/"
+
+func mapShare(sh *model.AgentConversationShare) dto.ConversationShareResponse {
+	return dto.ConversationShareResponse{
+		ID: sh.ID, ConversationID: sh.ConversationID, Token: sh.Token,
+		URL:       sharedConversationPath + sh.Token,
+		ExpiresAt: sh.ExpiresAt, RevokedAt: sh.RevokedAt, CreatedAt: sh.CreatedAt,
+	}
+}
+
+// CreateShare 生成只读分享链接，访问范围限定在分享人所在工厂
+func (s *AgentService) CreateShare(id uint, user model.User, req *dto.CreateShareRequest) (*dto.ConversationShareResponse, error) {
+	if _, err := s.ownConversation(id, user.ID); err != nil {
+		return nil, err
+	}
+	token, err := newShareToken()
+	if err != nil {
+		return nil, err
+	}
+	share := &model.AgentConversationShare{ConversationID: id, Token: token, CreatedBy: user.ID, FactoryID: user.FactoryID}
+	if req.ExpiresInHours > 0 {
+		expires := time.Now().Add(time.Duration(req.ExpiresInHours) * time.Hour)
+		share.ExpiresAt = &expires
+	}
+	if err := s.repo.CreateConversationShare(share); err != nil {
+		return nil, err
+	}
+	res := mapShare(share)
+	return &res, nil
+}
+
+func (s *AgentService) ListShares(id uint, userID uint) ([]dto.ConversationShareResponse, error) {
+	if _, err := s.ownConversation(id, userID); err != nil {
+		return nil, err
+	}
+	shares, err := s.repo.ListConversationShares(id)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]dto.ConversationShareResponse, len(shares))
+	for i := range shares {
+		results[i] = mapShare(&shares[i])
+	}
+	return results, nil
+}
+
+func (s *AgentService) RevokeShare(id uint, shareID uint, userID uint) error {
+	if _, err := s.ownConversation(id, userID); err != nil {
+		return err
+	}
+	shares, err := s.repo.ListConversationShares(id)
+	if err != nil {
+		return err
+	}
+	for _, sh := range shares {
+		if sh.ID == shareID {
+			return s.repo.RevokeConversationShare(shareID)
+		}
+	}
+	return fmt.Errorf("share not found")
+}
+
+// GetSharedConversation 通过分享链接只读查看对话，仅同工厂用户（或管理员）可访问
+func (s *AgentService) GetSharedConversation(token string, viewer model.User) (*dto.SharedConversationResponse, error) {
+	share, err := s.repo.GetConversationShareByToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if share.RevokedAt != nil || (share.ExpiresAt != nil && time.Now().After(*share.ExpiresAt)) {
+		return nil, ErrShareUnavailable
+	}
+	if viewer.Role != model.RoleAdmin && !sameFactory(share.FactoryID, viewer.FactoryID) {
+		return nil, ErrPermissionDenied
+	}
+	conv, err := s.repo.GetConversationByID(share.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+	res := &dto.SharedConversationResponse{ConversationResponse: mapConversation(conv), ReadOnly: true, SharedBy: share.CreatedBy}
+	for _, m := range conv.Messages {
+		res.Messages = append(res.Messages, mapMessage(m))
+	}
+	return res, nil
+}
+
+func sameFactory(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// ExportConversation 将对话（含工具调用）导出为 Markdown 或 JSON
+func (s *AgentService) ExportConversation(id uint, userID uint, role string, format string) (*ExportedArtifact, error) {
+	conv, err := s.GetConversation(id, userID, role)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(struct {
+			*dto.ConversationResponse
+			ExportedAt time.Time `json:"exported_at"`
+		}{conv, time.Now()}, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return &ExportedArtifact{
+			Filename: fmt.Sprintf("ems-conversation-%d.json", conv.ID), ContentType: "application/json", Data: data,
+		}, nil
+	case "md":
+		return &ExportedArtifact{
+			Filename:    fmt.Sprintf("ems-conversation-%d.md", conv.ID),
+			ContentType: "text/markdown; charset=utf-8",
+			Data:        []byte(renderConversationMarkdown(conv)),
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported export format: %s", format)
+}
+
+func renderConversationMarkdown(conv *dto.ConversationResponse) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", conv.Title)
+	fmt.Fprintf(&b, "- 会话 ID：%d\n- 状态：%s\n- 创建时间：%s\n- 导出时间：%s\n\n---\n",
+		conv.ID, conv.Status, conv.CreatedAt.Format("2006-01-02 15:04"), time.Now().Format("2006-01-02 15:04"))
+
+	for _, m := range conv.Messages {
+		label := messageRoleLabels[m.Role]
+		if label == "" {
+			label = m.Role
+		}
+		fmt.Fprintf(&b, "\n### %s · %s\n\n%s\n", label, m.CreatedAt.Format("2006-01-02 15:04:05"), strings.TrimSpace(m.Content))
+		if len(m.ToolCalls) == 0 {
+			continue
+		}
+		b.WriteString("\n**工具调用**\n")
+		for i, tc := range m.ToolCalls {
+			fmt.Fprintf(&b, "\n%d. `%s`\n\n   参数：\n\n   ```json\n   %s\n   ```\n", i+1, tc.Name, indentBlock(tc.Arguments))
+			if tc.Error != "" {
+				fmt.Fprintf(&b, "\n   错误：%s\n", tc.Error)
+			} else if tc.Result != "" {
+				fmt.Fprintf(&b, "\n   结果：\n\n   ```\n   %s\n   ```\n", indentBlock(tc.Result))
+			}
+		}
+	}
+	return b.String()
+}
+
+// indentBlock 使多行内容在列表项内的代码块中保持缩进
+func indentBlock(s string) string {
+	return strings.ReplaceAll(strings.TrimSpace(s), "\n", "\n   ")
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ems/backend/internal/agent/dto"
+	"github.com/ems/backend/internal/model"
+	"github.com/ems/backend/pkg/config"
+	"github.com/ems/backend/pkg/llm"
+)
+
+func TestToolCallRecords(t *testing.T) {
+	call := llm.ToolCall{ID: "call_1"}
+	call.Function.Name = "get_failure_stats"
+	call.Function.Arguments = `{"equipment_id":3}`
+	failed := llm.ToolCall{ID: "call_2"}
+	failed.Function.Name = "get_cost_analysis"
+
+	records := toolCallRecords([]llm.Message{
+		{Role: "system", Content: "..."},
+		{Role: "assistant", ToolCalls: []llm.ToolCall{call, failed}},
+		{Role: "tool", ToolCallID: "call_1", Content: `{"total":4}`},
+		{Role: "tool", ToolCallID: "call_2", Content: "Error: equipment not found"},
+		{Role: "assistant", Content: "近 30 天故障 4 次 [E1]。"},
+	})
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 tool calls, got %d", len(records))
+	}
+	if records[0].Name != "get_failure_stats" || records[0].Result != `{"total":4}` {
+		t.Errorf("Unexpected first record: %+v", records[0])
+	}
+	if records[1].Error != "equipment not found" || records[1].Result != "" {
+		t.Errorf("Unexpected second record: %+v", records[1])
+	}
+}
+
+func TestAgentService_ConversationLifecycle(t *testing.T) {
+	config.Cfg = &config.Config{Storage: config.StorageConfig{Mode: "memory"}}
+	svc := NewAgentService()
+
+	factoryA, factoryB := uint(1), uint(2)
+	owner := model.User{BaseModel: model.BaseModel{ID: 601}, Role: model.RoleEngineer, FactoryID: &factoryA}
+	colleague := model.User{BaseModel: model.BaseModel{ID: 602}, Role: model.RoleMaintenance, FactoryID: &factoryA}
+	outsider := model.User{BaseModel: model.BaseModel{ID: 603}, Role: model.RoleMaintenance, FactoryID: &factoryB}
+
+	conv := &model.AgentConversation{UserID: owner.ID, Title: "CNC-01 主轴异响"}
+	_ = svc.repo.CreateConversation(conv)
+	calls, _ := json.Marshal([]dto.ToolCallRecord{{ID: "call_1", Name: "get_failure_stats", Arguments: `{"equipment_id":1}`, Result: `{"total":4}`}})
+	callsJSON := string(calls)
+	_ = svc.repo.CreateMessage(&model.AgentMessage{ConversationID: conv.ID, Role: "user", Content: "CNC-01 主轴最近总是异响，怎么处理？"})
+	_ = svc.repo.CreateMessage(&model.AgentMessage{ConversationID: conv.ID, Role: "assistant", Content: "建议先检查主轴轴承润滑。", ToolCalls: &callsJSON})
+
+	title := "主轴异响排查记录"
+	if _, err := svc.UpdateConversation(conv.ID, colleague.ID, &dto.UpdateConversationRequest{Title: &title}); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("Expected only the owner to rename, got %v", err)
+	}
+	updated, err := svc.UpdateConversation(conv.ID, owner.ID, &dto.UpdateConversationRequest{Title: &title, Status: "archived"})
+	if err != nil || updated.Title != title || updated.Status != "archived" {
+		t.Fatalf("Unexpected update result: %+v, %v", updated, err)
+	}
+	if active, _ := svc.ListConversations(owner.ID, "active", 20); len(active) != 0 {
+		t.Errorf("Expected archived conversation to be hidden, got %+v", active)
+	}
+	if archived, _ := svc.ListConversations(owner.ID, "archived", 20); len(archived) != 1 {
+		t.Errorf("Expected one archived conversation, got %+v", archived)
+	}
+
+	hits, _ := svc.SearchMessages(owner.ID, "主轴 润滑", 10)
+	if len(hits) != 1 || hits[0].ConversationTitle != title || hits[0].Role != "assistant" {
+		t.Errorf("Unexpected search hits: %+v", hits)
+	}
+	if hits, _ := svc.SearchMessages(colleague.ID, "主轴", 10); len(hits) != 0 {
+		t.Errorf("Expected search to be scoped to the user, got %+v", hits)
+	}
+
+	share, err := svc.CreateShare(conv.ID, owner, &dto.CreateShareRequest{ExpiresInHours: 24})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	shared, err := svc.GetSharedConversation(share.Token, colleague)
+	if err != nil || !shared.ReadOnly || len(shared.Messages) != 2 || len(shared.Messages[1].ToolCalls) != 1 {
+		t.Fatalf("Unexpected shared conversation: %+v, %v", shared, err)
+	}
+	if _, err := svc.GetSharedConversation(share.Token, outsider); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("Expected other factories to be denied, got %v", err)
+	}
+	_ = svc.RevokeShare(conv.ID, share.ID, owner.ID)
+	if _, err := svc.GetSharedConversation(share.Token, colleague); !errors.Is(err, ErrShareUnavailable) {
+		t.Errorf("Expected revoked share to be unavailable, got %v", err)
+	}
+
+	md, err := svc.ExportConversation(conv.ID, owner.ID, string(owner.Role), "md")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(string(md.Data), "# "+title) || !strings.Contains(string(md.Data), "`get_failure_stats`") {
+		t.Errorf("Markdown export is missing title or tool calls:\n%s", md.Data)
+	}
+	js, _ := svc.ExportConversation(conv.ID, owner.ID, string(owner.Role), "json")
+	var exported dto.ConversationResponse
+	if err := json.Unmarshal(js.Data, &exported); err != nil || exported.Messages[1].ToolCalls[0].Result != `{"total":4}` {
+		t.Errorf("Unexpected JSON export: %s", js.Data)
+	}
+
+	if err := svc.DeleteConversation(conv.ID, owner.ID); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := svc.GetConversation(conv.ID, owner.ID, string(owner.Role)); err == nil {
+		t.Error("Expected conversation to be deleted")
+	}
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/ems/backend/internal/agent/pushfilter"
+	"github.com/ems/backend/internal/event"
+	"github.com/ems/backend/internal/model"
+	"github.com/ems/backend/pkg/database"
+)
+
+// DomainEventPush Webhook 推送的领域事件负载，data 的结构由 event 与 version 决定
+type DomainEventPush struct {
+	Event       string          `json:"event"`
+	Version     string          `json:"version"`
+	EventID     uint            `json:"event_id"`
+	FactoryID   *uint           `json:"factory_id,omitempty"`
+	EquipmentID uint            `json:"equipment_id,omitempty"`
+	OccurredAt  time.Time       `json:"occurred_at"`
+	Data        json.RawMessage `json:"data"`
+}
+
+// subscriberCovers 订阅用户是否有权看到该工厂的事件：管理员或未绑定工厂的用户不受限
+func subscriberCovers(user model.User, factoryID *uint) bool {
+	if user.Role == model.RoleAdmin || user.FactoryID == nil {
+		return true
+	}
+	return factoryID != nil && *factoryID == *user.FactoryID
+}
+
+// HandleDomainEvent 将领域事件推送给 push_type 与事件类型一致、且工厂范围匹配的 Webhook 订阅
+func (s *AgentService) HandleDomainEvent(ctx context.Context, e event.Event) error {
+	db := database.GetDB()
+	var subs []model.AgentPushSubscription
+	if err := db.WithContext(ctx).Where("push_type = ? AND enabled = ?", string(e.Type), true).Find(&subs).Error; err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(DomainEventPush{
+		Event: string(e.Type), Version: PushSchemaVersion, EventID: e.ID, FactoryID: e.FactoryID, EquipmentID: e.EquipmentID,
+		OccurredAt: e.OccurredAt, Data: e.Payload,
+	})
+	if err != nil {
+		return err
+	}
+
+	facts := eventFacts(e)
+	var failed []string
+	for _, sub := range subs {
+		var user model.User
+		if err := db.First(&user, sub.UserID).Error; err != nil || !subscriberCovers(user, e.FactoryID) {
+			continue // 跨工厂，跳过此订阅者
+		}
+		if !subscriptionFilter(&sub).Match(facts) {
+			continue
+		}
+		if err := s.postPush(sub, string(e.Type), 0, body); err != nil {
+			failed = append(failed, fmt.Sprintf("subscription %d: %v", sub.ID, err))
+		}
+	}
+	if len(failed) > 0 {
+		// 入队后的发送与重试由 Webhook 分发器负责；入队失败不重试整条事件，避免其他订阅者重复收到
+		log.Printf("[AgentService] Domain event %d (%s) push failed: %s", e.ID, e.Type, strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// eventFacts 提取事件的过滤属性：设备所属车间与类型、严重度、优先级与库存比例
+func eventFacts(e event.Event) pushfilter.Facts {
+	facts := pushfilter.Facts{FactoryID: e.FactoryID, EquipmentID: e.EquipmentID, At: e.OccurredAt, Severity: "low"}
+	if e.EquipmentID > 0 {
+		var equipment model.Equipment
+		if err := database.GetDB().Select("id", "type_id", "workshop_id").First(&equipment, e.EquipmentID).Error; err == nil {
+			facts.WorkshopID, facts.EquipmentTypeID = equipment.WorkshopID, equipment.TypeID
+		}
+	}
+
+	switch e.Type {
+	case event.RepairCreated:
+		var p event.RepairCreatedPayload
+		if e.Decode(&p) == nil {
+			facts.Priority, facts.Severity = p.Priority, prioritySeverity(p.Priority)
+		}
+	case event.RepairStatusChanged:
+		var p event.RepairStatusChangedPayload
+		if e.Decode(&p) == nil {
+			facts.Priority = p.Priority
+		}
+	case event.InspectionNGDetected:
+		var p event.InspectionNGDetectedPayload
+		facts.Severity = "medium"
+		if e.Decode(&p) == nil && len(p.Items) >= 3 {
+			facts.Severity = "high"
+		}
+	case event.MaintenanceOverdue:
+		facts.Severity = "medium"
+	case event.SparePartBelowSafetyStock:
+		var p event.SparePartBelowSafetyStockPayload
+		if e.Decode(&p) == nil {
+			facts.Severity = "medium"
+			if p.Quantity <= 0 {
+				facts.Severity = "high"
+			}
+			if p.SafetyStock > 0 {
+				ratio := float64(p.Quantity) / float64(p.SafetyStock)
+				facts.StockRatio = &ratio
+			}
+		}
+	case event.EquipmentStatusChanged:
+		var p event.EquipmentStatusChangedPayload
+		if e.Decode(&p) == nil && (p.To == "maintenance" || p.To == "scrapped") {
+			facts.Severity = "medium"
+		}
+	case event.EquipmentRULRisk:
+		var p event.EquipmentRULRiskPayload
+		facts.Severity = "high"
+		if e.Decode(&p) == nil {
+			facts.RULDays = &p.EstimatedRULDays
+		}
+	case event.RepairSLABreached:
+		var p event.RepairSLABreachedPayload
+		if e.Decode(&p) == nil {
+			facts.Priority, facts.Severity = p.Priority, prioritySeverity(p.Priority)
+		}
+	}
+	return facts
+}
+
+// prioritySeverity 维修优先级 1=高 2=中 3=低
+func prioritySeverity(priority int) string {
+	switch priority {
+	case 1:
+		return "high"
+	case 2:
+		return "medium"
+	}
+	return "low"
+}
+
+// HandleRepairClosed 维修单关闭后立即沉淀设备长期备注，不必等到下次查询时再收割
+func (s *AgentService) HandleRepairClosed(ctx context.Context, e event.Event) error {
+	var p event.RepairStatusChangedPayload
+	if err := e.Decode(&p); err != nil {
+		return err
+	}
+	if p.To != string(model.RepairClosed) || p.EquipmentID == 0 {
+		return nil
+	}
+	return s.harvestRepairNotes(p.FactoryID, p.EquipmentID, time.Now().Add(-repairNoteLookback))
+}
@@ -0,0 +1,409 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/ems/backend/internal/agent/dto"
+	"github.com/ems/backend/internal/agent/repository"
+	"github.com/ems/backend/internal/agent/tool"
+	"github.com/ems/backend/internal/model"
+	internalRepo "github.com/ems/backend/internal/repository"
+	"github.com/ems/backend/pkg/config"
+	"github.com/ems/backend/pkg/database"
+	"github.com/ems/backend/pkg/llm"
+	"github.com/ems/backend/pkg/memory"
+)
+
+var ErrNoteReviewed = errors.New("equipment note has already been reviewed")
+
+const (
+	noteSourceManual       = "manual"
+	noteSourceConversation = "conversation"
+	noteSourceRepair       = "repair_order"
+
+	// 对话中提取的事实默认有效期；维修方案与手工录入默认长期有效
+	conversationNoteTTL = 180 * 24 * time.Hour
+	// 维修单关闭时回溯该设备最近关闭的维修单，补上之前漏处理的
+	repairNoteLookback = 90 * 24 * time.Hour
+	// 每台设备注入上下文的备注上限
+	maxInjectedNotes = 8
+)
+
+// durableRepairTerms 维修方案中出现这些词时，说明设备状态发生了长期变化，值得记入设备备注
+var durableRepairTerms = []string{"非原厂", "代用", "替代", "改造", "加装", "改装", "更换为", "换成", "参数调整", "调整参数", "临时", "遗留", "待彻底"}
+
+// canReviewNotes 主管与管理员可审核设备备注，录入时直接生效
+func canReviewNotes(user model.User) bool {
+	return user.Role == model.RoleAdmin || user.Role == model.RoleSupervisor
+}
+
+// equipmentScope 返回设备编码与所属工厂，非管理员只能访问本工厂设备
+func (s *AgentService) equipmentScope(user model.User, equipmentID uint) (string, *uint, error) {
+	var code string
+	var factoryID *uint
+	if config.Cfg.Storage.Mode == "memory" {
+		store := memory.GetStore()
+		eq, ok := store.Equipment[equipmentID]
+		if !ok {
+			return "", nil, fmt.Errorf("equipment not found")
+		}
+		code = eq.Code
+		if w, ok := store.Workshops[eq.WorkshopID]; ok {
+			factoryID = &w.FactoryID
+		}
+	} else {
+		eq, err := internalRepo.NewEquipmentRepo().GetByID(equipmentID)
+		if err != nil {
+			return "", nil, err
+		}
+		code = eq.Code
+		if eq.Workshop != nil {
+			factoryID = &eq.Workshop.FactoryID
+		}
+	}
+	if scope := feedbackScope(user); scope != nil && (factoryID == nil || *factoryID != *scope) {
+		return "", nil, fmt.Errorf("%w: equipment belongs to another factory", ErrPermissionDenied)
+	}
+	return code, factoryID, nil
+}
+
+func parseNoteExpiry(s string) *time.Time {
+	if s == "" {
+		return nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
+
+func noteProvenance(n *model.AgentEquipmentNote) string {
+	var src string
+	switch n.SourceType {
+	case noteSourceConversation:
+		src = fmt.Sprintf("对话 #%d", n.SourceID)
+	case noteSourceRepair:
+		src = fmt.Sprintf("维修单 #%d", n.SourceID)
+	default:
+		src = fmt.Sprintf("用户 #%d 录入", n.CreatedBy)
+	}
+	src += "，" + n.CreatedAt.Format("2006-01-02")
+	if n.ReviewedBy != nil {
+		src += fmt.Sprintf("，审核人 #%d", *n.ReviewedBy)
+	}
+	return src
+}
+
+func toEquipmentNoteResponse(n *model.AgentEquipmentNote, now time.Time) dto.EquipmentNoteResponse {
+	return dto.EquipmentNoteResponse{
+		ID: n.ID, EquipmentID: n.EquipmentID, Category: n.Category, Content: n.Content,
+		SourceType: n.SourceType, SourceID: n.SourceID, Provenance: noteProvenance(n), CreatedBy: n.CreatedBy,
+		Status: n.Status, ReviewedBy: n.ReviewedBy, ReviewedAt: n.ReviewedAt, ReviewNote: n.ReviewNote,
+		ExpiresAt: n.ExpiresAt, Expired: n.ExpiresAt != nil && !n.ExpiresAt.After(now), CreatedAt: n.CreatedAt,
+	}
+}
+
+// AddEquipmentNote 手工录入设备备注；主管与管理员录入直接生效，其他角色进入审核队列
+func (s *AgentService) AddEquipmentNote(user model.User, equipmentID uint, req *dto.EquipmentNoteRequest) (*dto.EquipmentNoteResponse, error) {
+	_, factoryID, err := s.equipmentScope(user, equipmentID)
+	if err != nil {
+		return nil, err
+	}
+	category := req.Category
+	if category == "" {
+		category = "other"
+	}
+	note := &model.AgentEquipmentNote{
+		EquipmentID: equipmentID, FactoryID: factoryID, Category: category, Content: strings.TrimSpace(req.Content),
+		SourceType: noteSourceManual, CreatedBy: user.ID, Status: "pending", ExpiresAt: parseNoteExpiry(req.ExpiresAt),
+	}
+	if canReviewNotes(user) {
+		now := time.Now()
+		note.Status, note.ReviewedBy, note.ReviewedAt = "approved", &user.ID, &now
+	}
+	if err := s.repo.SaveEquipmentNote(note); err != nil {
+		return nil, err
+	}
+	res := toEquipmentNoteResponse(note, time.Now())
+	return &res, nil
+}
+
+// ListEquipmentNotes 按设备或状态列出备注（主管仅见本工厂）
+func (s *AgentService) ListEquipmentNotes(user model.User, q *dto.EquipmentNoteQuery) ([]dto.EquipmentNoteResponse, error) {
+	filter := repository.EquipmentNoteFilter{FactoryID: feedbackScope(user), Status: q.Status, Limit: 200}
+	if q.EquipmentID > 0 {
+		if _, _, err := s.equipmentScope(user, q.EquipmentID); err != nil {
+			return nil, err
+		}
+		filter.EquipmentIDs = []uint{q.EquipmentID}
+	}
+	now := time.Now()
+	if !q.IncludeExpired {
+		filter.ActiveAt = &now
+	}
+	notes, err := s.repo.ListEquipmentNotes(filter)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]dto.EquipmentNoteResponse, len(notes))
+	for i := range notes {
+		results[i] = toEquipmentNoteResponse(&notes[i], now)
+	}
+	return results, nil
+}
+
+// ReviewEquipmentNote 审核待定备注，审核时可修订内容与有效期
+func (s *AgentService) ReviewEquipmentNote(reviewer model.User, id uint, req *dto.EquipmentNoteReviewRequest) (*dto.EquipmentNoteResponse, error) {
+	note, err := s.repo.GetEquipmentNoteByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if scope := feedbackScope(reviewer); scope != nil && (note.FactoryID == nil || *note.FactoryID != *scope) {
+		return nil, fmt.Errorf("%w: equipment note belongs to another factory", ErrPermissionDenied)
+	}
+	if note.Status != "pending" {
+		return nil, ErrNoteReviewed
+	}
+	now := time.Now()
+	note.Status, note.ReviewNote, note.ReviewedBy, note.ReviewedAt = req.Status, req.Note, &reviewer.ID, &now
+	if req.Content != "" {
+		note.Content = strings.TrimSpace(req.Content)
+	}
+	if req.ExpiresAt != "" {
+		note.ExpiresAt = parseNoteExpiry(req.ExpiresAt)
+	}
+	if err := s.repo.SaveEquipmentNote(note); err != nil {
+		return nil, err
+	}
+	res := toEquipmentNoteResponse(note, now)
+	return &res, nil
+}
+
+// DeleteEquipmentNote 录入人可撤回未审核的备注，主管与管理员可删除本工厂任意备注
+func (s *AgentService) DeleteEquipmentNote(user model.User, id uint) error {
+	note, err := s.repo.GetEquipmentNoteByID(id)
+	if err != nil {
+		return err
+	}
+	ownPending := note.SourceType == noteSourceManual && note.CreatedBy == user.ID && note.Status == "pending"
+	if !ownPending && !canReviewNotes(user) {
+		return fmt.Errorf("%w: only reviewers can delete reviewed notes", ErrPermissionDenied)
+	}
+	if scope := feedbackScope(user); !ownPending && scope != nil && (note.FactoryID == nil || *note.FactoryID != *scope) {
+		return fmt.Errorf("%w: equipment note belongs to another factory", ErrPermissionDenied)
+	}
+	return s.repo.DeleteEquipmentNote(id)
+}
+
+// activeEquipmentNotes 已审核且未过期的设备备注
+func (s *AgentService) activeEquipmentNotes(equipmentIDs ...uint) []model.AgentEquipmentNote {
+	if len(equipmentIDs) == 0 {
+		return nil
+	}
+	now := time.Now()
+	notes, err := s.repo.ListEquipmentNotes(repository.EquipmentNoteFilter{
+		EquipmentIDs: equipmentIDs, Status: "approved", ActiveAt: &now, Limit: maxInjectedNotes * len(equipmentIDs),
+	})
+	if err != nil {
+		log.Printf("[AgentService] Failed to load equipment notes: %v", err)
+		return nil
+	}
+	return notes
+}
+
+// equipmentNotesContext 生成注入系统提示词的设备长期备注段落，每条附带来源
+func (s *AgentService) equipmentNotesContext(equipmentIDs ...uint) string {
+	notes := s.activeEquipmentNotes(equipmentIDs...)
+	if len(notes) == 0 {
+		return ""
+	}
+	perEquipment := map[uint]int{}
+	var b strings.Builder
+	b.WriteString("\n### 设备长期备注（已审核）\n")
+	for i := range notes {
+		n := &notes[i]
+		if perEquipment[n.EquipmentID] >= maxInjectedNotes {
+			continue
+		}
+		perEquipment[n.EquipmentID]++
+		fmt.Fprintf(&b, "- [设备 #%d][%s] %s（来源：%s）\n", n.EquipmentID, n.Category, n.Content, noteProvenance(n))
+	}
+	return b.String()
+}
+
+// handleGetEquipmentNotes 工具：查询设备的长期备注
+func (s *AgentService) handleGetEquipmentNotes(ctx context.Context, user model.User, args dto.EquipmentToolArgs) (interface{}, error) {
+	if _, _, err := s.equipmentScope(user, args.EquipmentID); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	notes := s.activeEquipmentNotes(args.EquipmentID)
+	results := make([]dto.EquipmentNoteResponse, len(notes))
+	for i := range notes {
+		results[i] = toEquipmentNoteResponse(&notes[i], now)
+	}
+	return results, nil
+}
+
+// harvestRepairNotes 将 since 之后关闭、且处理方案涉及长期变化的维修单转为待审核备注，已转换的维修单不会重复生成。
+// 由维修单关闭事件触发（HandleRepairClosed）
+func (s *AgentService) harvestRepairNotes(factoryID *uint, equipmentID uint, since time.Time) error {
+	type candidate struct {
+		order     model.RepairOrder
+		factoryID *uint
+	}
+	var candidates []candidate
+	if config.Cfg.Storage.Mode == "memory" {
+		store := memory.GetStore()
+		for _, o := range store.RepairOrders {
+			if o.Status != model.RepairClosed || repairClosedBefore(o, since) || (equipmentID > 0 && o.EquipmentID != equipmentID) {
+				continue
+			}
+			var fid *uint
+			if eq, ok := store.Equipment[o.EquipmentID]; ok {
+				if w, ok := store.Workshops[eq.WorkshopID]; ok {
+					fid = &w.FactoryID
+				}
+			}
+			if factoryID != nil && (fid == nil || *fid != *factoryID) {
+				continue
+			}
+			candidates = append(candidates, candidate{*o, fid})
+		}
+	} else {
+		var orders []model.RepairOrder
+		q := database.GetDB().Model(&model.RepairOrder{}).
+			Joins("JOIN equipment ON equipment.id = repair_orders.equipment_id").
+			Joins("JOIN workshops ON workshops.id = equipment.workshop_id").
+			Where("repair_orders.status = ? AND COALESCE(repair_orders.closed_at, repair_orders.audited_at) >= ? AND repair_orders.solution <> ''", model.RepairClosed, since)
+		if factoryID != nil {
+			q = q.Where("workshops.factory_id = ?", *factoryID)
+		}
+		if equipmentID > 0 {
+			q = q.Where("repair_orders.equipment_id = ?", equipmentID)
+		}
+		if err := q.Preload("Equipment.Workshop").Find(&orders).Error; err != nil {
+			return err
+		}
+		for _, o := range orders {
+			var fid *uint
+			if o.Equipment != nil && o.Equipment.Workshop != nil {
+				fid = &o.Equipment.Workshop.FactoryID
+			}
+			candidates = append(candidates, candidate{o, fid})
+		}
+	}
+
+	var durable []candidate
+	var ids []uint
+	for _, c := range candidates {
+		if isDurableRepair(c.order.Solution) {
+			durable = append(durable, c)
+			ids = append(ids, c.order.ID)
+		}
+	}
+	if len(durable) == 0 {
+		return nil
+	}
+	existing, err := s.repo.ListEquipmentNotes(repository.EquipmentNoteFilter{SourceType: noteSourceRepair, SourceIDs: ids})
+	if err != nil {
+		return err
+	}
+	done := map[uint]bool{}
+	for _, n := range existing {
+		done[n.SourceID] = true
+	}
+	for _, c := range durable {
+		if done[c.order.ID] {
+			continue
+		}
+		content := fmt.Sprintf("%s 维修：%s；处理：%s", c.order.CreatedAt.Format("2006-01-02"),
+			truncateRunes(c.order.FaultDescription, 80), truncateRunes(c.order.Solution, 300))
+		if err := s.repo.SaveEquipmentNote(&model.AgentEquipmentNote{
+			EquipmentID: c.order.EquipmentID, FactoryID: c.factoryID, Category: "modification", Content: content,
+			SourceType: noteSourceRepair, SourceID: c.order.ID, Status: "pending",
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// repairClosedBefore 早期关闭的维修单未记录 closed_at，以审核时间代替
+func repairClosedBefore(o *model.RepairOrder, since time.Time) bool {
+	closedAt := o.ClosedAt
+	if closedAt == nil {
+		closedAt = o.AuditedAt
+	}
+	return closedAt == nil || closedAt.Before(since)
+}
+
+func isDurableRepair(solution string) bool {
+	for _, term := range durableRepairTerms {
+		if strings.Contains(solution, term) {
+			return true
+		}
+	}
+	return false
+}
+
+type extractedEquipmentNote struct {
+	Category      string `json:"category" binding:"required,oneof=modification known_issue operation other"`
+	Content       string `json:"content" binding:"required,max=1000"`
+	ExpiresInDays int    `json:"expires_in_days" binding:"min=0"`
+}
+
+var equipmentNoteSchema = tool.SchemaOf([]extractedEquipmentNote{})
+
+// asyncExtractEquipmentNotes 从对话中提取讨论设备的长期事实，作为待审核备注
+func (s *AgentService) asyncExtractEquipmentNotes(history []model.AgentMessage, convID uint, user model.User) {
+	var asked strings.Builder
+	for _, m := range history {
+		if m.Role == "user" {
+			asked.WriteString(m.Content)
+			asked.WriteString("\n")
+		}
+	}
+	eqID, ok := s.extractEquipmentID(asked.String(), user)
+	if !ok {
+		return
+	}
+	code, factoryID, err := s.equipmentScope(user, eqID)
+	if err != nil {
+		return
+	}
+	var extracted []extractedEquipmentNote
+	err = llm.Structured(s.llmFor(user), []llm.Message{
+		{Role: "system", Content: "你是一个资深的设备管理专家。"},
+		{Role: "user", Content: s.promptTool.BuildEquipmentNoteExtractionPrompt(code, history)},
+	}, llm.StructuredSpec{Name: "equipment_notes", Schema: equipmentNoteSchema}, &extracted)
+	if err != nil {
+		log.Printf("[AgentService] Equipment note extraction failed for conversation %d: %v", convID, err)
+		return
+	}
+	for i, e := range extracted {
+		if i >= 3 || strings.TrimSpace(e.Content) == "" {
+			break
+		}
+		ttl := conversationNoteTTL
+		if e.ExpiresInDays > 0 {
+			ttl = time.Duration(e.ExpiresInDays) * 24 * time.Hour
+		}
+		expires := time.Now().Add(ttl)
+		switch e.Category {
+		case "modification", "known_issue", "operation":
+		default:
+			e.Category = "other"
+		}
+		_ = s.repo.SaveEquipmentNote(&model.AgentEquipmentNote{
+			EquipmentID: eqID, FactoryID: factoryID, Category: e.Category, Content: truncateRunes(strings.TrimSpace(e.Content), 1000),
+			SourceType: noteSourceConversation, SourceID: convID, CreatedBy: user.ID, Status: "pending", ExpiresAt: &expires,
+		})
+	}
+}
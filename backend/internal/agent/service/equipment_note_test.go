@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ems/backend/internal/agent/dto"
+	"github.com/ems/backend/internal/event"
+	"github.com/ems/backend/internal/model"
+	"github.com/ems/backend/pkg/config"
+	"github.com/ems/backend/pkg/memory"
+)
+
+func TestAgentService_EquipmentNotes(t *testing.T) {
+	config.Cfg = &config.Config{Storage: config.StorageConfig{Mode: "memory"}}
+	svc := NewAgentService()
+	store := memory.GetStore()
+
+	factoryA, factoryB := store.NextID(), store.NextID()
+	wsID, eqID := store.NextID(), store.NextID()
+	store.Workshops[wsID] = &model.Workshop{BaseModel: model.BaseModel{ID: wsID}, FactoryID: factoryA, Name: "冲压车间"}
+	store.Equipment[eqID] = &model.Equipment{BaseModel: model.BaseModel{ID: eqID}, Code: "PRESS-03", WorkshopID: wsID}
+
+	tech := model.User{BaseModel: model.BaseModel{ID: 601}, Role: model.RoleMaintenance, FactoryID: &factoryA}
+	supervisor := model.User{BaseModel: model.BaseModel{ID: 602}, Role: model.RoleSupervisor, FactoryID: &factoryA}
+	outsider := model.User{BaseModel: model.BaseModel{ID: 603}, Role: model.RoleSupervisor, FactoryID: &factoryB}
+
+	note, err := svc.AddEquipmentNote(tech, eqID, &dto.EquipmentNoteRequest{Category: "modification", Content: "3 月液压泵更换为非原厂件"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if note.Status != "pending" || note.SourceType != "manual" {
+		t.Errorf("Expected a pending manual note, got %+v", note)
+	}
+	if ctx := svc.equipmentNotesContext(eqID); ctx != "" {
+		t.Errorf("Pending notes must not be injected, got %q", ctx)
+	}
+	if _, err := svc.AddEquipmentNote(outsider, eqID, &dto.EquipmentNoteRequest{Content: "越权备注"}); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("Expected permission denied for another factory, got %v", err)
+	}
+	if _, err := svc.ReviewEquipmentNote(outsider, note.ID, &dto.EquipmentNoteReviewRequest{Status: "approved"}); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("Expected permission denied when reviewing another factory's note, got %v", err)
+	}
+
+	reviewed, err := svc.ReviewEquipmentNote(supervisor, note.ID, &dto.EquipmentNoteReviewRequest{Status: "approved"})
+	if err != nil || reviewed.Status != "approved" {
+		t.Fatalf("Expected approval, got %+v, %v", reviewed, err)
+	}
+	if _, err := svc.ReviewEquipmentNote(supervisor, note.ID, &dto.EquipmentNoteReviewRequest{Status: "rejected"}); !errors.Is(err, ErrNoteReviewed) {
+		t.Errorf("Expected ErrNoteReviewed on second review, got %v", err)
+	}
+
+	// 主管录入直接生效，过期备注不再注入
+	past := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+	expired, _ := svc.AddEquipmentNote(supervisor, eqID, &dto.EquipmentNoteRequest{Content: "临时限速运行", ExpiresAt: past})
+	if expired.Status != "approved" || !expired.Expired {
+		t.Errorf("Expected an approved but expired note, got %+v", expired)
+	}
+	ctx := svc.equipmentNotesContext(eqID)
+	if !strings.Contains(ctx, "非原厂件") || !strings.Contains(ctx, "审核人 #602") || strings.Contains(ctx, "临时限速") {
+		t.Errorf("Unexpected injected context: %q", ctx)
+	}
+
+	// 关闭的维修单中涉及长期变化的处理方案在关闭事件后进入审核队列，且只生成一次；
+	// 按关闭时间回溯，报修很久但刚关闭的维修单也会收割
+	orderID, routineID := store.NextID(), store.NextID()
+	reported, closed := time.Now().AddDate(0, 0, -120), time.Now()
+	store.RepairOrders[orderID] = &model.RepairOrder{BaseModel: model.BaseModel{ID: orderID, CreatedAt: reported},
+		EquipmentID: eqID, FaultDescription: "滑块下行无力", Solution: "液压阀更换为国产代用型号", Status: model.RepairClosed, ClosedAt: &closed}
+	store.RepairOrders[routineID] = &model.RepairOrder{BaseModel: model.BaseModel{ID: routineID, CreatedAt: time.Now()},
+		EquipmentID: eqID, FaultDescription: "油位低", Solution: "补充液压油", Status: model.RepairClosed, ClosedAt: &closed}
+	if pending, _ := svc.ListEquipmentNotes(supervisor, &dto.EquipmentNoteQuery{Status: "pending"}); len(pending) != 0 {
+		t.Fatalf("Expected listing notes not to harvest repair orders, got %+v", pending)
+	}
+	payload, _ := json.Marshal(event.RepairStatusChangedPayload{Subject: event.Subject{FactoryID: &factoryA, EquipmentID: eqID},
+		OrderID: orderID, From: string(model.RepairAudited), To: string(model.RepairClosed)})
+	closedEvent := event.Event{Type: event.RepairStatusChanged, Payload: payload}
+	for i := 0; i < 2; i++ {
+		if err := svc.HandleRepairClosed(context.Background(), closedEvent); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		pending, err := svc.ListEquipmentNotes(supervisor, &dto.EquipmentNoteQuery{Status: "pending"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(pending) != 1 || pending[0].SourceType != "repair_order" || pending[0].SourceID != orderID {
+			t.Fatalf("Expected one pending note from repair order %d, got %+v", orderID, pending)
+		}
+	}
+	if notes, _ := svc.ListEquipmentNotes(outsider, &dto.EquipmentNoteQuery{Status: "pending"}); len(notes) != 0 {
+		t.Errorf("Expected no notes visible to another factory, got %+v", notes)
+	}
+
+	if err := svc.DeleteEquipmentNote(tech, note.ID); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("Expected technician unable to delete a reviewed note, got %v", err)
+	}
+	if err := svc.DeleteEquipmentNote(supervisor, note.ID); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ems/backend/internal/model"
+	"github.com/ems/backend/pkg/config"
+	"github.com/ems/backend/pkg/report"
+)
+
+// ExportedArtifact 导出结果
+type ExportedArtifact struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// 证据来源表 -> 展示名称与前端路由
+var evidenceSources = map[string]struct {
+	label string
+	route string
+}{
+	"equipment":               {"设备", "/equipment/detail/%d"},
+	"repair_orders":           {"维修工单", "/repair/orders?id=%d"},
+	"maintenance_tasks":       {"保养任务", "/maintenance/tasks?id=%d"},
+	"inspection_tasks":        {"点检任务", "/inspection/tasks?id=%d"},
+	"spare_parts":             {"备件", "/spareparts?id=%d"},
+	"knowledge_articles":      {"知识库文章", "/knowledge?id=%d"},
+	"equipment_manual_chunks": {"设备手册片段", "/knowledge?chunk=%d"},
+}
+
+// 作为“关键发现”呈现的结果字段
+var findingKeys = []string{"findings", "key_findings", "anomalies", "issues", "risk_factors", "recommendations", "suggestions"}
+
+// ExportArtifact 将产物渲染为 pdf / docx / md 报告
+func (s *AgentService) ExportArtifact(id uint, userID uint, role string, format string) (*ExportedArtifact, error) {
+	artifact, err := s.repo.GetArtifactByID(id)
+	if err != nil {
+		return nil, err
+	}
+	session, err := s.repo.GetSessionByID(artifact.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if role != "admin" && session.UserID != userID {
+		return nil, fmt.Errorf("permission denied: unauthorized access to artifact")
+	}
+
+	r := buildArtifactReport(artifact, session)
+	data, contentType, ext, err := report.Render(r, format)
+	if err != nil {
+		return nil, err
+	}
+	return &ExportedArtifact{
+		Filename:    fmt.Sprintf("ems-artifact-%d.%s", artifact.ID, ext),
+		ContentType: contentType,
+		Data:        data,
+	}, nil
+}
+
+func buildArtifactReport(artifact *model.AgentArtifact, session *model.AgentSession) *report.Report {
+	r := &report.Report{
+		Title:       artifact.Title,
+		RiskLevel:   artifact.RiskLevel,
+		Summary:     artifact.Summary,
+		GeneratedAt: artifact.CreatedAt,
+	}
+	if r.Title == "" {
+		r.Title = fmt.Sprintf("分析报告 #%d", artifact.ID)
+	}
+	if session != nil && session.Scenario != "" {
+		r.Subtitle = fmt.Sprintf("场景：%s ｜ 追踪号：%s", session.Scenario, session.TraceID)
+	}
+
+	var result interface{}
+	if err := json.Unmarshal([]byte(artifact.ResultJSON), &result); err == nil {
+		r.Findings, r.Tables = reportContent(result)
+	}
+
+	for i, ev := range artifact.EvidenceLinks {
+		r.Citations = append(r.Citations, evidenceCitation(i+1, ev))
+	}
+	return r
+}
+
+func evidenceCitation(index int, ev model.AgentEvidenceLink) report.Citation {
+	c := report.Citation{
+		Index:   index,
+		Source:  fmt.Sprintf("%s#%d", ev.SourceTable, ev.SourceID),
+		Excerpt: ev.Excerpt,
+	}
+	src, ok := evidenceSources[ev.SourceTable]
+	if !ok {
+		c.Title = c.Source
+		return c
+	}
+	c.Title = fmt.Sprintf("%s #%d", src.label, ev.SourceID)
+	if ev.SourceID > 0 {
+		baseURL := strings.TrimRight(config.Cfg.App.BaseURL, "/")
+		c.URL = baseURL + fmt.Sprintf(src.route, ev.SourceID)
+	}
+	return c
+}
+
+// reportContent 从 ResultJSON 中提取发现列表与数据表
+func reportContent(result interface{}) ([]string, []report.Table) {
+	var findings []string
+	var tables []report.Table
+
+	switch v := result.(type) {
+	case []interface{}:
+		if t, ok := objectTable("结果明细", v); ok {
+			tables = append(tables, t)
+		}
+	case map[string]interface{}:
+		used := map[string]bool{}
+		for _, key := range findingKeys {
+			items, ok := v[key].([]interface{})
+			if !ok {
+				continue
+			}
+			if t, ok := objectTable(key, items); ok {
+				tables = append(tables, t)
+			} else {
+				for _, it := range items {
+					findings = append(findings, scalarString(it))
+				}
+			}
+			used[key] = true
+		}
+
+		metrics := report.Table{Title: "关键指标", Headers: []string{"指标", "值"}}
+		keys := sortedKeys(v)
+		for _, key := range keys {
+			if used[key] {
+				continue
+			}
+			switch val := v[key].(type) {
+			case []interface{}:
+				if t, ok := objectTable(key, val); ok {
+					tables = append(tables, t)
+				} else if len(val) > 0 {
+					metrics.Rows = append(metrics.Rows, []string{key, joinScalars(val)})
+				}
+			case map[string]interface{}:
+				for _, sub := range sortedKeys(val) {
+					metrics.Rows = append(metrics.Rows, []string{key + "." + sub, scalarString(val[sub])})
+				}
+			default:
+				metrics.Rows = append(metrics.Rows, []string{key, scalarString(val)})
+			}
+		}
+		if len(metrics.Rows) > 0 {
+			tables = append([]report.Table{metrics}, tables...)
+		}
+	}
+	return findings, tables
+}
+
+// objectTable 将对象数组转为表格，列为各对象键的并集
+func objectTable(title string, items []interface{}) (report.Table, bool) {
+	if len(items) == 0 {
+		return report.Table{}, false
+	}
+	columns := map[string]bool{}
+	for _, it := range items {
+		obj, ok := it.(map[string]interface{})
+		if !ok {
+			return report.Table{}, false
+		}
+		for k := range obj {
+			columns[k] = true
+		}
+	}
+	t := report.Table{Title: title}
+	for k := range columns {
+		t.Headers = append(t.Headers, k)
+	}
+	sort.Strings(t.Headers)
+	for _, it := range items {
+		obj := it.(map[string]interface{})
+		row := make([]string, len(t.Headers))
+		for i, h := range t.Headers {
+			row[i] = scalarString(obj[h])
+		}
+		t.Rows = append(t.Rows, row)
+	}
+	return t, true
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func joinScalars(items []interface{}) string {
+	parts := make([]string, 0, len(items))
+	for _, it := range items {
+		parts = append(parts, scalarString(it))
+	}
+	return strings.Join(parts, "、")
+}
+
+func scalarString(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		if val == float64(int64(val)) {
+			return fmt.Sprintf("%d", int64(val))
+		}
+		return fmt.Sprintf("%.2f", val)
+	case bool:
+		if val {
+			return "是"
+		}
+		return "否"
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
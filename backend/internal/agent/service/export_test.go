@@ -0,0 +1,41 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/ems/backend/internal/model"
+	"github.com/ems/backend/pkg/config"
+)
+
+func TestBuildArtifactReport(t *testing.T) {
+	config.Cfg = &config.Config{App: config.AppConfig{BaseURL: "https://ems.example.com/"}}
+
+	artifact := &model.AgentArtifact{
+		BaseModel: model.BaseModel{ID: 7},
+		Title:     "保养计划诊断",
+		RiskLevel: "medium",
+		ResultJSON: `{"health_score": 72.5, "anomalies": ["保养逾期 3 次"],
+			"tasks": [{"code": "MT-1", "overdue_days": 4}, {"code": "MT-2"}]}`,
+		EvidenceLinks: []model.AgentEvidenceLink{
+			{SourceTable: "repair_orders", SourceID: 12},
+			{SourceTable: "custom_table", SourceID: 3},
+		},
+	}
+	r := buildArtifactReport(artifact, &model.AgentSession{Scenario: "maintenance_recommendation"})
+
+	if len(r.Findings) != 1 || r.Findings[0] != "保养逾期 3 次" {
+		t.Errorf("Expected anomalies as findings, got %v", r.Findings)
+	}
+	if len(r.Tables) != 2 || r.Tables[0].Title != "关键指标" || r.Tables[0].Rows[0][1] != "72.50" {
+		t.Fatalf("Expected metrics table followed by tasks table, got %+v", r.Tables)
+	}
+	if got := r.Tables[1].Headers; len(got) != 2 || got[0] != "code" || got[1] != "overdue_days" {
+		t.Errorf("Expected sorted union of object keys, got %v", got)
+	}
+	if r.Citations[0].URL != "https://ems.example.com/repair/orders?id=12" {
+		t.Errorf("Unexpected citation URL: %s", r.Citations[0].URL)
+	}
+	if r.Citations[1].URL != "" || r.Citations[1].Title != "custom_table#3" {
+		t.Errorf("Expected unknown source without link, got %+v", r.Citations[1])
+	}
+}
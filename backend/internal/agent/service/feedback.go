@@ -0,0 +1,384 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ems/backend/internal/agent/dto"
+	"github.com/ems/backend/internal/agent/repository"
+	"github.com/ems/backend/internal/model"
+	"github.com/ems/backend/pkg/config"
+)
+
+var (
+	ErrPermissionDenied = errors.New("permission denied")
+	ErrNotUnderReview   = errors.New("feedback is not under review")
+)
+
+// 评价对知识置信度的影响：点赞缓慢上升，点踩较快下降
+const (
+	knowledgeUpStep   = 0.05
+	knowledgeDownStep = 0.10
+)
+
+// feedbackTarget 被评价对象的归属与上下文
+type feedbackTarget struct {
+	ownerID      uint
+	scenario     string
+	skillID      string
+	model        string
+	knowledgeIDs []string
+	content      string
+}
+
+func currentModelName() string {
+	if config.Cfg.LLM.Model == "" {
+		return "rule-based"
+	}
+	return config.Cfg.LLM.Model
+}
+
+func ratingValue(rating string) int {
+	if rating == "up" {
+		return 1
+	}
+	return -1
+}
+
+func ratingLabel(rating int) string {
+	if rating > 0 {
+		return "up"
+	}
+	return "down"
+}
+
+func (s *AgentService) resolveFeedbackTarget(targetType string, targetID uint) (*feedbackTarget, error) {
+	switch targetType {
+	case "message":
+		msg, err := s.repo.GetMessageByID(targetID)
+		if err != nil {
+			return nil, err
+		}
+		if msg.Role != "assistant" {
+			return nil, fmt.Errorf("only assistant messages can be rated")
+		}
+		conv, err := s.repo.GetConversationByID(msg.ConversationID)
+		if err != nil {
+			return nil, err
+		}
+		t := &feedbackTarget{ownerID: conv.UserID, scenario: "chat", skillID: msg.SkillID, content: msg.Content, model: currentModelName()}
+		if msg.SkillID != "" {
+			t.scenario = "skill_execution"
+		}
+		if msg.KnowledgeIDs != nil {
+			_ = json.Unmarshal([]byte(*msg.KnowledgeIDs), &t.knowledgeIDs)
+		}
+		// 对话的用量记录以会话 ID 作为 session_id
+		if usage, err := s.repo.GetUsageBySession(conv.ID, "chat"); err == nil {
+			t.model = usage.Model
+		}
+		return t, nil
+	case "artifact":
+		artifact, err := s.repo.GetArtifactByID(targetID)
+		if err != nil {
+			return nil, err
+		}
+		session, err := s.repo.GetSessionByID(artifact.SessionID)
+		if err != nil {
+			return nil, err
+		}
+		t := &feedbackTarget{ownerID: session.UserID, scenario: session.Scenario, content: artifact.Summary, model: currentModelName()}
+		if artifact.KnowledgeIDs != nil {
+			_ = json.Unmarshal([]byte(*artifact.KnowledgeIDs), &t.knowledgeIDs)
+		}
+		if usage, err := s.repo.GetUsageBySession(session.ID, session.Scenario); err == nil {
+			t.model = usage.Model
+		}
+		return t, nil
+	}
+	return nil, fmt.Errorf("unsupported feedback target: %s", targetType)
+}
+
+// SubmitFeedback 记录对消息或产物的赞/踩与纠正，并回写技能成功率、知识置信度与用户经验
+func (s *AgentService) SubmitFeedback(user model.User, req *dto.FeedbackRequest) (*dto.FeedbackResponse, error) {
+	target, err := s.resolveFeedbackTarget(req.TargetType, req.TargetID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Role != model.RoleAdmin && target.ownerID != user.ID {
+		return nil, fmt.Errorf("%w: cannot rate another user's answer", ErrPermissionDenied)
+	}
+
+	rating := ratingValue(req.Rating)
+	fb, err := s.repo.GetUserFeedback(user.ID, req.TargetType, req.TargetID)
+	if err != nil {
+		fb = &model.AgentFeedback{UserID: user.ID, FactoryID: user.FactoryID, TargetType: req.TargetType, TargetID: req.TargetID}
+	}
+	ratingChanged := fb.ID == 0 || fb.Rating != rating
+	previous := fb.Rating // 新建评价为 0
+	correctionChanged := req.Correction != "" && req.Correction != fb.Correction
+
+	knowledgeJSON, _ := json.Marshal(target.knowledgeIDs)
+	fb.Rating = rating
+	fb.Correction = req.Correction
+	fb.Scenario = target.scenario
+	fb.SkillID = target.skillID
+	fb.Model = target.model
+	fb.KnowledgeIDs = string(knowledgeJSON)
+	if rating < 0 {
+		if ratingChanged || correctionChanged {
+			fb.ReviewStatus = "pending"
+			fb.ReviewedBy, fb.ReviewedAt, fb.ReviewNote = nil, nil, ""
+		}
+	} else {
+		fb.ReviewStatus = ""
+	}
+	if err := s.repo.SaveFeedback(fb); err != nil {
+		return nil, err
+	}
+
+	if ratingChanged {
+		if fb.SkillID != "" {
+			s.refreshSkillSuccessRate(fb.SkillID)
+		}
+		s.adjustKnowledgeConfidence(target.knowledgeIDs, previous, rating)
+	}
+	if correctionChanged {
+		s.rememberFeedback(user.ID, fb)
+	}
+
+	return toFeedbackResponse(fb, ""), nil
+}
+
+// refreshSkillSuccessRate 以评价中的赞占比作为技能成功率
+func (s *AgentService) refreshSkillSuccessRate(skillID string) {
+	id, err := strconv.ParseUint(skillID, 10, 32)
+	if err != nil {
+		return
+	}
+	skill := s.repo.GetSkillByID(uint(id))
+	if skill == nil {
+		return
+	}
+	list, err := s.repo.ListFeedback(repository.FeedbackFilter{SkillID: skillID})
+	if err != nil || len(list) == 0 {
+		return
+	}
+	up := 0
+	for _, fb := range list {
+		if fb.Rating > 0 {
+			up++
+		}
+	}
+	skill.SuccessRate = math.Round(float64(up)/float64(len(list))*10000) / 10000
+	_ = s.repo.UpdateSkill(skill)
+}
+
+// adjustKnowledgeConfidence 先撤销旧评价的影响再施加新评价，反复切换赞/踩不会让置信度持续漂移
+func (s *AgentService) adjustKnowledgeConfidence(ids []string, previous, rating int) {
+	for _, id := range ids {
+		k, err := s.repo.GetKnowledgeByID(id)
+		if err != nil {
+			continue
+		}
+		confidence := applyRating(revertRating(k.Confidence, previous), rating)
+		_ = s.repo.UpdateKnowledgeConfidence(id, math.Round(confidence*10000)/10000)
+	}
+}
+
+func applyRating(confidence float64, rating int) float64 {
+	switch {
+	case rating > 0:
+		return confidence + knowledgeUpStep*(1-confidence)
+	case rating < 0:
+		return confidence - knowledgeDownStep*confidence
+	}
+	return confidence
+}
+
+// revertRating 是 applyRating 的逆运算
+func revertRating(confidence float64, rating int) float64 {
+	switch {
+	case rating > 0:
+		confidence = (confidence - knowledgeUpStep) / (1 - knowledgeUpStep)
+	case rating < 0:
+		confidence = confidence / (1 - knowledgeDownStep)
+	}
+	return math.Max(0, math.Min(1, confidence))
+}
+
+// rememberFeedback 将纠正或偏好写入用户经验，后续对话自动注入
+func (s *AgentService) rememberFeedback(userID uint, fb *model.AgentFeedback) {
+	category, content := "preference", "用户认可的做法："+fb.Correction
+	if fb.Rating < 0 {
+		category, content = "correction", "用户曾纠正助手的回答："+fb.Correction
+	}
+	_ = s.repo.CreateExperience(&model.AgentExperience{
+		UserID: userID, Category: category, Content: content, Weight: 1.0, DecayRate: 0.01, Status: "active",
+	})
+}
+
+func feedbackScope(user model.User) *uint {
+	if user.Role == model.RoleAdmin {
+		return nil
+	}
+	return user.FactoryID
+}
+
+func toFeedbackResponse(fb *model.AgentFeedback, excerpt string) *dto.FeedbackResponse {
+	return &dto.FeedbackResponse{
+		ID: fb.ID, TargetType: fb.TargetType, TargetID: fb.TargetID, Rating: ratingLabel(fb.Rating),
+		Correction: fb.Correction, Scenario: fb.Scenario, SkillID: fb.SkillID, Model: fb.Model,
+		ReviewStatus: fb.ReviewStatus, ReviewNote: fb.ReviewNote, Excerpt: excerpt, UserID: fb.UserID, CreatedAt: fb.CreatedAt,
+	}
+}
+
+// ListFeedbackReviews 负面评价复核队列（主管仅见本工厂）
+func (s *AgentService) ListFeedbackReviews(user model.User, status string) ([]dto.FeedbackResponse, error) {
+	if status == "" {
+		status = "pending"
+	}
+	list, err := s.repo.ListFeedback(repository.FeedbackFilter{FactoryID: feedbackScope(user), ReviewStatus: status, Limit: 200})
+	if err != nil {
+		return nil, err
+	}
+	results := make([]dto.FeedbackResponse, 0, len(list))
+	for i := range list {
+		excerpt := ""
+		if target, err := s.resolveFeedbackTarget(list[i].TargetType, list[i].TargetID); err == nil {
+			excerpt = truncateRunes(target.content, 200)
+		}
+		results = append(results, *toFeedbackResponse(&list[i], excerpt))
+	}
+	return results, nil
+}
+
+// ReviewFeedback 处理复核队列中的负面评价，可选将纠正沉淀为知识草稿
+func (s *AgentService) ReviewFeedback(reviewer model.User, id uint, req *dto.FeedbackReviewRequest) (*dto.FeedbackResponse, error) {
+	fb, err := s.repo.GetFeedbackByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if scope := feedbackScope(reviewer); scope != nil && (fb.FactoryID == nil || *fb.FactoryID != *scope) {
+		return nil, fmt.Errorf("%w: feedback belongs to another factory", ErrPermissionDenied)
+	}
+	if fb.ReviewStatus == "" {
+		return nil, ErrNotUnderReview
+	}
+
+	now := time.Now()
+	fb.ReviewStatus = req.Status
+	fb.ReviewNote = req.Note
+	fb.ReviewedBy = &reviewer.ID
+	fb.ReviewedAt = &now
+	if err := s.repo.SaveFeedback(fb); err != nil {
+		return nil, err
+	}
+
+	if req.CreateKnowledge && req.Status == "resolved" && fb.Correction != "" {
+		details, _ := json.Marshal(map[string]interface{}{
+			"source": "feedback", "feedback_id": fb.ID, "scenario": fb.Scenario, "review_note": req.Note,
+		})
+		_ = s.repo.CreateKnowledge(&model.AgentKnowledge{
+			ID: fmt.Sprintf("k_fb_%d", fb.ID), Title: "用户纠正：" + truncateRunes(fb.Correction, 40), Type: "correction",
+			Summary: fb.Correction, Details: string(details), Confidence: 0.6, Status: "draft",
+			CreatedBy: fmt.Sprintf("feedback:%d", fb.ID),
+		})
+	}
+	return toFeedbackResponse(fb, ""), nil
+}
+
+// FeedbackAnalytics 按场景、技能与模型统计回答质量
+func (s *AgentService) FeedbackAnalytics(user model.User, days int) (*dto.FeedbackAnalyticsResponse, error) {
+	if days <= 0 {
+		days = 30
+	}
+	since := time.Now().AddDate(0, 0, -days)
+	list, err := s.repo.ListFeedback(repository.FeedbackFilter{FactoryID: feedbackScope(user), Since: &since})
+	if err != nil {
+		return nil, err
+	}
+
+	res := &dto.FeedbackAnalyticsResponse{Days: days, Total: len(list)}
+	byScenario := map[string]*dto.FeedbackQualityItem{}
+	bySkill := map[string]*dto.FeedbackQualityItem{}
+	byModel := map[string]*dto.FeedbackQualityItem{}
+	up := 0
+	for _, fb := range list {
+		if fb.Rating > 0 {
+			up++
+		}
+		if fb.ReviewStatus == "pending" {
+			res.PendingReviews++
+		}
+		addQuality(byScenario, fb.Scenario, fb)
+		addQuality(byModel, fb.Model, fb)
+		if fb.SkillID != "" {
+			addQuality(bySkill, fb.SkillID, fb)
+		}
+	}
+	if len(list) > 0 {
+		res.Satisfaction = math.Round(float64(up)/float64(len(list))*10000) / 10000
+	}
+
+	res.ByScenario = sortedQuality(byScenario)
+	res.ByModel = sortedQuality(byModel)
+	res.BySkill = sortedQuality(bySkill)
+	for i := range res.BySkill {
+		if id, err := strconv.ParseUint(res.BySkill[i].Key, 10, 32); err == nil {
+			if skill := s.repo.GetSkillByID(uint(id)); skill != nil {
+				res.BySkill[i].Label = skill.Name
+			}
+		}
+	}
+	return res, nil
+}
+
+func addQuality(m map[string]*dto.FeedbackQualityItem, key string, fb model.AgentFeedback) {
+	if key == "" {
+		key = "unknown"
+	}
+	item, ok := m[key]
+	if !ok {
+		item = &dto.FeedbackQualityItem{Key: key}
+		m[key] = item
+	}
+	if fb.Rating > 0 {
+		item.Up++
+	} else {
+		item.Down++
+	}
+	if fb.Correction != "" {
+		item.Corrections++
+	}
+}
+
+// sortedQuality 按评价数降序输出，并计算满意度
+func sortedQuality(m map[string]*dto.FeedbackQualityItem) []dto.FeedbackQualityItem {
+	items := make([]dto.FeedbackQualityItem, 0, len(m))
+	for _, item := range m {
+		item.Satisfaction = math.Round(float64(item.Up)/float64(item.Up+item.Down)*10000) / 10000
+		items = append(items, *item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		ti, tj := items[i].Up+items[i].Down, items[j].Up+items[j].Down
+		if ti != tj {
+			return ti > tj
+		}
+		return items[i].Key < items[j].Key
+	})
+	return items
+}
+
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "..."
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/ems/backend/internal/agent/dto"
+	"github.com/ems/backend/internal/model"
+	"github.com/ems/backend/pkg/config"
+)
+
+func TestAgentService_SubmitFeedback(t *testing.T) {
+	config.Cfg = &config.Config{Storage: config.StorageConfig{Mode: "memory"}}
+	svc := NewAgentService()
+
+	owner := model.User{BaseModel: model.BaseModel{ID: 501}, Role: model.RoleMaintenance}
+	conv := &model.AgentConversation{UserID: owner.ID, Title: "主轴异响"}
+	_ = svc.repo.CreateConversation(conv)
+	skill := &model.AgentSkill{Name: "主轴异响排查", Steps: "[]", Status: "active"}
+	_ = svc.repo.CreateSkill(skill)
+	_ = svc.repo.CreateKnowledge(&model.AgentKnowledge{ID: "k_fb_test", Title: "轴承润滑不足", Confidence: 0.5, Status: "confirmed"})
+
+	ids := `["k_fb_test"]`
+	msg := &model.AgentMessage{ConversationID: conv.ID, Role: "assistant", Content: "建议更换轴承", SkillID: strconv.FormatUint(uint64(skill.ID), 10), KnowledgeIDs: &ids}
+	_ = svc.repo.CreateMessage(msg)
+
+	res, err := svc.SubmitFeedback(owner, &dto.FeedbackRequest{TargetType: "message", TargetID: msg.ID, Rating: "down", Correction: "应先检查润滑油位"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if res.ReviewStatus != "pending" || res.Scenario != "skill_execution" {
+		t.Errorf("Expected pending skill_execution feedback, got %+v", res)
+	}
+	if got := svc.repo.GetSkillByID(skill.ID).SuccessRate; got != 0 {
+		t.Errorf("Expected skill success rate 0, got %v", got)
+	}
+	if k, _ := svc.repo.GetKnowledgeByID("k_fb_test"); k.Confidence != 0.45 {
+		t.Errorf("Expected knowledge confidence 0.45, got %v", k.Confidence)
+	}
+	exps, _ := svc.repo.ListActiveExperiences(owner.ID)
+	if len(exps) != 1 || exps[0].Category != "correction" {
+		t.Errorf("Expected one correction experience, got %+v", exps)
+	}
+
+	// 改为点赞：同一用户同一对象只保留一条评价
+	res, _ = svc.SubmitFeedback(owner, &dto.FeedbackRequest{TargetType: "message", TargetID: msg.ID, Rating: "up"})
+	if res.ReviewStatus != "" {
+		t.Errorf("Expected positive feedback to leave the review queue, got %q", res.ReviewStatus)
+	}
+	if got := svc.repo.GetSkillByID(skill.ID).SuccessRate; got != 1 {
+		t.Errorf("Expected skill success rate 1, got %v", got)
+	}
+	// 撤销点踩后再点赞：0.5 + 0.05*0.5
+	if k, _ := svc.repo.GetKnowledgeByID("k_fb_test"); k.Confidence != 0.525 {
+		t.Errorf("Expected knowledge confidence 0.525, got %v", k.Confidence)
+	}
+	for i := 0; i < 5; i++ {
+		_, _ = svc.SubmitFeedback(owner, &dto.FeedbackRequest{TargetType: "message", TargetID: msg.ID, Rating: "down"})
+		_, _ = svc.SubmitFeedback(owner, &dto.FeedbackRequest{TargetType: "message", TargetID: msg.ID, Rating: "up"})
+	}
+	if k, _ := svc.repo.GetKnowledgeByID("k_fb_test"); k.Confidence != 0.525 {
+		t.Errorf("Expected repeated toggling to keep confidence at 0.525, got %v", k.Confidence)
+	}
+
+	other := model.User{BaseModel: model.BaseModel{ID: 502}, Role: model.RoleMaintenance}
+	if _, err := svc.SubmitFeedback(other, &dto.FeedbackRequest{TargetType: "message", TargetID: msg.ID, Rating: "down"}); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("Expected permission denied for another user, got %v", err)
+	}
+
+	admin := model.User{BaseModel: model.BaseModel{ID: 1}, Role: model.RoleAdmin}
+	analytics, err := svc.FeedbackAnalytics(admin, 7)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(analytics.BySkill) != 1 || analytics.BySkill[0].Label != "主轴异响排查" || analytics.BySkill[0].Up != 1 {
+		t.Errorf("Unexpected skill analytics: %+v", analytics.BySkill)
+	}
+}
+
+func TestAgentService_SubmitFeedbackOnArtifact(t *testing.T) {
+	config.Cfg = &config.Config{Storage: config.StorageConfig{Mode: "memory"}}
+	svc := NewAgentService()
+
+	owner := model.User{BaseModel: model.BaseModel{ID: 511}, Role: model.RoleSupervisor}
+	_ = svc.repo.CreateKnowledge(&model.AgentKnowledge{ID: "k_fb_artifact", Title: "冷却泵季节性故障", Confidence: 0.8, Status: "confirmed"})
+	session := &model.AgentSession{UserID: owner.ID, Scenario: "analysis", TraceID: "trace-fb-artifact"}
+	_ = svc.repo.CreateSession(session)
+	ids := `["k_fb_artifact"]`
+	artifact := &model.AgentArtifact{SessionID: session.ID, ArtifactType: "analysis_result", Summary: "夏季冷却泵故障率升高", KnowledgeIDs: &ids}
+	_ = svc.repo.CreateArtifact(artifact)
+
+	if _, err := svc.SubmitFeedback(owner, &dto.FeedbackRequest{TargetType: "artifact", TargetID: artifact.ID, Rating: "down"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if k, _ := svc.repo.GetKnowledgeByID("k_fb_artifact"); k.Confidence != 0.72 {
+		t.Errorf("Expected cited knowledge confidence 0.72, got %v", k.Confidence)
+	}
+}
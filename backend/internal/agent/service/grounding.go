@@ -0,0 +1,59 @@
+package service
+
+import (
+	"log"
+
+	"github.com/ems/backend/internal/agent/citation"
+	"github.com/ems/backend/internal/agent/dto"
+	"github.com/ems/backend/internal/model"
+)
+
+// groundSummary 校验 LLM 摘要中的证据引用，返回校验结果与修正后的风险等级。
+// 非 LLM 生成的兜底摘要直接取自分析器结果，无需校验；管理员自定义系统提示词时
+// 提示词中没有引用规则与编号证据，只报告校验结果，不据此调整风险等级。
+func (s *AgentService) groundSummary(summary string, generated, customPrompt bool, evidence []dto.EvidenceItem, riskLevel string, context ...interface{}) (*citation.Result, string) {
+	if !generated {
+		return &citation.Result{Citations: []dto.CitationItem{}, Confidence: 1}, riskLevel
+	}
+	g := citation.Validate(summary, evidence, context...)
+	adjusted := riskLevel
+	if !customPrompt {
+		adjusted = g.AdjustRiskLevel(riskLevel)
+	}
+	if len(g.Report.UnsupportedClaims) > 0 {
+		log.Printf("[AgentService] Grounding: %d/%d claims supported, unknown markers %v, risk %s -> %s",
+			g.Report.SupportedClaims, g.Report.TotalClaims, g.Report.UnknownMarkers, riskLevel, adjusted)
+	}
+	return g, adjusted
+}
+
+// saveEvidenceLinks 按证据顺序落库，返回的记录与 [E1]...[En] 一一对应
+func (s *AgentService) saveEvidenceLinks(artifactID uint, evidence []dto.EvidenceItem) []model.AgentEvidenceLink {
+	if artifactID == 0 || len(evidence) == 0 {
+		return nil
+	}
+	links := make([]model.AgentEvidenceLink, 0, len(evidence))
+	for _, ev := range evidence {
+		links = append(links, model.AgentEvidenceLink{
+			ArtifactID: artifactID, EvidenceType: ev.EvidenceType,
+			SourceTable: ev.SourceTable, SourceID: ev.SourceID, Excerpt: ev.Excerpt, Score: ev.Score,
+		})
+	}
+	if err := s.repo.CreateEvidenceLinks(links); err != nil {
+		log.Printf("[AgentService] Failed to create evidence links: %v", err)
+		return nil
+	}
+	return links
+}
+
+// withGrounding 将引用与校验结果写入响应
+func withGrounding(res *dto.AgentResponseEnvelope, g *citation.Result, links []model.AgentEvidenceLink) *dto.AgentResponseEnvelope {
+	g.BindLinks(links)
+	res.Confidence = g.Confidence
+	res.Citations = g.Citations
+	if g.Report.TotalClaims > 0 {
+		report := g.Report
+		res.Grounding = &report
+	}
+	return res
+}
@@ -0,0 +1,460 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ems/backend/internal/agent/dto"
+	"github.com/ems/backend/internal/agent/orchestrator"
+	"github.com/ems/backend/internal/model"
+	"github.com/ems/backend/pkg/llm"
+	"github.com/ems/backend/pkg/trace"
+)
+
+const (
+	defaultOrchestrationConcurrency = 4
+	orchestrationTaskTimeout        = 60 * time.Second
+	orchestrationTimeout            = 5 * time.Minute
+	// 汇总提示词中单个子任务结果的最大长度
+	maxSubTaskDataRunes = 1500
+)
+
+// orchestrationAnalyzers 编排可绑定的分析器及其参数说明
+var orchestrationAnalyzers = []struct {
+	name        string
+	description string
+	args        string
+}{
+	{"maintenance_recommend", "保养计划优化建议：评估保养周期与项目是否合理", `{"equipment_type_id": 整数, "workshop_id": 整数, "equipment_ids": [整数]}`},
+	{"maintenance_audit", "保养执行合规审计：延期、漏检与计划偏差", `{"equipment_type_id": 整数}`},
+	{"repair_audit", "维修审计：短期重复故障、费用异常", `{"equipment_type_id": 整数, "workshop_id": 整数}`},
+	{"root_cause", "机群根因分析：同型设备的故障聚类，按备件批次、近期保养、维修人员、车间排序根因假设", `{"equipment_type_id": 整数, "workshop_id": 整数, "fault_code": 字符串, "keyword": 字符串}`},
+	{"predictive", "预测性分析：逐台设备的剩余寿命(RUL)与总持有成本(TCO)", `{"equipment_ids": [整数]}`},
+}
+
+var taskRefRe = regexp.MustCompile(`^\$\{(\w+)\.(\w+)\}$`)
+
+// Orchestrate 规划-执行编排：规划器将问题拆解为子任务 DAG，执行器按依赖并行运行分析器或工具，
+// 汇总器将结果与全部证据合并为一份产物
+func (s *AgentService) Orchestrate(user model.User, req *dto.OrchestrateRequest) (*dto.AgentResponseEnvelope, error) {
+	startTime := time.Now()
+	traceID := trace.GenerateTraceID()
+
+	agentCtx, err := s.policy.DeriveAgentContext(user.ID, string(user.Role), req.Language)
+	if err != nil {
+		return nil, err
+	}
+	targetFactoryID := req.FactoryID
+	if targetFactoryID == 0 && agentCtx.FactoryID != nil {
+		targetFactoryID = *agentCtx.FactoryID
+	}
+	if err := s.policy.ValidateScope(agentCtx, &targetFactoryID); err != nil {
+		return nil, err
+	}
+
+	// 1. 规划
+	plan, planner := s.planOrchestration(user, req.Question)
+
+	// 2. 并行执行
+	concurrency := req.MaxConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultOrchestrationConcurrency
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), orchestrationTimeout)
+	defer cancel()
+	results := orchestrator.Run(ctx, plan, s.orchestrationExecutor(user, targetFactoryID, req), concurrency)
+
+	// 3. 汇总
+	evidence, offsets := orchestrator.CombinedEvidence(results)
+	data := &dto.OrchestrationData{Goal: plan.Goal, Planner: planner, Tasks: make([]dto.SubTaskResult, len(results)), Evidence: evidence}
+	completed := 0
+	for i, r := range results {
+		data.Tasks[i] = dto.SubTaskResult{
+			ID: r.Task.ID, Title: r.Task.Title, Kind: r.Task.Kind, Target: r.Task.Target, Args: r.Task.Args,
+			DependsOn: r.Task.DependsOn, Status: r.Status, Summary: r.Output.Summary, Error: r.Error,
+			DurationMs: r.DurationMs, Data: r.Output.Data,
+		}
+		if start, ok := offsets[r.Task.ID]; ok {
+			data.Tasks[i].EvidenceRange = evidenceRange(start, len(r.Output.Evidence))
+		}
+		if r.Status == orchestrator.StatusCompleted {
+			completed++
+		}
+	}
+	if completed == 0 {
+		return nil, fmt.Errorf("all %d sub-tasks failed", len(results))
+	}
+
+	summary := fallbackSynthesis(data)
+	generated := false
+	var knowledgeIDs []string
+	if s.llmClient != nil {
+		confirmedContext, confirmedIDs := s.confirmedKnowledge(req.Question)
+		resp, err := s.llmFor(user).ChatCompletion([]llm.Message{
+			{Role: "system", Content: "你是一个顶级的工业资产战略分析师。"},
+			{Role: "user", Content: s.promptTool.BuildSynthesisPrompt(plan.Goal, synthesisInput(data), evidence) + confirmedContext},
+		})
+		if err != nil {
+			log.Printf("[AgentService] LLM synthesis failed in Orchestrate: %v", err)
+		} else if resp != "" {
+			summary, generated = resp, true
+			knowledgeIDs = confirmedIDs
+		}
+	}
+
+	status := "completed"
+	if completed < len(results) {
+		status = "partial"
+	}
+	inputSnap, _ := json.Marshal(req)
+	resultJSON, _ := json.Marshal(data)
+	session := &model.AgentSession{
+		UserID: user.ID, Scenario: "orchestration", FactoryID: &targetFactoryID,
+		Language: agentCtx.Language, InputSnapshot: string(inputSnap), TraceID: traceID, Status: status,
+	}
+	if err := s.repo.CreateSession(session); err != nil {
+		log.Printf("[AgentService] Failed to create session: %v", err)
+		return nil, err
+	}
+
+	grounding, riskLevel := s.groundSummary(summary, generated, false, evidence, "medium", data.Tasks)
+	artifact := &model.AgentArtifact{
+		SessionID: session.ID, ArtifactType: "orchestration_report", Title: "综合分析报告",
+		Summary: summary, ResultJSON: string(resultJSON), RiskLevel: riskLevel, KnowledgeIDs: knowledgeIDsJSON(knowledgeIDs),
+	}
+	if err := s.repo.CreateArtifact(artifact); err != nil {
+		log.Printf("[AgentService] Failed to create artifact: %v", err)
+	}
+	links := s.saveEvidenceLinks(artifact.ID, evidence)
+
+	scope := map[string]interface{}{"factory_id": targetFactoryID, "sub_tasks": len(results), "completed": completed}
+	res := &dto.AgentResponseEnvelope{
+		Success: true, TraceID: traceID, Language: agentCtx.Language, Scenario: "orchestration",
+		ScopeSummary: scope, Summary: summary, RiskLevel: riskLevel, ArtifactID: artifact.ID,
+		EvidenceCount: len(evidence), Data: data,
+	}
+	s.logUsage(session.ID, user.ID, "orchestration", startTime)
+	return withGrounding(res, grounding, links), nil
+}
+
+// planOrchestration 由 LLM 规划任务 DAG，规划失败或未配置 LLM 时退回规则计划
+func (s *AgentService) planOrchestration(user model.User, question string) (*orchestrator.Plan, string) {
+	if s.llmClient != nil {
+		resp, err := s.llmFor(user).ChatCompletion([]llm.Message{
+			{Role: "system", Content: "你是设备管理分析的任务规划器，只输出 JSON。"},
+			{Role: "user", Content: s.promptTool.BuildPlannerPrompt(question, s.orchestrationBindings(), orchestrator.MaxTasks)},
+		})
+		if err == nil {
+			plan, perr := orchestrator.ParsePlan(resp)
+			if perr == nil {
+				perr = orchestrator.Validate(plan, s.knownBinding)
+			}
+			if perr == nil {
+				if plan.Goal == "" {
+					plan.Goal = question
+				}
+				return plan, "llm"
+			}
+			err = perr
+		}
+		log.Printf("[AgentService] Planner failed, falling back to rule-based plan: %v", err)
+	}
+	return s.defaultPlan(user, question), "rule"
+}
+
+// defaultPlan 规则计划：保养、维修三个分析器并行执行，识别到具体设备时追加预测性分析
+func (s *AgentService) defaultPlan(user model.User, question string) *orchestrator.Plan {
+	plan := &orchestrator.Plan{Goal: question, Tasks: []orchestrator.Task{
+		{ID: "t1", Title: "保养计划优化建议", Kind: orchestrator.KindAnalyzer, Target: "maintenance_recommend"},
+		{ID: "t2", Title: "保养执行合规审计", Kind: orchestrator.KindAnalyzer, Target: "maintenance_audit"},
+		{ID: "t3", Title: "维修异常审计", Kind: orchestrator.KindAnalyzer, Target: "repair_audit"},
+	}}
+	if eqID, ok := s.extractEquipmentID(question, user); ok {
+		plan.Tasks = append(plan.Tasks, orchestrator.Task{
+			ID: "t4", Title: "剩余寿命与持有成本预测", Kind: orchestrator.KindAnalyzer, Target: "predictive",
+			Args: map[string]interface{}{"equipment_ids": []uint{eqID}},
+		})
+	}
+	return plan
+}
+
+// orchestrationBindings 列出规划器可用的分析器与只读工具（编排中不开放写操作）
+func (s *AgentService) orchestrationBindings() string {
+	var b strings.Builder
+	b.WriteString("分析器 (kind=analyzer)：\n")
+	for _, a := range orchestrationAnalyzers {
+		fmt.Fprintf(&b, "- %s：%s，参数 %s\n", a.name, a.description, a.args)
+	}
+	b.WriteString("工具 (kind=tool)：\n")
+	defs := s.toolRegistry.List(model.User{})
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	for _, def := range defs {
+		if entry, ok := s.toolRegistry.GetTool(def.Name); !ok || !entry.IsReadOnly {
+			continue
+		}
+		schema, _ := json.Marshal(def.InputSchema)
+		fmt.Fprintf(&b, "- %s：%s，参数 %s\n", def.Name, def.Description, schema)
+	}
+	return b.String()
+}
+
+func (s *AgentService) knownBinding(kind, target string) bool {
+	switch kind {
+	case orchestrator.KindAnalyzer:
+		for _, a := range orchestrationAnalyzers {
+			if a.name == target {
+				return true
+			}
+		}
+	case orchestrator.KindTool:
+		entry, ok := s.toolRegistry.GetTool(target)
+		return ok && entry.IsReadOnly
+	}
+	return false
+}
+
+// orchestrationExecutor 将子任务绑定到分析器或工具执行，单个子任务超时后不再等待其结果
+func (s *AgentService) orchestrationExecutor(user model.User, factoryID uint, req *dto.OrchestrateRequest) orchestrator.Executor {
+	return func(ctx context.Context, task orchestrator.Task, deps map[string]orchestrator.Output) (orchestrator.Output, error) {
+		args := resolveTaskArgs(task.Args, deps)
+		ctx, cancel := context.WithTimeout(ctx, orchestrationTaskTimeout)
+		defer cancel()
+
+		type outcome struct {
+			out orchestrator.Output
+			err error
+		}
+		ch := make(chan outcome, 1)
+		go func() {
+			// 子任务不在请求 goroutine 中运行，需自行恢复 panic，否则会使整个进程崩溃
+			defer func() {
+				if p := recover(); p != nil {
+					log.Printf("[AgentService] Sub-task %s (%s) panicked: %v\n%s", task.ID, task.Target, p, debug.Stack())
+					ch <- outcome{err: fmt.Errorf("sub-task %s failed unexpectedly", task.ID)}
+				}
+			}()
+			var o outcome
+			if task.Kind == orchestrator.KindTool {
+				o.out, o.err = s.runToolTask(ctx, user, task.Target, args)
+			} else {
+				o.out, o.err = s.runAnalyzerTask(user, factoryID, req, task.Target, args)
+			}
+			ch <- o
+		}()
+		select {
+		case o := <-ch:
+			return o.out, o.err
+		case <-ctx.Done():
+			return orchestrator.Output{}, fmt.Errorf("sub-task %s timed out: %w", task.ID, ctx.Err())
+		}
+	}
+}
+
+func (s *AgentService) runAnalyzerTask(user model.User, factoryID uint, req *dto.OrchestrateRequest, target string, args map[string]interface{}) (orchestrator.Output, error) {
+	raw, _ := json.Marshal(args)
+	switch target {
+	case "maintenance_recommend":
+		var r dto.MaintenanceRecommendRequest
+		_ = json.Unmarshal(raw, &r)
+		r.FactoryID, r.TimeRange, r.Question, r.Language, r.SystemPrompt = factoryID, req.TimeRange, req.Question, req.Language, ""
+		data, err := s.maintenanceAnalyzer.Analyze(&r, user)
+		if err != nil {
+			return orchestrator.Output{}, err
+		}
+		summary := fmt.Sprintf("生成 %d 条保养优化建议", len(data.Recommendations))
+		if len(data.Recommendations) > 0 {
+			summary += "：" + data.Recommendations[0].Description
+		}
+		return orchestrator.Output{Summary: summary, Data: data, Evidence: data.Evidence}, nil
+	case "maintenance_audit":
+		var r dto.MaintenanceAuditRequest
+		_ = json.Unmarshal(raw, &r)
+		r.FactoryID, r.TimeRange, r.Language, r.SystemPrompt = factoryID, req.TimeRange, req.Language, ""
+		data, err := s.maintenanceAnalyzer.Audit(&r, user)
+		if err != nil {
+			return orchestrator.Output{}, err
+		}
+		return orchestrator.Output{Summary: data.AuditSummary, Data: data, Evidence: data.Evidence}, nil
+	case "repair_audit":
+		var r dto.RepairAuditRequest
+		_ = json.Unmarshal(raw, &r)
+		r.FactoryID, r.TimeRange, r.Language, r.SystemPrompt = factoryID, req.TimeRange, req.Language, ""
+		data, err := s.repairAuditAnalyzer.Analyze(&r, user)
+		if err != nil {
+			return orchestrator.Output{}, err
+		}
+		summary := fmt.Sprintf("发现 %d 项维修异常", len(data.Anomalies))
+		if stats, ok := data.Stats.(map[string]interface{}); ok {
+			if v, ok := stats["anomaly_summary"].(string); ok && v != "" {
+				summary = v
+			}
+		}
+		return orchestrator.Output{Summary: summary, Data: data, Evidence: data.Evidence}, nil
+	case "root_cause":
+		var r dto.RootCauseRequest
+		_ = json.Unmarshal(raw, &r)
+		r.FactoryID, r.TimeRange, r.Language, r.SystemPrompt = factoryID, req.TimeRange, req.Language, ""
+		data, err := s.rootCauseAnalyzer.Analyze(&r, user)
+		if err != nil {
+			return orchestrator.Output{}, err
+		}
+		summary := fmt.Sprintf("识别 %d 个故障簇、%d 条根因假设", len(data.Clusters), len(data.Hypotheses))
+		if v, ok := data.Stats["root_cause_summary"].(string); ok && v != "" {
+			summary = v
+		}
+		return orchestrator.Output{Summary: summary, Data: data, Evidence: data.Evidence}, nil
+	case "predictive":
+		var r struct {
+			EquipmentIDs []uint `json:"equipment_ids"`
+		}
+		_ = json.Unmarshal(raw, &r)
+		return s.runPredictiveTask(user, r.EquipmentIDs)
+	}
+	return orchestrator.Output{}, fmt.Errorf("unknown analyzer: %s", target)
+}
+
+// runPredictiveTask 逐台设备预测 RUL 与 TCO；无权访问或缺少数据的设备记入结果但不中断任务
+func (s *AgentService) runPredictiveTask(user model.User, equipmentIDs []uint) (orchestrator.Output, error) {
+	if len(equipmentIDs) == 0 {
+		return orchestrator.Output{}, fmt.Errorf("predictive analyzer requires equipment_ids")
+	}
+	type row struct {
+		EquipmentID uint               `json:"equipment_id"`
+		RUL         *dto.RULPrediction `json:"rul,omitempty"`
+		TCO         interface{}        `json:"tco,omitempty"`
+		Error       string             `json:"error,omitempty"`
+	}
+	rows := make([]row, 0, len(equipmentIDs))
+	var evidence []dto.EvidenceItem
+	atRisk := 0
+	for _, id := range equipmentIDs {
+		r := row{EquipmentID: id}
+		rul, err := s.predictiveAnalyzer.PredictRUL(id, user)
+		if err != nil {
+			r.Error = err.Error()
+			rows = append(rows, r)
+			continue
+		}
+		r.RUL = rul
+		if tco, err := s.predictiveAnalyzer.CalculateTCO(id, user); err == nil {
+			r.TCO = tco
+		}
+		if rul.EstimatedRULDays < 30 {
+			atRisk++
+		}
+		evidence = append(evidence, dto.EvidenceItem{
+			EvidenceType: "prediction", SourceTable: "equipment", SourceID: id, Title: "RUL 预测",
+			Excerpt: fmt.Sprintf("设备 %s 预计剩余寿命 %d 天，健康分 %.1f", rul.EquipmentCode, rul.EstimatedRULDays, rul.HealthScore),
+			Score:   rul.Reliability,
+		})
+		rows = append(rows, r)
+	}
+	if len(evidence) == 0 {
+		return orchestrator.Output{}, fmt.Errorf("no equipment could be analyzed: %s", rows[0].Error)
+	}
+	return orchestrator.Output{
+		Summary:  fmt.Sprintf("完成 %d 台设备预测，其中 %d 台剩余寿命不足 30 天", len(evidence), atRisk),
+		Data:     rows,
+		Evidence: evidence,
+	}, nil
+}
+
+func (s *AgentService) runToolTask(ctx context.Context, user model.User, name string, args map[string]interface{}) (orchestrator.Output, error) {
+	entry, ok := s.toolRegistry.GetTool(name)
+	if !ok || !entry.IsReadOnly {
+		return orchestrator.Output{}, fmt.Errorf("tool %s is not available for orchestration", name)
+	}
+	res, err := s.toolRegistry.Call(ctx, name, user, args, nil)
+	if err != nil {
+		return orchestrator.Output{}, err
+	}
+	if evs, ok := res.([]dto.EvidenceItem); ok {
+		return orchestrator.Output{Summary: fmt.Sprintf("检索到 %d 条知识", len(evs)), Data: evs, Evidence: evs}, nil
+	}
+	resJSON, _ := json.Marshal(res)
+	return orchestrator.Output{
+		Summary: truncateRunes(string(resJSON), 200),
+		Data:    res,
+		Evidence: []dto.EvidenceItem{{
+			EvidenceType: "tool_result", Title: entry.Definition.Description, Excerpt: string(resJSON), Score: 0.9,
+		}},
+	}, nil
+}
+
+// resolveTaskArgs 将 "${任务ID.字段}" 形式的参数替换为依赖任务结果中的对应字段
+func resolveTaskArgs(args map[string]interface{}, deps map[string]orchestrator.Output) map[string]interface{} {
+	resolved := make(map[string]interface{}, len(args))
+	for k, v := range args {
+		resolved[k] = v
+		ref, ok := v.(string)
+		if !ok {
+			continue
+		}
+		m := taskRefRe.FindStringSubmatch(ref)
+		if m == nil {
+			continue
+		}
+		dep, ok := deps[m[1]]
+		if !ok {
+			continue
+		}
+		var fields map[string]interface{}
+		raw, _ := json.Marshal(dep.Data)
+		if json.Unmarshal(raw, &fields) == nil {
+			if val, ok := fields[m[2]]; ok {
+				resolved[k] = val
+			}
+		}
+	}
+	return resolved
+}
+
+func evidenceRange(start, count int) string {
+	if count <= 1 {
+		return fmt.Sprintf("E%d", start)
+	}
+	return fmt.Sprintf("E%d-E%d", start, start+count-1)
+}
+
+// synthesisInput 渲染汇总器输入，标明每个子任务对应的证据编号范围
+func synthesisInput(data *dto.OrchestrationData) string {
+	var b strings.Builder
+	for _, t := range data.Tasks {
+		fmt.Fprintf(&b, "#### [%s] %s（%s:%s，%s）\n", t.ID, t.Title, t.Kind, t.Target, t.Status)
+		if t.Status != orchestrator.StatusCompleted {
+			fmt.Fprintf(&b, "未完成：%s\n\n", t.Error)
+			continue
+		}
+		if t.EvidenceRange != "" {
+			fmt.Fprintf(&b, "证据编号：%s\n", t.EvidenceRange)
+		}
+		raw, _ := json.Marshal(t.Data)
+		fmt.Fprintf(&b, "摘要：%s\n数据：%s\n\n", t.Summary, truncateRunes(string(raw), maxSubTaskDataRunes))
+	}
+	return b.String()
+}
+
+// fallbackSynthesis 未配置 LLM 或汇总失败时按子任务罗列结论
+func fallbackSynthesis(data *dto.OrchestrationData) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "已围绕“%s”完成 %d 个子任务的分析：\n", data.Goal, len(data.Tasks))
+	for _, t := range data.Tasks {
+		if t.Status != orchestrator.StatusCompleted {
+			fmt.Fprintf(&b, "- %s：未完成（%s）\n", t.Title, t.Error)
+			continue
+		}
+		fmt.Fprintf(&b, "- %s：%s", t.Title, t.Summary)
+		if t.EvidenceRange != "" {
+			fmt.Fprintf(&b, "（证据 %s）", t.EvidenceRange)
+		}
+		b.WriteString("\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
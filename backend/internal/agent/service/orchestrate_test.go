@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ems/backend/internal/agent/dto"
+	"github.com/ems/backend/internal/agent/orchestrator"
+	"github.com/ems/backend/internal/model"
+	"github.com/ems/backend/pkg/config"
+	"github.com/ems/backend/pkg/memory"
+)
+
+func TestResolveTaskArgs(t *testing.T) {
+	deps := map[string]orchestrator.Output{
+		"t1": {Data: map[string]interface{}{"equipment_ids": []uint{3, 5}, "equipment_type_id": 12}},
+	}
+	args := resolveTaskArgs(map[string]interface{}{
+		"equipment_ids":     "${t1.equipment_ids}",
+		"equipment_type_id": "${t1.equipment_type_id}",
+		"keyword":           "主轴",
+		"missing":           "${t9.x}",
+	}, deps)
+
+	if ids, ok := args["equipment_ids"].([]interface{}); !ok || len(ids) != 2 {
+		t.Errorf("Expected equipment_ids resolved from t1, got %#v", args["equipment_ids"])
+	}
+	if args["equipment_type_id"] != float64(12) || args["keyword"] != "主轴" || args["missing"] != "${t9.x}" {
+		t.Errorf("Unexpected resolved args: %#v", args)
+	}
+}
+
+func TestAgentService_OrchestrateWithRulePlan(t *testing.T) {
+	config.Cfg = &config.Config{Storage: config.StorageConfig{Mode: "memory"}}
+	svc := NewAgentService()
+	store := memory.GetStore()
+	admin := model.User{BaseModel: model.BaseModel{ID: store.NextID()}, Username: "orchestrate_admin", Role: model.RoleAdmin}
+	store.Users[admin.ID] = &admin
+
+	res, err := svc.Orchestrate(admin, &dto.OrchestrateRequest{Question: "规划下季度 A 工厂的保养预算", MaxConcurrency: 2})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	data, ok := res.Data.(*dto.OrchestrationData)
+	if !ok {
+		t.Fatalf("Unexpected data type %T", res.Data)
+	}
+	if data.Planner != "rule" || len(data.Tasks) < 3 {
+		t.Errorf("Expected rule-based plan with analyzer tasks, got %+v", data)
+	}
+	if res.ArtifactID == 0 || res.Scenario != "orchestration" || res.EvidenceCount != len(data.Evidence) {
+		t.Errorf("Unexpected envelope: %+v", res)
+	}
+	for _, task := range data.Tasks {
+		if task.Status == "" {
+			t.Errorf("task %s has no status", task.ID)
+		}
+	}
+}
+
+func TestOrchestrationExecutorRecoversPanic(t *testing.T) {
+	// 未初始化分析器的服务在执行子任务时会触发空指针 panic
+	svc := &AgentService{}
+	exec := svc.orchestrationExecutor(model.User{Role: model.RoleAdmin}, 1, &dto.OrchestrateRequest{})
+	_, err := exec(context.Background(), orchestrator.Task{ID: "t1", Kind: orchestrator.KindAnalyzer, Target: "maintenance_recommend"}, nil)
+	if err == nil {
+		t.Fatal("Expected the panicking sub-task to fail with an error")
+	}
+}
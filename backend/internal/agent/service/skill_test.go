@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ems/backend/internal/agent/dto"
+	"github.com/ems/backend/internal/model"
+	"github.com/ems/backend/pkg/config"
+	"github.com/ems/backend/pkg/llm"
+)
+
+// scriptedLLM 第一轮请求写工具，第二轮给出最终回答
+type scriptedLLM struct {
+	offered  []string
+	toolMsgs []string
+	rounds   int
+}
+
+func (c *scriptedLLM) ChatCompletion(_ []llm.Message) (string, error) { return "", nil }
+
+func (c *scriptedLLM) ChatWithTools(messages []llm.Message, tools []llm.Tool) (*llm.Message, error) {
+	c.rounds++
+	if c.rounds == 1 {
+		for _, t := range tools {
+			c.offered = append(c.offered, t.Function.Name)
+		}
+		call := llm.ToolCall{ID: "call_1", Type: "function"}
+		call.Function.Name = "report_repair"
+		call.Function.Arguments = `{"equipment_id":1,"fault_description":"主轴异响"}`
+		return &llm.Message{Role: "assistant", ToolCalls: []llm.ToolCall{call}}, nil
+	}
+	for _, m := range messages {
+		if m.Role == "tool" {
+			c.toolMsgs = append(c.toolMsgs, m.Content)
+		}
+	}
+	return &llm.Message{Role: "assistant", Content: "已完成分析。"}, nil
+}
+
+func TestExecuteSkillOffersOnlyReadOnlyTools(t *testing.T) {
+	config.Cfg = &config.Config{Storage: config.StorageConfig{Mode: "memory"}}
+	svc := NewAgentService()
+	fake := &scriptedLLM{}
+	svc.llmClient = fake
+
+	user := model.User{BaseModel: model.BaseModel{ID: 1}, Role: model.RoleAdmin}
+	skill := &model.AgentSkill{Name: "主轴异响排查", Steps: "[]"}
+	if _, err := svc.ExecuteSkill(context.Background(), user, skill, &dto.ChatRequest{Message: "主轴异响"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(fake.offered) == 0 {
+		t.Fatal("Expected read-only tools to be offered")
+	}
+	for _, name := range fake.offered {
+		if entry, ok := svc.toolRegistry.GetTool(name); !ok || !entry.IsReadOnly {
+			t.Errorf("Expected only read-only tools, got %s", name)
+		}
+	}
+	if len(fake.toolMsgs) != 1 || !strings.Contains(fake.toolMsgs[0], "not available during skill execution") {
+		t.Errorf("Expected write tool call to be refused, got %v", fake.toolMsgs)
+	}
+}
@@ -0,0 +1,290 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/ems/backend/internal/agent/dto"
+	"github.com/ems/backend/internal/agent/pushfilter"
+	"github.com/ems/backend/internal/agent/tool"
+	"github.com/ems/backend/internal/agent/webhook"
+	"github.com/ems/backend/internal/event"
+	"github.com/ems/backend/internal/model"
+)
+
+var (
+	ErrSubscriptionNotFound = errors.New("push subscription not found")
+	ErrDeliveryNotFound     = errors.New("webhook delivery not found")
+	ErrSubscriptionDisabled = errors.New("push subscription is paused or disabled")
+	ErrInvalidWebhookURL    = errors.New("webhook_url must be an absolute http(s) URL")
+)
+
+// filterCache 按 scope 原文缓存编译后的过滤器，避免每个事件重复解析
+var filterCache sync.Map
+
+// subscriptionFilter 返回订阅的过滤器；历史上保存的非法 scope 不做限制，仅记录日志
+func subscriptionFilter(sub *model.AgentPushSubscription) *pushfilter.Filter {
+	if cached, ok := filterCache.Load(sub.Scope); ok {
+		return cached.(*pushfilter.Filter)
+	}
+	f, err := pushfilter.Parse(sub.Scope)
+	if err != nil {
+		log.Printf("[AgentService] Ignoring invalid scope of subscription %d: %v", sub.ID, err)
+		f = nil
+	}
+	filterCache.Store(sub.Scope, f)
+	return f
+}
+
+// PushSchemaVersion 推送负载的结构版本；字段只增不减，破坏性变更时递增
+const PushSchemaVersion = "1"
+
+const (
+	pushEventAgentAlert  = "agent_alert"
+	pushEventPing        = "ping"
+	defaultDeliveryLimit = 50
+)
+
+// AgentAlertPush Agent 产物（风险预警、定时简报等）的推送负载
+type AgentAlertPush struct {
+	Event      string `json:"event"`
+	Version    string `json:"version"`
+	ArtifactID uint   `json:"artifact_id"`
+	Title      string `json:"title"`
+	Summary    string `json:"summary"`
+	RiskLevel  string `json:"risk_level"`
+	Timestamp  int64  `json:"timestamp"`
+}
+
+// PingPush 测试投递负载
+type PingPush struct {
+	Event          string    `json:"event"`
+	Version        string    `json:"version"`
+	SubscriptionID uint      `json:"subscription_id"`
+	PushType       string    `json:"push_type"`
+	Message        string    `json:"message"`
+	SentAt         time.Time `json:"sent_at"`
+}
+
+var pushEventDescriptions = map[string]string{
+	string(event.RepairCreated):             "新建维修工单（人工报修或点检 NG 自动创建）",
+	string(event.RepairStatusChanged):       "维修工单状态流转",
+	string(event.InspectionNGDetected):      "点检发现不合格项",
+	string(event.MaintenanceOverdue):        "保养任务逾期",
+	string(event.SparePartBelowSafetyStock): "备件库存跌破安全库存",
+	string(event.EquipmentStatusChanged):    "设备状态变化",
+	string(event.EquipmentRULRisk):          "设备预测剩余寿命不足一周",
+	string(event.RepairSLABreached):         "维修工单超出响应或修复时限",
+	pushEventAgentAlert:                     "Agent 产物推送（风险预警、定时简报）",
+	pushEventPing:                           "测试投递",
+}
+
+// PushSchemas 返回各推送类型当前版本的负载 JSON Schema
+func (s *AgentService) PushSchemas() []dto.PushSchemaResponse {
+	var out []dto.PushSchemaResponse
+	for _, t := range event.Types {
+		schema := tool.SchemaOf(DomainEventPush{})
+		schema["properties"].(map[string]interface{})["data"] = tool.SchemaOf(event.NewPayload(t))
+		out = append(out, dto.PushSchemaResponse{
+			Event: string(t), Version: PushSchemaVersion, Description: pushEventDescriptions[string(t)], Schema: schema,
+		})
+	}
+	out = append(out,
+		dto.PushSchemaResponse{Event: pushEventAgentAlert, Version: PushSchemaVersion, Description: pushEventDescriptions[pushEventAgentAlert], Schema: tool.SchemaOf(AgentAlertPush{})},
+		dto.PushSchemaResponse{Event: pushEventPing, Version: PushSchemaVersion, Description: pushEventDescriptions[pushEventPing], Schema: tool.SchemaOf(PingPush{})},
+	)
+	return out
+}
+
+// validateWebhookURL 空地址表示仅站内订阅；主机解析到回环、链路本地或内网地址时拒绝
+func (s *AgentService) validateWebhookURL(ctx context.Context, raw string) error {
+	if raw == "" {
+		return nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidWebhookURL
+	}
+	if err := s.webhooks.CheckURL(ctx, raw); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidWebhookURL, err)
+	}
+	return nil
+}
+
+// ownedSubscription 加载订阅并校验归属
+func (s *AgentService) ownedSubscription(userID, subscriptionID uint) (*model.AgentPushSubscription, error) {
+	sub, err := s.repo.GetPushSubscriptionByID(subscriptionID)
+	if err != nil || sub == nil {
+		return nil, ErrSubscriptionNotFound
+	}
+	if sub.UserID != userID {
+		return nil, ErrPermissionDenied
+	}
+	return sub, nil
+}
+
+// UpdateSubscription 修改订阅的地址、范围或启用状态，未提供的字段保持不变
+func (s *AgentService) UpdateSubscription(userID, subscriptionID uint, req *dto.UpdateSubscriptionRequest) (*model.AgentPushSubscription, error) {
+	sub, err := s.ownedSubscription(userID, subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	if req.WebhookURL != nil {
+		if err := s.validateWebhookURL(context.Background(), *req.WebhookURL); err != nil {
+			return nil, err
+		}
+		sub.WebhookURL = *req.WebhookURL
+	}
+	if len(req.Scope) > 0 {
+		if _, err := pushfilter.Parse(string(req.Scope)); err != nil {
+			return nil, err
+		}
+		sub.Scope = string(req.Scope)
+	}
+	if req.Enabled != nil {
+		setSubscriptionEnabled(sub, *req.Enabled, time.Now())
+	}
+	if err := s.repo.CreatePushSubscription(sub); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// SetSubscriptionPaused 暂停或恢复订阅；暂停期间不再产生新投递，队列中的投递转入死信，可在恢复后重投
+func (s *AgentService) SetSubscriptionPaused(userID, subscriptionID uint, paused bool) (*model.AgentPushSubscription, error) {
+	sub, err := s.ownedSubscription(userID, subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	setSubscriptionEnabled(sub, !paused, time.Now())
+	if err := s.repo.CreatePushSubscription(sub); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+func setSubscriptionEnabled(sub *model.AgentPushSubscription, enabled bool, now time.Time) {
+	if enabled {
+		// 恢复时清除自动停用状态
+		sub.Enabled, sub.ConsecutiveFailures, sub.DisabledAt, sub.DisabledReason = true, 0, nil, ""
+		return
+	}
+	if sub.Enabled {
+		sub.Enabled, sub.DisabledAt, sub.DisabledReason = false, &now, "paused by user"
+	}
+}
+
+func (s *AgentService) DeleteSubscription(userID, subscriptionID uint) error {
+	if _, err := s.ownedSubscription(userID, subscriptionID); err != nil {
+		return err
+	}
+	return s.repo.DeletePushSubscription(subscriptionID)
+}
+
+// RotateSubscriptionSecret 生成新的签名密钥；旧密钥在宽限期内仍会随请求一起签名
+func (s *AgentService) RotateSubscriptionSecret(userID, subscriptionID uint) (string, error) {
+	sub, err := s.ownedSubscription(userID, subscriptionID)
+	if err != nil {
+		return "", err
+	}
+	secret, err := webhook.GenerateSecret()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	sub.PreviousSecret, sub.Secret, sub.SecretRotatedAt = sub.Secret, secret, &now
+	if err := s.repo.CreatePushSubscription(sub); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// ListDeliveries 返回订阅最近的投递记录
+func (s *AgentService) ListDeliveries(userID, subscriptionID uint, q *dto.DeliveryQuery) ([]dto.WebhookDeliveryResponse, error) {
+	if _, err := s.ownedSubscription(userID, subscriptionID); err != nil {
+		return nil, err
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultDeliveryLimit
+	}
+	logs, err := s.repo.ListPushLogs(subscriptionID, q.Status, limit)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]dto.WebhookDeliveryResponse, len(logs))
+	for i := range logs {
+		results[i] = toDeliveryResponse(&logs[i])
+	}
+	return results, nil
+}
+
+// Redeliver 以原负载重新投递一次，签名使用当前密钥与时间戳
+func (s *AgentService) Redeliver(userID, subscriptionID, deliveryID uint) (*dto.WebhookDeliveryResponse, error) {
+	sub, err := s.ownedSubscription(userID, subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	src, err := s.repo.GetPushLogByID(deliveryID)
+	if err != nil || src.SubscriptionID != sub.ID {
+		return nil, ErrDeliveryNotFound
+	}
+	if !sub.Enabled || sub.WebhookURL == "" {
+		return nil, ErrSubscriptionDisabled
+	}
+	entry, err := s.webhooks.Redeliver(src)
+	if err != nil {
+		return nil, err
+	}
+	resp := toDeliveryResponse(entry)
+	return &resp, nil
+}
+
+// PingSubscription 同步发送测试请求，便于接收方联调签名校验；暂停的订阅也可测试
+func (s *AgentService) PingSubscription(ctx context.Context, userID, subscriptionID uint) (*dto.WebhookDeliveryResponse, error) {
+	sub, err := s.ownedSubscription(userID, subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	if sub.WebhookURL == "" {
+		return nil, ErrInvalidWebhookURL
+	}
+	// 早于地址校验创建的订阅也不能用来探测内网
+	if err := s.validateWebhookURL(ctx, sub.WebhookURL); err != nil {
+		return nil, err
+	}
+	body, _ := json.Marshal(PingPush{
+		Event: pushEventPing, Version: PushSchemaVersion, SubscriptionID: sub.ID, PushType: sub.PushType,
+		Message: "EMS webhook test", SentAt: time.Now(),
+	})
+	entry, err := s.webhooks.Ping(ctx, sub, pushEventPing, body)
+	if err != nil {
+		return nil, err
+	}
+	resp := toDeliveryResponse(entry)
+	return &resp, nil
+}
+
+// RunWebhookDispatcher 持续投递 Webhook 队列，直到 ctx 结束
+func (s *AgentService) RunWebhookDispatcher(ctx context.Context) {
+	s.webhooks.Run(ctx)
+}
+
+func toDeliveryResponse(l *model.AgentPushLog) dto.WebhookDeliveryResponse {
+	payload := json.RawMessage(l.Payload)
+	if !json.Valid(payload) {
+		payload, _ = json.Marshal(l.Payload)
+	}
+	return dto.WebhookDeliveryResponse{
+		ID: l.ID, SubscriptionID: l.SubscriptionID, EventType: l.EventType, Status: l.Status,
+		Attempts: l.RetryCount, ResponseCode: l.ResponseCode, LatencyMs: l.LatencyMs, Error: l.ErrorMessage,
+		Payload: payload, RedeliveryOf: l.RedeliveryOf, NextAttemptAt: l.NextAttemptAt,
+		DeliveredAt: l.DeliveredAt, CreatedAt: l.CreatedAt,
+	}
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ems/backend/internal/agent/dto"
+	"github.com/ems/backend/internal/agent/pushfilter"
+	"github.com/ems/backend/internal/agent/webhook"
+	"github.com/ems/backend/pkg/config"
+)
+
+func TestAgentService_SubscriptionLifecycle(t *testing.T) {
+	config.Cfg = &config.Config{Storage: config.StorageConfig{Mode: "memory"}}
+	svc := NewAgentService()
+
+	var secret string
+	var verifyErr error
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		verifyErr = webhook.Verify(secret, r.Header.Get(webhook.HeaderSignature), r.Header.Get(webhook.HeaderTimestamp), body, time.Minute, time.Now())
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	const owner, other = 701, 702
+	if _, _, err := svc.Subscribe(owner, "repair.created", true, nil, "ftp://example.com/hook"); !errors.Is(err, ErrInvalidWebhookURL) {
+		t.Fatalf("Expected invalid webhook URL to be rejected, got %v", err)
+	}
+	// 默认拒绝回环与内网地址，测试接收端在 127.0.0.1 上，之后放开
+	for _, raw := range []string{srv.URL, "http://10.0.0.8/hook", "http://169.254.169.254/latest/meta-data"} {
+		if _, _, err := svc.Subscribe(owner, "repair.created", true, nil, raw); !errors.Is(err, ErrInvalidWebhookURL) {
+			t.Fatalf("Expected private webhook URL %s to be rejected, got %v", raw, err)
+		}
+	}
+	svc.webhooks.AllowPrivateNetworks = true
+	if _, _, err := svc.Subscribe(owner, "repair.created", true, map[string]any{"min_severity": "urgent"}, srv.URL); !errors.Is(err, pushfilter.ErrInvalidFilter) {
+		t.Fatalf("Expected invalid scope to be rejected, got %v", err)
+	}
+	sub, secret, err := svc.Subscribe(owner, "repair.created", true, map[string]any{"factory_id": 1}, srv.URL)
+	if err != nil || secret == "" {
+		t.Fatalf("Expected a new subscription with a secret, got %v, %q", err, secret)
+	}
+	if _, again, _ := svc.Subscribe(owner, "repair.created", true, nil, srv.URL); again != "" {
+		t.Errorf("Expected the secret to be returned only once")
+	}
+
+	ping, err := svc.PingSubscription(context.Background(), owner, sub.ID)
+	if err != nil || ping.Status != webhook.StatusSuccess || ping.ResponseCode != http.StatusOK {
+		t.Fatalf("Expected a successful ping, got %+v, %v", ping, err)
+	}
+	if verifyErr != nil {
+		t.Fatalf("Receiver could not verify the ping signature: %v", verifyErr)
+	}
+	if _, err := svc.PingSubscription(context.Background(), other, sub.ID); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("Expected another user to be denied, got %v", err)
+	}
+
+	redelivered, err := svc.Redeliver(owner, sub.ID, ping.ID)
+	if err != nil || redelivered.Status != webhook.StatusPending || redelivered.RedeliveryOf == nil || *redelivered.RedeliveryOf != ping.ID {
+		t.Fatalf("Expected a queued redelivery of the ping, got %+v, %v", redelivered, err)
+	}
+	deliveries, err := svc.ListDeliveries(owner, sub.ID, &dto.DeliveryQuery{})
+	if err != nil || len(deliveries) != 2 || deliveries[0].ID != redelivered.ID {
+		t.Fatalf("Expected newest-first deliveries, got %+v, %v", deliveries, err)
+	}
+	if string(deliveries[1].Payload[:1]) != "{" {
+		t.Errorf("Expected the payload as raw JSON, got %s", deliveries[1].Payload)
+	}
+
+	paused, err := svc.SetSubscriptionPaused(owner, sub.ID, true)
+	if err != nil || paused.Enabled || paused.DisabledAt == nil {
+		t.Fatalf("Expected the subscription to be paused, got %+v, %v", paused, err)
+	}
+	if _, err := svc.Redeliver(owner, sub.ID, ping.ID); !errors.Is(err, ErrSubscriptionDisabled) {
+		t.Errorf("Expected redelivery to a paused subscription to be rejected, got %v", err)
+	}
+	enabled := true
+	updated, err := svc.UpdateSubscription(owner, sub.ID, &dto.UpdateSubscriptionRequest{Enabled: &enabled, Scope: []byte(`{"factory_id":2}`)})
+	if err != nil || !updated.Enabled || updated.DisabledAt != nil || updated.Scope != `{"factory_id":2}` {
+		t.Fatalf("Expected the update to resume and re-scope, got %+v, %v", updated, err)
+	}
+
+	if err := svc.DeleteSubscription(owner, sub.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.ListDeliveries(owner, sub.ID, &dto.DeliveryQuery{}); !errors.Is(err, ErrSubscriptionNotFound) {
+		t.Errorf("Expected deleted subscription to be gone, got %v", err)
+	}
+}
+
+func TestAgentService_PushSchemas(t *testing.T) {
+	config.Cfg = &config.Config{Storage: config.StorageConfig{Mode: "memory"}}
+	svc := NewAgentService()
+	for _, schema := range svc.PushSchemas() {
+		if schema.Event != "repair.created" {
+			continue
+		}
+		props := schema.Schema["properties"].(map[string]interface{})
+		data := props["data"].(map[string]interface{})["properties"].(map[string]interface{})
+		if _, ok := data["order_id"]; !ok || schema.Version != PushSchemaVersion {
+			t.Fatalf("Expected repair.created data schema with order_id, got %+v", schema)
+		}
+		return
+	}
+	t.Fatal("Expected a repair.created schema")
+}
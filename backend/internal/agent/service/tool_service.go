@@ -1,7 +1,11 @@
 package service
 
 import (
+	"context"
+	"errors"
+
 	"github.com/ems/backend/internal/agent/dto"
+	"github.com/ems/backend/internal/agent/tool"
 	"github.com/ems/backend/internal/model"
 )
 
@@ -11,9 +15,14 @@ func (s *AgentService) ListTools(user model.User) ([]dto.ToolDefinition, error)
 }
 
 // CallTool executes a tool call for an external Agent
-func (s *AgentService) CallTool(user model.User, req *dto.CallToolRequest, scopes []string) (*dto.CallToolResponse, error) {
-	result, err := s.toolRegistry.Call(req.Name, user, req.Arguments, scopes)
+func (s *AgentService) CallTool(ctx context.Context, user model.User, req *dto.CallToolRequest, scopes []string) (*dto.CallToolResponse, error) {
+	result, err := s.toolRegistry.Call(ctx, req.Name, user, req.Arguments, scopes)
 	if err != nil {
+		// 超时、限流等结构化错误原样返回，便于调用方区分处理
+		var toolErr *tool.ToolError
+		if errors.As(err, &toolErr) {
+			return &dto.CallToolResponse{Content: toolErr, IsError: true}, nil
+		}
 		return &dto.CallToolResponse{Content: err.Error(), IsError: true}, nil
 	}
 	return &dto.CallToolResponse{Content: result, IsError: false}, nil
@@ -0,0 +1,157 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/ems/backend/internal/agent/dto"
+	"github.com/ems/backend/internal/model"
+)
+
+// Spec declares a service method published as an agent tool
+type Spec struct {
+	Name        string
+	Description string
+	Scopes      []string
+	ReadOnly    bool
+	// OutputSchema 可选，设置后工具返回值会按其校验，并在工具发现中公开
+	OutputSchema interface{}
+	Limits       Limits
+}
+
+// Catalog holds declaratively published tools. Registries consult it on lookup,
+// so publications made after an AgentService is constructed are still visible.
+type Catalog struct {
+	mu      sync.RWMutex
+	entries map[string]ToolEntry
+}
+
+func NewCatalog() *Catalog {
+	return &Catalog{entries: make(map[string]ToolEntry)}
+}
+
+// Published is the process-wide catalog used by NewToolRegistry
+var Published = NewCatalog()
+
+// Add 添加已构造的工具；Definition 中的 schema 在此规范化
+func (c *Catalog) Add(entry ToolEntry) {
+	entry = newToolEntry(entry.Definition, entry.Handler, entry.Scopes, entry.IsReadOnly).withLimits(entry.Limits)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[entry.Definition.Name] = entry
+}
+
+func (c *Catalog) Get(name string) (ToolEntry, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	e, ok := c.entries[name]
+	return e, ok
+}
+
+// Entries 按名称排序返回全部已发布工具
+func (c *Catalog) Entries() []ToolEntry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entries := make([]ToolEntry, 0, len(c.entries))
+	for _, e := range c.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Definition.Name < entries[j].Definition.Name })
+	return entries
+}
+
+// Publish publishes fn as a tool in the process-wide catalog. The input schema
+// is reflected from Req, and call arguments are bound into a Req before fn runs:
+//
+//	tool.Publish(tool.Spec{Name: "list_spare_parts", Scopes: []string{"read:sparepart"}, ReadOnly: true}, listSpareParts)
+func Publish[Req any](spec Spec, fn func(ctx context.Context, user model.User, req Req) (interface{}, error)) {
+	PublishTo(Published, spec, fn)
+}
+
+// PublishTo is Publish against an explicit catalog
+func PublishTo[Req any](c *Catalog, spec Spec, fn func(ctx context.Context, user model.User, req Req) (interface{}, error)) {
+	var zero Req
+	c.Add(ToolEntry{
+		Definition: dto.ToolDefinition{
+			Name: spec.Name, Description: spec.Description,
+			InputSchema: SchemaOf(zero), OutputSchema: spec.OutputSchema,
+		},
+		Handler:    Typed(fn),
+		Scopes:     spec.Scopes,
+		IsReadOnly: spec.ReadOnly,
+		Limits:     spec.Limits,
+	})
+}
+
+// Typed adapts a handler taking a typed request struct to a ToolFunc. Arguments
+// are validated against the tool schema by the registry before binding.
+func Typed[Req any](fn func(ctx context.Context, user model.User, req Req) (interface{}, error)) ToolFunc {
+	return func(ctx context.Context, user model.User, args map[string]interface{}) (interface{}, error) {
+		req, err := Bind[Req](args)
+		if err != nil {
+			return nil, err
+		}
+		return fn(ctx, user, req)
+	}
+}
+
+// Bind decodes tool call arguments into a typed request struct
+func Bind[Req any](args map[string]interface{}) (Req, error) {
+	var req Req
+	if len(args) == 0 {
+		return req, nil
+	}
+	raw, err := json.Marshal(argsByFieldName(reflect.TypeOf(req), args))
+	if err != nil {
+		return req, fmt.Errorf("invalid arguments: %w", err)
+	}
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return req, fmt.Errorf("invalid arguments: %w", err)
+	}
+	return req, nil
+}
+
+// argsByFieldName 将仅带 form 标签的字段参数名改写为 Go 字段名，使 encoding/json 能够匹配
+func argsByFieldName(t reflect.Type, args map[string]interface{}) map[string]interface{} {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return args
+	}
+	names := map[string]string{}
+	formNames(t, names)
+	out := make(map[string]interface{}, len(args))
+	for k, v := range args {
+		if goName, ok := names[k]; ok {
+			k = goName
+		}
+		out[k] = v
+	}
+	return out
+}
+
+func formNames(t reflect.Type, names map[string]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Tag.Get("json") == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				formNames(ft, names)
+				continue
+			}
+		}
+		if f.Tag.Get("json") == "" && f.IsExported() {
+			if name := fieldName(f); name != "" && name != f.Name {
+				names[name] = f.Name
+			}
+		}
+	}
+}
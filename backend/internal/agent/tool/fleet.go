@@ -0,0 +1,225 @@
+package tool
+
+import (
+	"sort"
+	"time"
+
+	"github.com/ems/backend/internal/model"
+	"github.com/ems/backend/pkg/config"
+	"github.com/ems/backend/pkg/database"
+	"github.com/ems/backend/pkg/memory"
+)
+
+// FleetScope selects a group of equipment for fleet-level analysis. Zero fields do not filter.
+type FleetScope struct {
+	FactoryID       uint
+	WorkshopID      uint
+	EquipmentTypeID uint
+	EquipmentIDs    []uint
+	Since           time.Time
+}
+
+// FleetHistory is the repair, maintenance and spare part history of a group of equipment
+type FleetHistory struct {
+	Equipment    []model.Equipment
+	Orders       []model.RepairOrder          // 按创建时间正序
+	Maintenance  []model.MaintenanceTask      // 仅已完成任务
+	Consumptions []model.SparePartConsumption // 关联到上述工单或保养任务的领用
+	StockIns     []model.SparePartTransaction // 相关备件的入库记录（批次），按时间正序
+	Users        map[uint]string              // 维修/保养人员姓名
+	FactoryOf    map[uint]uint                // 设备 ID -> 工厂 ID
+}
+
+// FleetTool loads the history that fleet-level analyzers correlate
+type FleetTool struct{}
+
+func NewFleetTool() *FleetTool {
+	return &FleetTool{}
+}
+
+// GetFleetHistory returns the history of equipment in scope; non-admin users are limited to their factory.
+// Spare part consumptions and stock-ins are loaded from an extra lookback before Since so that
+// parts installed earlier can still be linked to failures inside the window.
+func (t *FleetTool) GetFleetHistory(scope FleetScope, lookback time.Duration, user model.User) (*FleetHistory, error) {
+	if user.Role != model.RoleAdmin && user.FactoryID != nil {
+		scope.FactoryID = *user.FactoryID
+	}
+	if config.Cfg.Storage.Mode == "memory" {
+		return t.memoryHistory(scope, lookback), nil
+	}
+	return t.dbHistory(scope, lookback)
+}
+
+func (t *FleetTool) memoryHistory(scope FleetScope, lookback time.Duration) *FleetHistory {
+	store := memory.GetStore()
+	h := &FleetHistory{Users: map[uint]string{}, FactoryOf: map[uint]uint{}}
+	wanted := idSet(scope.EquipmentIDs)
+	inScope := map[uint]bool{}
+	for _, e := range store.Equipment {
+		if scope.WorkshopID > 0 && e.WorkshopID != scope.WorkshopID {
+			continue
+		}
+		if scope.EquipmentTypeID > 0 && e.TypeID != scope.EquipmentTypeID {
+			continue
+		}
+		if len(wanted) > 0 && !wanted[e.ID] {
+			continue
+		}
+		w, ok := store.Workshops[e.WorkshopID]
+		if scope.FactoryID > 0 && (!ok || w.FactoryID != scope.FactoryID) {
+			continue
+		}
+		if ok {
+			h.FactoryOf[e.ID] = w.FactoryID
+		}
+		inScope[e.ID] = true
+		h.Equipment = append(h.Equipment, *e)
+	}
+
+	from := scope.Since.Add(-lookback)
+	orderIDs, taskIDs := map[uint]bool{}, map[uint]bool{}
+	for _, o := range store.RepairOrders {
+		if inScope[o.EquipmentID] && !o.CreatedAt.Before(from) {
+			h.Orders = append(h.Orders, *o)
+			orderIDs[o.ID] = true
+		}
+	}
+	for _, m := range store.MaintenanceTasks {
+		if inScope[m.EquipmentID] && m.Status == "completed" && m.CompletedAt != nil && !m.CompletedAt.Before(from) {
+			h.Maintenance = append(h.Maintenance, *m)
+			taskIDs[m.ID] = true
+		}
+	}
+	partIDs := map[uint]bool{}
+	for _, c := range store.SparePartConsumption {
+		if (c.OrderID != nil && orderIDs[*c.OrderID]) || (c.TaskID != nil && taskIDs[*c.TaskID]) {
+			h.Consumptions = append(h.Consumptions, *c)
+			partIDs[c.SparePartID] = true
+		}
+	}
+	for _, tx := range store.SparePartTransactions {
+		if tx.Type == "in" && partIDs[tx.SparePartID] {
+			h.StockIns = append(h.StockIns, *tx)
+		}
+	}
+	for _, u := range store.Users {
+		h.Users[u.ID] = u.Name
+	}
+	h.sortChronologically()
+	return h
+}
+
+func (t *FleetTool) dbHistory(scope FleetScope, lookback time.Duration) (*FleetHistory, error) {
+	db := database.GetDB()
+	h := &FleetHistory{Users: map[uint]string{}, FactoryOf: map[uint]uint{}}
+
+	query := db.Model(&model.Equipment{}).Joins("JOIN workshops ON workshops.id = equipment.workshop_id")
+	if scope.FactoryID > 0 {
+		query = query.Where("workshops.factory_id = ?", scope.FactoryID)
+	}
+	if scope.WorkshopID > 0 {
+		query = query.Where("equipment.workshop_id = ?", scope.WorkshopID)
+	}
+	if scope.EquipmentTypeID > 0 {
+		query = query.Where("equipment.type_id = ?", scope.EquipmentTypeID)
+	}
+	if len(scope.EquipmentIDs) > 0 {
+		query = query.Where("equipment.id IN ?", scope.EquipmentIDs)
+	}
+	if err := query.Preload("Workshop").Find(&h.Equipment).Error; err != nil {
+		return nil, err
+	}
+	if len(h.Equipment) == 0 {
+		return h, nil
+	}
+	equipIDs := make([]uint, len(h.Equipment))
+	for i, e := range h.Equipment {
+		equipIDs[i] = e.ID
+		if e.Workshop != nil {
+			h.FactoryOf[e.ID] = e.Workshop.FactoryID
+		}
+	}
+
+	from := scope.Since.Add(-lookback)
+	if err := db.Where("equipment_id IN ? AND created_at >= ?", equipIDs, from).
+		Order("created_at ASC").Find(&h.Orders).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("equipment_id IN ? AND status = ? AND completed_at >= ?", equipIDs, "completed", from).
+		Order("completed_at ASC").Find(&h.Maintenance).Error; err != nil {
+		return nil, err
+	}
+
+	orderIDs := make([]uint, len(h.Orders))
+	for i, o := range h.Orders {
+		orderIDs[i] = o.ID
+	}
+	taskIDs := make([]uint, len(h.Maintenance))
+	for i, m := range h.Maintenance {
+		taskIDs[i] = m.ID
+	}
+	if len(orderIDs) > 0 || len(taskIDs) > 0 {
+		if err := db.Where("order_id IN ? OR task_id IN ?", append(orderIDs, 0), append(taskIDs, 0)).
+			Preload("SparePart").Find(&h.Consumptions).Error; err != nil {
+			return nil, err
+		}
+	}
+	partIDs := map[uint]bool{}
+	var parts []uint
+	for _, c := range h.Consumptions {
+		if !partIDs[c.SparePartID] {
+			partIDs[c.SparePartID] = true
+			parts = append(parts, c.SparePartID)
+		}
+	}
+	if len(parts) > 0 {
+		if err := db.Where("type = ? AND spare_part_id IN ?", "in", parts).
+			Order("created_at ASC").Find(&h.StockIns).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	userIDs := map[uint]bool{}
+	for _, o := range h.Orders {
+		if o.AssignedTo != nil {
+			userIDs[*o.AssignedTo] = true
+		}
+	}
+	for _, m := range h.Maintenance {
+		userIDs[m.AssignedTo] = true
+	}
+	if len(userIDs) > 0 {
+		ids := make([]uint, 0, len(userIDs))
+		for id := range userIDs {
+			ids = append(ids, id)
+		}
+		var users []model.User
+		if err := db.Select("id", "name").Where("id IN ?", ids).Find(&users).Error; err != nil {
+			return nil, err
+		}
+		for _, u := range users {
+			h.Users[u.ID] = u.Name
+		}
+	}
+	h.sortChronologically()
+	return h, nil
+}
+
+func (h *FleetHistory) sortChronologically() {
+	sortByTime(h.Orders, func(o model.RepairOrder) time.Time { return o.CreatedAt })
+	sortByTime(h.Maintenance, func(m model.MaintenanceTask) time.Time { return *m.CompletedAt })
+	sortByTime(h.Consumptions, func(c model.SparePartConsumption) time.Time { return c.CreatedAt })
+	sortByTime(h.StockIns, func(tx model.SparePartTransaction) time.Time { return tx.CreatedAt })
+}
+
+func sortByTime[T any](items []T, at func(T) time.Time) {
+	sort.SliceStable(items, func(i, j int) bool { return at(items[i]).Before(at(items[j])) })
+}
+
+func idSet(ids []uint) map[uint]bool {
+	set := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
@@ -0,0 +1,146 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"time"
+
+	"github.com/ems/backend/internal/model"
+)
+
+// DefaultTimeout applies to tools that do not declare their own timeout
+const DefaultTimeout = 30 * time.Second
+
+// Limits bounds how a tool may be called. Zero values mean the default timeout
+// and no rate or concurrency limit.
+type Limits struct {
+	Timeout         time.Duration
+	RatePerMinute   int // 所有调用方合计
+	CallerPerMinute int // 每个 API Key（或 Web 用户）
+	MaxConcurrent   int // 本进程内同时执行的调用数
+}
+
+// RateLimiter reports whether another call in bucket is allowed within window
+// (see middleware.AllowRate).
+type RateLimiter func(bucket string, limit int, window time.Duration) bool
+
+// 结构化工具错误码
+const (
+	ErrCodeTimeout     = "timeout"
+	ErrCodeCancelled   = "cancelled"
+	ErrCodeRateLimited = "rate_limited"
+	ErrCodeBusy        = "busy"
+)
+
+// ToolError is a structured failure reported back to the LLM as JSON, so the
+// model can tell a timeout or throttling apart from a bad call and react.
+type ToolError struct {
+	Code              string `json:"code"`
+	Tool              string `json:"tool"`
+	Message           string `json:"message"`
+	Retryable         bool   `json:"retryable"`
+	RetryAfterSeconds int    `json:"retry_after_seconds,omitempty"`
+}
+
+func (e *ToolError) Error() string {
+	b, _ := json.Marshal(struct {
+		Error *ToolError `json:"error"`
+	}{e})
+	return string(b)
+}
+
+type callerKey struct{}
+
+// WithCaller tags ctx with the identity used for per-caller rate limits, e.g. "apikey:12".
+// Without it, calls are attributed to the user.
+func WithCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+func callerOf(ctx context.Context, user model.User) string {
+	if caller, ok := ctx.Value(callerKey{}).(string); ok && caller != "" {
+		return caller
+	}
+	return fmt.Sprintf("user:%d", user.ID)
+}
+
+// checkRate 检查工具级与调用方级限流
+func (r *ToolRegistry) checkRate(ctx context.Context, name string, user model.User, limits Limits) error {
+	if r.limiter == nil {
+		return nil
+	}
+	if limits.RatePerMinute > 0 && !r.limiter("tool:"+name, limits.RatePerMinute, time.Minute) {
+		return &ToolError{Code: ErrCodeRateLimited, Tool: name, Retryable: true, RetryAfterSeconds: 60,
+			Message: fmt.Sprintf("tool is limited to %d calls per minute; wait before calling it again or use another tool", limits.RatePerMinute)}
+	}
+	if limits.CallerPerMinute > 0 && !r.limiter("tool:"+name+":"+callerOf(ctx, user), limits.CallerPerMinute, time.Minute) {
+		return &ToolError{Code: ErrCodeRateLimited, Tool: name, Retryable: true, RetryAfterSeconds: 60,
+			Message: fmt.Sprintf("you may call this tool at most %d times per minute; reuse earlier results or wait", limits.CallerPerMinute)}
+	}
+	return nil
+}
+
+// invoke 在超时与并发限制下执行处理函数。处理函数被放弃后仍占用并发槽直到真正返回，
+// 以免取消不及时的慢查询叠加。
+func invoke(ctx context.Context, name string, entry ToolEntry, user model.User, args map[string]interface{}) (interface{}, error) {
+	timeout := entry.Limits.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, cancelled(name, err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if entry.slots != nil {
+		select {
+		case entry.slots <- struct{}{}:
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, &ToolError{Code: ErrCodeBusy, Tool: name, Retryable: true,
+					Message: fmt.Sprintf("tool is busy (at most %d concurrent calls); try again later", entry.Limits.MaxConcurrent)}
+			}
+			return nil, cancelled(name, ctx.Err())
+		}
+	}
+
+	type outcome struct {
+		res interface{}
+		err error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		if entry.slots != nil {
+			defer func() { <-entry.slots }()
+		}
+		// 处理函数不在请求 goroutine 中运行，需自行恢复 panic
+		defer func() {
+			if p := recover(); p != nil {
+				log.Printf("[ToolRegistry] tool %s panicked: %v\n%s", name, p, debug.Stack())
+				done <- outcome{nil, fmt.Errorf("tool %s failed unexpectedly", name)}
+			}
+		}()
+		res, err := entry.Handler(ctx, user, args)
+		done <- outcome{res, err}
+	}()
+
+	select {
+	case o := <-done:
+		return o.res, o.err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, &ToolError{Code: ErrCodeTimeout, Tool: name, Retryable: true,
+				Message: fmt.Sprintf("tool did not finish within %s; narrow the request (e.g. fewer rows or a shorter time range) and retry", timeout)}
+		}
+		return nil, cancelled(name, ctx.Err())
+	}
+}
+
+func cancelled(name string, err error) *ToolError {
+	return &ToolError{Code: ErrCodeCancelled, Tool: name, Message: fmt.Sprintf("call was cancelled: %v", err)}
+}
@@ -0,0 +1,126 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ems/backend/internal/agent/dto"
+	"github.com/ems/backend/internal/model"
+)
+
+func TestToolRegistry_Timeout(t *testing.T) {
+	registry := NewToolRegistry()
+	registry.catalog = nil
+	handlerCtxDone := make(chan struct{})
+	var once sync.Once
+	registry.Register("slow_tool", dto.ToolDefinition{Name: "slow_tool"},
+		func(ctx context.Context, user model.User, args map[string]interface{}) (interface{}, error) {
+			<-ctx.Done()
+			once.Do(func() { close(handlerCtxDone) })
+			return nil, ctx.Err()
+		}, nil, true)
+	registry.SetLimits("slow_tool", Limits{Timeout: 20 * time.Millisecond})
+
+	_, err := registry.Call(context.Background(), "slow_tool", model.User{}, nil, nil)
+	var toolErr *ToolError
+	if !errors.As(err, &toolErr) || toolErr.Code != ErrCodeTimeout || !toolErr.Retryable {
+		t.Fatalf("Expected structured timeout error, got %v", err)
+	}
+	select {
+	case <-handlerCtxDone:
+	case <-time.After(time.Second):
+		t.Error("Expected handler context to be cancelled on timeout")
+	}
+
+	parent, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := registry.Call(parent, "slow_tool", model.User{}, nil, nil); !errors.As(err, &toolErr) || toolErr.Code != ErrCodeCancelled {
+		t.Errorf("Expected cancelled error, got %v", err)
+	}
+}
+
+func TestToolRegistry_ConcurrencyCap(t *testing.T) {
+	registry := NewToolRegistry()
+	registry.catalog = nil
+	release := make(chan struct{})
+	started := make(chan struct{})
+	registry.Register("capped_tool", dto.ToolDefinition{Name: "capped_tool"},
+		func(ctx context.Context, user model.User, args map[string]interface{}) (interface{}, error) {
+			started <- struct{}{}
+			<-release
+			return "ok", nil
+		}, nil, true)
+	registry.SetLimits("capped_tool", Limits{Timeout: 50 * time.Millisecond, MaxConcurrent: 1})
+
+	first := make(chan error, 1)
+	go func() {
+		_, err := registry.Call(context.Background(), "capped_tool", model.User{}, nil, nil)
+		first <- err
+	}()
+	<-started
+
+	_, err := registry.Call(context.Background(), "capped_tool", model.User{}, nil, nil)
+	var toolErr *ToolError
+	if !errors.As(err, &toolErr) || toolErr.Code != ErrCodeBusy {
+		t.Errorf("Expected busy error while the only slot is taken, got %v", err)
+	}
+
+	// 第一个调用已超时，但处理函数返回前并发槽仍被占用
+	<-first
+	if _, err := registry.Call(context.Background(), "capped_tool", model.User{}, nil, nil); !errors.As(err, &toolErr) || toolErr.Code != ErrCodeBusy {
+		t.Errorf("Expected slot to stay held by the abandoned handler, got %v", err)
+	}
+	close(release)
+}
+
+func TestToolRegistry_RateLimits(t *testing.T) {
+	registry := NewToolRegistry()
+	registry.catalog = nil
+	counts := map[string]int{}
+	registry.SetRateLimiter(func(bucket string, limit int, window time.Duration) bool {
+		counts[bucket]++
+		return counts[bucket] <= limit
+	})
+	registry.Register("limited_tool", dto.ToolDefinition{Name: "limited_tool"},
+		func(ctx context.Context, user model.User, args map[string]interface{}) (interface{}, error) {
+			return "ok", nil
+		}, nil, true)
+	registry.SetLimits("limited_tool", Limits{RatePerMinute: 3, CallerPerMinute: 1})
+
+	keyA := WithCaller(context.Background(), "apikey:1")
+	keyB := WithCaller(context.Background(), "apikey:2")
+	if _, err := registry.Call(keyA, "limited_tool", model.User{}, nil, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	_, err := registry.Call(keyA, "limited_tool", model.User{}, nil, nil)
+	var toolErr *ToolError
+	if !errors.As(err, &toolErr) || toolErr.Code != ErrCodeRateLimited || toolErr.RetryAfterSeconds != 60 {
+		t.Errorf("Expected per-key rate limit, got %v", err)
+	}
+	if _, err := registry.Call(keyB, "limited_tool", model.User{}, nil, nil); err != nil {
+		t.Errorf("Expected another key to have its own quota, got %v", err)
+	}
+	if _, err := registry.Call(WithCaller(context.Background(), "apikey:3"), "limited_tool", model.User{}, nil, nil); !errors.As(err, &toolErr) {
+		t.Errorf("Expected tool-wide limit after 3 calls, got %v", err)
+	}
+	if counts["tool:limited_tool:apikey:1"] != 2 {
+		t.Errorf("Unexpected bucket counts: %v", counts)
+	}
+}
+
+func TestToolRegistry_RecoversPanics(t *testing.T) {
+	registry := NewToolRegistry()
+	registry.catalog = nil
+	registry.Register("broken_tool", dto.ToolDefinition{Name: "broken_tool"},
+		func(ctx context.Context, user model.User, args map[string]interface{}) (interface{}, error) {
+			var m map[string]int
+			m["x"] = 1
+			return nil, nil
+		}, nil, true)
+	if _, err := registry.Call(context.Background(), "broken_tool", model.User{}, nil, nil); err == nil {
+		t.Error("Expected panic to be reported as an error")
+	}
+}
@@ -1,13 +1,19 @@
 package tool
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"sync"
+
 	"github.com/ems/backend/internal/agent/dto"
 	"github.com/ems/backend/internal/model"
+	"github.com/ems/backend/pkg/jsonschema"
 )
 
-// ToolFunc is the signature for a tool implementation
-type ToolFunc func(user model.User, args map[string]interface{}) (interface{}, error)
+// ToolFunc is the signature for a tool implementation. ctx carries the tool's
+// deadline and is cancelled when the caller goes away.
+type ToolFunc func(ctx context.Context, user model.User, args map[string]interface{}) (interface{}, error)
 
 // ToolEntry represents a registered tool with its metadata
 type ToolEntry struct {
@@ -15,26 +21,84 @@ type ToolEntry struct {
 	Handler    ToolFunc
 	Scopes     []string // Required scopes for this tool
 	IsReadOnly bool
+	Limits     Limits
+
+	slots chan struct{} // MaxConcurrent > 0 时的并发槽
+
+	// 规范化后的 schema，注册时计算一次
+	inputSchema  map[string]interface{}
+	outputSchema map[string]interface{}
+}
+
+func newToolEntry(def dto.ToolDefinition, handler ToolFunc, scopes []string, isReadOnly bool) ToolEntry {
+	return ToolEntry{
+		Definition:   def,
+		Handler:      handler,
+		Scopes:       scopes,
+		IsReadOnly:   isReadOnly,
+		inputSchema:  normalizeSchema(def.InputSchema),
+		outputSchema: normalizeSchema(def.OutputSchema),
+	}
+}
+
+func (e ToolEntry) withLimits(limits Limits) ToolEntry {
+	e.Limits = limits
+	e.slots = nil
+	if limits.MaxConcurrent > 0 {
+		e.slots = sharedSlots(e.Definition.Name, limits.MaxConcurrent)
+	}
+	return e
+}
+
+var (
+	slotsMu sync.Mutex
+	slotsBy = map[string]chan struct{}{}
+)
+
+// sharedSlots 同名工具在进程内共用并发槽，多个 AgentService 实例不会各自放大上限
+func sharedSlots(name string, n int) chan struct{} {
+	slotsMu.Lock()
+	defer slotsMu.Unlock()
+	key := fmt.Sprintf("%s/%d", name, n)
+	if ch, ok := slotsBy[key]; ok {
+		return ch
+	}
+	ch := make(chan struct{}, n)
+	slotsBy[key] = ch
+	return ch
 }
 
 // ToolRegistry manages a collection of agent tools
 type ToolRegistry struct {
-	tools map[string]ToolEntry
+	tools   map[string]ToolEntry
+	catalog *Catalog // 声明式发布的工具，同名时以手动注册的为准
+	limiter RateLimiter
 }
 
 func NewToolRegistry() *ToolRegistry {
 	return &ToolRegistry{
-		tools: make(map[string]ToolEntry),
+		tools:   make(map[string]ToolEntry),
+		catalog: Published,
 	}
 }
 
 func (r *ToolRegistry) Register(name string, def dto.ToolDefinition, handler ToolFunc, scopes []string, isReadOnly bool) {
-	r.tools[name] = ToolEntry{
-		Definition: def,
-		Handler:    handler,
-		Scopes:     scopes,
-		IsReadOnly: isReadOnly,
+	r.tools[name] = newToolEntry(def, handler, scopes, isReadOnly)
+}
+
+// SetLimits sets timeout, rate and concurrency limits for a registered or published tool
+func (r *ToolRegistry) SetLimits(name string, limits Limits) bool {
+	entry, ok := r.GetTool(name)
+	if !ok {
+		return false
 	}
+	r.tools[name] = entry.withLimits(limits)
+	return true
+}
+
+// SetRateLimiter enables the per-tool and per-caller rate limits
+func (r *ToolRegistry) SetRateLimiter(limiter RateLimiter) {
+	r.limiter = limiter
 }
 
 func (r *ToolRegistry) List(user model.User) []dto.ToolDefinition {
@@ -43,11 +107,20 @@ func (r *ToolRegistry) List(user model.User) []dto.ToolDefinition {
 		// In a more advanced implementation, filter by user scopes here
 		defs = append(defs, t.Definition)
 	}
+	if r.catalog != nil {
+		for _, t := range r.catalog.Entries() {
+			if _, shadowed := r.tools[t.Definition.Name]; !shadowed {
+				defs = append(defs, t.Definition)
+			}
+		}
+	}
 	return defs
 }
 
-func (r *ToolRegistry) Call(name string, user model.User, args map[string]interface{}, userScopes []string) (interface{}, error) {
-	entry, ok := r.tools[name]
+// Call validates and runs a tool. Timeouts, throttling and cancellation are
+// returned as *ToolError; schema violations as *ValidationError.
+func (r *ToolRegistry) Call(ctx context.Context, name string, user model.User, args map[string]interface{}, userScopes []string) (interface{}, error) {
+	entry, ok := r.GetTool(name)
 	if !ok {
 		return nil, fmt.Errorf("tool not found: %s", name)
 	}
@@ -76,10 +149,48 @@ func (r *ToolRegistry) Call(name string, user model.User, args map[string]interf
 		// We could strictly enforce that API Key users MUST have scopes.
 	}
 
-	return entry.Handler(user, args)
+	if entry.inputSchema != nil {
+		if args == nil {
+			args = map[string]interface{}{}
+		}
+		if issues := jsonschema.Check(entry.inputSchema, args); len(issues) > 0 {
+			return nil, &ValidationError{Tool: name, Issues: issues, Schema: entry.inputSchema}
+		}
+	}
+
+	if err := r.checkRate(ctx, name, user, entry.Limits); err != nil {
+		return nil, err
+	}
+
+	result, err := invoke(ctx, name, entry, user, args)
+	if err != nil || entry.outputSchema == nil || result == nil {
+		return result, err
+	}
+	if issues := validateOutput(entry.outputSchema, result); len(issues) > 0 {
+		return nil, &ValidationError{Tool: name, Output: true, Issues: issues, Schema: entry.outputSchema}
+	}
+	return result, nil
+}
+
+// validateOutput 按 JSON 序列化后的形态校验工具返回值
+func validateOutput(schema map[string]interface{}, result interface{}) []Issue {
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return []Issue{{Message: fmt.Sprintf("output is not JSON serializable: %v", err)}}
+	}
+	var generic interface{}
+	if err := json.Unmarshal(raw, &generic); err != nil || generic == nil {
+		return nil
+	}
+	return jsonschema.Check(schema, generic)
 }
 
 func (r *ToolRegistry) GetTool(name string) (ToolEntry, bool) {
-	t, ok := r.tools[name]
-	return t, ok
+	if t, ok := r.tools[name]; ok {
+		return t, true
+	}
+	if r.catalog != nil {
+		return r.catalog.Get(name)
+	}
+	return ToolEntry{}, false
 }
@@ -1,6 +1,7 @@
 package tool
 
 import (
+	"context"
 	"testing"
 	"github.com/ems/backend/internal/agent/dto"
 	"github.com/ems/backend/internal/model"
@@ -14,7 +15,7 @@ func TestToolRegistry_RegisterAndList(t *testing.T) {
 		Description: "A test tool",
 	}
 	
-	handler := func(user model.User, args map[string]interface{}) (interface{}, error) {
+	handler := func(ctx context.Context, user model.User, args map[string]interface{}) (interface{}, error) {
 		return "ok", nil
 	}
 	
@@ -34,7 +35,7 @@ func TestToolRegistry_Call(t *testing.T) {
 	registry := NewToolRegistry()
 	
 	def := dto.ToolDefinition{Name: "test_tool"}
-	handler := func(user model.User, args map[string]interface{}) (interface{}, error) {
+	handler := func(ctx context.Context, user model.User, args map[string]interface{}) (interface{}, error) {
 		return args["input"], nil
 	}
 	
@@ -43,7 +44,7 @@ func TestToolRegistry_Call(t *testing.T) {
 	user := model.User{BaseModel: model.BaseModel{ID: 1}}
 	args := map[string]interface{}{"input": "hello"}
 	
-	result, err := registry.Call("test_tool", user, args, []string{"read"})
+	result, err := registry.Call(context.Background(), "test_tool", user, args, []string{"read"})
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
 	}
@@ -56,7 +57,7 @@ func TestToolRegistry_Call(t *testing.T) {
 func TestToolRegistry_ToolNotFound(t *testing.T) {
 	registry := NewToolRegistry()
 	
-	_, err := registry.Call("non_existent", model.User{}, nil, nil)
+	_, err := registry.Call(context.Background(), "non_existent", model.User{}, nil, nil)
 	if err == nil {
 		t.Error("Expected error for non-existent tool, got nil")
 	}
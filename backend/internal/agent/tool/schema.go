@@ -0,0 +1,159 @@
+package tool
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// SchemaOf derives a JSON Schema object from a request DTO struct.
+//
+// Property names come from the `json` tag, falling back to the `form` tag used
+// by query DTOs. `desc:"..."` becomes the description, and the gin `binding`
+// rules required, oneof, min and max are translated to required, enum and
+// minimum/maximum (minLength/maxLength for strings).
+func SchemaOf(v interface{}) map[string]interface{} {
+	if v == nil {
+		return map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+	}
+	return schemaFor(reflect.TypeOf(v))
+}
+
+func schemaFor(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaFor(t.Elem())}
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		var required []string
+		collectFields(t, properties, &required)
+		schema := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	}
+	// interface{} 等无法推断的类型不做约束
+	return map[string]interface{}{}
+}
+
+// collectFields 收集结构体字段，匿名嵌入的结构体字段会被展开到同一层
+func collectFields(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Tag.Get("json") == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				collectFields(ft, properties, required)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		name := fieldName(f)
+		if name == "" {
+			continue
+		}
+
+		prop := schemaFor(f.Type)
+		if desc := f.Tag.Get("desc"); desc != "" {
+			prop["description"] = desc
+		}
+		if applyBinding(prop, f.Tag.Get("binding")) {
+			*required = append(*required, name)
+		}
+		properties[name] = prop
+	}
+}
+
+// fieldName 返回字段在参数中的名称，"-" 表示不公开
+func fieldName(f reflect.StructField) string {
+	for _, key := range []string{"json", "form"} {
+		tag := f.Tag.Get(key)
+		if tag == "" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return f.Name
+}
+
+// applyBinding 将 binding 规则写入属性 schema，返回字段是否必填
+func applyBinding(prop map[string]interface{}, binding string) bool {
+	if binding == "" {
+		return false
+	}
+	required := false
+	isString := prop["type"] == "string"
+	isInteger := prop["type"] == "integer"
+	for _, rule := range strings.Split(binding, ",") {
+		key, value, _ := strings.Cut(rule, "=")
+		switch key {
+		case "required":
+			required = true
+		case "oneof":
+			var enum []interface{}
+			for _, opt := range strings.Fields(value) {
+				if n, err := strconv.ParseInt(opt, 10, 64); err == nil && isInteger {
+					enum = append(enum, n)
+				} else {
+					enum = append(enum, opt)
+				}
+			}
+			prop["enum"] = enum
+		case "min", "gte", "max", "lte":
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			lower := key == "min" || key == "gte"
+			switch {
+			case isString && lower:
+				prop["minLength"] = int(n)
+			case isString:
+				prop["maxLength"] = int(n)
+			case prop["type"] == "array" && lower:
+				prop["minItems"] = int(n)
+			case prop["type"] == "array":
+				prop["maxItems"] = int(n)
+			case lower:
+				prop["minimum"] = n
+			default:
+				prop["maximum"] = n
+			}
+		}
+	}
+	return required
+}
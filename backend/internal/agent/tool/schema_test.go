@@ -0,0 +1,101 @@
+package tool
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ems/backend/internal/model"
+)
+
+type schemaTestBase struct {
+	Page     int `form:"page" binding:"min=1"`
+	PageSize int `form:"page_size" binding:"min=1,max=100"`
+}
+
+type schemaTestQuery struct {
+	schemaTestBase
+	EquipmentID uint       `json:"equipment_id" binding:"required" desc:"Equipment ID"`
+	Status      string     `form:"status" binding:"omitempty,oneof=pending completed"`
+	Priority    int        `json:"priority" binding:"oneof=1 2 3"`
+	Tags        []string   `json:"tags"`
+	Since       *time.Time `json:"since"`
+	Internal    string     `json:"-"`
+}
+
+func TestSchemaOf(t *testing.T) {
+	schema := SchemaOf(schemaTestQuery{})
+	props := schema["properties"].(map[string]interface{})
+
+	for _, name := range []string{"page", "page_size", "equipment_id", "status", "priority", "tags", "since"} {
+		if _, ok := props[name]; !ok {
+			t.Errorf("Expected property %q, got %v", name, props)
+		}
+	}
+	if _, ok := props["Internal"]; ok {
+		t.Error("Expected json:\"-\" field to be hidden")
+	}
+	if !reflect.DeepEqual(schema["required"], []string{"equipment_id"}) {
+		t.Errorf("Unexpected required list: %v", schema["required"])
+	}
+
+	equip := props["equipment_id"].(map[string]interface{})
+	if equip["type"] != "integer" || equip["description"] != "Equipment ID" {
+		t.Errorf("Unexpected equipment_id schema: %v", equip)
+	}
+	if size := props["page_size"].(map[string]interface{}); size["minimum"] != 1.0 || size["maximum"] != 100.0 {
+		t.Errorf("Unexpected page_size bounds: %v", size)
+	}
+	if enum := props["status"].(map[string]interface{})["enum"]; !reflect.DeepEqual(enum, []interface{}{"pending", "completed"}) {
+		t.Errorf("Unexpected status enum: %v", enum)
+	}
+	if enum := props["priority"].(map[string]interface{})["enum"]; !reflect.DeepEqual(enum, []interface{}{int64(1), int64(2), int64(3)}) {
+		t.Errorf("Unexpected priority enum: %v", enum)
+	}
+	if since := props["since"].(map[string]interface{}); since["format"] != "date-time" {
+		t.Errorf("Expected date-time string for time.Time, got %v", since)
+	}
+	if items := props["tags"].(map[string]interface{})["items"].(map[string]interface{}); items["type"] != "string" {
+		t.Errorf("Unexpected tags items: %v", items)
+	}
+}
+
+func TestPublishTo_BindsFormTaggedArguments(t *testing.T) {
+	catalog := NewCatalog()
+	var got schemaTestQuery
+	PublishTo(catalog, Spec{Name: "list_things", Scopes: []string{"read:things"}, ReadOnly: true},
+		func(ctx context.Context, user model.User, req schemaTestQuery) (interface{}, error) {
+			got = req
+			return "ok", nil
+		})
+
+	registry := NewToolRegistry()
+	registry.catalog = catalog
+	if defs := registry.List(model.User{}); len(defs) != 1 || defs[0].Name != "list_things" {
+		t.Fatalf("Expected published tool in discovery, got %v", defs)
+	}
+
+	args := map[string]interface{}{"equipment_id": float64(7), "status": "pending", "page_size": float64(5)}
+	if _, err := registry.Call(context.Background(), "list_things", model.User{}, args, []string{"read:things"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got.EquipmentID != 7 || got.Status != "pending" || got.PageSize != 5 {
+		t.Errorf("Arguments not bound: %+v", got)
+	}
+
+	if _, err := registry.Call(context.Background(), "list_things", model.User{}, args, []string{"write:other"}); err == nil {
+		t.Error("Expected scope check to apply to published tools")
+	}
+
+	// 手动注册的同名工具优先
+	registry.Register("list_things", catalog.Entries()[0].Definition, func(ctx context.Context, user model.User, args map[string]interface{}) (interface{}, error) {
+		return "manual", nil
+	}, nil, true)
+	if res, _ := registry.Call(context.Background(), "list_things", model.User{}, args, nil); res != "manual" {
+		t.Errorf("Expected manual registration to shadow the catalog, got %v", res)
+	}
+	if defs := registry.List(model.User{}); len(defs) != 1 {
+		t.Errorf("Expected shadowed tool to be listed once, got %d", len(defs))
+	}
+}
@@ -1,6 +1,7 @@
 package tool
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"github.com/ems/backend/internal/model"
@@ -34,7 +35,7 @@ func NewSQLAnalystTool() *SQLAnalystTool {
 	}
 }
 
-func (t *SQLAnalystTool) ExecuteQuery(query string, user model.User) (interface{}, error) {
+func (t *SQLAnalystTool) ExecuteQuery(ctx context.Context, query string, user model.User) (interface{}, error) {
 	// 1. Basic Safety Check
 	upperQuery := strings.ToUpper(query)
 	forbiddenKeywords := []string{"INSERT", "UPDATE", "DELETE", "DROP", "TRUNCATE", "ALTER", "CREATE", "GRANT", "REVOKE"}
@@ -63,11 +64,11 @@ func (t *SQLAnalystTool) ExecuteQuery(query string, user model.User) (interface{
 		return t.executeInMemory(query, user)
 	}
 
-	return t.executeInDB(query, user)
+	return t.executeInDB(ctx, query, user)
 }
 
-func (t *SQLAnalystTool) executeInDB(query string, user model.User) (interface{}, error) {
-	db := database.GetDB()
+func (t *SQLAnalystTool) executeInDB(ctx context.Context, query string, user model.User) (interface{}, error) {
+	db := database.GetDB().WithContext(ctx)
 	
 	// Pre-filter by factory_id if possible by wrapping the query or using a CTE
 	// To keep it simple and safe for now:
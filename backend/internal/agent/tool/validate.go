@@ -0,0 +1,44 @@
+package tool
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ems/backend/pkg/jsonschema"
+)
+
+// Issue is a single schema violation, addressed by a dotted path such as "items[0].id"
+type Issue = jsonschema.Issue
+
+// ValidationError is returned when tool arguments or outputs violate the tool's schema.
+// Its message lists every issue together with the expected schema so that an LLM
+// can correct the call and retry.
+type ValidationError struct {
+	Tool   string
+	Output bool // true 表示工具返回值不符合 OutputSchema
+	Issues []Issue
+	Schema map[string]interface{}
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Issues))
+	for i, issue := range e.Issues {
+		msgs[i] = issue.String()
+	}
+	if e.Output {
+		return fmt.Sprintf("tool %q returned output that does not match its output schema: %s", e.Tool, strings.Join(msgs, "; "))
+	}
+	schema, _ := json.Marshal(e.Schema)
+	return fmt.Sprintf("invalid arguments for tool %q: %s. Fix the arguments to match the input schema %s and call the tool again",
+		e.Tool, strings.Join(msgs, "; "), schema)
+}
+
+func normalizeSchema(schema interface{}) map[string]interface{} {
+	return jsonschema.Normalize(schema)
+}
+
+// Validate checks value against a JSON Schema subset; see jsonschema.Validate.
+func Validate(schema interface{}, value interface{}) []Issue {
+	return jsonschema.Validate(schema, value)
+}
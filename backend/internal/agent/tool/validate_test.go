@@ -0,0 +1,111 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ems/backend/internal/agent/dto"
+	"github.com/ems/backend/internal/model"
+)
+
+type validateTestArgs struct {
+	EquipmentID uint   `json:"equipment_id" binding:"required"`
+	Priority    int    `json:"priority" binding:"omitempty,oneof=1 2 3"`
+	Keyword     string `json:"keyword" binding:"max=5"`
+}
+
+func TestValidate(t *testing.T) {
+	schema := SchemaOf(validateTestArgs{})
+
+	cases := []struct {
+		name   string
+		args   map[string]interface{}
+		issues []string
+	}{
+		{"valid json numbers", map[string]interface{}{"equipment_id": float64(3), "priority": float64(2)}, nil},
+		{"go integers", map[string]interface{}{"equipment_id": uint(3)}, nil},
+		{"missing required", map[string]interface{}{}, []string{"equipment_id: is required"}},
+		{"wrong type", map[string]interface{}{"equipment_id": "3"}, []string{`equipment_id: expected integer, got string "3"`}},
+		{"fractional integer", map[string]interface{}{"equipment_id": 1.5}, []string{"equipment_id: expected integer, got number 1.5"}},
+		{"negative uint", map[string]interface{}{"equipment_id": float64(-1)}, []string{"equipment_id: must be >= 0, got -1"}},
+		{"enum", map[string]interface{}{"equipment_id": float64(1), "priority": float64(5)}, []string{"priority: must be one of 1, 2, 3, got integer 5"}},
+		{"max length", map[string]interface{}{"equipment_id": float64(1), "keyword": "abcdef"}, []string{"keyword: must be at most 5 characters"}},
+		{"null optional", map[string]interface{}{"equipment_id": float64(1), "keyword": nil}, nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			issues := Validate(schema, tc.args)
+			if len(issues) != len(tc.issues) {
+				t.Fatalf("Expected %v, got %v", tc.issues, issues)
+			}
+			for i, want := range tc.issues {
+				if issues[i].String() != want {
+					t.Errorf("Expected %q, got %q", want, issues[i].String())
+				}
+			}
+		})
+	}
+}
+
+func TestValidate_HandWrittenSchema(t *testing.T) {
+	schema := map[string]interface{}{
+		"type": "object", "properties": map[string]interface{}{
+			"ids": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "integer"}},
+		}, "required": []string{"ids"}, "additionalProperties": false,
+	}
+	issues := Validate(schema, map[string]interface{}{"ids": []interface{}{float64(1), "x"}, "extra": true})
+	if len(issues) != 2 || issues[0].Path != "extra" || issues[1].Path != "ids[1]" {
+		t.Errorf("Unexpected issues: %v", issues)
+	}
+}
+
+func TestToolRegistry_ValidatesBeforeDispatch(t *testing.T) {
+	registry := NewToolRegistry()
+	registry.catalog = nil
+	called := false
+	registry.Register("typed_tool", dto.ToolDefinition{Name: "typed_tool", InputSchema: SchemaOf(validateTestArgs{})},
+		Typed(func(ctx context.Context, user model.User, args validateTestArgs) (interface{}, error) {
+			called = true
+			return args.EquipmentID, nil
+		}), nil, true)
+
+	_, err := registry.Call(context.Background(), "typed_tool", model.User{}, map[string]interface{}{"equipment_id": "abc"}, nil)
+	var verr *ValidationError
+	if !errors.As(err, &verr) || called {
+		t.Fatalf("Expected validation error before dispatch, got %v (called=%v)", err, called)
+	}
+	if !strings.Contains(err.Error(), `"equipment_id"`) || !strings.Contains(err.Error(), "call the tool again") {
+		t.Errorf("Expected self-correction hint with schema, got %q", err.Error())
+	}
+
+	res, err := registry.Call(context.Background(), "typed_tool", model.User{}, map[string]interface{}{"equipment_id": float64(9)}, nil)
+	if err != nil || res != uint(9) {
+		t.Errorf("Expected typed binding to receive 9, got %v, %v", res, err)
+	}
+}
+
+func TestToolRegistry_ValidatesOutput(t *testing.T) {
+	registry := NewToolRegistry()
+	registry.catalog = nil
+	type row struct {
+		Name  string  `json:"name"`
+		Value float64 `json:"value"`
+	}
+	var out interface{}
+	registry.Register("out_tool", dto.ToolDefinition{Name: "out_tool", OutputSchema: SchemaOf([]row{})},
+		func(ctx context.Context, user model.User, args map[string]interface{}) (interface{}, error) { return out, nil }, nil, true)
+
+	out = []row{{Name: "a", Value: 1}}
+	if _, err := registry.Call(context.Background(), "out_tool", model.User{}, nil, nil); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	out = []map[string]interface{}{{"name": 1}}
+	_, err := registry.Call(context.Background(), "out_tool", model.User{}, nil, nil)
+	var verr *ValidationError
+	if !errors.As(err, &verr) || !verr.Output || verr.Issues[0].Path != "[0].name" {
+		t.Errorf("Expected output validation error, got %v", err)
+	}
+}
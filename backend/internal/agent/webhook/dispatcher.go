@@ -0,0 +1,292 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ems/backend/internal/model"
+)
+
+// Store 投递队列与订阅的持久化接口，由 agent 仓储实现
+type Store interface {
+	GetPushSubscriptionByID(id uint) (*model.AgentPushSubscription, error)
+	ResetPushSubscriptionFailures(id uint) error
+	RecordPushSubscriptionFailure(id uint, disableAfter int, now time.Time) (bool, error)
+	CreatePushLog(log *model.AgentPushLog) error
+	UpdatePushLog(log *model.AgentPushLog) error
+	ClaimDuePushLogs(now time.Time, lease time.Duration, limit int) ([]model.AgentPushLog, error)
+	CreatePushDeadLetter(dl *model.AgentPushDeadLetter) error
+}
+
+const (
+	StatusPending = "pending"
+	StatusSending = "sending" // 同步测试投递进行中，不进入队列
+	StatusSuccess = "success"
+	StatusFailed  = "failed" // 同步测试投递失败，不重试
+	StatusDead    = "dead"
+
+	ReasonMaxAttempts          = "max_attempts"
+	ReasonNonRetryable         = "non_retryable"
+	ReasonSubscriptionDisabled = "subscription_disabled"
+
+	defaultPollInterval   = 2 * time.Second
+	defaultRequestTimeout = 10 * time.Second
+	defaultMaxAttempts    = 10
+	defaultDisableAfter   = 20
+	// SecretGracePeriod 轮换后旧密钥继续签名的时长
+	SecretGracePeriod = 24 * time.Hour
+
+	baseBackoff    = 10 * time.Second
+	maxBackoff     = time.Hour
+	claimLease     = 2 * time.Minute
+	claimBatch     = 50
+	maxErrorLength = 500
+)
+
+// Dispatcher 从持久化队列中领取到期投递并发送，进程重启后未完成的投递会被继续处理
+type Dispatcher struct {
+	store  Store
+	client *http.Client
+	wake   chan struct{}
+
+	PollInterval time.Duration
+	MaxAttempts  int // 单次投递的最大尝试次数，超过后进入死信
+	DisableAfter int // 订阅连续失败次数达到该值时自动停用
+	// AllowPrivateNetworks 为 true 时允许投递到回环与内网地址，仅用于测试
+	AllowPrivateNetworks bool
+
+	now    func() time.Time
+	randMu sync.Mutex
+	rand   *rand.Rand
+}
+
+func NewDispatcher(store Store) *Dispatcher {
+	d := &Dispatcher{
+		store:        store,
+		wake:         make(chan struct{}, 1),
+		PollInterval: defaultPollInterval,
+		MaxAttempts:  defaultMaxAttempts,
+		DisableAfter: defaultDisableAfter,
+		now:          time.Now,
+		rand:         rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	d.client = d.newClient()
+	return d
+}
+
+// Enqueue 将负载写入投递队列，由 Run 异步发送
+func (d *Dispatcher) Enqueue(sub model.AgentPushSubscription, eventType string, artifactID uint, body []byte) (*model.AgentPushLog, error) {
+	return d.enqueue(&model.AgentPushLog{
+		SubscriptionID: sub.ID, ArtifactID: artifactID, EventType: eventType, Payload: string(body),
+	})
+}
+
+// Redeliver 以原负载重新入队一次投递，新记录通过 RedeliveryOf 关联原投递
+func (d *Dispatcher) Redeliver(src *model.AgentPushLog) (*model.AgentPushLog, error) {
+	srcID := src.ID
+	return d.enqueue(&model.AgentPushLog{
+		SubscriptionID: src.SubscriptionID, ArtifactID: src.ArtifactID, EventType: src.EventType,
+		Payload: src.Payload, RedeliveryOf: &srcID,
+	})
+}
+
+// Ping 同步发送一次测试请求并返回投递记录；失败不重试，也不计入订阅的连续失败次数
+func (d *Dispatcher) Ping(ctx context.Context, sub *model.AgentPushSubscription, eventType string, body []byte) (*model.AgentPushLog, error) {
+	entry := &model.AgentPushLog{SubscriptionID: sub.ID, EventType: eventType, Payload: string(body), Status: StatusSending}
+	if err := d.store.CreatePushLog(entry); err != nil {
+		return nil, err
+	}
+	code, latency, err := d.send(ctx, sub, entry)
+	entry.RetryCount, entry.ResponseCode, entry.LatencyMs = 1, code, latency.Milliseconds()
+	if err != nil {
+		entry.Status, entry.ErrorMessage = StatusFailed, truncate(err.Error(), maxErrorLength)
+	} else {
+		now := d.now()
+		entry.Status, entry.DeliveredAt = StatusSuccess, &now
+	}
+	d.save(entry)
+	return entry, nil
+}
+
+func (d *Dispatcher) enqueue(entry *model.AgentPushLog) (*model.AgentPushLog, error) {
+	now := d.now()
+	entry.Status, entry.NextAttemptAt = StatusPending, &now
+	if err := d.store.CreatePushLog(entry); err != nil {
+		return nil, err
+	}
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+	return entry, nil
+}
+
+// Run 轮询投递队列直到 ctx 结束
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.PollInterval)
+	defer ticker.Stop()
+	log.Printf("[Webhook] Dispatcher started")
+
+	for {
+		d.dispatchDue(ctx)
+		select {
+		case <-ctx.Done():
+			log.Printf("[Webhook] Dispatcher stopped")
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+func (d *Dispatcher) dispatchDue(ctx context.Context) {
+	entries, err := d.store.ClaimDuePushLogs(d.now(), claimLease, claimBatch)
+	if err != nil {
+		log.Printf("[Webhook] Failed to claim deliveries: %v", err)
+		return
+	}
+	for i := range entries {
+		if ctx.Err() != nil {
+			return
+		}
+		d.Attempt(ctx, &entries[i])
+	}
+}
+
+// Attempt 发送一次投递并更新投递记录、订阅健康状况，必要时写入死信
+func (d *Dispatcher) Attempt(ctx context.Context, entry *model.AgentPushLog) {
+	sub, err := d.store.GetPushSubscriptionByID(entry.SubscriptionID)
+	if err != nil || !sub.Enabled || sub.WebhookURL == "" {
+		entry.ErrorMessage = "subscription disabled or removed"
+		d.deadLetter(entry, ReasonSubscriptionDisabled)
+		return
+	}
+
+	code, latency, sendErr := d.send(ctx, sub, entry)
+	entry.RetryCount++
+	entry.ResponseCode = code
+	entry.LatencyMs = latency.Milliseconds()
+
+	if sendErr == nil {
+		now := d.now()
+		entry.Status, entry.ErrorMessage, entry.NextAttemptAt, entry.DeliveredAt = StatusSuccess, "", nil, &now
+		d.save(entry)
+		if sub.ConsecutiveFailures > 0 {
+			if err := d.store.ResetPushSubscriptionFailures(sub.ID); err != nil {
+				log.Printf("[Webhook] Failed to reset failures of subscription %d: %v", sub.ID, err)
+			}
+		}
+		return
+	}
+
+	entry.ErrorMessage = truncate(sendErr.Error(), maxErrorLength)
+	d.recordFailure(sub)
+	switch {
+	case !retryable(code):
+		d.deadLetter(entry, ReasonNonRetryable)
+	case entry.RetryCount >= d.MaxAttempts:
+		d.deadLetter(entry, ReasonMaxAttempts)
+	default:
+		next := d.now().Add(d.backoff(entry.RetryCount))
+		entry.NextAttemptAt = &next
+		d.save(entry)
+	}
+}
+
+func (d *Dispatcher) send(ctx context.Context, sub *model.AgentPushSubscription, entry *model.AgentPushLog) (int, time.Duration, error) {
+	body := []byte(entry.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, 0, err
+	}
+	now := d.now()
+	ts := now.Unix()
+	secrets := []string{sub.Secret}
+	if sub.PreviousSecret != "" && sub.SecretRotatedAt != nil && now.Sub(*sub.SecretRotatedAt) < SecretGracePeriod {
+		secrets = append(secrets, sub.PreviousSecret)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, entry.EventType)
+	req.Header.Set(HeaderDelivery, strconv.FormatUint(uint64(entry.ID), 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(ts, 10))
+	if sig := SignatureHeader(ts, body, secrets...); sig != "" {
+		req.Header.Set(HeaderSignature, sig)
+	}
+
+	start := time.Now()
+	resp, err := d.client.Do(req)
+	latency := time.Since(start)
+	if err != nil {
+		return 0, latency, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, latency, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return resp.StatusCode, latency, nil
+}
+
+// recordFailure 累加订阅的连续失败次数，达到阈值时自动停用；只更新健康状况列，
+// 不回写投递开始时读到的整条订阅，以免覆盖期间的修改
+func (d *Dispatcher) recordFailure(sub *model.AgentPushSubscription) {
+	disabled, err := d.store.RecordPushSubscriptionFailure(sub.ID, d.DisableAfter, d.now())
+	if err != nil {
+		log.Printf("[Webhook] Failed to record failure of subscription %d: %v", sub.ID, err)
+		return
+	}
+	if disabled {
+		log.Printf("[Webhook] Subscription %d disabled after %d consecutive failures", sub.ID, d.DisableAfter)
+	}
+}
+
+func (d *Dispatcher) deadLetter(entry *model.AgentPushLog, reason string) {
+	entry.Status, entry.NextAttemptAt = StatusDead, nil
+	d.save(entry)
+	dl := &model.AgentPushDeadLetter{
+		SubscriptionID: entry.SubscriptionID, PushLogID: entry.ID, EventType: entry.EventType,
+		Payload: entry.Payload, Attempts: entry.RetryCount, LastResponseCode: entry.ResponseCode,
+		LastError: entry.ErrorMessage, Reason: reason,
+	}
+	if err := d.store.CreatePushDeadLetter(dl); err != nil {
+		log.Printf("[Webhook] Failed to dead-letter delivery %d: %v", entry.ID, err)
+	}
+}
+
+func (d *Dispatcher) save(entry *model.AgentPushLog) {
+	if err := d.store.UpdatePushLog(entry); err != nil {
+		log.Printf("[Webhook] Failed to save delivery %d: %v", entry.ID, err)
+	}
+}
+
+// backoff 指数退避加抖动：第 n 次失败后等待 10s·2^(n-1) 的 50%~100%，最长 1 小时
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := baseBackoff << uint(attempts-1)
+	if delay <= 0 || delay > maxBackoff {
+		delay = maxBackoff
+	}
+	d.randMu.Lock()
+	jitter := time.Duration(d.rand.Int63n(int64(delay/2) + 1))
+	d.randMu.Unlock()
+	return delay/2 + jitter
+}
+
+// retryable 网络错误、超时、限流与服务端错误可重试，其他 4xx 视为接收方拒绝
+func retryable(code int) bool {
+	return code == 0 || code == http.StatusRequestTimeout || code == http.StatusTooManyRequests || code >= 500
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
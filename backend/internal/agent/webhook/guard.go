@@ -0,0 +1,87 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrBlockedAddress 目标解析到回环、链路本地或内网地址，拒绝投递以免被用来探测内部服务
+var ErrBlockedAddress = errors.New("webhook host resolves to a loopback, link-local or private address")
+
+// blockedIP 回环、链路本地（含云厂商元数据地址 169.254.169.254）、内网与未指定地址
+func blockedIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsPrivate() || ip.IsUnspecified()
+}
+
+// CheckURL 解析地址中的主机名，任一解析结果为受限地址时拒绝
+func (d *Dispatcher) CheckURL(ctx context.Context, raw string) error {
+	if d.AllowPrivateNetworks {
+		return nil
+	}
+	return CheckURL(ctx, raw)
+}
+
+// CheckURL 解析地址中的主机名，任一解析结果为受限地址时拒绝；通知渠道等其他出站请求共用
+func CheckURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Hostname() == "" {
+		return fmt.Errorf("invalid webhook URL %q", raw)
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return fmt.Errorf("resolve webhook host: %w", err)
+	}
+	for _, a := range addrs {
+		if blockedIP(a.IP) {
+			return ErrBlockedAddress
+		}
+	}
+	return nil
+}
+
+// newClient 投递用的 HTTP 客户端，AllowPrivateNetworks 在拨号时读取，测试可在创建后修改
+func (d *Dispatcher) newClient() *http.Client {
+	return guardedClient(defaultRequestTimeout, func() bool { return d.AllowPrivateNetworks })
+}
+
+// GuardedClient 返回在建立连接时检查实际拨号 IP 的 HTTP 客户端，
+// 防止校验后 DNS 改指向内网（DNS rebinding）或经重定向跳转到内网
+func GuardedClient(timeout time.Duration) *http.Client {
+	return guardedClient(timeout, func() bool { return false })
+}
+
+func guardedClient(timeout time.Duration, allowPrivate func() bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			if allowPrivate() {
+				return nil
+			}
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || blockedIP(ip) {
+				return ErrBlockedAddress
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// 不走环境代理，否则拨号检查的是代理地址而不是目标地址
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+			MaxIdleConnsPerHost: 4,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+}
@@ -0,0 +1,80 @@
+// Package webhook delivers agent push payloads to subscriber endpoints. Every
+// request is signed with the subscription secret, queued persistently in
+// agent_push_logs and retried with backoff until it succeeds or is moved to the
+// dead-letter store.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	HeaderEvent     = "X-EMS-Event"
+	HeaderDelivery  = "X-EMS-Delivery"
+	HeaderTimestamp = "X-EMS-Timestamp"
+	HeaderSignature = "X-EMS-Signature"
+
+	secretPrefix    = "whsec_"
+	signaturePrefix = "v1="
+)
+
+var (
+	ErrSignatureMismatch = errors.New("webhook signature mismatch")
+	ErrTimestampExpired  = errors.New("webhook timestamp outside tolerance")
+)
+
+// GenerateSecret 生成新的订阅签名密钥
+func GenerateSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return secretPrefix + hex.EncodeToString(buf), nil
+}
+
+// Sign 计算 HMAC-SHA256("<timestamp>.<body>")，防止负载被篡改或重放
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignatureHeader 生成 X-EMS-Signature 的值；密钥轮换宽限期内会同时携带新旧两个签名，以逗号分隔
+func SignatureHeader(timestamp int64, body []byte, secrets ...string) string {
+	var parts []string
+	for _, secret := range secrets {
+		if secret != "" {
+			parts = append(parts, signaturePrefix+Sign(secret, timestamp, body))
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// Verify 供接收方校验请求：时间戳需在 tolerance 内，且任一签名与 secret 匹配
+func Verify(secret, signatureHeader, timestampHeader string, body []byte, tolerance time.Duration, now time.Time) error {
+	ts, err := strconv.ParseInt(timestampHeader, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", HeaderTimestamp, err)
+	}
+	if d := now.Sub(time.Unix(ts, 0)); d > tolerance || d < -tolerance {
+		return ErrTimestampExpired
+	}
+	expected := Sign(secret, ts, body)
+	for _, part := range strings.Split(signatureHeader, ",") {
+		sig := strings.TrimPrefix(strings.TrimSpace(part), signaturePrefix)
+		if hmac.Equal([]byte(sig), []byte(expected)) {
+			return nil
+		}
+	}
+	return ErrSignatureMismatch
+}
@@ -0,0 +1,194 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ems/backend/internal/agent/repository"
+	"github.com/ems/backend/internal/model"
+	"github.com/ems/backend/pkg/memory"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	now := time.Unix(1767225600, 0)
+	body := []byte(`{"event":"repair.created"}`)
+	header := SignatureHeader(now.Unix(), body, "whsec_new", "whsec_old")
+	if strings.Count(header, "v1=") != 2 {
+		t.Fatalf("Expected both signatures during rotation, got %q", header)
+	}
+	ts := "1767225600"
+	for _, secret := range []string{"whsec_new", "whsec_old"} {
+		if err := Verify(secret, header, ts, body, 5*time.Minute, now); err != nil {
+			t.Errorf("Expected %s to verify, got %v", secret, err)
+		}
+	}
+	if err := Verify("whsec_new", header, ts, []byte(`{"event":"tampered"}`), 5*time.Minute, now); err != ErrSignatureMismatch {
+		t.Errorf("Expected tampered body to be rejected, got %v", err)
+	}
+	if err := Verify("whsec_new", header, ts, body, 5*time.Minute, now.Add(10*time.Minute)); err != ErrTimestampExpired {
+		t.Errorf("Expected stale timestamp to be rejected, got %v", err)
+	}
+}
+
+func newTestDispatcher(t *testing.T, url string) (*Dispatcher, repository.IAgentRepository, *model.AgentPushSubscription) {
+	t.Helper()
+	memory.GetStore()
+	repo := repository.NewMemoryAgentRepository()
+	sub := &model.AgentPushSubscription{UserID: 1, PushType: "repair.created", Enabled: true, WebhookURL: url, Secret: "whsec_test"}
+	if err := repo.CreatePushSubscription(sub); err != nil {
+		t.Fatal(err)
+	}
+	d := NewDispatcher(repo)
+	d.AllowPrivateNetworks = true // 测试接收端监听在回环地址
+	return d, repo, sub
+}
+
+func TestBlockedIP(t *testing.T) {
+	cases := map[string]bool{
+		"127.0.0.1": true, "::1": true, "10.1.2.3": true, "172.16.0.9": true, "192.168.1.20": true,
+		"169.254.169.254": true, "fe80::1": true, "fd00::1": true, "0.0.0.0": true,
+		"8.8.8.8": false, "2606:4700:4700::1111": false,
+	}
+	for addr, want := range cases {
+		if got := blockedIP(net.ParseIP(addr)); got != want {
+			t.Errorf("blockedIP(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestDispatcherRefusesPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Expected no request to reach the loopback receiver")
+	}))
+	defer srv.Close()
+
+	d, _, sub := newTestDispatcher(t, srv.URL)
+	d.AllowPrivateNetworks = false
+	if err := d.CheckURL(context.Background(), srv.URL); !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("Expected loopback URL to be blocked, got %v", err)
+	}
+	if err := d.CheckURL(context.Background(), "https://8.8.8.8/hook"); err != nil {
+		t.Errorf("Expected public address to be allowed, got %v", err)
+	}
+	// 即使绕过了地址校验，拨号时仍会拒绝
+	entry, err := d.Ping(context.Background(), sub, "ping", []byte(`{}`))
+	if err != nil || entry.Status != StatusFailed || !strings.Contains(entry.ErrorMessage, ErrBlockedAddress.Error()) {
+		t.Errorf("Expected the ping to be refused at dial time, got %+v, %v", entry, err)
+	}
+}
+
+func TestAttemptSignsAndRecordsSuccess(t *testing.T) {
+	var verifyErr error
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		verifyErr = Verify("whsec_test", r.Header.Get(HeaderSignature), r.Header.Get(HeaderTimestamp), body, time.Minute, time.Now())
+		if r.Header.Get(HeaderEvent) != "repair.created" || r.Header.Get(HeaderDelivery) == "" {
+			verifyErr = io.ErrUnexpectedEOF
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	d, repo, sub := newTestDispatcher(t, srv.URL)
+	entry, err := d.Enqueue(*sub, "repair.created", 0, []byte(`{"order_id":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.dispatchDue(context.Background())
+	if verifyErr != nil {
+		t.Fatalf("Receiver rejected the request: %v", verifyErr)
+	}
+
+	claimed, _ := repo.ClaimDuePushLogs(time.Now().Add(time.Hour), time.Minute, 100)
+	for _, l := range claimed {
+		if l.ID == entry.ID {
+			t.Fatalf("Expected delivered entry to leave the queue")
+		}
+	}
+	saved := memory.GetStore().AgentPushLogs[entry.ID]
+	if saved.Status != StatusSuccess || saved.ResponseCode != http.StatusNoContent || saved.DeliveredAt == nil || saved.RetryCount != 1 {
+		t.Errorf("Unexpected delivery record %+v", saved)
+	}
+}
+
+func TestAttemptRetriesThenDeadLetters(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	d, _, sub := newTestDispatcher(t, srv.URL)
+	d.MaxAttempts = 3
+	now := time.Now()
+	d.now = func() time.Time { return now }
+	entry, _ := d.Enqueue(*sub, "repair.created", 0, []byte(`{}`))
+
+	d.Attempt(context.Background(), entry)
+	if entry.Status != StatusPending || entry.NextAttemptAt == nil {
+		t.Fatalf("Expected a scheduled retry, got %+v", entry)
+	}
+	if wait := entry.NextAttemptAt.Sub(now); wait < 5*time.Second || wait > 10*time.Second {
+		t.Errorf("Expected first backoff between 5s and 10s, got %v", wait)
+	}
+	d.Attempt(context.Background(), entry)
+	d.Attempt(context.Background(), entry)
+	if entry.Status != StatusDead || atomic.LoadInt32(&calls) != 3 {
+		t.Fatalf("Expected dead status after 3 attempts, got %s after %d calls", entry.Status, calls)
+	}
+	var found *model.AgentPushDeadLetter
+	for _, dl := range memory.GetStore().AgentPushDeadLetters {
+		if dl.PushLogID == entry.ID {
+			found = dl
+		}
+	}
+	if found == nil || found.Reason != ReasonMaxAttempts || found.LastResponseCode != http.StatusBadGateway || found.Attempts != 3 {
+		t.Errorf("Unexpected dead letter %+v", found)
+	}
+}
+
+func TestAttemptDisablesFailingSubscription(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer srv.Close()
+
+	d, repo, sub := newTestDispatcher(t, srv.URL)
+	d.DisableAfter = 2
+	first, _ := d.Enqueue(*sub, "repair.created", 0, []byte(`{}`))
+	d.Attempt(context.Background(), first)
+	if first.Status != StatusDead {
+		t.Fatalf("Expected 410 to be dead-lettered without retry, got %s", first.Status)
+	}
+	second, _ := d.Enqueue(*sub, "repair.created", 0, []byte(`{}`))
+	d.Attempt(context.Background(), second)
+
+	saved, _ := repo.GetPushSubscriptionByID(sub.ID)
+	if saved.Enabled || saved.DisabledAt == nil || saved.ConsecutiveFailures != 2 {
+		t.Fatalf("Expected subscription to be auto-disabled, got %+v", saved)
+	}
+	third, _ := d.Enqueue(*saved, "repair.created", 0, []byte(`{}`))
+	d.Attempt(context.Background(), third)
+	if third.RetryCount != 0 || third.Status != StatusDead {
+		t.Errorf("Expected deliveries to a disabled subscription to be dead-lettered unsent, got %+v", third)
+	}
+}
+
+func TestBackoffIsCappedWithJitter(t *testing.T) {
+	d := NewDispatcher(nil)
+	for attempts := 1; attempts <= 20; attempts++ {
+		delay := d.backoff(attempts)
+		if delay < baseBackoff/2 || delay > maxBackoff {
+			t.Errorf("backoff(%d) = %v out of range", attempts, delay)
+		}
+	}
+}
@@ -83,3 +83,9 @@ type AnalyticsQuery struct {
 	FactoryID *uint  `form:"factory_id"`
 	EquipmentTypeID *uint `form:"equipment_type_id"`
 }
+
+// RankingQuery represents query parameters for equipment rankings
+type RankingQuery struct {
+	Limit     int   `form:"limit" binding:"omitempty,min=1,max=100" desc:"Number of equipment to return, default 10"`
+	FactoryID *uint `form:"factory_id" desc:"Ignored for non-admin users, who only see their own factory"`
+}
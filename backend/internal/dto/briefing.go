@@ -0,0 +1,16 @@
+package dto
+
+// =====================================================
+// Agent Briefing DTOs
+// =====================================================
+
+// BriefingRequest creates or updates a scheduled briefing definition
+type BriefingRequest struct {
+	Name      string `json:"name" binding:"required"`
+	Cron      string `json:"cron" binding:"required"`                                 // 如 "0 8 * * *"
+	Period    string `json:"period" binding:"omitempty,oneof=daily weekly"`           // 统计窗口
+	Audience  string `json:"audience" binding:"required,oneof=supervisor technician"` // 受众
+	FactoryID *uint  `json:"factory_id"`                                              // 为空表示所有工厂
+	Template  string `json:"template"`                                                // text/template，为空用默认模板
+	Enabled   *bool  `json:"enabled"`
+}
@@ -18,9 +18,10 @@ type BaseResponse struct {
 }
 
 type FactoryRequest struct {
-	BaseID uint   `json:"base_id" binding:"required"`
-	Code   string `json:"code" binding:"required"`
-	Name   string `json:"name" binding:"required"`
+	BaseID    uint   `json:"base_id" binding:"required"`
+	Code      string `json:"code" binding:"required"`
+	Name      string `json:"name" binding:"required"`
+	ManagerID *uint  `json:"manager_id"` // 厂长，升级链 factory_manager 的接收人
 }
 
 type FactoryResponse struct {
@@ -29,14 +30,16 @@ type FactoryResponse struct {
 	BaseName  string    `json:"base_name,omitempty"`
 	Code      string    `json:"code"`
 	Name      string    `json:"name"`
+	ManagerID *uint     `json:"manager_id"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
 type WorkshopRequest struct {
-	FactoryID uint   `json:"factory_id" binding:"required"`
-	Code      string `json:"code" binding:"required"`
-	Name      string `json:"name" binding:"required"`
+	FactoryID    uint   `json:"factory_id" binding:"required"`
+	Code         string `json:"code" binding:"required"`
+	Name         string `json:"name" binding:"required"`
+	SupervisorID *uint  `json:"supervisor_id"` // 车间主管，升级链 workshop_supervisor 的接收人
 }
 
 type WorkshopResponse struct {
@@ -45,6 +48,7 @@ type WorkshopResponse struct {
 	FactoryName string   `json:"factory_name,omitempty"`
 	Code       string    `json:"code"`
 	Name       string    `json:"name"`
+	SupervisorID *uint   `json:"supervisor_id"`
 	CreatedAt  time.Time `json:"created_at"`
 	UpdatedAt  time.Time `json:"updated_at"`
 }
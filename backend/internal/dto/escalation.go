@@ -0,0 +1,53 @@
+package dto
+
+import "time"
+
+// =====================================================
+// Escalation DTOs
+// =====================================================
+
+// EscalationStep 升级链中的一级，after_minutes 从维修单或告警产生时起算
+type EscalationStep struct {
+	AfterMinutes int    `json:"after_minutes"`
+	Target       string `json:"target"` // assignee, workshop_supervisor, factory_manager, on_call, role:<role>, user:<id>
+}
+
+// EscalationPolicyRequest 新增或修改升级策略，factory_id 为空表示默认策略（仅管理员）
+type EscalationPolicyRequest struct {
+	Name      string           `json:"name" binding:"required"`
+	FactoryID *uint            `json:"factory_id"`
+	Priority  int              `json:"priority" binding:"required,oneof=1 2 3"`
+	Steps     []EscalationStep `json:"steps" binding:"required"`
+	Enabled   *bool            `json:"enabled"`
+}
+
+// EscalationPolicyResponse 升级策略
+type EscalationPolicyResponse struct {
+	ID        uint             `json:"id"`
+	Name      string           `json:"name"`
+	FactoryID *uint            `json:"factory_id"`
+	Priority  int              `json:"priority"`
+	Steps     []EscalationStep `json:"steps"`
+	Enabled   bool             `json:"enabled"`
+}
+
+// EscalationQuery 升级列表筛选
+type EscalationQuery struct {
+	Status string `form:"status" binding:"omitempty,oneof=active acknowledged resolved exhausted"`
+	Limit  int    `form:"limit"`
+}
+
+// OnCallShiftRequest 新增值班，factory_id 为空表示不限工厂
+type OnCallShiftRequest struct {
+	FactoryID *uint     `json:"factory_id"`
+	UserID    uint      `json:"user_id" binding:"required"`
+	StartAt   time.Time `json:"start_at" binding:"required"`
+	EndAt     time.Time `json:"end_at" binding:"required"`
+	Note      string    `json:"note"`
+}
+
+// OnCallQuery 值班表查询区间，默认从现在起 7 天
+type OnCallQuery struct {
+	From *time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To   *time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+}
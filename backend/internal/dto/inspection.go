@@ -52,10 +52,24 @@ type InspectionItemResponse struct {
 type InspectionTaskQuery struct {
 	Page       int    `form:"page" binding:"min=1"`
 	PageSize   int    `form:"page_size" binding:"min=1,max=100"`
-	AssignedTo uint   `form:"assigned_to"`
-	Status     string `form:"status"`
-	DateFrom   string `form:"date_from"`
-	DateTo     string `form:"date_to"`
+	AssignedTo uint   `form:"assigned_to" desc:"Inspector user ID"`
+	Status     string `form:"status" desc:"pending, in_progress, completed or overdue"`
+	DateFrom   string `form:"date_from" desc:"YYYY-MM-DD"`
+	DateTo     string `form:"date_to" desc:"YYYY-MM-DD"`
+}
+
+// InspectionHistoryQuery represents query parameters for an equipment's inspection history
+type InspectionHistoryQuery struct {
+	EquipmentID uint   `form:"equipment_id" binding:"required" desc:"Equipment ID"`
+	DateFrom    string `form:"date_from" desc:"YYYY-MM-DD"`
+	DateTo      string `form:"date_to" desc:"YYYY-MM-DD"`
+	Limit       int    `form:"limit" binding:"omitempty,min=1,max=100" desc:"Number of tasks to return, default 20"`
+}
+
+// NGTrendQuery represents query parameters for the daily NG trend
+type NGTrendQuery struct {
+	EquipmentID uint `form:"equipment_id" desc:"Equipment ID; omit for the whole factory"`
+	Days        int  `form:"days" binding:"omitempty,min=1,max=180" desc:"Number of days to look back, default 30"`
 }
 
 type InspectionTaskResponse struct {
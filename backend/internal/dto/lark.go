@@ -5,7 +5,7 @@ type LarkWebhookRequest struct {
 	Schema string                 `json:"schema"`
 	Header LarkWebhookHeader      `json:"header"`
 	Event  map[string]interface{} `json:"event"`
-	
+
 	// Challenge fields
 	Challenge string `json:"challenge"`
 	Token     string `json:"token"`
@@ -52,3 +52,21 @@ type LarkMessage struct {
 type LarkMessageTextContent struct {
 	Text string `json:"text"`
 }
+
+// LarkCardActionEvent 卡片按钮回调（card.action.trigger）的事件体
+type LarkCardActionEvent struct {
+	Operator LarkSenderID    `json:"operator"`
+	Token    string          `json:"token"` // 回调 token，可用于延时更新卡片
+	Action   LarkCardAction  `json:"action"`
+	Context  LarkCardContext `json:"context"`
+}
+
+type LarkCardAction struct {
+	Tag   string            `json:"tag"`
+	Value map[string]string `json:"value"` // 按钮发送时携带的 value
+}
+
+type LarkCardContext struct {
+	OpenMessageID string `json:"open_message_id"`
+	OpenChatID    string `json:"open_chat_id"`
+}
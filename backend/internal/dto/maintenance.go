@@ -88,6 +88,7 @@ type MaintenanceTaskResponse struct {
 	ScheduledDate  string                   `json:"scheduled_date"`
 	DueDate        string                   `json:"due_date"`
 	Status         MaintenanceTaskStatus    `json:"status"`
+	AutoGenerated  bool                     `json:"auto_generated"` // 由保养计划滚动生成
 	StartedAt      *time.Time                `json:"started_at,omitempty"`
 	CompletedAt    *time.Time                `json:"completed_at,omitempty"`
 	ActualHours    float64                  `json:"actual_hours"`
@@ -180,10 +181,40 @@ type MyMaintenanceStatistics struct {
 
 // MaintenanceTaskQuery represents query parameters for maintenance tasks
 type MaintenanceTaskQuery struct {
-	Status     string `form:"status"`
-	AssignedTo uint   `form:"assigned_to"`
-	DateFrom   string `form:"date_from"`
-	DateTo     string `form:"date_to"`
+	Status     string `form:"status" desc:"pending, in_progress, completed or overdue"`
+	AssignedTo uint   `form:"assigned_to" desc:"Maintenance worker user ID"`
+	DateFrom   string `form:"date_from" desc:"YYYY-MM-DD"`
+	DateTo     string `form:"date_to" desc:"YYYY-MM-DD"`
 	Page       int    `form:"page"`
 	PageSize   int    `form:"page_size"`
 }
+
+// MaintenanceCalendarQuery represents query parameters for the maintenance calendar
+type MaintenanceCalendarQuery struct {
+	DateFrom   string `form:"date_from" binding:"required" desc:"YYYY-MM-DD"`
+	DateTo     string `form:"date_to" binding:"required" desc:"YYYY-MM-DD, at most 62 days after date_from"`
+	AssignedTo uint   `form:"assigned_to" desc:"Maintenance worker user ID"`
+	Status     string `form:"status" desc:"pending, in_progress, completed or overdue"`
+}
+
+// MaintenanceCalendarDay groups the maintenance tasks scheduled on one day
+type MaintenanceCalendarDay struct {
+	Date  string                    `json:"date"`
+	Tasks []MaintenanceTaskResponse `json:"tasks"`
+}
+
+// CreateMaintenanceTaskRequest schedules a single maintenance task
+type CreateMaintenanceTaskRequest struct {
+	PlanID        uint   `json:"plan_id" binding:"required" desc:"Maintenance plan ID; must match the equipment type"`
+	EquipmentID   uint   `json:"equipment_id" binding:"required"`
+	ScheduledDate string `json:"scheduled_date" binding:"required" desc:"YYYY-MM-DD"`
+	AssignedTo    uint   `json:"assigned_to" desc:"Maintenance worker user ID from the equipment's factory; defaults to the equipment's dedicated maintainer"`
+	Remark        string `json:"remark" binding:"max=500"`
+}
+
+// RescheduleMaintenanceTaskRequest moves a task that has not started yet
+type RescheduleMaintenanceTaskRequest struct {
+	TaskID        uint   `json:"task_id" binding:"required"`
+	ScheduledDate string `json:"scheduled_date" binding:"required" desc:"New date, YYYY-MM-DD"`
+	Reason        string `json:"reason" binding:"required,max=200"`
+}
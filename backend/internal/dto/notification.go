@@ -0,0 +1,71 @@
+package dto
+
+// =====================================================
+// Notification DTOs
+// =====================================================
+
+// NotificationPreferenceRequest 设置某个渠道的接收偏好
+type NotificationPreferenceRequest struct {
+	Address    string   `json:"address"`     // 邮箱或机器人/Webhook 地址，飞书渠道可为空
+	Secret     *string  `json:"secret"`      // 为空表示保持不变，空字符串表示清除
+	Language   string   `json:"language"`    // zh-CN, en-US，为空使用默认语言
+	EventTypes []string `json:"event_types"` // 为空表示全部事件
+	Enabled    *bool    `json:"enabled"`
+}
+
+// NotificationPreferenceResponse 渠道偏好，不返回密钥
+type NotificationPreferenceResponse struct {
+	Channel    string   `json:"channel"`
+	Address    string   `json:"address"`
+	HasSecret  bool     `json:"has_secret"`
+	Language   string   `json:"language"`
+	EventTypes []string `json:"event_types"`
+	Enabled    bool     `json:"enabled"`
+}
+
+// NotificationChannelResponse 可用的通知渠道
+type NotificationChannelResponse struct {
+	Name       string `json:"name"`
+	Configured bool   `json:"configured"` // 邮件渠道需配置 SMTP
+}
+
+// NotificationTemplateRequest 新增或覆盖某事件类型与语言的模板
+type NotificationTemplateRequest struct {
+	EventType string `json:"event_type" binding:"required"`
+	Language  string `json:"language" binding:"required"`
+	Subject   string `json:"subject" binding:"required"`
+	Body      string `json:"body"`
+}
+
+// NotificationTemplateResponse 生效中的模板；Custom 为 false 时为内置模板
+type NotificationTemplateResponse struct {
+	ID        uint   `json:"id,omitempty"`
+	EventType string `json:"event_type"`
+	Language  string `json:"language"`
+	Subject   string `json:"subject"`
+	Body      string `json:"body"`
+	Custom    bool   `json:"custom"`
+}
+
+// NotificationDeliveryQuery 投递记录筛选
+type NotificationDeliveryQuery struct {
+	UserID  uint   `form:"user_id"` // 仅管理员可查看他人
+	Channel string `form:"channel"`
+	Status  string `form:"status" binding:"omitempty,oneof=success failed"`
+	Limit   int    `form:"limit"`
+}
+
+// QuietHoursRequest 设置免打扰时段，期间非紧急告警延后以摘要发送
+type QuietHoursRequest struct {
+	Start    string `json:"start" binding:"required"` // HH:MM
+	End      string `json:"end" binding:"required"`   // HH:MM，早于 start 表示跨夜
+	Timezone string `json:"timezone"`                 // IANA 时区，默认 Asia/Shanghai
+	Enabled  bool   `json:"enabled"`
+}
+
+// AlertQuery 告警列表筛选
+type AlertQuery struct {
+	Status   string `form:"status" binding:"omitempty,oneof=firing acknowledged resolved"`
+	Severity string `form:"severity" binding:"omitempty,oneof=low medium high critical"`
+	Limit    int    `form:"limit"`
+}
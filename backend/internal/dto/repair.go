@@ -174,3 +174,15 @@ type RepairTaskQuery struct {
 	DateFrom   string `form:"date_from"`
 	DateTo     string `form:"date_to"`
 }
+
+// RepairOrderSearchQuery represents query parameters for searching repair orders
+type RepairOrderSearchQuery struct {
+	FaultCode   string `form:"fault_code" desc:"Fault code, prefix match"`
+	EquipmentID uint   `form:"equipment_id"`
+	Status      string `form:"status" desc:"pending, assigned, in_progress, testing, confirmed, audited or closed"`
+	Priority    int    `form:"priority" binding:"omitempty,oneof=1 2 3"`
+	DateFrom    string `form:"date_from" desc:"Created on or after, YYYY-MM-DD"`
+	DateTo      string `form:"date_to" desc:"Created on or before, YYYY-MM-DD"`
+	Page        int    `form:"page"`
+	PageSize    int    `form:"page_size"`
+}
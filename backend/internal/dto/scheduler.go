@@ -0,0 +1,27 @@
+package dto
+
+import "time"
+
+// =====================================================
+// Scheduler DTOs
+// =====================================================
+
+// JobResponse 定时任务定义与调度状态
+type JobResponse struct {
+	Name           string     `json:"name"`
+	Description    string     `json:"description"`
+	Cron           string     `json:"cron"`
+	Misfire        string     `json:"misfire"` // run_once, skip
+	Paused         bool       `json:"paused"`
+	NextRunAt      *time.Time `json:"next_run_at"`
+	LastRunAt      *time.Time `json:"last_run_at"`
+	LastStatus     string     `json:"last_status"`
+	LastError      string     `json:"last_error,omitempty"`
+	LastDurationMs int64      `json:"last_duration_ms"`
+}
+
+// JobRunQuery 执行记录筛选
+type JobRunQuery struct {
+	Status string `form:"status" binding:"omitempty,oneof=success failed skipped"`
+	Limit  int    `form:"limit"`
+}
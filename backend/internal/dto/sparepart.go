@@ -55,6 +55,7 @@ type StockInRequest struct {
 	SparePartID uint   `json:"spare_part_id" binding:"required"`
 	FactoryID   uint   `json:"factory_id" binding:"required"`
 	Quantity    int    `json:"quantity" binding:"required,min=1"`
+	BatchNo     string `json:"batch_no" binding:"max=50"` // supplier lot, used by root cause analysis
 	Remark      string `json:"remark"`
 }
 
@@ -151,8 +152,8 @@ type LowStockAlert struct {
 
 // SparePartQuery represents query parameters for spare parts
 type SparePartQuery struct {
-	Code     string `form:"code"`
-	Name     string `form:"name"`
+	Code     string `form:"code" desc:"Part code, partial match"`
+	Name     string `form:"name" desc:"Part name, partial match"`
 	Page     int    `form:"page"`
 	PageSize int    `form:"page_size"`
 }
@@ -160,12 +161,39 @@ type SparePartQuery struct {
 // InventoryQuery represents query parameters for inventory
 type InventoryQuery struct {
 	SparePartID *uint  `form:"spare_part_id"`
-	FactoryID   *uint  `form:"factory_id"`
-	LowStock    *bool  `form:"low_stock"`
+	FactoryID   *uint  `form:"factory_id" desc:"Ignored for non-admin users, who only see their own factory"`
+	LowStock    *bool  `form:"low_stock" desc:"Only return items below safety stock"`
 	Page        int    `form:"page"`
 	PageSize    int    `form:"page_size"`
 }
 
+// IssueSparePartRequest issues (stocks out) spare parts for a repair order or maintenance task
+type IssueSparePartRequest struct {
+	SparePartID       uint   `json:"spare_part_id" binding:"required"`
+	Quantity          int    `json:"quantity" binding:"required,min=1,max=1000"`
+	RepairOrderID     *uint  `json:"repair_order_id" desc:"Repair order the parts are for; give this or maintenance_task_id"`
+	MaintenanceTaskID *uint  `json:"maintenance_task_id" desc:"Maintenance task the parts are for"`
+	FactoryID         *uint  `json:"factory_id" desc:"Stock location; ignored for non-admin users, who draw from their own factory"`
+	Remark            string `json:"remark" binding:"max=200"`
+	Confirm           bool   `json:"confirm" desc:"Must be true. Issuing is a permanent stock-out recorded as consumption; confirm with the user before calling"`
+}
+
+// ReserveSparePartRequest holds spare parts for a repair order or maintenance task without stocking out (agent tool)
+type ReserveSparePartRequest struct {
+	SparePartID       uint   `json:"spare_part_id" binding:"required"`
+	Quantity          int    `json:"quantity" binding:"required,min=1,max=1000"`
+	RepairOrderID     *uint  `json:"repair_order_id" desc:"Repair order the parts are held for; give this or maintenance_task_id"`
+	MaintenanceTaskID *uint  `json:"maintenance_task_id" desc:"Maintenance task the parts are held for"`
+	FactoryID         *uint  `json:"factory_id" desc:"Stock location; ignored for non-admin users, who reserve from their own factory"`
+	ExpiresInHours    int    `json:"expires_in_hours" binding:"omitempty,min=1,max=168" desc:"Hours until the hold lapses, default 24"`
+	Remark            string `json:"remark" binding:"max=200"`
+}
+
+// ReleaseSparePartReservationRequest releases an active reservation (agent tool)
+type ReleaseSparePartReservationRequest struct {
+	ReservationID uint `json:"reservation_id" binding:"required"`
+}
+
 // ConsumptionQuery represents query parameters for consumption records
 type ConsumptionQuery struct {
 	SparePartID *uint  `form:"spare_part_id"`
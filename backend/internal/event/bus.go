@@ -0,0 +1,258 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ems/backend/internal/model"
+	"github.com/ems/backend/internal/repository"
+	"gorm.io/gorm"
+)
+
+// Handler 处理一个事件；返回错误时该订阅者会按退避策略重试，已成功的订阅者不会重复收到
+type Handler func(ctx context.Context, e Event) error
+
+type subscriber struct {
+	name    string
+	types   map[Type]bool // 为空表示订阅全部类型
+	handler Handler
+}
+
+func (s subscriber) accepts(t Type) bool {
+	return len(s.types) == 0 || s.types[t]
+}
+
+const (
+	defaultPollInterval   = 2 * time.Second
+	defaultHandlerTimeout = 30 * time.Second
+	defaultMaxAttempts    = 8
+	claimLease            = 2 * time.Minute // 领取整批的初始租约；每条记录投递前再按订阅者数量续约
+	claimBatch            = 100
+	dispatchedRetention   = 7 * 24 * time.Hour
+)
+
+// Bus 进程内事件总线
+type Bus struct {
+	mu   sync.RWMutex
+	subs []subscriber
+	wake chan struct{}
+
+	PollInterval   time.Duration
+	HandlerTimeout time.Duration
+	MaxAttempts    int
+	now            func() time.Time
+}
+
+func NewBus() *Bus {
+	return &Bus{
+		wake:           make(chan struct{}, 1),
+		PollInterval:   defaultPollInterval,
+		HandlerTimeout: defaultHandlerTimeout,
+		MaxAttempts:    defaultMaxAttempts,
+		now:            time.Now,
+	}
+}
+
+var std = NewBus()
+
+// Default returns the process-wide bus used by business services
+func Default() *Bus { return std }
+
+// Publish records p on the default bus; see Bus.Publish
+func Publish(tx *gorm.DB, p Payload) error { return std.Publish(tx, p) }
+
+// Subscribe registers handler on the default bus; see Bus.Subscribe
+func Subscribe(name string, handler Handler, types ...Type) { std.Subscribe(name, handler, types...) }
+
+// Subscribe 注册订阅者；name 在总线内唯一，用于记录投递进度，types 为空时订阅全部事件
+func (b *Bus) Subscribe(name string, handler Handler, types ...Type) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	sub := subscriber{name: name, handler: handler, types: map[Type]bool{}}
+	for _, t := range types {
+		sub.types[t] = true
+	}
+	for i := range b.subs {
+		if b.subs[i].name == name {
+			b.subs[i] = sub
+			return
+		}
+	}
+	b.subs = append(b.subs, sub)
+}
+
+func (b *Bus) subscribers(t Type) []subscriber {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	var out []subscriber
+	for _, s := range b.subs {
+		if s.accepts(t) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// Publish 在 tx 中写入 outbox，随业务数据一起提交；事务回滚则事件也不会发出。
+// tx 为 nil（内存模式）时不做持久化，直接异步分发。
+func (b *Bus) Publish(tx *gorm.DB, p Payload) error {
+	body, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("marshal %s event: %w", p.EventType(), err)
+	}
+	scope := p.Scope()
+	if tx == nil {
+		e := Event{Type: p.EventType(), Subject: scope, OccurredAt: b.now(), Payload: body}
+		go b.deliver(context.Background(), e, map[string]bool{})
+		return nil
+	}
+	row := &model.DomainEvent{
+		Type: string(p.EventType()), FactoryID: scope.FactoryID, EquipmentID: scope.EquipmentID,
+		Payload: string(body), Status: "pending",
+	}
+	if err := repository.NewDomainEventRepository().WithTx(tx).Create(row); err != nil {
+		return fmt.Errorf("record %s event: %w", p.EventType(), err)
+	}
+	// 唤醒分发器；此时事务可能尚未提交，未提交的事件会在下一轮轮询时被领取
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Run 轮询 outbox 并投递事件，直到 ctx 结束
+func (b *Bus) Run(ctx context.Context) {
+	repo := repository.NewDomainEventRepository()
+	ticker := time.NewTicker(b.PollInterval)
+	defer ticker.Stop()
+	lastPurge := time.Time{}
+	log.Printf("[EventBus] Dispatcher started")
+
+	for {
+		b.dispatchDue(ctx, repo)
+		if now := b.now(); now.Sub(lastPurge) > time.Hour {
+			if n, err := repo.PurgeDispatched(now.Add(-dispatchedRetention)); err != nil {
+				log.Printf("[EventBus] Failed to purge dispatched events: %v", err)
+			} else if n > 0 {
+				log.Printf("[EventBus] Purged %d dispatched event(s)", n)
+			}
+			lastPurge = now
+		}
+		select {
+		case <-ctx.Done():
+			log.Printf("[EventBus] Dispatcher stopped")
+			return
+		case <-ticker.C:
+		case <-b.wake:
+		}
+	}
+}
+
+func (b *Bus) dispatchDue(ctx context.Context, repo *repository.DomainEventRepository) {
+	rows, err := repo.ClaimDue(b.now(), claimLease, claimBatch)
+	if err != nil {
+		log.Printf("[EventBus] Failed to claim events: %v", err)
+		return
+	}
+	for i := range rows {
+		row := &rows[i]
+		// 批次靠后的记录可能在轮到它之前租约就已过期，投递前续约；已被其他实例接管的跳过
+		ok, err := repo.ExtendLease(row, b.now().Add(b.rowLease(Type(row.Type))))
+		if err != nil {
+			log.Printf("[EventBus] Failed to extend lease of event %d: %v", row.ID, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		b.dispatchRow(ctx, row)
+		if err := repo.SaveResult(row); err != nil {
+			log.Printf("[EventBus] Failed to save result of event %d: %v", row.ID, err)
+		}
+	}
+}
+
+// rowLease 投递一条事件所需的租约：每个订阅者最多 HandlerTimeout，再留出 claimLease 余量
+func (b *Bus) rowLease(t Type) time.Duration {
+	return time.Duration(len(b.subscribers(t)))*b.HandlerTimeout + claimLease
+}
+
+// dispatchRow 投递一条 outbox 记录并更新其状态、重试时间与已投递订阅者
+func (b *Bus) dispatchRow(ctx context.Context, row *model.DomainEvent) {
+	delivered := map[string]bool{}
+	for _, name := range strings.Split(row.Delivered, ",") {
+		if name != "" {
+			delivered[name] = true
+		}
+	}
+	e := Event{
+		ID: row.ID, Type: Type(row.Type), Subject: Subject{FactoryID: row.FactoryID, EquipmentID: row.EquipmentID},
+		OccurredAt: row.CreatedAt, Payload: json.RawMessage(row.Payload),
+	}
+	errs := b.deliver(ctx, e, delivered)
+
+	names := make([]string, 0, len(delivered))
+	for name := range delivered {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	row.Delivered = strings.Join(names, ",")
+	row.Attempts++
+	now := b.now()
+	if len(errs) == 0 {
+		row.Status, row.LastError, row.NextAttemptAt, row.DispatchedAt = "dispatched", "", nil, &now
+		return
+	}
+	row.LastError = strings.Join(errs, "; ")
+	if row.Attempts >= b.MaxAttempts {
+		row.Status, row.NextAttemptAt = "failed", nil
+		log.Printf("[EventBus] Event %d (%s) failed after %d attempts: %s", row.ID, row.Type, row.Attempts, row.LastError)
+		return
+	}
+	next := now.Add(retryDelay(row.Attempts))
+	row.NextAttemptAt = &next
+}
+
+// retryDelay 指数退避：5s、10s、20s…，最长 10 分钟
+func retryDelay(attempts int) time.Duration {
+	d := 5 * time.Second << uint(attempts-1)
+	if d <= 0 || d > 10*time.Minute {
+		return 10 * time.Minute
+	}
+	return d
+}
+
+// deliver 依次调用尚未成功的订阅者，成功者写入 delivered，返回失败信息
+func (b *Bus) deliver(ctx context.Context, e Event, delivered map[string]bool) []string {
+	var errs []string
+	for _, sub := range b.subscribers(e.Type) {
+		if delivered[sub.name] {
+			continue
+		}
+		if err := b.call(ctx, sub, e); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", sub.name, err))
+			log.Printf("[EventBus] Subscriber %s failed on event %d (%s): %v", sub.name, e.ID, e.Type, err)
+			continue
+		}
+		delivered[sub.name] = true
+	}
+	return errs
+}
+
+func (b *Bus) call(ctx context.Context, sub subscriber, e Event) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, b.HandlerTimeout)
+	defer cancel()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return sub.handler(ctx, e)
+}
@@ -0,0 +1,132 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ems/backend/internal/model"
+)
+
+func testBus(now time.Time) *Bus {
+	b := NewBus()
+	b.now = func() time.Time { return now }
+	return b
+}
+
+func TestDispatchRowRetriesOnlyFailedSubscribers(t *testing.T) {
+	now := time.Date(2026, 5, 1, 8, 0, 0, 0, time.UTC)
+	b := testBus(now)
+	calls := map[string]int{}
+	fail := true
+	b.Subscribe("push", func(ctx context.Context, e Event) error {
+		calls["push"]++
+		return nil
+	})
+	b.Subscribe("lark", func(ctx context.Context, e Event) error {
+		calls["lark"]++
+		if fail {
+			return errors.New("bot offline")
+		}
+		return nil
+	}, RepairCreated)
+	b.Subscribe("notes", func(ctx context.Context, e Event) error {
+		calls["notes"]++
+		return nil
+	}, RepairStatusChanged)
+
+	row := &model.DomainEvent{Type: string(RepairCreated), Payload: `{"order_id":1}`, Status: "pending"}
+	b.dispatchRow(context.Background(), row)
+	if row.Status != "pending" || row.Attempts != 1 || row.Delivered != "push" {
+		t.Fatalf("Expected a pending retry with push delivered, got %+v", row)
+	}
+	if row.NextAttemptAt == nil || !row.NextAttemptAt.Equal(now.Add(5*time.Second)) {
+		t.Errorf("Expected next attempt in 5s, got %v", row.NextAttemptAt)
+	}
+	if calls["notes"] != 0 {
+		t.Errorf("Expected subscribers of other types to be skipped, got %d call(s)", calls["notes"])
+	}
+
+	fail = false
+	b.dispatchRow(context.Background(), row)
+	if row.Status != "dispatched" || row.DispatchedAt == nil || row.LastError != "" {
+		t.Fatalf("Expected the event to be dispatched, got %+v", row)
+	}
+	if calls["push"] != 1 || calls["lark"] != 2 {
+		t.Errorf("Expected push once and lark twice, got %v", calls)
+	}
+	if row.Delivered != "lark,push" {
+		t.Errorf("Expected sorted delivered list, got %q", row.Delivered)
+	}
+}
+
+func TestDispatchRowFailsAfterMaxAttempts(t *testing.T) {
+	b := testBus(time.Now())
+	b.MaxAttempts = 2
+	b.Subscribe("broken", func(ctx context.Context, e Event) error { panic("boom") })
+
+	row := &model.DomainEvent{Type: string(MaintenanceOverdue), Payload: `{}`, Status: "pending"}
+	b.dispatchRow(context.Background(), row)
+	if row.Status != "pending" {
+		t.Fatalf("Expected a retry after the first panic, got %s", row.Status)
+	}
+	b.dispatchRow(context.Background(), row)
+	if row.Status != "failed" || row.NextAttemptAt != nil {
+		t.Fatalf("Expected the event to fail after max attempts, got %+v", row)
+	}
+	if row.LastError != "broken: panic: boom" {
+		t.Errorf("Expected the recovered panic as last error, got %q", row.LastError)
+	}
+}
+
+func TestRowLeaseCoversAllSubscribers(t *testing.T) {
+	b := testBus(time.Now())
+	b.HandlerTimeout = 30 * time.Second
+	b.Subscribe("push", func(ctx context.Context, e Event) error { return nil })
+	b.Subscribe("lark", func(ctx context.Context, e Event) error { return nil }, RepairCreated)
+	b.Subscribe("notes", func(ctx context.Context, e Event) error { return nil }, RepairStatusChanged)
+
+	if got, want := b.rowLease(RepairCreated), 2*b.HandlerTimeout+claimLease; got != want {
+		t.Errorf("Expected lease %v for two subscribers, got %v", want, got)
+	}
+	if got := b.rowLease(MaintenanceOverdue); got <= b.HandlerTimeout {
+		t.Errorf("Expected lease longer than one handler timeout, got %v", got)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	cases := map[int]time.Duration{1: 5 * time.Second, 2: 10 * time.Second, 4: 40 * time.Second, 10: 10 * time.Minute, 80: 10 * time.Minute}
+	for attempts, want := range cases {
+		if got := retryDelay(attempts); got != want {
+			t.Errorf("retryDelay(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
+
+func TestPublishWithoutTxDeliversDirectly(t *testing.T) {
+	b := testBus(time.Now())
+	got := make(chan RepairCreatedPayload, 1)
+	b.Subscribe("push", func(ctx context.Context, e Event) error {
+		var p RepairCreatedPayload
+		if err := e.Decode(&p); err != nil {
+			return err
+		}
+		got <- p
+		return nil
+	}, RepairCreated)
+
+	factoryID := uint(3)
+	err := b.Publish(nil, RepairCreatedPayload{Subject: Subject{FactoryID: &factoryID, EquipmentID: 7}, OrderID: 42, Source: "manual"})
+	if err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	select {
+	case p := <-got:
+		if p.OrderID != 42 || p.EquipmentID != 7 || p.FactoryID == nil || *p.FactoryID != 3 {
+			t.Errorf("Unexpected payload %+v", p)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the event to be delivered without a transaction")
+	}
+}
@@ -0,0 +1,189 @@
+// Package event is the in-process domain event bus. Business services record
+// typed events in the same database transaction as the change that caused them
+// (transactional outbox); a dispatcher then delivers them to the registered
+// subscribers such as webhook push, Lark and the agent.
+package event
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Type 领域事件类型
+type Type string
+
+const (
+	RepairCreated             Type = "repair.created"
+	RepairStatusChanged       Type = "repair.status_changed"
+	InspectionNGDetected      Type = "inspection.ng_detected"
+	MaintenanceOverdue        Type = "maintenance.overdue"
+	SparePartBelowSafetyStock Type = "sparepart.below_safety_stock"
+	EquipmentStatusChanged    Type = "equipment.status_changed"
+	EquipmentRULRisk          Type = "equipment.rul_risk"
+	RepairSLABreached         Type = "repair.sla_breached"
+)
+
+// Types lists every domain event type
+var Types = []Type{
+	RepairCreated, RepairStatusChanged, InspectionNGDetected,
+	MaintenanceOverdue, SparePartBelowSafetyStock, EquipmentStatusChanged,
+	EquipmentRULRisk, RepairSLABreached,
+}
+
+// NewPayload returns an empty payload value for t, or nil if t is unknown
+func NewPayload(t Type) Payload {
+	switch t {
+	case RepairCreated:
+		return &RepairCreatedPayload{}
+	case RepairStatusChanged:
+		return &RepairStatusChangedPayload{}
+	case InspectionNGDetected:
+		return &InspectionNGDetectedPayload{}
+	case MaintenanceOverdue:
+		return &MaintenanceOverduePayload{}
+	case SparePartBelowSafetyStock:
+		return &SparePartBelowSafetyStockPayload{}
+	case EquipmentStatusChanged:
+		return &EquipmentStatusChangedPayload{}
+	case EquipmentRULRisk:
+		return &EquipmentRULRiskPayload{}
+	case RepairSLABreached:
+		return &RepairSLABreachedPayload{}
+	}
+	return nil
+}
+
+// Subject 事件所属的工厂与设备，用于订阅者做权限与范围过滤
+type Subject struct {
+	FactoryID   *uint `json:"factory_id,omitempty"`
+	EquipmentID uint  `json:"equipment_id,omitempty"`
+}
+
+func (s Subject) Scope() Subject { return s }
+
+// Payload is implemented by the typed payload of each event type
+type Payload interface {
+	EventType() Type
+	Scope() Subject
+}
+
+// Event 投递给订阅者的事件信封
+type Event struct {
+	ID         uint            `json:"id"` // outbox 记录 ID，内存模式下为 0
+	Type       Type            `json:"type"`
+	Subject                    // 工厂与设备
+	OccurredAt time.Time       `json:"occurred_at"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// Decode 将负载解析为对应的类型化结构体
+func (e Event) Decode(v Payload) error {
+	return json.Unmarshal(e.Payload, v)
+}
+
+// RepairCreatedPayload 新建维修工单（人工报修或点检 NG 自动创建）
+type RepairCreatedPayload struct {
+	Subject
+	OrderID          uint   `json:"order_id"`
+	EquipmentCode    string `json:"equipment_code"`
+	FaultDescription string `json:"fault_description"`
+	FaultCode        string `json:"fault_code"`
+	Priority         int    `json:"priority"`
+	ReporterID       uint   `json:"reporter_id"`
+	Source           string `json:"source"` // manual, inspection_ng
+}
+
+func (RepairCreatedPayload) EventType() Type { return RepairCreated }
+
+// RepairStatusChangedPayload 维修工单状态流转
+type RepairStatusChangedPayload struct {
+	Subject
+	OrderID    uint   `json:"order_id"`
+	Priority   int    `json:"priority"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+	OperatorID uint   `json:"operator_id"`
+	Comment    string `json:"comment,omitempty"`
+}
+
+func (RepairStatusChangedPayload) EventType() Type { return RepairStatusChanged }
+
+// NGItem 点检不合格项
+type NGItem struct {
+	ItemID   uint   `json:"item_id"`
+	Name     string `json:"name"`
+	Remark   string `json:"remark"`
+	PhotoURL string `json:"photo_url,omitempty"`
+}
+
+// InspectionNGDetectedPayload 点检发现不合格项
+type InspectionNGDetectedPayload struct {
+	Subject
+	TaskID        uint     `json:"task_id"`
+	InspectorID   uint     `json:"inspector_id"`
+	Items         []NGItem `json:"items"`
+	RepairOrderID uint     `json:"repair_order_id,omitempty"` // 自动创建的维修单
+}
+
+func (InspectionNGDetectedPayload) EventType() Type { return InspectionNGDetected }
+
+// MaintenanceOverduePayload 保养任务逾期
+type MaintenanceOverduePayload struct {
+	Subject
+	TaskID     uint   `json:"task_id"`
+	PlanID     uint   `json:"plan_id"`
+	PlanName   string `json:"plan_name"`
+	DueDate    string `json:"due_date"`
+	AssignedTo uint   `json:"assigned_to"`
+}
+
+func (MaintenanceOverduePayload) EventType() Type { return MaintenanceOverdue }
+
+// SparePartBelowSafetyStockPayload 备件库存跌破安全库存
+type SparePartBelowSafetyStockPayload struct {
+	Subject
+	SparePartID uint   `json:"spare_part_id"`
+	Code        string `json:"code"`
+	Name        string `json:"name"`
+	Quantity    int    `json:"quantity"`
+	SafetyStock int    `json:"safety_stock"`
+}
+
+func (SparePartBelowSafetyStockPayload) EventType() Type { return SparePartBelowSafetyStock }
+
+// EquipmentStatusChangedPayload 设备状态变化
+type EquipmentStatusChangedPayload struct {
+	Subject
+	EquipmentCode string `json:"equipment_code"`
+	From          string `json:"from"`
+	To            string `json:"to"`
+	Reason        string `json:"reason,omitempty"` // 如 repair_order:12
+}
+
+func (EquipmentStatusChangedPayload) EventType() Type { return EquipmentStatusChanged }
+
+// EquipmentRULRiskPayload 巡检预测设备剩余寿命低于阈值
+type EquipmentRULRiskPayload struct {
+	Subject
+	EquipmentCode    string  `json:"equipment_code"`
+	EquipmentName    string  `json:"equipment_name"`
+	EstimatedRULDays int     `json:"estimated_rul_days"`
+	HealthScore      float64 `json:"health_score"`
+	Recommendation   string  `json:"recommendation,omitempty"`
+}
+
+func (EquipmentRULRiskPayload) EventType() Type { return EquipmentRULRisk }
+
+// RepairSLABreachedPayload 维修工单超出响应或修复时限
+type RepairSLABreachedPayload struct {
+	Subject
+	OrderID       uint      `json:"order_id"`
+	EquipmentCode string    `json:"equipment_code"`
+	Priority      int       `json:"priority"`
+	Status        string    `json:"status"`
+	Stage         string    `json:"stage"` // response: 未开始维修; resolution: 未完成维修
+	Deadline      time.Time `json:"deadline"`
+	AssignedTo    *uint     `json:"assigned_to,omitempty"`
+}
+
+func (RepairSLABreachedPayload) EventType() Type { return RepairSLABreached }
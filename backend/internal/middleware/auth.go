@@ -151,18 +151,29 @@ func GetUserRole(c *gin.Context) (string, bool) {
 }
 
 func checkRateLimit(apiKeyID uint, limit int) bool {
+	return AllowRate(fmt.Sprintf("apikey:%d", apiKeyID), limit, time.Minute)
+}
+
+// AllowRate is a fixed-window limiter shared by all requests using the same bucket,
+// e.g. "apikey:12" or "tool:sql_data_analyst:apikey:12". It fails open when Redis
+// is unavailable.
+func AllowRate(bucket string, limit int, window time.Duration) bool {
 	if redis.Client == nil {
 		return true // Skip if redis is not available
 	}
 
-	key := fmt.Sprintf("ratelimit:apikey:%d:%d", apiKeyID, time.Now().Unix()/60)
+	seconds := int64(window / time.Second)
+	if seconds <= 0 {
+		seconds = 1
+	}
+	key := fmt.Sprintf("ratelimit:%s:%d", bucket, time.Now().Unix()/seconds)
 	count, err := redis.Client.Incr(redis.Ctx, key).Result()
 	if err != nil {
 		return true // Fail open on redis error
 	}
 
 	if count == 1 {
-		redis.Client.Expire(redis.Ctx, key, time.Minute)
+		redis.Client.Expire(redis.Ctx, key, window)
 	}
 
 	return int(count) <= limit
@@ -48,6 +48,7 @@ type User struct {
 	FactoryID          *uint    `json:"factory_id"`
 	Factory            *Factory `json:"factory,omitempty" gorm:"foreignKey:FactoryID"`
 	LarkOpenID         *string  `json:"lark_openid" gorm:"column:lark_openid;size:100;uniqueIndex"`
+	LarkBotAppID       *string  `json:"lark_bot_app_id" gorm:"size:100"` // 绑定 open_id 时所在的飞书应用；open_id 按应用区分
 	LarkAppID          *string  `json:"lark_app_id" gorm:"size:100"`
 	LarkAppSecret      *string  `json:"lark_app_secret" gorm:"size:255"`
 	LarkVerificationToken *string `json:"lark_verification_token" gorm:"size:100"`
@@ -67,6 +68,7 @@ type Factory struct {
 	Base      *Base      `json:"base,omitempty" gorm:"foreignKey:BaseID"`
 	Code      string     `json:"code" gorm:"size:50;uniqueIndex;not null"`
 	Name      string     `json:"name" gorm:"size:100;not null"`
+	ManagerID *uint      `json:"manager_id"` // 厂长，升级链 factory_manager 的接收人
 	Workshops []Workshop `json:"workshops,omitempty" gorm:"foreignKey:FactoryID"`
 }
 
@@ -76,6 +78,7 @@ type Workshop struct {
 	Factory   *Factory    `json:"factory,omitempty" gorm:"foreignKey:FactoryID"`
 	Code      string      `json:"code" gorm:"size:50;uniqueIndex;not null"`
 	Name      string      `json:"name" gorm:"size:100;not null"`
+	SupervisorID *uint    `json:"supervisor_id"` // 车间主管，升级链 workshop_supervisor 的接收人
 	Equipments []Equipment `json:"equipments,omitempty" gorm:"foreignKey:WorkshopID"`
 }
 
@@ -206,6 +209,8 @@ type RepairOrder struct {
 	ConfirmedAt      *time.Time `json:"confirmed_at"`
 	AuditedAt        *time.Time `json:"audited_at"`
 	ClosedAt         *time.Time `json:"closed_at"`
+	ResponseBreachedAt   *time.Time `json:"response_breached_at"`   // 超出响应时限（未开始维修）的时间
+	ResolutionBreachedAt *time.Time `json:"resolution_breached_at"` // 超出修复时限的时间
 	Solution         string     `json:"solution" gorm:"type:text"`
 	Photos           []string   `json:"photos" gorm:"type:text[]"`
 	Logs             []RepairLog `json:"logs,omitempty" gorm:"foreignKey:OrderID"`
@@ -279,6 +284,7 @@ type MaintenanceTask struct {
 	ScheduledDate string           `json:"scheduled_date" gorm:"size:10;not null"`
 	DueDate       string           `json:"due_date" gorm:"size:10"`
 	Status        string           `json:"status" gorm:"size:20;default:'pending'"`
+	AutoGenerated bool             `json:"auto_generated" gorm:"default:false"` // 由保养计划滚动生成，未开始前可随计划重排
 	AssignedTo    uint             `json:"assigned_to" gorm:"not null"`
 	Assignee      *User            `json:"assignee,omitempty" gorm:"foreignKey:AssignedTo"`
 	StartedAt     *time.Time       `json:"started_at"`
@@ -348,9 +354,244 @@ type SparePartTransaction struct {
 	OperatorID  uint           `json:"operator_id" gorm:"not null"`
 	Operator    *User          `json:"operator,omitempty" gorm:"foreignKey:OperatorID"`
 	RelatedID   *uint          `json:"related_id"` // can be order_id or task_id
+	BatchNo     string         `json:"batch_no" gorm:"size:50;index"` // 入库批次号，仅 in 类型记录
 	Remark      string         `json:"remark" gorm:"type:text"`
 }
 
+// SparePartReservation 备件预留：占用可用库存但不出库；释放或到期后归还，按同一工单出库时转为已领用
+type SparePartReservation struct {
+	BaseModel
+	SparePartID       uint       `json:"spare_part_id" gorm:"not null;index:idx_spare_reservation"`
+	SparePart         *SparePart `json:"spare_part,omitempty" gorm:"foreignKey:SparePartID"`
+	FactoryID         uint       `json:"factory_id" gorm:"not null;index:idx_spare_reservation"`
+	Quantity          int        `json:"quantity" gorm:"not null"`
+	RepairOrderID     *uint      `json:"repair_order_id" gorm:"index"`
+	MaintenanceTaskID *uint      `json:"maintenance_task_id" gorm:"index"`
+	Status            string     `json:"status" gorm:"size:20;not null;index"` // active, released, issued
+	ExpiresAt         time.Time  `json:"expires_at" gorm:"index"`              // 到期后不再占用库存
+	ReservedBy        uint       `json:"reserved_by" gorm:"not null"`
+	Remark            string     `json:"remark" gorm:"type:text"`
+}
+
+// 备件预留状态（active 且已过 ExpiresAt 视为已过期）
+const (
+	ReservationActive   = "active"
+	ReservationReleased = "released"
+	ReservationIssued   = "issued"
+)
+
+// =====================================================
+// Domain Event Outbox
+// =====================================================
+
+// DomainEvent 领域事件 outbox：与业务数据在同一事务中写入，由分发器异步投递给订阅者
+type DomainEvent struct {
+	BaseModel
+	Type          string     `json:"type" gorm:"size:50;not null;index"`
+	FactoryID     *uint      `json:"factory_id" gorm:"index"`
+	EquipmentID   uint       `json:"equipment_id" gorm:"index"`
+	Payload       string     `json:"payload" gorm:"type:text"`
+	Status        string     `json:"status" gorm:"size:20;default:pending;index"` // pending, dispatched, failed
+	Attempts      int        `json:"attempts" gorm:"default:0"`
+	Delivered     string     `json:"delivered" gorm:"type:text"` // 已处理成功的订阅者，逗号分隔，重试时跳过
+	LastError     string     `json:"last_error" gorm:"type:text"`
+	NextAttemptAt *time.Time `json:"next_attempt_at" gorm:"index"`
+	DispatchedAt  *time.Time `json:"dispatched_at"`
+}
+
+// LarkAlertCard 发送给单个接收人的飞书提醒卡片，按钮回调时据此重建并原地更新卡片
+type LarkAlertCard struct {
+	BaseModel
+	MessageID      string     `json:"message_id" gorm:"size:100;uniqueIndex;not null"`
+	RecipientID    uint       `json:"recipient_id" gorm:"not null;index"`
+	EventID        uint       `json:"event_id" gorm:"index"`
+	EventType      string     `json:"event_type" gorm:"size:50;not null"`
+	Event          string     `json:"event" gorm:"type:text"` // 事件信封 JSON；outbox 记录会被清理，卡片需自带内容
+	RepairOrderID  *uint      `json:"repair_order_id"`        // 关联或通过卡片创建的维修单
+	AcknowledgedAt *time.Time `json:"acknowledged_at"`
+	AssignedAt     *time.Time `json:"assigned_at"` // 通过"指派给我"接单的时间
+}
+
+// NotificationPreference 用户在某个通知渠道上的接收设置
+type NotificationPreference struct {
+	BaseModel
+	UserID     uint   `json:"user_id" gorm:"not null;uniqueIndex:idx_notification_pref"`
+	Channel    string `json:"channel" gorm:"size:20;not null;uniqueIndex:idx_notification_pref"` // email, wecom, dingtalk, webhook, lark
+	Address    string `json:"address" gorm:"size:500"`                                           // 邮箱或机器人/Webhook 地址，飞书为空（使用绑定账号）
+	Secret     string `json:"-" gorm:"size:255"`                                                 // 钉钉加签密钥或 Webhook 签名密钥
+	Language   string `json:"language" gorm:"size:10"`
+	EventTypes string `json:"event_types" gorm:"size:500"` // 逗号分隔，为空表示全部事件
+	Enabled    bool   `json:"enabled"`
+}
+
+// NotificationTemplate 覆盖内置模板的通知模板，按事件类型与语言唯一
+type NotificationTemplate struct {
+	BaseModel
+	EventType string `json:"event_type" gorm:"size:50;not null;uniqueIndex:idx_notification_tpl"`
+	Language  string `json:"language" gorm:"size:10;not null;uniqueIndex:idx_notification_tpl"`
+	Subject   string `json:"subject" gorm:"size:255;not null"`
+	Body      string `json:"body" gorm:"type:text"`
+}
+
+// NotificationDelivery 各渠道通知的统一投递记录
+type NotificationDelivery struct {
+	BaseModel
+	UserID    uint   `json:"user_id" gorm:"index"`
+	Channel   string `json:"channel" gorm:"size:20;index"`
+	EventType string `json:"event_type" gorm:"size:50"`
+	EventID   uint   `json:"event_id" gorm:"index"`
+	Address   string `json:"address" gorm:"size:500"` // 已脱敏
+	Subject   string `json:"subject" gorm:"size:255"`
+	Status    string `json:"status" gorm:"size:20;index"` // success, failed
+	Error     string `json:"error" gorm:"type:text"`
+	LatencyMs int64  `json:"latency_ms"`
+}
+
+// NotificationQuietHours 用户的免打扰时段，期间非紧急告警延后以摘要发送
+type NotificationQuietHours struct {
+	BaseModel
+	UserID   uint   `json:"user_id" gorm:"not null;uniqueIndex"`
+	Start    string `json:"start" gorm:"size:5"` // HH:MM，End 早于 Start 表示跨夜
+	End      string `json:"end" gorm:"size:5"`
+	Timezone string `json:"timezone" gorm:"size:50"`
+	Enabled  bool   `json:"enabled"`
+}
+
+// 告警状态
+const (
+	AlertFiring       = "firing"
+	AlertAcknowledged = "acknowledged"
+	AlertResolved     = "resolved"
+)
+
+// Alert 按指纹（事件类型 + 设备）聚合的告警，重复事件只累加计数
+type Alert struct {
+	BaseModel
+	Fingerprint    string     `json:"fingerprint" gorm:"size:200;not null;index"`
+	ActiveKey      *string    `json:"-" gorm:"size:200;uniqueIndex"` // 未解决时等于 Fingerprint，保证同一指纹只有一条活动告警
+	EventType      string     `json:"event_type" gorm:"size:50;not null"`
+	FactoryID      *uint      `json:"factory_id" gorm:"index"`
+	EquipmentID    uint       `json:"equipment_id" gorm:"index"`
+	Severity       string     `json:"severity" gorm:"size:20"` // low, medium, high, critical
+	Status         string     `json:"status" gorm:"size:20;index"`
+	Count          int        `json:"count"`
+	LastEventID    uint       `json:"last_event_id"`
+	Event          string     `json:"event" gorm:"type:text"` // 最近一次事件信封 JSON
+	FirstSeenAt    time.Time  `json:"first_seen_at"`
+	LastSeenAt     time.Time  `json:"last_seen_at"`
+	LastNotifiedAt *time.Time `json:"last_notified_at"`
+	AcknowledgedBy *uint      `json:"acknowledged_by"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at"`
+	ResolvedBy     *uint      `json:"resolved_by"` // 为空表示自动解决
+	ResolvedAt     *time.Time `json:"resolved_at"`
+}
+
+// AlertDigestEntry 待合并到摘要中发送的告警
+type AlertDigestEntry struct {
+	BaseModel
+	UserID  uint       `json:"user_id" gorm:"not null;index"`
+	AlertID uint       `json:"alert_id" gorm:"not null;index"`
+	Reason  string     `json:"reason" gorm:"size:20"` // low_severity, quiet_hours
+	SentAt  *time.Time `json:"sent_at" gorm:"index"`
+}
+
+// EscalationPolicy 按工厂与优先级配置的升级链，FactoryID 为空表示所有工厂的默认策略
+type EscalationPolicy struct {
+	BaseModel
+	Name      string `json:"name" gorm:"size:100;not null"`
+	FactoryID *uint  `json:"factory_id" gorm:"index"`
+	Priority  int    `json:"priority" gorm:"not null"` // 1=高 2=中 3=低；告警按严重度折算
+	Steps     string `json:"steps" gorm:"type:text"`   // EscalationStep 数组 JSON
+	Enabled   bool   `json:"enabled"`
+}
+
+// EscalationStep 升级链中的一级：自开始起 AfterMinutes 分钟后通知 Target
+type EscalationStep struct {
+	AfterMinutes int    `json:"after_minutes"`
+	Target       string `json:"target"` // assignee, workshop_supervisor, factory_manager, on_call, role:<role>, user:<id>
+}
+
+// 升级状态
+const (
+	EscalationActive       = "active"
+	EscalationAcknowledged = "acknowledged"
+	EscalationResolved     = "resolved"
+	EscalationExhausted    = "exhausted"
+)
+
+// 升级对象
+const (
+	EscalationSubjectRepairOrder = "repair_order"
+	EscalationSubjectAlert       = "alert"
+)
+
+// Escalation 维修单或告警的一次升级过程，未被确认时按策略逐级通知
+type Escalation struct {
+	BaseModel
+	SubjectType    string     `json:"subject_type" gorm:"size:20;not null;index:idx_escalation_subject"`
+	SubjectID      uint       `json:"subject_id" gorm:"not null;index:idx_escalation_subject"`
+	FactoryID      *uint      `json:"factory_id" gorm:"index"`
+	PolicyID       uint       `json:"policy_id"`
+	Priority       int        `json:"priority"`
+	Status         string     `json:"status" gorm:"size:20;index"`
+	NextStep       int        `json:"next_step"`
+	NextAt         *time.Time `json:"next_at" gorm:"index"`
+	StartedAt      time.Time  `json:"started_at"`
+	AcknowledgedBy *uint      `json:"acknowledged_by"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at"`
+	Logs           []EscalationLog `json:"logs,omitempty" gorm:"foreignKey:EscalationID"`
+}
+
+// EscalationLog 升级历史：每一级的通知对象与确认、结束记录
+type EscalationLog struct {
+	BaseModel
+	EscalationID uint   `json:"escalation_id" gorm:"not null;index"`
+	Step         int    `json:"step"`
+	Target       string `json:"target" gorm:"size:50"`
+	Action       string `json:"action" gorm:"size:20"`    // notified, skipped, acknowledged, resolved, exhausted
+	UserIDs      string `json:"user_ids" gorm:"size:255"` // 逗号分隔的通知对象
+	ActorID      *uint  `json:"actor_id"`
+	Note         string `json:"note" gorm:"type:text"`
+}
+
+// OnCallShift 值班安排；升级链按角色通知时优先通知当前值班人
+type OnCallShift struct {
+	BaseModel
+	FactoryID *uint     `json:"factory_id" gorm:"index"`
+	UserID    uint      `json:"user_id" gorm:"not null;index"`
+	User      *User     `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	StartAt   time.Time `json:"start_at" gorm:"not null;index"`
+	EndAt     time.Time `json:"end_at" gorm:"not null;index"`
+	Note      string    `json:"note" gorm:"size:255"`
+}
+
+// ScheduledJob 内置定时任务的调度状态，多副本共享
+type ScheduledJob struct {
+	BaseModel
+	Name           string     `json:"name" gorm:"size:100;uniqueIndex;not null"`
+	Cron           string     `json:"cron" gorm:"size:100"`
+	Paused         bool       `json:"paused"`
+	NextRunAt      *time.Time `json:"next_run_at"`
+	LastRunAt      *time.Time `json:"last_run_at"`
+	LastStatus     string     `json:"last_status" gorm:"size:20"`
+	LastError      string     `json:"last_error" gorm:"type:text"`
+	LastDurationMs int64      `json:"last_duration_ms"`
+}
+
+// JobRun 定时任务的一次执行记录
+type JobRun struct {
+	ID         uint      `json:"id" gorm:"primarykey"`
+	JobName    string    `json:"job_name" gorm:"size:100;not null;index"`
+	Trigger    string    `json:"trigger" gorm:"size:20"` // schedule, misfire, manual
+	Status     string    `json:"status" gorm:"size:20"`  // success, failed, skipped
+	Instance   string    `json:"instance" gorm:"size:100"`
+	StartedAt  time.Time `json:"started_at" gorm:"index"`
+	FinishedAt time.Time `json:"finished_at"`
+	DurationMs int64     `json:"duration_ms"`
+	Result     string    `json:"result" gorm:"type:text"`
+	Error      string    `json:"error" gorm:"type:text"`
+}
+
 // =====================================================
 // Knowledge & Document Models
 // =====================================================
@@ -448,7 +689,7 @@ type AgentConversation struct {
 	UserID    uint           `json:"user_id" gorm:"not null;index"`
 	User      *User          `json:"user,omitempty" gorm:"foreignKey:UserID"`
 	Title     string         `json:"title" gorm:"size:200"`
-	Status    string         `json:"status" gorm:"size:20;default:'active'"`
+	Status    string         `json:"status" gorm:"size:20;default:'active'"` // active, archived
 	Messages  []AgentMessage `json:"messages,omitempty" gorm:"foreignKey:ConversationID"`
 }
 
@@ -472,20 +713,107 @@ type AgentPushSubscription struct {
 	Enabled    bool   `json:"enabled" gorm:"default:true"`
 	Scope      string `json:"scope" gorm:"type:text"`
 	WebhookURL string `json:"webhook_url" gorm:"size:500"`
-	Secret     string `json:"secret" gorm:"size:100"` // Signing secret
+	Secret     string `json:"-" gorm:"size:100"` // Signing secret，仅在创建与轮换时返回一次
+	// 轮换后旧密钥在宽限期内继续签名，便于接收方平滑切换
+	PreviousSecret      string     `json:"-" gorm:"size:100"`
+	SecretRotatedAt     *time.Time `json:"secret_rotated_at"`
+	ConsecutiveFailures int        `json:"consecutive_failures" gorm:"default:0"`
+	DisabledAt          *time.Time `json:"disabled_at"` // 连续失败过多被自动停用
+	DisabledReason      string     `json:"disabled_reason" gorm:"size:255"`
 }
 
+// AgentPushLog 一次 Webhook 投递，同时作为持久化投递队列（status=pending 且到期即重试）
 type AgentPushLog struct {
 	BaseModel
-	SubscriptionID uint      `json:"subscription_id" gorm:"not null;index"`
-	ArtifactID     uint      `json:"artifact_id" gorm:"index"`
-	Payload        string    `json:"payload" gorm:"type:text"`
-	Status         string    `json:"status" gorm:"size:20"` // success, failed
-	RetryCount     int       `json:"retry_count" gorm:"default:0"`
-	ErrorMessage   string    `json:"error_message" gorm:"type:text"`
+	SubscriptionID uint       `json:"subscription_id" gorm:"not null;index"`
+	ArtifactID     uint       `json:"artifact_id" gorm:"index"`
+	EventType      string     `json:"event_type" gorm:"size:50"`
+	Payload        string     `json:"payload" gorm:"type:text"`
+	Status         string     `json:"status" gorm:"size:20;index"` // pending, sending, success, failed (ping), dead
+	RetryCount     int        `json:"retry_count" gorm:"default:0"`
+	RedeliveryOf   *uint      `json:"redelivery_of"` // 手动重投时指向原投递
+	NextAttemptAt  *time.Time `json:"next_attempt_at" gorm:"index"`
+	ResponseCode   int        `json:"response_code"`
+	LatencyMs      int64      `json:"latency_ms"`
+	ErrorMessage   string     `json:"error_message" gorm:"type:text"`
 	DeliveredAt    *time.Time `json:"delivered_at"`
 }
 
+// AgentPushDeadLetter 重试耗尽或不可重试的投递，保留负载以便排查与人工重投
+type AgentPushDeadLetter struct {
+	BaseModel
+	SubscriptionID   uint   `json:"subscription_id" gorm:"not null;index"`
+	PushLogID        uint   `json:"push_log_id" gorm:"uniqueIndex"`
+	EventType        string `json:"event_type" gorm:"size:50"`
+	Payload          string `json:"payload" gorm:"type:text"`
+	Attempts         int    `json:"attempts"`
+	LastResponseCode int    `json:"last_response_code"`
+	LastError        string `json:"last_error" gorm:"type:text"`
+	Reason           string `json:"reason" gorm:"size:30"` // max_attempts, non_retryable, subscription_disabled
+}
+
+// AgentFeedback 用户对智能体回答（对话消息或分析产物）的评价与纠正
+type AgentFeedback struct {
+	BaseModel
+	UserID       uint       `json:"user_id" gorm:"not null;index"`
+	FactoryID    *uint      `json:"factory_id" gorm:"index"`
+	TargetType   string     `json:"target_type" gorm:"size:20;not null;index:idx_agent_feedback_target"` // message, artifact
+	TargetID     uint       `json:"target_id" gorm:"not null;index:idx_agent_feedback_target"`
+	Rating       int        `json:"rating"` // 1 赞 / -1 踩
+	Correction   string     `json:"correction" gorm:"type:text"`
+	Scenario     string     `json:"scenario" gorm:"size:100;index"`
+	SkillID      string     `json:"skill_id" gorm:"size:100;index"`
+	Model        string     `json:"model" gorm:"size:100"`
+	KnowledgeIDs string     `json:"knowledge_ids" gorm:"type:text"`
+	ReviewStatus string     `json:"review_status" gorm:"size:20;index"` // 负面评价进入复核：pending, resolved, dismissed
+	ReviewedBy   *uint      `json:"reviewed_by"`
+	ReviewedAt   *time.Time `json:"reviewed_at"`
+	ReviewNote   string     `json:"review_note" gorm:"type:text"`
+}
+
+// AgentConversationShare 对话只读分享链接，仅同工厂用户可访问
+type AgentConversationShare struct {
+	BaseModel
+	ConversationID uint       `json:"conversation_id" gorm:"not null;index"`
+	Token          string     `json:"token" gorm:"size:64;uniqueIndex;not null"`
+	CreatedBy      uint       `json:"created_by" gorm:"not null"`
+	FactoryID      *uint      `json:"factory_id" gorm:"index"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+	RevokedAt      *time.Time `json:"revoked_at"`
+}
+
+// AgentEquipmentNote 设备长期备注：跨对话保留的单台设备知识，审核通过且未过期时注入 Agent 上下文
+type AgentEquipmentNote struct {
+	BaseModel
+	EquipmentID uint       `json:"equipment_id" gorm:"not null;index"`
+	FactoryID   *uint      `json:"factory_id" gorm:"index"`
+	Category    string     `json:"category" gorm:"size:30"` // modification, known_issue, operation, other
+	Content     string     `json:"content" gorm:"type:text;not null"`
+	SourceType  string     `json:"source_type" gorm:"size:20;not null;index:idx_agent_equipment_note_source"` // manual, conversation, repair_order
+	SourceID    uint       `json:"source_id" gorm:"index:idx_agent_equipment_note_source"`                    // 对话或维修单 ID，手工录入为 0
+	CreatedBy   uint       `json:"created_by"`                                                               // 自动提取时为对话所属用户，维修单为 0
+	Status      string     `json:"status" gorm:"size:20;default:'pending';index"`                            // pending, approved, rejected
+	ReviewedBy  *uint      `json:"reviewed_by"`
+	ReviewedAt  *time.Time `json:"reviewed_at"`
+	ReviewNote  string     `json:"review_note" gorm:"type:text"`
+	ExpiresAt   *time.Time `json:"expires_at"` // 为空表示长期有效
+}
+
+// AgentBriefing 定时简报定义（按工厂与角色推送晨报/周报）
+type AgentBriefing struct {
+	BaseModel
+	Name      string     `json:"name" gorm:"size:100;not null"`
+	Cron      string     `json:"cron" gorm:"size:50;not null"`     // 5 段 cron 表达式，如 "0 8 * * *"
+	Period    string     `json:"period" gorm:"size:10;default:'daily'"` // daily, weekly（决定统计窗口）
+	Audience  string     `json:"audience" gorm:"size:20;not null"` // supervisor, technician
+	FactoryID *uint      `json:"factory_id" gorm:"index"`          // 为空表示所有工厂
+	Template  string     `json:"template" gorm:"type:text"`        // text/template 模板，为空使用默认模板
+	Enabled   bool       `json:"enabled" gorm:"not null"`  // 不设默认值：带 default 标签时 false 会被 Create 忽略
+	LastRunAt *time.Time `json:"last_run_at"`
+	NextRunAt *time.Time `json:"next_run_at" gorm:"index"`
+	CreatedBy uint       `json:"created_by"`
+}
+
 type AgentUsage struct {
 	BaseModel
 	SessionID      uint      `json:"session_id" gorm:"not null;index"`
@@ -518,6 +846,7 @@ type AgentArtifact struct {
 	Summary       string               `json:"summary" gorm:"type:text"`
 	ResultJSON    string               `json:"result_json" gorm:"type:text"`
 	RiskLevel     string               `json:"risk_level" gorm:"size:20"`
+	KnowledgeIDs  *string              `json:"knowledge_ids" gorm:"type:text"` // 生成时引用的已确认知识，评价时回写置信度
 	EvidenceLinks []AgentEvidenceLink `json:"evidence_links,omitempty" gorm:"foreignKey:ArtifactID"`
 }
 
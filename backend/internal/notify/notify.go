@@ -0,0 +1,142 @@
+// Package notify delivers rendered notifications to people through pluggable
+// channels (email, WeCom and DingTalk robots, generic webhooks, Lark). Callers
+// pick channels from each user's preferences, render a per-event-type and
+// per-language template into a Message and hand it to Channel.Send.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ems/backend/internal/agent/webhook"
+	"github.com/ems/backend/internal/event"
+)
+
+// 渠道名称
+const (
+	ChannelEmail    = "email"
+	ChannelWeCom    = "wecom"
+	ChannelDingTalk = "dingtalk"
+	ChannelWebhook  = "webhook"
+	ChannelLark     = "lark"
+)
+
+var (
+	ErrNotConfigured  = errors.New("notification channel is not configured")
+	ErrInvalidAddress = errors.New("invalid notification address")
+)
+
+const defaultTimeout = 10 * time.Second
+
+// Message 已渲染的通知内容
+type Message struct {
+	Subject   string
+	Body      string
+	EventType string
+	Event     *event.Event // 原始事件，可原生渲染的渠道（如飞书卡片）使用
+}
+
+// Destination 接收地址：邮箱、机器人 Webhook 地址等，Secret 为机器人加签密钥
+type Destination struct {
+	UserID  uint
+	Address string
+	Secret  string
+}
+
+// Channel 通知渠道
+type Channel interface {
+	Name() string
+	// Validate 在保存用户偏好时校验地址
+	Validate(dest Destination) error
+	Send(ctx context.Context, dest Destination, msg Message) error
+}
+
+func validateHTTPURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: must be an absolute http(s) URL", ErrInvalidAddress)
+	}
+	return nil
+}
+
+// robotResponse 企业微信与钉钉机器人的通用响应
+type robotResponse struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+// postJSON 发送 JSON 请求；非 2xx 视为失败，robot 为 true 时还会校验 errcode
+func postJSON(ctx context.Context, client *http.Client, target string, payload interface{}, headers map[string]string, robot bool) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	// 响应内容不写入错误：错误会进入用户可见的投递日志
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	if !robot {
+		return nil
+	}
+	var result robotResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return fmt.Errorf("decode robot response: %w", err)
+	}
+	if result.ErrCode != 0 {
+		return fmt.Errorf("robot error: %s (errcode: %d)", result.ErrMsg, result.ErrCode)
+	}
+	return nil
+}
+
+// httpClient 未指定客户端时使用拒绝回环、链路本地与内网地址的客户端（与 Agent Webhook 推送相同的规则）
+func httpClient(c *http.Client, allowPrivate bool) *http.Client {
+	if c != nil {
+		return c
+	}
+	if allowPrivate {
+		return &http.Client{Timeout: defaultTimeout}
+	}
+	return webhook.GuardedClient(defaultTimeout)
+}
+
+// checkTarget 解析目标主机，拒绝指向受限地址的机器人与 Webhook 地址
+func checkTarget(ctx context.Context, raw string, allowPrivate bool) error {
+	if allowPrivate {
+		return nil
+	}
+	if err := webhook.CheckURL(ctx, raw); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidAddress, err)
+	}
+	return nil
+}
+
+// validateTargetURL 保存偏好时校验地址格式与解析结果
+func validateTargetURL(raw string, allowPrivate bool) error {
+	if err := validateHTTPURL(raw); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return checkTarget(ctx, raw, allowPrivate)
+}
@@ -0,0 +1,227 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ems/backend/internal/agent/webhook"
+	"github.com/ems/backend/internal/event"
+)
+
+// fakeSMTP 最小 SMTP 服务端，记录收件人与 DATA 内容
+type fakeSMTP struct {
+	addr string
+	rcpt chan string
+	data chan string
+}
+
+func startFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	s := &fakeSMTP{addr: ln.Addr().String(), rcpt: make(chan string, 1), data: make(chan string, 1)}
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "RCPT TO:"):
+				s.rcpt <- strings.TrimSpace(line[len("RCPT TO:"):])
+				reply("250 OK")
+			case cmd == "DATA":
+				reply("354 End data with <CR><LF>.<CR><LF>")
+				var b strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil || l == ".\r\n" {
+						break
+					}
+					b.WriteString(l)
+				}
+				s.data <- b.String()
+				reply("250 OK")
+			case cmd == "QUIT":
+				reply("221 Bye")
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+	return s
+}
+
+func TestSMTPChannelSendsMail(t *testing.T) {
+	srv := startFakeSMTP(t)
+	host, port, _ := net.SplitHostPort(srv.addr)
+	p, _ := strconv.Atoi(port)
+	ch := &SMTPChannel{Host: host, Port: p, From: "EMS <ems@example.com>"}
+
+	msg := Message{Subject: "维修超时", Body: "维修单 #9 已超出响应时限", EventType: "repair.sla_breached"}
+	if err := ch.Send(context.Background(), Destination{Address: "alice@example.com"}, msg); err != nil {
+		t.Fatal(err)
+	}
+	if rcpt := <-srv.rcpt; rcpt != "<alice@example.com>" {
+		t.Errorf("Expected recipient alice, got %s", rcpt)
+	}
+	data := <-srv.data
+	if !strings.Contains(data, "Subject: =?UTF-8?b?") || !strings.Contains(data, "X-EMS-Event: repair.sla_breached") {
+		t.Errorf("Expected encoded subject and event header: %s", data)
+	}
+	body := data[strings.Index(data, "\r\n\r\n")+4:]
+	decoded, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(body, "\r\n", ""))
+	if err != nil || string(decoded) != msg.Body {
+		t.Errorf("Expected base64 body %q, got %q (%v)", msg.Body, decoded, err)
+	}
+}
+
+func TestSMTPChannelValidation(t *testing.T) {
+	ch := &SMTPChannel{}
+	if err := ch.Validate(Destination{Address: "not-an-email"}); err == nil {
+		t.Errorf("Expected invalid address to be rejected")
+	}
+	if err := ch.Send(context.Background(), Destination{Address: "a@example.com"}, Message{}); err != ErrNotConfigured {
+		t.Errorf("Expected ErrNotConfigured without a host, got %v", err)
+	}
+}
+
+func TestDingTalkChannelSignsRequest(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	var query url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+	}))
+	defer srv.Close()
+
+	ch := DingTalkChannel{now: func() time.Time { return now }, AllowPrivateNetworks: true} // 测试接收端监听在回环地址
+	if err := ch.Send(context.Background(), Destination{Address: srv.URL + "/robot/send?access_token=t", Secret: "SEC"}, Message{Subject: "s", Body: "b"}); err != nil {
+		t.Fatal(err)
+	}
+	mac := hmac.New(sha256.New, []byte("SEC"))
+	mac.Write([]byte("1700000000000\nSEC"))
+	if query.Get("timestamp") != "1700000000000" || query.Get("access_token") != "t" {
+		t.Errorf("Expected timestamp and token in query, got %v", query)
+	}
+	if want := base64.StdEncoding.EncodeToString(mac.Sum(nil)); query.Get("sign") != want {
+		t.Errorf("Expected sign %s, got %s", want, query.Get("sign"))
+	}
+}
+
+func TestRobotErrorCodeFails(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"errcode":93000,"errmsg":"invalid webhook url"}`))
+	}))
+	defer srv.Close()
+
+	err := WeComChannel{AllowPrivateNetworks: true}.Send(context.Background(), Destination{Address: srv.URL}, Message{Subject: "s"})
+	if err == nil || !strings.Contains(err.Error(), "93000") {
+		t.Errorf("Expected robot errcode to fail the send, got %v", err)
+	}
+	if err := (WeComChannel{}).Validate(Destination{Address: "ftp://example.com"}); err == nil {
+		t.Errorf("Expected non-http address to be rejected")
+	}
+}
+
+func TestWebhookChannelSignsBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := webhook.Verify("s3cret", r.Header.Get(webhook.HeaderSignature), r.Header.Get(webhook.HeaderTimestamp), body, time.Minute, time.Now()); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var p webhookPayload
+		if json.Unmarshal(body, &p) != nil || p.EventType != "repair.created" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	msg := Message{Subject: "s", Body: "b", EventType: "repair.created"}
+	if err := (WebhookChannel{AllowPrivateNetworks: true}).Send(context.Background(), Destination{Address: srv.URL, Secret: "s3cret"}, msg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChannelsRejectPrivateAddresses(t *testing.T) {
+	var hits int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("internal secret"))
+	}))
+	defer srv.Close()
+
+	for _, ch := range []Channel{WeComChannel{}, DingTalkChannel{}, WebhookChannel{}} {
+		if err := ch.Validate(Destination{Address: srv.URL}); !errors.Is(err, ErrInvalidAddress) {
+			t.Errorf("%s: expected loopback address to be rejected on save, got %v", ch.Name(), err)
+		}
+		if err := ch.Validate(Destination{Address: "http://169.254.169.254/latest/meta-data"}); !errors.Is(err, ErrInvalidAddress) {
+			t.Errorf("%s: expected metadata address to be rejected on save, got %v", ch.Name(), err)
+		}
+		if err := ch.Send(context.Background(), Destination{Address: srv.URL}, Message{Subject: "s"}); err == nil {
+			t.Errorf("%s: expected loopback address to be rejected on send", ch.Name())
+		}
+	}
+	if hits != 0 {
+		t.Errorf("Expected no request to reach the loopback server, got %d", hits)
+	}
+
+	// 响应内容不应出现在错误（投递日志）中
+	err := WebhookChannel{AllowPrivateNetworks: true}.Send(context.Background(), Destination{Address: srv.URL}, Message{Subject: "s"})
+	if err == nil || strings.Contains(err.Error(), "internal secret") {
+		t.Errorf("Expected status-only error, got %v", err)
+	}
+}
+
+func TestTemplateRenderAndFallback(t *testing.T) {
+	body, _ := json.Marshal(event.SparePartBelowSafetyStockPayload{Code: "BRG-01", Name: "轴承", Quantity: 2, SafetyStock: 5})
+	e := event.Event{Type: event.SparePartBelowSafetyStock, Payload: body}
+
+	msg, err := Builtin(e.Type, LanguageEN).Render(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Subject != "[EMS] Low stock: BRG-01" || !strings.Contains(msg.Body, "below the safety stock of 5") {
+		t.Errorf("Unexpected english rendering: %+v", msg)
+	}
+	if msg, _ := Builtin(e.Type, "fr-FR").Render(e); !strings.HasPrefix(msg.Subject, "【EMS】") {
+		t.Errorf("Expected unsupported language to fall back to %s, got %s", DefaultLanguage, msg.Subject)
+	}
+	if msg, _ := Builtin("custom.event", LanguageEN).Render(event.Event{Type: "custom.event"}); msg.Subject != "[EMS] custom.event" {
+		t.Errorf("Expected generic template for unknown event, got %s", msg.Subject)
+	}
+	if err := (Template{Subject: "{{.Data.code"}).Validate(); err == nil {
+		t.Errorf("Expected malformed template to be rejected")
+	}
+}
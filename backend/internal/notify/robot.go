@@ -0,0 +1,133 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/ems/backend/internal/agent/webhook"
+)
+
+// WeComChannel 企业微信群机器人，地址为机器人 Webhook（含 key）
+type WeComChannel struct {
+	Client *http.Client
+	// AllowPrivateNetworks 为 true 时允许回环与内网地址，仅用于测试
+	AllowPrivateNetworks bool
+}
+
+func (WeComChannel) Name() string { return ChannelWeCom }
+
+func (c WeComChannel) Validate(dest Destination) error {
+	return validateTargetURL(dest.Address, c.AllowPrivateNetworks)
+}
+
+func (c WeComChannel) Send(ctx context.Context, dest Destination, msg Message) error {
+	if err := checkTarget(ctx, dest.Address, c.AllowPrivateNetworks); err != nil {
+		return err
+	}
+	return postJSON(ctx, httpClient(c.Client, c.AllowPrivateNetworks), dest.Address, map[string]interface{}{
+		"msgtype":  "markdown",
+		"markdown": map[string]string{"content": "**" + msg.Subject + "**\n" + msg.Body},
+	}, nil, true)
+}
+
+// DingTalkChannel 钉钉群机器人；配置了加签密钥时按钉钉规则在地址上附加 timestamp 与 sign
+type DingTalkChannel struct {
+	Client *http.Client
+	// AllowPrivateNetworks 为 true 时允许回环与内网地址，仅用于测试
+	AllowPrivateNetworks bool
+	now                  func() time.Time
+}
+
+func (DingTalkChannel) Name() string { return ChannelDingTalk }
+
+func (c DingTalkChannel) Validate(dest Destination) error {
+	return validateTargetURL(dest.Address, c.AllowPrivateNetworks)
+}
+
+func (c DingTalkChannel) Send(ctx context.Context, dest Destination, msg Message) error {
+	if err := checkTarget(ctx, dest.Address, c.AllowPrivateNetworks); err != nil {
+		return err
+	}
+	target := dest.Address
+	if dest.Secret != "" {
+		now := time.Now
+		if c.now != nil {
+			now = c.now
+		}
+		signed, err := dingTalkSignedURL(target, dest.Secret, now())
+		if err != nil {
+			return err
+		}
+		target = signed
+	}
+	return postJSON(ctx, httpClient(c.Client, c.AllowPrivateNetworks), target, map[string]interface{}{
+		"msgtype":  "markdown",
+		"markdown": map[string]string{"title": msg.Subject, "text": "### " + msg.Subject + "\n\n" + msg.Body},
+	}, nil, true)
+}
+
+// dingTalkSignedURL sign = base64(HMAC-SHA256(secret, timestamp + "\n" + secret))，timestamp 为毫秒
+func dingTalkSignedURL(raw, secret string, now time.Time) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	ts := strconv.FormatInt(now.UnixMilli(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "\n" + secret))
+	q := u.Query()
+	q.Set("timestamp", ts)
+	q.Set("sign", base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// WebhookChannel 通用 Webhook：POST JSON，配置密钥时按 Agent 推送相同的规则签名
+type WebhookChannel struct {
+	Client *http.Client
+	// AllowPrivateNetworks 为 true 时允许回环与内网地址，仅用于测试
+	AllowPrivateNetworks bool
+}
+
+func (WebhookChannel) Name() string { return ChannelWebhook }
+
+func (c WebhookChannel) Validate(dest Destination) error {
+	return validateTargetURL(dest.Address, c.AllowPrivateNetworks)
+}
+
+// webhookPayload 通用 Webhook 负载
+type webhookPayload struct {
+	EventType string      `json:"event_type"`
+	Subject   string      `json:"subject"`
+	Body      string      `json:"body"`
+	Event     interface{} `json:"event,omitempty"`
+	SentAt    time.Time   `json:"sent_at"`
+}
+
+func (c WebhookChannel) Send(ctx context.Context, dest Destination, msg Message) error {
+	if err := checkTarget(ctx, dest.Address, c.AllowPrivateNetworks); err != nil {
+		return err
+	}
+	payload := webhookPayload{EventType: msg.EventType, Subject: msg.Subject, Body: msg.Body, SentAt: time.Now()}
+	if msg.Event != nil {
+		payload.Event = msg.Event
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	headers := map[string]string{webhook.HeaderEvent: msg.EventType}
+	if dest.Secret != "" {
+		ts := time.Now().Unix()
+		headers[webhook.HeaderTimestamp] = strconv.FormatInt(ts, 10)
+		headers[webhook.HeaderSignature] = webhook.SignatureHeader(ts, body, dest.Secret)
+	}
+	return postJSON(ctx, httpClient(c.Client, c.AllowPrivateNetworks), dest.Address, json.RawMessage(body), headers, false)
+}
@@ -0,0 +1,151 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+
+	"github.com/ems/backend/pkg/config"
+)
+
+// SMTPChannel 邮件通知；服务器支持时使用 STARTTLS，ImplicitTLS 用于 465 端口
+type SMTPChannel struct {
+	Host        string
+	Port        int
+	Username    string
+	Password    string
+	From        string
+	ImplicitTLS bool
+	Timeout     time.Duration
+}
+
+func NewSMTPChannel(cfg config.SMTPConfig) *SMTPChannel {
+	return &SMTPChannel{
+		Host: cfg.Host, Port: cfg.Port, Username: cfg.Username, Password: cfg.Password,
+		From: cfg.From, ImplicitTLS: cfg.ImplicitTLS,
+	}
+}
+
+func (c *SMTPChannel) Name() string { return ChannelEmail }
+
+func (c *SMTPChannel) Validate(dest Destination) error {
+	if _, err := mail.ParseAddress(dest.Address); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidAddress, err)
+	}
+	return nil
+}
+
+func (c *SMTPChannel) Send(ctx context.Context, dest Destination, msg Message) error {
+	if c.Host == "" || c.From == "" {
+		return ErrNotConfigured
+	}
+	from, err := mail.ParseAddress(c.From)
+	if err != nil {
+		return fmt.Errorf("invalid smtp from address: %w", err)
+	}
+	to, err := mail.ParseAddress(dest.Address)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidAddress, err)
+	}
+
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return err
+	}
+	client, err := smtp.NewClient(conn, c.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if !c.ImplicitTLS {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(&tls.Config{ServerName: c.Host}); err != nil {
+				return err
+			}
+		}
+	}
+	if c.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", c.Username, c.Password, c.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to.Address); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(buildMail(from, to, msg, time.Now())); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func (c *SMTPChannel) dial(ctx context.Context) (net.Conn, error) {
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+	port := c.Port
+	if port == 0 {
+		port = 587
+	}
+	addr := net.JoinHostPort(c.Host, strconv.Itoa(port))
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	var err error
+	if c.ImplicitTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: c.Host})
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+	return conn, nil
+}
+
+// buildMail 生成 UTF-8 纯文本邮件，正文 base64 编码
+func buildMail(from, to *mail.Address, msg Message, now time.Time) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from.String())
+	fmt.Fprintf(&b, "To: %s\r\n", to.String())
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: base64\r\n")
+	if msg.EventType != "" {
+		fmt.Fprintf(&b, "X-EMS-Event: %s\r\n", msg.EventType)
+	}
+	b.WriteString("\r\n")
+	encoded := base64.StdEncoding.EncodeToString([]byte(msg.Body))
+	for len(encoded) > 76 {
+		b.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	b.WriteString(encoded + "\r\n")
+	return b.Bytes()
+}
@@ -0,0 +1,180 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/ems/backend/internal/event"
+)
+
+// 支持的模板语言
+const (
+	LanguageZH      = "zh-CN"
+	LanguageEN      = "en-US"
+	DefaultLanguage = LanguageZH
+)
+
+// Languages 支持的模板语言
+var Languages = []string{LanguageZH, LanguageEN}
+
+// Template 通知模板，Subject 与 Body 均为 text/template 语法。
+// 模板数据：.Type 事件类型，.Event 事件信封，.Data 事件负载（JSON 字段名）
+type Template struct {
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// templateData 渲染模板时的数据
+type templateData struct {
+	Type  string
+	Event event.Event
+	Data  map[string]interface{}
+}
+
+// ValidLanguage 是否为支持的模板语言
+func ValidLanguage(lang string) bool {
+	for _, l := range Languages {
+		if l == lang {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate 校验模板语法
+func (t Template) Validate() error {
+	for _, text := range []string{t.Subject, t.Body} {
+		if _, err := template.New("").Option("missingkey=zero").Parse(text); err != nil {
+			return fmt.Errorf("invalid template: %w", err)
+		}
+	}
+	if strings.TrimSpace(t.Subject) == "" {
+		return fmt.Errorf("invalid template: subject is required")
+	}
+	return nil
+}
+
+// Render 渲染事件为通知消息
+func (t Template) Render(e event.Event) (Message, error) {
+	data := templateData{Type: string(e.Type), Event: e}
+	if len(e.Payload) > 0 {
+		if err := json.Unmarshal(e.Payload, &data.Data); err != nil {
+			return Message{}, err
+		}
+	}
+	subject, err := execute(t.Subject, data)
+	if err != nil {
+		return Message{}, err
+	}
+	body, err := execute(t.Body, data)
+	if err != nil {
+		return Message{}, err
+	}
+	ev := e
+	return Message{Subject: strings.TrimSpace(subject), Body: strings.TrimSpace(body), EventType: string(e.Type), Event: &ev}, nil
+}
+
+func execute(text string, data templateData) (string, error) {
+	tpl, err := template.New("").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	if err := tpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// builtin 内置模板，按事件类型与语言索引
+var builtin = map[event.Type]map[string]Template{
+	event.RepairCreated: {
+		LanguageZH: {"【EMS】新维修单 #{{.Data.order_id}}", "设备 {{.Data.equipment_code}} 新建维修单 #{{.Data.order_id}}（优先级 {{.Data.priority}}）\n{{.Data.fault_description}}"},
+		LanguageEN: {"[EMS] New repair order #{{.Data.order_id}}", "Repair order #{{.Data.order_id}} was created for equipment {{.Data.equipment_code}} (priority {{.Data.priority}}).\n{{.Data.fault_description}}"},
+	},
+	event.RepairStatusChanged: {
+		LanguageZH: {"【EMS】维修单 #{{.Data.order_id}} 状态变更", "维修单 #{{.Data.order_id}} 状态由 {{.Data.from}} 变为 {{.Data.to}}{{with .Data.comment}}\n备注：{{.}}{{end}}"},
+		LanguageEN: {"[EMS] Repair order #{{.Data.order_id}} status changed", "Repair order #{{.Data.order_id}} moved from {{.Data.from}} to {{.Data.to}}.{{with .Data.comment}}\nComment: {{.}}{{end}}"},
+	},
+	event.InspectionNGDetected: {
+		LanguageZH: {"【EMS】点检异常", "点检任务 #{{.Data.task_id}} 发现 {{len .Data.items}} 项不合格{{range .Data.items}}\n- {{.name}}: {{.remark}}{{end}}"},
+		LanguageEN: {"[EMS] Inspection failed", "Inspection task #{{.Data.task_id}} found {{len .Data.items}} failed item(s).{{range .Data.items}}\n- {{.name}}: {{.remark}}{{end}}"},
+	},
+	event.MaintenanceOverdue: {
+		LanguageZH: {"【EMS】保养逾期", "保养任务 #{{.Data.task_id}}（{{.Data.plan_name}}）已超过截止日期 {{.Data.due_date}}"},
+		LanguageEN: {"[EMS] Maintenance overdue", "Maintenance task #{{.Data.task_id}} ({{.Data.plan_name}}) is past its due date {{.Data.due_date}}."},
+	},
+	event.SparePartBelowSafetyStock: {
+		LanguageZH: {"【EMS】低库存：{{.Data.code}}", "备件 {{.Data.code}} {{.Data.name}} 库存 {{.Data.quantity}}，低于安全库存 {{.Data.safety_stock}}"},
+		LanguageEN: {"[EMS] Low stock: {{.Data.code}}", "Spare part {{.Data.code}} {{.Data.name}} has {{.Data.quantity}} in stock, below the safety stock of {{.Data.safety_stock}}."},
+	},
+	event.EquipmentStatusChanged: {
+		LanguageZH: {"【EMS】设备 {{.Data.equipment_code}} 状态变更", "设备 {{.Data.equipment_code}} 状态由 {{.Data.from}} 变为 {{.Data.to}}"},
+		LanguageEN: {"[EMS] Equipment {{.Data.equipment_code}} status changed", "Equipment {{.Data.equipment_code}} moved from {{.Data.from}} to {{.Data.to}}."},
+	},
+	event.EquipmentRULRisk: {
+		LanguageZH: {"【EMS】设备停机风险预警：{{.Data.equipment_code}}", "设备 {{.Data.equipment_code}} {{.Data.equipment_name}} 预计剩余健康寿命 {{.Data.estimated_rul_days}} 天{{with .Data.recommendation}}\n{{.}}{{end}}"},
+		LanguageEN: {"[EMS] Downtime risk: {{.Data.equipment_code}}", "Equipment {{.Data.equipment_code}} {{.Data.equipment_name}} has an estimated {{.Data.estimated_rul_days}} day(s) of remaining useful life.{{with .Data.recommendation}}\n{{.}}{{end}}"},
+	},
+	event.RepairSLABreached: {
+		LanguageZH: {"【EMS】维修单 #{{.Data.order_id}} 超时", "维修单 #{{.Data.order_id}}（设备 {{.Data.equipment_code}}，优先级 {{.Data.priority}}）已超出{{if eq .Data.stage \"response\"}}响应{{else}}修复{{end}}时限 {{.Data.deadline}}，当前状态 {{.Data.status}}"},
+		LanguageEN: {"[EMS] Repair order #{{.Data.order_id}} breached its SLA", "Repair order #{{.Data.order_id}} (equipment {{.Data.equipment_code}}, priority {{.Data.priority}}) missed its {{.Data.stage}} deadline {{.Data.deadline}}; current status {{.Data.status}}."},
+	},
+}
+
+// fallback 无对应模板时使用
+var fallback = map[string]Template{
+	LanguageZH: {"【EMS】{{.Type}}", "事件 {{.Type}}{{with .Event.EquipmentID}}（设备 #{{.}}）{{end}}"},
+	LanguageEN: {"[EMS] {{.Type}}", "Event {{.Type}}{{with .Event.EquipmentID}} (equipment #{{.}}){{end}}"},
+}
+
+// DigestEventType 告警摘要消息的事件类型
+const DigestEventType = "alert.digest"
+
+// Digest 将多条已渲染的告警摘要行合并为一条消息
+func Digest(lang string, lines []string) Message {
+	msg := Message{
+		Subject:   fmt.Sprintf("【EMS】告警摘要（%d 条）", len(lines)),
+		Body:      "以下告警已合并发送：\n" + strings.Join(lines, "\n"),
+		EventType: DigestEventType,
+	}
+	if lang == LanguageEN {
+		msg.Subject = fmt.Sprintf("[EMS] Alert digest (%d)", len(lines))
+		msg.Body = "The following alerts were batched:\n" + strings.Join(lines, "\n")
+	}
+	return msg
+}
+
+// EscalationEventType 升级通知的事件类型，用于投递日志
+const EscalationEventType = "escalation"
+
+// Escalation 升级通知：title 为维修单或告警的描述，level 为第几级（从 1 开始）
+func Escalation(lang, title string, level int, elapsed time.Duration) Message {
+	minutes := int(elapsed.Minutes())
+	msg := Message{
+		Subject:   fmt.Sprintf("【EMS】第 %d 级升级：%s", level, title),
+		Body:      fmt.Sprintf("%s 已 %d 分钟未确认处理，请尽快跟进或确认。", title, minutes),
+		EventType: EscalationEventType,
+	}
+	if lang == LanguageEN {
+		msg.Subject = fmt.Sprintf("[EMS] Escalation level %d: %s", level, title)
+		msg.Body = fmt.Sprintf("%s has not been acknowledged for %d minutes. Please follow up or acknowledge it.", title, minutes)
+	}
+	return msg
+}
+
+// Builtin 返回事件类型在指定语言下的内置模板，语言不支持时退回默认语言，
+// 事件类型没有专用模板时返回通用模板
+func Builtin(eventType event.Type, lang string) Template {
+	if !ValidLanguage(lang) {
+		lang = DefaultLanguage
+	}
+	if byLang, ok := builtin[eventType]; ok {
+		return byLang[lang]
+	}
+	return fallback[lang]
+}
@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/ems/backend/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Alert Repository：告警、摘要队列与免打扰时段
+type AlertRepository struct {
+	db *gorm.DB
+}
+
+func NewAlertRepository() *AlertRepository {
+	return &AlertRepository{db: DB}
+}
+
+func (r *AlertRepository) WithTx(tx *gorm.DB) *AlertRepository {
+	return &AlertRepository{db: tx}
+}
+
+// LockActive 锁定指纹对应的活动（未解决）告警，不存在时返回 gorm.ErrRecordNotFound
+func (r *AlertRepository) LockActive(fingerprint string) (*model.Alert, error) {
+	var alert model.Alert
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("active_key = ?", fingerprint).First(&alert).Error
+	if err != nil {
+		return nil, err
+	}
+	return &alert, nil
+}
+
+// GetActive 返回指纹对应的活动告警
+func (r *AlertRepository) GetActive(fingerprint string) (*model.Alert, error) {
+	var alert model.Alert
+	if err := r.db.Where("active_key = ?", fingerprint).First(&alert).Error; err != nil {
+		return nil, err
+	}
+	return &alert, nil
+}
+
+func (r *AlertRepository) Create(alert *model.Alert) error {
+	return r.db.Create(alert).Error
+}
+
+func (r *AlertRepository) Save(alert *model.Alert) error {
+	return r.db.Save(alert).Error
+}
+
+func (r *AlertRepository) GetByID(id uint) (*model.Alert, error) {
+	var alert model.Alert
+	if err := r.db.First(&alert, id).Error; err != nil {
+		return nil, err
+	}
+	return &alert, nil
+}
+
+func (r *AlertRepository) GetByIDs(ids []uint) ([]model.Alert, error) {
+	var alerts []model.Alert
+	err := r.db.Where("id IN ?", ids).Order("id").Find(&alerts).Error
+	return alerts, err
+}
+
+// List 按状态、严重度与工厂筛选告警，factoryID 为空时不限工厂
+func (r *AlertRepository) List(status, severity string, factoryID *uint, limit int) ([]model.Alert, error) {
+	query := r.db.Model(&model.Alert{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if severity != "" {
+		query = query.Where("severity = ?", severity)
+	}
+	if factoryID != nil {
+		query = query.Where("factory_id = ?", *factoryID)
+	}
+	var alerts []model.Alert
+	err := query.Order("last_seen_at DESC").Limit(limit).Find(&alerts).Error
+	return alerts, err
+}
+
+// ListActiveByEquipment 返回设备上指定事件类型的活动告警
+func (r *AlertRepository) ListActiveByEquipment(equipmentID uint, eventTypes []string) ([]model.Alert, error) {
+	var alerts []model.Alert
+	err := r.db.Where("equipment_id = ? AND event_type IN ? AND active_key IS NOT NULL", equipmentID, eventTypes).Find(&alerts).Error
+	return alerts, err
+}
+
+// ListStale 返回最近一次事件早于 before 的活动告警
+func (r *AlertRepository) ListStale(before time.Time) ([]model.Alert, error) {
+	var alerts []model.Alert
+	err := r.db.Where("active_key IS NOT NULL AND last_seen_at < ?", before).Find(&alerts).Error
+	return alerts, err
+}
+
+// EnqueueDigest 将告警加入用户的摘要队列；同一告警已在队列中时不重复加入
+func (r *AlertRepository) EnqueueDigest(userID, alertID uint, reason string) error {
+	var count int64
+	if err := r.db.Model(&model.AlertDigestEntry{}).
+		Where("user_id = ? AND alert_id = ? AND sent_at IS NULL", userID, alertID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	return r.db.Create(&model.AlertDigestEntry{UserID: userID, AlertID: alertID, Reason: reason}).Error
+}
+
+func (r *AlertRepository) ListPendingDigest() ([]model.AlertDigestEntry, error) {
+	var entries []model.AlertDigestEntry
+	err := r.db.Where("sent_at IS NULL").Order("user_id, id").Find(&entries).Error
+	return entries, err
+}
+
+func (r *AlertRepository) MarkDigestSent(ids []uint, at time.Time) error {
+	return r.db.Model(&model.AlertDigestEntry{}).Where("id IN ?", ids).Update("sent_at", at).Error
+}
+
+func (r *AlertRepository) GetQuietHours(userID uint) (*model.NotificationQuietHours, error) {
+	var q model.NotificationQuietHours
+	if err := r.db.Where("user_id = ?", userID).First(&q).Error; err != nil {
+		return nil, err
+	}
+	return &q, nil
+}
+
+func (r *AlertRepository) SaveQuietHours(q *model.NotificationQuietHours) error {
+	return r.db.Save(q).Error
+}
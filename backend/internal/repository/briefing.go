@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/ems/backend/internal/model"
+	"gorm.io/gorm"
+)
+
+// AgentBriefing Repository
+type AgentBriefingRepository struct {
+	db *gorm.DB
+}
+
+func NewAgentBriefingRepository() *AgentBriefingRepository {
+	return &AgentBriefingRepository{db: DB}
+}
+
+func (r *AgentBriefingRepository) Create(briefing *model.AgentBriefing) error {
+	return r.db.Create(briefing).Error
+}
+
+func (r *AgentBriefingRepository) GetByID(id uint) (*model.AgentBriefing, error) {
+	var briefing model.AgentBriefing
+	if err := r.db.First(&briefing, id).Error; err != nil {
+		return nil, err
+	}
+	return &briefing, nil
+}
+
+// List 返回简报定义；factoryID 不为空时仅返回该工厂及全局定义
+func (r *AgentBriefingRepository) List(factoryID *uint) ([]model.AgentBriefing, error) {
+	var briefings []model.AgentBriefing
+	query := r.db.Model(&model.AgentBriefing{})
+	if factoryID != nil {
+		query = query.Where("factory_id = ? OR factory_id IS NULL", *factoryID)
+	}
+	err := query.Order("id ASC").Find(&briefings).Error
+	return briefings, err
+}
+
+// ListDue 返回已到执行时间的启用定义
+func (r *AgentBriefingRepository) ListDue(now time.Time) ([]model.AgentBriefing, error) {
+	var briefings []model.AgentBriefing
+	err := r.db.Where("enabled = ? AND next_run_at IS NOT NULL AND next_run_at <= ?", true, now).
+		Order("next_run_at ASC").Find(&briefings).Error
+	return briefings, err
+}
+
+func (r *AgentBriefingRepository) Update(briefing *model.AgentBriefing) error {
+	return r.db.Save(briefing).Error
+}
+
+func (r *AgentBriefingRepository) Delete(id uint) error {
+	return r.db.Delete(&model.AgentBriefing{}, id).Error
+}
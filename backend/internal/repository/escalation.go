@@ -0,0 +1,183 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/ems/backend/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Escalation Repository：升级策略、升级过程、升级历史与值班安排
+type EscalationRepository struct {
+	db *gorm.DB
+}
+
+func NewEscalationRepository() *EscalationRepository {
+	return &EscalationRepository{db: DB}
+}
+
+func (r *EscalationRepository) WithTx(tx *gorm.DB) *EscalationRepository {
+	return &EscalationRepository{db: tx}
+}
+
+// ListPolicies factoryID 为空时返回全部策略
+func (r *EscalationRepository) ListPolicies(factoryID *uint) ([]model.EscalationPolicy, error) {
+	query := r.db.Model(&model.EscalationPolicy{})
+	if factoryID != nil {
+		query = query.Where("factory_id = ? OR factory_id IS NULL", *factoryID)
+	}
+	var policies []model.EscalationPolicy
+	err := query.Order("factory_id NULLS FIRST, priority, id").Find(&policies).Error
+	return policies, err
+}
+
+func (r *EscalationRepository) GetPolicy(id uint) (*model.EscalationPolicy, error) {
+	var policy model.EscalationPolicy
+	if err := r.db.First(&policy, id).Error; err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// FindPolicy 返回工厂与优先级对应的启用策略，工厂未配置时使用默认策略（factory_id 为空）
+func (r *EscalationRepository) FindPolicy(factoryID *uint, priority int) (*model.EscalationPolicy, error) {
+	query := r.db.Where("priority = ? AND enabled = ?", priority, true)
+	if factoryID != nil {
+		query = query.Where("factory_id = ? OR factory_id IS NULL", *factoryID)
+	} else {
+		query = query.Where("factory_id IS NULL")
+	}
+	var policy model.EscalationPolicy
+	if err := query.Order("factory_id NULLS LAST, id").First(&policy).Error; err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+func (r *EscalationRepository) SavePolicy(policy *model.EscalationPolicy) error {
+	return r.db.Save(policy).Error
+}
+
+func (r *EscalationRepository) DeletePolicy(id uint) error {
+	return r.db.Delete(&model.EscalationPolicy{}, id).Error
+}
+
+func (r *EscalationRepository) Create(esc *model.Escalation) error {
+	return r.db.Create(esc).Error
+}
+
+func (r *EscalationRepository) Save(esc *model.Escalation) error {
+	return r.db.Save(esc).Error
+}
+
+func (r *EscalationRepository) GetByID(id uint) (*model.Escalation, error) {
+	var esc model.Escalation
+	if err := r.db.Preload("Logs", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).First(&esc, id).Error; err != nil {
+		return nil, err
+	}
+	return &esc, nil
+}
+
+// LockOpen 锁定对象上尚未确认或解决的升级（进行中或已通知完所有级别），不存在时返回 gorm.ErrRecordNotFound
+func (r *EscalationRepository) LockOpen(subjectType string, subjectID uint) (*model.Escalation, error) {
+	var esc model.Escalation
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("subject_type = ? AND subject_id = ? AND status IN ?", subjectType, subjectID,
+			[]string{model.EscalationActive, model.EscalationExhausted}).
+		Order("id DESC").First(&esc).Error
+	if err != nil {
+		return nil, err
+	}
+	return &esc, nil
+}
+
+// ExistsForSubject 对象是否已经开始过升级（含已结束的），用于事件重试时保持幂等
+func (r *EscalationRepository) ExistsForSubject(subjectType string, subjectID uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&model.Escalation{}).Where("subject_type = ? AND subject_id = ?", subjectType, subjectID).Count(&count).Error
+	return count > 0, err
+}
+
+// ListBySubject 返回对象的全部升级及其历史
+func (r *EscalationRepository) ListBySubject(subjectType string, subjectID uint) ([]model.Escalation, error) {
+	var escalations []model.Escalation
+	err := r.db.Preload("Logs", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Where("subject_type = ? AND subject_id = ?", subjectType, subjectID).Order("id").Find(&escalations).Error
+	return escalations, err
+}
+
+// List 按状态与工厂筛选升级，factoryID 为空时不限工厂
+func (r *EscalationRepository) List(status string, factoryID *uint, limit int) ([]model.Escalation, error) {
+	query := r.db.Model(&model.Escalation{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if factoryID != nil {
+		query = query.Where("factory_id = ?", *factoryID)
+	}
+	var escalations []model.Escalation
+	err := query.Order("id DESC").Limit(limit).Find(&escalations).Error
+	return escalations, err
+}
+
+// ClaimDue 锁定并领取到期的升级，领取后 lease 时间内其他实例不会重复领取
+func (r *EscalationRepository) ClaimDue(now time.Time, lease time.Duration, limit int) ([]model.Escalation, error) {
+	var escalations []model.Escalation
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_at <= ?", model.EscalationActive, now).
+			Order("next_at").Limit(limit).Find(&escalations).Error
+		if err != nil || len(escalations) == 0 {
+			return err
+		}
+		ids := make([]uint, len(escalations))
+		for i, esc := range escalations {
+			ids[i] = esc.ID
+		}
+		return tx.Model(&model.Escalation{}).Where("id IN ?", ids).Update("next_at", now.Add(lease)).Error
+	})
+	return escalations, err
+}
+
+func (r *EscalationRepository) CreateLog(entry *model.EscalationLog) error {
+	return r.db.Create(entry).Error
+}
+
+// ListShifts 返回与 [from, to) 有交集的值班，factoryID 为空时不限工厂
+func (r *EscalationRepository) ListShifts(factoryID *uint, from, to time.Time) ([]model.OnCallShift, error) {
+	query := r.db.Preload("User").Where("start_at < ? AND end_at > ?", to, from)
+	if factoryID != nil {
+		query = query.Where("factory_id = ? OR factory_id IS NULL", *factoryID)
+	}
+	var shifts []model.OnCallShift
+	err := query.Order("start_at, id").Find(&shifts).Error
+	return shifts, err
+}
+
+// OnCallUserIDs 返回 at 时刻工厂的值班人（含不限工厂的值班）
+func (r *EscalationRepository) OnCallUserIDs(factoryID *uint, at time.Time) ([]uint, error) {
+	query := r.db.Model(&model.OnCallShift{}).Where("start_at <= ? AND end_at > ?", at, at)
+	if factoryID != nil {
+		query = query.Where("factory_id = ? OR factory_id IS NULL", *factoryID)
+	}
+	var ids []uint
+	err := query.Distinct().Order("user_id").Pluck("user_id", &ids).Error
+	return ids, err
+}
+
+func (r *EscalationRepository) GetShift(id uint) (*model.OnCallShift, error) {
+	var shift model.OnCallShift
+	if err := r.db.First(&shift, id).Error; err != nil {
+		return nil, err
+	}
+	return &shift, nil
+}
+
+func (r *EscalationRepository) CreateShift(shift *model.OnCallShift) error {
+	return r.db.Create(shift).Error
+}
+
+func (r *EscalationRepository) DeleteShift(id uint) error {
+	return r.db.Delete(&model.OnCallShift{}, id).Error
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/ems/backend/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DomainEvent Repository (transactional outbox)
+type DomainEventRepository struct {
+	db *gorm.DB
+}
+
+func NewDomainEventRepository() *DomainEventRepository {
+	return &DomainEventRepository{db: DB}
+}
+
+// WithTx 返回绑定到事务 tx 的仓储，使事件与业务数据一同提交或回滚
+func (r *DomainEventRepository) WithTx(tx *gorm.DB) *DomainEventRepository {
+	return &DomainEventRepository{db: tx}
+}
+
+func (r *DomainEventRepository) Create(event *model.DomainEvent) error {
+	return r.db.Create(event).Error
+}
+
+// ExistsSince 设备自 since 起是否已记录过该类型事件，用于周期巡检去重
+func (r *DomainEventRepository) ExistsSince(eventType string, equipmentID uint, since time.Time) (bool, error) {
+	var count int64
+	err := r.db.Model(&model.DomainEvent{}).
+		Where("type = ? AND equipment_id = ? AND created_at >= ?", eventType, equipmentID, since).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// ClaimDue 锁定并领取到期的待投递事件，领取后 lease 时间内其他实例不会重复领取；
+// 进程在租约内崩溃时，事件会在租约到期后被重新领取。返回记录的 NextAttemptAt 为租约到期时间，续约时作为持有凭证
+func (r *DomainEventRepository) ClaimDue(now time.Time, lease time.Duration, limit int) ([]model.DomainEvent, error) {
+	var events []model.DomainEvent
+	// 数据库只保存到微秒，截断后才能按值比较租约
+	until := now.Add(lease).Truncate(time.Microsecond)
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND (next_attempt_at IS NULL OR next_attempt_at <= ?)", "pending", now).
+			Order("id").Limit(limit).Find(&events).Error
+		if err != nil || len(events) == 0 {
+			return err
+		}
+		ids := make([]uint, len(events))
+		for i, e := range events {
+			ids[i] = e.ID
+		}
+		return tx.Model(&model.DomainEvent{}).Where("id IN ?", ids).Update("next_attempt_at", until).Error
+	})
+	for i := range events {
+		events[i].NextAttemptAt = &until
+	}
+	return events, err
+}
+
+// ExtendLease 将本实例持有的租约延长到 until；租约已过期并被其他实例重新领取时返回 false
+func (r *DomainEventRepository) ExtendLease(event *model.DomainEvent, until time.Time) (bool, error) {
+	until = until.Truncate(time.Microsecond)
+	res := r.db.Model(&model.DomainEvent{}).
+		Where("id = ? AND status = ? AND next_attempt_at = ?", event.ID, "pending", event.NextAttemptAt).
+		Update("next_attempt_at", until)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return false, res.Error
+	}
+	event.NextAttemptAt = &until
+	return true, nil
+}
+
+// SaveResult 只更新投递相关字段
+func (r *DomainEventRepository) SaveResult(event *model.DomainEvent) error {
+	return r.db.Model(&model.DomainEvent{}).Where("id = ?", event.ID).Updates(map[string]interface{}{
+		"status":          event.Status,
+		"attempts":        event.Attempts,
+		"delivered":       event.Delivered,
+		"last_error":      event.LastError,
+		"next_attempt_at": event.NextAttemptAt,
+		"dispatched_at":   event.DispatchedAt,
+	}).Error
+}
+
+// PurgeDispatched 清理早于 before 的已投递事件，失败事件保留以便排查
+func (r *DomainEventRepository) PurgeDispatched(before time.Time) (int64, error) {
+	res := r.db.Unscoped().Where("status = ? AND dispatched_at < ?", "dispatched", before).Delete(&model.DomainEvent{})
+	return res.RowsAffected, res.Error
+}
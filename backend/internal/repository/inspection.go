@@ -96,6 +96,11 @@ func NewInspectionTaskRepository() *InspectionTaskRepository {
 	return &InspectionTaskRepository{db: DB}
 }
 
+// WithTx 返回绑定到事务 tx 的仓储
+func (r *InspectionTaskRepository) WithTx(tx *gorm.DB) *InspectionTaskRepository {
+	return &InspectionTaskRepository{db: tx}
+}
+
 func (r *InspectionTaskRepository) Create(task *model.InspectionTask) error {
 	return r.db.Create(task).Error
 }
@@ -115,6 +120,7 @@ func (r *InspectionTaskRepository) GetByID(id uint) (*model.InspectionTask, erro
 
 type InspectionTaskFilter struct {
 	AssignedTo uint
+	FactoryID  *uint
 	Status     string
 	DateFrom   time.Time
 	DateTo     time.Time
@@ -128,6 +134,9 @@ func (r *InspectionTaskRepository) List(filter InspectionTaskFilter) ([]model.In
 
 	query := r.db.Model(&model.InspectionTask{})
 
+	if filter.FactoryID != nil {
+		query = query.Where("equipment_id IN (?)", factoryEquipmentIDs(r.db, *filter.FactoryID))
+	}
 	if filter.AssignedTo > 0 {
 		query = query.Where("assigned_to = ?", filter.AssignedTo)
 	}
@@ -199,6 +208,23 @@ func (r *InspectionTaskRepository) GetOverdueTasks() ([]model.InspectionTask, er
 	return tasks, err
 }
 
+// GetHistoryByEquipment returns an equipment's inspection tasks with their records, newest first
+func (r *InspectionTaskRepository) GetHistoryByEquipment(equipmentID uint, dateFrom, dateTo time.Time, limit int) ([]model.InspectionTask, error) {
+	var tasks []model.InspectionTask
+	query := r.db.Where("equipment_id = ?", equipmentID)
+	if !dateFrom.IsZero() {
+		query = query.Where("scheduled_date >= ?", dateFrom)
+	}
+	if !dateTo.IsZero() {
+		query = query.Where("scheduled_date <= ?", dateTo)
+	}
+	err := query.Preload("Equipment").Preload("Assignee").Preload("Template").Preload("Template.Items").
+		Preload("Records").Preload("Records.Item").
+		Order("scheduled_date DESC, id DESC").
+		Limit(limit).Find(&tasks).Error
+	return tasks, err
+}
+
 // InspectionRecord Repository
 type InspectionRecordRepository struct {
 	db *gorm.DB
@@ -208,6 +234,11 @@ func NewInspectionRecordRepository() *InspectionRecordRepository {
 	return &InspectionRecordRepository{db: DB}
 }
 
+// WithTx 返回绑定到事务 tx 的仓储
+func (r *InspectionRecordRepository) WithTx(tx *gorm.DB) *InspectionRecordRepository {
+	return &InspectionRecordRepository{db: tx}
+}
+
 func (r *InspectionRecordRepository) Create(record *model.InspectionRecord) error {
 	return r.db.Create(record).Error
 }
@@ -246,6 +277,41 @@ func (r *InspectionRecordRepository) GetNGRecords(taskID uint) ([]model.Inspecti
 	return records, err
 }
 
+// NGTrendFilter 按设备或工厂统计 NG 记录
+type NGTrendFilter struct {
+	EquipmentID uint
+	FactoryID   *uint
+	Since       time.Time
+}
+
+type NGTrendPoint struct {
+	Date    string `json:"date"`
+	NGCount int64  `json:"ng_count"`
+	Tasks   int64  `json:"tasks"`
+}
+
+// GetNGTrend counts NG inspection records per scheduled day
+func (r *InspectionRecordRepository) GetNGTrend(filter NGTrendFilter) ([]NGTrendPoint, error) {
+	var points []NGTrendPoint
+	query := r.db.Table("inspection_records").
+		Select("TO_CHAR(DATE(inspection_tasks.scheduled_date), 'YYYY-MM-DD') AS date, COUNT(*) AS ng_count, COUNT(DISTINCT inspection_tasks.id) AS tasks").
+		Joins("JOIN inspection_tasks ON inspection_tasks.id = inspection_records.task_id").
+		Where("inspection_records.result = ? AND inspection_records.deleted_at IS NULL", "NG").
+		Where("inspection_tasks.scheduled_date >= ?", filter.Since)
+	if filter.EquipmentID > 0 {
+		query = query.Where("inspection_tasks.equipment_id = ?", filter.EquipmentID)
+	}
+	if filter.FactoryID != nil {
+		query = query.Joins("JOIN equipment ON equipment.id = inspection_tasks.equipment_id").
+			Joins("JOIN workshops ON workshops.id = equipment.workshop_id").
+			Where("workshops.factory_id = ?", *filter.FactoryID)
+	}
+	err := query.Group("DATE(inspection_tasks.scheduled_date)").
+		Order("DATE(inspection_tasks.scheduled_date) ASC").
+		Scan(&points).Error
+	return points, err
+}
+
 // Check if all items in a task are completed
 func (r *InspectionRecordRepository) GetTaskProgress(taskID uint) (total int, completed int, err error) {
 	var totalCount int64
@@ -269,19 +335,27 @@ func (r *InspectionRecordRepository) GetTaskProgress(taskID uint) (total int, co
 }
 
 // Statistics
-func (r *InspectionTaskRepository) GetStatistics() (map[string]int64, error) {
+// GetStatistics 任务统计，factoryID 不为空时只统计该工厂的设备
+func (r *InspectionTaskRepository) GetStatistics(factoryID *uint) (map[string]int64, error) {
 	stats := make(map[string]int64)
+	tasks := func() *gorm.DB {
+		q := r.db.Model(&model.InspectionTask{})
+		if factoryID != nil {
+			q = q.Where("equipment_id IN (?)", factoryEquipmentIDs(r.db, *factoryID))
+		}
+		return q
+	}
 
 	var total, pending, inProgress, completed, overdue, todayCompleted int64
-	r.db.Model(&model.InspectionTask{}).Count(&total)
-	r.db.Model(&model.InspectionTask{}).Where("status = ?", "pending").Count(&pending)
-	r.db.Model(&model.InspectionTask{}).Where("status = ?", "in_progress").Count(&inProgress)
-	r.db.Model(&model.InspectionTask{}).Where("status = ?", "completed").Count(&completed)
-	r.db.Model(&model.InspectionTask{}).Where("status = ?", "overdue").Count(&overdue)
+	tasks().Count(&total)
+	tasks().Where("status = ?", "pending").Count(&pending)
+	tasks().Where("status = ?", "in_progress").Count(&inProgress)
+	tasks().Where("status = ?", "completed").Count(&completed)
+	tasks().Where("status = ?", "overdue").Count(&overdue)
 
 	// Today completed
 	today := time.Now().Format("2006-01-02")
-	r.db.Model(&model.InspectionTask{}).
+	tasks().
 		Where("status = ? AND DATE(completed_at) = ?", "completed", today).
 		Count(&todayCompleted)
 
@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"github.com/ems/backend/internal/model"
+	"gorm.io/gorm"
+)
+
+// LarkAlertCard Repository
+type LarkAlertCardRepository struct {
+	db *gorm.DB
+}
+
+func NewLarkAlertCardRepository() *LarkAlertCardRepository {
+	return &LarkAlertCardRepository{db: DB}
+}
+
+func (r *LarkAlertCardRepository) Create(card *model.LarkAlertCard) error {
+	return r.db.Create(card).Error
+}
+
+func (r *LarkAlertCardRepository) GetByMessageID(messageID string) (*model.LarkAlertCard, error) {
+	var card model.LarkAlertCard
+	if err := r.db.Where("message_id = ?", messageID).First(&card).Error; err != nil {
+		return nil, err
+	}
+	return &card, nil
+}
+
+func (r *LarkAlertCardRepository) Update(card *model.LarkAlertCard) error {
+	return r.db.Save(card).Error
+}
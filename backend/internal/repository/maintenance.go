@@ -98,6 +98,11 @@ func NewMaintenanceTaskRepository() *MaintenanceTaskRepository {
 	return &MaintenanceTaskRepository{db: DB}
 }
 
+// WithTx 返回绑定到事务 tx 的仓储
+func (r *MaintenanceTaskRepository) WithTx(tx *gorm.DB) *MaintenanceTaskRepository {
+	return &MaintenanceTaskRepository{db: tx}
+}
+
 func (r *MaintenanceTaskRepository) Create(task *model.MaintenanceTask) error {
 	return r.db.Create(task).Error
 }
@@ -219,11 +224,73 @@ func (r *MaintenanceTaskRepository) GetOverdueTasks() ([]model.MaintenanceTask,
 	var tasks []model.MaintenanceTask
 	today := time.Now().Format("2006-01-02")
 	err := r.db.Where("due_date < ? AND status IN ?", today, []string{"pending", "in_progress"}).
-		Preload("Equipment").Preload("Assignee").
+		Preload("Equipment.Workshop").Preload("Plan").Preload("Assignee").
+		Find(&tasks).Error
+	return tasks, err
+}
+
+// ListOpenByPlan 返回计划下未完成的任务（待执行、进行中、逾期），按计划日期排序
+func (r *MaintenanceTaskRepository) ListOpenByPlan(planID uint) ([]model.MaintenanceTask, error) {
+	var tasks []model.MaintenanceTask
+	err := r.db.Where("plan_id = ? AND status IN ?", planID,
+		[]string{model.MaintenancePending, model.MaintenanceInProgress, model.MaintenanceOverdue}).
+		Order("scheduled_date ASC, id ASC").
 		Find(&tasks).Error
 	return tasks, err
 }
 
+// LastCompletedByPlan 返回计划下每台设备最近一次完成保养的时间
+func (r *MaintenanceTaskRepository) LastCompletedByPlan(planID uint) (map[uint]time.Time, error) {
+	var rows []struct {
+		EquipmentID uint
+		CompletedAt time.Time
+	}
+	err := r.db.Model(&model.MaintenanceTask{}).
+		Select("equipment_id, MAX(completed_at) AS completed_at").
+		Where("plan_id = ? AND status = ? AND completed_at IS NOT NULL", planID, model.MaintenanceCompleted).
+		Group("equipment_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[uint]time.Time, len(rows))
+	for _, row := range rows {
+		result[row.EquipmentID] = row.CompletedAt
+	}
+	return result, nil
+}
+
+// OpenCountByAssignee 返回每个用户未完成的保养任务数，用于按工作量分配
+func (r *MaintenanceTaskRepository) OpenCountByAssignee(userIDs []uint) (map[uint]int, error) {
+	result := make(map[uint]int, len(userIDs))
+	if len(userIDs) == 0 {
+		return result, nil
+	}
+	var rows []struct {
+		AssignedTo uint
+		Count      int
+	}
+	err := r.db.Model(&model.MaintenanceTask{}).
+		Select("assigned_to, COUNT(*) AS count").
+		Where("assigned_to IN ? AND status IN ?", userIDs,
+			[]string{model.MaintenancePending, model.MaintenanceInProgress, model.MaintenanceOverdue}).
+		Group("assigned_to").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		result[row.AssignedTo] = row.Count
+	}
+	return result, nil
+}
+
+// RescheduleDates 更新任务的计划日期与截止日期
+func (r *MaintenanceTaskRepository) RescheduleDates(id uint, scheduledDate, dueDate string) error {
+	return r.db.Model(&model.MaintenanceTask{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"scheduled_date": scheduledDate, "due_date": dueDate}).Error
+}
+
 // Update overdue status
 func (r *MaintenanceTaskRepository) UpdateOverdueStatus(ids []uint) error {
 	return r.db.Model(&model.MaintenanceTask{}).
@@ -269,21 +336,28 @@ func (r *MaintenanceRecordRepository) GetByTaskID(taskID uint) ([]model.Maintena
 	return records, err
 }
 
-// Get statistics
-func (r *MaintenanceTaskRepository) GetStatistics() (map[string]int64, error) {
+// GetStatistics 任务统计，factoryID 不为空时只统计该工厂的设备（保养计划按设备类型定义，不区分工厂）
+func (r *MaintenanceTaskRepository) GetStatistics(factoryID *uint) (map[string]int64, error) {
 	stats := make(map[string]int64)
+	tasks := func() *gorm.DB {
+		q := r.db.Model(&model.MaintenanceTask{})
+		if factoryID != nil {
+			q = q.Where("equipment_id IN (?)", factoryEquipmentIDs(r.db, *factoryID))
+		}
+		return q
+	}
 
 	var totalPlans, totalTasks, pending, inProgress, completed, overdue, todayCompleted int64
 	r.db.Model(&model.MaintenancePlan{}).Count(&totalPlans)
-	r.db.Model(&model.MaintenanceTask{}).Count(&totalTasks)
-	r.db.Model(&model.MaintenanceTask{}).Where("status = ?", "pending").Count(&pending)
-	r.db.Model(&model.MaintenanceTask{}).Where("status = ?", "in_progress").Count(&inProgress)
-	r.db.Model(&model.MaintenanceTask{}).Where("status = ?", "completed").Count(&completed)
-	r.db.Model(&model.MaintenanceTask{}).Where("status = ?", "overdue").Count(&overdue)
+	tasks().Count(&totalTasks)
+	tasks().Where("status = ?", "pending").Count(&pending)
+	tasks().Where("status = ?", "in_progress").Count(&inProgress)
+	tasks().Where("status = ?", "completed").Count(&completed)
+	tasks().Where("status = ?", "overdue").Count(&overdue)
 
 	// Today completed
 	today := time.Now().Format("2006-01-02")
-	r.db.Model(&model.MaintenanceTask{}).
+	tasks().
 		Where("status = ? AND DATE(completed_at) = ?", "completed", today).
 		Count(&todayCompleted)
 
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"github.com/ems/backend/internal/model"
+	"gorm.io/gorm"
+)
+
+// Notification Repository：渠道偏好、模板与投递记录
+type NotificationRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationRepository() *NotificationRepository {
+	return &NotificationRepository{db: DB}
+}
+
+func (r *NotificationRepository) ListPreferences(userID uint) ([]model.NotificationPreference, error) {
+	var prefs []model.NotificationPreference
+	err := r.db.Where("user_id = ?", userID).Order("channel").Find(&prefs).Error
+	return prefs, err
+}
+
+func (r *NotificationRepository) GetPreference(userID uint, channel string) (*model.NotificationPreference, error) {
+	var pref model.NotificationPreference
+	if err := r.db.Where("user_id = ? AND channel = ?", userID, channel).First(&pref).Error; err != nil {
+		return nil, err
+	}
+	return &pref, nil
+}
+
+func (r *NotificationRepository) SavePreference(pref *model.NotificationPreference) error {
+	return r.db.Save(pref).Error
+}
+
+func (r *NotificationRepository) DeletePreference(userID uint, channel string) error {
+	// 物理删除：(user_id, channel) 唯一索引不含 deleted_at，软删除后无法重新添加
+	result := r.db.Unscoped().Where("user_id = ? AND channel = ?", userID, channel).Delete(&model.NotificationPreference{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *NotificationRepository) ListTemplates() ([]model.NotificationTemplate, error) {
+	var templates []model.NotificationTemplate
+	err := r.db.Order("event_type, language").Find(&templates).Error
+	return templates, err
+}
+
+func (r *NotificationRepository) GetTemplate(eventType, language string) (*model.NotificationTemplate, error) {
+	var tpl model.NotificationTemplate
+	if err := r.db.Where("event_type = ? AND language = ?", eventType, language).First(&tpl).Error; err != nil {
+		return nil, err
+	}
+	return &tpl, nil
+}
+
+func (r *NotificationRepository) SaveTemplate(tpl *model.NotificationTemplate) error {
+	return r.db.Save(tpl).Error
+}
+
+func (r *NotificationRepository) DeleteTemplate(id uint) error {
+	// 物理删除，原因同 DeletePreference
+	result := r.db.Unscoped().Delete(&model.NotificationTemplate{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *NotificationRepository) CreateDelivery(d *model.NotificationDelivery) error {
+	return r.db.Create(d).Error
+}
+
+// ListDeliveries 按用户（0 表示全部）、渠道与状态筛选最近的投递记录
+func (r *NotificationRepository) ListDeliveries(userID uint, channel, status string, limit int) ([]model.NotificationDelivery, error) {
+	query := r.db.Model(&model.NotificationDelivery{})
+	if userID > 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+	if channel != "" {
+		query = query.Where("channel = ?", channel)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var deliveries []model.NotificationDelivery
+	err := query.Order("id DESC").Limit(limit).Find(&deliveries).Error
+	return deliveries, err
+}
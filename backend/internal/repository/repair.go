@@ -17,6 +17,11 @@ func NewRepairOrderRepository() *RepairOrderRepository {
 	return &RepairOrderRepository{db: DB}
 }
 
+// WithTx 返回绑定到事务 tx 的仓储
+func (r *RepairOrderRepository) WithTx(tx *gorm.DB) *RepairOrderRepository {
+	return &RepairOrderRepository{db: tx}
+}
+
 func (r *RepairOrderRepository) Create(order *model.RepairOrder) error {
 	return r.db.Create(order).Error
 }
@@ -34,14 +39,16 @@ func (r *RepairOrderRepository) GetByID(id uint) (*model.RepairOrder, error) {
 }
 
 type RepairOrderFilter struct {
-	Status     string
-	Priority   int
-	AssignedTo uint
-	FactoryID  uint // Filter by factory
-	DateFrom   time.Time
-	DateTo     time.Time
-	Page       int
-	PageSize   int
+	Status      string
+	Priority    int
+	AssignedTo  uint
+	FactoryID   uint // Filter by factory
+	EquipmentID uint
+	FaultCode   string // 前缀匹配
+	DateFrom    time.Time
+	DateTo      time.Time
+	Page        int
+	PageSize    int
 }
 
 func (r *RepairOrderRepository) List(filter RepairOrderFilter) ([]model.RepairOrder, int64, error) {
@@ -65,6 +72,12 @@ func (r *RepairOrderRepository) List(filter RepairOrderFilter) ([]model.RepairOr
 	if filter.AssignedTo > 0 {
 		query = query.Where("repair_orders.assigned_to = ?", filter.AssignedTo)
 	}
+	if filter.EquipmentID > 0 {
+		query = query.Where("repair_orders.equipment_id = ?", filter.EquipmentID)
+	}
+	if filter.FaultCode != "" {
+		query = query.Where("repair_orders.fault_code LIKE ?", filter.FaultCode+"%")
+	}
 	if !filter.DateFrom.IsZero() {
 		query = query.Where("repair_orders.created_at >= ?", filter.DateFrom)
 	}
@@ -130,6 +143,25 @@ func (r *RepairOrderRepository) GetPendingOrders() ([]model.RepairOrder, error)
 	return orders, err
 }
 
+// ListUnfinished 返回尚未提交验收的工单（待指派、已指派、维修中），用于 SLA 检查
+func (r *RepairOrderRepository) ListUnfinished() ([]model.RepairOrder, error) {
+	var orders []model.RepairOrder
+	err := r.db.Where("status IN ?", []string{"pending", "assigned", "in_progress"}).
+		Preload("Equipment").Preload("Equipment.Workshop").
+		Order("priority ASC, created_at ASC").
+		Find(&orders).Error
+	return orders, err
+}
+
+// MarkSLABreached 记录工单超出响应（response）或修复（resolution）时限的时间
+func (r *RepairOrderRepository) MarkSLABreached(orderID uint, stage string, at time.Time) error {
+	column := "response_breached_at"
+	if stage == "resolution" {
+		column = "resolution_breached_at"
+	}
+	return r.db.Model(&model.RepairOrder{}).Where("id = ?", orderID).Update(column, at).Error
+}
+
 // Get orders by assignee
 func (r *RepairOrderRepository) GetByAssignee(assigneeID uint) ([]model.RepairOrder, error) {
 	var orders []model.RepairOrder
@@ -278,6 +310,11 @@ func NewRepairLogRepository() *RepairLogRepository {
 	return &RepairLogRepository{db: DB}
 }
 
+// WithTx 返回绑定到事务 tx 的仓储
+func (r *RepairLogRepository) WithTx(tx *gorm.DB) *RepairLogRepository {
+	return &RepairLogRepository{db: tx}
+}
+
 func (r *RepairLogRepository) Create(log *model.RepairLog) error {
 	return r.db.Create(log).Error
 }
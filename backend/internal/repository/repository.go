@@ -203,6 +203,11 @@ func NewEquipmentRepo() *EquipmentRepository {
 	return &EquipmentRepository{db: DB}
 }
 
+// WithTx 返回绑定到事务 tx 的仓储
+func (r *EquipmentRepository) WithTx(tx *gorm.DB) *EquipmentRepository {
+	return &EquipmentRepository{db: tx}
+}
+
 func (r *EquipmentRepository) Create(equipment *model.Equipment) error {
 	return r.db.Create(equipment).Error
 }
@@ -295,6 +300,20 @@ func (r *EquipmentRepository) List(filter EquipmentFilter) ([]model.Equipment, i
 	return equipments, total, err
 }
 
+// factoryEquipmentIDs 工厂下设备 ID 的子查询，用于按工厂过滤点检、保养任务
+func factoryEquipmentIDs(db *gorm.DB, factoryID uint) *gorm.DB {
+	return db.Model(&model.Equipment{}).Select("equipment.id").
+		Joins("JOIN workshops ON workshops.id = equipment.workshop_id").
+		Where("workshops.factory_id = ?", factoryID)
+}
+
+// ListByType 返回指定类型的全部设备（含车间，用于确定所属工厂）
+func (r *EquipmentRepository) ListByType(typeID uint) ([]model.Equipment, error) {
+	var equipments []model.Equipment
+	err := r.db.Preload("Workshop").Where("type_id = ?", typeID).Order("id ASC").Find(&equipments).Error
+	return equipments, err
+}
+
 func (r *EquipmentRepository) Update(equipment *model.Equipment) error {
 	return r.db.Save(equipment).Error
 }
@@ -422,12 +441,47 @@ func (r *UserRepository) GetByLarkAppID(appID string) (*model.User, error) {
 	return &user, nil
 }
 
+// FindLarkBot 返回同工厂已配置飞书机器人的用户；open_id 按应用区分，不使用其他工厂的机器人
+func (r *UserRepository) FindLarkBot(factoryID *uint) (*model.User, error) {
+	if factoryID == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	var user model.User
+	err := r.db.Where("factory_id = ? AND lark_app_id IS NOT NULL AND lark_app_id <> '' AND lark_app_secret IS NOT NULL AND lark_app_secret <> ''", *factoryID).
+		Order("id ASC").First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ListActiveByRoles 返回指定角色的在职用户，factoryID 为空时不限工厂
+func (r *UserRepository) ListActiveByRoles(roles []string, factoryID *uint) ([]model.User, error) {
+	var users []model.User
+	query := r.db.Where("role IN ? AND is_active = ?", roles, true)
+	if factoryID != nil {
+		query = query.Where("factory_id = ?", *factoryID)
+	}
+	err := query.Order("id ASC").Find(&users).Error
+	return users, err
+}
+
 func (r *UserRepository) UpdateLarkCredentials(userID uint, creds map[string]interface{}) error {
 	return r.db.Model(&model.User{}).Where("id = ?", userID).Updates(creds).Error
 }
 
-func (r *UserRepository) UpdateLarkOpenID(userID uint, openID string) error {
-	return r.db.Model(&model.User{}).Where("id = ?", userID).Update("LarkOpenID", openID).Error
+// UpdateLarkOpenID 绑定飞书 open_id，appID 为发起绑定的机器人应用，为空时不记录
+func (r *UserRepository) UpdateLarkOpenID(userID uint, openID string, appID string) error {
+	updates := map[string]interface{}{"lark_openid": openID, "lark_bot_app_id": nil}
+	if appID != "" {
+		updates["lark_bot_app_id"] = appID
+	}
+	return r.db.Model(&model.User{}).Where("id = ?", userID).Updates(updates).Error
+}
+
+// UpdateLarkBotAppID 记录用户 open_id 所属的飞书应用
+func (r *UserRepository) UpdateLarkBotAppID(userID uint, appID string) error {
+	return r.db.Model(&model.User{}).Where("id = ?", userID).Update("lark_bot_app_id", appID).Error
 }
 
 func (r *UserRepository) UpdateLarkConfig(userID uint, appID, appSecret, verificationToken, encryptKey string) error {
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/ems/backend/internal/model"
+	"gorm.io/gorm"
+)
+
+// Scheduler Repository：定时任务调度状态与执行记录
+type SchedulerRepository struct {
+	db *gorm.DB
+}
+
+func NewSchedulerRepository() *SchedulerRepository {
+	return &SchedulerRepository{db: DB}
+}
+
+func (r *SchedulerRepository) GetJob(name string) (*model.ScheduledJob, error) {
+	var job model.ScheduledJob
+	if err := r.db.Where("name = ?", name).First(&job).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *SchedulerRepository) ListJobs() ([]model.ScheduledJob, error) {
+	var jobs []model.ScheduledJob
+	err := r.db.Order("name").Find(&jobs).Error
+	return jobs, err
+}
+
+func (r *SchedulerRepository) SaveJob(job *model.ScheduledJob) error {
+	return r.db.Save(job).Error
+}
+
+// UpdateJobRun 只更新执行结果与下次触发时间，run 或 next 为 nil 时不更新对应列
+func (r *SchedulerRepository) UpdateJobRun(name string, run *model.JobRun, next *time.Time) error {
+	updates := map[string]interface{}{}
+	if run != nil {
+		updates["last_run_at"], updates["last_status"] = run.StartedAt, run.Status
+		updates["last_error"], updates["last_duration_ms"] = run.Error, run.DurationMs
+	}
+	if next != nil {
+		updates["next_run_at"] = *next
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	return r.db.Model(&model.ScheduledJob{}).Where("name = ?", name).Updates(updates).Error
+}
+
+// UpdateJobPaused 只更新暂停状态，next 不为 nil 时同时更新下次触发时间
+func (r *SchedulerRepository) UpdateJobPaused(name string, paused bool, next *time.Time) error {
+	updates := map[string]interface{}{"paused": paused}
+	if next != nil {
+		updates["next_run_at"] = *next
+	}
+	return r.db.Model(&model.ScheduledJob{}).Where("name = ?", name).Updates(updates).Error
+}
+
+func (r *SchedulerRepository) CreateRun(run *model.JobRun) error {
+	return r.db.Create(run).Error
+}
+
+// ListRuns 任务的执行记录，name 为空时返回全部任务
+func (r *SchedulerRepository) ListRuns(name, status string, limit int) ([]model.JobRun, error) {
+	query := r.db.Model(&model.JobRun{})
+	if name != "" {
+		query = query.Where("job_name = ?", name)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var runs []model.JobRun
+	err := query.Order("id DESC").Limit(limit).Find(&runs).Error
+	return runs, err
+}
+
+// PurgeRuns 清理早于 before 的执行记录
+func (r *SchedulerRepository) PurgeRuns(before time.Time) (int64, error) {
+	result := r.db.Where("started_at < ?", before).Delete(&model.JobRun{})
+	return result.RowsAffected, result.Error
+}
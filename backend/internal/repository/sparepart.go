@@ -5,6 +5,7 @@ import (
 
 	"github.com/ems/backend/internal/model"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // SparePart Repository
@@ -16,6 +17,11 @@ func NewSparePartRepository() *SparePartRepository {
 	return &SparePartRepository{db: DB}
 }
 
+// WithTx 返回绑定到事务 tx 的仓储
+func (r *SparePartRepository) WithTx(tx *gorm.DB) *SparePartRepository {
+	return &SparePartRepository{db: tx}
+}
+
 func (r *SparePartRepository) Create(part *model.SparePart) error {
 	return r.db.Create(part).Error
 }
@@ -50,6 +56,9 @@ func (r *SparePartRepository) List(filter SparePartFilter) ([]model.SparePart, i
 	if filter.Name != "" {
 		query = query.Where("name LIKE ?", "%"+filter.Name+"%")
 	}
+	if filter.FactoryID != nil {
+		query = query.Where("factory_id = ? OR factory_id IS NULL", *filter.FactoryID)
+	}
 
 	// Count total
 	if err := query.Count(&total).Error; err != nil {
@@ -82,6 +91,11 @@ func NewSparePartInventoryRepository() *SparePartInventoryRepository {
 	return &SparePartInventoryRepository{db: DB}
 }
 
+// WithTx 返回绑定到事务 tx 的仓储
+func (r *SparePartInventoryRepository) WithTx(tx *gorm.DB) *SparePartInventoryRepository {
+	return &SparePartInventoryRepository{db: tx}
+}
+
 func (r *SparePartInventoryRepository) GetByPartAndFactory(partID, factoryID uint) (*model.SparePartInventory, error) {
 	var inv model.SparePartInventory
 	err := r.db.Where("spare_part_id = ? AND factory_id = ?", partID, factoryID).
@@ -94,6 +108,17 @@ func (r *SparePartInventoryRepository) GetByPartAndFactory(partID, factoryID uin
 	return &inv, nil
 }
 
+// LockByPartAndFactory 行锁定库存记录，用于预留时的可用量检查
+func (r *SparePartInventoryRepository) LockByPartAndFactory(partID, factoryID uint) (*model.SparePartInventory, error) {
+	var inv model.SparePartInventory
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("spare_part_id = ? AND factory_id = ?", partID, factoryID).First(&inv).Error
+	if err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
 func (r *SparePartInventoryRepository) List(filter InventoryFilter) ([]model.SparePartInventory, int64, error) {
 	var inventories []model.SparePartInventory
 	var total int64
@@ -201,6 +226,11 @@ func NewSparePartConsumptionRepository() *SparePartConsumptionRepository {
 	return &SparePartConsumptionRepository{db: DB}
 }
 
+// WithTx 返回绑定到事务 tx 的仓储
+func (r *SparePartConsumptionRepository) WithTx(tx *gorm.DB) *SparePartConsumptionRepository {
+	return &SparePartConsumptionRepository{db: tx}
+}
+
 func (r *SparePartConsumptionRepository) Create(consumption *model.SparePartConsumption) error {
 	return r.db.Create(consumption).Error
 }
@@ -287,6 +317,11 @@ func NewSparePartTransactionRepository() *SparePartTransactionRepository {
 	return &SparePartTransactionRepository{db: DB}
 }
 
+// WithTx 返回绑定到事务 tx 的仓储
+func (r *SparePartTransactionRepository) WithTx(tx *gorm.DB) *SparePartTransactionRepository {
+	return &SparePartTransactionRepository{db: tx}
+}
+
 func (r *SparePartTransactionRepository) Create(tx *model.SparePartTransaction) error {
 	return r.db.Create(tx).Error
 }
@@ -324,12 +359,77 @@ func (r *SparePartTransactionRepository) List(filter TransactionFilter) ([]model
 	return txs, total, err
 }
 
+// SparePartReservation Repository
+type SparePartReservationRepository struct {
+	db *gorm.DB
+}
+
+func NewSparePartReservationRepository() *SparePartReservationRepository {
+	return &SparePartReservationRepository{db: DB}
+}
+
+// WithTx 返回绑定到事务 tx 的仓储
+func (r *SparePartReservationRepository) WithTx(tx *gorm.DB) *SparePartReservationRepository {
+	return &SparePartReservationRepository{db: tx}
+}
+
+func (r *SparePartReservationRepository) Create(reservation *model.SparePartReservation) error {
+	return r.db.Create(reservation).Error
+}
+
+func (r *SparePartReservationRepository) GetByID(id uint) (*model.SparePartReservation, error) {
+	var reservation model.SparePartReservation
+	if err := r.db.Preload("SparePart").First(&reservation, id).Error; err != nil {
+		return nil, err
+	}
+	return &reservation, nil
+}
+
+// Release 释放仍有效的预留，已领用、已释放或已过期时返回 false
+func (r *SparePartReservationRepository) Release(id uint, now time.Time) (bool, error) {
+	res := r.db.Model(&model.SparePartReservation{}).
+		Where("id = ? AND status = ? AND expires_at > ?", id, model.ReservationActive, now).
+		Update("status", model.ReservationReleased)
+	return res.RowsAffected > 0, res.Error
+}
+
+// ReservedQuantity 统计仍有效的预留数量；orderID/taskID 非空时不计该工单自己的预留
+func (r *SparePartReservationRepository) ReservedQuantity(partID, factoryID uint, now time.Time, orderID, taskID *uint) (int, error) {
+	var total int64
+	query := r.db.Model(&model.SparePartReservation{}).
+		Where("spare_part_id = ? AND factory_id = ? AND status = ? AND expires_at > ?", partID, factoryID, model.ReservationActive, now)
+	if orderID != nil {
+		query = query.Where("repair_order_id IS NULL OR repair_order_id <> ?", *orderID)
+	}
+	if taskID != nil {
+		query = query.Where("maintenance_task_id IS NULL OR maintenance_task_id <> ?", *taskID)
+	}
+	err := query.Select("COALESCE(SUM(quantity), 0)").Scan(&total).Error
+	return int(total), err
+}
+
+// MarkIssued 工单出库后，将其对该备件的有效预留标记为已领用
+func (r *SparePartReservationRepository) MarkIssued(partID, factoryID uint, now time.Time, orderID, taskID *uint) error {
+	if orderID == nil && taskID == nil {
+		return nil
+	}
+	query := r.db.Model(&model.SparePartReservation{}).
+		Where("spare_part_id = ? AND factory_id = ? AND status = ? AND expires_at > ?", partID, factoryID, model.ReservationActive, now)
+	if orderID != nil {
+		query = query.Where("repair_order_id = ?", *orderID)
+	} else {
+		query = query.Where("maintenance_task_id = ?", *taskID)
+	}
+	return query.Update("status", model.ReservationIssued).Error
+}
+
 // Filter types
 type SparePartFilter struct {
-	Code     string
-	Name     string
-	Page     int
-	PageSize int
+	Code      string
+	Name      string
+	FactoryID *uint // 本工厂与未指定工厂的通用备件
+	Page      int
+	PageSize  int
 }
 
 type InventoryFilter struct {
@@ -0,0 +1,79 @@
+package scheduler
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"hash/fnv"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// PostgresLocker 基于 Postgres 会话级 advisory lock；锁与数据库连接绑定，
+// 进程崩溃或连接断开时自动释放，因此忽略 ttl
+type PostgresLocker struct {
+	db *sql.DB
+}
+
+func NewPostgresLocker(db *sql.DB) *PostgresLocker {
+	return &PostgresLocker{db: db}
+}
+
+// advisoryKey 将锁名映射为 advisory lock 的 bigint 键
+func advisoryKey(key string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return int64(h.Sum64())
+}
+
+func (l *PostgresLocker) TryLock(ctx context.Context, key string, _ time.Duration) (func(), bool, error) {
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	id := advisoryKey(key)
+	var ok bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", id).Scan(&ok); err != nil || !ok {
+		conn.Close()
+		return nil, false, err
+	}
+	unlock := func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", id); err != nil {
+			log.Printf("[Scheduler] Failed to release advisory lock %s: %v", key, err)
+		}
+		conn.Close()
+	}
+	return unlock, true, nil
+}
+
+// RedisLocker 基于 SET NX PX 的 Redis 锁；只释放自己持有的锁，进程崩溃时在 ttl 后过期
+type RedisLocker struct {
+	client *redis.Client
+}
+
+func NewRedisLocker(client *redis.Client) *RedisLocker {
+	return &RedisLocker{client: client}
+}
+
+var redisUnlockScript = redis.NewScript(`if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) end return 0`)
+
+func (l *RedisLocker) TryLock(ctx context.Context, key string, ttl time.Duration) (func(), bool, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, false, err
+	}
+	token := hex.EncodeToString(buf)
+	ok, err := l.client.SetNX(ctx, key, token, ttl).Result()
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	unlock := func() {
+		if err := redisUnlockScript.Run(context.Background(), l.client, []string{key}, token).Err(); err != nil {
+			log.Printf("[Scheduler] Failed to release redis lock %s: %v", key, err)
+		}
+	}
+	return unlock, true, nil
+}
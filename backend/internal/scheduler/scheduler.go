@@ -0,0 +1,346 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ems/backend/internal/model"
+	"github.com/ems/backend/pkg/cron"
+	"gorm.io/gorm"
+)
+
+// Store 调度状态与执行记录的持久化接口，由 repository.SchedulerRepository 实现
+type Store interface {
+	GetJob(name string) (*model.ScheduledJob, error) // 不存在时返回 gorm.ErrRecordNotFound
+	SaveJob(job *model.ScheduledJob) error
+	// UpdateJobRun 只写执行结果与下次触发时间，run 或 next 为 nil 时不更新对应列；
+	// 不回写整条状态，以免覆盖执行期间的暂停等修改
+	UpdateJobRun(name string, run *model.JobRun, next *time.Time) error
+	// UpdateJobPaused 只写暂停状态，next 不为 nil 时同时重新排期
+	UpdateJobPaused(name string, paused bool, next *time.Time) error
+	CreateRun(run *model.JobRun) error
+}
+
+// Locker 分布式锁；锁已被其他实例持有时 ok 为 false
+type Locker interface {
+	TryLock(ctx context.Context, key string, ttl time.Duration) (unlock func(), ok bool, err error)
+}
+
+const (
+	// MisfireRunOnce 停机等原因错过的触发补跑一次，随后按 cron 重新排期
+	MisfireRunOnce = "run_once"
+	// MisfireSkip 错过的触发不补跑，只记录一条 skipped 记录
+	MisfireSkip = "skip"
+
+	TriggerSchedule = "schedule"
+	TriggerMisfire  = "misfire"
+	TriggerManual   = "manual"
+
+	StatusSuccess = "success"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+
+	defaultTickInterval = 20 * time.Second
+	defaultTimeout      = 10 * time.Minute
+	// misfireThreshold 触发时间已过去超过该时长视为错过（正常情况下最多延迟一个 tick）
+	misfireThreshold = time.Minute
+	lockPrefix       = "ems:scheduler:"
+	maxTextLength    = 2000
+)
+
+var (
+	ErrUnknownJob = errors.New("unknown job")
+	ErrJobRunning = errors.New("job is already running")
+)
+
+// Func 任务函数，返回执行摘要
+type Func func(ctx context.Context) (string, error)
+
+// Job 注册的任务定义
+type Job struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Cron        string        `json:"cron"`
+	Misfire     string        `json:"misfire"`
+	Timeout     time.Duration `json:"-"`
+	Run         Func          `json:"-"`
+
+	schedule *cron.Schedule
+}
+
+// Scheduler 按 cron 执行注册的任务。调度状态保存在数据库中由多副本共享，
+// 每次执行前获取以任务名为键的分布式锁，保证同一触发只有一个实例执行
+type Scheduler struct {
+	store  Store
+	locker Locker
+
+	mu   sync.RWMutex
+	jobs map[string]*Job
+
+	Instance     string
+	TickInterval time.Duration
+
+	now func() time.Time
+}
+
+func New(store Store, locker Locker) *Scheduler {
+	host, _ := os.Hostname()
+	return &Scheduler{
+		store:        store,
+		locker:       locker,
+		jobs:         map[string]*Job{},
+		Instance:     fmt.Sprintf("%s/%d", host, os.Getpid()),
+		TickInterval: defaultTickInterval,
+		now:          time.Now,
+	}
+}
+
+// Register 注册任务；cron 表达式非法、错过策略未知或重名时返回错误
+func (s *Scheduler) Register(job Job) error {
+	schedule, err := cron.Parse(job.Cron)
+	if err != nil {
+		return fmt.Errorf("job %s: %w", job.Name, err)
+	}
+	switch job.Misfire {
+	case "":
+		job.Misfire = MisfireRunOnce
+	case MisfireRunOnce, MisfireSkip:
+	default:
+		return fmt.Errorf("job %s: unknown misfire policy %q", job.Name, job.Misfire)
+	}
+	if job.Timeout <= 0 {
+		job.Timeout = defaultTimeout
+	}
+	job.schedule = schedule
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[job.Name]; ok {
+		return fmt.Errorf("job %s already registered", job.Name)
+	}
+	s.jobs[job.Name] = &job
+	return nil
+}
+
+// Jobs 返回按名称排序的任务定义
+func (s *Scheduler) Jobs() []Job {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	jobs := make([]Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, *job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Name < jobs[j].Name })
+	return jobs
+}
+
+func (s *Scheduler) job(name string) (*Job, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	job, ok := s.jobs[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownJob, name)
+	}
+	return job, nil
+}
+
+// Sync 将注册的任务写入调度状态：新任务按 cron 排期，表达式变化时从当前时间重新排期
+func (s *Scheduler) Sync() error {
+	now := s.now()
+	for _, job := range s.Jobs() {
+		state, err := s.store.GetJob(job.Name)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			state, err = &model.ScheduledJob{Name: job.Name}, nil
+		}
+		if err != nil {
+			return err
+		}
+		if state.ID != 0 && state.Cron == job.Cron && state.NextRunAt != nil {
+			continue
+		}
+		next := job.schedule.Next(now)
+		state.Cron, state.NextRunAt = job.Cron, &next
+		if err := s.store.SaveJob(state); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run 同步调度状态后按 TickInterval 检查到期任务，直到 ctx 结束
+func (s *Scheduler) Run(ctx context.Context) {
+	if err := s.Sync(); err != nil {
+		log.Printf("[Scheduler] Failed to sync jobs: %v", err)
+	}
+	ticker := time.NewTicker(s.TickInterval)
+	defer ticker.Stop()
+	log.Printf("[Scheduler] Started as %s with %d jobs", s.Instance, len(s.Jobs()))
+
+	for {
+		// 不等待本轮任务结束，长任务不会推迟其他任务的触发
+		s.startDue(ctx)
+		select {
+		case <-ctx.Done():
+			log.Printf("[Scheduler] Stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick 并发执行到期的任务并等待其结束
+func (s *Scheduler) Tick(ctx context.Context) {
+	s.startDue(ctx).Wait()
+}
+
+// startDue 每个任务在各自的 goroutine 中检查并执行，互不阻塞；
+// 上一次执行尚未结束的任务拿不到锁，不会重复执行
+func (s *Scheduler) startDue(ctx context.Context) *sync.WaitGroup {
+	var wg sync.WaitGroup
+	for _, job := range s.Jobs() {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			if err := s.runDue(ctx, name); err != nil {
+				log.Printf("[Scheduler] Job %s: %v", name, err)
+			}
+		}(job.Name)
+	}
+	return &wg
+}
+
+func due(state *model.ScheduledJob, now time.Time) bool {
+	return !state.Paused && state.NextRunAt != nil && !state.NextRunAt.After(now)
+}
+
+func (s *Scheduler) runDue(ctx context.Context, name string) error {
+	job, err := s.job(name)
+	if err != nil {
+		return err
+	}
+	state, err := s.store.GetJob(name)
+	if err != nil || !due(state, s.now()) {
+		return err
+	}
+	unlock, ok, err := s.locker.TryLock(ctx, lockPrefix+name, job.Timeout+time.Minute)
+	if err != nil || !ok {
+		return err
+	}
+	defer unlock()
+
+	// 持锁后重新读取：其他实例可能刚执行完并已重新排期
+	state, err = s.store.GetJob(name)
+	now := s.now()
+	if err != nil || !due(state, now) {
+		return err
+	}
+	trigger := TriggerSchedule
+	if now.Sub(*state.NextRunAt) > misfireThreshold {
+		trigger = TriggerMisfire
+	}
+	var run *model.JobRun
+	if trigger == TriggerMisfire && job.Misfire == MisfireSkip {
+		skipped := &model.JobRun{
+			JobName: name, Trigger: trigger, Status: StatusSkipped, Instance: s.Instance, StartedAt: now, FinishedAt: now,
+			Result: "missed run at " + state.NextRunAt.Format(time.RFC3339),
+		}
+		if err := s.store.CreateRun(skipped); err != nil {
+			log.Printf("[Scheduler] Failed to record run of %s: %v", name, err)
+		}
+	} else {
+		run = s.execute(ctx, job, trigger)
+	}
+	// 从当前时间排期，停机期间错过的多次触发最多补跑一次
+	next := job.schedule.Next(s.now())
+	return s.store.UpdateJobRun(name, run, &next)
+}
+
+// execute 在超时内执行任务并记录结果，任务 panic 视为失败
+func (s *Scheduler) execute(ctx context.Context, job *Job, trigger string) *model.JobRun {
+	run := &model.JobRun{JobName: job.Name, Trigger: trigger, Instance: s.Instance, StartedAt: s.now()}
+	runCtx, cancel := context.WithTimeout(ctx, job.Timeout)
+	defer cancel()
+
+	result, err := safeRun(runCtx, job.Run)
+	run.FinishedAt = s.now()
+	run.DurationMs = run.FinishedAt.Sub(run.StartedAt).Milliseconds()
+	run.Status, run.Result = StatusSuccess, truncate(result)
+	if err != nil {
+		run.Status, run.Error = StatusFailed, truncate(err.Error())
+		log.Printf("[Scheduler] Job %s failed after %dms: %v", job.Name, run.DurationMs, err)
+	}
+	if err := s.store.CreateRun(run); err != nil {
+		log.Printf("[Scheduler] Failed to record run of %s: %v", job.Name, err)
+	}
+	return run
+}
+
+func safeRun(ctx context.Context, fn Func) (result string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn(ctx)
+}
+
+func truncate(s string) string {
+	if len(s) > maxTextLength {
+		return s[:maxTextLength]
+	}
+	return s
+}
+
+// Trigger 立即在后台执行一次任务（暂停的任务也可执行），不影响排期；
+// 任务正在本实例或其他实例执行时返回 ErrJobRunning
+func (s *Scheduler) Trigger(ctx context.Context, name string) error {
+	job, err := s.job(name)
+	if err != nil {
+		return err
+	}
+	unlock, ok, err := s.locker.TryLock(ctx, lockPrefix+name, job.Timeout+time.Minute)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrJobRunning
+	}
+	go func() {
+		defer unlock()
+		run := s.execute(context.Background(), job, TriggerManual)
+		if err := s.store.UpdateJobRun(name, run, nil); err != nil {
+			log.Printf("[Scheduler] Failed to update state of %s: %v", name, err)
+		}
+	}()
+	return nil
+}
+
+// SetPaused 暂停或恢复任务；恢复时从当前时间重新排期，暂停期间的触发不补跑
+func (s *Scheduler) SetPaused(name string, paused bool) (*model.ScheduledJob, error) {
+	job, err := s.job(name)
+	if err != nil {
+		return nil, err
+	}
+	state, err := s.store.GetJob(name)
+	if err != nil {
+		return nil, err
+	}
+	if state.Paused == paused {
+		return state, nil
+	}
+	var next *time.Time
+	if !paused {
+		t := job.schedule.Next(s.now())
+		next = &t
+	}
+	if err := s.store.UpdateJobPaused(name, paused, next); err != nil {
+		return nil, err
+	}
+	return s.store.GetJob(name)
+}
@@ -0,0 +1,313 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ems/backend/internal/model"
+	"gorm.io/gorm"
+)
+
+type memoryStore struct {
+	mu   sync.Mutex
+	jobs map[string]model.ScheduledJob
+	runs []model.JobRun
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{jobs: map[string]model.ScheduledJob{}}
+}
+
+func (m *memoryStore) GetJob(name string) (*model.ScheduledJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[name]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &job, nil
+}
+
+func (m *memoryStore) SaveJob(job *model.ScheduledJob) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if job.ID == 0 {
+		job.ID = uint(len(m.jobs) + 1)
+	}
+	m.jobs[job.Name] = *job
+	return nil
+}
+
+func (m *memoryStore) UpdateJobRun(name string, run *model.JobRun, next *time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job := m.jobs[name]
+	if run != nil {
+		job.LastRunAt, job.LastStatus = &run.StartedAt, run.Status
+		job.LastError, job.LastDurationMs = run.Error, run.DurationMs
+	}
+	if next != nil {
+		job.NextRunAt = next
+	}
+	m.jobs[name] = job
+	return nil
+}
+
+func (m *memoryStore) UpdateJobPaused(name string, paused bool, next *time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job := m.jobs[name]
+	job.Paused = paused
+	if next != nil {
+		job.NextRunAt = next
+	}
+	m.jobs[name] = job
+	return nil
+}
+
+func (m *memoryStore) CreateRun(run *model.JobRun) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.runs = append(m.runs, *run)
+	return nil
+}
+
+func (m *memoryStore) Runs() []model.JobRun {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]model.JobRun(nil), m.runs...)
+}
+
+// memoryLocker 模拟多个实例共享的锁
+type memoryLocker struct {
+	mu   sync.Mutex
+	held map[string]bool
+}
+
+func (l *memoryLocker) TryLock(_ context.Context, key string, _ time.Duration) (func(), bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.held == nil {
+		l.held = map[string]bool{}
+	}
+	if l.held[key] {
+		return nil, false, nil
+	}
+	l.held[key] = true
+	return func() {
+		l.mu.Lock()
+		delete(l.held, key)
+		l.mu.Unlock()
+	}, true, nil
+}
+
+func newTestScheduler(t *testing.T, store *memoryStore, locker *memoryLocker, clock *time.Time, job Job) *Scheduler {
+	t.Helper()
+	s := New(store, locker)
+	s.now = func() time.Time { return *clock }
+	if err := s.Register(job); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSchedulerRunsDueJobOnce(t *testing.T) {
+	store, locker := newMemoryStore(), &memoryLocker{}
+	clock := time.Date(2026, 5, 1, 8, 0, 30, 0, time.UTC)
+	calls := 0
+	job := Job{Name: "overdue", Cron: "*/10 * * * *", Run: func(context.Context) (string, error) {
+		calls++
+		return "3 tasks", nil
+	}}
+	a := newTestScheduler(t, store, locker, &clock, job)
+	b := newTestScheduler(t, store, locker, &clock, job)
+
+	a.Tick(context.Background())
+	if calls != 0 {
+		t.Fatalf("Expected no run before 08:10, got %d", calls)
+	}
+	clock = time.Date(2026, 5, 1, 8, 10, 5, 0, time.UTC)
+	a.Tick(context.Background())
+	b.Tick(context.Background())
+	if calls != 1 {
+		t.Fatalf("Expected exactly one run across instances, got %d", calls)
+	}
+	runs := store.Runs()
+	if len(runs) != 1 || runs[0].Trigger != TriggerSchedule || runs[0].Status != StatusSuccess || runs[0].Result != "3 tasks" {
+		t.Errorf("Unexpected run history %+v", runs)
+	}
+	state, _ := store.GetJob("overdue")
+	if want := time.Date(2026, 5, 1, 8, 20, 0, 0, time.UTC); !state.NextRunAt.Equal(want) || state.LastStatus != StatusSuccess {
+		t.Errorf("Expected next run %s and last status success, got %+v", want, state)
+	}
+}
+
+func TestSchedulerSkipsWhileLocked(t *testing.T) {
+	store, locker := newMemoryStore(), &memoryLocker{}
+	clock := time.Date(2026, 5, 1, 8, 0, 0, 0, time.UTC)
+	calls := 0
+	s := newTestScheduler(t, store, locker, &clock, Job{Name: "decay", Cron: "@hourly", Run: func(context.Context) (string, error) {
+		calls++
+		return "", nil
+	}})
+	unlock, _, _ := locker.TryLock(context.Background(), lockPrefix+"decay", time.Minute)
+	clock = clock.Add(time.Hour)
+	s.Tick(context.Background())
+	if calls != 0 {
+		t.Fatalf("Expected job held by another instance not to run")
+	}
+	if err := s.Trigger(context.Background(), "decay"); !errors.Is(err, ErrJobRunning) {
+		t.Errorf("Expected ErrJobRunning, got %v", err)
+	}
+	unlock()
+	s.Tick(context.Background())
+	if calls != 1 {
+		t.Errorf("Expected job to run after the lock is released, got %d", calls)
+	}
+}
+
+func TestSchedulerMisfire(t *testing.T) {
+	for _, policy := range []string{MisfireRunOnce, MisfireSkip} {
+		store, locker := newMemoryStore(), &memoryLocker{}
+		clock := time.Date(2026, 5, 1, 8, 0, 0, 0, time.UTC)
+		calls := 0
+		s := newTestScheduler(t, store, locker, &clock, Job{Name: "scan", Cron: "@hourly", Misfire: policy, Run: func(context.Context) (string, error) {
+			calls++
+			return "", nil
+		}})
+		// 停机 5 小时后恢复，只处理一次错过的触发
+		clock = clock.Add(5*time.Hour + 10*time.Minute)
+		s.Tick(context.Background())
+		s.Tick(context.Background())
+
+		runs := store.Runs()
+		if len(runs) != 1 || runs[0].Trigger != TriggerMisfire {
+			t.Fatalf("%s: expected a single misfire run, got %+v", policy, runs)
+		}
+		wantCalls, wantStatus := 1, StatusSuccess
+		if policy == MisfireSkip {
+			wantCalls, wantStatus = 0, StatusSkipped
+		}
+		if calls != wantCalls || runs[0].Status != wantStatus {
+			t.Errorf("%s: expected %d calls and status %s, got %d and %s", policy, wantCalls, wantStatus, calls, runs[0].Status)
+		}
+		state, _ := store.GetJob("scan")
+		if want := time.Date(2026, 5, 1, 14, 0, 0, 0, time.UTC); !state.NextRunAt.Equal(want) {
+			t.Errorf("%s: expected next run %s, got %s", policy, want, state.NextRunAt)
+		}
+	}
+}
+
+func TestSchedulerPauseAndFailures(t *testing.T) {
+	store, locker := newMemoryStore(), &memoryLocker{}
+	clock := time.Date(2026, 5, 1, 8, 0, 0, 0, time.UTC)
+	s := newTestScheduler(t, store, locker, &clock, Job{Name: "flaky", Cron: "* * * * *", Run: func(context.Context) (string, error) {
+		panic("boom")
+	}})
+	if _, err := s.SetPaused("flaky", true); err != nil {
+		t.Fatal(err)
+	}
+	clock = clock.Add(10 * time.Minute)
+	s.Tick(context.Background())
+	if len(store.Runs()) != 0 {
+		t.Fatalf("Expected paused job not to run")
+	}
+
+	state, err := s.SetPaused("flaky", false)
+	if err != nil || !state.NextRunAt.Equal(clock.Add(time.Minute)) {
+		t.Fatalf("Expected resume to reschedule from now, got %+v, %v", state, err)
+	}
+	clock = clock.Add(time.Minute)
+	s.Tick(context.Background())
+	runs := store.Runs()
+	if len(runs) != 1 || runs[0].Status != StatusFailed || runs[0].Error != "panic: boom" {
+		t.Errorf("Expected panic to be recorded as a failed run, got %+v", runs)
+	}
+	if _, err := s.SetPaused("missing", true); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("Expected ErrUnknownJob, got %v", err)
+	}
+}
+
+func TestSchedulerKeepsPauseMadeDuringRun(t *testing.T) {
+	store, locker := newMemoryStore(), &memoryLocker{}
+	clock := time.Date(2026, 5, 1, 8, 0, 0, 0, time.UTC)
+	var s *Scheduler
+	s = newTestScheduler(t, store, locker, &clock, Job{Name: "report", Cron: "@hourly", Run: func(context.Context) (string, error) {
+		// 执行期间管理员暂停了任务
+		if _, err := s.SetPaused("report", true); err != nil {
+			return "", err
+		}
+		return "sent", nil
+	}})
+	clock = clock.Add(time.Hour)
+	s.Tick(context.Background())
+
+	state, _ := store.GetJob("report")
+	if !state.Paused || state.LastStatus != StatusSuccess {
+		t.Fatalf("Expected the pause to survive the run and the result to be recorded, got %+v", state)
+	}
+	clock = clock.Add(time.Hour)
+	s.Tick(context.Background())
+	if runs := store.Runs(); len(runs) != 1 {
+		t.Errorf("Expected the paused job not to run again, got %d runs", len(runs))
+	}
+}
+
+func TestSchedulerRunsDueJobsConcurrently(t *testing.T) {
+	store, locker := newMemoryStore(), &memoryLocker{}
+	clock := time.Date(2026, 5, 1, 8, 0, 0, 0, time.UTC)
+	quickDone := make(chan struct{})
+	slow := Job{Name: "a_slow", Cron: "@hourly", Run: func(context.Context) (string, error) {
+		// 顺序执行时 b_quick 要等本任务结束才开始，这里会超时
+		select {
+		case <-quickDone:
+			return "", nil
+		case <-time.After(2 * time.Second):
+			return "", errors.New("b_quick did not run while a_slow was running")
+		}
+	}}
+	s := newTestScheduler(t, store, locker, &clock, slow)
+	if err := s.Register(Job{Name: "b_quick", Cron: "@hourly", Run: func(context.Context) (string, error) {
+		close(quickDone)
+		return "", nil
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	clock = clock.Add(time.Hour)
+	s.Tick(context.Background())
+
+	for _, run := range store.Runs() {
+		if run.Status != StatusSuccess {
+			t.Errorf("Expected %s to succeed, got %s: %s", run.JobName, run.Status, run.Error)
+		}
+	}
+	if runs := store.Runs(); len(runs) != 2 {
+		t.Errorf("Expected both jobs to run, got %+v", runs)
+	}
+}
+
+func TestRegisterValidation(t *testing.T) {
+	s := New(newMemoryStore(), &memoryLocker{})
+	noop := func(context.Context) (string, error) { return "", nil }
+	if err := s.Register(Job{Name: "bad", Cron: "every minute", Run: noop}); err == nil {
+		t.Errorf("Expected invalid cron to be rejected")
+	}
+	if err := s.Register(Job{Name: "bad", Cron: "@daily", Misfire: "later", Run: noop}); err == nil {
+		t.Errorf("Expected unknown misfire policy to be rejected")
+	}
+	if err := s.Register(Job{Name: "ok", Cron: "@daily", Run: noop}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Register(Job{Name: "ok", Cron: "@daily", Run: noop}); err == nil {
+		t.Errorf("Expected duplicate job name to be rejected")
+	}
+}
@@ -0,0 +1,450 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ems/backend/internal/agent/pushfilter"
+	"github.com/ems/backend/internal/event"
+	"github.com/ems/backend/internal/model"
+	"github.com/ems/backend/internal/repository"
+	"github.com/ems/backend/pkg/config"
+	"gorm.io/gorm"
+)
+
+// 告警严重度
+const (
+	SeverityLow      = "low"
+	SeverityMedium   = "medium"
+	SeverityHigh     = "high"
+	SeverityCritical = "critical"
+)
+
+// 告警进入摘要队列的原因
+const (
+	digestLowSeverity = "low_severity"
+	digestQuietHours  = "quiet_hours"
+)
+
+const (
+	defaultAlertListLimit = 100
+	defaultQuietHoursZone = "Asia/Shanghai"
+)
+
+// repairAlertTypes 维修单关闭时自动解决的设备告警
+var repairAlertTypes = []string{string(event.RepairCreated), string(event.RepairSLABreached), string(event.InspectionNGDetected)}
+
+// AlertManager 位于领域事件与通知渠道之间：按指纹聚合告警、抑制重复通知、
+// 将低级别告警合并为摘要，并遵守用户免打扰时段（紧急告警除外）
+type AlertManager struct {
+	alertRepo *repository.AlertRepository
+	userRepo  *repository.UserRepository
+	notifySvc *NotificationService
+	escalSvc  *EscalationService
+	cfg       config.AlertConfig
+}
+
+func NewAlertManager() *AlertManager {
+	m := &AlertManager{
+		alertRepo: repository.NewAlertRepository(),
+		userRepo:  repository.NewUserRepository(),
+		notifySvc: NewNotificationService(),
+		escalSvc:  NewEscalationService(),
+	}
+	if config.Cfg != nil {
+		m.cfg = config.Cfg.Notify.Alert
+	}
+	return m
+}
+
+// alertFingerprint 事件类型 + 设备；无设备的事件（如低库存）按工厂与负载中的业务对象区分
+func alertFingerprint(e event.Event) string {
+	if e.EquipmentID > 0 {
+		return fmt.Sprintf("%s/equipment:%d", e.Type, e.EquipmentID)
+	}
+	var ids struct {
+		SparePartID uint `json:"spare_part_id"`
+		TaskID      uint `json:"task_id"`
+		OrderID     uint `json:"order_id"`
+	}
+	_ = json.Unmarshal(e.Payload, &ids)
+	factory := "all"
+	if e.FactoryID != nil {
+		factory = fmt.Sprint(*e.FactoryID)
+	}
+	switch {
+	case ids.SparePartID > 0:
+		return fmt.Sprintf("%s/factory:%s/spare_part:%d", e.Type, factory, ids.SparePartID)
+	case ids.TaskID > 0:
+		return fmt.Sprintf("%s/factory:%s/task:%d", e.Type, factory, ids.TaskID)
+	case ids.OrderID > 0:
+		return fmt.Sprintf("%s/factory:%s/order:%d", e.Type, factory, ids.OrderID)
+	}
+	return fmt.Sprintf("%s/factory:%s", e.Type, factory)
+}
+
+// alertSeverity 事件的告警严重度；critical 不受免打扰限制，low 合并为摘要
+func alertSeverity(e event.Event) string {
+	switch e.Type {
+	case event.RepairCreated:
+		var p event.RepairCreatedPayload
+		if e.Decode(&p) == nil {
+			return prioritySeverity(p.Priority, SeverityHigh)
+		}
+	case event.RepairSLABreached:
+		var p event.RepairSLABreachedPayload
+		if e.Decode(&p) == nil {
+			return prioritySeverity(p.Priority, SeverityCritical)
+		}
+	case event.InspectionNGDetected:
+		var p event.InspectionNGDetectedPayload
+		if e.Decode(&p) == nil && len(p.Items) >= 3 {
+			return SeverityHigh
+		}
+		return SeverityMedium
+	case event.SparePartBelowSafetyStock:
+		var p event.SparePartBelowSafetyStockPayload
+		if e.Decode(&p) == nil && p.Quantity <= 0 {
+			return SeverityHigh
+		}
+	case event.EquipmentRULRisk:
+		var p event.EquipmentRULRiskPayload
+		if e.Decode(&p) == nil && p.EstimatedRULDays <= 3 {
+			return SeverityCritical
+		}
+		return SeverityHigh
+	}
+	return SeverityLow
+}
+
+// prioritySeverity 优先级 1 为 top，此后每降一级严重度降一档
+func prioritySeverity(priority int, top string) string {
+	rank := severityRank(top) - (priority - 1)
+	if priority < 1 || rank < 1 {
+		rank = 1
+	}
+	return pushfilter.Severities[rank-1]
+}
+
+func severityRank(s string) int {
+	for i, v := range pushfilter.Severities {
+		if v == s {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// shouldRenotify 已存在的活动告警再次触发时是否重新通知：严重度升级时总是通知，
+// 已确认的告警不再通知，否则距上次通知超过抑制窗口才通知
+func shouldRenotify(alert *model.Alert, severity string, now time.Time, window time.Duration) bool {
+	if severityRank(severity) > severityRank(alert.Severity) {
+		return true
+	}
+	if alert.Status == model.AlertAcknowledged {
+		return false
+	}
+	return alert.LastNotifiedAt == nil || now.Sub(*alert.LastNotifiedAt) >= window
+}
+
+// Handle 事件总线订阅者：记录告警并按需通知；维修单关闭时解决设备上的维修类告警
+func (m *AlertManager) Handle(ctx context.Context, e event.Event) error {
+	if e.Type == event.RepairStatusChanged {
+		return m.resolveForRepair(e)
+	}
+	now := time.Now()
+	alert, notify, err := m.record(e, now)
+	if err != nil {
+		return err
+	}
+	// 同一告警只开始一次升级，事件重试时保持幂等
+	if alert.Status == model.AlertFiring {
+		if err := m.escalSvc.StartForAlert(alert); err != nil {
+			return err
+		}
+	}
+	if !notify {
+		return nil
+	}
+	recipients, err := m.userRepo.ListActiveByRoles(alertRoles, e.FactoryID)
+	if err != nil {
+		return err
+	}
+	for _, user := range recipients {
+		// 逐人发送失败只记录日志，避免重试时重复打扰已收到的用户
+		if err := m.dispatch(ctx, user, alert, e, now); err != nil {
+			log.Printf("[AlertManager] Failed to notify user %d of alert %d: %v", user.ID, alert.ID, err)
+		}
+	}
+	return nil
+}
+
+// record 按指纹合并到活动告警，返回告警及是否需要通知。
+// 并发创建同一指纹时唯一索引冲突会使事件稍后重试，届时合并到已创建的告警
+func (m *AlertManager) record(e event.Event, now time.Time) (*model.Alert, bool, error) {
+	fingerprint, severity := alertFingerprint(e), alertSeverity(e)
+	envelope, _ := json.Marshal(e)
+	var alert *model.Alert
+	notify := false
+	err := repository.DB.Transaction(func(tx *gorm.DB) error {
+		repo := m.alertRepo.WithTx(tx)
+		existing, err := repo.LockActive(fingerprint)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			alert = &model.Alert{
+				Fingerprint: fingerprint, ActiveKey: &fingerprint, EventType: string(e.Type), FactoryID: e.FactoryID,
+				EquipmentID: e.EquipmentID, Severity: severity, Status: model.AlertFiring, Count: 1,
+				LastEventID: e.ID, Event: string(envelope), FirstSeenAt: now, LastSeenAt: now, LastNotifiedAt: &now,
+			}
+			notify = true
+			return repo.Create(alert)
+		}
+		if err != nil {
+			return err
+		}
+		notify = shouldRenotify(existing, severity, now, m.cfg.SuppressWindow())
+		existing.Count++
+		existing.LastEventID, existing.Event, existing.LastSeenAt = e.ID, string(envelope), now
+		if severityRank(severity) > severityRank(existing.Severity) {
+			existing.Severity = severity
+		}
+		if notify {
+			existing.LastNotifiedAt = &now
+		}
+		alert = existing
+		return repo.Save(existing)
+	})
+	return alert, notify, err
+}
+
+// dispatch 低级别告警进入摘要；免打扰时段内非紧急告警延后到摘要；其余立即通知
+func (m *AlertManager) dispatch(ctx context.Context, user model.User, alert *model.Alert, e event.Event, now time.Time) error {
+	switch {
+	case alert.Severity == SeverityLow:
+		return m.alertRepo.EnqueueDigest(user.ID, alert.ID, digestLowSeverity)
+	case alert.Severity != SeverityCritical && m.inQuietHours(user.ID, now):
+		return m.alertRepo.EnqueueDigest(user.ID, alert.ID, digestQuietHours)
+	}
+	return m.notifySvc.NotifyUser(ctx, user, e)
+}
+
+func quietWindow(q *model.NotificationQuietHours) (*pushfilter.TimeWindow, error) {
+	tz := q.Timezone
+	if tz == "" {
+		tz = defaultQuietHoursZone
+	}
+	return pushfilter.CompileWindow(pushfilter.Window{Start: q.Start, End: q.End, Timezone: tz})
+}
+
+func (m *AlertManager) inQuietHours(userID uint, now time.Time) bool {
+	q, err := m.alertRepo.GetQuietHours(userID)
+	if err != nil || !q.Enabled {
+		return false
+	}
+	w, err := quietWindow(q)
+	return err == nil && w.Contains(now)
+}
+
+// digestDue 免打扰延后的告警在免打扰结束后立即发送，低级别告警按摘要周期发送
+func digestDue(entries []model.AlertDigestEntry, now time.Time, interval time.Duration) bool {
+	for _, entry := range entries {
+		if entry.Reason == digestQuietHours || !entry.CreatedAt.After(now.Add(-interval)) {
+			return true
+		}
+	}
+	return false
+}
+
+// FlushDigests 向不在免打扰时段、且摘要已到期的用户发送摘要，返回发送的摘要数
+func (m *AlertManager) FlushDigests(ctx context.Context, now time.Time) (int, error) {
+	entries, err := m.alertRepo.ListPendingDigest()
+	if err != nil {
+		return 0, err
+	}
+	var users []uint
+	byUser := map[uint][]model.AlertDigestEntry{}
+	for _, entry := range entries {
+		if _, ok := byUser[entry.UserID]; !ok {
+			users = append(users, entry.UserID)
+		}
+		byUser[entry.UserID] = append(byUser[entry.UserID], entry)
+	}
+
+	sent := 0
+	for _, userID := range users {
+		pending := byUser[userID]
+		if !digestDue(pending, now, m.cfg.DigestInterval()) || m.inQuietHours(userID, now) {
+			continue
+		}
+		entryIDs, alertIDs := make([]uint, 0, len(pending)), make([]uint, 0, len(pending))
+		for _, entry := range pending {
+			entryIDs, alertIDs = append(entryIDs, entry.ID), append(alertIDs, entry.AlertID)
+		}
+		alerts, err := m.alertRepo.GetByIDs(alertIDs)
+		if err != nil {
+			return sent, err
+		}
+		// 已确认或已解决的告警不再打扰
+		var firing []model.Alert
+		for _, a := range alerts {
+			if a.Status == model.AlertFiring {
+				firing = append(firing, a)
+			}
+		}
+		// 发送失败时保留摘要待下次重试；用户已删除、停用或告警均已处理时直接标记为已发送
+		user, err := m.userRepo.GetByID(userID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("[AlertManager] Failed to load user %d for digest: %v", userID, err)
+			continue
+		}
+		if err == nil && user.IsActive && len(firing) > 0 {
+			if err := m.notifySvc.NotifyDigest(ctx, *user, firing); err != nil {
+				log.Printf("[AlertManager] Failed to send digest to user %d, will retry: %v", userID, err)
+				continue
+			}
+			sent++
+		}
+		if err := m.alertRepo.MarkDigestSent(entryIDs, now); err != nil {
+			return sent, err
+		}
+	}
+	return sent, nil
+}
+
+// ResolveStale 自动解决超过 resolve_hours 无新事件的活动告警
+func (m *AlertManager) ResolveStale(now time.Time) (int, error) {
+	alerts, err := m.alertRepo.ListStale(now.Add(-m.cfg.ResolveTimeout()))
+	if err != nil {
+		return 0, err
+	}
+	for i := range alerts {
+		if err := m.alertRepo.Save(resolveAlert(&alerts[i], nil, now)); err != nil {
+			return i, err
+		}
+	}
+	return len(alerts), nil
+}
+
+func (m *AlertManager) resolveForRepair(e event.Event) error {
+	var p event.RepairStatusChangedPayload
+	if err := e.Decode(&p); err != nil {
+		return err
+	}
+	if p.To != string(model.RepairClosed) || p.EquipmentID == 0 {
+		return nil
+	}
+	alerts, err := m.alertRepo.ListActiveByEquipment(p.EquipmentID, repairAlertTypes)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for i := range alerts {
+		if err := m.alertRepo.Save(resolveAlert(&alerts[i], &p.OperatorID, now)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func resolveAlert(alert *model.Alert, by *uint, now time.Time) *model.Alert {
+	alert.Status, alert.ActiveKey = model.AlertResolved, nil
+	alert.ResolvedBy, alert.ResolvedAt = by, &now
+	return alert
+}
+
+func acknowledgeAlert(alert *model.Alert, by uint, now time.Time) *model.Alert {
+	if alert.Status == model.AlertFiring {
+		alert.Status, alert.AcknowledgedBy, alert.AcknowledgedAt = model.AlertAcknowledged, &by, &now
+	}
+	return alert
+}
+
+// =====================================================
+// Alert APIs
+// =====================================================
+
+func alertVisible(user *model.User, alert *model.Alert) bool {
+	if user.Role == model.RoleAdmin || user.FactoryID == nil {
+		return true
+	}
+	return alert.FactoryID != nil && *alert.FactoryID == *user.FactoryID
+}
+
+// ListAlerts 非管理员只能查看本工厂告警
+func (m *AlertManager) ListAlerts(user *model.User, status, severity string, limit int) ([]model.Alert, error) {
+	var factoryID *uint
+	if user.Role != model.RoleAdmin {
+		factoryID = user.FactoryID
+	}
+	if limit <= 0 || limit > 500 {
+		limit = defaultAlertListLimit
+	}
+	return m.alertRepo.List(status, severity, factoryID, limit)
+}
+
+func (m *AlertManager) GetAlert(user *model.User, id uint) (*model.Alert, error) {
+	alert, err := m.alertRepo.GetByID(id)
+	if err != nil || !alertVisible(user, alert) {
+		return nil, ErrNotFound
+	}
+	return alert, nil
+}
+
+// Acknowledge 确认告警：此后重复事件不再通知，直到严重度升级或告警解决；告警仍在升级时同时结束升级
+func (m *AlertManager) Acknowledge(user *model.User, id uint) (*model.Alert, error) {
+	alert, err := m.GetAlert(user, id)
+	if err != nil {
+		return nil, err
+	}
+	if alert.Status == model.AlertResolved {
+		return nil, fmt.Errorf("%w: alert is already resolved", ErrInvalidInput)
+	}
+	// 升级中的告警只有可确认该升级的人能确认
+	return m.escalSvc.AcknowledgeAlert(user, alert.ID)
+}
+
+// Resolve 手动解决告警；同一指纹的后续事件会产生新告警
+func (m *AlertManager) Resolve(user *model.User, id uint) (*model.Alert, error) {
+	alert, err := m.GetAlert(user, id)
+	if err != nil {
+		return nil, err
+	}
+	if alert.Status == model.AlertResolved {
+		return alert, nil
+	}
+	if err := m.alertRepo.Save(resolveAlert(alert, &user.ID, time.Now())); err != nil {
+		return nil, err
+	}
+	return alert, nil
+}
+
+// GetQuietHours 返回用户的免打扰时段，未设置时返回未启用的默认值
+func (m *AlertManager) GetQuietHours(userID uint) (*model.NotificationQuietHours, error) {
+	q, err := m.alertRepo.GetQuietHours(userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &model.NotificationQuietHours{UserID: userID, Start: "22:00", End: "07:00", Timezone: defaultQuietHoursZone}, nil
+	}
+	return q, err
+}
+
+// SaveQuietHours 设置用户的免打扰时段
+func (m *AlertManager) SaveQuietHours(userID uint, start, end, timezone string, enabled bool) (*model.NotificationQuietHours, error) {
+	q, err := m.alertRepo.GetQuietHours(userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		q, err = &model.NotificationQuietHours{UserID: userID}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	q.Start, q.End, q.Timezone, q.Enabled = start, end, timezone, enabled
+	if _, err := quietWindow(q); err != nil {
+		return nil, fmt.Errorf("%w: quiet hours need distinct HH:MM start and end and a valid IANA timezone", ErrInvalidInput)
+	}
+	if err := m.alertRepo.SaveQuietHours(q); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ems/backend/internal/event"
+	"github.com/ems/backend/internal/model"
+)
+
+func TestAlertFingerprint(t *testing.T) {
+	factory := uint(3)
+	rul := testEvent(t, event.EquipmentRULRiskPayload{Subject: event.Subject{FactoryID: &factory, EquipmentID: 7}})
+	if fp := alertFingerprint(rul); fp != "equipment.rul_risk/equipment:7" {
+		t.Errorf("Expected equipment fingerprint, got %s", fp)
+	}
+	stock := testEvent(t, event.SparePartBelowSafetyStockPayload{Subject: event.Subject{FactoryID: &factory}, SparePartID: 12})
+	if fp := alertFingerprint(stock); fp != "sparepart.below_safety_stock/factory:3/spare_part:12" {
+		t.Errorf("Expected spare part fingerprint, got %s", fp)
+	}
+}
+
+func TestAlertSeverity(t *testing.T) {
+	cases := []struct {
+		payload event.Payload
+		want    string
+	}{
+		{event.RepairSLABreachedPayload{Priority: 1}, SeverityCritical},
+		{event.RepairSLABreachedPayload{Priority: 3}, SeverityMedium},
+		{event.RepairCreatedPayload{Priority: 3}, SeverityLow},
+		{event.EquipmentRULRiskPayload{EstimatedRULDays: 2}, SeverityCritical},
+		{event.EquipmentRULRiskPayload{EstimatedRULDays: 5}, SeverityHigh},
+		{event.MaintenanceOverduePayload{}, SeverityLow},
+		{event.SparePartBelowSafetyStockPayload{Quantity: 0}, SeverityHigh},
+	}
+	for _, c := range cases {
+		if got := alertSeverity(testEvent(t, c.payload)); got != c.want {
+			t.Errorf("%T %+v: expected %s, got %s", c.payload, c.payload, c.want, got)
+		}
+	}
+}
+
+func TestShouldRenotify(t *testing.T) {
+	now := time.Now()
+	notified := now.Add(-10 * time.Minute)
+	alert := &model.Alert{Severity: SeverityHigh, Status: model.AlertFiring, LastNotifiedAt: &notified}
+
+	if shouldRenotify(alert, SeverityHigh, now, 30*time.Minute) {
+		t.Errorf("Expected repeat within the window to be suppressed")
+	}
+	if !shouldRenotify(alert, SeverityHigh, now.Add(25*time.Minute), 30*time.Minute) {
+		t.Errorf("Expected repeat after the window to notify again")
+	}
+	if !shouldRenotify(alert, SeverityCritical, now, 30*time.Minute) {
+		t.Errorf("Expected severity escalation to bypass suppression")
+	}
+	alert.Status = model.AlertAcknowledged
+	if shouldRenotify(alert, SeverityHigh, now.Add(time.Hour), 30*time.Minute) {
+		t.Errorf("Expected acknowledged alert to stay quiet")
+	}
+}
+
+func TestDigestDueAndQuietHours(t *testing.T) {
+	now := time.Date(2026, 5, 1, 23, 30, 0, 0, time.UTC)
+	fresh := model.AlertDigestEntry{Reason: digestLowSeverity}
+	fresh.CreatedAt = now.Add(-10 * time.Minute)
+	if digestDue([]model.AlertDigestEntry{fresh}, now, time.Hour) {
+		t.Errorf("Expected fresh low-severity entries to wait for the digest interval")
+	}
+	old := fresh
+	old.CreatedAt = now.Add(-time.Hour)
+	deferred := model.AlertDigestEntry{Reason: digestQuietHours}
+	deferred.CreatedAt = now
+	if !digestDue([]model.AlertDigestEntry{fresh, old}, now, time.Hour) || !digestDue([]model.AlertDigestEntry{deferred}, now, time.Hour) {
+		t.Errorf("Expected aged or quiet-hours entries to be due")
+	}
+
+	w, err := quietWindow(&model.NotificationQuietHours{Start: "22:00", End: "07:00", Timezone: "UTC"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !w.Contains(now) || !w.Contains(now.Add(7*time.Hour)) || w.Contains(now.Add(8*time.Hour)) {
+		t.Errorf("Expected overnight quiet hours 22:00-07:00")
+	}
+	if _, err := quietWindow(&model.NotificationQuietHours{Start: "22:00", End: "22:00"}); err == nil {
+		t.Errorf("Expected identical start and end to be rejected")
+	}
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"time"
+
+	agentService "github.com/ems/backend/internal/agent/service"
+	"github.com/ems/backend/internal/event"
+	"github.com/ems/backend/internal/model"
+	"github.com/ems/backend/internal/repository"
+	"gorm.io/gorm"
+)
+
+// 维修 SLA：按优先级的响应时限（开始维修）与修复时限（提交验收），均从报修时间起算
+var repairSLA = map[int]struct{ Response, Resolution time.Duration }{
+	1: {2 * time.Hour, 24 * time.Hour},
+	2: {8 * time.Hour, 72 * time.Hour},
+	3: {24 * time.Hour, 7 * 24 * time.Hour},
+}
+
+const (
+	rulRiskMaxDays  = 6 // 剩余寿命不足一周视为风险
+	rulRiskCooldown = 24 * time.Hour
+	rulScanPageSize = 100
+)
+
+// AlertScanService 巡检维修 SLA 与设备剩余寿命并发布对应的领域事件，由调度任务周期调用
+type AlertScanService struct {
+	orderRepo    *repository.RepairOrderRepository
+	equipRepo    *repository.EquipmentRepository
+	eventRepo    *repository.DomainEventRepository
+	agentService *agentService.AgentService
+}
+
+func NewAlertScanService() *AlertScanService {
+	return &AlertScanService{
+		orderRepo:    repository.NewRepairOrderRepository(),
+		equipRepo:    repository.NewEquipmentRepo(),
+		eventRepo:    repository.NewDomainEventRepository(),
+		agentService: agentService.NewAgentService(),
+	}
+}
+
+func slaBreach(order *model.RepairOrder, now time.Time) (stage string, deadline time.Time) {
+	sla, ok := repairSLA[order.Priority]
+	if !ok {
+		sla = repairSLA[3]
+	}
+	if order.StartedAt == nil && order.ResponseBreachedAt == nil {
+		if deadline = order.CreatedAt.Add(sla.Response); now.After(deadline) {
+			return "response", deadline
+		}
+	}
+	if order.ResolutionBreachedAt == nil {
+		if deadline = order.CreatedAt.Add(sla.Resolution); now.After(deadline) {
+			return "resolution", deadline
+		}
+	}
+	return "", time.Time{}
+}
+
+// CheckRepairSLA 标记超时工单并发布 repair.sla_breached
+func (s *AlertScanService) CheckRepairSLA(now time.Time) (int, error) {
+	orders, err := s.orderRepo.ListUnfinished()
+	if err != nil {
+		return 0, err
+	}
+	breached := 0
+	for i := range orders {
+		order := &orders[i]
+		stage, deadline := slaBreach(order, now)
+		if stage == "" {
+			continue
+		}
+		p := event.RepairSLABreachedPayload{
+			Subject: event.Subject{EquipmentID: order.EquipmentID}, OrderID: order.ID, Priority: order.Priority,
+			Status: string(order.Status), Stage: stage, Deadline: deadline, AssignedTo: order.AssignedTo,
+		}
+		if order.Equipment != nil {
+			p.Subject, p.EquipmentCode = equipmentSubject(order.Equipment), order.Equipment.Code
+		}
+		err := repository.DB.Transaction(func(tx *gorm.DB) error {
+			if err := s.orderRepo.WithTx(tx).MarkSLABreached(order.ID, stage, now); err != nil {
+				return err
+			}
+			return event.Publish(tx, p)
+		})
+		if err != nil {
+			return breached, err
+		}
+		breached++
+	}
+	return breached, nil
+}
+
+// ScanRULRisks 预测运行中设备的剩余寿命，低于阈值时发布 equipment.rul_risk；同一设备 24 小时内只发布一次
+func (s *AlertScanService) ScanRULRisks(now time.Time) (int, error) {
+	// 全局巡检以管理员身份预测，接收范围由订阅者按工厂过滤
+	system := model.User{Username: "system", Role: model.RoleAdmin}
+	found := 0
+	for page := 1; ; page++ {
+		equipments, total, err := s.equipRepo.List(repository.EquipmentFilter{Status: "running", Page: page, PageSize: rulScanPageSize})
+		if err != nil {
+			return found, err
+		}
+		for i := range equipments {
+			e := &equipments[i]
+			pred, err := s.agentService.PredictRUL(e.ID, system)
+			if err != nil || pred == nil || pred.EstimatedRULDays > rulRiskMaxDays {
+				continue
+			}
+			if exists, err := s.eventRepo.ExistsSince(string(event.EquipmentRULRisk), e.ID, now.Add(-rulRiskCooldown)); err != nil || exists {
+				continue
+			}
+			err = event.Publish(repository.DB, event.EquipmentRULRiskPayload{
+				Subject: equipmentSubject(e), EquipmentCode: e.Code, EquipmentName: e.Name,
+				EstimatedRULDays: pred.EstimatedRULDays, HealthScore: pred.HealthScore, Recommendation: pred.Recommendation,
+			})
+			if err != nil {
+				return found, err
+			}
+			found++
+		}
+		if int64(page*rulScanPageSize) >= total {
+			return found, nil
+		}
+	}
+}
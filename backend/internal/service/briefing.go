@@ -0,0 +1,542 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	agentDto "github.com/ems/backend/internal/agent/dto"
+	agentService "github.com/ems/backend/internal/agent/service"
+	"github.com/ems/backend/internal/model"
+	"github.com/ems/backend/internal/repository"
+	"github.com/ems/backend/pkg/cron"
+)
+
+// 简报受众
+const (
+	BriefingAudienceSupervisor = "supervisor"
+	BriefingAudienceTechnician = "technician"
+)
+
+// 受众到用户角色的映射
+var briefingAudienceRoles = map[string][]string{
+	BriefingAudienceSupervisor: {string(model.RoleSupervisor)},
+	BriefingAudienceTechnician: {string(model.RoleMaintenance), string(model.RoleEngineer)},
+}
+
+// BriefingPushType 简报在订阅中使用的推送类型
+const BriefingPushType = "briefing"
+
+const briefingRULScanLimit = 50
+const briefingTopRULRisks = 5
+
+// BriefingService 按定义定时汇总业务数据，生成简报产物并推送
+type BriefingService struct {
+	briefingRepo   *repository.AgentBriefingRepository
+	userRepo       *repository.UserRepository
+	repairSvc      *RepairOrderService
+	maintenanceSvc *MaintenanceTaskService
+	inspectionSvc  *InspectionTaskService
+	sparePartSvc   *SparePartService
+	equipmentSvc   *EquipmentService
+	larkSvc        *LarkService
+	agentService   *agentService.AgentService
+}
+
+func NewBriefingService() *BriefingService {
+	return &BriefingService{
+		briefingRepo:   repository.NewAgentBriefingRepository(),
+		userRepo:       repository.NewUserRepository(),
+		repairSvc:      NewRepairOrderService(),
+		maintenanceSvc: NewMaintenanceTaskService(),
+		inspectionSvc:  NewInspectionTaskService(),
+		sparePartSvc:   NewSparePartService(),
+		equipmentSvc:   NewEquipmentService(),
+		larkSvc:        NewLarkService(),
+		agentService:   agentService.NewAgentService(),
+	}
+}
+
+// =====================================================
+// Briefing Definitions
+// =====================================================
+
+// BriefingDefinitionRequest 创建或更新简报定义
+type BriefingDefinitionRequest struct {
+	Name      string
+	Cron      string
+	Period    string
+	Audience  string
+	FactoryID *uint
+	Template  string
+	Enabled   bool
+	CreatedBy uint
+}
+
+func (s *BriefingService) validate(req *BriefingDefinitionRequest) error {
+	if strings.TrimSpace(req.Name) == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidInput)
+	}
+	if err := cron.Validate(req.Cron); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidInput, err)
+	}
+	if _, ok := briefingAudienceRoles[req.Audience]; !ok {
+		return fmt.Errorf("%w: audience must be supervisor or technician", ErrInvalidInput)
+	}
+	if req.Period == "" {
+		req.Period = "daily"
+	}
+	if req.Period != "daily" && req.Period != "weekly" {
+		return fmt.Errorf("%w: period must be daily or weekly", ErrInvalidInput)
+	}
+	if req.Template != "" {
+		if _, err := template.New("briefing").Parse(req.Template); err != nil {
+			return fmt.Errorf("%w: invalid template: %v", ErrInvalidInput, err)
+		}
+	}
+	return nil
+}
+
+func (s *BriefingService) Create(req *BriefingDefinitionRequest) (*model.AgentBriefing, error) {
+	if err := s.validate(req); err != nil {
+		return nil, err
+	}
+	briefing := &model.AgentBriefing{
+		Name: req.Name, Cron: req.Cron, Period: req.Period, Audience: req.Audience,
+		FactoryID: req.FactoryID, Template: req.Template, Enabled: req.Enabled, CreatedBy: req.CreatedBy,
+	}
+	s.scheduleNext(briefing, time.Now())
+	if err := s.briefingRepo.Create(briefing); err != nil {
+		return nil, err
+	}
+	return briefing, nil
+}
+
+func (s *BriefingService) Update(id uint, req *BriefingDefinitionRequest) (*model.AgentBriefing, error) {
+	briefing, err := s.briefingRepo.GetByID(id)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	if err := s.validate(req); err != nil {
+		return nil, err
+	}
+	briefing.Name = req.Name
+	briefing.Cron = req.Cron
+	briefing.Period = req.Period
+	briefing.Audience = req.Audience
+	briefing.FactoryID = req.FactoryID
+	briefing.Template = req.Template
+	briefing.Enabled = req.Enabled
+	s.scheduleNext(briefing, time.Now())
+	if err := s.briefingRepo.Update(briefing); err != nil {
+		return nil, err
+	}
+	return briefing, nil
+}
+
+func (s *BriefingService) GetByID(id uint) (*model.AgentBriefing, error) {
+	briefing, err := s.briefingRepo.GetByID(id)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	return briefing, nil
+}
+
+func (s *BriefingService) List(factoryID *uint) ([]model.AgentBriefing, error) {
+	return s.briefingRepo.List(factoryID)
+}
+
+func (s *BriefingService) Delete(id uint) error {
+	return s.briefingRepo.Delete(id)
+}
+
+func (s *BriefingService) scheduleNext(briefing *model.AgentBriefing, from time.Time) {
+	schedule, err := cron.Parse(briefing.Cron)
+	if err != nil || !briefing.Enabled {
+		briefing.NextRunAt = nil
+		return
+	}
+	next := schedule.Next(from)
+	briefing.NextRunAt = &next
+}
+
+// =====================================================
+// Scheduling
+// =====================================================
+
+// RunDue 执行到期的简报定义，由调度任务每分钟调用。
+// 服务停机期间错过的触发只补跑一次，随后按 cron 重新排期。
+func (s *BriefingService) RunDue(now time.Time) (int, error) {
+	due, err := s.briefingRepo.ListDue(now)
+	if err != nil {
+		return 0, err
+	}
+	for i := range due {
+		if _, err := s.Execute(&due[i]); err != nil {
+			log.Printf("[BriefingService] Briefing %d (%s) failed: %v", due[i].ID, due[i].Name, err)
+		}
+	}
+	return len(due), nil
+}
+
+// BriefingRunResult 一次简报执行的结果
+type BriefingRunResult struct {
+	BriefingID  uint   `json:"briefing_id"`
+	Recipients  int    `json:"recipients"`
+	ArtifactIDs []uint `json:"artifact_ids"`
+	Pushed      int    `json:"pushed"`
+	LarkSent    int    `json:"lark_sent"`
+}
+
+// Trigger 手动立即执行一次简报（不影响下次排期）
+func (s *BriefingService) Trigger(id uint) (*BriefingRunResult, error) {
+	briefing, err := s.briefingRepo.GetByID(id)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	return s.generate(briefing, time.Now())
+}
+
+// Execute 执行简报并推进排期
+func (s *BriefingService) Execute(briefing *model.AgentBriefing) (*BriefingRunResult, error) {
+	now := time.Now()
+	result, err := s.generate(briefing, now)
+
+	briefing.LastRunAt = &now
+	s.scheduleNext(briefing, now)
+	if uerr := s.briefingRepo.Update(briefing); uerr != nil {
+		log.Printf("[BriefingService] Failed to reschedule briefing %d: %v", briefing.ID, uerr)
+	}
+	return result, err
+}
+
+func (s *BriefingService) generate(briefing *model.AgentBriefing, now time.Time) (*BriefingRunResult, error) {
+	recipients, err := s.userRepo.ListActiveByRoles(briefingAudienceRoles[briefing.Audience], briefing.FactoryID)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, err := s.parseTemplate(briefing)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &BriefingRunResult{BriefingID: briefing.ID, Recipients: len(recipients)}
+	for _, user := range recipients {
+		data := s.collect(briefing, user, now)
+
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			log.Printf("[BriefingService] Template render failed for briefing %d user %d: %v", briefing.ID, user.ID, err)
+			continue
+		}
+
+		resultJSON, _ := json.Marshal(data)
+		artifact := &model.AgentArtifact{
+			ArtifactType: "briefing",
+			Title:        data.Title,
+			Summary:      buf.String(),
+			ResultJSON:   string(resultJSON),
+			RiskLevel:    data.RiskLevel,
+		}
+		if err := s.agentService.PublishArtifact(user, "briefing_"+briefing.Audience, data.FactoryID, artifact, data.evidence()); err != nil {
+			log.Printf("[BriefingService] Failed to store briefing artifact for user %d: %v", user.ID, err)
+			continue
+		}
+		result.ArtifactIDs = append(result.ArtifactIDs, artifact.ID)
+
+		result.Pushed += s.agentService.DeliverToSubscribers(user.ID, BriefingPushType, artifact)
+		if user.LarkOpenID != nil && *user.LarkOpenID != "" {
+			ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+			if err := s.larkSvc.SendTextToUser(ctx, user, artifact.Title+"\n\n"+artifact.Summary); err != nil {
+				log.Printf("[BriefingService] Lark delivery failed for user %d: %v", user.ID, err)
+			} else {
+				result.LarkSent++
+			}
+			cancel()
+		}
+	}
+
+	log.Printf("[BriefingService] Briefing %d (%s) generated %d artifact(s), pushed=%d lark=%d",
+		briefing.ID, briefing.Name, len(result.ArtifactIDs), result.Pushed, result.LarkSent)
+	return result, nil
+}
+
+func (s *BriefingService) parseTemplate(briefing *model.AgentBriefing) (*template.Template, error) {
+	text := briefing.Template
+	if text == "" {
+		text = defaultSupervisorBriefingTemplate
+		if briefing.Audience == BriefingAudienceTechnician {
+			text = defaultTechnicianBriefingTemplate
+		}
+	}
+	return template.New("briefing").Parse(text)
+}
+
+// =====================================================
+// Data Assembly
+// =====================================================
+
+// BriefingItem 简报中的一行条目
+type BriefingItem struct {
+	ID     uint   `json:"id"`
+	Code   string `json:"code"`
+	Name   string `json:"name"`
+	Detail string `json:"detail"`
+}
+
+// BriefingRULRisk 设备剩余寿命风险条目
+type BriefingRULRisk struct {
+	EquipmentID   uint    `json:"equipment_id"`
+	EquipmentCode string  `json:"equipment_code"`
+	EquipmentName string  `json:"equipment_name"`
+	RULDays       int     `json:"rul_days"`
+	HealthScore   float64 `json:"health_score"`
+	Advice        string  `json:"advice"`
+}
+
+// BriefingData 供模板渲染的简报数据
+type BriefingData struct {
+	Title       string `json:"title"`
+	Name        string `json:"name"`
+	Period      string `json:"period"`
+	PeriodLabel string `json:"period_label"`
+	Date        string `json:"date"`
+	WindowStart string `json:"window_start"`
+	Recipient   string `json:"recipient"`
+	Audience    string `json:"audience"`
+	FactoryID   *uint  `json:"factory_id"`
+	RiskLevel   string `json:"risk_level"`
+
+	// 主管视角
+	Breakdowns   []BriefingItem    `json:"breakdowns,omitempty"`
+	OverdueTasks []BriefingItem    `json:"overdue_tasks,omitempty"`
+	LowStock     []BriefingItem    `json:"low_stock,omitempty"`
+	RULRisks     []BriefingRULRisk `json:"rul_risks,omitempty"`
+
+	// 技术员视角
+	RepairQueue      []BriefingItem `json:"repair_queue,omitempty"`
+	MaintenanceQueue []BriefingItem `json:"maintenance_queue,omitempty"`
+	InspectionQueue  []BriefingItem `json:"inspection_queue,omitempty"`
+}
+
+func (s *BriefingService) collect(briefing *model.AgentBriefing, user model.User, now time.Time) *BriefingData {
+	factoryID := briefing.FactoryID
+	if factoryID == nil {
+		factoryID = user.FactoryID
+	}
+
+	windowStart := now.AddDate(0, 0, -1)
+	label := "晨报"
+	if briefing.Period == "weekly" {
+		windowStart = now.AddDate(0, 0, -7)
+		label = "周报"
+	}
+
+	data := &BriefingData{
+		Name: briefing.Name, Period: briefing.Period, PeriodLabel: label,
+		Date: now.Format("2006-01-02"), WindowStart: windowStart.Format("2006-01-02 15:04"),
+		Recipient: user.Name, Audience: briefing.Audience, FactoryID: factoryID,
+	}
+	data.Title = fmt.Sprintf("设备管理%s %s", label, data.Date)
+
+	if briefing.Audience == BriefingAudienceTechnician {
+		s.collectTechnician(data, user, now)
+	} else {
+		s.collectSupervisor(data, user, factoryID, windowStart)
+	}
+	data.RiskLevel = data.riskLevel()
+	return data
+}
+
+func (s *BriefingService) collectSupervisor(data *BriefingData, user model.User, factoryID *uint, windowStart time.Time) {
+	var fid uint
+	if factoryID != nil {
+		fid = *factoryID
+	}
+
+	// 1. 统计窗口内新增的故障报修
+	if res, err := s.repairSvc.List(&RepairOrderFilter{FactoryID: fid, DateFrom: windowStart, Page: 1, PageSize: 50}); err == nil {
+		for _, o := range res.Items {
+			data.Breakdowns = append(data.Breakdowns, BriefingItem{
+				ID: o.ID, Code: equipmentCode(o.Equipment), Name: equipmentName(o.Equipment),
+				Detail: fmt.Sprintf("P%d %s [%s]", o.Priority, o.FaultDescription, o.Status),
+			})
+		}
+	} else {
+		log.Printf("[BriefingService] Failed to load breakdowns: %v", err)
+	}
+
+	// 2. 逾期保养
+	if res, err := s.maintenanceSvc.List(&MaintenanceTaskFilter{Status: model.MaintenanceOverdue, FactoryID: fid, Page: 1, PageSize: 50}); err == nil {
+		for _, t := range res.Items {
+			planName := ""
+			if t.Plan != nil {
+				planName = t.Plan.Name
+			}
+			data.OverdueTasks = append(data.OverdueTasks, BriefingItem{
+				ID: t.ID, Code: equipmentCode(t.Equipment), Name: equipmentName(t.Equipment),
+				Detail: fmt.Sprintf("%s 计划 %s，截止 %s", planName, t.ScheduledDate, t.DueDate),
+			})
+		}
+	} else {
+		log.Printf("[BriefingService] Failed to load overdue maintenance: %v", err)
+	}
+
+	// 3. 低库存备件
+	if alerts, err := s.sparePartSvc.GetLowStockAlerts(); err == nil {
+		for _, a := range alerts {
+			if fid != 0 && a.FactoryID != fid {
+				continue
+			}
+			data.LowStock = append(data.LowStock, BriefingItem{
+				ID: a.SparePartID, Code: a.SparePartCode, Name: a.SparePartName,
+				Detail: fmt.Sprintf("库存 %d / 安全库存 %d，缺口 %d", a.CurrentStock, a.SafetyStock, a.Shortage),
+			})
+		}
+	} else {
+		log.Printf("[BriefingService] Failed to load low stock alerts: %v", err)
+	}
+
+	// 4. 剩余寿命风险 Top N（以接收人权限预测，保证工厂隔离）
+	eqFilter := &EquipmentFilter{FactoryID: factoryID, Status: "running", Page: 1, PageSize: briefingRULScanLimit}
+	if res, err := s.equipmentSvc.List(eqFilter); err == nil {
+		for _, e := range res.Items {
+			pred, err := s.agentService.PredictRUL(e.ID, user)
+			if err != nil || pred == nil {
+				continue
+			}
+			data.RULRisks = append(data.RULRisks, BriefingRULRisk{
+				EquipmentID: e.ID, EquipmentCode: e.Code, EquipmentName: e.Name,
+				RULDays: pred.EstimatedRULDays, HealthScore: pred.HealthScore, Advice: pred.Recommendation,
+			})
+		}
+		sort.Slice(data.RULRisks, func(i, j int) bool {
+			if data.RULRisks[i].RULDays != data.RULRisks[j].RULDays {
+				return data.RULRisks[i].RULDays < data.RULRisks[j].RULDays
+			}
+			return data.RULRisks[i].HealthScore < data.RULRisks[j].HealthScore
+		})
+		if len(data.RULRisks) > briefingTopRULRisks {
+			data.RULRisks = data.RULRisks[:briefingTopRULRisks]
+		}
+	} else {
+		log.Printf("[BriefingService] Failed to load equipment for RUL scan: %v", err)
+	}
+}
+
+func (s *BriefingService) collectTechnician(data *BriefingData, user model.User, now time.Time) {
+	if orders, err := s.repairSvc.GetMyTasks(user.ID); err == nil {
+		for _, o := range orders {
+			data.RepairQueue = append(data.RepairQueue, BriefingItem{
+				ID: o.ID, Code: equipmentCode(o.Equipment), Name: equipmentName(o.Equipment),
+				Detail: fmt.Sprintf("P%d %s [%s]", o.Priority, o.FaultDescription, o.Status),
+			})
+		}
+	}
+	if tasks, err := s.maintenanceSvc.GetMyTasks(user.ID, now); err == nil {
+		for _, t := range tasks {
+			planName := ""
+			if t.Plan != nil {
+				planName = t.Plan.Name
+			}
+			data.MaintenanceQueue = append(data.MaintenanceQueue, BriefingItem{
+				ID: t.ID, Code: equipmentCode(t.Equipment), Name: equipmentName(t.Equipment),
+				Detail: fmt.Sprintf("%s 计划日期 %s", planName, t.ScheduledDate),
+			})
+		}
+	}
+	if tasks, err := s.inspectionSvc.GetMyTasks(user.ID, now); err == nil {
+		for _, t := range tasks {
+			data.InspectionQueue = append(data.InspectionQueue, BriefingItem{
+				ID: t.ID, Code: equipmentCode(t.Equipment), Name: equipmentName(t.Equipment),
+				Detail: fmt.Sprintf("计划日期 %s [%s]", t.ScheduledDate.Format("2006-01-02"), t.Status),
+			})
+		}
+	}
+}
+
+func (d *BriefingData) riskLevel() string {
+	for _, r := range d.RULRisks {
+		if r.RULDays < 7 {
+			return "high"
+		}
+	}
+	if len(d.OverdueTasks) > 0 || len(d.LowStock) > 0 || len(d.Breakdowns) > 5 {
+		return "medium"
+	}
+	return "low"
+}
+
+// evidence 将简报条目映射为证据链接，便于追溯源数据
+func (d *BriefingData) evidence() []agentDto.EvidenceItem {
+	var items []agentDto.EvidenceItem
+	add := func(evType, table string, list []BriefingItem) {
+		for _, it := range list {
+			items = append(items, agentDto.EvidenceItem{
+				EvidenceType: evType, SourceTable: table, SourceID: it.ID,
+				Title: it.Name, Excerpt: it.Detail, Score: 1,
+			})
+		}
+	}
+	add("repair_order", "repair_orders", d.Breakdowns)
+	add("maintenance_task", "maintenance_tasks", d.OverdueTasks)
+	add("spare_part", "spare_parts", d.LowStock)
+	add("repair_order", "repair_orders", d.RepairQueue)
+	add("maintenance_task", "maintenance_tasks", d.MaintenanceQueue)
+	add("inspection_task", "inspection_tasks", d.InspectionQueue)
+	for _, r := range d.RULRisks {
+		items = append(items, agentDto.EvidenceItem{
+			EvidenceType: "rul_prediction", SourceTable: "equipment", SourceID: r.EquipmentID,
+			Title: r.EquipmentName, Excerpt: fmt.Sprintf("预计剩余 %d 天，健康分 %.0f", r.RULDays, r.HealthScore), Score: 1,
+		})
+	}
+	return items
+}
+
+func equipmentCode(e *model.Equipment) string {
+	if e == nil {
+		return ""
+	}
+	return e.Code
+}
+
+func equipmentName(e *model.Equipment) string {
+	if e == nil {
+		return ""
+	}
+	return e.Name
+}
+
+const defaultSupervisorBriefingTemplate = `{{.Recipient}}，早上好！以下是{{.PeriodLabel}}（{{.WindowStart}} 起）：
+
+【故障报修】{{len .Breakdowns}} 单
+{{range .Breakdowns}}- {{.Code}} {{.Name}}：{{.Detail}}
+{{end}}
+【逾期保养】{{len .OverdueTasks}} 项
+{{range .OverdueTasks}}- {{.Code}} {{.Name}}：{{.Detail}}
+{{end}}
+【低库存备件】{{len .LowStock}} 项
+{{range .LowStock}}- {{.Code}} {{.Name}}：{{.Detail}}
+{{end}}
+【剩余寿命风险 Top {{len .RULRisks}}】
+{{range .RULRisks}}- {{.EquipmentCode}} {{.EquipmentName}}：预计剩余 {{.RULDays}} 天，健康分 {{printf "%.0f" .HealthScore}}
+{{end}}`
+
+const defaultTechnicianBriefingTemplate = `{{.Recipient}}，早上好！今日待办如下：
+
+【维修任务】{{len .RepairQueue}} 单
+{{range .RepairQueue}}- #{{.ID}} {{.Code}} {{.Name}}：{{.Detail}}
+{{end}}
+【保养任务】{{len .MaintenanceQueue}} 项
+{{range .MaintenanceQueue}}- #{{.ID}} {{.Code}} {{.Name}}：{{.Detail}}
+{{end}}
+【点检任务】{{len .InspectionQueue}} 项
+{{range .InspectionQueue}}- #{{.ID}} {{.Code}} {{.Name}}：{{.Detail}}
+{{end}}`
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ems/backend/internal/model"
+	"github.com/ems/backend/internal/repository"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestBriefingValidate(t *testing.T) {
+	s := &BriefingService{}
+
+	ok := &BriefingDefinitionRequest{Name: "晨报", Cron: "0 8 * * *", Audience: BriefingAudienceSupervisor}
+	if err := s.validate(ok); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if ok.Period != "daily" {
+		t.Errorf("Expected period to default to daily, got %s", ok.Period)
+	}
+
+	bad := []*BriefingDefinitionRequest{
+		{Name: "", Cron: "0 8 * * *", Audience: BriefingAudienceSupervisor},
+		{Name: "x", Cron: "0 25 * * *", Audience: BriefingAudienceSupervisor},
+		{Name: "x", Cron: "0 8 * * *", Audience: "operator"},
+		{Name: "x", Cron: "0 8 * * *", Audience: BriefingAudienceTechnician, Period: "monthly"},
+		{Name: "x", Cron: "0 8 * * *", Audience: BriefingAudienceTechnician, Template: "{{.Missing"},
+	}
+	for i, req := range bad {
+		if err := s.validate(req); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("case %d: expected ErrInvalidInput, got %v", i, err)
+		}
+	}
+}
+
+func TestBriefingDefaultTemplates(t *testing.T) {
+	s := &BriefingService{}
+	data := &BriefingData{
+		Recipient: "张工", PeriodLabel: "晨报",
+		Breakdowns:  []BriefingItem{{ID: 1, Code: "CNC-01", Name: "数控机床", Detail: "P1 主轴异响"}},
+		RULRisks:    []BriefingRULRisk{{EquipmentCode: "CNC-02", RULDays: 3, HealthScore: 12}},
+		RepairQueue: []BriefingItem{{ID: 9, Code: "PUMP-01", Name: "液压泵", Detail: "P2 漏油"}},
+	}
+
+	for _, audience := range []string{BriefingAudienceSupervisor, BriefingAudienceTechnician} {
+		tmpl, err := s.parseTemplate(&model.AgentBriefing{Audience: audience})
+		if err != nil {
+			t.Fatalf("Unexpected parse error for %s: %v", audience, err)
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			t.Fatalf("Unexpected render error for %s: %v", audience, err)
+		}
+		want := "CNC-01"
+		if audience == BriefingAudienceTechnician {
+			want = "PUMP-01"
+		}
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected %s briefing to mention %s, got:\n%s", audience, want, buf.String())
+		}
+	}
+}
+
+func TestBriefingRiskLevel(t *testing.T) {
+	d := &BriefingData{}
+	if d.riskLevel() != "low" {
+		t.Errorf("Expected low, got %s", d.riskLevel())
+	}
+	d.LowStock = []BriefingItem{{ID: 1}}
+	if d.riskLevel() != "medium" {
+		t.Errorf("Expected medium, got %s", d.riskLevel())
+	}
+	d.RULRisks = []BriefingRULRisk{{RULDays: 2}}
+	if d.riskLevel() != "high" {
+		t.Errorf("Expected high, got %s", d.riskLevel())
+	}
+	if len(d.evidence()) != 2 {
+		t.Errorf("Expected 2 evidence items, got %d", len(d.evidence()))
+	}
+}
+
+func TestBriefingCreateKeepsDisabled(t *testing.T) {
+	// DryRun 只生成 SQL 不连库，用来确认 enabled=false 会写进 INSERT
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost dbname=ems"}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var insert string
+	db.Callback().Create().After("gorm:create").Register("test:capture", func(tx *gorm.DB) {
+		insert = db.Dialector.Explain(tx.Statement.SQL.String(), tx.Statement.Vars...)
+	})
+
+	prev := repository.DB
+	repository.DB = db
+	defer func() { repository.DB = prev }()
+
+	s := &BriefingService{briefingRepo: repository.NewAgentBriefingRepository()}
+	briefing, err := s.Create(&BriefingDefinitionRequest{Name: "晨报", Cron: "0 8 * * *", Audience: BriefingAudienceSupervisor})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if briefing.Enabled {
+		t.Error("Expected briefing to stay disabled")
+	}
+	if !strings.Contains(insert, `"enabled"`) || !strings.Contains(insert, "false") {
+		t.Errorf("Expected insert to write enabled=false, got %s", insert)
+	}
+}
@@ -0,0 +1,816 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ems/backend/internal/dto"
+	"github.com/ems/backend/internal/event"
+	"github.com/ems/backend/internal/model"
+	"github.com/ems/backend/internal/notify"
+	"github.com/ems/backend/internal/repository"
+	"gorm.io/gorm"
+)
+
+// 升级目标
+const (
+	TargetAssignee           = "assignee"
+	TargetWorkshopSupervisor = "workshop_supervisor"
+	TargetFactoryManager     = "factory_manager"
+	TargetOnCall             = "on_call"
+	targetRolePrefix         = "role:"
+	targetUserPrefix         = "user:"
+)
+
+// 升级历史动作
+const (
+	escalationLogNotified     = "notified"
+	escalationLogSkipped      = "skipped"
+	escalationLogAcknowledged = "acknowledged"
+	escalationLogResolved     = "resolved"
+	escalationLogExhausted    = "exhausted"
+)
+
+const (
+	escalationLease            = 5 * time.Minute
+	escalationBatch            = 50
+	maxEscalationSteps         = 10
+	defaultEscalationListLimit = 100
+	defaultOnCallDays          = 7
+)
+
+// EscalationService 维修单或告警在产生后未被确认时，按工厂与优先级配置的升级链逐级通知；
+// 按角色通知时优先通知当前值班人
+type EscalationService struct {
+	repo          *repository.EscalationRepository
+	userRepo      *repository.UserRepository
+	orderRepo     *repository.RepairOrderRepository
+	equipmentRepo *repository.EquipmentRepository
+	factoryRepo   *repository.FactoryRepository
+	alertRepo     *repository.AlertRepository
+	notifySvc     *NotificationService
+}
+
+func NewEscalationService() *EscalationService {
+	return &EscalationService{
+		repo:          repository.NewEscalationRepository(),
+		userRepo:      repository.NewUserRepository(),
+		orderRepo:     repository.NewRepairOrderRepository(),
+		equipmentRepo: repository.NewEquipmentRepo(),
+		factoryRepo:   repository.NewFactoryRepo(),
+		alertRepo:     repository.NewAlertRepository(),
+		notifySvc:     NewNotificationService(),
+	}
+}
+
+// validEscalationTarget 校验升级目标
+func validEscalationTarget(target string) bool {
+	switch target {
+	case TargetAssignee, TargetWorkshopSupervisor, TargetFactoryManager, TargetOnCall:
+		return true
+	}
+	if role, ok := strings.CutPrefix(target, targetRolePrefix); ok {
+		switch model.UserRole(role) {
+		case model.RoleAdmin, model.RoleSupervisor, model.RoleEngineer, model.RoleMaintenance, model.RoleOperator:
+			return true
+		}
+		return false
+	}
+	if id, ok := strings.CutPrefix(target, targetUserPrefix); ok {
+		n, err := strconv.ParseUint(id, 10, 32)
+		return err == nil && n > 0
+	}
+	return false
+}
+
+// validateEscalationSteps 升级链至少一级、最多 maxEscalationSteps 级，等待时间不递减
+func validateEscalationSteps(steps []model.EscalationStep) error {
+	if len(steps) == 0 || len(steps) > maxEscalationSteps {
+		return fmt.Errorf("%w: escalation policy needs 1 to %d steps", ErrInvalidInput, maxEscalationSteps)
+	}
+	for i, step := range steps {
+		if !validEscalationTarget(step.Target) {
+			return fmt.Errorf("%w: unknown escalation target %q", ErrInvalidInput, step.Target)
+		}
+		if step.AfterMinutes < 0 || (i > 0 && step.AfterMinutes < steps[i-1].AfterMinutes) {
+			return fmt.Errorf("%w: after_minutes must be non-negative and non-decreasing", ErrInvalidInput)
+		}
+	}
+	return nil
+}
+
+func parseEscalationSteps(raw string) ([]model.EscalationStep, error) {
+	var steps []model.EscalationStep
+	if err := json.Unmarshal([]byte(raw), &steps); err != nil {
+		return nil, err
+	}
+	return steps, validateEscalationSteps(steps)
+}
+
+// escalationStepAt 第 i 级的通知时间，从升级开始时起算
+func escalationStepAt(started time.Time, steps []model.EscalationStep, i int) time.Time {
+	return started.Add(time.Duration(steps[i].AfterMinutes) * time.Minute)
+}
+
+// preferOnCall 候选人中有人正在值班时只通知值班人，否则通知全部候选人
+func preferOnCall(users []model.User, onCall []uint) []model.User {
+	var picked []model.User
+	for _, u := range users {
+		for _, id := range onCall {
+			if u.ID == id {
+				picked = append(picked, u)
+				break
+			}
+		}
+	}
+	if len(picked) == 0 {
+		return users
+	}
+	return picked
+}
+
+// alertEscalationPriority 告警严重度折算为升级优先级，low 告警不升级
+func alertEscalationPriority(severity string) int {
+	switch severity {
+	case SeverityCritical:
+		return 1
+	case SeverityHigh:
+		return 2
+	case SeverityMedium:
+		return 3
+	}
+	return 0
+}
+
+// orderAwaiting 维修单仍在等待响应（未开始维修）
+func orderAwaiting(status model.RepairStatus) bool {
+	return status == model.RepairPending || status == model.RepairAssigned
+}
+
+// =====================================================
+// Start & stop
+// =====================================================
+
+// Handle 事件总线订阅者：新维修单开始升级，维修单开始处理后结束升级
+func (s *EscalationService) Handle(ctx context.Context, e event.Event) error {
+	switch e.Type {
+	case event.RepairCreated:
+		var p event.RepairCreatedPayload
+		if err := e.Decode(&p); err != nil {
+			return err
+		}
+		return s.start(model.EscalationSubjectRepairOrder, p.OrderID, e.FactoryID, p.Priority, time.Now())
+	case event.RepairStatusChanged:
+		var p event.RepairStatusChangedPayload
+		if err := e.Decode(&p); err != nil {
+			return err
+		}
+		if orderAwaiting(model.RepairStatus(p.To)) {
+			return nil
+		}
+		var actor *uint
+		if p.OperatorID != 0 {
+			actor = &p.OperatorID
+		}
+		return s.close(model.EscalationSubjectRepairOrder, p.OrderID, model.EscalationResolved, actor, "status "+p.To)
+	}
+	return nil
+}
+
+// StartForAlert 新告警按严重度开始升级；新维修单告警由维修单自身的升级覆盖
+func (s *EscalationService) StartForAlert(alert *model.Alert) error {
+	priority := alertEscalationPriority(alert.Severity)
+	if priority == 0 || alert.EventType == string(event.RepairCreated) {
+		return nil
+	}
+	return s.start(model.EscalationSubjectAlert, alert.ID, alert.FactoryID, priority, alert.FirstSeenAt)
+}
+
+// start 按工厂与优先级匹配策略开始升级；未配置策略时不升级，同一对象只升级一次
+func (s *EscalationService) start(subjectType string, subjectID uint, factoryID *uint, priority int, at time.Time) error {
+	exists, err := s.repo.ExistsForSubject(subjectType, subjectID)
+	if err != nil || exists {
+		return err
+	}
+	policy, err := s.repo.FindPolicy(factoryID, priority)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	steps, err := parseEscalationSteps(policy.Steps)
+	if err != nil {
+		log.Printf("[Escalation] Policy %d has invalid steps: %v", policy.ID, err)
+		return nil
+	}
+	next := escalationStepAt(at, steps, 0)
+	return s.repo.Create(&model.Escalation{
+		SubjectType: subjectType, SubjectID: subjectID, FactoryID: factoryID, PolicyID: policy.ID,
+		Priority: priority, Status: model.EscalationActive, NextAt: &next, StartedAt: at,
+	})
+}
+
+// close 结束对象上未结束的升级并记录历史
+func (s *EscalationService) close(subjectType string, subjectID uint, status string, actor *uint, note string) error {
+	return repository.DB.Transaction(func(tx *gorm.DB) error {
+		repo := s.repo.WithTx(tx)
+		esc, err := repo.LockOpen(subjectType, subjectID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return finishEscalation(repo, esc, status, actor, note, time.Now())
+	})
+}
+
+func finishEscalation(repo *repository.EscalationRepository, esc *model.Escalation, status string, actor *uint, note string, now time.Time) error {
+	esc.Status, esc.NextAt = status, nil
+	action := escalationLogResolved
+	if status == model.EscalationAcknowledged {
+		action = escalationLogAcknowledged
+		esc.AcknowledgedBy, esc.AcknowledgedAt = actor, &now
+	}
+	if err := repo.Save(esc); err != nil {
+		return err
+	}
+	return repo.CreateLog(&model.EscalationLog{EscalationID: esc.ID, Step: esc.NextStep, Action: action, ActorID: actor, Note: note})
+}
+
+// =====================================================
+// Escalation loop
+// =====================================================
+
+// ProcessDue 领取到期的升级并通知下一级；处理失败的升级在租约到期后重试
+func (s *EscalationService) ProcessDue(ctx context.Context, now time.Time) (int, error) {
+	due, err := s.repo.ClaimDue(now, escalationLease, escalationBatch)
+	if err != nil {
+		return 0, err
+	}
+	for i := range due {
+		if err := s.advance(ctx, &due[i], now); err != nil {
+			log.Printf("[Escalation] Escalation %d failed: %v", due[i].ID, err)
+		}
+	}
+	return len(due), nil
+}
+
+// escalationSubject 升级对象的当前状态与通知所需信息
+type escalationSubject struct {
+	Title       string
+	Open        bool
+	Status      string // 对象已结束时的升级状态
+	ActorID     *uint
+	Note        string
+	FactoryID   *uint
+	EquipmentID uint
+	AssigneeID  *uint
+}
+
+func (s *EscalationService) loadSubject(esc *model.Escalation) (*escalationSubject, error) {
+	switch esc.SubjectType {
+	case model.EscalationSubjectRepairOrder:
+		order, err := s.orderRepo.GetByID(esc.SubjectID)
+		if err != nil {
+			return nil, err
+		}
+		subj := &escalationSubject{
+			Title: fmt.Sprintf("RO-%d P%d", order.ID, order.Priority), Open: orderAwaiting(order.Status),
+			Status: model.EscalationResolved, Note: "status " + string(order.Status),
+			FactoryID: esc.FactoryID, EquipmentID: order.EquipmentID, AssigneeID: order.AssignedTo,
+		}
+		if order.Equipment != nil {
+			subj.Title += " " + order.Equipment.Code
+		}
+		return subj, nil
+	case model.EscalationSubjectAlert:
+		alert, err := s.alertRepo.GetByID(esc.SubjectID)
+		if err != nil {
+			return nil, err
+		}
+		subj := &escalationSubject{
+			Title: fmt.Sprintf("ALERT-%d %s [%s]", alert.ID, alert.EventType, alert.Severity), Open: alert.Status == model.AlertFiring,
+			Status: model.EscalationResolved, Note: "alert " + alert.Status,
+			FactoryID: alert.FactoryID, EquipmentID: alert.EquipmentID,
+		}
+		if alert.Status == model.AlertAcknowledged {
+			subj.Status, subj.ActorID = model.EscalationAcknowledged, alert.AcknowledgedBy
+		}
+		return subj, nil
+	}
+	return nil, fmt.Errorf("unknown escalation subject %s", esc.SubjectType)
+}
+
+// advance 对象已处理时结束升级，否则通知当前一级并安排下一级
+func (s *EscalationService) advance(ctx context.Context, esc *model.Escalation, now time.Time) error {
+	subj, err := s.loadSubject(esc)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return s.close(esc.SubjectType, esc.SubjectID, model.EscalationResolved, nil, "subject deleted")
+	}
+	if err != nil {
+		return err
+	}
+	if !subj.Open {
+		return s.close(esc.SubjectType, esc.SubjectID, subj.Status, subj.ActorID, subj.Note)
+	}
+	policy, err := s.repo.GetPolicy(esc.PolicyID)
+	var steps []model.EscalationStep
+	if err == nil {
+		steps, err = parseEscalationSteps(policy.Steps)
+	}
+	if err != nil || esc.NextStep >= len(steps) {
+		// 策略被删除或修改后级数不足，视为已通知完所有级别
+		return s.exhaust(esc, "policy changed")
+	}
+
+	step := steps[esc.NextStep]
+	users, err := s.resolveTarget(step.Target, subj, now)
+	if err != nil {
+		return err
+	}
+	results := make([]escalationDelivery, 0, len(users))
+	for _, user := range users {
+		build := func(lang string) notify.Message {
+			return notify.Escalation(lang, subj.Title, esc.NextStep+1, now.Sub(esc.StartedAt))
+		}
+		sent, err := s.notifySvc.NotifyMessage(ctx, user, build)
+		if err != nil {
+			log.Printf("[Escalation] Failed to notify user %d: %v", user.ID, err)
+		}
+		results = append(results, escalationDelivery{UserID: user.ID, Sent: sent, Err: err})
+	}
+	entries := escalationStepLogs(esc.ID, esc.NextStep+1, step.Target, results)
+
+	return repository.DB.Transaction(func(tx *gorm.DB) error {
+		repo := s.repo.WithTx(tx)
+		for _, entry := range entries {
+			if err := repo.CreateLog(entry); err != nil {
+				return err
+			}
+		}
+		current, err := repo.LockOpen(esc.SubjectType, esc.SubjectID)
+		if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && current.ID != esc.ID) {
+			return nil // 通知期间已被确认
+		}
+		if err != nil {
+			return err
+		}
+		current.NextStep++
+		if current.NextStep >= len(steps) {
+			current.Status, current.NextAt = model.EscalationExhausted, nil
+			if err := repo.Save(current); err != nil {
+				return err
+			}
+			return repo.CreateLog(&model.EscalationLog{EscalationID: current.ID, Step: current.NextStep, Action: escalationLogExhausted})
+		}
+		next := escalationStepAt(current.StartedAt, steps, current.NextStep)
+		current.NextAt = &next
+		return repo.Save(current)
+	})
+}
+
+// escalationDelivery 一级升级中对单个用户的通知结果
+type escalationDelivery struct {
+	UserID uint
+	Sent   int // 发送成功的渠道数
+	Err    error
+}
+
+// escalationStepLogs 只有至少一个渠道发送成功的用户记为已通知（之后可确认升级），
+// 没有可用渠道或全部失败的用户单独记为跳过并注明原因
+func escalationStepLogs(escID uint, step int, target string, results []escalationDelivery) []*model.EscalationLog {
+	if len(results) == 0 {
+		return []*model.EscalationLog{{EscalationID: escID, Step: step, Target: target, Action: escalationLogSkipped, Note: "no recipient"}}
+	}
+	var notified, skipped, reasons []string
+	for _, r := range results {
+		id := strconv.FormatUint(uint64(r.UserID), 10)
+		switch {
+		case r.Err != nil:
+			skipped = append(skipped, id)
+			reasons = append(reasons, fmt.Sprintf("user %d: %v", r.UserID, r.Err))
+		case r.Sent == 0:
+			skipped = append(skipped, id)
+			reasons = append(reasons, fmt.Sprintf("user %d: no channel delivered", r.UserID))
+		default:
+			notified = append(notified, id)
+		}
+	}
+	var entries []*model.EscalationLog
+	if len(notified) > 0 {
+		entries = append(entries, &model.EscalationLog{
+			EscalationID: escID, Step: step, Target: target, Action: escalationLogNotified, UserIDs: strings.Join(notified, ","),
+		})
+	}
+	if len(skipped) > 0 {
+		entries = append(entries, &model.EscalationLog{
+			EscalationID: escID, Step: step, Target: target, Action: escalationLogSkipped,
+			UserIDs: strings.Join(skipped, ","), Note: strings.Join(reasons, "; "),
+		})
+	}
+	return entries
+}
+
+func (s *EscalationService) exhaust(esc *model.Escalation, note string) error {
+	esc.Status, esc.NextAt = model.EscalationExhausted, nil
+	if err := s.repo.Save(esc); err != nil {
+		return err
+	}
+	return s.repo.CreateLog(&model.EscalationLog{EscalationID: esc.ID, Step: esc.NextStep, Action: escalationLogExhausted, Note: note})
+}
+
+// resolveTarget 解析升级目标对应的在职用户
+func (s *EscalationService) resolveTarget(target string, subj *escalationSubject, now time.Time) ([]model.User, error) {
+	onCall, err := s.repo.OnCallUserIDs(subj.FactoryID, now)
+	if err != nil {
+		return nil, err
+	}
+	switch target {
+	case TargetAssignee:
+		return s.usersByID(subj.AssigneeID)
+	case TargetWorkshopSupervisor:
+		if subj.EquipmentID != 0 {
+			if eq, err := s.equipmentRepo.GetByID(subj.EquipmentID); err == nil && eq.Workshop != nil && eq.Workshop.SupervisorID != nil {
+				return s.usersByID(eq.Workshop.SupervisorID)
+			}
+		}
+		// 车间未指定主管时通知工厂的主管
+		return s.usersByRole(model.RoleSupervisor, subj.FactoryID, onCall)
+	case TargetFactoryManager:
+		if subj.FactoryID == nil {
+			return nil, nil
+		}
+		factory, err := s.factoryRepo.GetByID(*subj.FactoryID)
+		if err != nil {
+			return nil, err
+		}
+		return s.usersByID(factory.ManagerID)
+	case TargetOnCall:
+		var users []model.User
+		for i := range onCall {
+			found, err := s.usersByID(&onCall[i])
+			if err != nil {
+				return nil, err
+			}
+			users = append(users, found...)
+		}
+		return users, nil
+	}
+	if role, ok := strings.CutPrefix(target, targetRolePrefix); ok {
+		return s.usersByRole(model.UserRole(role), subj.FactoryID, onCall)
+	}
+	if raw, ok := strings.CutPrefix(target, targetUserPrefix); ok {
+		n, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			return nil, nil
+		}
+		id := uint(n)
+		return s.usersByID(&id)
+	}
+	return nil, nil
+}
+
+func (s *EscalationService) usersByID(id *uint) ([]model.User, error) {
+	if id == nil {
+		return nil, nil
+	}
+	user, err := s.userRepo.GetByID(*id)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && !user.IsActive) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []model.User{*user}, nil
+}
+
+func (s *EscalationService) usersByRole(role model.UserRole, factoryID *uint, onCall []uint) ([]model.User, error) {
+	users, err := s.userRepo.ListActiveByRoles([]string{string(role)}, factoryID)
+	if err != nil {
+		return nil, err
+	}
+	return preferOnCall(users, onCall), nil
+}
+
+// =====================================================
+// Escalation APIs
+// =====================================================
+
+func escalationVisible(user *model.User, factoryID *uint) bool {
+	if user.Role == model.RoleAdmin || user.FactoryID == nil {
+		return true
+	}
+	return factoryID != nil && *factoryID == *user.FactoryID
+}
+
+// escalationAcknowledgeable 可确认升级的人：管理员、主管、对象负责人、之前各级已通知到的人与当前值班人
+func escalationAcknowledgeable(user *model.User, esc *model.Escalation, assigneeID *uint, onCall []uint) bool {
+	if !escalationVisible(user, esc.FactoryID) {
+		return false
+	}
+	if user.Role == model.RoleAdmin || user.Role == model.RoleSupervisor {
+		return true
+	}
+	if assigneeID != nil && *assigneeID == user.ID {
+		return true
+	}
+	for _, id := range onCall {
+		if id == user.ID {
+			return true
+		}
+	}
+	self := strconv.FormatUint(uint64(user.ID), 10)
+	for _, entry := range esc.Logs {
+		if entry.Action != escalationLogNotified {
+			continue
+		}
+		for _, id := range strings.Split(entry.UserIDs, ",") {
+			if id == self {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// escalationManageable 管理员可管理全部策略与值班，主管只能管理本工厂的
+func escalationManageable(user *model.User, factoryID *uint) bool {
+	if user.Role == model.RoleAdmin {
+		return true
+	}
+	return user.Role == model.RoleSupervisor && user.FactoryID != nil && factoryID != nil && *factoryID == *user.FactoryID
+}
+
+// ListEscalations 非管理员只能查看本工厂的升级
+func (s *EscalationService) ListEscalations(user *model.User, status string, limit int) ([]model.Escalation, error) {
+	var factoryID *uint
+	if user.Role != model.RoleAdmin {
+		factoryID = user.FactoryID
+	}
+	if limit <= 0 || limit > 500 {
+		limit = defaultEscalationListLimit
+	}
+	return s.repo.List(status, factoryID, limit)
+}
+
+// GetEscalation 返回升级及其历史
+func (s *EscalationService) GetEscalation(user *model.User, id uint) (*model.Escalation, error) {
+	esc, err := s.repo.GetByID(id)
+	if err != nil || !escalationVisible(user, esc.FactoryID) {
+		return nil, ErrNotFound
+	}
+	return esc, nil
+}
+
+// Acknowledge 确认升级，停止后续各级通知；告警的升级同时确认告警
+func (s *EscalationService) Acknowledge(user *model.User, id uint) (*model.Escalation, error) {
+	esc, err := s.GetEscalation(user, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.acknowledgeSubject(user, esc.SubjectType, esc.SubjectID)
+}
+
+// AcknowledgeOrder 确认维修单：停止该维修单的升级
+func (s *EscalationService) AcknowledgeOrder(user *model.User, orderID uint) (*model.Escalation, error) {
+	if _, err := s.orderRepo.GetByID(orderID); err != nil {
+		return nil, ErrNotFound
+	}
+	return s.acknowledgeSubject(user, model.EscalationSubjectRepairOrder, orderID)
+}
+
+func (s *EscalationService) acknowledgeSubject(user *model.User, subjectType string, subjectID uint) (*model.Escalation, error) {
+	var esc *model.Escalation
+	err := repository.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		esc, err = s.repo.WithTx(tx).LockOpen(subjectType, subjectID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: no escalation in progress", ErrInvalidInput)
+		}
+		if err != nil {
+			return err
+		}
+		return s.acknowledgeOpen(tx, user, esc, time.Now())
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(esc.ID)
+}
+
+// AcknowledgeAlert 确认告警；告警仍在升级时按升级的确认权限校验，并一并结束升级
+func (s *EscalationService) AcknowledgeAlert(user *model.User, alertID uint) (*model.Alert, error) {
+	var alert *model.Alert
+	err := repository.DB.Transaction(func(tx *gorm.DB) error {
+		esc, err := s.repo.WithTx(tx).LockOpen(model.EscalationSubjectAlert, alertID)
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if alert, err = s.alertRepo.WithTx(tx).GetByID(alertID); err != nil {
+				return err
+			}
+			return s.alertRepo.WithTx(tx).Save(acknowledgeAlert(alert, user.ID, time.Now()))
+		case err != nil:
+			return err
+		}
+		return s.acknowledgeOpen(tx, user, esc, time.Now())
+	})
+	if err != nil {
+		return nil, err
+	}
+	if alert == nil {
+		return s.alertRepo.GetByID(alertID)
+	}
+	return alert, nil
+}
+
+// acknowledgeOpen 校验确认权限后确认已锁定的升级；告警的升级同时确认告警
+func (s *EscalationService) acknowledgeOpen(tx *gorm.DB, user *model.User, esc *model.Escalation, now time.Time) error {
+	if !escalationVisible(user, esc.FactoryID) {
+		return ErrNotFound
+	}
+	repo := s.repo.WithTx(tx)
+	// 带出历史，用于判断之前各级通知过谁
+	esc, err := repo.GetByID(esc.ID)
+	if err != nil {
+		return err
+	}
+	var assigneeID *uint
+	if esc.SubjectType == model.EscalationSubjectRepairOrder {
+		if order, err := s.orderRepo.GetByID(esc.SubjectID); err == nil {
+			assigneeID = order.AssignedTo
+		}
+	}
+	onCall, err := repo.OnCallUserIDs(esc.FactoryID, now)
+	if err != nil {
+		return err
+	}
+	if !escalationAcknowledgeable(user, esc, assigneeID, onCall) {
+		return ErrForbidden
+	}
+	if esc.SubjectType == model.EscalationSubjectAlert {
+		alert, err := s.alertRepo.WithTx(tx).GetByID(esc.SubjectID)
+		if err != nil {
+			return err
+		}
+		if err := s.alertRepo.WithTx(tx).Save(acknowledgeAlert(alert, user.ID, now)); err != nil {
+			return err
+		}
+	}
+	return finishEscalation(repo, esc, model.EscalationAcknowledged, &user.ID, "", now)
+}
+
+// SubjectHistory 维修单或告警的升级历史
+func (s *EscalationService) SubjectHistory(user *model.User, subjectType string, subjectID uint) ([]model.Escalation, error) {
+	escalations, err := s.repo.ListBySubject(subjectType, subjectID)
+	if err != nil {
+		return nil, err
+	}
+	visible := escalations[:0]
+	for _, esc := range escalations {
+		if escalationVisible(user, esc.FactoryID) {
+			visible = append(visible, esc)
+		}
+	}
+	return visible, nil
+}
+
+func toEscalationPolicyResponse(p *model.EscalationPolicy) dto.EscalationPolicyResponse {
+	resp := dto.EscalationPolicyResponse{ID: p.ID, Name: p.Name, FactoryID: p.FactoryID, Priority: p.Priority, Enabled: p.Enabled}
+	var steps []model.EscalationStep
+	_ = json.Unmarshal([]byte(p.Steps), &steps)
+	resp.Steps = make([]dto.EscalationStep, len(steps))
+	for i, step := range steps {
+		resp.Steps[i] = dto.EscalationStep{AfterMinutes: step.AfterMinutes, Target: step.Target}
+	}
+	return resp
+}
+
+// ListPolicies 非管理员只能查看本工厂与默认策略
+func (s *EscalationService) ListPolicies(user *model.User) ([]dto.EscalationPolicyResponse, error) {
+	var factoryID *uint
+	if user.Role != model.RoleAdmin {
+		factoryID = user.FactoryID
+	}
+	policies, err := s.repo.ListPolicies(factoryID)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]dto.EscalationPolicyResponse, len(policies))
+	for i := range policies {
+		result[i] = toEscalationPolicyResponse(&policies[i])
+	}
+	return result, nil
+}
+
+// SavePolicy 新增（id 为 0）或修改升级策略；默认策略仅管理员可修改。
+// 修改只影响之后开始的升级和进行中升级的后续各级
+func (s *EscalationService) SavePolicy(user *model.User, id uint, req *dto.EscalationPolicyRequest) (*dto.EscalationPolicyResponse, error) {
+	policy := &model.EscalationPolicy{Enabled: true}
+	if id != 0 {
+		existing, err := s.repo.GetPolicy(id)
+		if err != nil {
+			return nil, ErrNotFound
+		}
+		if !escalationManageable(user, existing.FactoryID) {
+			return nil, ErrForbidden
+		}
+		policy = existing
+	}
+	if !escalationManageable(user, req.FactoryID) {
+		return nil, ErrForbidden
+	}
+	steps := make([]model.EscalationStep, len(req.Steps))
+	for i, step := range req.Steps {
+		steps[i] = model.EscalationStep{AfterMinutes: step.AfterMinutes, Target: strings.TrimSpace(step.Target)}
+	}
+	if err := validateEscalationSteps(steps); err != nil {
+		return nil, err
+	}
+	raw, _ := json.Marshal(steps)
+	policy.Name, policy.FactoryID, policy.Priority, policy.Steps = req.Name, req.FactoryID, req.Priority, string(raw)
+	if req.Enabled != nil {
+		policy.Enabled = *req.Enabled
+	}
+	if err := s.repo.SavePolicy(policy); err != nil {
+		return nil, err
+	}
+	resp := toEscalationPolicyResponse(policy)
+	return &resp, nil
+}
+
+// DeletePolicy 删除策略；进行中的升级在下一级到期时结束
+func (s *EscalationService) DeletePolicy(user *model.User, id uint) error {
+	policy, err := s.repo.GetPolicy(id)
+	if err != nil {
+		return ErrNotFound
+	}
+	if !escalationManageable(user, policy.FactoryID) {
+		return ErrForbidden
+	}
+	return s.repo.DeletePolicy(id)
+}
+
+// =====================================================
+// On-call APIs
+// =====================================================
+
+// ListShifts 值班表（默认从 from 起 7 天），非管理员只能查看本工厂（含不限工厂）的值班
+func (s *EscalationService) ListShifts(user *model.User, from, to *time.Time) ([]model.OnCallShift, error) {
+	start := time.Now()
+	if from != nil {
+		start = *from
+	}
+	end := start.AddDate(0, 0, defaultOnCallDays)
+	if to != nil {
+		end = *to
+	}
+	var factoryID *uint
+	if user.Role != model.RoleAdmin {
+		factoryID = user.FactoryID
+	}
+	return s.repo.ListShifts(factoryID, start, end)
+}
+
+// CurrentOnCall 当前值班人
+func (s *EscalationService) CurrentOnCall(user *model.User) ([]model.OnCallShift, error) {
+	now := time.Now()
+	return s.ListShifts(user, &now, &now)
+}
+
+// CreateShift 新增值班
+func (s *EscalationService) CreateShift(user *model.User, req *dto.OnCallShiftRequest) (*model.OnCallShift, error) {
+	if !escalationManageable(user, req.FactoryID) {
+		return nil, ErrForbidden
+	}
+	if !req.EndAt.After(req.StartAt) {
+		return nil, fmt.Errorf("%w: end_at must be after start_at", ErrInvalidInput)
+	}
+	if users, err := s.usersByID(&req.UserID); err != nil || len(users) == 0 {
+		return nil, fmt.Errorf("%w: user %d is not an active user", ErrInvalidInput, req.UserID)
+	}
+	shift := &model.OnCallShift{FactoryID: req.FactoryID, UserID: req.UserID, StartAt: req.StartAt, EndAt: req.EndAt, Note: req.Note}
+	if err := s.repo.CreateShift(shift); err != nil {
+		return nil, err
+	}
+	return shift, nil
+}
+
+// DeleteShift 删除值班
+func (s *EscalationService) DeleteShift(user *model.User, id uint) error {
+	shift, err := s.repo.GetShift(id)
+	if err != nil {
+		return ErrNotFound
+	}
+	if !escalationManageable(user, shift.FactoryID) {
+		return ErrForbidden
+	}
+	return s.repo.DeleteShift(id)
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ems/backend/internal/model"
+)
+
+func TestValidateEscalationSteps(t *testing.T) {
+	valid := []model.EscalationStep{
+		{AfterMinutes: 0, Target: TargetAssignee},
+		{AfterMinutes: 15, Target: TargetWorkshopSupervisor},
+		{AfterMinutes: 60, Target: TargetFactoryManager},
+		{AfterMinutes: 60, Target: "role:engineer"},
+		{AfterMinutes: 120, Target: "user:7"},
+	}
+	if err := validateEscalationSteps(valid); err != nil {
+		t.Fatalf("Expected steps to be valid, got %v", err)
+	}
+
+	invalid := [][]model.EscalationStep{
+		nil,
+		{{AfterMinutes: 0, Target: "role:nobody"}},
+		{{AfterMinutes: 0, Target: "user:0"}},
+		{{AfterMinutes: -1, Target: TargetOnCall}},
+		{{AfterMinutes: 30, Target: TargetAssignee}, {AfterMinutes: 15, Target: TargetFactoryManager}},
+	}
+	for _, steps := range invalid {
+		if err := validateEscalationSteps(steps); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("Expected %+v to be rejected, got %v", steps, err)
+		}
+	}
+}
+
+func TestEscalationStepAt(t *testing.T) {
+	started := time.Date(2026, 5, 1, 8, 0, 0, 0, time.UTC)
+	steps := []model.EscalationStep{{AfterMinutes: 0}, {AfterMinutes: 15}, {AfterMinutes: 60}}
+	if at := escalationStepAt(started, steps, 0); !at.Equal(started) {
+		t.Errorf("Expected first step immediately, got %s", at)
+	}
+	if at := escalationStepAt(started, steps, 2); !at.Equal(started.Add(time.Hour)) {
+		t.Errorf("Expected third step one hour after start, got %s", at)
+	}
+}
+
+func TestPreferOnCall(t *testing.T) {
+	users := []model.User{{BaseModel: model.BaseModel{ID: 1}}, {BaseModel: model.BaseModel{ID: 2}}, {BaseModel: model.BaseModel{ID: 3}}}
+	if picked := preferOnCall(users, []uint{3, 9}); len(picked) != 1 || picked[0].ID != 3 {
+		t.Errorf("Expected only the on-call user, got %+v", picked)
+	}
+	if picked := preferOnCall(users, []uint{9}); len(picked) != 3 {
+		t.Errorf("Expected all candidates when none is on call, got %d", len(picked))
+	}
+}
+
+func TestAlertEscalationPriority(t *testing.T) {
+	cases := map[string]int{SeverityCritical: 1, SeverityHigh: 2, SeverityMedium: 3, SeverityLow: 0}
+	for severity, want := range cases {
+		if got := alertEscalationPriority(severity); got != want {
+			t.Errorf("%s: expected priority %d, got %d", severity, want, got)
+		}
+	}
+}
+
+func TestEscalationAcknowledgeable(t *testing.T) {
+	factory, other := uint(1), uint(2)
+	assignee := uint(11)
+	esc := &model.Escalation{FactoryID: &factory, Logs: []model.EscalationLog{
+		{Action: escalationLogNotified, UserIDs: "21,22"},
+		{Action: escalationLogSkipped, UserIDs: "23"},
+	}}
+	user := func(id uint, role model.UserRole, factoryID *uint) *model.User {
+		return &model.User{BaseModel: model.BaseModel{ID: id}, Role: role, FactoryID: factoryID}
+	}
+	cases := []struct {
+		name string
+		user *model.User
+		want bool
+	}{
+		{"admin", user(1, model.RoleAdmin, nil), true},
+		{"supervisor", user(2, model.RoleSupervisor, &factory), true},
+		{"supervisor of another factory", user(3, model.RoleSupervisor, &other), false},
+		{"assignee", user(assignee, model.RoleMaintenance, &factory), true},
+		{"notified earlier", user(22, model.RoleEngineer, &factory), true},
+		{"on call", user(31, model.RoleMaintenance, &factory), true},
+		{"skipped step is not a notification", user(23, model.RoleMaintenance, &factory), false},
+		{"bystander", user(41, model.RoleOperator, &factory), false},
+	}
+	for _, c := range cases {
+		if got := escalationAcknowledgeable(c.user, esc, &assignee, []uint{31}); got != c.want {
+			t.Errorf("%s: expected %v, got %v", c.name, c.want, got)
+		}
+	}
+}
+
+func TestEscalationStepLogs(t *testing.T) {
+	entries := escalationStepLogs(7, 2, "on_call", []escalationDelivery{
+		{UserID: 21, Sent: 1},
+		{UserID: 22},
+		{UserID: 23, Err: errors.New("preferences unavailable")},
+	})
+	if len(entries) != 2 {
+		t.Fatalf("Expected a notified and a skipped entry, got %+v", entries)
+	}
+	if entries[0].Action != escalationLogNotified || entries[0].UserIDs != "21" {
+		t.Errorf("Expected only the delivered user to be notified, got %+v", entries[0])
+	}
+	if entries[1].Action != escalationLogSkipped || entries[1].UserIDs != "22,23" || entries[1].Note == "" {
+		t.Errorf("Expected undelivered users to be skipped with a reason, got %+v", entries[1])
+	}
+
+	// 只记为跳过的用户不能确认升级
+	factory := uint(1)
+	esc := &model.Escalation{FactoryID: &factory, Logs: []model.EscalationLog{*entries[0], *entries[1]}}
+	if !escalationAcknowledgeable(&model.User{BaseModel: model.BaseModel{ID: 21}, Role: model.RoleMaintenance, FactoryID: &factory}, esc, nil, nil) {
+		t.Error("Expected the delivered user to acknowledge")
+	}
+	if escalationAcknowledgeable(&model.User{BaseModel: model.BaseModel{ID: 22}, Role: model.RoleMaintenance, FactoryID: &factory}, esc, nil, nil) {
+		t.Error("Expected a user whose channels all failed not to acknowledge")
+	}
+
+	none := escalationStepLogs(7, 1, "assignee", nil)
+	if len(none) != 1 || none[0].Action != escalationLogSkipped || none[0].Note != "no recipient" {
+		t.Errorf("Expected a single no-recipient entry, got %+v", none)
+	}
+}
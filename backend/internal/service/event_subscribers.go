@@ -0,0 +1,24 @@
+package service
+
+import (
+	agentService "github.com/ems/backend/internal/agent/service"
+	"github.com/ems/backend/internal/event"
+	"github.com/ems/backend/internal/model"
+)
+
+// alertRoles 接收事件提醒的角色，按事件所属工厂过滤
+var alertRoles = []string{string(model.RoleSupervisor), string(model.RoleEngineer)}
+
+// RegisterEventSubscribers 在总线上注册推送、通知与 Agent 订阅者
+func RegisterEventSubscribers(bus *event.Bus) {
+	agentSvc := agentService.NewAgentService()
+	alerts := NewAlertManager()
+	escalations := NewEscalationService()
+
+	bus.Subscribe("agent.push", agentSvc.HandleDomainEvent)
+	bus.Subscribe("agent.equipment_notes", agentSvc.HandleRepairClosed, event.RepairStatusChanged)
+	bus.Subscribe("notify.alert", alerts.Handle,
+		event.RepairCreated, event.InspectionNGDetected, event.MaintenanceOverdue, event.SparePartBelowSafetyStock,
+		event.EquipmentRULRisk, event.RepairSLABreached, event.RepairStatusChanged)
+	bus.Subscribe("notify.escalation", escalations.Handle, event.RepairCreated, event.RepairStatusChanged)
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"github.com/ems/backend/internal/event"
+	"github.com/ems/backend/internal/model"
+	"github.com/ems/backend/internal/repository"
+	"gorm.io/gorm"
+)
+
+// equipmentSubject 事件的工厂与设备范围；需要预加载 Workshop
+func equipmentSubject(e *model.Equipment) event.Subject {
+	s := event.Subject{EquipmentID: e.ID}
+	if e.Workshop != nil {
+		factoryID := e.Workshop.FactoryID
+		s.FactoryID = &factoryID
+	}
+	return s
+}
+
+func subjectOf(equipRepo *repository.EquipmentRepository, equipmentID uint) event.Subject {
+	if e, err := equipRepo.GetByID(equipmentID); err == nil {
+		return equipmentSubject(e)
+	}
+	return event.Subject{EquipmentID: equipmentID}
+}
+
+// setEquipmentStatus 在事务中更新设备状态，状态确有变化时发布 equipment.status_changed
+func setEquipmentStatus(tx *gorm.DB, equipRepo *repository.EquipmentRepository, equipment *model.Equipment, status, reason string) error {
+	from := equipment.Status
+	if from == status {
+		return nil
+	}
+	equipment.Status = status
+	if err := equipRepo.WithTx(tx).Update(equipment); err != nil {
+		return err
+	}
+	return event.Publish(tx, event.EquipmentStatusChangedPayload{
+		Subject: equipmentSubject(equipment), EquipmentCode: equipment.Code, From: from, To: status, Reason: reason,
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/ems/backend/internal/event"
 	"github.com/ems/backend/internal/model"
 	"github.com/ems/backend/internal/repository"
 	"gorm.io/gorm"
@@ -237,6 +238,7 @@ func (s *InspectionTaskService) GetByID(id uint) (*model.InspectionTask, error)
 func (s *InspectionTaskService) List(filter *InspectionTaskFilter) (*InspectionTaskListResult, error) {
 	repoFilter := repository.InspectionTaskFilter{
 		AssignedTo: filter.AssignedTo,
+		FactoryID:  filter.FactoryID,
 		Status:     filter.Status,
 		Page:       filter.Page,
 		PageSize:   filter.PageSize,
@@ -374,6 +376,7 @@ func (s *InspectionTaskService) CompleteInspection(
 	// Create records
 	var recordModels []model.InspectionRecord
 	var ngItemIDs []uint
+	var ngItems []event.NGItem
 	var ngDescriptions []string
 	var ngPhotos []string
 
@@ -397,17 +400,13 @@ func (s *InspectionTaskService) CompleteInspection(
 			}
 			desc := fmt.Sprintf("- %s: %s", itemName, r.Remark)
 			ngDescriptions = append(ngDescriptions, desc)
+			ngItems = append(ngItems, event.NGItem{ItemID: r.ItemID, Name: itemName, Remark: r.Remark, PhotoURL: r.PhotoURL})
 			if r.PhotoURL != "" {
 				ngPhotos = append(ngPhotos, r.PhotoURL)
 			}
 		}
 	}
 
-	// Save records
-	if err := s.recordRepo.CreateBatch(recordModels); err != nil {
-		return nil, err
-	}
-
 	// Update task
 	now := time.Now()
 	task.Status = "completed"
@@ -415,46 +414,21 @@ func (s *InspectionTaskService) CompleteInspection(
 	task.Latitude = latitude
 	task.Longitude = longitude
 
-	if err := s.taskRepo.Update(task); err != nil {
-		return nil, err
-	}
-
-	// Trigger repair workflow if there are NG items
-	if len(ngItemIDs) > 0 {
-		faultDesc := "点检发现异常:\n" + fmt.Sprintf("%v", ngDescriptions)
-		if len(ngDescriptions) > 0 {
-			faultDesc = "点检发现异常:\n"
-			for _, d := range ngDescriptions {
-				faultDesc += d + "\n"
-			}
+	// 记录、任务状态、NG 维修单与领域事件在同一事务中提交
+	err = repository.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.recordRepo.WithTx(tx).CreateBatch(recordModels); err != nil {
+			return err
 		}
-
-		order := &model.RepairOrder{
-			EquipmentID:      task.EquipmentID,
-			FaultDescription: faultDesc,
-			FaultCode:        "INSPECTION_NG",
-			Photos:           ngPhotos,
-			Priority:         2, // Medium
-			ReporterID:       userID,
-			Status:           model.RepairPending,
+		if err := s.taskRepo.WithTx(tx).Update(task); err != nil {
+			return err
 		}
-
-		if err := s.repairRepo.Create(order); err == nil {
-			// Update equipment status
-			if equip, err := s.equipRepo.GetByID(task.EquipmentID); err == nil {
-				equip.Status = "maintenance"
-				s.equipRepo.Update(equip)
-			}
-
-			// Create log
-			log := &model.RepairLog{
-				OrderID: order.ID,
-				UserID:  userID,
-				Action:  "created",
-				Content: "通过点检 NG 自动创建",
-			}
-			s.logRepo.Create(log)
+		if len(ngItemIDs) == 0 {
+			return nil
 		}
+		return s.triggerNGRepair(tx, task, userID, ngItems, ngDescriptions, ngPhotos)
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// Get total items for count
@@ -471,12 +445,58 @@ func (s *InspectionTaskService) CompleteInspection(
 	}, nil
 }
 
+// triggerNGRepair 点检 NG 时自动创建维修单，并发布点检 NG、新建维修单与设备状态事件
+func (s *InspectionTaskService) triggerNGRepair(tx *gorm.DB, task *model.InspectionTask, userID uint, items []event.NGItem, descriptions, photos []string) error {
+	faultDesc := "点检发现异常:\n"
+	for _, d := range descriptions {
+		faultDesc += d + "\n"
+	}
+
+	order := &model.RepairOrder{
+		EquipmentID:      task.EquipmentID,
+		FaultDescription: faultDesc,
+		FaultCode:        "INSPECTION_NG",
+		Photos:           photos,
+		Priority:         2, // Medium
+		ReporterID:       userID,
+		Status:           model.RepairPending,
+	}
+	if err := s.repairRepo.WithTx(tx).Create(order); err != nil {
+		return err
+	}
+	if err := s.logRepo.WithTx(tx).Create(&model.RepairLog{OrderID: order.ID, UserID: userID, Action: "created", Content: "通过点检 NG 自动创建"}); err != nil {
+		return err
+	}
+
+	subject := event.Subject{EquipmentID: task.EquipmentID}
+	equipmentCode := ""
+	if equip, err := s.equipRepo.GetByID(task.EquipmentID); err == nil {
+		subject, equipmentCode = equipmentSubject(equip), equip.Code
+		// Update equipment status
+		if err := setEquipmentStatus(tx, s.equipRepo, equip, "maintenance", fmt.Sprintf("repair_order:%d", order.ID)); err != nil {
+			return err
+		}
+	}
+
+	if err := event.Publish(tx, event.InspectionNGDetectedPayload{
+		Subject: subject, TaskID: task.ID, InspectorID: userID, Items: items, RepairOrderID: order.ID,
+	}); err != nil {
+		return err
+	}
+	return event.Publish(tx, event.RepairCreatedPayload{
+		Subject: subject, OrderID: order.ID, EquipmentCode: equipmentCode,
+		FaultDescription: order.FaultDescription, FaultCode: order.FaultCode, Priority: order.Priority,
+		ReporterID: userID, Source: "inspection_ng",
+	})
+}
+
 func (s *InspectionTaskService) Delete(id uint) error {
 	return s.taskRepo.Delete(id)
 }
 
-func (s *InspectionTaskService) GetStatistics() (*InspectionStatistics, error) {
-	stats, err := s.taskRepo.GetStatistics()
+// GetStatistics 点检任务统计，factoryID 不为空时只统计该工厂
+func (s *InspectionTaskService) GetStatistics(factoryID *uint) (*InspectionStatistics, error) {
+	stats, err := s.taskRepo.GetStatistics(factoryID)
 	if err != nil {
 		return nil, err
 	}
@@ -500,6 +520,23 @@ func (s *InspectionTaskService) GetStatistics() (*InspectionStatistics, error) {
 	}, nil
 }
 
+// GetEquipmentHistory returns recent inspection tasks of an equipment with their item results
+func (s *InspectionTaskService) GetEquipmentHistory(equipmentID uint, dateFrom, dateTo time.Time, limit int) ([]model.InspectionTask, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	return s.taskRepo.GetHistoryByEquipment(equipmentID, dateFrom, dateTo, limit)
+}
+
+// GetNGTrend returns daily NG counts for the last days, optionally for a single equipment or factory
+func (s *InspectionTaskService) GetNGTrend(equipmentID uint, factoryID *uint, days int) ([]repository.NGTrendPoint, error) {
+	if days <= 0 {
+		days = 30
+	}
+	since := time.Now().AddDate(0, 0, -days+1).Truncate(24 * time.Hour)
+	return s.recordRepo.GetNGTrend(repository.NGTrendFilter{EquipmentID: equipmentID, FactoryID: factoryID, Since: since})
+}
+
 func (s *InspectionTaskService) GetMyStatistics(userID uint) (*MyTasksStatistics, error) {
 	stats, err := s.taskRepo.GetUserStatistics(userID)
 	if err != nil {
@@ -589,6 +626,7 @@ func (s *InspectionTaskService) UpdateOverdueTasks() (int, error) {
 // Types
 type InspectionTaskFilter struct {
 	AssignedTo uint
+	FactoryID  *uint
 	Status     string
 	DateFrom   time.Time
 	DateTo     time.Time
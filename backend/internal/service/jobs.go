@@ -0,0 +1,248 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	agentRepository "github.com/ems/backend/internal/agent/repository"
+	"github.com/ems/backend/internal/dto"
+	"github.com/ems/backend/internal/model"
+	"github.com/ems/backend/internal/repository"
+	"github.com/ems/backend/internal/scheduler"
+	"github.com/ems/backend/pkg/config"
+	"github.com/ems/backend/pkg/redis"
+	"gorm.io/gorm"
+)
+
+// 内置定时任务；任务名用作配置键，只能包含字母、数字与下划线
+const (
+	JobInspectionOverdue  = "inspection_overdue"
+	JobMaintenanceOverdue = "maintenance_overdue"
+	JobMaintenancePlan    = "maintenance_plan"
+	JobExperienceDecay    = "experience_decay"
+	JobRepairSLAScan      = "repair_sla_scan"
+	JobRULRiskScan        = "rul_risk_scan"
+	JobAlertDigest        = "alert_digest"
+	JobAlertAutoResolve   = "alert_auto_resolve"
+	JobEscalation         = "escalation"
+	JobBriefing           = "briefing"
+	JobHistoryPurge       = "job_history_purge"
+)
+
+const (
+	defaultJobRunLimit = 50
+	jobRunRetention    = 30 * 24 * time.Hour
+)
+
+// JobService 注册内置定时任务并提供任务查看、手动触发与暂停
+type JobService struct {
+	scheduler *scheduler.Scheduler
+	repo      *repository.SchedulerRepository
+}
+
+func NewJobService() (*JobService, error) {
+	repo := repository.NewSchedulerRepository()
+	locker, err := newJobLocker(config.Cfg.Scheduler.Lock)
+	if err != nil {
+		return nil, err
+	}
+	s := &JobService{scheduler: scheduler.New(repo, locker), repo: repo}
+	for _, job := range s.builtinJobs() {
+		if o, ok := config.Cfg.Scheduler.Jobs[job.Name]; ok {
+			if o.Cron != "" {
+				job.Cron = o.Cron
+			}
+			if o.Misfire != "" {
+				job.Misfire = o.Misfire
+			}
+		}
+		if err := s.scheduler.Register(job); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// newJobLocker 默认使用 Postgres advisory lock；配置为 redis 但 Redis 不可用时回退到 Postgres
+func newJobLocker(kind string) (scheduler.Locker, error) {
+	if kind == "redis" {
+		if redis.Client != nil && redis.Client.Ping(context.Background()).Err() == nil {
+			return scheduler.NewRedisLocker(redis.Client), nil
+		}
+		log.Printf("[Scheduler] Redis unavailable, falling back to Postgres advisory locks")
+	}
+	sqlDB, err := repository.DB.DB()
+	if err != nil {
+		return nil, err
+	}
+	return scheduler.NewPostgresLocker(sqlDB), nil
+}
+
+func (s *JobService) builtinJobs() []scheduler.Job {
+	inspection := NewInspectionTaskService()
+	maintenance := NewMaintenanceTaskService()
+	scan := NewAlertScanService()
+	alerts := NewAlertManager()
+	escalations := NewEscalationService()
+	briefings := NewBriefingService()
+	agentRepo := agentRepository.NewDBAgentRepository(repository.DB)
+
+	return []scheduler.Job{
+		{Name: JobInspectionOverdue, Description: "将过期未执行的点检任务标记为逾期", Cron: "*/10 * * * *",
+			Run: func(context.Context) (string, error) {
+				n, err := inspection.UpdateOverdueTasks()
+				return fmt.Sprintf("%d inspection tasks marked overdue", n), err
+			}},
+		{Name: JobMaintenanceOverdue, Description: "将过期的保养任务标记为逾期并发布 maintenance.overdue", Cron: "*/10 * * * *",
+			Run: func(context.Context) (string, error) {
+				n, err := maintenance.UpdateOverdueTasks()
+				return fmt.Sprintf("%d maintenance tasks marked overdue", n), err
+			}},
+		{Name: JobMaintenancePlan, Description: "按保养计划滚动生成与重排保养任务", Cron: "0 1 * * *",
+			Run: func(context.Context) (string, error) {
+				result, err := maintenance.ScheduleFromPlans(time.Now())
+				if err != nil {
+					return "", err
+				}
+				return result.String(), nil
+			}},
+		{Name: JobExperienceDecay, Description: "按衰减率降低 Agent 经验权重", Cron: "0 3 * * *", Misfire: scheduler.MisfireSkip,
+			Run: func(context.Context) (string, error) {
+				return "experience weights decayed", agentRepo.ApplyDecayToExperiences()
+			}},
+		{Name: JobRepairSLAScan, Description: "巡检维修单 SLA，超时发布 repair.sla_breached", Cron: "*/15 * * * *",
+			Run: func(context.Context) (string, error) {
+				n, err := scan.CheckRepairSLA(time.Now())
+				return fmt.Sprintf("%d repair orders breached SLA", n), err
+			}},
+		{Name: JobRULRiskScan, Description: "预测运行中设备的剩余寿命，风险设备发布 equipment.rul_risk", Cron: "0 * * * *",
+			Timeout: 30 * time.Minute,
+			Run: func(context.Context) (string, error) {
+				n, err := scan.ScanRULRisks(time.Now())
+				return fmt.Sprintf("%d equipment at RUL risk", n), err
+			}},
+		{Name: JobAlertDigest, Description: "发送到期的告警摘要", Cron: "* * * * *",
+			Run: func(ctx context.Context) (string, error) {
+				n, err := alerts.FlushDigests(ctx, time.Now())
+				return fmt.Sprintf("%d alert digests sent", n), err
+			}},
+		{Name: JobAlertAutoResolve, Description: "自动解决长时间无新事件的告警", Cron: "*/5 * * * *",
+			Run: func(context.Context) (string, error) {
+				n, err := alerts.ResolveStale(time.Now())
+				return fmt.Sprintf("%d alerts auto-resolved", n), err
+			}},
+		{Name: JobEscalation, Description: "按升级链通知未确认的维修单与告警", Cron: "* * * * *",
+			Run: func(ctx context.Context) (string, error) {
+				n, err := escalations.ProcessDue(ctx, time.Now())
+				return fmt.Sprintf("%d escalations processed", n), err
+			}},
+		{Name: JobBriefing, Description: "生成到期的 Agent 简报", Cron: "* * * * *", Timeout: 30 * time.Minute,
+			Run: func(context.Context) (string, error) {
+				n, err := briefings.RunDue(time.Now())
+				return fmt.Sprintf("%d briefings run", n), err
+			}},
+		{Name: JobHistoryPurge, Description: "清理 30 天前的任务执行记录", Cron: "30 4 * * *", Misfire: scheduler.MisfireSkip,
+			Run: func(context.Context) (string, error) {
+				n, err := s.repo.PurgeRuns(time.Now().Add(-jobRunRetention))
+				return fmt.Sprintf("%d job runs purged", n), err
+			}},
+	}
+}
+
+// Run 执行调度循环，直到 ctx 结束
+func (s *JobService) Run(ctx context.Context) {
+	s.scheduler.Run(ctx)
+}
+
+// Sync 写入任务的初始调度状态，供未运行调度循环的实例查看任务
+func (s *JobService) Sync() error {
+	return s.scheduler.Sync()
+}
+
+func toJobResponse(job scheduler.Job, state *model.ScheduledJob) dto.JobResponse {
+	resp := dto.JobResponse{Name: job.Name, Description: job.Description, Cron: job.Cron, Misfire: job.Misfire}
+	if state != nil {
+		resp.Paused, resp.NextRunAt, resp.LastRunAt = state.Paused, state.NextRunAt, state.LastRunAt
+		resp.LastStatus, resp.LastError, resp.LastDurationMs = state.LastStatus, state.LastError, state.LastDurationMs
+	}
+	return resp
+}
+
+// ListJobs 返回全部任务及其调度状态
+func (s *JobService) ListJobs() ([]dto.JobResponse, error) {
+	states, err := s.repo.ListJobs()
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]*model.ScheduledJob, len(states))
+	for i := range states {
+		byName[states[i].Name] = &states[i]
+	}
+	jobs := s.scheduler.Jobs()
+	result := make([]dto.JobResponse, len(jobs))
+	for i, job := range jobs {
+		result[i] = toJobResponse(job, byName[job.Name])
+	}
+	return result, nil
+}
+
+func (s *JobService) findJob(name string) (scheduler.Job, error) {
+	for _, job := range s.scheduler.Jobs() {
+		if job.Name == name {
+			return job, nil
+		}
+	}
+	return scheduler.Job{}, ErrNotFound
+}
+
+// GetJob 返回任务及其调度状态
+func (s *JobService) GetJob(name string) (*dto.JobResponse, error) {
+	job, err := s.findJob(name)
+	if err != nil {
+		return nil, err
+	}
+	state, err := s.repo.GetJob(name)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	resp := toJobResponse(job, state)
+	return &resp, nil
+}
+
+// ListRuns 任务最近的执行记录
+func (s *JobService) ListRuns(name string, q *dto.JobRunQuery) ([]model.JobRun, error) {
+	if _, err := s.findJob(name); err != nil {
+		return nil, err
+	}
+	limit := q.Limit
+	if limit <= 0 || limit > 500 {
+		limit = defaultJobRunLimit
+	}
+	return s.repo.ListRuns(name, q.Status, limit)
+}
+
+// Trigger 立即在后台执行一次任务；任务正在执行时返回 scheduler.ErrJobRunning
+func (s *JobService) Trigger(ctx context.Context, name string) error {
+	err := s.scheduler.Trigger(ctx, name)
+	if errors.Is(err, scheduler.ErrUnknownJob) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// SetPaused 暂停或恢复任务
+func (s *JobService) SetPaused(name string, paused bool) (*dto.JobResponse, error) {
+	job, err := s.findJob(name)
+	if err != nil {
+		return nil, err
+	}
+	state, err := s.scheduler.SetPaused(name, paused)
+	if err != nil {
+		return nil, err
+	}
+	resp := toJobResponse(job, state)
+	return &resp, nil
+}
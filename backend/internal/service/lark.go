@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log"
+	"net/url"
 
 	agentDto "github.com/ems/backend/internal/agent/dto"
 	agentService "github.com/ems/backend/internal/agent/service"
@@ -17,6 +19,9 @@ import (
 
 type LarkService struct {
 	userRepo     *repository.UserRepository
+	cardRepo     *repository.LarkAlertCardRepository
+	alertRepo    *repository.AlertRepository
+	repairSvc    *RepairOrderService
 	agentService *agentService.AgentService
 }
 
@@ -25,6 +30,9 @@ func NewLarkService() *LarkService {
 	// We'll still create the service, but repository calls might fail if not careful
 	return &LarkService{
 		userRepo:     repository.NewUserRepository(),
+		cardRepo:     repository.NewLarkAlertCardRepository(),
+		alertRepo:    repository.NewAlertRepository(),
+		repairSvc:    NewRepairOrderService(),
 		agentService: agentService.NewAgentService(),
 	}
 }
@@ -56,26 +64,16 @@ func (s *LarkService) HandleIncomingMessage(ctx context.Context, botUser model.U
 	}
 
 	// 1. Try to find bound user (the sender)
-	var user *model.User
-	if config.Cfg.Storage.Mode == "memory" {
-		store := memory.GetStore()
-		for _, u := range store.Users {
-			if u.LarkOpenID != nil && *u.LarkOpenID == openID {
-				user = u
-				break
-			}
-		}
-	} else {
-		var err error
-		user, err = s.userRepo.GetByLarkOpenID(openID)
-		if err != nil {
-			user = nil
-		}
-	}
-
+	user := s.findBoundUser(openID)
 	if user == nil {
 		// Not bound, send binding link
-		return s.sendBindingGuide(ctx, client, openID)
+		return s.sendBindingGuide(ctx, client, *botUser.LarkAppID, openID)
+	}
+	// 早期绑定未记录应用：消息来自哪个机器人，open_id 就属于哪个应用
+	if (user.LarkBotAppID == nil || *user.LarkBotAppID == "") && config.Cfg.Storage.Mode != "memory" {
+		if err := s.userRepo.UpdateLarkBotAppID(user.ID, *botUser.LarkAppID); err != nil {
+			log.Printf("[Lark] Failed to record bot app of user %d: %v", user.ID, err)
+		}
 	}
 
 	// 2. Parse text content
@@ -89,7 +87,7 @@ func (s *LarkService) HandleIncomingMessage(ctx context.Context, botUser model.U
 		Message: content.Text,
 	}
 
-	resp, err := s.agentService.Chat(*user, chatReq)
+	resp, err := s.agentService.Chat(ctx, *user, chatReq)
 	if err != nil {
 		return client.SendTextMessage(ctx, "open_id", openID, "抱歉，分析过程中出现了点问题："+err.Error())
 	}
@@ -97,16 +95,73 @@ func (s *LarkService) HandleIncomingMessage(ctx context.Context, botUser model.U
 	return client.SendTextMessage(ctx, "open_id", openID, resp.Reply)
 }
 
-func (s *LarkService) sendBindingGuide(ctx context.Context, client *lark.Client, openID string) error {
-	baseURL := config.Cfg.App.BaseURL
-	if baseURL == "" {
-		baseURL = "http://localhost:5173" // Fallback
+// findBoundUser 按飞书 open_id 查找已绑定的 EMS 用户，未绑定返回 nil
+func (s *LarkService) findBoundUser(openID string) *model.User {
+	if openID == "" {
+		return nil
+	}
+	if config.Cfg.Storage.Mode == "memory" {
+		for _, u := range memory.GetStore().Users {
+			if u.LarkOpenID != nil && *u.LarkOpenID == openID {
+				return u
+			}
+		}
+		return nil
+	}
+	user, err := s.userRepo.GetByLarkOpenID(openID)
+	if err != nil {
+		return nil
+	}
+	return user
+}
+
+// appBaseURL 前端访问地址，用于生成绑定与详情链接
+func appBaseURL() string {
+	if config.Cfg.App.BaseURL != "" {
+		return config.Cfg.App.BaseURL
 	}
-	bindURL := fmt.Sprintf("%s/h5/bind-lark?openid=%s", baseURL, openID)
+	return "http://localhost:5173" // Fallback
+}
+
+func (s *LarkService) sendBindingGuide(ctx context.Context, client *lark.Client, appID, openID string) error {
+	bindURL := fmt.Sprintf("%s/h5/bind-lark?openid=%s&app_id=%s", appBaseURL(), url.QueryEscape(openID), url.QueryEscape(appID))
 	text := fmt.Sprintf("您尚未绑定 EMS 系统账号。请点击下方链接完成身份验证后，即可在飞书中使用智能助手：\n%s", bindURL)
 	return client.SendTextMessage(ctx, "open_id", openID, text)
 }
 
-func (s *LarkService) BindUser(userID uint, openID string) error {
-	return s.userRepo.UpdateLarkOpenID(userID, openID)
+// BindUser 绑定飞书 open_id；appID 为发送绑定链接的机器人应用
+func (s *LarkService) BindUser(userID uint, openID, appID string) error {
+	return s.userRepo.UpdateLarkOpenID(userID, openID, appID)
+}
+
+// botFor 选择用户绑定时所在应用的飞书机器人（未记录时为同工厂的机器人），返回客户端与接收人的 open_id。
+// open_id 按应用区分，不能通过其他应用的机器人发送
+func (s *LarkService) botFor(recipient model.User) (*lark.Client, string, error) {
+	if recipient.LarkOpenID == nil || *recipient.LarkOpenID == "" {
+		return nil, "", fmt.Errorf("user %d has not bound lark", recipient.ID)
+	}
+	var bot *model.User
+	var err error
+	if recipient.LarkBotAppID != nil && *recipient.LarkBotAppID != "" {
+		bot, err = s.userRepo.GetByLarkAppID(*recipient.LarkBotAppID)
+	} else {
+		bot, err = s.userRepo.FindLarkBot(recipient.FactoryID)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("no lark bot for user %d: %w", recipient.ID, err)
+	}
+	client, err := s.getClient(*bot)
+	if err != nil {
+		return nil, "", err
+	}
+	return client, *recipient.LarkOpenID, nil
+}
+
+// SendTextToUser 通过用户绑定的飞书机器人向已绑定的 EMS 用户推送文本
+func (s *LarkService) SendTextToUser(ctx context.Context, recipient model.User, text string) error {
+	client, openID, err := s.botFor(recipient)
+	if err != nil {
+		return err
+	}
+	return client.SendTextMessage(ctx, "open_id", openID, text)
 }
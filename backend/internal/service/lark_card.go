@@ -0,0 +1,287 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ems/backend/internal/dto"
+	"github.com/ems/backend/internal/event"
+	"github.com/ems/backend/internal/model"
+	"github.com/ems/backend/internal/repository"
+	"github.com/ems/backend/pkg/lark"
+)
+
+// 提醒卡片按钮的回调动作；"查看详情"为链接按钮，不产生回调
+const (
+	CardActionAssignToMe   = "assign_to_me"
+	CardActionCreateRepair = "create_repair"
+	CardActionAcknowledge  = "acknowledge"
+)
+
+// alertView 领域事件在飞书提醒中的展示内容与可执行动作
+type alertView struct {
+	Title         string
+	Template      string // 卡片标题颜色
+	Body          string
+	EquipmentID   uint
+	RepairOrderID uint
+	Assignable    bool // 关联维修单待指派，可"指派给我"
+	Repairable    bool // 无关联维修单，可"创建维修单"
+	Priority      int  // 通过卡片创建维修单时使用的优先级
+	DetailPath    string
+}
+
+func priorityTemplate(priority int) string {
+	switch priority {
+	case 1:
+		return "red"
+	case 2:
+		return "orange"
+	}
+	return "blue"
+}
+
+func equipmentDetailPath(equipmentID uint) string {
+	if equipmentID == 0 {
+		return ""
+	}
+	return fmt.Sprintf("/h5/equipment/detail/%d", equipmentID)
+}
+
+func alertViewOf(e event.Event) (*alertView, error) {
+	view := &alertView{EquipmentID: e.EquipmentID, DetailPath: equipmentDetailPath(e.EquipmentID), Priority: 2}
+	switch e.Type {
+	case event.RepairCreated:
+		var p event.RepairCreatedPayload
+		if err := e.Decode(&p); err != nil {
+			return nil, err
+		}
+		source := "人工报修"
+		if p.Source == "inspection_ng" {
+			source = "点检 NG 自动创建"
+		}
+		view.Title, view.Template = "新维修单", priorityTemplate(p.Priority)
+		view.Body = fmt.Sprintf("设备 %s 新建维修单 #%d（%s，优先级 %d）\n%s", p.EquipmentCode, p.OrderID, source, p.Priority, p.FaultDescription)
+		view.RepairOrderID, view.Assignable = p.OrderID, true
+	case event.InspectionNGDetected:
+		var p event.InspectionNGDetectedPayload
+		if err := e.Decode(&p); err != nil {
+			return nil, err
+		}
+		view.Title, view.Template = "点检异常", "orange"
+		view.Body = fmt.Sprintf("点检任务 #%d 发现 %d 项不合格", p.TaskID, len(p.Items))
+		for _, item := range p.Items {
+			view.Body += fmt.Sprintf("\n- %s: %s", item.Name, item.Remark)
+		}
+		view.RepairOrderID = p.RepairOrderID
+		view.Assignable, view.Repairable = p.RepairOrderID > 0, p.RepairOrderID == 0
+	case event.MaintenanceOverdue:
+		var p event.MaintenanceOverduePayload
+		if err := e.Decode(&p); err != nil {
+			return nil, err
+		}
+		view.Title, view.Template = "保养逾期", "yellow"
+		view.Body = fmt.Sprintf("保养任务 #%d（%s）已超过截止日期 %s", p.TaskID, p.PlanName, p.DueDate)
+	case event.SparePartBelowSafetyStock:
+		var p event.SparePartBelowSafetyStockPayload
+		if err := e.Decode(&p); err != nil {
+			return nil, err
+		}
+		view.Title, view.Template = "低库存", "orange"
+		if p.Quantity <= 0 {
+			view.Template = "red"
+		}
+		view.Body = fmt.Sprintf("备件 %s %s 库存 %d，低于安全库存 %d", p.Code, p.Name, p.Quantity, p.SafetyStock)
+		view.DetailPath = "/h5/spareparts"
+	case event.EquipmentRULRisk:
+		var p event.EquipmentRULRiskPayload
+		if err := e.Decode(&p); err != nil {
+			return nil, err
+		}
+		view.Title, view.Template = "设备停机风险预警", "red"
+		view.Body = fmt.Sprintf("设备 %s %s 预计剩余健康寿命 %d 天（健康分 %.0f）", p.EquipmentCode, p.EquipmentName, p.EstimatedRULDays, p.HealthScore)
+		if p.Recommendation != "" {
+			view.Body += "\n" + p.Recommendation
+		}
+		view.Repairable = true
+		if p.EstimatedRULDays <= 3 {
+			view.Priority = 1
+		}
+	case event.RepairSLABreached:
+		var p event.RepairSLABreachedPayload
+		if err := e.Decode(&p); err != nil {
+			return nil, err
+		}
+		stage := "修复"
+		if p.Stage == "response" {
+			stage = "响应"
+		}
+		view.Title, view.Template = "维修超时", "red"
+		view.Body = fmt.Sprintf("维修单 #%d（设备 %s，优先级 %d）已超出%s时限 %s，当前状态 %s",
+			p.OrderID, p.EquipmentCode, p.Priority, stage, p.Deadline.Local().Format("01-02 15:04"), p.Status)
+		view.RepairOrderID, view.Assignable = p.OrderID, p.Status == string(model.RepairPending)
+	default:
+		return nil, fmt.Errorf("unsupported event type %s", e.Type)
+	}
+	return view, nil
+}
+
+// alertCard 按提醒内容与卡片当前处理状态构建卡片；已执行的动作不再显示按钮
+func alertCard(view *alertView, state *model.LarkAlertCard) *lark.Card {
+	card := lark.NewCard(view.Title, view.Template).Markdown(view.Body)
+
+	createdHere := state.RepairOrderID != nil && view.RepairOrderID == 0
+	var buttons []lark.Button
+	if state.AssignedAt == nil && (view.Assignable || createdHere) {
+		buttons = append(buttons, lark.CallbackButton("指派给我", "primary", map[string]string{"action": CardActionAssignToMe}))
+	}
+	if view.Repairable && state.RepairOrderID == nil && view.EquipmentID > 0 {
+		buttons = append(buttons, lark.CallbackButton("创建维修单", "danger", map[string]string{"action": CardActionCreateRepair}))
+	}
+	if state.AcknowledgedAt == nil {
+		buttons = append(buttons, lark.CallbackButton("确认已知悉", "default", map[string]string{"action": CardActionAcknowledge}))
+	}
+	if view.DetailPath != "" {
+		buttons = append(buttons, lark.LinkButton("查看详情", appBaseURL()+view.DetailPath))
+	}
+	card.Actions(buttons...)
+
+	if createdHere {
+		card.Note(fmt.Sprintf("已创建维修单 #%d", *state.RepairOrderID))
+	}
+	if state.AssignedAt != nil {
+		card.Note(fmt.Sprintf("已指派给我 · %s", state.AssignedAt.Format("01-02 15:04")))
+	}
+	if state.AcknowledgedAt != nil {
+		card.Note(fmt.Sprintf("已确认知悉 · %s", state.AcknowledgedAt.Format("01-02 15:04")))
+	}
+	return card
+}
+
+// SendAlertCard 向已绑定飞书的用户发送事件提醒卡片，并记录卡片以便处理按钮回调
+func (s *LarkService) SendAlertCard(ctx context.Context, recipient model.User, e event.Event) error {
+	view, err := alertViewOf(e)
+	if err != nil {
+		return err
+	}
+	client, openID, err := s.botFor(recipient)
+	if err != nil {
+		return err
+	}
+	state := &model.LarkAlertCard{RecipientID: recipient.ID, EventID: e.ID, EventType: string(e.Type)}
+	messageID, err := client.SendCardMessage(ctx, "open_id", openID, alertCard(view, state).JSON())
+	if err != nil {
+		return err
+	}
+	envelope, _ := json.Marshal(e)
+	state.MessageID, state.Event = messageID, string(envelope)
+	return s.cardRepo.Create(state)
+}
+
+// canTakeRepair 可以接维修单的角色
+func canTakeRepair(role model.UserRole) bool {
+	return role == model.RoleMaintenance || role == model.RoleEngineer || role == model.RoleSupervisor
+}
+
+// HandleCardAction 以点击者绑定的 EMS 用户身份执行卡片按钮动作，返回 toast 与更新后的卡片
+func (s *LarkService) HandleCardAction(ctx context.Context, action dto.LarkCardActionEvent) lark.CardActionResponse {
+	actor := s.findBoundUser(action.Operator.OpenID)
+	if actor == nil {
+		return lark.ActionResponse("error", "请先绑定 EMS 账号后再操作", nil)
+	}
+	if repository.DB == nil {
+		return lark.ActionResponse("error", "该提醒已失效", nil) // 内存模式不发送提醒卡片
+	}
+	state, err := s.cardRepo.GetByMessageID(action.Context.OpenMessageID)
+	if err != nil {
+		return lark.ActionResponse("error", "该提醒已失效", nil)
+	}
+	if state.RecipientID != actor.ID {
+		return lark.ActionResponse("error", "只能处理发送给自己的提醒", nil)
+	}
+	var e event.Event
+	if err := json.Unmarshal([]byte(state.Event), &e); err != nil {
+		return lark.ActionResponse("error", "提醒内容已损坏", nil)
+	}
+	view, err := alertViewOf(e)
+	if err != nil {
+		return lark.ActionResponse("error", err.Error(), nil)
+	}
+
+	var message string
+	now := time.Now()
+	switch action.Action.Value["action"] {
+	case CardActionAcknowledge:
+		// 同步确认对应的告警，此后重复事件不再通知；告警仍在升级时按升级的确认权限校验
+		// （EscalationService 依赖通知服务，不能作为 LarkService 的字段，否则构造时循环）
+		if alert, err := s.alertRepo.GetActive(alertFingerprint(e)); err == nil {
+			_, err := NewEscalationService().AcknowledgeAlert(actor, alert.ID)
+			if errors.Is(err, ErrForbidden) {
+				return lark.ActionResponse("error", "该告警正在升级，只有已通知人员、值班人、主管或管理员可以确认", alertCard(view, state))
+			}
+			if err != nil {
+				log.Printf("[LarkService] Failed to acknowledge alert %d: %v", alert.ID, err)
+			}
+		}
+		if state.AcknowledgedAt == nil {
+			state.AcknowledgedAt = &now
+		}
+		message = "已确认知悉"
+	case CardActionAssignToMe:
+		message, err = s.assignFromCard(actor, view, state, now)
+	case CardActionCreateRepair:
+		message, err = s.createRepairFromCard(actor, view, state)
+	default:
+		return lark.ActionResponse("error", "不支持的操作", alertCard(view, state))
+	}
+	if err != nil {
+		return lark.ActionResponse("error", err.Error(), alertCard(view, state))
+	}
+	if err := s.cardRepo.Update(state); err != nil {
+		log.Printf("[LarkService] Failed to save alert card %s: %v", state.MessageID, err)
+	}
+	return lark.ActionResponse("success", message, alertCard(view, state))
+}
+
+func (s *LarkService) assignFromCard(actor *model.User, view *alertView, state *model.LarkAlertCard, now time.Time) (string, error) {
+	if state.AssignedAt != nil {
+		return "已指派给你", nil
+	}
+	if !canTakeRepair(actor.Role) {
+		return "", fmt.Errorf("当前角色不能接维修单")
+	}
+	orderID := view.RepairOrderID
+	if state.RepairOrderID != nil {
+		orderID = *state.RepairOrderID
+	}
+	if orderID == 0 {
+		return "", fmt.Errorf("该提醒没有关联的维修单")
+	}
+	if _, err := s.repairSvc.AssignOrder(orderID, actor.ID, actor.ID); err != nil {
+		return "", fmt.Errorf("指派失败：%v", err)
+	}
+	state.AssignedAt, state.RepairOrderID = &now, &orderID
+	return fmt.Sprintf("维修单 #%d 已指派给你", orderID), nil
+}
+
+func (s *LarkService) createRepairFromCard(actor *model.User, view *alertView, state *model.LarkAlertCard) (string, error) {
+	if state.RepairOrderID != nil {
+		return fmt.Sprintf("已创建维修单 #%d", *state.RepairOrderID), nil
+	}
+	if !view.Repairable || view.EquipmentID == 0 {
+		return "", fmt.Errorf("该提醒不能创建维修单")
+	}
+	order, err := s.repairSvc.CreateOrder(&CreateOrderRequest{
+		EquipmentID: view.EquipmentID, FaultDescription: fmt.Sprintf("【%s】%s", view.Title, view.Body),
+		Priority: view.Priority, ReporterID: actor.ID,
+	})
+	if err != nil {
+		return "", fmt.Errorf("创建维修单失败：%v", err)
+	}
+	state.RepairOrderID = &order.ID
+	return fmt.Sprintf("已创建维修单 #%d", order.ID), nil
+}
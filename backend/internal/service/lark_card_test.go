@@ -0,0 +1,97 @@
+package service
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ems/backend/internal/event"
+	"github.com/ems/backend/internal/model"
+	"github.com/ems/backend/pkg/config"
+)
+
+func testEvent(t *testing.T, p event.Payload) event.Event {
+	t.Helper()
+	body, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return event.Event{ID: 1, Type: p.EventType(), Subject: p.Scope(), OccurredAt: time.Now(), Payload: body}
+}
+
+func TestAlertCardButtonsFollowState(t *testing.T) {
+	config.Cfg = &config.Config{App: config.AppConfig{BaseURL: "https://ems.example.com"}}
+	e := testEvent(t, event.EquipmentRULRiskPayload{
+		Subject: event.Subject{EquipmentID: 7}, EquipmentCode: "CNC-01", EquipmentName: "数控机床", EstimatedRULDays: 2,
+	})
+	view, err := alertViewOf(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if view.Priority != 1 || !view.Repairable {
+		t.Fatalf("Expected a repairable P1 view, got %+v", view)
+	}
+
+	state := &model.LarkAlertCard{}
+	card := alertCard(view, state).JSON()
+	for _, want := range []string{"创建维修单", "确认已知悉", "https://ems.example.com/h5/equipment/detail/7", CardActionCreateRepair} {
+		if !strings.Contains(card, want) {
+			t.Errorf("Expected fresh card to contain %q: %s", want, card)
+		}
+	}
+	if strings.Contains(card, "指派给我") {
+		t.Errorf("Did not expect assign button without a repair order")
+	}
+
+	orderID, now := uint(42), time.Now()
+	state.RepairOrderID, state.AcknowledgedAt = &orderID, &now
+	card = alertCard(view, state).JSON()
+	if strings.Contains(card, "创建维修单\"") || strings.Contains(card, CardActionAcknowledge) {
+		t.Errorf("Expected completed actions to be removed: %s", card)
+	}
+	if !strings.Contains(card, CardActionAssignToMe) || !strings.Contains(card, "已创建维修单 #42") {
+		t.Errorf("Expected the created order to be assignable and noted: %s", card)
+	}
+
+	state.AssignedAt = &now
+	if card = alertCard(view, state).JSON(); strings.Contains(card, CardActionAssignToMe) {
+		t.Errorf("Expected assign button to disappear once assigned: %s", card)
+	}
+}
+
+func TestAlertViewOfSLABreach(t *testing.T) {
+	e := testEvent(t, event.RepairSLABreachedPayload{OrderID: 9, Priority: 1, Status: "assigned", Stage: "response", Deadline: time.Now()})
+	view, err := alertViewOf(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if view.Assignable || view.RepairOrderID != 9 || !strings.Contains(view.Body, "响应时限") {
+		t.Errorf("Expected an already assigned order to be non-assignable, got %+v", view)
+	}
+	if _, err := alertViewOf(testEvent(t, event.EquipmentStatusChangedPayload{})); err == nil {
+		t.Errorf("Expected equipment.status_changed to have no alert card")
+	}
+}
+
+func TestSLABreachStages(t *testing.T) {
+	created := time.Date(2026, 5, 1, 8, 0, 0, 0, time.UTC)
+	order := &model.RepairOrder{Priority: 1}
+	order.CreatedAt = created
+
+	if stage, _ := slaBreach(order, created.Add(time.Hour)); stage != "" {
+		t.Errorf("Expected no breach within the response window, got %s", stage)
+	}
+	stage, deadline := slaBreach(order, created.Add(3*time.Hour))
+	if stage != "response" || !deadline.Equal(created.Add(2*time.Hour)) {
+		t.Fatalf("Expected response breach at 2h, got %s %v", stage, deadline)
+	}
+	marked := created.Add(3 * time.Hour)
+	order.ResponseBreachedAt = &marked
+	if stage, _ := slaBreach(order, created.Add(5*time.Hour)); stage != "" {
+		t.Errorf("Expected the response breach to be reported once, got %s", stage)
+	}
+	if stage, _ := slaBreach(order, created.Add(25*time.Hour)); stage != "resolution" {
+		t.Errorf("Expected resolution breach after 24h, got %s", stage)
+	}
+}
@@ -3,10 +3,13 @@ package service
 import (
 	"errors"
 	"fmt"
+	"log"
 	"time"
 
+	"github.com/ems/backend/internal/event"
 	"github.com/ems/backend/internal/model"
 	"github.com/ems/backend/internal/repository"
+	"gorm.io/gorm"
 )
 
 // MaintenancePlanService
@@ -209,6 +212,7 @@ func (s *MaintenanceTaskService) List(filter *MaintenanceTaskFilter) (*Maintenan
 	repoFilter := repository.MaintenanceTaskFilter{
 		Status:     filter.Status,
 		AssignedTo: filter.AssignedTo,
+		FactoryID:  filter.FactoryID,
 		DateFrom:   filter.DateFrom,
 		DateTo:     filter.DateTo,
 		Page:       filter.Page,
@@ -226,6 +230,101 @@ func (s *MaintenanceTaskService) List(filter *MaintenanceTaskFilter) (*Maintenan
 	}, nil
 }
 
+// CreateMaintenanceTaskRequest schedules a single maintenance task outside batch generation
+type CreateMaintenanceTaskRequest struct {
+	PlanID        uint
+	EquipmentID   uint
+	ScheduledDate time.Time
+	AssignedTo    uint // 为 0 时使用设备的专属维护人员，其次为创建人
+	Remark        string
+	CreatedBy     uint
+}
+
+func (s *MaintenanceTaskService) CreateTask(req *CreateMaintenanceTaskRequest) (*model.MaintenanceTask, error) {
+	plan, err := s.planRepo.GetByID(req.PlanID)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	equipment, err := s.equipRepo.GetByID(req.EquipmentID)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	if equipment.TypeID != plan.EquipmentTypeID {
+		return nil, fmt.Errorf("%w: plan %s does not apply to equipment type of %s", ErrInvalidInput, plan.Name, equipment.Code)
+	}
+
+	if req.AssignedTo != 0 {
+		user, err := s.userRepo.GetByID(req.AssignedTo)
+		if err != nil {
+			return nil, fmt.Errorf("%w: assignee %d not found", ErrInvalidInput, req.AssignedTo)
+		}
+		if !assigneeInEquipmentFactory(user, equipment) {
+			return nil, fmt.Errorf("%w: assignee %d belongs to another factory than %s", ErrInvalidInput, req.AssignedTo, equipment.Code)
+		}
+	}
+
+	assignee := req.AssignedTo
+	if assignee == 0 && equipment.DedicatedMaintenanceID != nil {
+		assignee = *equipment.DedicatedMaintenanceID
+	}
+	if assignee == 0 {
+		assignee = req.CreatedBy
+	}
+
+	task := &model.MaintenanceTask{
+		PlanID:        plan.ID,
+		EquipmentID:   equipment.ID,
+		AssignedTo:    assignee,
+		ScheduledDate: req.ScheduledDate.Format("2006-01-02"),
+		DueDate:       req.ScheduledDate.AddDate(0, 0, plan.FlexibleDays).Format("2006-01-02"),
+		Status:        "pending",
+		Remark:        req.Remark,
+	}
+	if err := s.taskRepo.Create(task); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// assigneeInEquipmentFactory 指定的执行人必须与设备属于同一工厂
+func assigneeInEquipmentFactory(user *model.User, equipment *model.Equipment) bool {
+	return user.FactoryID != nil && equipment.Workshop != nil && *user.FactoryID == equipment.Workshop.FactoryID
+}
+
+// RescheduleTask moves a task that has not started to a new date, keeping the plan's flexible window
+func (s *MaintenanceTaskService) RescheduleTask(taskID uint, date time.Time, reason string) (*model.MaintenanceTask, error) {
+	task, err := s.taskRepo.GetByID(taskID)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	if task.Status != "pending" && task.Status != "overdue" {
+		return nil, fmt.Errorf("%w: task in %s status cannot be rescheduled", ErrInvalidInput, task.Status)
+	}
+
+	flexible := 0
+	if task.Plan != nil {
+		flexible = task.Plan.FlexibleDays
+	}
+	previous := task.ScheduledDate
+	task.ScheduledDate = date.Format("2006-01-02")
+	task.DueDate = date.AddDate(0, 0, flexible).Format("2006-01-02")
+	task.Status = "pending"
+	task.AutoGenerated = false // 手动改期后不再随计划重排
+	note := fmt.Sprintf("改期 %s -> %s", previous, task.ScheduledDate)
+	if reason != "" {
+		note += "：" + reason
+	}
+	if task.Remark != "" {
+		note = task.Remark + "\n" + note
+	}
+	task.Remark = note
+
+	if err := s.taskRepo.Update(task); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
 func (s *MaintenanceTaskService) GetMyTasks(userID uint, date time.Time) ([]model.MaintenanceTask, error) {
 	return s.taskRepo.GetPendingTasksForUser(userID, date)
 }
@@ -338,6 +437,11 @@ func (s *MaintenanceTaskService) CompleteMaintenance(req *CompleteMaintenanceReq
 		return nil, err
 	}
 
+	// 按实际完成日期重排该设备后续的自动任务
+	if _, err := s.ReplanEquipment(task.PlanID, task.EquipmentID, now); err != nil {
+		log.Printf("Failed to replan maintenance of equipment %d: %v", task.EquipmentID, err)
+	}
+
 	// Trigger repair workflow if there are NG items
 	if len(ngItemIDs) > 0 {
 		faultDesc := "保养发现异常:\n"
@@ -386,8 +490,9 @@ func (s *MaintenanceTaskService) CompleteMaintenance(req *CompleteMaintenanceReq
 	}, nil
 }
 
-func (s *MaintenanceTaskService) GetStatistics() (*MaintenanceStatistics, error) {
-	stats, err := s.taskRepo.GetStatistics()
+// GetStatistics 保养任务统计，factoryID 不为空时只统计该工厂
+func (s *MaintenanceTaskService) GetStatistics(factoryID *uint) (*MaintenanceStatistics, error) {
+	stats, err := s.taskRepo.GetStatistics(factoryID)
 	if err != nil {
 		return nil, err
 	}
@@ -438,7 +543,18 @@ func (s *MaintenanceTaskService) UpdateOverdueTasks() (int, error) {
 	}
 
 	if len(ids) > 0 {
-		err := s.taskRepo.UpdateOverdueStatus(ids)
+		// 状态更新与每个任务的逾期事件一同提交
+		err := repository.DB.Transaction(func(tx *gorm.DB) error {
+			if err := s.taskRepo.WithTx(tx).UpdateOverdueStatus(ids); err != nil {
+				return err
+			}
+			for _, task := range tasks {
+				if err := event.Publish(tx, overduePayload(&task)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
 		if err != nil {
 			return 0, err
 		}
@@ -447,10 +563,25 @@ func (s *MaintenanceTaskService) UpdateOverdueTasks() (int, error) {
 	return len(ids), nil
 }
 
+func overduePayload(task *model.MaintenanceTask) event.MaintenanceOverduePayload {
+	p := event.MaintenanceOverduePayload{
+		Subject: event.Subject{EquipmentID: task.EquipmentID}, TaskID: task.ID, PlanID: task.PlanID,
+		DueDate: task.DueDate, AssignedTo: task.AssignedTo,
+	}
+	if task.Equipment != nil {
+		p.Subject = equipmentSubject(task.Equipment)
+	}
+	if task.Plan != nil {
+		p.PlanName = task.Plan.Name
+	}
+	return p
+}
+
 // Types
 type MaintenanceTaskFilter struct {
 	Status     string
 	AssignedTo uint
+	FactoryID  uint
 	DateFrom   time.Time
 	DateTo     time.Time
 	Page       int
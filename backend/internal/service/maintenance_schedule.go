@@ -0,0 +1,270 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ems/backend/internal/model"
+	"github.com/ems/backend/pkg/config"
+)
+
+// 保养计划滚动生成：按设备最近一次完成保养的日期推算下次到期日，在 maintenance.horizon_days 内
+// 保持待执行的自动任务；提前或延后完成时重排尚未开始的自动任务
+
+// MaintenanceScheduleResult 一次滚动生成的结果
+type MaintenanceScheduleResult struct {
+	Created     int
+	Rescheduled int
+	Removed     int
+	Errors      []string
+}
+
+func (r *MaintenanceScheduleResult) String() string {
+	s := fmt.Sprintf("%d created, %d rescheduled, %d removed", r.Created, r.Rescheduled, r.Removed)
+	if len(r.Errors) > 0 {
+		s += fmt.Sprintf(", %d errors: %v", len(r.Errors), r.Errors)
+	}
+	return s
+}
+
+// maintenanceSkipped 报废与封存（stopped）的设备不生成保养任务
+func maintenanceSkipped(status string) bool {
+	return status == "scrapped" || status == "stopped"
+}
+
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// nextDueDate 计算下一次保养日期：有完成记录时为最近完成日 + 周期，否则为今天。
+// 已过期时安排在今天，但已生成且已到期的任务保持原日期，避免每次运行都顺延而永不逾期
+func nextDueDate(lastCompleted *time.Time, cycleDays int, firstOpen *time.Time, today time.Time) time.Time {
+	if lastCompleted == nil {
+		if firstOpen != nil {
+			return *firstOpen
+		}
+		return today
+	}
+	next := startOfDay(*lastCompleted).AddDate(0, 0, cycleDays)
+	if !next.Before(today) {
+		return next
+	}
+	if firstOpen != nil && !firstOpen.Before(next) && !firstOpen.After(today) {
+		return *firstOpen
+	}
+	return today
+}
+
+// rollingDueDates 从 start 起按周期返回不晚于 horizonEnd 的保养日期
+func rollingDueDates(start time.Time, cycleDays int, horizonEnd time.Time) []time.Time {
+	var dates []time.Time
+	for d := start; !d.After(horizonEnd); d = d.AddDate(0, 0, cycleDays) {
+		dates = append(dates, d)
+	}
+	return dates
+}
+
+// leastLoaded 返回未完成任务最少的候选人，相同时取 ID 最小者
+func leastLoaded(candidates []model.User, workload map[uint]int) (uint, bool) {
+	var picked uint
+	for _, u := range candidates {
+		if picked == 0 || workload[u.ID] < workload[picked] || (workload[u.ID] == workload[picked] && u.ID < picked) {
+			picked = u.ID
+		}
+	}
+	return picked, picked != 0
+}
+
+// maintenancePlanner 一次滚动生成的上下文，缓存各工厂的保养人员与工作量
+type maintenancePlanner struct {
+	svc        *MaintenanceTaskService
+	today      time.Time
+	horizonEnd time.Time
+	staff      map[uint][]model.User
+	workload   map[uint]int
+	result     *MaintenanceScheduleResult
+}
+
+func (s *MaintenanceTaskService) newPlanner(now time.Time) *maintenancePlanner {
+	today := startOfDay(now)
+	return &maintenancePlanner{
+		svc:        s,
+		today:      today,
+		horizonEnd: today.AddDate(0, 0, config.Cfg.Maintenance.Horizon()),
+		staff:      map[uint][]model.User{},
+		workload:   map[uint]int{},
+		result:     &MaintenanceScheduleResult{},
+	}
+}
+
+// ScheduleFromPlans 按全部保养计划为匹配类型的设备滚动生成任务
+func (s *MaintenanceTaskService) ScheduleFromPlans(now time.Time) (*MaintenanceScheduleResult, error) {
+	plans, err := s.planRepo.List()
+	if err != nil {
+		return nil, err
+	}
+	p := s.newPlanner(now)
+	for i := range plans {
+		if err := p.schedulePlan(&plans[i], 0); err != nil {
+			p.result.Errors = append(p.result.Errors, fmt.Sprintf("Plan %s: %v", plans[i].Name, err))
+		}
+	}
+	return p.result, nil
+}
+
+// ReplanEquipment 重排单台设备在计划下的自动任务，保养完成后调用
+func (s *MaintenanceTaskService) ReplanEquipment(planID, equipmentID uint, now time.Time) (*MaintenanceScheduleResult, error) {
+	plan, err := s.planRepo.GetByID(planID)
+	if err != nil {
+		return nil, err
+	}
+	p := s.newPlanner(now)
+	if err := p.schedulePlan(plan, equipmentID); err != nil {
+		return nil, err
+	}
+	return p.result, nil
+}
+
+// schedulePlan 处理计划下的设备；equipmentID 不为 0 时只处理该设备
+func (p *maintenancePlanner) schedulePlan(plan *model.MaintenancePlan, equipmentID uint) error {
+	if plan.CycleDays <= 0 {
+		return fmt.Errorf("%w: cycle_days must be positive", ErrInvalidInput)
+	}
+	equipments, err := p.svc.equipRepo.ListByType(plan.EquipmentTypeID)
+	if err != nil {
+		return err
+	}
+	open, err := p.svc.taskRepo.ListOpenByPlan(plan.ID)
+	if err != nil {
+		return err
+	}
+	openByEquipment := map[uint][]model.MaintenanceTask{}
+	for _, t := range open {
+		openByEquipment[t.EquipmentID] = append(openByEquipment[t.EquipmentID], t)
+	}
+	lastCompleted, err := p.svc.taskRepo.LastCompletedByPlan(plan.ID)
+	if err != nil {
+		return err
+	}
+
+	for i := range equipments {
+		equipment := &equipments[i]
+		if equipmentID != 0 && equipment.ID != equipmentID {
+			continue
+		}
+		var last *time.Time
+		if t, ok := lastCompleted[equipment.ID]; ok {
+			last = &t
+		}
+		if err := p.scheduleEquipment(plan, equipment, openByEquipment[equipment.ID], last); err != nil {
+			p.result.Errors = append(p.result.Errors, fmt.Sprintf("Equipment %s: %v", equipment.Code, err))
+		}
+	}
+	return nil
+}
+
+func (p *maintenancePlanner) scheduleEquipment(plan *model.MaintenancePlan, equipment *model.Equipment, open []model.MaintenanceTask, lastCompleted *time.Time) error {
+	// 只有未开始的自动任务可以重排；存在手动任务、进行中或逾期任务时等待其完成后再排
+	var auto []model.MaintenanceTask
+	for _, t := range open {
+		if !t.AutoGenerated || t.Status != model.MaintenancePending {
+			if maintenanceSkipped(equipment.Status) {
+				continue
+			}
+			return nil
+		}
+		auto = append(auto, t)
+	}
+
+	var dates []time.Time
+	if !maintenanceSkipped(equipment.Status) {
+		var firstOpen *time.Time
+		if len(auto) > 0 {
+			if d, err := time.ParseInLocation("2006-01-02", auto[0].ScheduledDate, p.today.Location()); err == nil {
+				firstOpen = &d
+			}
+		}
+		start := nextDueDate(lastCompleted, plan.CycleDays, firstOpen, p.today)
+		dates = rollingDueDates(start, plan.CycleDays, p.horizonEnd)
+	}
+
+	for i, date := range dates {
+		scheduled := date.Format("2006-01-02")
+		due := date.AddDate(0, 0, plan.FlexibleDays).Format("2006-01-02")
+		if i < len(auto) {
+			if auto[i].ScheduledDate != scheduled || auto[i].DueDate != due {
+				if err := p.svc.taskRepo.RescheduleDates(auto[i].ID, scheduled, due); err != nil {
+					return err
+				}
+				p.result.Rescheduled++
+			}
+			continue
+		}
+		assignee, err := p.assignee(equipment)
+		if err != nil {
+			return err
+		}
+		task := &model.MaintenanceTask{
+			PlanID:        plan.ID,
+			EquipmentID:   equipment.ID,
+			AssignedTo:    assignee,
+			ScheduledDate: scheduled,
+			DueDate:       due,
+			Status:        model.MaintenancePending,
+			AutoGenerated: true,
+		}
+		if err := p.svc.taskRepo.Create(task); err != nil {
+			return err
+		}
+		p.workload[assignee]++
+		p.result.Created++
+	}
+
+	// 周期变长、horizon 缩短或设备报废/封存后多余的自动任务
+	if len(auto) > len(dates) {
+		for _, t := range auto[len(dates):] {
+			if err := p.svc.taskRepo.Delete(t.ID); err != nil {
+				return err
+			}
+			p.result.Removed++
+		}
+	}
+	return nil
+}
+
+// assignee 优先分配给设备的专属保养人员，否则分配给本工厂未完成任务最少的保养人员
+func (p *maintenancePlanner) assignee(equipment *model.Equipment) (uint, error) {
+	if equipment.DedicatedMaintenanceID != nil {
+		return *equipment.DedicatedMaintenanceID, nil
+	}
+	if equipment.Workshop == nil {
+		return 0, fmt.Errorf("%w: equipment has no workshop", ErrInvalidInput)
+	}
+	factoryID := equipment.Workshop.FactoryID
+	staff, ok := p.staff[factoryID]
+	if !ok {
+		users, err := p.svc.userRepo.ListActiveByRoles([]string{string(model.RoleMaintenance)}, &factoryID)
+		if err != nil {
+			return 0, err
+		}
+		ids := make([]uint, len(users))
+		for i, u := range users {
+			ids[i] = u.ID
+		}
+		counts, err := p.svc.taskRepo.OpenCountByAssignee(ids)
+		if err != nil {
+			return 0, err
+		}
+		for id, n := range counts {
+			p.workload[id] = n
+		}
+		staff = users
+		p.staff[factoryID] = staff
+	}
+	id, ok := leastLoaded(staff, p.workload)
+	if !ok {
+		return 0, fmt.Errorf("%w: no active maintenance staff in factory %d", ErrInvalidInput, factoryID)
+	}
+	return id, nil
+}
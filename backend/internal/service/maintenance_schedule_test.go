@@ -0,0 +1,66 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ems/backend/internal/model"
+)
+
+func planDay(m time.Month, d int) time.Time {
+	return time.Date(2026, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestNextDueDate(t *testing.T) {
+	today := planDay(5, 10)
+	early := planDay(5, 5)     // 提前完成：从完成日起算
+	late := planDay(4, 1)      // 很久未保养：安排在今天
+	generated := planDay(5, 8) // 之前已生成且已到期的任务
+	future := planDay(5, 20)
+	cases := []struct {
+		name      string
+		last      *time.Time
+		firstOpen *time.Time
+		want      time.Time
+	}{
+		{"no history", nil, nil, today},
+		{"no history keeps generated task", nil, &generated, generated},
+		{"completed recently", &early, nil, planDay(5, 12)},
+		{"overdue schedules today", &late, nil, today},
+		{"overdue keeps generated task", &late, &generated, generated},
+		{"future task moved to today", &late, &future, today},
+	}
+	for _, c := range cases {
+		if got := nextDueDate(c.last, 7, c.firstOpen, today); !got.Equal(c.want) {
+			t.Errorf("%s: expected %s, got %s", c.name, c.want.Format("2006-01-02"), got.Format("2006-01-02"))
+		}
+	}
+}
+
+func TestRollingDueDates(t *testing.T) {
+	dates := rollingDueDates(planDay(5, 10), 7, planDay(6, 1))
+	if len(dates) != 4 || !dates[3].Equal(planDay(5, 31)) {
+		t.Errorf("Expected 4 weekly dates through 05-31, got %v", dates)
+	}
+	if dates := rollingDueDates(planDay(6, 2), 7, planDay(6, 1)); len(dates) != 0 {
+		t.Errorf("Expected no dates beyond the horizon, got %v", dates)
+	}
+}
+
+func TestLeastLoaded(t *testing.T) {
+	users := []model.User{{BaseModel: model.BaseModel{ID: 4}}, {BaseModel: model.BaseModel{ID: 2}}, {BaseModel: model.BaseModel{ID: 3}}}
+	if id, _ := leastLoaded(users, map[uint]int{2: 5, 3: 1, 4: 1}); id != 3 {
+		t.Errorf("Expected least loaded user with the lowest ID, got %d", id)
+	}
+	if _, ok := leastLoaded(nil, nil); ok {
+		t.Errorf("Expected no assignee without candidates")
+	}
+}
+
+func TestMaintenanceSkipped(t *testing.T) {
+	for status, want := range map[string]bool{"running": false, "maintenance": false, "stopped": true, "scrapped": true} {
+		if got := maintenanceSkipped(status); got != want {
+			t.Errorf("%s: expected %v, got %v", status, want, got)
+		}
+	}
+}
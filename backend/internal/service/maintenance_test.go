@@ -0,0 +1,25 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/ems/backend/internal/model"
+)
+
+func TestAssigneeInEquipmentFactory(t *testing.T) {
+	factory := func(id uint) *uint { return &id }
+	equipment := &model.Equipment{Workshop: &model.Workshop{FactoryID: 1}}
+
+	if !assigneeInEquipmentFactory(&model.User{FactoryID: factory(1)}, equipment) {
+		t.Error("Expected assignee from the equipment's factory to be allowed")
+	}
+	if assigneeInEquipmentFactory(&model.User{FactoryID: factory(2)}, equipment) {
+		t.Error("Expected assignee from another factory to be rejected")
+	}
+	if assigneeInEquipmentFactory(&model.User{}, equipment) {
+		t.Error("Expected assignee without factory to be rejected")
+	}
+	if assigneeInEquipmentFactory(&model.User{FactoryID: factory(1)}, &model.Equipment{}) {
+		t.Error("Expected equipment without workshop to be rejected")
+	}
+}
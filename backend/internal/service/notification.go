@@ -0,0 +1,454 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ems/backend/internal/dto"
+	"github.com/ems/backend/internal/event"
+	"github.com/ems/backend/internal/model"
+	"github.com/ems/backend/internal/notify"
+	"github.com/ems/backend/internal/repository"
+	"github.com/ems/backend/pkg/config"
+	"gorm.io/gorm"
+)
+
+// 投递结果
+const (
+	NotificationSuccess = "success"
+	NotificationFailed  = "failed"
+)
+
+const defaultNotificationDeliveryLimit = 50
+
+// notificationChannelOrder 渠道展示顺序
+var notificationChannelOrder = []string{
+	notify.ChannelLark, notify.ChannelEmail, notify.ChannelWeCom, notify.ChannelDingTalk, notify.ChannelWebhook,
+}
+
+// NotificationService 按用户渠道偏好渲染模板、发送通知并记录投递
+type NotificationService struct {
+	repo     *repository.NotificationRepository
+	userRepo *repository.UserRepository
+	channels map[string]notify.Channel
+}
+
+func NewNotificationService() *NotificationService {
+	larkSvc := NewLarkService()
+	s := &NotificationService{
+		repo:     repository.NewNotificationRepository(),
+		userRepo: repository.NewUserRepository(),
+		channels: map[string]notify.Channel{},
+	}
+	var smtpCfg config.SMTPConfig
+	if config.Cfg != nil {
+		smtpCfg = config.Cfg.Notify.SMTP
+	}
+	for _, ch := range []notify.Channel{
+		notify.NewSMTPChannel(smtpCfg),
+		notify.WeComChannel{},
+		notify.DingTalkChannel{},
+		notify.WebhookChannel{},
+		&larkChannel{userRepo: s.userRepo, larkSvc: larkSvc},
+	} {
+		s.channels[ch.Name()] = ch
+	}
+	return s
+}
+
+// larkChannel 通过飞书机器人发送：可交互的提醒事件发送卡片，其余发送文本
+type larkChannel struct {
+	userRepo *repository.UserRepository
+	larkSvc  *LarkService
+}
+
+func (c *larkChannel) Name() string { return notify.ChannelLark }
+
+func (c *larkChannel) Validate(dest notify.Destination) error {
+	user, err := c.userRepo.GetByID(dest.UserID)
+	if err != nil {
+		return err
+	}
+	if user.LarkOpenID == nil || *user.LarkOpenID == "" {
+		return fmt.Errorf("%w: lark account is not bound", notify.ErrInvalidAddress)
+	}
+	return nil
+}
+
+func (c *larkChannel) Send(ctx context.Context, dest notify.Destination, msg notify.Message) error {
+	user, err := c.userRepo.GetByID(dest.UserID)
+	if err != nil {
+		return err
+	}
+	if msg.Event != nil {
+		if _, err := alertViewOf(*msg.Event); err == nil {
+			return c.larkSvc.SendAlertCard(ctx, *user, *msg.Event)
+		}
+	}
+	return c.larkSvc.SendTextToUser(ctx, *user, msg.Subject+"\n"+msg.Body)
+}
+
+// Channels 返回可用渠道
+func (s *NotificationService) Channels() []dto.NotificationChannelResponse {
+	out := make([]dto.NotificationChannelResponse, 0, len(notificationChannelOrder))
+	for _, name := range notificationChannelOrder {
+		configured := true
+		if smtp, ok := s.channels[name].(*notify.SMTPChannel); ok {
+			configured = smtp.Host != "" && smtp.From != ""
+		}
+		out = append(out, dto.NotificationChannelResponse{Name: name, Configured: configured})
+	}
+	return out
+}
+
+// =====================================================
+// Preferences
+// =====================================================
+
+func toPreferenceResponse(p *model.NotificationPreference) dto.NotificationPreferenceResponse {
+	return dto.NotificationPreferenceResponse{
+		Channel: p.Channel, Address: p.Address, HasSecret: p.Secret != "", Language: p.Language,
+		EventTypes: splitEventTypes(p.EventTypes), Enabled: p.Enabled,
+	}
+}
+
+func splitEventTypes(s string) []string {
+	out := []string{}
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// wantsEvent 偏好是否接收该事件类型
+func wantsEvent(p *model.NotificationPreference, t event.Type) bool {
+	types := splitEventTypes(p.EventTypes)
+	if len(types) == 0 {
+		return true
+	}
+	for _, want := range types {
+		if want == string(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *NotificationService) ListPreferences(userID uint) ([]dto.NotificationPreferenceResponse, error) {
+	prefs, err := s.repo.ListPreferences(userID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]dto.NotificationPreferenceResponse, 0, len(prefs))
+	for i := range prefs {
+		out = append(out, toPreferenceResponse(&prefs[i]))
+	}
+	return out, nil
+}
+
+// SavePreference 新增或更新用户在某渠道上的偏好，保存前校验地址与事件类型
+func (s *NotificationService) SavePreference(userID uint, channel string, req *dto.NotificationPreferenceRequest) (*dto.NotificationPreferenceResponse, error) {
+	ch, ok := s.channels[channel]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown channel %s", ErrInvalidInput, channel)
+	}
+	lang := req.Language
+	if lang == "" {
+		lang = notify.DefaultLanguage
+	}
+	if !notify.ValidLanguage(lang) {
+		return nil, fmt.Errorf("%w: unsupported language %s", ErrInvalidInput, lang)
+	}
+	for _, t := range req.EventTypes {
+		if event.NewPayload(event.Type(t)) == nil {
+			return nil, fmt.Errorf("%w: unknown event type %s", ErrInvalidInput, t)
+		}
+	}
+
+	pref, err := s.repo.GetPreference(userID, channel)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		pref, err = &model.NotificationPreference{UserID: userID, Channel: channel, Enabled: true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	pref.Address = strings.TrimSpace(req.Address)
+	pref.Language = lang
+	pref.EventTypes = strings.Join(req.EventTypes, ",")
+	if req.Secret != nil {
+		pref.Secret = *req.Secret
+	}
+	if req.Enabled != nil {
+		pref.Enabled = *req.Enabled
+	}
+	if err := ch.Validate(notify.Destination{UserID: userID, Address: pref.Address, Secret: pref.Secret}); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidInput, err)
+	}
+	if err := s.repo.SavePreference(pref); err != nil {
+		return nil, err
+	}
+	resp := toPreferenceResponse(pref)
+	return &resp, nil
+}
+
+func (s *NotificationService) DeletePreference(userID uint, channel string) error {
+	err := s.repo.DeletePreference(userID, channel)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// TestPreference 通过用户已保存的渠道发送一条测试通知
+func (s *NotificationService) TestPreference(ctx context.Context, userID uint, channel string) (*model.NotificationDelivery, error) {
+	pref, err := s.repo.GetPreference(userID, channel)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	msg := notify.Message{Subject: "EMS test notification", Body: "This channel is set up to receive EMS notifications.", EventType: "test"}
+	if pref.Language == notify.LanguageZH {
+		msg.Subject, msg.Body = "EMS 测试通知", "该渠道已可接收 EMS 通知。"
+	}
+	return s.deliver(ctx, pref, msg, 0), nil
+}
+
+// =====================================================
+// Templates
+// =====================================================
+
+// ListTemplates 返回每个事件类型与语言当前生效的模板
+func (s *NotificationService) ListTemplates() ([]dto.NotificationTemplateResponse, error) {
+	custom, err := s.repo.ListTemplates()
+	if err != nil {
+		return nil, err
+	}
+	overrides := map[string]*model.NotificationTemplate{}
+	for i := range custom {
+		overrides[custom[i].EventType+"|"+custom[i].Language] = &custom[i]
+	}
+	var out []dto.NotificationTemplateResponse
+	for _, t := range event.Types {
+		for _, lang := range notify.Languages {
+			if o, ok := overrides[string(t)+"|"+lang]; ok {
+				out = append(out, dto.NotificationTemplateResponse{
+					ID: o.ID, EventType: o.EventType, Language: o.Language, Subject: o.Subject, Body: o.Body, Custom: true,
+				})
+				continue
+			}
+			b := notify.Builtin(t, lang)
+			out = append(out, dto.NotificationTemplateResponse{EventType: string(t), Language: lang, Subject: b.Subject, Body: b.Body})
+		}
+	}
+	return out, nil
+}
+
+// SaveTemplate 新增或覆盖某事件类型与语言的模板
+func (s *NotificationService) SaveTemplate(req *dto.NotificationTemplateRequest) (*model.NotificationTemplate, error) {
+	if event.NewPayload(event.Type(req.EventType)) == nil {
+		return nil, fmt.Errorf("%w: unknown event type %s", ErrInvalidInput, req.EventType)
+	}
+	if !notify.ValidLanguage(req.Language) {
+		return nil, fmt.Errorf("%w: unsupported language %s", ErrInvalidInput, req.Language)
+	}
+	if err := (notify.Template{Subject: req.Subject, Body: req.Body}).Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidInput, err)
+	}
+	tpl, err := s.repo.GetTemplate(req.EventType, req.Language)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		tpl, err = &model.NotificationTemplate{EventType: req.EventType, Language: req.Language}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	tpl.Subject, tpl.Body = req.Subject, req.Body
+	if err := s.repo.SaveTemplate(tpl); err != nil {
+		return nil, err
+	}
+	return tpl, nil
+}
+
+// DeleteTemplate 删除自定义模板，恢复内置模板
+func (s *NotificationService) DeleteTemplate(id uint) error {
+	err := s.repo.DeleteTemplate(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// render 按 自定义模板 → 内置模板 的顺序渲染事件
+func (s *NotificationService) render(e event.Event, lang string) (notify.Message, error) {
+	if !notify.ValidLanguage(lang) {
+		lang = notify.DefaultLanguage
+	}
+	if custom, err := s.repo.GetTemplate(string(e.Type), lang); err == nil {
+		msg, err := notify.Template{Subject: custom.Subject, Body: custom.Body}.Render(e)
+		if err == nil {
+			return msg, nil
+		}
+		log.Printf("[Notification] Custom template %s/%s failed, using builtin: %v", e.Type, lang, err)
+	}
+	return notify.Builtin(e.Type, lang).Render(e)
+}
+
+// =====================================================
+// Delivery
+// =====================================================
+
+// NotifyUser 按用户的渠道偏好发送事件通知。未设置任何偏好但已绑定飞书的用户默认通过飞书接收。
+// 单个渠道失败只记录投递日志，不影响其他渠道
+func (s *NotificationService) NotifyUser(ctx context.Context, user model.User, e event.Event) error {
+	prefs, err := s.preferencesOf(user)
+	if err != nil {
+		return err
+	}
+	rendered := map[string]notify.Message{}
+	for i := range prefs {
+		pref := &prefs[i]
+		if !pref.Enabled || !wantsEvent(pref, e.Type) {
+			continue
+		}
+		msg, ok := rendered[pref.Language]
+		if !ok {
+			if msg, err = s.render(e, pref.Language); err != nil {
+				return fmt.Errorf("render %s notification: %w", e.Type, err)
+			}
+			rendered[pref.Language] = msg
+		}
+		s.deliver(ctx, pref, msg, e.ID)
+	}
+	return nil
+}
+
+// preferencesOf 用户的渠道偏好；未设置任何偏好但已绑定飞书时默认使用飞书
+func (s *NotificationService) preferencesOf(user model.User) ([]model.NotificationPreference, error) {
+	prefs, err := s.repo.ListPreferences(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(prefs) == 0 && user.LarkOpenID != nil && *user.LarkOpenID != "" {
+		prefs = []model.NotificationPreference{{UserID: user.ID, Channel: notify.ChannelLark, Language: notify.DefaultLanguage, Enabled: true}}
+	}
+	return prefs, nil
+}
+
+// NotifyDigest 将多条告警合并为一条摘要，按用户的渠道偏好发送；每个渠道只包含其关注的事件类型。
+// 所有尝试的渠道都发送失败时返回错误，由调用方保留摘要待下次重试
+func (s *NotificationService) NotifyDigest(ctx context.Context, user model.User, alerts []model.Alert) error {
+	prefs, err := s.preferencesOf(user)
+	if err != nil {
+		return err
+	}
+	delivered := false
+	var failures []string
+	for i := range prefs {
+		pref := &prefs[i]
+		if !pref.Enabled {
+			continue
+		}
+		var lines []string
+		for _, alert := range alerts {
+			var e event.Event
+			if !wantsEvent(pref, event.Type(alert.EventType)) || json.Unmarshal([]byte(alert.Event), &e) != nil {
+				continue
+			}
+			msg, err := s.render(e, pref.Language)
+			if err != nil {
+				return fmt.Errorf("render %s notification: %w", e.Type, err)
+			}
+			lines = append(lines, fmt.Sprintf("- [%s] %s ×%d", alert.Severity, msg.Subject, alert.Count))
+		}
+		if len(lines) > 0 {
+			if record := s.deliver(ctx, pref, notify.Digest(pref.Language, lines), 0); record.Status == NotificationSuccess {
+				delivered = true
+			} else {
+				failures = append(failures, pref.Channel+": "+record.Error)
+			}
+		}
+	}
+	if !delivered && len(failures) > 0 {
+		return fmt.Errorf("digest not delivered: %s", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// NotifyMessage 按用户启用的全部渠道发送一条非事件消息（如升级通知），不受事件类型筛选限制
+func (s *NotificationService) NotifyMessage(ctx context.Context, user model.User, build func(lang string) notify.Message) (int, error) {
+	prefs, err := s.preferencesOf(user)
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	for i := range prefs {
+		pref := &prefs[i]
+		if !pref.Enabled {
+			continue
+		}
+		if s.deliver(ctx, pref, build(pref.Language), 0).Status == NotificationSuccess {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// deliver 发送到单个渠道并记录投递结果
+func (s *NotificationService) deliver(ctx context.Context, pref *model.NotificationPreference, msg notify.Message, eventID uint) *model.NotificationDelivery {
+	record := &model.NotificationDelivery{
+		UserID: pref.UserID, Channel: pref.Channel, EventType: msg.EventType, EventID: eventID,
+		Address: maskAddress(pref.Address), Subject: msg.Subject, Status: NotificationSuccess,
+	}
+	start := time.Now()
+	err := fmt.Errorf("unknown channel %s", pref.Channel)
+	if ch, ok := s.channels[pref.Channel]; ok {
+		err = ch.Send(ctx, notify.Destination{UserID: pref.UserID, Address: pref.Address, Secret: pref.Secret}, msg)
+	}
+	record.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		record.Status, record.Error = NotificationFailed, err.Error()
+		log.Printf("[Notification] Failed to send %s to user %d via %s: %v", msg.EventType, pref.UserID, pref.Channel, err)
+	}
+	if err := s.repo.CreateDelivery(record); err != nil {
+		log.Printf("[Notification] Failed to record delivery: %v", err)
+	}
+	return record
+}
+
+// maskAddress 投递日志中隐藏地址里的令牌：邮箱保留首字母与域名，URL 去掉查询参数
+func maskAddress(addr string) string {
+	if addr == "" {
+		return ""
+	}
+	if at := strings.LastIndex(addr, "@"); at > 0 && !strings.Contains(addr, "://") {
+		return addr[:1] + "***" + addr[at:]
+	}
+	u, err := url.Parse(addr)
+	if err != nil || u.Host == "" {
+		return "***"
+	}
+	masked := u.Scheme + "://" + u.Host + u.Path
+	if u.RawQuery != "" {
+		masked += "?***"
+	}
+	return masked
+}
+
+// ListDeliveries 查询投递记录；非管理员只能查看自己的记录
+func (s *NotificationService) ListDeliveries(user *model.User, q *dto.NotificationDeliveryQuery) ([]model.NotificationDelivery, error) {
+	userID := user.ID
+	if user.Role == model.RoleAdmin {
+		userID = q.UserID
+	}
+	limit := q.Limit
+	if limit <= 0 || limit > 200 {
+		limit = defaultNotificationDeliveryLimit
+	}
+	return s.repo.ListDeliveries(userID, q.Channel, q.Status, limit)
+}
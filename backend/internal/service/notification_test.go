@@ -0,0 +1,65 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ems/backend/internal/event"
+	"github.com/ems/backend/internal/model"
+	"github.com/ems/backend/internal/repository"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestMaskAddressHidesTokens(t *testing.T) {
+	cases := map[string]string{
+		"alice@example.com": "a***@example.com",
+		"https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=SECRET": "https://qyapi.weixin.qq.com/cgi-bin/webhook/send?***",
+		"https://hooks.example.com/ems":                               "https://hooks.example.com/ems",
+		"":                                                            "",
+	}
+	for in, want := range cases {
+		if got := maskAddress(in); got != want {
+			t.Errorf("maskAddress(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestPreferenceEventFilter(t *testing.T) {
+	all := &model.NotificationPreference{}
+	if !wantsEvent(all, event.RepairCreated) {
+		t.Errorf("Expected empty event types to accept every event")
+	}
+	some := &model.NotificationPreference{EventTypes: "repair.sla_breached, equipment.rul_risk"}
+	if !wantsEvent(some, event.EquipmentRULRisk) || wantsEvent(some, event.RepairCreated) {
+		t.Errorf("Expected only listed event types to be accepted")
+	}
+}
+
+func TestNotificationSettingsAreHardDeleted(t *testing.T) {
+	// DryRun 只生成 SQL 不连库；软删除会生成 UPDATE ... deleted_at，唯一索引下无法重新添加
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost dbname=ems"}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var statements []string
+	capture := func(tx *gorm.DB) { statements = append(statements, tx.Statement.SQL.String()) }
+	db.Callback().Delete().After("gorm:delete").Register("test:capture", capture)
+
+	prev := repository.DB
+	repository.DB = db
+	defer func() { repository.DB = prev }()
+
+	repo := repository.NewNotificationRepository()
+	_ = repo.DeletePreference(1, "wecom")
+	_ = repo.DeleteTemplate(1)
+	if len(statements) != 2 {
+		t.Fatalf("Expected 2 delete statements, got %v", statements)
+	}
+	for _, sql := range statements {
+		if !strings.HasPrefix(sql, "DELETE FROM") {
+			t.Errorf("Expected hard delete, got %s", sql)
+		}
+	}
+}
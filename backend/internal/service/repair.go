@@ -6,8 +6,10 @@ import (
 	"time"
 
 	"github.com/ems/backend/internal/dto"
+	"github.com/ems/backend/internal/event"
 	"github.com/ems/backend/internal/model"
 	"github.com/ems/backend/internal/repository"
+	"gorm.io/gorm"
 )
 
 // RepairOrderService
@@ -60,17 +62,28 @@ func (s *RepairOrderService) CreateOrder(req *CreateOrderRequest) (*model.Repair
 		Status:           model.RepairPending,
 	}
 
-	if err := s.orderRepo.Create(order); err != nil {
+	// 工单、设备状态、日志与领域事件在同一事务中提交
+	err = repository.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.orderRepo.WithTx(tx).Create(order); err != nil {
+			return err
+		}
+		// Update equipment status to maintenance
+		if err := setEquipmentStatus(tx, s.equipRepo, equipment, "maintenance", fmt.Sprintf("repair_order:%d", order.ID)); err != nil {
+			return err
+		}
+		if err := s.logRepo.WithTx(tx).Create(&model.RepairLog{OrderID: order.ID, UserID: req.ReporterID, Action: "created", Content: "创建维修工单"}); err != nil {
+			return err
+		}
+		return event.Publish(tx, event.RepairCreatedPayload{
+			Subject: equipmentSubject(equipment), OrderID: order.ID, EquipmentCode: equipment.Code,
+			FaultDescription: order.FaultDescription, FaultCode: order.FaultCode, Priority: order.Priority,
+			ReporterID: order.ReporterID, Source: "manual",
+		})
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	// Update equipment status to maintenance
-	equipment.Status = "maintenance"
-	s.equipRepo.Update(equipment)
-
-	// Create log
-	s.createLog(order.ID, req.ReporterID, "created", "创建维修工单")
-
 	return s.orderRepo.GetByID(order.ID)
 }
 
@@ -81,24 +94,30 @@ func (s *RepairOrderService) GetByID(id uint) (*model.RepairOrder, error) {
 
 // List returns repair orders with filtering
 type RepairOrderFilter struct {
-	Status     string
-	Priority   int
-	AssignedTo uint
-	DateFrom   time.Time
-	DateTo     time.Time
-	Page       int
-	PageSize   int
+	Status      string
+	Priority    int
+	AssignedTo  uint
+	FactoryID   uint
+	EquipmentID uint
+	FaultCode   string
+	DateFrom    time.Time
+	DateTo      time.Time
+	Page        int
+	PageSize    int
 }
 
 func (s *RepairOrderService) List(filter *RepairOrderFilter) (*RepairOrderListResult, error) {
 	repoFilter := repository.RepairOrderFilter{
-		Status:     filter.Status,
-		Priority:   filter.Priority,
-		AssignedTo: filter.AssignedTo,
-		DateFrom:   filter.DateFrom,
-		DateTo:     filter.DateTo,
-		Page:       filter.Page,
-		PageSize:   filter.PageSize,
+		Status:      filter.Status,
+		Priority:    filter.Priority,
+		AssignedTo:  filter.AssignedTo,
+		FactoryID:   filter.FactoryID,
+		EquipmentID: filter.EquipmentID,
+		FaultCode:   filter.FaultCode,
+		DateFrom:    filter.DateFrom,
+		DateTo:      filter.DateTo,
+		Page:        filter.Page,
+		PageSize:    filter.PageSize,
 	}
 
 	orders, total, err := s.orderRepo.List(repoFilter)
@@ -130,13 +149,10 @@ func (s *RepairOrderService) AssignOrder(orderID uint, assignTo uint, assignedBy
 
 	order.AssignedTo = &assignTo
 	order.Status = model.RepairAssigned
-	if err := s.orderRepo.Update(order); err != nil {
+	if err := s.transition(order, model.RepairPending, assignedBy, "assigned", fmt.Sprintf("指派给维修工 #%d", assignTo), nil); err != nil {
 		return nil, err
 	}
 
-	// Create log
-	s.createLog(orderID, assignedBy, "assigned", fmt.Sprintf("指派给维修工 #%d", assignTo))
-
 	return s.orderRepo.GetByID(orderID)
 }
 
@@ -197,13 +213,7 @@ func (s *RepairOrderService) StartRepair(orderID uint, userID uint) error {
 	order.Status = model.RepairInProgress
 	order.StartedAt = &now
 
-	if err := s.orderRepo.Update(order); err != nil {
-		return err
-	}
-
-	s.createLog(orderID, userID, "started", "开始维修")
-
-	return nil
+	return s.transition(order, model.RepairAssigned, userID, "started", "开始维修", nil)
 }
 
 // UpdateRepair updates repair progress
@@ -229,6 +239,7 @@ func (s *RepairOrderService) UpdateRepair(orderID uint, userID uint, req *Update
 		return errors.New("order is not in updatable state")
 	}
 
+	from := order.Status
 	order.Solution = req.Solution
 	if len(req.Photos) > 0 {
 		order.Photos = req.Photos
@@ -243,9 +254,6 @@ func (s *RepairOrderService) UpdateRepair(orderID uint, userID uint, req *Update
 		OtherCost:     req.OtherCost,
 		DowntimeLoss:  req.DowntimeLoss,
 	}
-	if err := s.orderRepo.UpdateCostDetail(cost); err != nil {
-		return err
-	}
 
 	// Handle status transition
 	var statusChanged bool
@@ -273,17 +281,13 @@ func (s *RepairOrderService) UpdateRepair(orderID uint, userID uint, req *Update
 		order.Status = newStatus
 	}
 
-	if err := s.orderRepo.Update(order); err != nil {
-		return err
-	}
-
 	logAction := "updated"
 	if statusChanged {
 		logAction = "status_changed"
 	}
-	s.createLog(orderID, userID, logAction, logContent)
-
-	return nil
+	return s.transition(order, from, userID, logAction, logContent, func(tx *gorm.DB) error {
+		return s.orderRepo.WithTx(tx).UpdateCostDetail(cost)
+	})
 }
 
 // ConfirmRepair confirms or rejects a completed repair
@@ -297,6 +301,8 @@ func (s *RepairOrderService) ConfirmRepair(orderID uint, userID uint, accepted b
 		return errors.New("order is not ready for confirmation")
 	}
 
+	from := order.Status
+	action, content := "confirmed", "确认维修完成"
 	if accepted {
 		now := time.Now()
 		order.Status = model.RepairAudited
@@ -304,18 +310,17 @@ func (s *RepairOrderService) ConfirmRepair(orderID uint, userID uint, accepted b
 		if order.CompletedAt == nil {
 			order.CompletedAt = &now
 		}
-		s.createLog(orderID, userID, "confirmed", "确认维修完成")
 	} else {
 		// Reject - send back to in_progress
 		order.Status = model.RepairInProgress
-		s.createLog(orderID, userID, "rejected", "确认不通过: "+comment)
+		action, content = "rejected", "确认不通过: "+comment
 	}
 
 	if len(photos) > 0 {
 		order.Photos = photos
 	}
 
-	return s.orderRepo.Update(order)
+	return s.transition(order, from, userID, action, content, nil)
 }
 
 // AuditRepair audits a confirmed repair (supervisor/engineer)
@@ -329,39 +334,37 @@ func (s *RepairOrderService) AuditRepair(orderID uint, userID uint, req *dto.Aud
 		return errors.New("order is not ready for audit")
 	}
 
-	if req.Approved {
-		now := time.Now()
-		order.Status = model.RepairClosed
-		order.AuditedAt = &now
-
-		// Update costs from audit
-		cost := &model.RepairCostDetail{
-			OrderID:       orderID,
-			ActualHours:   req.ActualHours,
-			SparePartCost: req.SparePartCost,
-			LaborCost:     req.LaborCost,
-			OtherCost:     req.OtherCost,
-			DowntimeLoss:  req.DowntimeLoss,
-		}
-		if err := s.orderRepo.UpdateCostDetail(cost); err != nil {
-			return err
-		}
-
-		// Update equipment status back to running
-		if equipment, err := s.equipRepo.GetByID(order.EquipmentID); err == nil {
-			equipment.Status = "running"
-			s.equipRepo.Update(equipment)
-		}
-
-		s.createLog(orderID, userID, "audited", "审核通过，工单关闭")
-	} else {
+	if !req.Approved {
 		// Reject - send back to in_progress
 		order.Status = model.RepairInProgress
 		order.ConfirmedAt = nil
-		s.createLog(orderID, userID, "audit_rejected", "审核不通过: "+req.Comment)
+		return s.transition(order, model.RepairAudited, userID, "audit_rejected", "审核不通过: "+req.Comment, nil)
 	}
 
-	return s.orderRepo.Update(order)
+	now := time.Now()
+	order.Status = model.RepairClosed
+	order.AuditedAt = &now
+	order.ClosedAt = &now
+
+	// Update costs from audit
+	cost := &model.RepairCostDetail{
+		OrderID:       orderID,
+		ActualHours:   req.ActualHours,
+		SparePartCost: req.SparePartCost,
+		LaborCost:     req.LaborCost,
+		OtherCost:     req.OtherCost,
+		DowntimeLoss:  req.DowntimeLoss,
+	}
+	return s.transition(order, model.RepairAudited, userID, "audited", "审核通过，工单关闭", func(tx *gorm.DB) error {
+		if err := s.orderRepo.WithTx(tx).UpdateCostDetail(cost); err != nil {
+			return err
+		}
+		// Update equipment status back to running
+		if equipment, err := s.equipRepo.GetByID(order.EquipmentID); err == nil {
+			return setEquipmentStatus(tx, s.equipRepo, equipment, "running", fmt.Sprintf("repair_order:%d", order.ID))
+		}
+		return nil
+	})
 }
 
 // GetStatistics returns repair statistics
@@ -407,14 +410,29 @@ func (s *RepairOrderService) GetMyTasks(userID uint) ([]model.RepairOrder, error
 }
 
 // Helper functions
-func (s *RepairOrderService) createLog(orderID uint, userID uint, action, content string) {
-	log := &model.RepairLog{
-		OrderID: orderID,
-		UserID:  userID,
-		Action:  action,
-		Content: content,
-	}
-	s.logRepo.Create(log)
+
+// transition 在同一事务中保存工单、记录日志，状态变化时发布 repair.status_changed
+func (s *RepairOrderService) transition(order *model.RepairOrder, from model.RepairStatus, userID uint, action, content string, extra func(tx *gorm.DB) error) error {
+	return repository.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.orderRepo.WithTx(tx).Update(order); err != nil {
+			return err
+		}
+		if extra != nil {
+			if err := extra(tx); err != nil {
+				return err
+			}
+		}
+		if err := s.logRepo.WithTx(tx).Create(&model.RepairLog{OrderID: order.ID, UserID: userID, Action: action, Content: content}); err != nil {
+			return err
+		}
+		if order.Status == from {
+			return nil
+		}
+		return event.Publish(tx, event.RepairStatusChangedPayload{
+			Subject: subjectOf(s.equipRepo, order.EquipmentID), OrderID: order.ID, Priority: order.Priority,
+			From: string(from), To: string(order.Status), OperatorID: userID, Comment: content,
+		})
+	})
 }
 
 func (s *RepairOrderService) findLeastBusyTechnician(technicians []uint) uint {
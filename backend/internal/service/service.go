@@ -13,6 +13,7 @@ var (
 	ErrNotFound      = errors.New("record not found")
 	ErrDuplicateCode = errors.New("code already exists")
 	ErrInvalidInput  = errors.New("invalid input")
+	ErrForbidden     = errors.New("insufficient permissions")
 )
 
 // Base Service
@@ -102,7 +103,7 @@ func NewFactoryService() *FactoryService {
 	}
 }
 
-func (s *FactoryService) Create(baseID uint, code, name string) (*model.Factory, error) {
+func (s *FactoryService) Create(baseID uint, code, name string, managerID *uint) (*model.Factory, error) {
 	// Verify base exists
 	if _, err := s.baseRepo.GetByID(baseID); err != nil {
 		return nil, ErrNotFound
@@ -114,9 +115,10 @@ func (s *FactoryService) Create(baseID uint, code, name string) (*model.Factory,
 	}
 
 	factory := &model.Factory{
-		BaseID: baseID,
-		Code:   code,
-		Name:   name,
+		BaseID:    baseID,
+		Code:      code,
+		Name:      name,
+		ManagerID: managerID,
 	}
 
 	if err := s.repo.Create(factory); err != nil {
@@ -138,7 +140,7 @@ func (s *FactoryService) ListByBaseID(baseID uint) ([]model.Factory, error) {
 	return s.repo.ListByBaseID(baseID)
 }
 
-func (s *FactoryService) Update(id uint, baseID uint, code, name string) (*model.Factory, error) {
+func (s *FactoryService) Update(id uint, baseID uint, code, name string, managerID *uint) (*model.Factory, error) {
 	factory, err := s.repo.GetByID(id)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -162,6 +164,7 @@ func (s *FactoryService) Update(id uint, baseID uint, code, name string) (*model
 	factory.BaseID = baseID
 	factory.Code = code
 	factory.Name = name
+	factory.ManagerID = managerID
 
 	if err := s.repo.Update(factory); err != nil {
 		return nil, err
@@ -194,7 +197,7 @@ func NewWorkshopService() *WorkshopService {
 	}
 }
 
-func (s *WorkshopService) Create(factoryID uint, code, name string) (*model.Workshop, error) {
+func (s *WorkshopService) Create(factoryID uint, code, name string, supervisorID *uint) (*model.Workshop, error) {
 	// Verify factory exists
 	if _, err := s.factoryRepo.GetByID(factoryID); err != nil {
 		return nil, ErrNotFound
@@ -206,9 +209,10 @@ func (s *WorkshopService) Create(factoryID uint, code, name string) (*model.Work
 	}
 
 	workshop := &model.Workshop{
-		FactoryID: factoryID,
-		Code:      code,
-		Name:      name,
+		FactoryID:    factoryID,
+		Code:         code,
+		Name:         name,
+		SupervisorID: supervisorID,
 	}
 
 	if err := s.repo.Create(workshop); err != nil {
@@ -230,7 +234,7 @@ func (s *WorkshopService) ListByFactoryID(factoryID uint) ([]model.Workshop, err
 	return s.repo.ListByFactoryID(factoryID)
 }
 
-func (s *WorkshopService) Update(id uint, factoryID uint, code, name string) (*model.Workshop, error) {
+func (s *WorkshopService) Update(id uint, factoryID uint, code, name string, supervisorID *uint) (*model.Workshop, error) {
 	workshop, err := s.repo.GetByID(id)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -254,6 +258,7 @@ func (s *WorkshopService) Update(id uint, factoryID uint, code, name string) (*m
 	workshop.FactoryID = factoryID
 	workshop.Code = code
 	workshop.Name = name
+	workshop.SupervisorID = supervisorID
 
 	if err := s.repo.Update(workshop); err != nil {
 		return nil, err
@@ -504,8 +509,9 @@ func (s *EquipmentService) Scrap(id uint) error {
 		return err
 	}
 
-	equipment.Status = "scrapped"
-	return s.repo.Update(equipment)
+	return repository.DB.Transaction(func(tx *gorm.DB) error {
+		return setEquipmentStatus(tx, s.repo, equipment, "scrapped", "scrap")
+	})
 }
 
 func (s *EquipmentService) Seal(id uint) error {
@@ -517,8 +523,9 @@ func (s *EquipmentService) Seal(id uint) error {
 		return err
 	}
 
-	equipment.Status = "stopped" // 'stopped' represents sealed/deactivated
-	return s.repo.Update(equipment)
+	return repository.DB.Transaction(func(tx *gorm.DB) error {
+		return setEquipmentStatus(tx, s.repo, equipment, "stopped", "seal") // 'stopped' represents sealed/deactivated
+	})
 }
 
 func (s *EquipmentService) Enable(id uint) error {
@@ -530,8 +537,9 @@ func (s *EquipmentService) Enable(id uint) error {
 		return err
 	}
 
-	equipment.Status = "running"
-	return s.repo.Update(equipment)
+	return repository.DB.Transaction(func(tx *gorm.DB) error {
+		return setEquipmentStatus(tx, s.repo, equipment, "running", "enable")
+	})
 }
 
 func (s *EquipmentService) GetStatistics() (*EquipmentStatistics, error) {
@@ -3,9 +3,12 @@ package service
 import (
 	"errors"
 	"fmt"
+	"time"
 
+	"github.com/ems/backend/internal/event"
 	"github.com/ems/backend/internal/model"
 	"github.com/ems/backend/internal/repository"
+	"gorm.io/gorm"
 )
 
 // SparePartService
@@ -14,6 +17,7 @@ type SparePartService struct {
 	inventoryRepo   *repository.SparePartInventoryRepository
 	consumptionRepo *repository.SparePartConsumptionRepository
 	txRepo          *repository.SparePartTransactionRepository
+	reservationRepo *repository.SparePartReservationRepository
 	userRepo        *repository.UserRepository
 }
 
@@ -23,6 +27,7 @@ func NewSparePartService() *SparePartService {
 		inventoryRepo:   repository.NewSparePartInventoryRepository(),
 		consumptionRepo: repository.NewSparePartConsumptionRepository(),
 		txRepo:          repository.NewSparePartTransactionRepository(),
+		reservationRepo: repository.NewSparePartReservationRepository(),
 		userRepo:        repository.NewUserRepository(),
 	}
 }
@@ -93,7 +98,7 @@ func (s *SparePartService) DeletePart(id uint) error {
 }
 
 // Inventory operations
-func (s *SparePartService) StockIn(partID, factoryID uint, quantity int, remark string, userID uint) error {
+func (s *SparePartService) StockIn(partID, factoryID uint, quantity int, batchNo, remark string, userID uint) error {
 	// Verify part exists
 	_, err := s.partRepo.GetByID(partID)
 	if err != nil {
@@ -112,6 +117,7 @@ func (s *SparePartService) StockIn(partID, factoryID uint, quantity int, remark
 		Type:        "in",
 		Quantity:    quantity,
 		OperatorID:  userID,
+		BatchNo:     batchNo,
 		Remark:      remark,
 	}
 
@@ -120,7 +126,7 @@ func (s *SparePartService) StockIn(partID, factoryID uint, quantity int, remark
 
 func (s *SparePartService) StockOut(partID, factoryID uint, quantity int, orderID, taskID *uint, remark string, userID uint) error {
 	// Verify part exists
-	_, err := s.partRepo.GetByID(partID)
+	part, err := s.partRepo.GetByID(partID)
 	if err != nil {
 		return ErrNotFound
 	}
@@ -134,40 +140,126 @@ func (s *SparePartService) StockOut(partID, factoryID uint, quantity int, orderI
 	if inv.Quantity < quantity {
 		return fmt.Errorf("insufficient stock: available %d, requested %d", inv.Quantity, quantity)
 	}
-
-	// Update inventory
-	if err := s.inventoryRepo.UpdateQuantity(partID, factoryID, -quantity); err != nil {
+	// 其他工单预留的数量不可出库
+	now := time.Now()
+	reserved, err := s.reservationRepo.ReservedQuantity(partID, factoryID, now, orderID, taskID)
+	if err != nil {
 		return err
 	}
-
-	// Create consumption record
-	consumption := &model.SparePartConsumption{
-		SparePartID: partID,
-		OrderID:     orderID,
-		TaskID:      taskID,
-		Quantity:    quantity,
-		UserID:      userID,
+	if inv.Quantity-reserved < quantity {
+		return fmt.Errorf("insufficient stock: available %d (%d reserved), requested %d", inv.Quantity-reserved, reserved, quantity)
 	}
 
-	if err := s.consumptionRepo.Create(consumption); err != nil {
-		return err
+	// 库存、消耗、流水与低库存事件在同一事务中提交
+	return repository.DB.Transaction(func(db *gorm.DB) error {
+		// Update inventory
+		if err := s.inventoryRepo.WithTx(db).UpdateQuantity(partID, factoryID, -quantity); err != nil {
+			return err
+		}
+		if err := s.reservationRepo.WithTx(db).MarkIssued(partID, factoryID, now, orderID, taskID); err != nil {
+			return err
+		}
+
+		// Create consumption record
+		consumption := &model.SparePartConsumption{
+			SparePartID: partID,
+			OrderID:     orderID,
+			TaskID:      taskID,
+			Quantity:    quantity,
+			UserID:      userID,
+		}
+
+		if err := s.consumptionRepo.WithTx(db).Create(consumption); err != nil {
+			return err
+		}
+
+		// Create transaction record
+		tx := &model.SparePartTransaction{
+			SparePartID: partID,
+			FactoryID:   factoryID,
+			Type:        "out",
+			Quantity:    -quantity,
+			OperatorID:  userID,
+			RelatedID:   orderID, // Prioritize order_id as related_id
+			Remark:      remark,
+		}
+		if tx.RelatedID == nil {
+			tx.RelatedID = taskID
+		}
+		if err := s.txRepo.WithTx(db).Create(tx); err != nil {
+			return err
+		}
+
+		// 仅在本次出库使库存跌破安全库存时发布，避免持续低库存时重复告警
+		remaining := inv.Quantity - quantity
+		if inv.Quantity < part.SafetyStock || remaining >= part.SafetyStock {
+			return nil
+		}
+		return event.Publish(db, event.SparePartBelowSafetyStockPayload{
+			Subject: event.Subject{FactoryID: &factoryID}, SparePartID: partID, Code: part.Code, Name: part.Name,
+			Quantity: remaining, SafetyStock: part.SafetyStock,
+		})
+	})
+}
+
+// Reserve 为工单或保养任务预留备件：只占用可用库存，不出库；到期自动失效，可随时释放
+func (s *SparePartService) Reserve(partID, factoryID uint, quantity int, orderID, taskID *uint, ttl time.Duration, remark string, userID uint) (*model.SparePartReservation, int, error) {
+	if quantity <= 0 || ttl <= 0 {
+		return nil, 0, fmt.Errorf("%w: quantity and expiry must be positive", ErrInvalidInput)
+	}
+	if _, err := s.partRepo.GetByID(partID); err != nil {
+		return nil, 0, ErrNotFound
 	}
 
-	// Create transaction record
-	tx := &model.SparePartTransaction{
-		SparePartID: partID,
-		FactoryID:   factoryID,
-		Type:        "out",
-		Quantity:    -quantity,
-		OperatorID:  userID,
-		RelatedID:   orderID, // Prioritize order_id as related_id
-		Remark:      remark,
+	var reservation *model.SparePartReservation
+	available := 0
+	err := repository.DB.Transaction(func(db *gorm.DB) error {
+		// 锁定库存行，保证并发预留不会超出可用量
+		inv, err := s.inventoryRepo.WithTx(db).LockByPartAndFactory(partID, factoryID)
+		if err != nil {
+			return errors.New("inventory not found")
+		}
+		now := time.Now()
+		reserved, err := s.reservationRepo.WithTx(db).ReservedQuantity(partID, factoryID, now, nil, nil)
+		if err != nil {
+			return err
+		}
+		available = inv.Quantity - reserved
+		if available < quantity {
+			return fmt.Errorf("insufficient stock: available %d (%d reserved), requested %d", available, reserved, quantity)
+		}
+		reservation = &model.SparePartReservation{
+			SparePartID: partID, FactoryID: factoryID, Quantity: quantity,
+			RepairOrderID: orderID, MaintenanceTaskID: taskID, Status: model.ReservationActive,
+			ExpiresAt: now.Add(ttl), ReservedBy: userID, Remark: remark,
+		}
+		available -= quantity
+		return s.reservationRepo.WithTx(db).Create(reservation)
+	})
+	if err != nil {
+		return nil, 0, err
 	}
-	if tx.RelatedID == nil {
-		tx.RelatedID = taskID
+	return reservation, available, nil
+}
+
+func (s *SparePartService) GetReservation(id uint) (*model.SparePartReservation, error) {
+	reservation, err := s.reservationRepo.GetByID(id)
+	if err != nil {
+		return nil, ErrNotFound
 	}
+	return reservation, nil
+}
 
-	return s.txRepo.Create(tx)
+// ReleaseReservation 释放预留，归还可用库存
+func (s *SparePartService) ReleaseReservation(id uint) error {
+	released, err := s.reservationRepo.Release(id, time.Now())
+	if err != nil {
+		return err
+	}
+	if !released {
+		return fmt.Errorf("%w: reservation %d is no longer active", ErrInvalidInput, id)
+	}
+	return nil
 }
 
 func (s *SparePartService) GetInventory(filter repository.InventoryFilter) (*InventoryListResult, error) {
@@ -129,6 +129,21 @@ func runDatabaseMode() {
 		&model.SparePartInventory{},
 		&model.SparePartConsumption{},
 		&model.SparePartTransaction{},
+		&model.SparePartReservation{},
+		&model.DomainEvent{},
+		&model.LarkAlertCard{},
+		&model.NotificationPreference{},
+		&model.NotificationTemplate{},
+		&model.NotificationDelivery{},
+		&model.NotificationQuietHours{},
+		&model.Alert{},
+		&model.AlertDigestEntry{},
+		&model.EscalationPolicy{},
+		&model.Escalation{},
+		&model.EscalationLog{},
+		&model.OnCallShift{},
+		&model.ScheduledJob{},
+		&model.JobRun{},
 		&model.KnowledgeArticle{},
 		&model.ManualDocument{},
 		&model.ManualChunk{},
@@ -144,6 +159,12 @@ func runDatabaseMode() {
 		&model.AgentConversation{},
 		&model.AgentMessage{},
 		&model.AgentPushSubscription{},
+		&model.AgentPushLog{},
+		&model.AgentPushDeadLetter{},
+		&model.AgentBriefing{},
+		&model.AgentFeedback{},
+		&model.AgentConversationShare{},
+		&model.AgentEquipmentNote{},
 		&model.UserAPIKey{},
 	}
 	for _, m := range models {
@@ -151,6 +172,12 @@ func runDatabaseMode() {
 			log.Printf("Warning: AutoMigrate error for %T: %v", m, err)
 		}
 	}
+	// 通知偏好与模板改为物理删除；清理此前软删除的记录，否则唯一索引会阻止重新添加
+	for _, m := range []interface{}{&model.NotificationPreference{}, &model.NotificationTemplate{}} {
+		if err := db.Unscoped().Where("deleted_at IS NOT NULL").Delete(m).Error; err != nil {
+			log.Printf("Warning: failed to purge soft-deleted %T: %v", m, err)
+		}
+	}
 
 	// Initialize middleware with DB
 	middleware.Init(db)
@@ -166,6 +193,11 @@ func runDatabaseMode() {
 	v1.InitKnowledge()
 	v1.InitManual()
 	v1.InitLark(database.GetDB())
+	v1.InitBriefing()
+	v1.InitNotification()
+	v1.InitEvents()
+	v1.InitScheduler()
+	v1.PublishAgentTools()
 
 	// 补种演示数据 (Milestone: Data Parity)
 	if err := repository.SeedDatabase(database.GetDB()); err != nil {
@@ -404,22 +436,50 @@ func setupMemoryRoutes(router *gin.Engine) {
 				agent.POST("/maintenance/recommend", agentCtrl.RecommendMaintenance)
 				agent.POST("/audit/repair", agentCtrl.AuditRepair)
 				agent.POST("/audit/maintenance", agentCtrl.AuditMaintenance)
+				agent.POST("/root-cause", agentCtrl.AnalyzeRootCause)
 				agent.POST("/analyze", agentCtrl.Analyze)
+				agent.POST("/orchestrate", agentCtrl.Orchestrate)
 				agent.POST("/chat", agentCtrl.Chat)
 				agent.GET("/knowledges", agentCtrl.ListKnowledges)
 				agent.PUT("/knowledge/:id/status", agentCtrl.AuditKnowledge)
 				agent.GET("/conversations", agentCtrl.ListConversations)
 				agent.GET("/conversations/:id", agentCtrl.GetConversation)
+				agent.PUT("/conversations/:id", agentCtrl.UpdateConversation)
+				agent.DELETE("/conversations/:id", agentCtrl.DeleteConversation)
+				agent.GET("/conversations/:id/export", agentCtrl.ExportConversation)
+				agent.POST("/conversations/:id/shares", agentCtrl.CreateShare)
+				agent.GET("/conversations/:id/shares", agentCtrl.ListShares)
+				agent.DELETE("/conversations/:id/shares/:shareId", agentCtrl.RevokeShare)
+				agent.GET("/shared/conversations/:token", agentCtrl.GetSharedConversation)
+				agent.GET("/messages/search", agentCtrl.SearchMessages)
 				agent.GET("/skills", agentCtrl.ListSkills)
 				agent.POST("/skills", agentCtrl.CreateSkill)
 				agent.GET("/skills/:id", agentCtrl.GetSkill)
 				agent.PUT("/skills/:id", agentCtrl.UpdateSkill)
 				agent.GET("/equipment/:id/prediction", agentCtrl.GetEquipmentPrediction)
+				agent.POST("/equipment/:id/notes", agentCtrl.AddEquipmentNote)
+				agent.GET("/equipment-notes", agentCtrl.ListEquipmentNotes)
+				agent.PUT("/equipment-notes/:id/review", agentCtrl.ReviewEquipmentNote)
+				agent.DELETE("/equipment-notes/:id", agentCtrl.DeleteEquipmentNote)
 				agent.POST("/subscribe", agentCtrl.Subscribe)
 				agent.GET("/subscriptions", agentCtrl.ListSubscriptions)
+				agent.PUT("/subscriptions/:id", agentCtrl.UpdateSubscription)
+				agent.DELETE("/subscriptions/:id", agentCtrl.DeleteSubscription)
+				agent.POST("/subscriptions/:id/pause", agentCtrl.PauseSubscription)
+				agent.POST("/subscriptions/:id/resume", agentCtrl.ResumeSubscription)
+				agent.POST("/subscriptions/:id/rotate-secret", agentCtrl.RotateSubscriptionSecret)
+				agent.POST("/subscriptions/:id/ping", agentCtrl.PingSubscription)
+				agent.GET("/subscriptions/:id/deliveries", agentCtrl.ListSubscriptionDeliveries)
+				agent.POST("/subscriptions/:id/deliveries/:delivery_id/redeliver", agentCtrl.RedeliverSubscriptionDelivery)
+				agent.GET("/push-schemas", agentCtrl.ListPushSchemas)
 				agent.GET("/sessions", agentCtrl.ListSessions)
 				agent.GET("/sessions/:id", agentCtrl.GetSession)
 				agent.GET("/artifacts/:id", agentCtrl.GetArtifact)
+				agent.GET("/artifacts/:id/export", agentCtrl.ExportArtifact)
+				agent.POST("/feedback", agentCtrl.SubmitFeedback)
+				agent.GET("/feedback/reviews", agentCtrl.ListFeedbackReviews)
+				agent.PUT("/feedback/:id/review", agentCtrl.ReviewFeedback)
+				agent.GET("/feedback/analytics", agentCtrl.FeedbackAnalytics)
 
 				// Tool Discovery (P2)
 				agent.GET("/tools", agentCtrl.ListTools)
@@ -549,6 +609,8 @@ func setupDatabaseRoutes(router *gin.Engine) {
 				repair.POST("/orders/:id/update", v1.UpdateRepair)
 				repair.POST("/orders/:id/confirm", v1.ConfirmRepair)
 				repair.POST("/orders/:id/audit", v1.AuditRepair)
+				repair.POST("/orders/:id/acknowledge", v1.AcknowledgeRepairOrder)
+				repair.GET("/orders/:id/escalations", v1.GetRepairOrderEscalations)
 				repair.GET("/my-tasks", v1.GetMyRepairTasks)
 				repair.GET("/my-stats", v1.GetMyRepairStatistics)
 				repair.GET("/statistics", v1.GetRepairStatistics)
@@ -625,6 +687,65 @@ func setupDatabaseRoutes(router *gin.Engine) {
 				knowledge.DELETE("/:id", v1.DeleteKnowledgeArticle)
 			}
 
+			// Notification routes (database mode only)
+			notifications := protected.Group("/notifications")
+			{
+				notifications.GET("/channels", v1.ListNotificationChannels)
+				notifications.GET("/preferences", v1.ListNotificationPreferences)
+				notifications.PUT("/preferences/:channel", v1.SaveNotificationPreference)
+				notifications.DELETE("/preferences/:channel", v1.DeleteNotificationPreference)
+				notifications.POST("/preferences/:channel/test", v1.TestNotificationPreference)
+				notifications.GET("/deliveries", v1.ListNotificationDeliveries)
+				notifications.GET("/templates", v1.ListNotificationTemplates)
+				notifications.PUT("/templates", v1.SaveNotificationTemplate)
+				notifications.DELETE("/templates/:id", v1.DeleteNotificationTemplate)
+				notifications.GET("/quiet-hours", v1.GetQuietHours)
+				notifications.PUT("/quiet-hours", v1.SaveQuietHours)
+			}
+
+			// Alert routes (database mode only)
+			alerts := protected.Group("/alerts")
+			{
+				alerts.GET("", v1.ListAlerts)
+				alerts.GET("/:id", v1.GetAlert)
+				alerts.POST("/:id/acknowledge", v1.AcknowledgeAlert)
+				alerts.POST("/:id/resolve", v1.ResolveAlert)
+				alerts.GET("/:id/escalations", v1.GetAlertEscalations)
+			}
+
+			// Escalation & on-call routes (database mode only)
+			escalationPolicies := protected.Group("/escalation-policies")
+			{
+				escalationPolicies.GET("", v1.ListEscalationPolicies)
+				escalationPolicies.POST("", v1.CreateEscalationPolicy)
+				escalationPolicies.PUT("/:id", v1.UpdateEscalationPolicy)
+				escalationPolicies.DELETE("/:id", v1.DeleteEscalationPolicy)
+			}
+			escalations := protected.Group("/escalations")
+			{
+				escalations.GET("", v1.ListEscalations)
+				escalations.GET("/:id", v1.GetEscalation)
+				escalations.POST("/:id/acknowledge", v1.AcknowledgeEscalation)
+			}
+			oncall := protected.Group("/oncall")
+			{
+				oncall.GET("", v1.ListOnCallShifts)
+				oncall.GET("/current", v1.GetCurrentOnCall)
+				oncall.POST("", v1.CreateOnCallShift)
+				oncall.DELETE("/:id", v1.DeleteOnCallShift)
+			}
+
+			// Scheduler routes (database mode only, admin)
+			jobs := protected.Group("/scheduler/jobs")
+			{
+				jobs.GET("", v1.ListScheduledJobs)
+				jobs.GET("/:name", v1.GetScheduledJob)
+				jobs.GET("/:name/runs", v1.ListScheduledJobRuns)
+				jobs.POST("/:name/trigger", v1.TriggerScheduledJob)
+				jobs.POST("/:name/pause", v1.PauseScheduledJob)
+				jobs.POST("/:name/resume", v1.ResumeScheduledJob)
+			}
+
 			// Agent routes
 			agent := protected.Group("/agent")
 			agentCtrl := agentController.NewAgentController()
@@ -632,26 +753,61 @@ func setupDatabaseRoutes(router *gin.Engine) {
 				agent.POST("/maintenance/recommend", agentCtrl.RecommendMaintenance)
 				agent.POST("/audit/repair", agentCtrl.AuditRepair)
 				agent.POST("/audit/maintenance", agentCtrl.AuditMaintenance)
+				agent.POST("/root-cause", agentCtrl.AnalyzeRootCause)
 				agent.POST("/analyze", agentCtrl.Analyze)
+				agent.POST("/orchestrate", agentCtrl.Orchestrate)
 				agent.POST("/chat", agentCtrl.Chat)
 				agent.GET("/knowledges", agentCtrl.ListKnowledges)
 				agent.PUT("/knowledge/:id/status", agentCtrl.AuditKnowledge)
 				agent.GET("/conversations", agentCtrl.ListConversations)
 				agent.GET("/conversations/:id", agentCtrl.GetConversation)
+				agent.PUT("/conversations/:id", agentCtrl.UpdateConversation)
+				agent.DELETE("/conversations/:id", agentCtrl.DeleteConversation)
+				agent.GET("/conversations/:id/export", agentCtrl.ExportConversation)
+				agent.POST("/conversations/:id/shares", agentCtrl.CreateShare)
+				agent.GET("/conversations/:id/shares", agentCtrl.ListShares)
+				agent.DELETE("/conversations/:id/shares/:shareId", agentCtrl.RevokeShare)
+				agent.GET("/shared/conversations/:token", agentCtrl.GetSharedConversation)
+				agent.GET("/messages/search", agentCtrl.SearchMessages)
 				agent.GET("/skills", agentCtrl.ListSkills)
 				agent.POST("/skills", agentCtrl.CreateSkill)
 				agent.GET("/skills/:id", agentCtrl.GetSkill)
 				agent.PUT("/skills/:id", agentCtrl.UpdateSkill)
 				agent.GET("/equipment/:id/prediction", agentCtrl.GetEquipmentPrediction)
+				agent.POST("/equipment/:id/notes", agentCtrl.AddEquipmentNote)
+				agent.GET("/equipment-notes", agentCtrl.ListEquipmentNotes)
+				agent.PUT("/equipment-notes/:id/review", agentCtrl.ReviewEquipmentNote)
+				agent.DELETE("/equipment-notes/:id", agentCtrl.DeleteEquipmentNote)
 				agent.POST("/subscribe", agentCtrl.Subscribe)
 				agent.GET("/subscriptions", agentCtrl.ListSubscriptions)
+				agent.PUT("/subscriptions/:id", agentCtrl.UpdateSubscription)
+				agent.DELETE("/subscriptions/:id", agentCtrl.DeleteSubscription)
+				agent.POST("/subscriptions/:id/pause", agentCtrl.PauseSubscription)
+				agent.POST("/subscriptions/:id/resume", agentCtrl.ResumeSubscription)
+				agent.POST("/subscriptions/:id/rotate-secret", agentCtrl.RotateSubscriptionSecret)
+				agent.POST("/subscriptions/:id/ping", agentCtrl.PingSubscription)
+				agent.GET("/subscriptions/:id/deliveries", agentCtrl.ListSubscriptionDeliveries)
+				agent.POST("/subscriptions/:id/deliveries/:delivery_id/redeliver", agentCtrl.RedeliverSubscriptionDelivery)
+				agent.GET("/push-schemas", agentCtrl.ListPushSchemas)
 				agent.GET("/sessions", agentCtrl.ListSessions)
 				agent.GET("/sessions/:id", agentCtrl.GetSession)
 				agent.GET("/artifacts/:id", agentCtrl.GetArtifact)
+				agent.GET("/artifacts/:id/export", agentCtrl.ExportArtifact)
+				agent.POST("/feedback", agentCtrl.SubmitFeedback)
+				agent.GET("/feedback/reviews", agentCtrl.ListFeedbackReviews)
+				agent.PUT("/feedback/:id/review", agentCtrl.ReviewFeedback)
+				agent.GET("/feedback/analytics", agentCtrl.FeedbackAnalytics)
 
 				// Tool Discovery (P2)
 				agent.GET("/tools", agentCtrl.ListTools)
 				agent.POST("/tools/call", agentCtrl.CallTool)
+
+				// Scheduled Briefings (database mode only)
+				agent.GET("/briefings", v1.ListBriefings)
+				agent.POST("/briefings", v1.CreateBriefing)
+				agent.PUT("/briefings/:id", v1.UpdateBriefing)
+				agent.DELETE("/briefings/:id", v1.DeleteBriefing)
+				agent.POST("/briefings/:id/run", v1.RunBriefing)
 			}
 		}
 	}
@@ -12,15 +12,18 @@ import (
 )
 
 type Config struct {
-	Server   ServerConfig
-	Storage  StorageConfig
-	Database DatabaseConfig
-	Redis    RedisConfig
-	JWT      JWTConfig
-	Log      LogConfig
-	Upload   UploadConfig
-	App      AppConfig
-	LLM      LLMConfig
+	Server      ServerConfig
+	Storage     StorageConfig
+	Database    DatabaseConfig
+	Redis       RedisConfig
+	JWT         JWTConfig
+	Log         LogConfig
+	Upload      UploadConfig
+	App         AppConfig
+	LLM         LLMConfig
+	Notify      NotifyConfig
+	Scheduler   SchedulerConfig
+	Maintenance MaintenanceConfig
 }
 
 type ServerConfig struct {
@@ -99,6 +102,102 @@ type LLMConfig struct {
 	BaseURL  string
 	APIKey   string
 	Model    string
+
+	Redaction RedactionConfig
+}
+
+// RedactionConfig 控制发送给 LLM 前的敏感信息脱敏
+type RedactionConfig struct {
+	Enabled bool
+	// Financial 为 true 时对所有工厂脱敏财务字段
+	Financial bool
+	// FinancialFactoryIDs 仅对列出的工厂脱敏财务字段
+	FinancialFactoryIDs []uint `mapstructure:"financial_factory_ids"`
+}
+
+// FinancialFor 判断指定工厂是否需要脱敏财务字段
+func (r RedactionConfig) FinancialFor(factoryID *uint) bool {
+	if r.Financial {
+		return true
+	}
+	if factoryID == nil {
+		return false
+	}
+	for _, id := range r.FinancialFactoryIDs {
+		if id == *factoryID {
+			return true
+		}
+	}
+	return false
+}
+
+// SchedulerConfig 内置定时任务调度（仅数据库模式）；多副本部署时通过分布式锁保证同一触发只执行一次
+type SchedulerConfig struct {
+	Disabled bool                   // 为 true 时本实例不执行定时任务，仍可通过接口查看与手动触发
+	Lock     string                 // postgres（默认，advisory lock）或 redis
+	Jobs     map[string]JobOverride // 按任务名覆盖 cron 表达式与错过策略
+}
+
+// JobOverride 单个任务的配置覆盖，为空时使用内置值
+type JobOverride struct {
+	Cron    string
+	Misfire string // run_once 或 skip
+}
+
+// MaintenanceConfig 保养任务滚动生成
+type MaintenanceConfig struct {
+	HorizonDays int `mapstructure:"horizon_days"` // 提前生成未来多少天内到期的任务，默认 30
+}
+
+func (m MaintenanceConfig) Horizon() int {
+	if m.HorizonDays <= 0 {
+		return 30
+	}
+	return m.HorizonDays
+}
+
+// NotifyConfig 通知渠道配置；企业微信、钉钉机器人地址由用户在通知偏好中填写
+type NotifyConfig struct {
+	SMTP  SMTPConfig
+	Alert AlertConfig
+}
+
+type SMTPConfig struct {
+	Host        string
+	Port        int
+	Username    string
+	Password    string
+	From        string // 发件人，如 "EMS <ems@example.com>"
+	ImplicitTLS bool   `mapstructure:"implicit_tls"` // 465 端口直接 TLS；否则服务器支持时使用 STARTTLS
+}
+
+// AlertConfig 告警聚合、摘要与自动解决的时间参数，为 0 时使用默认值
+type AlertConfig struct {
+	SuppressMinutes int `mapstructure:"suppress_minutes"` // 同一告警重复通知的最小间隔，默认 30
+	DigestMinutes   int `mapstructure:"digest_minutes"`   // 低级别告警摘要的发送周期，默认 60
+	ResolveHours    int `mapstructure:"resolve_hours"`    // 告警持续无新事件多久后自动解决，默认 24
+}
+
+func (a AlertConfig) SuppressWindow() time.Duration {
+	return minutesOr(a.SuppressMinutes, 30)
+}
+
+func (a AlertConfig) DigestInterval() time.Duration {
+	return minutesOr(a.DigestMinutes, 60)
+}
+
+func (a AlertConfig) ResolveTimeout() time.Duration {
+	if a.ResolveHours <= 0 {
+		return 24 * time.Hour
+	}
+	return time.Duration(a.ResolveHours) * time.Hour
+}
+
+func minutesOr(v, def int) time.Duration {
+	if v <= 0 {
+		v = def
+	}
+	return time.Duration(v) * time.Minute
 }
 
 var Cfg *Config
@@ -231,10 +330,24 @@ func applyEnvOverrides(cfg *Config) error {
 		return err
 	}
 
+	overrideString(&cfg.Notify.SMTP.Host, "EMS_SMTP_HOST", "SMTP_HOST")
+	overrideString(&cfg.Notify.SMTP.Username, "EMS_SMTP_USERNAME", "SMTP_USERNAME")
+	overrideString(&cfg.Notify.SMTP.Password, "EMS_SMTP_PASSWORD", "SMTP_PASSWORD")
+	overrideString(&cfg.Notify.SMTP.From, "EMS_SMTP_FROM", "SMTP_FROM")
+	if err := overrideInt(&cfg.Notify.SMTP.Port, "EMS_SMTP_PORT", "SMTP_PORT"); err != nil {
+		return err
+	}
+
 	overrideString(&cfg.LLM.Provider, "EMS_LLM_PROVIDER", "LLM_PROVIDER")
 	overrideString(&cfg.LLM.BaseURL, "EMS_LLM_BASE_URL", "LLM_BASE_URL")
 	overrideString(&cfg.LLM.APIKey, "EMS_LLM_API_KEY", "LLM_API_KEY")
 	overrideString(&cfg.LLM.Model, "EMS_LLM_MODEL", "LLM_MODEL")
+	if err := overrideBool(&cfg.LLM.Redaction.Enabled, "EMS_LLM_REDACTION_ENABLED"); err != nil {
+		return err
+	}
+	if err := overrideBool(&cfg.LLM.Redaction.Financial, "EMS_LLM_REDACTION_FINANCIAL"); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -0,0 +1,169 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule 表示一个已解析的 5 段 cron 表达式（分 时 日 月 周）
+type Schedule struct {
+	expr   string
+	minute uint64
+	hour   uint64
+	dom    uint64
+	month  uint64
+	dow    uint64
+	// 日/周任一为 "*" 时按标准 cron 语义取交集，否则取并集
+	domStar bool
+	dowStar bool
+}
+
+var descriptors = map[string]string{
+	"@yearly":  "0 0 1 1 *",
+	"@monthly": "0 0 1 * *",
+	"@weekly":  "0 0 * * 1",
+	"@daily":   "0 0 * * *",
+	"@hourly":  "0 * * * *",
+}
+
+type fieldBounds struct {
+	name     string
+	min, max int
+}
+
+var bounds = []fieldBounds{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day-of-month", 1, 31},
+	{"month", 1, 12},
+	{"day-of-week", 0, 7},
+}
+
+// Parse 解析 cron 表达式，支持 *、列表(1,2)、范围(1-5)、步长(*/15) 以及 @daily/@weekly 等描述符
+func Parse(expr string) (*Schedule, error) {
+	spec := strings.TrimSpace(expr)
+	if d, ok := descriptors[spec]; ok {
+		spec = d
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression %q: expected 5 fields, got %d", expr, len(fields))
+	}
+
+	var masks [5]uint64
+	for i, f := range fields {
+		m, err := parseField(f, bounds[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+		}
+		masks[i] = m
+	}
+	// 周日既可写作 0 也可写作 7
+	if masks[4]&(1<<7) != 0 {
+		masks[4] |= 1
+	}
+
+	return &Schedule{
+		expr:    expr,
+		minute:  masks[0],
+		hour:    masks[1],
+		dom:     masks[2],
+		month:   masks[3],
+		dow:     masks[4],
+		domStar: strings.HasPrefix(fields[2], "*"),
+		dowStar: strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+// Validate 仅校验表达式是否合法
+func Validate(expr string) error {
+	_, err := Parse(expr)
+	return err
+}
+
+func parseField(field string, b fieldBounds) (uint64, error) {
+	var mask uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if idx := strings.Index(part, "/"); idx >= 0 {
+			s, err := strconv.Atoi(part[idx+1:])
+			if err != nil || s <= 0 {
+				return 0, fmt.Errorf("%s: invalid step %q", b.name, part)
+			}
+			step = s
+			part = part[:idx]
+		}
+
+		lo, hi := b.min, b.max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			rng := strings.SplitN(part, "-", 2)
+			var err1, err2 error
+			lo, err1 = strconv.Atoi(rng[0])
+			hi, err2 = strconv.Atoi(rng[1])
+			if err1 != nil || err2 != nil {
+				return 0, fmt.Errorf("%s: invalid range %q", b.name, part)
+			}
+		default:
+			v, err := strconv.Atoi(part)
+			if err != nil {
+				return 0, fmt.Errorf("%s: invalid value %q", b.name, part)
+			}
+			lo = v
+			if step == 1 {
+				hi = v
+			}
+		}
+
+		if lo < b.min || hi > b.max || lo > hi {
+			return 0, fmt.Errorf("%s: value out of range [%d-%d] in %q", b.name, b.min, b.max, field)
+		}
+		for v := lo; v <= hi; v += step {
+			mask |= 1 << uint(v)
+		}
+	}
+	return mask, nil
+}
+
+// String 返回原始表达式
+func (s *Schedule) String() string { return s.expr }
+
+// Next 返回严格晚于 t 的下一个触发时间（分钟精度，使用 t 所在时区）
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	// 最多向后搜索 5 年，防止不可能的表达式（如 2 月 31 日）导致死循环
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	domOK := s.dom&(1<<uint(t.Day())) != 0
+	dowOK := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domOK && dowOK
+	}
+	return domOK || dowOK
+}
@@ -0,0 +1,61 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse_Invalid(t *testing.T) {
+	cases := []string{"", "* * * *", "60 * * * *", "* 24 * * *", "*/0 * * * *", "a * * * *", "5-1 * * * *"}
+	for _, c := range cases {
+		if _, err := Parse(c); err == nil {
+			t.Errorf("Expected error for %q", c)
+		}
+	}
+}
+
+func TestNext_Daily(t *testing.T) {
+	s, err := Parse("0 8 * * *")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	from := time.Date(2026, 5, 10, 9, 30, 0, 0, time.UTC)
+	want := time.Date(2026, 5, 11, 8, 0, 0, 0, time.UTC)
+	if got := s.Next(from); !got.Equal(want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestNext_WeeklyMonday(t *testing.T) {
+	s, _ := Parse("30 7 * * 1")
+	// 2026-05-10 is a Sunday
+	from := time.Date(2026, 5, 10, 12, 0, 0, 0, time.UTC)
+	want := time.Date(2026, 5, 11, 7, 30, 0, 0, time.UTC)
+	if got := s.Next(from); !got.Equal(want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestNext_StepAndDescriptor(t *testing.T) {
+	s, _ := Parse("*/15 * * * *")
+	from := time.Date(2026, 5, 10, 9, 16, 0, 0, time.UTC)
+	if got := s.Next(from); got.Minute() != 30 {
+		t.Errorf("Expected minute 30, got %v", got)
+	}
+
+	d, err := Parse("@daily")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := d.Next(from); got.Hour() != 0 || got.Day() != 11 {
+		t.Errorf("Expected midnight next day, got %v", got)
+	}
+}
+
+func TestNext_SundayAsSeven(t *testing.T) {
+	s, _ := Parse("0 9 * * 7")
+	from := time.Date(2026, 5, 11, 0, 0, 0, 0, time.UTC)
+	if got := s.Next(from); got.Weekday() != time.Sunday {
+		t.Errorf("Expected Sunday, got %v", got.Weekday())
+	}
+}
@@ -0,0 +1,262 @@
+// Package jsonschema validates decoded JSON values against the JSON Schema subset
+// used for agent tool definitions and structured LLM output.
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Issue is a single schema violation, addressed by a dotted path such as "items[0].id"
+type Issue struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (i Issue) String() string {
+	if i.Path == "" {
+		return i.Message
+	}
+	return i.Path + ": " + i.Message
+}
+
+// Normalize 通过 JSON 往返将手写 schema（如 []string 类型的 required）统一为通用结构
+func Normalize(schema interface{}) map[string]interface{} {
+	if schema == nil {
+		return nil
+	}
+	if m, ok := schema.(map[string]interface{}); ok && len(m) == 0 {
+		return nil
+	}
+	raw, err := json.Marshal(schema)
+	if err != nil {
+		return nil
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil
+	}
+	return out
+}
+
+// Validate checks value against a JSON Schema subset: type, properties, required,
+// additionalProperties, items, enum, minimum/maximum, minLength/maxLength and
+// minItems/maxItems. Go numeric types are accepted alongside JSON float64.
+func Validate(schema interface{}, value interface{}) []Issue {
+	return Check(Normalize(schema), value)
+}
+
+// Check validates value against a schema already produced by Normalize, for
+// callers that validate many values against the same schema.
+func Check(schema map[string]interface{}, value interface{}) []Issue {
+	if schema == nil {
+		return nil
+	}
+	var issues []Issue
+	validateValue(schema, value, "", &issues)
+	return issues
+}
+
+func validateValue(schema map[string]interface{}, value interface{}, path string, issues *[]Issue) {
+	add := func(format string, a ...interface{}) {
+		*issues = append(*issues, Issue{Path: path, Message: fmt.Sprintf(format, a...)})
+	}
+
+	if typ, ok := schema["type"].(string); ok && !matchesType(typ, value) {
+		add("expected %s, got %s", typ, describe(value))
+		return
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok && !inEnum(enum, value) {
+		opts := make([]string, len(enum))
+		for i, e := range enum {
+			b, _ := json.Marshal(e)
+			opts[i] = string(b)
+		}
+		add("must be one of %s, got %s", strings.Join(opts, ", "), describe(value))
+	}
+
+	if n, ok := toFloat(value); ok {
+		if min, ok := schema["minimum"].(float64); ok && n < min {
+			add("must be >= %v, got %v", min, n)
+		}
+		if max, ok := schema["maximum"].(float64); ok && n > max {
+			add("must be <= %v, got %v", max, n)
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		length := float64(len([]rune(v)))
+		if min, ok := schema["minLength"].(float64); ok && length < min {
+			add("must be at least %v characters", min)
+		}
+		if max, ok := schema["maxLength"].(float64); ok && length > max {
+			add("must be at most %v characters", max)
+		}
+	case map[string]interface{}:
+		validateObject(schema, v, path, issues)
+	default:
+		rv := reflect.ValueOf(value)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			return
+		}
+		length := float64(rv.Len())
+		if min, ok := schema["minItems"].(float64); ok && length < min {
+			add("must contain at least %v items", min)
+		}
+		if max, ok := schema["maxItems"].(float64); ok && length > max {
+			add("must contain at most %v items", max)
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i := 0; i < rv.Len(); i++ {
+				validateValue(items, rv.Index(i).Interface(), fmt.Sprintf("%s[%d]", path, i), issues)
+			}
+		}
+	}
+}
+
+func validateObject(schema map[string]interface{}, obj map[string]interface{}, path string, issues *[]Issue) {
+	props, _ := schema["properties"].(map[string]interface{})
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, r := range required {
+			name, _ := r.(string)
+			if v, present := obj[name]; !present || v == nil {
+				*issues = append(*issues, Issue{Path: joinPath(path, name), Message: "is required"})
+			}
+		}
+	}
+
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		value := obj[name]
+		if prop, ok := props[name].(map[string]interface{}); ok {
+			// 未列为 required 的字段允许为 null，等同于未提供
+			if value != nil {
+				validateValue(prop, value, joinPath(path, name), issues)
+			}
+			continue
+		}
+		switch extra := schema["additionalProperties"].(type) {
+		case bool:
+			if !extra {
+				allowed := make([]string, 0, len(props))
+				for p := range props {
+					allowed = append(allowed, p)
+				}
+				sort.Strings(allowed)
+				*issues = append(*issues, Issue{Path: joinPath(path, name), Message: "is not an allowed property; allowed: " + strings.Join(allowed, ", ")})
+			}
+		case map[string]interface{}:
+			if value != nil {
+				validateValue(extra, value, joinPath(path, name), issues)
+			}
+		}
+	}
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func matchesType(typ string, value interface{}) bool {
+	switch typ {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		if value == nil {
+			return false
+		}
+		k := reflect.ValueOf(value).Kind()
+		return k == reflect.Slice || k == reflect.Array
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "number":
+		_, ok := toFloat(value)
+		return ok
+	case "integer":
+		n, ok := toFloat(value)
+		return ok && n == math.Trunc(n)
+	case "null":
+		return value == nil
+	}
+	return true
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case nil, bool, string:
+		return 0, false
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+func inEnum(enum []interface{}, value interface{}) bool {
+	n, isNum := toFloat(value)
+	for _, e := range enum {
+		if en, ok := toFloat(e); ok && isNum && en == n {
+			return true
+		}
+		if reflect.DeepEqual(e, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// describe 用于错误信息中展示实际收到的值
+func describe(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		if len([]rune(v)) > 40 {
+			v = string([]rune(v)[:40]) + "..."
+		}
+		return fmt.Sprintf("string %q", v)
+	case bool:
+		return fmt.Sprintf("boolean %v", v)
+	case map[string]interface{}:
+		return "object"
+	}
+	if n, ok := toFloat(value); ok {
+		if n == math.Trunc(n) {
+			return "integer " + strconv.FormatFloat(n, 'f', -1, 64)
+		}
+		return "number " + strconv.FormatFloat(n, 'f', -1, 64)
+	}
+	if k := reflect.ValueOf(value).Kind(); k == reflect.Slice || k == reflect.Array {
+		return "array"
+	}
+	return fmt.Sprintf("%T", value)
+}
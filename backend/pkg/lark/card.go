@@ -0,0 +1,104 @@
+package lark
+
+import "encoding/json"
+
+// Card 交互式消息卡片（卡片 JSON 1.0 结构）
+type Card struct {
+	Config   CardConfig    `json:"config"`
+	Header   CardHeader    `json:"header"`
+	Elements []interface{} `json:"elements"`
+}
+
+type CardConfig struct {
+	WideScreenMode bool `json:"wide_screen_mode"`
+	UpdateMulti    bool `json:"update_multi"` // 允许通过回调响应原地更新
+}
+
+type CardHeader struct {
+	Title    CardText `json:"title"`
+	Template string   `json:"template,omitempty"` // 标题颜色：red, orange, yellow, blue, green...
+}
+
+type CardText struct {
+	Tag     string `json:"tag"` // plain_text, lark_md
+	Content string `json:"content"`
+}
+
+type Button struct {
+	Tag   string            `json:"tag"`
+	Text  CardText          `json:"text"`
+	Type  string            `json:"type,omitempty"` // default, primary, danger
+	URL   string            `json:"url,omitempty"`
+	Value map[string]string `json:"value,omitempty"` // 回调时原样带回
+}
+
+func NewCard(title, template string) *Card {
+	return &Card{
+		Config: CardConfig{WideScreenMode: true, UpdateMulti: true},
+		Header: CardHeader{Title: CardText{Tag: "plain_text", Content: title}, Template: template},
+	}
+}
+
+// Markdown 追加一段 lark_md 文本
+func (c *Card) Markdown(content string) *Card {
+	c.Elements = append(c.Elements, map[string]interface{}{
+		"tag": "div", "text": CardText{Tag: "lark_md", Content: content},
+	})
+	return c
+}
+
+// Actions 追加一行按钮，无按钮时忽略
+func (c *Card) Actions(buttons ...Button) *Card {
+	if len(buttons) > 0 {
+		c.Elements = append(c.Elements, map[string]interface{}{"tag": "action", "actions": buttons})
+	}
+	return c
+}
+
+// Note 追加底部备注
+func (c *Card) Note(content string) *Card {
+	c.Elements = append(c.Elements, map[string]interface{}{
+		"tag": "note", "elements": []CardText{{Tag: "plain_text", Content: content}},
+	})
+	return c
+}
+
+func (c *Card) JSON() string {
+	b, _ := json.Marshal(c)
+	return string(b)
+}
+
+// CallbackButton 点击后触发 card.action.trigger 回调，value 随回调返回
+func CallbackButton(text, style string, value map[string]string) Button {
+	return Button{Tag: "button", Text: CardText{Tag: "plain_text", Content: text}, Type: style, Value: value}
+}
+
+// LinkButton 点击后打开链接
+func LinkButton(text, url string) Button {
+	return Button{Tag: "button", Text: CardText{Tag: "plain_text", Content: text}, Type: "default", URL: url}
+}
+
+// CardActionResponse 卡片回调的同步响应：展示 toast，并以 card 原地替换原卡片
+type CardActionResponse struct {
+	Toast *Toast      `json:"toast,omitempty"`
+	Card  *CardUpdate `json:"card,omitempty"`
+}
+
+type Toast struct {
+	Type    string `json:"type"` // success, info, warning, error
+	Content string `json:"content"`
+}
+
+type CardUpdate struct {
+	Type string `json:"type"` // raw
+	Data *Card  `json:"data"`
+}
+
+// ActionResponse 构造回调响应；card 为 nil 时仅提示，不更新卡片
+func ActionResponse(toastType, message string, card *Card) CardActionResponse {
+	resp := CardActionResponse{Toast: &Toast{Type: toastType, Content: message}}
+	if card != nil {
+		resp.Card = &CardUpdate{Type: "raw", Data: card}
+	}
+	return resp
+}
@@ -98,14 +98,20 @@ func (c *Client) SendTextMessage(ctx context.Context, receiveIDType, receiveID,
 	return c.SendMessage(ctx, receiveIDType, receiveID, "text", string(content))
 }
 
-func (c *Client) SendCardMessage(ctx context.Context, receiveIDType, receiveID, cardJSON string) error {
-	return c.SendMessage(ctx, receiveIDType, receiveID, "interactive", cardJSON)
+// SendCardMessage 发送交互式卡片，返回 message_id 供卡片回调时关联
+func (c *Client) SendCardMessage(ctx context.Context, receiveIDType, receiveID, cardJSON string) (string, error) {
+	return c.send(ctx, receiveIDType, receiveID, "interactive", cardJSON)
 }
 
 func (c *Client) SendMessage(ctx context.Context, receiveIDType, receiveID, msgType, content string) error {
+	_, err := c.send(ctx, receiveIDType, receiveID, msgType, content)
+	return err
+}
+
+func (c *Client) send(ctx context.Context, receiveIDType, receiveID, msgType, content string) (string, error) {
 	token, err := c.GetTenantAccessToken(ctx)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	url := fmt.Sprintf("%s?receive_id_type=%s", sendMessageURL, receiveIDType)
@@ -118,7 +124,7 @@ func (c *Client) SendMessage(ctx context.Context, receiveIDType, receiveID, msgT
 
 	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(reqBody))
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	req.Header.Set("Authorization", "Bearer "+token)
@@ -126,21 +132,24 @@ func (c *Client) SendMessage(ctx context.Context, receiveIDType, receiveID, msgT
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer resp.Body.Close()
 
 	var result struct {
 		Code int    `json:"code"`
 		Msg  string `json:"msg"`
+		Data struct {
+			MessageID string `json:"message_id"`
+		} `json:"data"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return err
+		return "", err
 	}
 
 	if result.Code != 0 {
-		return fmt.Errorf("lark send message error: %s (code: %d)", result.Msg, result.Code)
+		return "", fmt.Errorf("lark send message error: %s (code: %d)", result.Msg, result.Code)
 	}
 
-	return nil
+	return result.Data.MessageID, nil
 }
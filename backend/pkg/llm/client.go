@@ -56,9 +56,21 @@ func NewOpenAIClient(baseURL, apiKey, model string) *OpenAIClient {
 }
 
 type chatRequest struct {
-	Model    string    `json:"model"`
-	Messages []Message `json:"messages"`
-	Tools    []Tool    `json:"tools,omitempty"`
+	Model          string          `json:"model"`
+	Messages       []Message       `json:"messages"`
+	Tools          []Tool          `json:"tools,omitempty"`
+	ToolChoice     interface{}     `json:"tool_choice,omitempty"`
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+}
+
+// APIError is a non-200 response from the chat completions endpoint
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("LLM API error (status %d): %s", e.StatusCode, e.Message)
 }
 
 type chatResponse struct {
@@ -76,10 +88,17 @@ func (c *OpenAIClient) ChatCompletion(messages []Message) (string, error) {
 }
 
 func (c *OpenAIClient) ChatWithTools(messages []Message, tools []Tool) (*Message, error) {
+	return c.ChatWithOptions(messages, ChatOptions{Tools: tools})
+}
+
+// ChatWithOptions sends a chat request with response_format / tool_choice set
+func (c *OpenAIClient) ChatWithOptions(messages []Message, opts ChatOptions) (*Message, error) {
 	reqBody := chatRequest{
-		Model:    c.Model,
-		Messages: messages,
-		Tools:    tools,
+		Model:          c.Model,
+		Messages:       messages,
+		Tools:          opts.Tools,
+		ToolChoice:     opts.ToolChoice,
+		ResponseFormat: opts.ResponseFormat,
 	}
 	
 	jsonData, err := json.Marshal(reqBody)
@@ -109,7 +128,7 @@ func (c *OpenAIClient) ChatWithTools(messages []Message, tools []Tool) (*Message
 			} `json:"error"`
 		}
 		json.NewDecoder(resp.Body).Decode(&errResp)
-		return nil, fmt.Errorf("LLM API error (status %d): %s", resp.StatusCode, errResp.Error.Message)
+		return nil, &APIError{StatusCode: resp.StatusCode, Message: errResp.Error.Message}
 	}
 
 	var result chatResponse
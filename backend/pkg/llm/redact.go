@@ -0,0 +1,333 @@
+package llm
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// 脱敏类别
+const (
+	RedactKindPhone     = "PHONE"
+	RedactKindSecret    = "SECRET"
+	RedactKindName      = "NAME"
+	RedactKindFinancial = "AMOUNT"
+)
+
+// redactRule 描述一条基于正则的脱敏规则，group 指定需要替换的子匹配（0 表示整体）
+type redactRule struct {
+	kind  string
+	re    *regexp.Regexp
+	group int
+}
+
+var secretRules = []redactRule{
+	{kind: RedactKindSecret, re: regexp.MustCompile(`\bems_[0-9a-fA-F]{16,}\b`)},
+	{kind: RedactKindSecret, re: regexp.MustCompile(`\bsk-[A-Za-z0-9_\-]{16,}`)},
+	{kind: RedactKindSecret, re: regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9._\-]{16,})`), group: 1},
+	{kind: RedactKindSecret, re: regexp.MustCompile(`(?i)\b(api[_-]?key|app[_-]?secret|secret|token|password|passwd)("?\s*[:=]\s*"?)([^\s",}\]]{6,})`), group: 3},
+}
+
+var phoneRules = []redactRule{
+	{kind: RedactKindPhone, re: regexp.MustCompile(`\b1[3-9]\d{9}\b`)},
+	{kind: RedactKindPhone, re: regexp.MustCompile(`\b0\d{2,3}-\d{7,8}\b`)},
+}
+
+var financialRules = []redactRule{
+	{kind: RedactKindFinancial, re: regexp.MustCompile(`"?\b(purchase_price|scrap_value|hourly_loss|total_cost|spare_part_cost|labor_cost|other_cost|downtime_loss|depreciated_value|accumulated_repair_cost|total_cost_of_ownership)"?(\s*[:=]\s*)(-?\d+(?:\.\d+)?(?:[eE][+-]?\d+)?)`), group: 3},
+	{kind: RedactKindFinancial, re: regexp.MustCompile(`[¥￥]\s?\d[\d,]*(?:\.\d+)?`)},
+	{kind: RedactKindFinancial, re: regexp.MustCompile(`\d[\d,]*(?:\.\d+)?\s?万?元`)},
+}
+
+// placeholderPattern 匹配 Vault 生成的占位符，用于避免二次替换
+var placeholderPattern = regexp.MustCompile(`\[\[[A-Z]+_\d+\]\]`)
+
+// Vault 保存一次 LLM 调用内原文与占位符之间的映射，用于回填模型回复
+type Vault struct {
+	byOriginal map[string]string
+	byToken    map[string]string
+	counters   map[string]int
+}
+
+func NewVault() *Vault {
+	return &Vault{
+		byOriginal: make(map[string]string),
+		byToken:    make(map[string]string),
+		counters:   make(map[string]int),
+	}
+}
+
+func (v *Vault) tokenFor(kind, original string) string {
+	key := kind + "\x00" + original
+	if token, ok := v.byOriginal[key]; ok {
+		return token
+	}
+	v.counters[kind]++
+	token := fmt.Sprintf("[[%s_%d]]", kind, v.counters[kind])
+	v.byOriginal[key] = token
+	v.byToken[token] = original
+	return token
+}
+
+// Restore 将文本中的占位符还原为原文
+func (v *Vault) Restore(text string) string {
+	if len(v.byToken) == 0 || !strings.Contains(text, "[[") {
+		return text
+	}
+	return placeholderPattern.ReplaceAllStringFunc(text, func(token string) string {
+		if original, ok := v.byToken[token]; ok {
+			return original
+		}
+		return token
+	})
+}
+
+// Counts 返回各类别被脱敏的不同原文数量
+func (v *Vault) Counts() map[string]int {
+	counts := make(map[string]int, len(v.counters))
+	for k, n := range v.counters {
+		counts[k] = n
+	}
+	return counts
+}
+
+// Redactor 负责在文本发送给 LLM 前替换手机号、人员姓名、密钥及（可选）财务数据
+type Redactor struct {
+	nameLoader func() []string
+	nameTTL    time.Duration
+
+	mu       sync.RWMutex
+	namesRe  *regexp.Regexp
+	loadedAt time.Time
+}
+
+// NewRedactor 创建脱敏器。nameLoader 用于加载需要保护的人员姓名（如 User 表），可为 nil；
+// 姓名列表按 ttl 周期刷新。
+func NewRedactor(nameLoader func() []string, ttl time.Duration) *Redactor {
+	if ttl <= 0 {
+		ttl = 10 * time.Minute
+	}
+	return &Redactor{nameLoader: nameLoader, nameTTL: ttl}
+}
+
+// SetNames 直接设置需要保护的人员姓名
+func (r *Redactor) SetNames(names []string) {
+	uniq := make(map[string]bool)
+	var list []string
+	for _, n := range names {
+		n = strings.TrimSpace(n)
+		// 单字姓名误伤率过高，跳过
+		if utf8.RuneCountInString(n) < 2 || uniq[n] {
+			continue
+		}
+		uniq[n] = true
+		list = append(list, n)
+	}
+	// 长名优先，避免“张三丰”被“张三”截断
+	sort.Slice(list, func(i, j int) bool { return len(list[i]) > len(list[j]) })
+
+	var re *regexp.Regexp
+	if len(list) > 0 {
+		quoted := make([]string, len(list))
+		for i, n := range list {
+			quoted[i] = regexp.QuoteMeta(n)
+		}
+		re = regexp.MustCompile(strings.Join(quoted, "|"))
+	}
+
+	r.mu.Lock()
+	r.namesRe = re
+	r.loadedAt = time.Now()
+	r.mu.Unlock()
+}
+
+func (r *Redactor) namePattern() *regexp.Regexp {
+	r.mu.RLock()
+	re, loadedAt := r.namesRe, r.loadedAt
+	r.mu.RUnlock()
+
+	if r.nameLoader != nil && time.Since(loadedAt) > r.nameTTL {
+		r.SetNames(r.nameLoader())
+		r.mu.RLock()
+		re = r.namesRe
+		r.mu.RUnlock()
+	}
+	return re
+}
+
+// Redact 对文本脱敏，映射写入 vault。financial 为 true 时同时脱敏金额类字段。
+func (r *Redactor) Redact(text string, vault *Vault, financial bool) string {
+	if text == "" {
+		return text
+	}
+	text = applyRules(text, secretRules, vault)
+	text = applyRules(text, phoneRules, vault)
+	if financial {
+		text = applyRules(text, financialRules, vault)
+	}
+	if re := r.namePattern(); re != nil {
+		text = replaceOutsidePlaceholders(text, re, func(m string) string {
+			return vault.tokenFor(RedactKindName, m)
+		})
+	}
+	return text
+}
+
+func applyRules(text string, rules []redactRule, vault *Vault) string {
+	for _, rule := range rules {
+		text = replaceGroup(text, rule, vault)
+	}
+	return text
+}
+
+func replaceGroup(text string, rule redactRule, vault *Vault) string {
+	matches := rule.re.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text
+	}
+	spans := placeholderPattern.FindAllStringIndex(text, -1)
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[2*rule.group], m[2*rule.group+1]
+		if start < 0 || overlapsPlaceholder(spans, start, end) {
+			continue
+		}
+		b.WriteString(text[last:start])
+		b.WriteString(vault.tokenFor(rule.kind, text[start:end]))
+		last = end
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+func replaceOutsidePlaceholders(text string, re *regexp.Regexp, repl func(string) string) string {
+	matches := re.FindAllStringIndex(text, -1)
+	if len(matches) == 0 {
+		return text
+	}
+	spans := placeholderPattern.FindAllStringIndex(text, -1)
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		if overlapsPlaceholder(spans, m[0], m[1]) {
+			continue
+		}
+		b.WriteString(text[last:m[0]])
+		b.WriteString(repl(text[m[0]:m[1]]))
+		last = m[1]
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// overlapsPlaceholder 判断 [start, end) 是否与已有的 [[KIND_n]] 占位符重叠，避免二次替换
+func overlapsPlaceholder(spans [][]int, start, end int) bool {
+	for _, sp := range spans {
+		if start < sp[1] && end > sp[0] {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactingClient 在请求前脱敏、在响应后回填，对调用方透明
+type RedactingClient struct {
+	inner     LLMClient
+	redactor  *Redactor
+	financial bool
+}
+
+func NewRedactingClient(inner LLMClient, redactor *Redactor) *RedactingClient {
+	return &RedactingClient{inner: inner, redactor: redactor}
+}
+
+// WithFinancial 返回一个按工厂策略决定是否脱敏财务字段的副本
+func (c *RedactingClient) WithFinancial(enabled bool) *RedactingClient {
+	cp := *c
+	cp.financial = enabled
+	return &cp
+}
+
+func (c *RedactingClient) ChatCompletion(messages []Message) (string, error) {
+	vault := NewVault()
+	resp, err := c.inner.ChatCompletion(c.redactMessages(messages, vault))
+	c.logDecision("ChatCompletion", vault)
+	if err != nil {
+		return "", err
+	}
+	return vault.Restore(resp), nil
+}
+
+func (c *RedactingClient) ChatWithTools(messages []Message, tools []Tool) (*Message, error) {
+	vault := NewVault()
+	resp, err := c.inner.ChatWithTools(c.redactMessages(messages, vault), tools)
+	c.logDecision("ChatWithTools", vault)
+	return restoreMessage(resp, err, vault)
+}
+
+// ChatWithOptions 透传结构化输出参数；内层客户端不支持时退化为 ChatWithTools，由 Structured 的校验兜底
+func (c *RedactingClient) ChatWithOptions(messages []Message, opts ChatOptions) (*Message, error) {
+	sc, ok := c.inner.(StructuredClient)
+	if !ok {
+		return c.ChatWithTools(messages, opts.Tools)
+	}
+	vault := NewVault()
+	resp, err := sc.ChatWithOptions(c.redactMessages(messages, vault), opts)
+	c.logDecision("ChatWithOptions", vault)
+	return restoreMessage(resp, err, vault)
+}
+
+func restoreMessage(resp *Message, err error, vault *Vault) (*Message, error) {
+	if err != nil || resp == nil {
+		return resp, err
+	}
+	restored := *resp
+	restored.Content = vault.Restore(resp.Content)
+	if len(resp.ToolCalls) > 0 {
+		restored.ToolCalls = make([]ToolCall, len(resp.ToolCalls))
+		for i, tc := range resp.ToolCalls {
+			tc.Function.Arguments = vault.Restore(tc.Function.Arguments)
+			restored.ToolCalls[i] = tc
+		}
+	}
+	return &restored, nil
+}
+
+func (c *RedactingClient) redactMessages(messages []Message, vault *Vault) []Message {
+	out := make([]Message, len(messages))
+	for i, m := range messages {
+		m.Content = c.redactor.Redact(m.Content, vault, c.financial)
+		if len(m.ToolCalls) > 0 {
+			calls := make([]ToolCall, len(m.ToolCalls))
+			for j, tc := range m.ToolCalls {
+				tc.Function.Arguments = c.redactor.Redact(tc.Function.Arguments, vault, c.financial)
+				calls[j] = tc
+			}
+			m.ToolCalls = calls
+		}
+		out[i] = m
+	}
+	return out
+}
+
+// logDecision 只记录各类别数量，绝不输出原文
+func (c *RedactingClient) logDecision(op string, vault *Vault) {
+	counts := vault.Counts()
+	if len(counts) == 0 {
+		return
+	}
+	kinds := make([]string, 0, len(counts))
+	total := 0
+	for k, n := range counts {
+		kinds = append(kinds, fmt.Sprintf("%s=%d", strings.ToLower(k), n))
+		total += n
+	}
+	sort.Strings(kinds)
+	log.Printf("[Redactor] %s: redacted %d item(s) (%s), financial=%v", op, total, strings.Join(kinds, " "), c.financial)
+}
@@ -0,0 +1,93 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+type captureClient struct {
+	received []Message
+	reply    string
+}
+
+func (c *captureClient) ChatCompletion(messages []Message) (string, error) {
+	c.received = messages
+	return c.reply, nil
+}
+
+func (c *captureClient) ChatWithTools(messages []Message, tools []Tool) (*Message, error) {
+	c.received = messages
+	return &Message{Role: "assistant", Content: c.reply}, nil
+}
+
+func TestRedactor_PhoneAndSecret(t *testing.T) {
+	r := NewRedactor(nil, time.Minute)
+	v := NewVault()
+
+	in := "联系 13800138000 或 021-12345678，api_key=ems_0123456789abcdef0123456789abcdef"
+	out := r.Redact(in, v, false)
+
+	for _, leaked := range []string{"13800138000", "021-12345678", "ems_0123456789abcdef"} {
+		if strings.Contains(out, leaked) {
+			t.Errorf("Expected %q to be redacted, got %s", leaked, out)
+		}
+	}
+	if !strings.Contains(out, "[[PHONE_1]]") || !strings.Contains(out, "[[SECRET_1]]") {
+		t.Errorf("Expected placeholders, got %s", out)
+	}
+	if v.Restore(out) != in {
+		t.Errorf("Expected restore to round-trip, got %s", v.Restore(out))
+	}
+}
+
+func TestRedactor_NamesLongestFirst(t *testing.T) {
+	r := NewRedactor(func() []string { return []string{"张三", "张三丰", "李"} }, time.Minute)
+	v := NewVault()
+
+	out := r.Redact("张三丰和张三都找过李工", v, false)
+	if strings.Contains(out, "张三") {
+		t.Errorf("Expected names to be redacted, got %s", out)
+	}
+	if !strings.Contains(out, "李工") {
+		t.Errorf("Single-rune names should be skipped, got %s", out)
+	}
+	if v.Counts()[RedactKindName] != 2 {
+		t.Errorf("Expected 2 distinct names, got %d", v.Counts()[RedactKindName])
+	}
+}
+
+func TestRedactor_FinancialOptional(t *testing.T) {
+	r := NewRedactor(nil, time.Minute)
+	in := `{"purchase_price": 50000, "name": "CNC-01"} 维修费 ¥1,200`
+
+	if out := r.Redact(in, NewVault(), false); out != in {
+		t.Errorf("Financial fields should pass through when disabled, got %s", out)
+	}
+
+	out := r.Redact(in, NewVault(), true)
+	if strings.Contains(out, "50000") || strings.Contains(out, "1,200") {
+		t.Errorf("Expected amounts to be redacted, got %s", out)
+	}
+	if !strings.Contains(out, `"purchase_price": [[AMOUNT_1]]`) {
+		t.Errorf("Expected key to be kept with placeholder value, got %s", out)
+	}
+}
+
+func TestRedactingClient_RoundTrip(t *testing.T) {
+	inner := &captureClient{reply: "建议联系 [[NAME_1]]（[[PHONE_1]]）"}
+	r := NewRedactor(func() []string { return []string{"王五"} }, time.Minute)
+	client := NewRedactingClient(inner, r)
+
+	reply, err := client.ChatCompletion([]Message{{Role: "user", Content: "王五 的电话是 13912345678"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	sent := inner.received[0].Content
+	if strings.Contains(sent, "王五") || strings.Contains(sent, "13912345678") {
+		t.Errorf("PII leaked to LLM: %s", sent)
+	}
+	if reply != "建议联系 王五（13912345678）" {
+		t.Errorf("Expected rehydrated reply, got %s", reply)
+	}
+}
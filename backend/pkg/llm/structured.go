@@ -0,0 +1,239 @@
+package llm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/ems/backend/pkg/jsonschema"
+)
+
+// StructuredClient is implemented by clients that can constrain the response
+// with response_format or a forced tool call. OpenAIClient and RedactingClient
+// implement it; plain LLMClient implementations fall back to prompt instructions.
+type StructuredClient interface {
+	ChatWithOptions(messages []Message, opts ChatOptions) (*Message, error)
+}
+
+// ChatOptions are the optional request fields beyond messages
+type ChatOptions struct {
+	Tools          []Tool
+	ToolChoice     interface{}
+	ResponseFormat *ResponseFormat
+}
+
+// ResponseFormat is the OpenAI response_format field
+type ResponseFormat struct {
+	Type       string      `json:"type"` // json_object 或 json_schema
+	JSONSchema *JSONSchema `json:"json_schema,omitempty"`
+}
+
+type JSONSchema struct {
+	Name   string      `json:"name"`
+	Schema interface{} `json:"schema"`
+	Strict bool        `json:"strict,omitempty"`
+}
+
+// StructuredMode selects how the schema is enforced on the provider side
+type StructuredMode string
+
+const (
+	ModeJSONSchema StructuredMode = "json_schema" // response_format: json_schema
+	ModeTool       StructuredMode = "tool"        // 强制调用一个以 schema 为参数的工具
+	ModePrompt     StructuredMode = "prompt"      // 仅在提示词中给出 schema
+)
+
+const defaultMaxRepairs = 2
+
+// StructuredSpec describes the expected output of a Structured call
+type StructuredSpec struct {
+	Name        string                 // schema / 工具名，仅限字母、数字、_ 和 -
+	Description string                 // 工具模式下的工具说明
+	Schema      map[string]interface{} // JSON Schema，可由 tool.SchemaOf 生成
+	Mode        StructuredMode         // 为空时：支持 StructuredClient 用 json_schema，否则 prompt
+	MaxRepairs  int                    // 校验失败后的修复重试次数，0 取默认值 2，负数不重试
+}
+
+// ErrStructuredOutput is matched by errors.Is when the output still violates
+// the schema after all repair attempts.
+var ErrStructuredOutput = errors.New("llm output does not match schema")
+
+// StructuredError carries the last invalid output and its schema violations
+type StructuredError struct {
+	Name     string
+	Attempts int
+	Issues   []string
+	Raw      string
+}
+
+func (e *StructuredError) Error() string {
+	return fmt.Sprintf("%s: %q after %d attempt(s): %s", ErrStructuredOutput, e.Name, e.Attempts, strings.Join(e.Issues, "; "))
+}
+
+func (e *StructuredError) Unwrap() error { return ErrStructuredOutput }
+
+var schemaNamePattern = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// Structured asks the model for a JSON value matching spec.Schema and decodes it
+// into out. Output that is not JSON or violates the schema is sent back to the
+// model together with the violations, up to spec.MaxRepairs times. Providers
+// that reject response_format or tool_choice (HTTP 400/422) are retried with
+// prompt-only instructions.
+func Structured(client LLMClient, messages []Message, spec StructuredSpec, out interface{}) error {
+	schema := jsonschema.Normalize(spec.Schema)
+	name := schemaNamePattern.ReplaceAllString(spec.Name, "_")
+	if name == "" {
+		name = "result"
+	}
+	repairs := spec.MaxRepairs
+	if repairs == 0 {
+		repairs = defaultMaxRepairs
+	} else if repairs < 0 {
+		repairs = 0
+	}
+
+	sc, ok := client.(StructuredClient)
+	mode := spec.Mode
+	if mode == "" {
+		mode = ModeJSONSchema
+	}
+	if !ok {
+		mode = ModePrompt
+	}
+
+	schemaJSON, _ := json.Marshal(schema)
+	base := append(append([]Message{}, messages...), Message{Role: "system", Content: instruction(mode, name, string(schemaJSON))})
+	msgs := base
+	var issues []string
+	var raw string
+	for attempt := 1; attempt <= repairs+1; attempt++ {
+		reply, err := structuredCall(client, sc, mode, msgs, spec, name, schema)
+		if err != nil {
+			var apiErr *APIError
+			if mode != ModePrompt && errors.As(err, &apiErr) &&
+				(apiErr.StatusCode == http.StatusBadRequest || apiErr.StatusCode == http.StatusUnprocessableEntity) {
+				log.Printf("[LLM] %s mode rejected for %q, falling back to prompt mode: %v", mode, name, err)
+				mode = ModePrompt
+				base[len(base)-1].Content = instruction(mode, name, string(schemaJSON))
+				msgs = base
+				attempt--
+				continue
+			}
+			return err
+		}
+
+		raw = reply.Content
+		if mode == ModeTool && len(reply.ToolCalls) > 0 {
+			raw = reply.ToolCalls[0].Function.Arguments
+		}
+		issues = decodeStructured(raw, schema, out)
+		if len(issues) == 0 {
+			return nil
+		}
+		log.Printf("[LLM] Structured output %q invalid (attempt %d): %s", name, attempt, strings.Join(issues, "; "))
+		msgs = append(msgs, repairMessages(mode, reply, issues)...)
+	}
+	return &StructuredError{Name: name, Attempts: repairs + 1, Issues: issues, Raw: raw}
+}
+
+func structuredCall(client LLMClient, sc StructuredClient, mode StructuredMode, msgs []Message, spec StructuredSpec, name string, schema map[string]interface{}) (*Message, error) {
+	switch mode {
+	case ModeJSONSchema:
+		return sc.ChatWithOptions(msgs, ChatOptions{ResponseFormat: &ResponseFormat{
+			Type: "json_schema", JSONSchema: &JSONSchema{Name: name, Schema: schema},
+		}})
+	case ModeTool:
+		var t Tool
+		t.Type = "function"
+		t.Function.Name = name
+		t.Function.Description = spec.Description
+		t.Function.Parameters = schema
+		return sc.ChatWithOptions(msgs, ChatOptions{
+			Tools:      []Tool{t},
+			ToolChoice: map[string]interface{}{"type": "function", "function": map[string]string{"name": name}},
+		})
+	}
+	content, err := client.ChatCompletion(msgs)
+	if err != nil {
+		return nil, err
+	}
+	return &Message{Role: "assistant", Content: content}, nil
+}
+
+func instruction(mode StructuredMode, name, schema string) string {
+	if mode == ModeTool {
+		return fmt.Sprintf("请调用 %s 工具提交结果，参数必须符合其 JSON Schema：\n%s", name, schema)
+	}
+	return fmt.Sprintf("请只输出一个符合以下 JSON Schema 的 JSON 值，不要使用 Markdown 代码块，也不要附加任何解释文字：\n%s", schema)
+}
+
+// repairMessages 将上一轮输出与校验问题回传给模型；工具模式下以 tool 消息回复该次调用
+func repairMessages(mode StructuredMode, reply *Message, issues []string) []Message {
+	feedback := "上一次输出不符合要求：\n- " + strings.Join(issues, "\n- ") + "\n请修正后重新输出完整结果。"
+	assistant := Message{Role: "assistant", Content: reply.Content, ToolCalls: reply.ToolCalls}
+	if mode == ModeTool && len(reply.ToolCalls) > 0 {
+		msgs := []Message{assistant}
+		for _, tc := range reply.ToolCalls {
+			msgs = append(msgs, Message{Role: "tool", ToolCallID: tc.ID, Content: feedback})
+		}
+		return msgs
+	}
+	assistant.ToolCalls = nil
+	return []Message{assistant, {Role: "user", Content: feedback}}
+}
+
+// decodeStructured 解析并校验输出，返回的问题列表为空表示 out 已填充
+func decodeStructured(raw string, schema map[string]interface{}, out interface{}) []string {
+	text := ExtractJSON(raw)
+	if text == "" {
+		return []string{"output does not contain a JSON value"}
+	}
+	var generic interface{}
+	if err := json.Unmarshal([]byte(text), &generic); err != nil {
+		return []string{fmt.Sprintf("invalid JSON: %v", err)}
+	}
+	if found := jsonschema.Check(schema, generic); len(found) > 0 {
+		issues := make([]string, len(found))
+		for i, issue := range found {
+			issues[i] = issue.String()
+		}
+		return issues
+	}
+	if err := json.Unmarshal([]byte(text), out); err != nil {
+		return []string{fmt.Sprintf("cannot decode into result: %v", err)}
+	}
+	return nil
+}
+
+// ExtractJSON strips Markdown code fences and surrounding prose, returning the
+// outermost JSON object or array in text, or "" if there is none.
+func ExtractJSON(text string) string {
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimPrefix(text, "```")
+		if i := strings.Index(text, "\n"); i >= 0 {
+			text = text[i+1:] // 去掉 ```json 语言标记
+		}
+		if i := strings.LastIndex(text, "```"); i >= 0 {
+			text = text[:i]
+		}
+		text = strings.TrimSpace(text)
+	}
+	start := strings.IndexAny(text, "{[")
+	if start < 0 {
+		return ""
+	}
+	closing := "}"
+	if text[start] == '[' {
+		closing = "]"
+	}
+	end := strings.LastIndex(text, closing)
+	if end < start {
+		return ""
+	}
+	return text[start : end+1]
+}
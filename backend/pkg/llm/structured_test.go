@@ -0,0 +1,172 @@
+package llm
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type structuredTestReport struct {
+	RiskLevel string   `json:"risk_level"`
+	Findings  []string `json:"findings"`
+}
+
+var structuredTestSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"risk_level": map[string]interface{}{"type": "string", "enum": []string{"low", "medium", "high"}},
+		"findings":   map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}, "minItems": 1},
+	},
+	"required": []string{"risk_level", "findings"},
+}
+
+// replyServer 按顺序返回预设的助手消息，并记录收到的请求
+func replyServer(t *testing.T, replies []Message, status []int) (*httptest.Server, *[]chatRequest) {
+	var requests []chatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req chatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		i := len(requests)
+		requests = append(requests, req)
+		if i < len(status) && status[i] != http.StatusOK {
+			w.WriteHeader(status[i])
+			json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]string{"message": "response_format is not supported"}})
+			return
+		}
+		if i >= len(replies) {
+			t.Fatalf("Unexpected request #%d", i+1)
+		}
+		resp := chatResponse{Choices: []struct {
+			Message Message `json:"message"`
+		}{{Message: replies[i]}}}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	return server, &requests
+}
+
+func TestStructured_RepairsInvalidOutput(t *testing.T) {
+	server, requests := replyServer(t, []Message{
+		{Role: "assistant", Content: `{"risk_level": "severe", "findings": []}`},
+		{Role: "assistant", Content: "```json\n{\"risk_level\": \"high\", \"findings\": [\"主轴温度持续升高\"]}\n```"},
+	}, nil)
+	defer server.Close()
+
+	var out structuredTestReport
+	client := NewOpenAIClient(server.URL, "key", "model")
+	err := Structured(client, []Message{{Role: "user", Content: "分析"}}, StructuredSpec{Name: "analysis report", Schema: structuredTestSchema}, &out)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if out.RiskLevel != "high" || len(out.Findings) != 1 {
+		t.Errorf("Unexpected result: %+v", out)
+	}
+	if len(*requests) != 2 {
+		t.Fatalf("Expected one repair retry, got %d requests", len(*requests))
+	}
+	first := (*requests)[0]
+	if first.ResponseFormat == nil || first.ResponseFormat.Type != "json_schema" || first.ResponseFormat.JSONSchema.Name != "analysis_report" {
+		t.Errorf("Expected json_schema response_format, got %+v", first.ResponseFormat)
+	}
+	repair := (*requests)[1].Messages
+	last := repair[len(repair)-1].Content
+	if !strings.Contains(last, "risk_level: must be one of") || !strings.Contains(last, "findings: must contain at least 1 items") {
+		t.Errorf("Expected validation issues in repair message, got %q", last)
+	}
+}
+
+func TestStructured_FallsBackWhenResponseFormatRejected(t *testing.T) {
+	server, requests := replyServer(t, []Message{
+		{},
+		{Role: "assistant", Content: `分析结果：{"risk_level": "low", "findings": ["运行平稳"]}`},
+	}, []int{http.StatusBadRequest})
+	defer server.Close()
+
+	var out structuredTestReport
+	client := NewOpenAIClient(server.URL, "key", "model")
+	if err := Structured(client, []Message{{Role: "user", Content: "分析"}}, StructuredSpec{Name: "report", Schema: structuredTestSchema, MaxRepairs: -1}, &out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if out.RiskLevel != "low" {
+		t.Errorf("Unexpected result: %+v", out)
+	}
+	if len(*requests) != 2 || (*requests)[1].ResponseFormat != nil {
+		t.Errorf("Expected a prompt-mode retry without response_format, got %d requests", len(*requests))
+	}
+}
+
+func TestStructured_ToolMode(t *testing.T) {
+	call := ToolCall{ID: "call_1", Type: "function"}
+	call.Function.Name = "report"
+	call.Function.Arguments = `{"risk_level": "medium"}`
+	fixed := call
+	fixed.ID = "call_2"
+	fixed.Function.Arguments = `{"risk_level": "medium", "findings": ["润滑不足"]}`
+	server, requests := replyServer(t, []Message{
+		{Role: "assistant", ToolCalls: []ToolCall{call}},
+		{Role: "assistant", ToolCalls: []ToolCall{fixed}},
+	}, nil)
+	defer server.Close()
+
+	var out structuredTestReport
+	client := NewOpenAIClient(server.URL, "key", "model")
+	if err := Structured(client, []Message{{Role: "user", Content: "分析"}}, StructuredSpec{Name: "report", Schema: structuredTestSchema, Mode: ModeTool}, &out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(out.Findings) != 1 || out.Findings[0] != "润滑不足" {
+		t.Errorf("Unexpected result: %+v", out)
+	}
+	first := (*requests)[0]
+	if len(first.Tools) != 1 || first.ToolChoice == nil {
+		t.Errorf("Expected a forced tool call, got tools=%v tool_choice=%v", first.Tools, first.ToolChoice)
+	}
+	repair := (*requests)[1].Messages
+	last := repair[len(repair)-1]
+	if last.Role != "tool" || last.ToolCallID != "call_1" || !strings.Contains(last.Content, "findings: is required") {
+		t.Errorf("Expected the issues as a tool reply, got %+v", last)
+	}
+}
+
+// plainClient 不支持 response_format 的客户端
+type plainClient struct {
+	replies []string
+	calls   int
+}
+
+func (c *plainClient) ChatCompletion(messages []Message) (string, error) {
+	c.calls++
+	return c.replies[c.calls-1], nil
+}
+
+func (c *plainClient) ChatWithTools(messages []Message, tools []Tool) (*Message, error) {
+	return nil, errors.New("not supported")
+}
+
+func TestStructured_GivesUpAfterRepairs(t *testing.T) {
+	client := &plainClient{replies: []string{"无法分析", `{"risk_level": 3}`, `{"risk_level": "high"}`}}
+	var out structuredTestReport
+	err := Structured(client, []Message{{Role: "user", Content: "分析"}}, StructuredSpec{Name: "report", Schema: structuredTestSchema}, &out)
+	if !errors.Is(err, ErrStructuredOutput) {
+		t.Fatalf("Expected ErrStructuredOutput, got %v", err)
+	}
+	var se *StructuredError
+	if !errors.As(err, &se) || se.Attempts != 3 || client.calls != 3 || se.Raw != `{"risk_level": "high"}` {
+		t.Errorf("Unexpected error details: %+v (calls=%d)", se, client.calls)
+	}
+}
+
+func TestExtractJSON(t *testing.T) {
+	cases := map[string]string{
+		`{"a": 1}`:             `{"a": 1}`,
+		"```json\n[1, 2]\n```": `[1, 2]`,
+		"结论如下：{\"a\": {\"b\": 2}} 供参考": `{"a": {"b": 2}}`,
+		"没有 JSON": "",
+	}
+	for in, want := range cases {
+		if got := ExtractJSON(in); got != want {
+			t.Errorf("ExtractJSON(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -50,10 +50,15 @@ type Store struct {
 	AgentConversations    map[uint]*model.AgentConversation
 	AgentMessages         map[uint]*model.AgentMessage
 	AgentPushSubscriptions map[uint]*model.AgentPushSubscription
+	AgentPushLogs          map[uint]*model.AgentPushLog
+	AgentPushDeadLetters   map[uint]*model.AgentPushDeadLetter
 	AgentUsages           map[uint]*model.AgentUsage
 	AgentArtifacts        map[uint]*model.AgentArtifact
 	AgentEvidenceLinks    map[uint]*model.AgentEvidenceLink
 	AgentSessions         map[uint]*model.AgentSession
+	AgentFeedbacks        map[uint]*model.AgentFeedback
+	AgentConversationShares map[uint]*model.AgentConversationShare
+	AgentEquipmentNotes     map[uint]*model.AgentEquipmentNote
 	
 	RuntimeSnapshots      map[uint]*model.EquipmentRuntimeSnapshot
 }
@@ -97,10 +102,15 @@ func GetStore() *Store {
 			AgentConversations:    make(map[uint]*model.AgentConversation),
 			AgentMessages:         make(map[uint]*model.AgentMessage),
 			AgentPushSubscriptions: make(map[uint]*model.AgentPushSubscription),
+			AgentPushLogs:          make(map[uint]*model.AgentPushLog),
+			AgentPushDeadLetters:   make(map[uint]*model.AgentPushDeadLetter),
 			AgentUsages:           make(map[uint]*model.AgentUsage),
 			AgentArtifacts:        make(map[uint]*model.AgentArtifact),
 			AgentEvidenceLinks:    make(map[uint]*model.AgentEvidenceLink),
 			AgentSessions:         make(map[uint]*model.AgentSession),
+			AgentFeedbacks:        make(map[uint]*model.AgentFeedback),
+			AgentConversationShares: make(map[uint]*model.AgentConversationShare),
+			AgentEquipmentNotes:     make(map[uint]*model.AgentEquipmentNote),
 			RuntimeSnapshots:      make(map[uint]*model.EquipmentRuntimeSnapshot),
 		}
 	})
@@ -0,0 +1,210 @@
+package report
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// DOCX 中文字体声明：Word 按 eastAsia 字体回退显示，不在文档内嵌字体文件以控制体积
+const docxEastAsiaFont = "Microsoft YaHei"
+
+const docxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>
+<Override PartName="/word/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.styles+xml"/>
+<Override PartName="/docProps/core.xml" ContentType="application/vnd.openxmlformats-package.core-properties+xml"/>
+</Types>`
+
+const docxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/package/2006/relationships/metadata/core-properties" Target="docProps/core.xml"/>
+</Relationships>`
+
+const docxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+<w:docDefaults><w:rPrDefault><w:rPr><w:rFonts w:ascii="Calibri" w:hAnsi="Calibri" w:eastAsia="` + docxEastAsiaFont + `" w:cs="Calibri"/><w:sz w:val="21"/><w:lang w:eastAsia="zh-CN"/></w:rPr></w:rPrDefault>
+<w:pPrDefault><w:pPr><w:spacing w:after="80" w:line="300" w:lineRule="auto"/></w:pPr></w:pPrDefault></w:docDefaults>
+<w:style w:type="paragraph" w:default="1" w:styleId="Normal"><w:name w:val="Normal"/></w:style>
+<w:style w:type="paragraph" w:styleId="Title"><w:name w:val="Title"/><w:basedOn w:val="Normal"/><w:pPr><w:spacing w:after="120"/></w:pPr><w:rPr><w:b/><w:sz w:val="36"/></w:rPr></w:style>
+<w:style w:type="paragraph" w:styleId="Heading1"><w:name w:val="heading 1"/><w:basedOn w:val="Normal"/><w:pPr><w:keepNext/><w:spacing w:before="240" w:after="120"/><w:pBdr><w:bottom w:val="single" w:sz="6" w:space="1" w:color="1860AA"/></w:pBdr><w:outlineLvl w:val="0"/></w:pPr><w:rPr><w:b/><w:color w:val="1860AA"/><w:sz w:val="28"/></w:rPr></w:style>
+<w:style w:type="character" w:styleId="Hyperlink"><w:name w:val="Hyperlink"/><w:rPr><w:color w:val="1860AA"/><w:u w:val="single"/></w:rPr></w:style>
+<w:style w:type="table" w:styleId="ReportTable"><w:name w:val="Report Table"/><w:tblPr><w:tblBorders><w:top w:val="single" w:sz="4" w:color="C8C8C8"/><w:left w:val="single" w:sz="4" w:color="C8C8C8"/><w:bottom w:val="single" w:sz="4" w:color="C8C8C8"/><w:right w:val="single" w:sz="4" w:color="C8C8C8"/><w:insideH w:val="single" w:sz="4" w:color="C8C8C8"/><w:insideV w:val="single" w:sz="4" w:color="C8C8C8"/></w:tblBorders></w:tblPr></w:style>
+</w:styles>`
+
+// docxWriter 累积 document.xml 正文与超链接关系
+type docxWriter struct {
+	body  strings.Builder
+	links []string
+}
+
+func xmlEscape(s string) string {
+	var b bytes.Buffer
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func (w *docxWriter) run(text string, props string) string {
+	return fmt.Sprintf(`<w:r><w:rPr>%s</w:rPr><w:t xml:space="preserve">%s</w:t></w:r>`, props, xmlEscape(text))
+}
+
+func (w *docxWriter) paragraph(style string, runs ...string) {
+	w.body.WriteString("<w:p>")
+	if style != "" {
+		fmt.Fprintf(&w.body, `<w:pPr><w:pStyle w:val="%s"/></w:pPr>`, style)
+	}
+	for _, r := range runs {
+		w.body.WriteString(r)
+	}
+	w.body.WriteString("</w:p>")
+}
+
+func (w *docxWriter) hyperlink(text, url string) string {
+	w.links = append(w.links, url)
+	id := fmt.Sprintf("rIdLink%d", len(w.links))
+	return fmt.Sprintf(`<w:hyperlink r:id="%s">%s</w:hyperlink>`, id, w.run(text, `<w:rStyle w:val="Hyperlink"/>`))
+}
+
+func (w *docxWriter) table(t Table) {
+	w.body.WriteString(`<w:tbl><w:tblPr><w:tblStyle w:val="ReportTable"/><w:tblW w:w="5000" w:type="pct"/></w:tblPr><w:tblGrid>`)
+	for range t.Headers {
+		w.body.WriteString(`<w:gridCol/>`)
+	}
+	w.body.WriteString(`</w:tblGrid>`)
+
+	writeRow := func(cells []string, header bool) {
+		w.body.WriteString("<w:tr>")
+		if header {
+			w.body.WriteString(`<w:trPr><w:tblHeader/></w:trPr>`)
+		}
+		for _, cell := range cells {
+			w.body.WriteString("<w:tc>")
+			props := ""
+			if header {
+				w.body.WriteString(`<w:tcPr><w:shd w:val="clear" w:color="auto" w:fill="EBF1FA"/></w:tcPr>`)
+				props = `<w:b/><w:color w:val="1860AA"/>`
+			}
+			w.paragraph("", w.run(oneLine(cell), props+`<w:sz w:val="18"/>`))
+			w.body.WriteString("</w:tc>")
+		}
+		w.body.WriteString("</w:tr>")
+	}
+
+	writeRow(t.Headers, true)
+	for _, row := range t.Rows {
+		writeRow(padRow(row, len(t.Headers)), false)
+	}
+	w.body.WriteString("</w:tbl>")
+	w.paragraph("")
+}
+
+// RenderDOCX 渲染为 Word 文档（纯 WordprocessingML，无模板依赖）
+func RenderDOCX(r *Report) ([]byte, error) {
+	w := &docxWriter{}
+	muted := `<w:color w:val="6E6E6E"/><w:sz w:val="18"/>`
+
+	w.paragraph("", w.run(r.Brand.Name+"  ｜  "+r.GeneratedAt.Format("2006-01-02 15:04"), `<w:color w:val="1860AA"/><w:sz w:val="18"/>`))
+	w.paragraph("Title", w.run(r.Title, ""))
+	if r.Subtitle != "" {
+		w.paragraph("", w.run(r.Subtitle, muted))
+	}
+	rc := riskColor(r.RiskLevel)
+	w.paragraph("", w.run("风险等级：", `<w:b/>`),
+		w.run(RiskLabel(r.RiskLevel), fmt.Sprintf(`<w:b/><w:color w:val="%02X%02X%02X"/>`, rc[0], rc[1], rc[2])))
+
+	if r.Summary != "" {
+		w.paragraph("Heading1", w.run("摘要", ""))
+		for _, line := range strings.Split(strings.TrimSpace(r.Summary), "\n") {
+			if strings.TrimSpace(line) != "" {
+				w.paragraph("", w.run(line, ""))
+			}
+		}
+	}
+
+	if len(r.Findings) > 0 {
+		w.paragraph("Heading1", w.run("关键发现", ""))
+		for i, f := range r.Findings {
+			w.paragraph("", w.run(fmt.Sprintf("%d. %s", i+1, oneLine(f)), ""))
+		}
+	}
+
+	for _, t := range r.Tables {
+		if len(t.Headers) == 0 {
+			continue
+		}
+		w.paragraph("Heading1", w.run(t.Title, ""))
+		w.table(t)
+	}
+
+	if len(r.Citations) > 0 {
+		w.paragraph("Heading1", w.run("证据引用", ""))
+		for _, c := range r.Citations {
+			title := c.Title
+			if title == "" {
+				title = c.Source
+			}
+			runs := []string{w.run(fmt.Sprintf("[%d] ", c.Index), "")}
+			if c.URL != "" {
+				runs = append(runs, w.hyperlink(title, c.URL))
+			} else {
+				runs = append(runs, w.run(title, ""))
+			}
+			if c.Source != "" && c.Source != title {
+				runs = append(runs, w.run("  "+c.Source, muted))
+			}
+			w.paragraph("", runs...)
+			if c.Excerpt != "" {
+				w.paragraph("", w.run("    "+oneLine(truncate(c.Excerpt, 200)), muted))
+			}
+		}
+	}
+
+	w.paragraph("", w.run(r.Brand.Footer, muted))
+
+	document := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><w:body>` +
+		w.body.String() +
+		`<w:sectPr><w:pgSz w:w="11906" w:h="16838"/><w:pgMar w:top="1440" w:right="1200" w:bottom="1440" w:left="1200" w:header="720" w:footer="720" w:gutter="0"/></w:sectPr></w:body></w:document>`
+
+	var rels strings.Builder
+	rels.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rIdStyles" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`)
+	for i, url := range w.links {
+		fmt.Fprintf(&rels, `<Relationship Id="rIdLink%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink" Target="%s" TargetMode="External"/>`, i+1, xmlEscape(url))
+	}
+	rels.WriteString(`</Relationships>`)
+
+	core := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:dcterms="http://purl.org/dc/terms/" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"><dc:title>%s</dc:title><dc:creator>%s</dc:creator><dcterms:created xsi:type="dcterms:W3CDTF">%s</dcterms:created></cp:coreProperties>`,
+		xmlEscape(r.Title), xmlEscape(r.Brand.Name), r.GeneratedAt.UTC().Format("2006-01-02T15:04:05Z"))
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", docxContentTypes},
+		{"_rels/.rels", docxRootRels},
+		{"docProps/core.xml", core},
+		{"word/document.xml", document},
+		{"word/styles.xml", docxStyles},
+		{"word/_rels/document.xml.rels", rels.String()},
+	}
+	for _, p := range parts {
+		f, err := zw.Create(p.name)
+		if err != nil {
+			return nil, fmt.Errorf("render docx: %w", err)
+		}
+		if _, err := f.Write([]byte(p.content)); err != nil {
+			return nil, fmt.Errorf("render docx: %w", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("render docx: %w", err)
+	}
+	return buf.Bytes(), nil
+}
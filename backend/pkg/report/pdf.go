@@ -0,0 +1,222 @@
+package report
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// 文泉驿微米黑（Apache-2.0 / GPLv3 双许可），用于 PDF 中的中日韩字符，
+// fpdf 输出时只嵌入实际用到的字形子集
+//
+//go:embed fonts/wqy-microhei.ttf
+var cjkFont []byte
+
+const (
+	pdfFont       = "wqy"
+	pdfLineHeight = 6.0
+	pdfCellHeight = 5.5
+)
+
+var (
+	brandColor = [3]int{24, 96, 170}
+	mutedColor = [3]int{110, 110, 110}
+)
+
+func riskColor(level string) [3]int {
+	switch strings.ToLower(level) {
+	case "high", "critical":
+		return [3]int{200, 40, 40}
+	case "medium":
+		return [3]int{225, 140, 20}
+	case "low":
+		return [3]int{40, 150, 70}
+	}
+	return mutedColor
+}
+
+// RenderPDF 渲染为 A4 PDF，字体内嵌，无需外部依赖
+func RenderPDF(r *Report) ([]byte, error) {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8FontFromBytes(pdfFont, "", cjkFont)
+	pdf.SetTitle(r.Title, true)
+	pdf.SetCreator(r.Brand.Name, true)
+	pdf.SetMargins(18, 22, 18)
+	pdf.SetAutoPageBreak(true, 18)
+	pdf.AliasNbPages("{nb}")
+
+	pageW, _ := pdf.GetPageSize()
+	left, _, right, _ := pdf.GetMargins()
+	contentW := pageW - left - right
+
+	pdf.SetHeaderFuncMode(func() {
+		pdf.SetFillColor(brandColor[0], brandColor[1], brandColor[2])
+		pdf.Rect(0, 0, pageW, 4, "F")
+		pdf.SetXY(left, 8)
+		pdf.SetFont(pdfFont, "", 9)
+		pdf.SetTextColor(brandColor[0], brandColor[1], brandColor[2])
+		pdf.CellFormat(contentW/2, 6, r.Brand.Name, "", 0, "L", false, 0, "")
+		pdf.SetTextColor(mutedColor[0], mutedColor[1], mutedColor[2])
+		pdf.CellFormat(contentW/2, 6, r.GeneratedAt.Format("2006-01-02 15:04"), "", 1, "R", false, 0, "")
+		pdf.SetDrawColor(220, 220, 220)
+		pdf.Line(left, 15, pageW-right, 15)
+		pdf.SetY(22)
+	}, true)
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-12)
+		pdf.SetFont(pdfFont, "", 8)
+		pdf.SetTextColor(mutedColor[0], mutedColor[1], mutedColor[2])
+		pdf.CellFormat(contentW*0.7, 5, r.Brand.Footer, "", 0, "L", false, 0, "")
+		pdf.CellFormat(contentW*0.3, 5, fmt.Sprintf("第 %d 页 / 共 {nb} 页", pdf.PageNo()), "", 0, "R", false, 0, "")
+	})
+
+	pdf.AddPage()
+
+	// 标题与风险等级
+	pdf.SetFont(pdfFont, "", 18)
+	pdf.SetTextColor(30, 30, 30)
+	pdf.MultiCell(contentW, 9, r.Title, "", "L", false)
+	if r.Subtitle != "" {
+		pdf.SetFont(pdfFont, "", 10)
+		pdf.SetTextColor(mutedColor[0], mutedColor[1], mutedColor[2])
+		pdf.MultiCell(contentW, pdfLineHeight, r.Subtitle, "", "L", false)
+	}
+	pdf.Ln(2)
+	rc := riskColor(r.RiskLevel)
+	pdf.SetFont(pdfFont, "", 10)
+	pdf.SetFillColor(rc[0], rc[1], rc[2])
+	pdf.SetTextColor(255, 255, 255)
+	label := "风险等级：" + RiskLabel(r.RiskLevel)
+	pdf.CellFormat(pdf.GetStringWidth(label)+8, 7, label, "", 1, "C", true, 0, "")
+	pdf.Ln(4)
+
+	if r.Summary != "" {
+		pdfSection(pdf, "摘要")
+		pdfParagraph(pdf, contentW, strings.TrimSpace(r.Summary))
+	}
+
+	if len(r.Findings) > 0 {
+		pdfSection(pdf, "关键发现")
+		for i, f := range r.Findings {
+			pdfParagraph(pdf, contentW, fmt.Sprintf("%d. %s", i+1, oneLine(f)))
+		}
+	}
+
+	for _, t := range r.Tables {
+		if len(t.Headers) == 0 {
+			continue
+		}
+		pdfSection(pdf, t.Title)
+		pdfTable(pdf, contentW, t)
+	}
+
+	if len(r.Citations) > 0 {
+		pdfSection(pdf, "证据引用")
+		for _, c := range r.Citations {
+			title := c.Title
+			if title == "" {
+				title = c.Source
+			}
+			pdf.SetFont(pdfFont, "", 10)
+			pdf.SetTextColor(30, 30, 30)
+			pdf.Write(pdfLineHeight, fmt.Sprintf("[%d] ", c.Index))
+			if c.URL != "" {
+				pdf.SetTextColor(brandColor[0], brandColor[1], brandColor[2])
+				pdf.WriteLinkString(pdfLineHeight, title, c.URL)
+			} else {
+				pdf.Write(pdfLineHeight, title)
+			}
+			if c.Source != "" && c.Source != title {
+				pdf.SetTextColor(mutedColor[0], mutedColor[1], mutedColor[2])
+				pdf.Write(pdfLineHeight, "  "+c.Source)
+			}
+			pdf.Ln(pdfLineHeight)
+			if c.Excerpt != "" {
+				pdf.SetFont(pdfFont, "", 9)
+				pdf.SetTextColor(mutedColor[0], mutedColor[1], mutedColor[2])
+				pdf.SetX(left + 6)
+				pdf.MultiCell(contentW-6, 5, oneLine(truncate(c.Excerpt, 200)), "", "L", false)
+			}
+			pdf.Ln(1)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, fmt.Errorf("render pdf: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func pdfSection(pdf *fpdf.Fpdf, title string) {
+	pdf.Ln(2)
+	pdf.SetFont(pdfFont, "", 13)
+	pdf.SetTextColor(brandColor[0], brandColor[1], brandColor[2])
+	pdf.CellFormat(0, 8, title, "B", 1, "L", false, 0, "")
+	pdf.Ln(2)
+}
+
+func pdfParagraph(pdf *fpdf.Fpdf, w float64, text string) {
+	pdf.SetFont(pdfFont, "", 10)
+	pdf.SetTextColor(30, 30, 30)
+	pdf.MultiCell(w, pdfLineHeight, text, "", "L", false)
+	pdf.Ln(1)
+}
+
+// pdfTable 等宽列表格，单元格内自动换行，跨页时重复表头
+func pdfTable(pdf *fpdf.Fpdf, w float64, t Table) {
+	colW := w / float64(len(t.Headers))
+	_, pageH := pdf.GetPageSize()
+	_, _, _, bottom := pdf.GetMargins()
+
+	var drawRow func(cells []string, header bool)
+	drawRow = func(cells []string, header bool) {
+		pdf.SetFont(pdfFont, "", 9)
+		lines := make([][]string, len(cells))
+		maxLines := 1
+		for i, cell := range cells {
+			lines[i] = pdf.SplitText(oneLine(cell), colW)
+			if len(lines[i]) > maxLines {
+				maxLines = len(lines[i])
+			}
+		}
+		rowH := float64(maxLines) * pdfCellHeight
+		if pdf.GetY()+rowH > pageH-bottom {
+			pdf.AddPage()
+			if !header {
+				drawRow(t.Headers, true)
+			}
+		}
+
+		x, y := pdf.GetX(), pdf.GetY()
+		if header {
+			pdf.SetFillColor(235, 241, 250)
+			pdf.SetTextColor(brandColor[0], brandColor[1], brandColor[2])
+		} else {
+			pdf.SetTextColor(30, 30, 30)
+		}
+		pdf.SetDrawColor(200, 200, 200)
+		for i := range cells {
+			cx := x + float64(i)*colW
+			style := "D"
+			if header {
+				style = "FD"
+			}
+			pdf.Rect(cx, y, colW, rowH, style)
+			for j, line := range lines[i] {
+				pdf.SetXY(cx, y+float64(j)*pdfCellHeight)
+				pdf.CellFormat(colW, pdfCellHeight, line, "", 0, "L", false, 0, "")
+			}
+		}
+		pdf.SetXY(x, y+rowH)
+	}
+
+	drawRow(t.Headers, true)
+	for _, row := range t.Rows {
+		drawRow(padRow(row, len(t.Headers)), false)
+	}
+	pdf.Ln(3)
+}
@@ -0,0 +1,185 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 支持的导出格式
+const (
+	FormatPDF      = "pdf"
+	FormatDOCX     = "docx"
+	FormatMarkdown = "md"
+)
+
+// Brand 报告品牌信息（页眉、页脚）
+type Brand struct {
+	Name   string
+	Footer string
+}
+
+// DefaultBrand 默认品牌
+var DefaultBrand = Brand{
+	Name:   "EMS 设备管理系统",
+	Footer: "由 EMS 智能助手生成，仅供参考",
+}
+
+// Table 报告中的数据表格
+type Table struct {
+	Title   string
+	Headers []string
+	Rows    [][]string
+}
+
+// Citation 编号证据引用
+type Citation struct {
+	Index   int
+	Title   string
+	Source  string // 如 repair_orders#12
+	Excerpt string
+	URL     string
+}
+
+// Report 与格式无关的报告内容
+type Report struct {
+	Brand       Brand
+	Title       string
+	Subtitle    string
+	RiskLevel   string
+	Summary     string
+	Findings    []string
+	Tables      []Table
+	Citations   []Citation
+	GeneratedAt time.Time
+}
+
+// Render 按格式渲染报告，返回内容、MIME 类型与文件扩展名
+func Render(r *Report, format string) ([]byte, string, string, error) {
+	if r.Brand.Name == "" {
+		r.Brand = DefaultBrand
+	}
+	if r.GeneratedAt.IsZero() {
+		r.GeneratedAt = time.Now()
+	}
+
+	switch format {
+	case FormatMarkdown:
+		return []byte(RenderMarkdown(r)), "text/markdown; charset=utf-8", "md", nil
+	case FormatDOCX:
+		data, err := RenderDOCX(r)
+		return data, "application/vnd.openxmlformats-officedocument.wordprocessingml.document", "docx", err
+	case FormatPDF:
+		data, err := RenderPDF(r)
+		return data, "application/pdf", "pdf", err
+	}
+	return nil, "", "", fmt.Errorf("unsupported export format: %s", format)
+}
+
+// RiskLabel 风险等级的中文标签
+func RiskLabel(level string) string {
+	switch strings.ToLower(level) {
+	case "high", "critical":
+		return "高风险"
+	case "medium":
+		return "中风险"
+	case "low":
+		return "低风险"
+	case "":
+		return "未评估"
+	}
+	return level
+}
+
+// RenderMarkdown 渲染为 Markdown
+func RenderMarkdown(r *Report) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# %s\n\n", r.Title)
+	if r.Subtitle != "" {
+		fmt.Fprintf(&b, "_%s_\n\n", r.Subtitle)
+	}
+	fmt.Fprintf(&b, "> %s ｜ 风险等级：**%s** ｜ 生成时间：%s\n\n", r.Brand.Name, RiskLabel(r.RiskLevel), r.GeneratedAt.Format("2006-01-02 15:04"))
+
+	if r.Summary != "" {
+		b.WriteString("## 摘要\n\n")
+		b.WriteString(strings.TrimSpace(r.Summary))
+		b.WriteString("\n\n")
+	}
+
+	if len(r.Findings) > 0 {
+		b.WriteString("## 关键发现\n\n")
+		for i, f := range r.Findings {
+			fmt.Fprintf(&b, "%d. %s\n", i+1, oneLine(f))
+		}
+		b.WriteString("\n")
+	}
+
+	for _, t := range r.Tables {
+		if len(t.Headers) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "## %s\n\n", t.Title)
+		b.WriteString("| " + strings.Join(escapeCells(t.Headers), " | ") + " |\n")
+		b.WriteString("|" + strings.Repeat(" --- |", len(t.Headers)) + "\n")
+		for _, row := range t.Rows {
+			b.WriteString("| " + strings.Join(escapeCells(padRow(row, len(t.Headers))), " | ") + " |\n")
+		}
+		b.WriteString("\n")
+	}
+
+	if len(r.Citations) > 0 {
+		b.WriteString("## 证据引用\n\n")
+		for _, c := range r.Citations {
+			title := c.Title
+			if title == "" {
+				title = c.Source
+			}
+			if c.URL != "" {
+				fmt.Fprintf(&b, "[%d] [%s](%s)", c.Index, title, c.URL)
+			} else {
+				fmt.Fprintf(&b, "[%d] %s", c.Index, title)
+			}
+			if c.Source != "" && c.Source != title {
+				fmt.Fprintf(&b, " (`%s`)", c.Source)
+			}
+			b.WriteString("\n")
+			if c.Excerpt != "" {
+				fmt.Fprintf(&b, "    > %s\n", oneLine(truncate(c.Excerpt, 200)))
+			}
+		}
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(&b, "---\n\n%s\n", r.Brand.Footer)
+	return b.String()
+}
+
+func escapeCells(cells []string) []string {
+	out := make([]string, len(cells))
+	for i, c := range cells {
+		out[i] = strings.ReplaceAll(oneLine(c), "|", "\\|")
+	}
+	return out
+}
+
+func padRow(row []string, n int) []string {
+	if len(row) >= n {
+		return row[:n]
+	}
+	out := make([]string, n)
+	copy(out, row)
+	return out
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func truncate(s string, maxRunes int) string {
+	runes := []rune(s)
+	if len(runes) <= maxRunes {
+		return s
+	}
+	return string(runes[:maxRunes]) + "…"
+}
@@ -0,0 +1,104 @@
+package report
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sampleReport() *Report {
+	return &Report{
+		Title:     "设备健康诊断：CNC-01",
+		Subtitle:  "场景：predictive_maintenance",
+		RiskLevel: "high",
+		Summary:   "主轴振动持续升高，预计 12 天内需要维护。",
+		Findings:  []string{"振动值超过阈值 30%", "近 30 天故障 3 次"},
+		Tables: []Table{{
+			Title:   "风险因素",
+			Headers: []string{"因素", "权重"},
+			Rows:    [][]string{{"振动 | 异常", "0.6"}, {"温度"}},
+		}},
+		Citations: []Citation{
+			{Index: 1, Title: "维修工单 #12", Source: "repair_orders#12", Excerpt: "更换主轴轴承", URL: "https://ems.example.com/repair/orders?id=12"},
+			{Index: 2, Source: "equipment#1"},
+		},
+		GeneratedAt: time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	md := RenderMarkdown(sampleReport())
+
+	for _, want := range []string{
+		"# 设备健康诊断：CNC-01",
+		"**高风险**",
+		"1. 振动值超过阈值 30%",
+		"| 振动 \\| 异常 | 0.6 |",
+		"| 温度 |  |",
+		"[1] [维修工单 #12](https://ems.example.com/repair/orders?id=12) (`repair_orders#12`)",
+		"[2] equipment#1",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("Expected markdown to contain %q, got:\n%s", want, md)
+		}
+	}
+}
+
+func TestRenderDOCX(t *testing.T) {
+	data, _, ext, err := Render(sampleReport(), FormatDOCX)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if ext != "docx" {
+		t.Errorf("Expected docx extension, got %s", ext)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Expected a valid zip archive: %v", err)
+	}
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		content, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(content)
+	}
+
+	doc, ok := files["word/document.xml"]
+	if !ok {
+		t.Fatal("Expected word/document.xml in archive")
+	}
+	if !strings.Contains(doc, "设备健康诊断：CNC-01") || !strings.Contains(doc, "<w:tbl>") {
+		t.Errorf("Expected title and table in document.xml")
+	}
+	if !strings.Contains(files["word/_rels/document.xml.rels"], "https://ems.example.com/repair/orders?id=12") {
+		t.Errorf("Expected hyperlink relationship for citation URL")
+	}
+}
+
+func TestRenderPDF(t *testing.T) {
+	data, mime, _, err := Render(sampleReport(), FormatPDF)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if mime != "application/pdf" {
+		t.Errorf("Expected application/pdf, got %s", mime)
+	}
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		t.Errorf("Expected PDF header")
+	}
+	// 字体应做子集化，输出远小于完整字体文件
+	if len(data) >= len(cjkFont) {
+		t.Errorf("Expected subsetted font, pdf size %d >= font size %d", len(data), len(cjkFont))
+	}
+}
+
+func TestRenderUnsupportedFormat(t *testing.T) {
+	if _, _, _, err := Render(sampleReport(), "xlsx"); err == nil {
+		t.Error("Expected error for unsupported format")
+	}
+}
@@ -5,10 +5,20 @@ export interface TimeRange {
   end_date: string
 }
 
+// 当前页面上下文：服务端按权限解析选中的对象并注入对话
+export interface ChatContext {
+  route?: string
+  equipment_ids?: number[]
+  repair_order_ids?: number[]
+  maintenance_task_ids?: number[]
+  spare_part_ids?: number[]
+  filters?: Record<string, string>
+}
+
 export interface ChatRequest {
   conversation_id?: number
   message: string
-  context?: any
+  context?: ChatContext
   system_prompt?: string
 }
 
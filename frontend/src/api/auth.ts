@@ -78,7 +78,7 @@ export const authApi = {
   refreshToken: (token: string) => request.post<{ token: string; expire_at: number }>('/auth/refresh', { token }),
   changePassword: (data: ChangePasswordRequest) => request.post('/auth/change-password', data),
   applyAccount: (data: ApplyAccountRequest) => request.post('/auth/apply', data),
-  bindLark: (openid: string) => request.post('/auth/bind-lark', { openid }),
+  bindLark: (openid: string, appId?: string) => request.post('/auth/bind-lark', { openid, app_id: appId || undefined }),
   getLarkConfig: () => request.get<LarkConfigResp>('/auth/lark-config'),
   updateLarkConfig: (data: LarkConfigReq) => request.put('/auth/lark-config', data),
 }